@@ -0,0 +1,236 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/cloudway/platform/api/types"
+)
+
+// GetConfig returns the platform configuration as a map of "section.key"
+// to value. Requires administrator privilege.
+func (api *APIClient) GetConfig(ctx context.Context) (map[string]string, error) {
+	var cfg map[string]string
+	resp, err := api.cli.Get(ctx, "/admin/config", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&cfg)
+		resp.EnsureClosed()
+	}
+	return cfg, err
+}
+
+// UpdateConfig changes the platform configuration. A key with an empty
+// value is removed. Requires administrator privilege.
+func (api *APIClient) UpdateConfig(ctx context.Context, changes map[string]string) error {
+	resp, err := api.cli.Put(ctx, "/admin/config", nil, changes, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// GetNodes returns status of container engine nodes. Requires administrator
+// privilege.
+func (api *APIClient) GetNodes(ctx context.Context) ([]*types.NodeStatus, error) {
+	var nodes []*types.NodeStatus
+	resp, err := api.cli.Get(ctx, "/admin/nodes", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&nodes)
+		resp.EnsureClosed()
+	}
+	return nodes, err
+}
+
+// Upgrade upgrades application containers in batches. The first canary
+// applications are upgraded before pausing the upgrade. Zero values use
+// server defaults. Requires administrator privilege.
+func (api *APIClient) Upgrade(ctx context.Context, batch, canary int, timeout time.Duration, dstout, dsterr io.Writer) error {
+	query := url.Values{}
+	if batch > 0 {
+		query.Set("batch", strconv.Itoa(batch))
+	}
+	if canary > 0 {
+		query.Set("canary", strconv.Itoa(canary))
+	}
+	if timeout > 0 {
+		query.Set("timeout", timeout.String())
+	}
+
+	resp, err := api.cli.Post(ctx, "/admin/upgrade", query, nil, nil)
+	if err != nil {
+		return err
+	}
+	err = api.drain(resp.Body, dstout, dsterr, nil)
+	resp.Body.Close()
+	return err
+}
+
+// PauseUpgrade pauses the upgrade in progress after the current batch.
+func (api *APIClient) PauseUpgrade(ctx context.Context) error {
+	resp, err := api.cli.Post(ctx, "/admin/upgrade/pause", nil, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// ResumeUpgrade resumes the paused upgrade.
+func (api *APIClient) ResumeUpgrade(ctx context.Context) error {
+	resp, err := api.cli.Post(ctx, "/admin/upgrade/resume", nil, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// AbortUpgrade aborts the upgrade in progress after the current batch.
+func (api *APIClient) AbortUpgrade(ctx context.Context) error {
+	resp, err := api.cli.Post(ctx, "/admin/upgrade/abort", nil, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// UnlockUser unlocks a user account locked due to too many failed login
+// attempts. Requires administrator privilege.
+func (api *APIClient) UnlockUser(ctx context.Context, name string) error {
+	resp, err := api.cli.Post(ctx, "/admin/users/"+pathEscape(name)+"/unlock", nil, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// AdminLockDeployments locks deployments of any application addressed by
+// the full name "name-namespace". Requires administrator privilege.
+func (api *APIClient) AdminLockDeployments(ctx context.Context, fullname, reason string) error {
+	var query url.Values
+	if reason != "" {
+		query = url.Values{"reason": {reason}}
+	}
+	resp, err := api.cli.Post(ctx, "/admin/applications/"+pathEscape(fullname)+"/deploy/lock", query, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// AdminUnlockDeployments unlocks deployments of any application addressed
+// by the full name. Requires administrator privilege.
+func (api *APIClient) AdminUnlockDeployments(ctx context.Context, fullname string) error {
+	resp, err := api.cli.Delete(ctx, "/admin/applications/"+pathEscape(fullname)+"/deploy/lock", nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// ResetPassword resets the password of a user. Requires administrator
+// privilege.
+func (api *APIClient) ResetPassword(ctx context.Context, name, password string) error {
+	req := types.ResetPassword{Password: password}
+	resp, err := api.cli.Put(ctx, "/admin/users/"+pathEscape(name)+"/password", nil, req, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// SetNamespaceDomain delegates the domain to the namespace, or removes the
+// delegation if the domain is empty. Requires administrator privilege.
+func (api *APIClient) SetNamespaceDomain(ctx context.Context, namespace, domain string) error {
+	path := "/admin/namespaces/" + pathEscape(namespace) + "/domain"
+	if domain == "" {
+		resp, err := api.cli.Delete(ctx, path, nil, nil)
+		resp.EnsureClosed()
+		return err
+	}
+	resp, err := api.cli.Put(ctx, path, nil, types.NamespaceDomain{Domain: domain}, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// GetInvites returns invitations that have not been used. Requires
+// administrator privilege.
+func (api *APIClient) GetInvites(ctx context.Context) ([]*types.Invite, error) {
+	var invites []*types.Invite
+	resp, err := api.cli.Get(ctx, "/admin/invites", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&invites)
+		resp.EnsureClosed()
+	}
+	return invites, err
+}
+
+// CreateInvite creates a single-use invitation to register a user. The
+// returned invitation contains the registration link. Requires
+// administrator privilege.
+func (api *APIClient) CreateInvite(ctx context.Context, req types.CreateInvite) (*types.Invite, error) {
+	var inv types.Invite
+	resp, err := api.cli.Post(ctx, "/admin/invites", nil, req, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&inv)
+		resp.EnsureClosed()
+	}
+	return &inv, err
+}
+
+// RemoveInvite revokes an invitation. Requires administrator privilege.
+func (api *APIClient) RemoveInvite(ctx context.Context, id string) error {
+	resp, err := api.cli.Delete(ctx, "/admin/invites/"+pathEscape(id), nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// ListServiceAccounts returns all service accounts. Requires administrator
+// privilege.
+func (api *APIClient) ListServiceAccounts(ctx context.Context) ([]*types.ServiceAccount, error) {
+	var accounts []*types.ServiceAccount
+	resp, err := api.cli.Get(ctx, "/admin/serviceaccounts", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&accounts)
+		resp.EnsureClosed()
+	}
+	return accounts, err
+}
+
+// CreateServiceAccount creates a service account. The returned service
+// account contains the token, which can't be retrieved again. Requires
+// administrator privilege.
+func (api *APIClient) CreateServiceAccount(ctx context.Context, req types.CreateServiceAccount) (*types.ServiceAccount, error) {
+	var sa types.ServiceAccount
+	resp, err := api.cli.Post(ctx, "/admin/serviceaccounts", nil, req, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&sa)
+		resp.EnsureClosed()
+	}
+	return &sa, err
+}
+
+// RemoveServiceAccount removes a service account and revokes its token.
+// Requires administrator privilege.
+func (api *APIClient) RemoveServiceAccount(ctx context.Context, name string) error {
+	resp, err := api.cli.Delete(ctx, "/admin/serviceaccounts/"+pathEscape(name), nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// ListNotices returns all platform notices including scheduled and expired
+// ones. Requires administrator privilege.
+func (api *APIClient) ListNotices(ctx context.Context) ([]*types.Notice, error) {
+	var notices []*types.Notice
+	resp, err := api.cli.Get(ctx, "/admin/notices", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&notices)
+		resp.EnsureClosed()
+	}
+	return notices, err
+}
+
+// CreateNotice creates a notice shown to all users. Requires administrator
+// privilege.
+func (api *APIClient) CreateNotice(ctx context.Context, req types.CreateNotice) (*types.Notice, error) {
+	var notice types.Notice
+	resp, err := api.cli.Post(ctx, "/admin/notices", nil, req, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&notice)
+		resp.EnsureClosed()
+	}
+	return &notice, err
+}
+
+// RemoveNotice removes a notice. Requires administrator privilege.
+func (api *APIClient) RemoveNotice(ctx context.Context, id string) error {
+	resp, err := api.cli.Delete(ctx, "/admin/notices/"+pathEscape(id), nil, nil)
+	resp.EnsureClosed()
+	return err
+}
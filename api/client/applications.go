@@ -4,15 +4,23 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"net/http"
 	"net/url"
+	"strconv"
 
 	"github.com/cloudway/platform/api/types"
-	"github.com/cloudway/platform/pkg/serverlog"
 )
 
-func (api *APIClient) GetApplications(ctx context.Context) ([]string, error) {
+// GetApplications returns names of applications matching all the label
+// selectors, such as "env=prod", "env!=prod" or "env".
+func (api *APIClient) GetApplications(ctx context.Context, labels ...string) ([]string, error) {
+	var query url.Values
+	if len(labels) != 0 {
+		query = url.Values{"label": labels}
+	}
+
 	var apps []string
-	resp, err := api.cli.Get(ctx, "/applications/", nil, nil)
+	resp, err := api.cli.Get(ctx, "/applications/", query, nil)
 	if err == nil {
 		err = json.NewDecoder(resp.Body).Decode(&apps)
 		resp.EnsureClosed()
@@ -33,28 +41,173 @@ func (api *APIClient) GetApplicationInfo(ctx context.Context, name string) (*typ
 func (api *APIClient) CreateApplication(ctx context.Context, opts types.CreateApplication, dstout, dsterr io.Writer) (*types.ApplicationInfo, error) {
 	resp, err := api.cli.Post(ctx, "/applications/", nil, &opts, nil)
 	if err != nil {
-		return nil, err
+		return nil, validationError(err)
 	}
 
 	var info types.ApplicationInfo
-	err = serverlog.Drain(resp.Body, dstout, dsterr, &info)
+	err = api.drain(resp.Body, dstout, dsterr, &info)
 	resp.Body.Close()
 	return &info, err
 }
 
+// PlanCreateApplication validates the create options and returns actions
+// to create the application, without creating anything.
+func (api *APIClient) PlanCreateApplication(ctx context.Context, opts types.CreateApplication) (*types.Plan, error) {
+	var plan types.Plan
+	resp, err := api.cli.Post(ctx, "/applications/", dryRunQuery, &opts, nil)
+	if err != nil {
+		return nil, validationError(err)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&plan)
+	resp.EnsureClosed()
+	return &plan, err
+}
+
+var dryRunQuery = url.Values{"dry-run": []string{"1"}}
+
 func (api *APIClient) RemoveApplication(ctx context.Context, name string) error {
 	resp, err := api.cli.Delete(ctx, "/applications/"+name, nil, nil)
 	resp.EnsureClosed()
 	return err
 }
 
+func (api *APIClient) SetProtection(ctx context.Context, name string, protected bool) error {
+	query := url.Values{"protected": {strconv.FormatBool(protected)}}
+	resp, err := api.cli.Put(ctx, "/applications/"+name+"/protection", query, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// LockDeployments refuses deployments of the application until unlocked.
+func (api *APIClient) LockDeployments(ctx context.Context, name, reason string) error {
+	var query url.Values
+	if reason != "" {
+		query = url.Values{"reason": {reason}}
+	}
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/deploy/lock", query, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+func (api *APIClient) UnlockDeployments(ctx context.Context, name string) error {
+	resp, err := api.cli.Delete(ctx, "/applications/"+name+"/deploy/lock", nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// SetDeployWindows restricts deployments of the application to the time
+// windows, such as "Mon-Fri 09:00-17:00". The default windows are used if
+// the list is empty.
+func (api *APIClient) SetDeployWindows(ctx context.Context, name string, windows []string) error {
+	if windows == nil {
+		windows = []string{}
+	}
+	resp, err := api.cli.Put(ctx, "/applications/"+name+"/deploy/windows", nil, windows, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// SetTraffic starts a canary release of the application, changes the
+// traffic weight, or promotes or rolls back the release in progress.
+// Returns nil if the release is completed.
+func (api *APIClient) SetTraffic(ctx context.Context, name string, opts types.TrafficOptions) (*types.Canary, error) {
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/traffic", nil, opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.EnsureClosed()
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+
+	var canary types.Canary
+	err = json.NewDecoder(resp.Body).Decode(&canary)
+	return &canary, err
+}
+
+func (api *APIClient) GetAccessPolicy(ctx context.Context, name string) (*types.AccessPolicy, error) {
+	var policy types.AccessPolicy
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/access-policy", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&policy)
+		resp.EnsureClosed()
+	}
+	return &policy, err
+}
+
+// SetAccessPolicy replaces the access policy of the application. Passwords
+// of existing basic auth users are kept if not given.
+func (api *APIClient) SetAccessPolicy(ctx context.Context, name string, policy *types.AccessPolicy) error {
+	resp, err := api.cli.Put(ctx, "/applications/"+name+"/access-policy", nil, policy, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+func (api *APIClient) RemoveAccessPolicy(ctx context.Context, name string) error {
+	resp, err := api.cli.Delete(ctx, "/applications/"+name+"/access-policy", nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+func (api *APIClient) GetHTTPSettings(ctx context.Context, name string) (*types.HTTPSettings, error) {
+	var settings types.HTTPSettings
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/http-settings", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&settings)
+		resp.EnsureClosed()
+	}
+	return &settings, err
+}
+
+func (api *APIClient) SetHTTPSettings(ctx context.Context, name string, settings *types.HTTPSettings) error {
+	resp, err := api.cli.Put(ctx, "/applications/"+name+"/http-settings", nil, settings, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+func (api *APIClient) GetLogging(ctx context.Context, name string) (*types.LogConfig, error) {
+	var logging types.LogConfig
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/logging", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&logging)
+		resp.EnsureClosed()
+	}
+	return &logging, err
+}
+
+// SetLogging sets the log shipping configuration of the application, an
+// empty configuration resets to the default log driver. Containers of the
+// application are recreated.
+func (api *APIClient) SetLogging(ctx context.Context, name string, logging *types.LogConfig) error {
+	resp, err := api.cli.Put(ctx, "/applications/"+name+"/logging", nil, logging, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// SetLabels replaces labels of the application, empty labels remove all
+// labels.
+func (api *APIClient) SetLabels(ctx context.Context, name string, labels map[string]string) error {
+	resp, err := api.cli.Put(ctx, "/applications/"+name+"/labels", nil, labels, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+func (api *APIClient) RenameApplication(ctx context.Context, name, newName string) error {
+	query := url.Values{}
+	query.Set("newname", newName)
+
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/rename", query, nil, nil)
+	resp.EnsureClosed()
+	return validationError(err)
+}
+
 func (api *APIClient) CreateService(ctx context.Context, dstout, dsterr io.Writer, app string, tags ...string) error {
 	resp, err := api.cli.Post(ctx, "/applications/"+app+"/services/", nil, tags, nil)
 	if err != nil {
 		return err
 	}
 
-	err = serverlog.Drain(resp.Body, dstout, dsterr, nil)
+	err = api.drain(resp.Body, dstout, dsterr, nil)
 	resp.Body.Close()
 	return err
 }
@@ -71,7 +224,7 @@ func (api *APIClient) StartApplication(ctx context.Context, name string, dstout,
 		return err
 	}
 
-	err = serverlog.Drain(resp.Body, dstout, dsterr, nil)
+	err = api.drain(resp.Body, dstout, dsterr, nil)
 	resp.Body.Close()
 	return err
 }
@@ -88,7 +241,37 @@ func (api *APIClient) RestartApplication(ctx context.Context, name string, dstou
 		return err
 	}
 
-	err = serverlog.Drain(resp.Body, dstout, dsterr, nil)
+	err = api.drain(resp.Body, dstout, dsterr, nil)
+	resp.Body.Close()
+	return err
+}
+
+// ReconcileApplication repairs the drift between the desired state of an
+// application and actual containers.
+func (api *APIClient) ReconcileApplication(ctx context.Context, name string, dstout, dsterr io.Writer) error {
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/reconcile", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	err = api.drain(resp.Body, dstout, dsterr, nil)
+	resp.Body.Close()
+	return err
+}
+
+// SetMaintenance turns the maintenance mode of an application on or off,
+// optionally stopping the application while in maintenance.
+func (api *APIClient) SetMaintenance(ctx context.Context, name string, on, stop bool, dstout, dsterr io.Writer) error {
+	query := url.Values{"on": {strconv.FormatBool(on)}}
+	if stop {
+		query.Set("stop", "true")
+	}
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/maintenance", query, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	err = api.drain(resp.Body, dstout, dsterr, nil)
 	resp.Body.Close()
 	return err
 }
@@ -102,6 +285,15 @@ func (api *APIClient) GetApplicationStatus(ctx context.Context, name string) (st
 	return
 }
 
+func (api *APIClient) GetApplicationRoutes(ctx context.Context, name string) (routes []*types.Route, err error) {
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/routes", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&routes)
+		resp.EnsureClosed()
+	}
+	return
+}
+
 func (api *APIClient) GetAllApplicationStatus(ctx context.Context) (status map[string][]*types.ContainerStatus, err error) {
 	resp, err := api.cli.Get(ctx, "/applications/status/", nil, nil)
 	if err == nil {
@@ -136,14 +328,25 @@ func (api *APIClient) DeployApplication(ctx context.Context, name, branch string
 		return err
 	}
 
-	err = serverlog.Drain(resp.Body, dstout, dsterr, nil)
+	err = api.drain(resp.Body, dstout, dsterr, nil)
 	resp.Body.Close()
 	return err
 }
 
-func (api *APIClient) GetApplicationDeployments(ctx context.Context, name string) (*types.Deployments, error) {
+// GetApplicationDeployments returns the current deployment branch and
+// deployment branches that contain the filter text. At most limit branches
+// are returned if limit is greater than zero.
+func (api *APIClient) GetApplicationDeployments(ctx context.Context, name, filterText string, limit int) (*types.Deployments, error) {
+	query := url.Values{}
+	if filterText != "" {
+		query.Set("filterText", filterText)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
 	var deployments types.Deployments
-	resp, err := api.cli.Get(ctx, "/applications/"+name+"/deploy", nil, nil)
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/deploy", query, nil)
 	if err == nil {
 		err = json.NewDecoder(resp.Body).Decode(&deployments)
 		resp.EnsureClosed()
@@ -151,9 +354,37 @@ func (api *APIClient) GetApplicationDeployments(ctx context.Context, name string
 	return &deployments, err
 }
 
+// ArchiveOptions selects the format of downloaded archives. Format is the
+// media type of the archive, gzip'd tar by default. If NoCompress is true,
+// the server doesn't compress the archive, which saves CPU on the server
+// when the data is already compressed. A gzip'd tar archive is sent as a
+// plain tar archive in this case.
+type ArchiveOptions struct {
+	Format     string
+	NoCompress bool
+}
+
+func (opts ArchiveOptions) query() (url.Values, map[string][]string) {
+	format := opts.Format
+	if format == "" {
+		format = "application/tar+gzip"
+	}
+	var query url.Values
+	if opts.NoCompress {
+		query = url.Values{"compress": {"none"}}
+	}
+	return query, map[string][]string{"Accept": {format}}
+}
+
 func (api *APIClient) Download(ctx context.Context, name string) (io.ReadCloser, error) {
-	headers := map[string][]string{"Accept": {"application/tar+gzip"}}
-	resp, err := api.cli.Get(ctx, "/applications/"+name+"/repo", nil, headers)
+	return api.DownloadArchive(ctx, name, ArchiveOptions{})
+}
+
+// DownloadArchive downloads the application repository in the given
+// archive format.
+func (api *APIClient) DownloadArchive(ctx context.Context, name string, opts ArchiveOptions) (io.ReadCloser, error) {
+	query, headers := opts.query()
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/repo", query, headers)
 	return resp.Body, err
 }
 
@@ -170,24 +401,224 @@ func (api *APIClient) Upload(ctx context.Context, name string, content io.Reader
 		return err
 	}
 
-	err = serverlog.Drain(resp.Body, dstout, dsterr, nil)
+	err = api.drain(resp.Body, dstout, dsterr, nil)
 	resp.Body.Close()
 	return err
 }
 
+// DeployArtifact uploads a prebuilt build artifact, such as a tar, zip or
+// jar file, and deploys it to the application. It's intended to be used
+// by CI jobs with a service account token.
+func (api *APIClient) DeployArtifact(ctx context.Context, name string, opts types.DeployArtifact, content io.Reader, dstout, dsterr io.Writer) (*types.Artifact, error) {
+	query := url.Values{}
+	if opts.Filename != "" {
+		query.Set("filename", opts.Filename)
+	}
+	if opts.Commit != "" {
+		query.Set("commit", opts.Commit)
+	}
+	if opts.Version != "" {
+		query.Set("version", opts.Version)
+	}
+
+	headers := map[string][]string{"Content-Type": {"application/octet-stream"}}
+	resp, err := api.cli.PutRaw(ctx, "/applications/"+name+"/build", query, content, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	var artifact types.Artifact
+	err = api.drain(resp.Body, dstout, dsterr, &artifact)
+	resp.Body.Close()
+	return &artifact, err
+}
+
+// GetArtifacts returns build artifacts of the application, the latest first.
+func (api *APIClient) GetArtifacts(ctx context.Context, name string) ([]*types.Artifact, error) {
+	var artifacts []*types.Artifact
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/artifacts", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&artifacts)
+		resp.EnsureClosed()
+	}
+	return artifacts, err
+}
+
+// RedeployArtifact deploys a previous build artifact of the application.
+func (api *APIClient) RedeployArtifact(ctx context.Context, name, id string, dstout, dsterr io.Writer) (*types.Artifact, error) {
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/artifacts/"+id+"/deploy", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var artifact types.Artifact
+	err = api.drain(resp.Body, dstout, dsterr, &artifact)
+	resp.Body.Close()
+	return &artifact, err
+}
+
+// GetDeploymentHistory returns the deployment history of the application,
+// the latest first.
+func (api *APIClient) GetDeploymentHistory(ctx context.Context, name string) ([]*types.DeploymentRecord, error) {
+	var history []*types.DeploymentRecord
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/deploy/history", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&history)
+		resp.EnsureClosed()
+	}
+	return history, err
+}
+
+// GetDeploymentLog returns the output of a deployment.
+func (api *APIClient) GetDeploymentLog(ctx context.Context, name, id string) (io.ReadCloser, error) {
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/deploy/history/"+id+"/log", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Rollback deploys the build of a previous successful deployment again.
+func (api *APIClient) Rollback(ctx context.Context, name, id string, dstout, dsterr io.Writer) (*types.DeploymentRecord, error) {
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/deploy/history/"+id+"/rollback", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var d types.DeploymentRecord
+	err = api.drain(resp.Body, dstout, dsterr, &d)
+	resp.Body.Close()
+	return &d, err
+}
+
 func (api *APIClient) Dump(ctx context.Context, name string) (io.ReadCloser, error) {
+	return api.DumpArchive(ctx, name, ArchiveOptions{})
+}
+
+// DumpArchive dumps the application data in the given archive format.
+func (api *APIClient) DumpArchive(ctx context.Context, name string, opts ArchiveOptions) (io.ReadCloser, error) {
+	return api.DumpService(ctx, name, "", opts)
+}
+
+// DumpService dumps data of a single service in the given archive format.
+// The service name "_" dumps data of the application containers only, and
+// an empty name dumps all data of the application.
+func (api *APIClient) DumpService(ctx context.Context, name, service string, opts ArchiveOptions) (io.ReadCloser, error) {
+	query, headers := opts.query()
+	resp, err := api.cli.Get(ctx, datapath(name, service), query, headers)
+	return resp.Body, err
+}
+
+// Restore restores the application data from the archive created by Dump.
+// Progress of the restore is written to dstout and dsterr.
+func (api *APIClient) Restore(ctx context.Context, name string, content io.Reader, dstout, dsterr io.Writer) error {
+	return api.RestoreService(ctx, name, "", content, dstout, dsterr)
+}
+
+// RestoreService restores data of a single service from the archive created
+// by DumpService, leaving data of other services untouched.
+func (api *APIClient) RestoreService(ctx context.Context, name, service string, content io.Reader, dstout, dsterr io.Writer) error {
+	headers := map[string][]string{"Content-Type": {"application/tar+gzip"}}
+	resp, err := api.cli.PutRaw(ctx, datapath(name, service), nil, content, headers)
+	if err != nil {
+		return err
+	}
+
+	err = api.drain(resp.Body, dstout, dsterr, nil)
+	resp.Body.Close()
+	return err
+}
+
+// ExportApplication exports the application into a portable bundle, which
+// can be imported into another install by ImportApplication.
+func (api *APIClient) ExportApplication(ctx context.Context, name string) (io.ReadCloser, error) {
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/export", nil, nil)
+	return resp.Body, err
+}
+
+// ImportApplication recreates an application from the bundle created by
+// ExportApplication. The application is named by the bundle if the name
+// is empty. Progress of the import is written to dstout and dsterr.
+func (api *APIClient) ImportApplication(ctx context.Context, name string, content io.Reader, dstout, dsterr io.Writer) (*types.ApplicationInfo, error) {
+	var query url.Values
+	if name != "" {
+		query = url.Values{"name": {name}}
+	}
+	headers := map[string][]string{"Content-Type": {"application/tar+gzip"}}
+	resp, err := api.cli.PostRaw(ctx, "/applications/import", query, content, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	var info types.ApplicationInfo
+	err = api.drain(resp.Body, dstout, dsterr, &info)
+	resp.Body.Close()
+	return &info, err
+}
+
+func (api *APIClient) GetApplicationDiskUsage(ctx context.Context, name string) (*types.DiskUsage, error) {
+	var usage types.DiskUsage
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/du", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&usage)
+		resp.EnsureClosed()
+	}
+	return &usage, err
+}
+
+func (api *APIClient) ListFiles(ctx context.Context, name, service, path string) ([]*types.FileInfo, error) {
+	var files []*types.FileInfo
+	query := fileQuery(path, nil, nil)
+	query.Set("list", "true")
+	resp, err := api.cli.Get(ctx, filespath(name, service), query, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&files)
+		resp.EnsureClosed()
+	}
+	return files, err
+}
+
+func (api *APIClient) DownloadFiles(ctx context.Context, name, service, path string, includes, excludes []string) (io.ReadCloser, error) {
+	query := fileQuery(path, includes, excludes)
 	headers := map[string][]string{"Accept": {"application/tar+gzip"}}
-	resp, err := api.cli.Get(ctx, "/applications/"+name+"/data", nil, headers)
+	resp, err := api.cli.Get(ctx, filespath(name, service), query, headers)
 	return resp.Body, err
 }
 
-func (api *APIClient) Restore(ctx context.Context, name string, content io.Reader) error {
+func (api *APIClient) UploadFiles(ctx context.Context, name, service, path string, content io.Reader, includes, excludes []string) error {
+	query := fileQuery(path, includes, excludes)
 	headers := map[string][]string{"Content-Type": {"application/tar+gzip"}}
-	resp, err := api.cli.PutRaw(ctx, "/applications/"+name+"/data", nil, content, headers)
+	resp, err := api.cli.PutRaw(ctx, filespath(name, service), query, content, headers)
 	resp.EnsureClosed()
 	return err
 }
 
+func filespath(name, service string) string {
+	if service == "" {
+		service = "_"
+	}
+	return "/applications/" + name + "/services/" + service + "/files"
+}
+
+func datapath(name, service string) string {
+	if service == "" {
+		return "/applications/" + name + "/data"
+	}
+	return "/applications/" + name + "/services/" + service + "/data"
+}
+
+func fileQuery(path string, includes, excludes []string) url.Values {
+	query := url.Values{}
+	query.Set("path", path)
+	for _, pattern := range includes {
+		query.Add("include", pattern)
+	}
+	for _, pattern := range excludes {
+		query.Add("exclude", pattern)
+	}
+	return query
+}
+
 func (api *APIClient) ScaleApplication(ctx context.Context, name, scaling string, dstout, dsterr io.Writer) error {
 	query := url.Values{"scale": []string{scaling}}
 	resp, err := api.cli.Post(ctx, "/applications/"+name+"/scale", query, nil, nil)
@@ -195,11 +626,51 @@ func (api *APIClient) ScaleApplication(ctx context.Context, name, scaling string
 		return err
 	}
 
-	serverlog.Drain(resp.Body, dstout, dsterr, nil)
+	api.drain(resp.Body, dstout, dsterr, nil)
 	resp.Body.Close()
 	return err
 }
 
+// PlanScaleApplication validates the scaling number and returns actions to
+// scale the application, without changing anything.
+func (api *APIClient) PlanScaleApplication(ctx context.Context, name, scaling string) (*types.Plan, error) {
+	var plan types.Plan
+	query := url.Values{"scale": []string{scaling}, "dry-run": []string{"1"}}
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/scale", query, nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&plan)
+		resp.EnsureClosed()
+	}
+	return &plan, err
+}
+
+// GetLinks returns services of other applications linked into the
+// application.
+func (api *APIClient) GetLinks(ctx context.Context, name string) ([]*types.ServiceLink, error) {
+	var links []*types.ServiceLink
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/links", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&links)
+		resp.EnsureClosed()
+	}
+	return links, err
+}
+
+// LinkService links the service of another application into the
+// application.
+func (api *APIClient) LinkService(ctx context.Context, name, app, service string) error {
+	resp, err := api.cli.Put(ctx, "/applications/"+name+"/links/"+pathEscape(app)+"/"+pathEscape(service), nil, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// UnlinkService removes the link to the service of another application.
+func (api *APIClient) UnlinkService(ctx context.Context, name, app, service string) error {
+	resp, err := api.cli.Delete(ctx, "/applications/"+name+"/links/"+pathEscape(app)+"/"+pathEscape(service), nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
 func envpath(name, service string) string {
 	if service == "" {
 		service = "_"
@@ -250,3 +721,29 @@ func (api *APIClient) ApplicationUnsetenv(ctx context.Context, name, service str
 	resp.EnsureClosed()
 	return err
 }
+
+// GetCollaborators returns users granted access to the application.
+func (api *APIClient) GetCollaborators(ctx context.Context, name string) ([]*types.Collaborator, error) {
+	var collaborators []*types.Collaborator
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/collaborators", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&collaborators)
+		resp.EnsureClosed()
+	}
+	return collaborators, err
+}
+
+// AddCollaborator grants the user read or deploy access to the application.
+func (api *APIClient) AddCollaborator(ctx context.Context, name, user, access string) error {
+	query := url.Values{"access": {access}}
+	resp, err := api.cli.Put(ctx, "/applications/"+name+"/collaborators/"+pathEscape(user), query, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// RemoveCollaborator revokes access of the user to the application.
+func (api *APIClient) RemoveCollaborator(ctx context.Context, name, user string) error {
+	resp, err := api.cli.Delete(ctx, "/applications/"+name+"/collaborators/"+pathEscape(user), nil, nil)
+	resp.EnsureClosed()
+	return err
+}
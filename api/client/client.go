@@ -1,13 +1,22 @@
 package client
 
 import (
+	"encoding/json"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
+	"github.com/cloudway/platform/api/types"
 	"github.com/cloudway/platform/pkg/rest"
+	"github.com/cloudway/platform/pkg/serverlog"
+	"github.com/cloudway/platform/pkg/stdcopy"
 )
 
 type APIClient struct {
-	cli *rest.Client
+	cli      *rest.Client
+	progress func(*serverlog.Progress)
 }
 
 func NewAPIClient(host, version string, client *http.Client, httpHeaders map[string]string) (*APIClient, error) {
@@ -15,5 +24,57 @@ func NewAPIClient(host, version string, client *http.Client, httpHeaders map[str
 	if err != nil {
 		return nil, err
 	}
-	return &APIClient{cli}, nil
+	// announce the latest stream framing supported by this client, the
+	// server falls back to version 1 framing if this header is absent
+	cli.AddCustomHeader(serverlog.StreamVersionHeader, strconv.Itoa(stdcopy.Version))
+	return &APIClient{cli: cli}, nil
+}
+
+// SetTraceWriter enables dumping of all requests and responses to the
+// given writer for debugging purpose. Credentials are redacted.
+func (api *APIClient) SetTraceWriter(w io.Writer) {
+	api.cli.SetTraceWriter(w)
+}
+
+// SetProgressHandler sets the function to be called when a progress record
+// received from server. The server only sends progress records if a progress
+// handler is set.
+func (api *APIClient) SetProgressHandler(fn func(*serverlog.Progress)) {
+	api.progress = fn
+	if fn != nil {
+		api.cli.AddCustomHeader(serverlog.ProgressHeader, "1")
+	} else {
+		api.cli.RemoveCustomHeader(serverlog.ProgressHeader)
+	}
+}
+
+// ConfirmApplication confirms destructive operations on the protected
+// application in subsequent requests.
+func (api *APIClient) ConfirmApplication(name string) {
+	api.cli.AddCustomHeader(types.ConfirmHeader, name)
+}
+
+// validationError decodes the structured validation error from the server
+// error response, or returns the original error if it's not a validation
+// error.
+func validationError(err error) error {
+	se, ok := err.(rest.ServerError)
+	if !ok || se.StatusCode() != http.StatusBadRequest {
+		return err
+	}
+	var verr types.ValidationError
+	if json.Unmarshal(se.RawError(), &verr) != nil || len(verr.Fields) == 0 {
+		return err
+	}
+	return &verr
+}
+
+func (api *APIClient) drain(in io.Reader, dstout, dsterr io.Writer, result interface{}) error {
+	return serverlog.DrainProgress(in, dstout, dsterr, api.progress, result)
+}
+
+// pathEscape escapes the string so it can be safely placed inside a single
+// URL path segment, including any slashes it contains.
+func pathEscape(s string) string {
+	return strings.Replace((&url.URL{Path: s}).EscapedPath(), "/", "%2F", -1)
 }
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+
+	"github.com/cloudway/platform/api/types"
+)
+
+// EventsOptions selects platform events. Filters are keyed by "type",
+// "action", "application", "namespace" or "service". Events after the
+// given event ID are returned if Since is not nil. If Follow is false,
+// the server returns when any events are available or the timeout expired.
+type EventsOptions struct {
+	Filters map[string][]string
+	Since   *uint64
+	Follow  bool
+	Timeout int
+}
+
+// Events receives platform events and calls the function for each event,
+// until the stream ended, the context canceled or the function returned
+// an error.
+func (api *APIClient) Events(ctx context.Context, options EventsOptions, fn func(*types.Event) error) error {
+	query := url.Values{}
+	if len(options.Filters) != 0 {
+		filters, err := json.Marshal(options.Filters)
+		if err != nil {
+			return err
+		}
+		query.Set("filters", string(filters))
+	}
+	if options.Since != nil {
+		query.Set("since", strconv.FormatUint(*options.Since, 10))
+	}
+	if options.Follow {
+		query.Set("follow", "1")
+	}
+	if options.Timeout > 0 {
+		query.Set("timeout", strconv.Itoa(options.Timeout))
+	}
+
+	resp, err := api.cli.Get(ctx, "/events", query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.EnsureClosed()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var event types.Event
+		if err = dec.Decode(&event); err != nil {
+			if err == io.EOF || ctx.Err() != nil {
+				err = nil
+			}
+			return err
+		}
+		if err = fn(&event); err != nil {
+			return err
+		}
+	}
+}
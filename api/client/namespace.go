@@ -26,6 +26,15 @@ func (api *APIClient) SetNamespace(ctx context.Context, namespace string) error
 	return err
 }
 
+func (api *APIClient) RenameNamespace(ctx context.Context, namespace string) error {
+	query := url.Values{}
+	query.Set("namespace", namespace)
+
+	resp, err := api.cli.Put(ctx, "/namespace", query, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
 func (api *APIClient) RemoveNamespace(ctx context.Context, force bool) error {
 	var query url.Values
 	if force {
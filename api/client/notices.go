@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/cloudway/platform/api/types"
+)
+
+// Notices returns platform notices that should be shown to users now.
+func (api *APIClient) Notices(ctx context.Context) ([]*types.Notice, error) {
+	var notices []*types.Notice
+	resp, err := api.cli.Get(ctx, "/notices", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&notices)
+		resp.EnsureClosed()
+	}
+	return notices, err
+}
+
+// SetNoticeHandler sets the function to be called with platform notices
+// sent in response headers. Each notice is in the form "kind: message".
+func (api *APIClient) SetNoticeHandler(fn func(notices []string)) {
+	if fn == nil {
+		api.cli.SetHeaderHook(nil)
+		return
+	}
+	api.cli.SetHeaderHook(func(h http.Header) {
+		if notices := h[types.NoticeHeader]; len(notices) != 0 {
+			fn(notices)
+		}
+	})
+}
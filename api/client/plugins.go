@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/url"
 
+	"github.com/cloudway/platform/api/types"
 	"github.com/cloudway/platform/pkg/manifest"
 )
 
@@ -47,8 +48,11 @@ func (api *APIClient) GetPluginInfo(ctx context.Context, tag string) (*manifest.
 	return plugin, err
 }
 
-func (api *APIClient) InstallPlugin(ctx context.Context, body io.Reader) error {
+func (api *APIClient) InstallPlugin(ctx context.Context, body io.Reader, signature string) error {
 	headers := map[string][]string{"Content-Type": {"application/tar"}}
+	if signature != "" {
+		headers[types.SignatureHeader] = []string{signature}
+	}
 	resp, err := api.cli.PostRaw(ctx, "/plugins/", nil, body, headers)
 	resp.EnsureClosed()
 	return err
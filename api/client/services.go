@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/cloudway/platform/api/types"
+)
+
+// GetStandaloneServices returns names of standalone services in the
+// namespace.
+func (api *APIClient) GetStandaloneServices(ctx context.Context) ([]string, error) {
+	var names []string
+	resp, err := api.cli.Get(ctx, "/services/", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&names)
+		resp.EnsureClosed()
+	}
+	return names, err
+}
+
+func (api *APIClient) GetStandaloneService(ctx context.Context, name string) (*types.StandaloneService, error) {
+	var info types.StandaloneService
+	resp, err := api.cli.Get(ctx, "/services/"+name, nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&info)
+		resp.EnsureClosed()
+	}
+	return &info, err
+}
+
+// CreateStandaloneService creates a service in the namespace that is not
+// owned by any application.
+func (api *APIClient) CreateStandaloneService(ctx context.Context, opts types.CreateStandaloneService, dstout, dsterr io.Writer) error {
+	resp, err := api.cli.Post(ctx, "/services/", nil, &opts, nil)
+	if err != nil {
+		return validationError(err)
+	}
+
+	err = api.drain(resp.Body, dstout, dsterr, nil)
+	resp.Body.Close()
+	return err
+}
+
+func (api *APIClient) RemoveStandaloneService(ctx context.Context, name string) error {
+	resp, err := api.cli.Delete(ctx, "/services/"+name, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+func (api *APIClient) StartStandaloneService(ctx context.Context, name string, dstout, dsterr io.Writer) error {
+	resp, err := api.cli.Post(ctx, "/services/"+name+"/start", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	err = api.drain(resp.Body, dstout, dsterr, nil)
+	resp.Body.Close()
+	return err
+}
+
+func (api *APIClient) StopStandaloneService(ctx context.Context, name string) error {
+	resp, err := api.cli.Post(ctx, "/services/"+name+"/stop", nil, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+func (api *APIClient) RestartStandaloneService(ctx context.Context, name string, dstout, dsterr io.Writer) error {
+	resp, err := api.cli.Post(ctx, "/services/"+name+"/restart", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	err = api.drain(resp.Body, dstout, dsterr, nil)
+	resp.Body.Close()
+	return err
+}
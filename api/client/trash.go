@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+
+	"github.com/cloudway/platform/api/types"
+)
+
+func (api *APIClient) PurgeApplication(ctx context.Context, name string) error {
+	resp, err := api.cli.Delete(ctx, "/applications/"+name, url.Values{"purge": {"1"}}, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+func (api *APIClient) GetTrash(ctx context.Context) (trash []*types.TrashedApplication, err error) {
+	resp, err := api.cli.Get(ctx, "/trash", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&trash)
+		resp.EnsureClosed()
+	}
+	return trash, err
+}
+
+func (api *APIClient) RestoreApplication(ctx context.Context, name string, dstout, dsterr io.Writer) (*types.ApplicationInfo, error) {
+	resp, err := api.cli.Post(ctx, "/trash/"+name+"/restore", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var info types.ApplicationInfo
+	err = api.drain(resp.Body, dstout, dsterr, &info)
+	resp.Body.Close()
+	return &info, err
+}
+
+func (api *APIClient) PurgeTrash(ctx context.Context, name string) error {
+	resp, err := api.cli.Delete(ctx, "/trash/"+name, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
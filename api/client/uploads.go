@@ -0,0 +1,184 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/rest"
+)
+
+// UploadChunkSize is the size of chunks sent by ResumableUpload.
+const UploadChunkSize = 8 << 20
+
+// The number of consecutive failed chunks before ResumableUpload gives up,
+// the retry delay doubles after each failure up to maxUploadRetryDelay.
+const (
+	maxUploadRetries    = 8
+	maxUploadRetryDelay = 30 * time.Second
+)
+
+// CreateUpload creates a resumable upload of the given size for the
+// application.
+func (api *APIClient) CreateUpload(ctx context.Context, name string, size int64) (*types.Upload, error) {
+	var upload types.Upload
+	opts := types.CreateUpload{Size: size}
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/uploads", nil, &opts, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&upload)
+		resp.EnsureClosed()
+	}
+	return &upload, err
+}
+
+// GetUpload returns the resumable upload, the offset is where the next
+// chunk starts.
+func (api *APIClient) GetUpload(ctx context.Context, name, id string) (*types.Upload, error) {
+	var upload types.Upload
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/uploads/"+id, nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&upload)
+		resp.EnsureClosed()
+	}
+	return &upload, err
+}
+
+// AppendUpload sends a chunk of the given length at the offset of the
+// resumable upload.
+func (api *APIClient) AppendUpload(ctx context.Context, name, id string, offset, length, size int64, chunk io.Reader) (*types.Upload, error) {
+	var upload types.Upload
+	headers := map[string][]string{
+		"Content-Type":  {"application/octet-stream"},
+		"Content-Range": {fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, size)},
+	}
+	resp, err := api.cli.PutRaw(ctx, "/applications/"+name+"/uploads/"+id, nil, chunk, headers)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&upload)
+		resp.EnsureClosed()
+	}
+	return &upload, err
+}
+
+// RemoveUpload removes the resumable upload.
+func (api *APIClient) RemoveUpload(ctx context.Context, name, id string) error {
+	resp, err := api.cli.Delete(ctx, "/applications/"+name+"/uploads/"+id, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// ResumableUpload sends the content in chunks to the resumable upload with
+// the given ID, or a new upload if the ID is empty. Failed chunks are
+// retried from the offset reported by the server, so a broken connection
+// doesn't require starting over. The retry function, if not nil, is called
+// before each retry. The completed upload is consumed by UploadFrom or
+// RestoreFrom.
+func (api *APIClient) ResumableUpload(ctx context.Context, name, id string, content io.ReaderAt, size int64, retry func(*types.Upload, error)) (*types.Upload, error) {
+	var upload *types.Upload
+	var err error
+	if id == "" {
+		upload, err = api.CreateUpload(ctx, name, size)
+	} else {
+		upload, err = api.GetUpload(ctx, name, id)
+		if err == nil && upload.Size != size {
+			err = fmt.Errorf("The size of upload %s is %d bytes, not %d bytes", id, upload.Size, size)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, UploadChunkSize)
+	failures := 0
+	delay := time.Second
+	for upload.Offset < upload.Size {
+		n := upload.Size - upload.Offset
+		if n > int64(len(buf)) {
+			n = int64(len(buf))
+		}
+		if _, err = content.ReadAt(buf[:n], upload.Offset); err != nil && err != io.EOF {
+			return upload, err
+		}
+
+		var next *types.Upload
+		next, err = api.AppendUpload(ctx, name, upload.ID, upload.Offset, n, upload.Size, bytes.NewReader(buf[:n]))
+		if err == nil {
+			upload, failures, delay = next, 0, time.Second
+			continue
+		}
+		if !retryableUploadError(err) || failures >= maxUploadRetries {
+			return upload, err
+		}
+
+		failures++
+		if retry != nil {
+			retry(upload, err)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return upload, ctx.Err()
+		}
+		if delay *= 2; delay > maxUploadRetryDelay {
+			delay = maxUploadRetryDelay
+		}
+
+		// resume from the offset received by the server, which may be
+		// beyond the offset of the failed chunk
+		if next, err = api.GetUpload(ctx, name, upload.ID); err == nil {
+			upload = next
+		} else if !retryableUploadError(err) {
+			return upload, err
+		}
+	}
+	return upload, nil
+}
+
+// retryableUploadError returns true if the chunk may succeed when resumed,
+// which is the case for connection failures, server errors and offset
+// mismatches.
+func retryableUploadError(err error) bool {
+	se, ok := err.(rest.ServerError)
+	if !ok {
+		return err != context.Canceled && err != context.DeadlineExceeded
+	}
+	return se.StatusCode() == http.StatusConflict || se.StatusCode() >= http.StatusInternalServerError
+}
+
+// UploadFrom deploys the application repository from the completed
+// resumable upload, which is removed on success.
+func (api *APIClient) UploadFrom(ctx context.Context, name, id string, binary bool, dstout, dsterr io.Writer) error {
+	query := url.Values{"upload": {id}}
+	if binary {
+		query.Set("binary", "true")
+	}
+
+	resp, err := api.cli.PutRaw(ctx, "/applications/"+name+"/repo", query, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	err = api.drain(resp.Body, dstout, dsterr, nil)
+	resp.Body.Close()
+	return err
+}
+
+// RestoreFrom restores the application data, or data of a single service if
+// the service name is not empty, from the completed resumable upload, which
+// is removed on success.
+func (api *APIClient) RestoreFrom(ctx context.Context, name, service, id string, dstout, dsterr io.Writer) error {
+	query := url.Values{"upload": {id}}
+	resp, err := api.cli.PutRaw(ctx, datapath(name, service), query, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	err = api.drain(resp.Body, dstout, dsterr, nil)
+	resp.Body.Close()
+	return err
+}
@@ -0,0 +1,165 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/cloudway/platform/api/types"
+)
+
+// GetUserInfo returns information of the current user.
+func (api *APIClient) GetUserInfo(ctx context.Context) (*types.UserInfo, error) {
+	var info types.UserInfo
+	resp, err := api.cli.Get(ctx, "/user", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&info)
+		resp.EnsureClosed()
+	}
+	return &info, err
+}
+
+// ChangeEmail sends a confirmation link to the new email address of the
+// current user.
+func (api *APIClient) ChangeEmail(ctx context.Context, email string) error {
+	req := types.ChangeEmail{Email: email}
+	resp, err := api.cli.Put(ctx, "/user/email", nil, &req, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// ConfirmEmail changes the email address with the confirmation token.
+func (api *APIClient) ConfirmEmail(ctx context.Context, token string) error {
+	req := types.ConfirmEmail{Token: token}
+	resp, err := api.cli.Post(ctx, "/user/email/confirm", nil, &req, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// DeleteAccount schedules deletion of the current user account, which is
+// confirmed with the user name. Returns the time when the account will be
+// deleted.
+func (api *APIClient) DeleteAccount(ctx context.Context, confirm string) (time.Time, error) {
+	var result types.AccountDeletion
+	headers := map[string][]string{types.ConfirmHeader: {confirm}}
+	resp, err := api.cli.Delete(ctx, "/user", nil, headers)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.EnsureClosed()
+	}
+	return result.DeleteAt, err
+}
+
+// RestoreAccount cancels the scheduled deletion of the current user account.
+func (api *APIClient) RestoreAccount(ctx context.Context) error {
+	resp, err := api.cli.Post(ctx, "/user/restore", nil, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// GetPreferences returns user interface preferences of the current user.
+func (api *APIClient) GetPreferences(ctx context.Context) (*types.UserPreferences, error) {
+	var prefs types.UserPreferences
+	resp, err := api.cli.Get(ctx, "/user/preferences", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&prefs)
+		resp.EnsureClosed()
+	}
+	return &prefs, err
+}
+
+// SetPreferences replaces user interface preferences of the current user.
+func (api *APIClient) SetPreferences(ctx context.Context, prefs *types.UserPreferences) error {
+	resp, err := api.cli.Put(ctx, "/user/preferences", nil, prefs, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+func (api *APIClient) GetSSHKeys(ctx context.Context) ([]*types.SSHKey, error) {
+	var keys []*types.SSHKey
+	resp, err := api.cli.Get(ctx, "/user/keys", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&keys)
+		resp.EnsureClosed()
+	}
+	return keys, err
+}
+
+func (api *APIClient) AddSSHKey(ctx context.Context, label, key string) (*types.SSHKey, error) {
+	var result types.SSHKey
+	req := types.CreateSSHKey{Label: label, Key: key}
+	resp, err := api.cli.Post(ctx, "/user/keys", nil, &req, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.EnsureClosed()
+	}
+	return &result, err
+}
+
+func (api *APIClient) RemoveSSHKey(ctx context.Context, fingerprint string) error {
+	resp, err := api.cli.Delete(ctx, "/user/keys/"+pathEscape(fingerprint), nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// GetAccessTokens returns personal access tokens of the current user.
+func (api *APIClient) GetAccessTokens(ctx context.Context) ([]*types.AccessToken, error) {
+	var tokens []*types.AccessToken
+	resp, err := api.cli.Get(ctx, "/user/tokens", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&tokens)
+		resp.EnsureClosed()
+	}
+	return tokens, err
+}
+
+// CreateAccessToken creates a personal access token with the scopes, the
+// token is only returned by this call.
+func (api *APIClient) CreateAccessToken(ctx context.Context, label string, scopes []string) (*types.AccessToken, error) {
+	var result types.AccessToken
+	req := types.CreateAccessToken{Label: label, Scopes: scopes}
+	resp, err := api.cli.Post(ctx, "/user/tokens", nil, &req, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.EnsureClosed()
+	}
+	return &result, err
+}
+
+// RemoveAccessToken revokes the personal access token with the given ID.
+func (api *APIClient) RemoveAccessToken(ctx context.Context, id string) error {
+	resp, err := api.cli.Delete(ctx, "/user/tokens/"+id, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// GetRegistries returns private registries configured by the current user.
+func (api *APIClient) GetRegistries(ctx context.Context) ([]*types.Registry, error) {
+	var registries []*types.Registry
+	resp, err := api.cli.Get(ctx, "/user/registries", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&registries)
+		resp.EnsureClosed()
+	}
+	return registries, err
+}
+
+// AddRegistry saves the credentials of a private registry, replacing the
+// existing credentials of the same server.
+func (api *APIClient) AddRegistry(ctx context.Context, server, username, password string) (*types.Registry, error) {
+	var result types.Registry
+	req := types.CreateRegistry{Server: server, Username: username, Password: password}
+	resp, err := api.cli.Post(ctx, "/user/registries", nil, &req, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.EnsureClosed()
+	}
+	return &result, err
+}
+
+// RemoveRegistry removes the credentials of a private registry.
+func (api *APIClient) RemoveRegistry(ctx context.Context, server string) error {
+	resp, err := api.cli.Delete(ctx, "/user/registries/"+pathEscape(server), nil, nil)
+	resp.EnsureClosed()
+	return err
+}
@@ -3,7 +3,9 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"regexp"
 
+	"github.com/cloudway/platform/api"
 	"github.com/cloudway/platform/api/types"
 )
 
@@ -17,6 +19,38 @@ func (api *APIClient) ServerVersion(ctx context.Context) (types.Version, error)
 	return server, err
 }
 
+// NegotiateAPIVersion downgrades the client API version to the server API
+// version if the server is older than the client, so that newer clients
+// keep working with older servers. Servers honor older API versions on
+// routes with breaking changes.
+func (cli *APIClient) NegotiateAPIVersion(ctx context.Context) error {
+	version := cli.cli.ClientVersion()
+	if version == "" {
+		return nil
+	}
+
+	// query the server version without requesting an API version
+	cli.cli.UpdateClientVersion("")
+	server, err := cli.ServerVersion(ctx)
+	cli.cli.UpdateClientVersion(version)
+	if err != nil {
+		return err
+	}
+
+	if IsReleaseVersion(server.Version) && api.CompareVersions(server.Version, version) < 0 {
+		cli.cli.UpdateClientVersion(server.Version)
+	}
+	return nil
+}
+
+// IsReleaseVersion returns true if the version can be used as an API
+// version. Development builds don't have release versions.
+func IsReleaseVersion(v string) bool {
+	return releaseVersion.MatchString(v)
+}
+
+var releaseVersion = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`)
+
 func (cli *APIClient) ClientVersion() string {
 	return cli.cli.ClientVersion()
 }
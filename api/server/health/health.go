@@ -0,0 +1,42 @@
+// Package health provides the liveness and readiness endpoints of platform
+// components, which are served at the root of the server without
+// authentication so orchestrators and load balancers can check them.
+package health
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/broker"
+)
+
+// InitRoutes registers the "/healthz" and "/readyz" endpoints. It must be
+// called before catch-all routes are registered.
+func InitRoutes(m *mux.Router, br *broker.Broker) {
+	m.Path("/healthz").Methods("GET", "HEAD").HandlerFunc(healthz)
+	m.Path("/readyz").Methods("GET", "HEAD").HandlerFunc(readyz(br))
+}
+
+// healthz reports the process is alive, it doesn't check dependencies so
+// the process is not restarted when an external service is down.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte("ok\n"))
+}
+
+// readyz reports the status of each dependency, and responds with 503 if
+// any dependency is unavailable.
+func readyz(br *broker.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		readiness := br.Readiness(r.Context())
+		code := http.StatusOK
+		if !readiness.Ready {
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		httputils.WriteJSON(w, code, readiness)
+	}
+}
@@ -0,0 +1,148 @@
+package httputils
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/pkg/archive"
+)
+
+// Archive formats produced by download endpoints.
+const (
+	ArchiveTarGzip = "application/tar+gzip"
+	ArchiveTar     = "application/x-tar"
+	ArchiveZip     = "application/zip"
+)
+
+// archiveAliases maps accepted media types to the archive formats.
+var archiveAliases = map[string]string{
+	"application/tar+gzip": ArchiveTarGzip,
+	"application/gzip":     ArchiveTarGzip,
+	"application/x-gzip":   ArchiveTarGzip,
+	"application/x-tar":    ArchiveTar,
+	"application/tar":      ArchiveTar,
+	"application/zip":      ArchiveZip,
+	"*/*":                  ArchiveTarGzip,
+	"application/*":        ArchiveTarGzip,
+}
+
+var archiveExtensions = map[string]string{
+	ArchiveTarGzip: ".tar.gz",
+	ArchiveTar:     ".tar",
+	ArchiveZip:     ".zip",
+}
+
+// NotAcceptableError indicates none of the media types in the Accept header
+// can be produced.
+type NotAcceptableError string
+
+func (e NotAcceptableError) Error() string {
+	return fmt.Sprintf("None of the accepted media types '%s' is supported, use %s, %s or %s",
+		string(e), ArchiveTarGzip, ArchiveTar, ArchiveZip)
+}
+
+func (e NotAcceptableError) HTTPErrorStatusCode() int {
+	return http.StatusNotAcceptable
+}
+
+// NegotiateArchive returns the archive format preferred by the Accept
+// header of the request. Gzip'd tar is preferred if the Accept header is
+// absent or accepts any type.
+func NegotiateArchive(r *http.Request) (string, error) {
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return ArchiveTarGzip, nil
+	}
+
+	format, quality := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		// the first of equally preferred types wins
+		if f, ok := archiveAliases[mediaType]; ok && q > quality {
+			format, quality = f, q
+		}
+	}
+	if format == "" {
+		return "", NotAcceptableError(accept)
+	}
+	return format, nil
+}
+
+// archiveLevel returns the compression level requested by the "compress"
+// parameter, which is "gzip" by default or "none" to skip recompression of
+// data already compressed by the container.
+func archiveLevel(r *http.Request) (int, error) {
+	switch compress := r.FormValue("compress"); compress {
+	case "", "gzip":
+		return defaults.GzipLevel(), nil
+	case "none":
+		return gzip.NoCompression, nil
+	default:
+		return 0, fmt.Errorf("Bad parameter: unsupported compression '%s', use gzip or none", compress)
+	}
+}
+
+// ArchiveOptions returns the archive format and compression level requested
+// by the Accept header and the "compress" parameter. If compression is
+// disabled, gzip'd tar is sent as plain tar and zip entries are stored.
+func ArchiveOptions(r *http.Request) (format string, level int, err error) {
+	if format, err = NegotiateArchive(r); err != nil {
+		return "", 0, err
+	}
+	if level, err = archiveLevel(r); err != nil {
+		return "", 0, err
+	}
+	if format == ArchiveTarGzip && level == gzip.NoCompression {
+		format = ArchiveTar
+	}
+	return format, level, nil
+}
+
+// WriteArchive writes the tar stream to the response in the archive format
+// requested by ArchiveOptions. The response is sent as an attachment named
+// by the base name, a timestamp and the format extension, e.g.
+// "demo-20170102-150405.zip".
+func WriteArchive(w http.ResponseWriter, r *http.Request, tr io.Reader, basename string) error {
+	format, level, err := ArchiveOptions(r)
+	if err != nil {
+		return err
+	}
+
+	filename := basename + "-" + time.Now().UTC().Format("20060102-150405") + archiveExtensions[format]
+	w.Header().Set("Content-Type", format)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.WriteHeader(http.StatusOK)
+
+	switch format {
+	case ArchiveZip:
+		return archive.ToZip(w, tr, level)
+	case ArchiveTar:
+		_, err = io.Copy(w, tr)
+		return err
+	default:
+		zw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(zw, tr); err == nil {
+			err = zw.Close()
+		}
+		return err
+	}
+}
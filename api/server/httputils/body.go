@@ -0,0 +1,91 @@
+package httputils
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/docker/go-units"
+)
+
+// RequestTooLargeError indicates the request body exceeds the configured
+// size limit.
+type RequestTooLargeError int64
+
+func (e RequestTooLargeError) Error() string {
+	return fmt.Sprintf("Request body too large, the maximum size is %s", units.BytesSize(float64(e)))
+}
+
+func (e RequestTooLargeError) HTTPErrorStatusCode() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+// LimitBody limits the size of the request body. Requests that announce a
+// larger body are rejected immediately, otherwise reading beyond the limit
+// fails with RequestTooLargeError. Zero or negative limit means unlimited.
+func LimitBody(r *http.Request, limit int64) error {
+	if limit <= 0 || r.Body == nil {
+		return nil
+	}
+	if r.ContentLength > limit {
+		return RequestTooLargeError(limit)
+	}
+	r.Body = &limitedBody{ReadCloser: r.Body, limit: limit, remaining: limit}
+	return nil
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (n int, err error) {
+	if b.remaining < 0 {
+		return 0, RequestTooLargeError(b.limit)
+	}
+	// read one more byte than remaining to detect the body exceeds limit
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err = b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = -1
+		return n, RequestTooLargeError(b.limit)
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// SpoolBody copies the request body to a temporary file, so the upload
+// completes and is checked against the size limit before any work is done,
+// without buffering the body in memory. The temporary file is removed when
+// the returned reader is closed.
+func SpoolBody(r *http.Request) (io.ReadCloser, error) {
+	f, err := ioutil.TempFile("", "upload")
+	if err != nil {
+		return nil, err
+	}
+	spool := &spoolFile{f}
+	if _, err = io.Copy(f, r.Body); err == nil {
+		_, err = f.Seek(0, os.SEEK_SET)
+	}
+	if err != nil {
+		spool.Close()
+		return nil, err
+	}
+	return spool, nil
+}
+
+type spoolFile struct {
+	*os.File
+}
+
+func (f spoolFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
@@ -21,6 +21,15 @@ type inputValidationError interface {
 	IsValidationError() bool
 }
 
+// jsonError is an interface that errors carrying structured details, such
+// as error messages of invalid fields, implement to be sent to clients as
+// JSON objects.
+type jsonError interface {
+	error
+	HTTPErrorStatusCode() int
+	JSONError() bool
+}
+
 type statusError int
 
 func (se statusError) Error() string {
@@ -31,6 +40,21 @@ func (se statusError) HTTPErrorStatusCode() int {
 	return int(se)
 }
 
+// InvalidParameterError indicates that a request parameter has an invalid
+// value.
+type InvalidParameterError struct {
+	Name  string
+	Value string
+}
+
+func (e InvalidParameterError) Error() string {
+	return fmt.Sprintf("Invalid %s: %s", e.Name, e.Value)
+}
+
+func (e InvalidParameterError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
 // NewStatusError returns a error object with HTTP status code.
 func NewStatusError(code int) error {
 	return statusError(code)
@@ -93,7 +117,15 @@ func WriteError(w http.ResponseWriter, r *http.Request, err error) {
 	statusCode := GetHTTPErrorStatusCode(err)
 	serverError := fmt.Sprintf("Handler for %s %s returned error: %v", r.Method, r.URL.Path, err)
 
-	if statusCode >= 500 {
+	if _, ok := err.(jsonError); ok {
+		logrus.Debug(serverError)
+		WriteJSON(w, statusCode, err)
+	} else if _, ok := err.(httpStatusError); ok && statusCode >= 500 {
+		// errors with explicit status code, such as service unavailable,
+		// carry messages for the user
+		logrus.Error(serverError)
+		http.Error(w, err.Error(), statusCode)
+	} else if statusCode >= 500 {
 		logrus.Error(serverError)
 		http.Error(w, "Internal server error", statusCode)
 	} else {
@@ -0,0 +1,61 @@
+package httputils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ETag computes an entity tag from the JSON encoding of the value.
+func ETag(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`, nil
+}
+
+// CheckNotModified sets the ETag header of the response and checks it
+// against the If-None-Match header of the request. If the entity tag
+// matches, a 304 Not Modified response is written and true is returned.
+func CheckNotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	if matchETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// WriteJSONWithETag writes the value to the http response stream as json
+// with an entity tag computed from the value. If the client already has
+// the same representation, a 304 Not Modified response is written instead.
+func WriteJSONWithETag(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	etag, err := ETag(v)
+	if err != nil {
+		return err
+	}
+	if CheckNotModified(w, r, etag) {
+		return nil
+	}
+	return WriteJSON(w, http.StatusOK, v)
+}
+
+// matchETag uses the weak comparison function to match the entity tag
+// against the If-None-Match header, as defined in RFC 7232.
+func matchETag(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"mime"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/pkg/serverlog"
+	"github.com/cloudway/platform/pkg/stdcopy"
 )
 
 // key is an unexported type for keys defined in this package.
@@ -22,6 +25,10 @@ const APIVersionKey key = 0
 // UseKey is the key for userdb.User values in Contexts.
 const UserKey key = 1
 
+// ServiceAccountKey is the key for userdb.ServiceAccount values in Contexts,
+// which is set if the request is authenticated by a service account.
+const ServiceAccountKey key = 2
+
 // APIFunc is an adapter to allow the use of ordinary functions as API endpoints.
 // Any function that has the appropriate signature can be registered as a API endpoint.
 type APIFunc func(w http.ResponseWriter, r *http.Request, vars map[string]string) error
@@ -95,3 +102,28 @@ func UserFromContext(ctx context.Context) (user *userdb.BasicUser) {
 	}
 	return val.(*userdb.BasicUser)
 }
+
+// ServiceAccountFromContext returns the authenticated service account from
+// the context using ServiceAccountKey, or nil if authenticated by a user.
+func ServiceAccountFromContext(ctx context.Context) *userdb.ServiceAccount {
+	if ctx == nil {
+		return nil
+	}
+	sa, _ := ctx.Value(ServiceAccountKey).(*userdb.ServiceAccount)
+	return sa
+}
+
+// NewServerLog creates a multiplexed server log for the response. Progress
+// records and newer stream framing are only used if the client announced
+// it can decode them.
+func NewServerLog(w http.ResponseWriter, r *http.Request) *serverlog.ServerLog {
+	var opts serverlog.Options
+	opts.Progress = r.Header.Get(serverlog.ProgressHeader) != ""
+	if v, err := strconv.Atoi(r.Header.Get(serverlog.StreamVersionHeader)); err == nil {
+		if v > stdcopy.Version {
+			v = stdcopy.Version
+		}
+		opts.Version = v
+	}
+	return serverlog.NewWithOptions(w, opts)
+}
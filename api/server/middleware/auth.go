@@ -4,20 +4,24 @@ import (
 	"context"
 	"net/http"
 	"regexp"
+	"strings"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/auth/userdb"
 	"github.com/cloudway/platform/broker"
 )
 
 type authMiddleware struct {
 	*broker.Broker
-	noAuthPattern *regexp.Regexp
+	noAuthPattern         *regexp.Regexp
+	serviceAccountPattern *regexp.Regexp
 }
 
 func NewAuthMiddleware(broker *broker.Broker, contextRoot string) authMiddleware {
-	pattern := regexp.MustCompile("^" + contextRoot + "(/v[0-9.]+)?/(version|auth|swagger.json)")
-	return authMiddleware{broker, pattern}
+	noAuth := regexp.MustCompile("^" + contextRoot + "(/v[0-9.]+)?/(version|auth|swagger.json|notices)")
+	serviceAccount := regexp.MustCompile("^" + contextRoot + "(/v[0-9.]+)?/applications/")
+	return authMiddleware{broker, noAuth, serviceAccount}
 }
 
 func (m authMiddleware) WrapHandler(handler httputils.APIFunc) httputils.APIFunc {
@@ -26,10 +30,38 @@ func (m authMiddleware) WrapHandler(handler httputils.APIFunc) httputils.APIFunc
 			return handler(w, r, vars)
 		}
 
-		user, err := m.Authz.Verify(r)
-		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			return nil
+		var user *userdb.BasicUser
+		var sa *userdb.ServiceAccount
+		var err error
+
+		switch token := bearerToken(r); {
+		case strings.HasPrefix(token, userdb.ServiceAccountTokenPrefix):
+			if user, sa, err = m.VerifyServiceAccount(token); err != nil {
+				return err
+			}
+		case strings.HasPrefix(token, userdb.AccessTokenPrefix):
+			// scoped access tokens are restricted as service accounts
+			if user, sa, err = m.VerifyAccessToken(token); err != nil {
+				return err
+			}
+		default:
+			if user, err = m.Authz.Verify(r); err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return nil
+			}
+		}
+
+		// service accounts can only access applications, scopes are
+		// checked by the applications router
+		if sa != nil {
+			if !m.serviceAccountPattern.MatchString(r.URL.Path) {
+				w.WriteHeader(http.StatusForbidden)
+				return nil
+			}
+			logrus.Debugf("Logged in service account: %s", sa.Name)
+			ctx := context.WithValue(r.Context(), httputils.UserKey, user)
+			ctx = context.WithValue(ctx, httputils.ServiceAccountKey, sa)
+			return handler(w, r.WithContext(ctx), vars)
 		}
 
 		logrus.Debugf("Logged in user: %s", user)
@@ -37,3 +69,11 @@ func (m authMiddleware) WrapHandler(handler httputils.APIFunc) httputils.APIFunc
 		return handler(w, r.WithContext(ctx), vars)
 	}
 }
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return auth[7:]
+	}
+	return ""
+}
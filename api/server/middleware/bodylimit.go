@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/config/defaults"
+)
+
+// archiveTypes are content types of archive uploads, which are limited by
+// the "api.max_upload_size" key. All other request bodies are limited by
+// the "api.max_body_size" key.
+var archiveTypes = []string{
+	"application/tar",
+	"application/tar+gzip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zip",
+	"application/octet-stream",
+}
+
+// BodyLimitMiddleware limits the size of request bodies, so a client cannot
+// exhaust memory of the API server with oversized requests.
+type BodyLimitMiddleware struct{}
+
+func NewBodyLimitMiddleware() BodyLimitMiddleware {
+	return BodyLimitMiddleware{}
+}
+
+func (m BodyLimitMiddleware) WrapHandler(handler httputils.APIFunc) httputils.APIFunc {
+	return func(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		limit := defaults.MaxBodySize()
+		if isArchive(r.Header.Get("Content-Type")) {
+			limit = defaults.MaxUploadSize()
+		}
+		if err := httputils.LimitBody(r, limit); err != nil {
+			return err
+		}
+		return handler(w, r, vars)
+	}
+}
+
+func isArchive(ct string) bool {
+	if ct == "" {
+		return false
+	}
+	for _, t := range archiveTypes {
+		if httputils.MatchesContentType(ct, t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/broker"
+)
+
+// NoticeMiddleware sends active platform notices in response headers, so
+// that clients can display them without polling.
+type NoticeMiddleware struct {
+	*broker.Broker
+}
+
+func NewNoticeMiddleware(broker *broker.Broker) NoticeMiddleware {
+	return NoticeMiddleware{broker}
+}
+
+func (m NoticeMiddleware) WrapHandler(handler httputils.APIFunc) httputils.APIFunc {
+	return func(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		for _, n := range m.Notices() {
+			w.Header().Add(types.NoticeHeader, n.Kind+": "+n.Message)
+		}
+		return handler(w, r, vars)
+	}
+}
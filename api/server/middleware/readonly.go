@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/broker"
+)
+
+// ReadOnlyMiddleware rejects requests that modify the platform state while
+// the platform is in read-only mode. Read requests and authentication are
+// always allowed.
+type ReadOnlyMiddleware struct {
+	allowPattern *regexp.Regexp
+}
+
+func NewReadOnlyMiddleware(contextRoot string) ReadOnlyMiddleware {
+	pattern := regexp.MustCompile("^" + contextRoot + "(/v[0-9.]+)?/auth")
+	return ReadOnlyMiddleware{pattern}
+}
+
+func (m ReadOnlyMiddleware) WrapHandler(handler httputils.APIFunc) httputils.APIFunc {
+	return func(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		switch r.Method {
+		case "GET", "HEAD", "OPTIONS":
+			return handler(w, r, vars)
+		}
+		if m.allowPattern.MatchString(r.URL.Path) {
+			return handler(w, r, vars)
+		}
+		if err := broker.CheckReadOnly(); err != nil {
+			return err
+		}
+		return handler(w, r, vars)
+	}
+}
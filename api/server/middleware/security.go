@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/pkg/secure"
+)
+
+// SecurityMiddleware sets security headers on API responses. API responses
+// are never rendered by browsers, so framing and all contents are denied.
+type SecurityMiddleware struct {
+	Policy *secure.Policy
+}
+
+func NewSecurityMiddleware() SecurityMiddleware {
+	return SecurityMiddleware{secure.NewPolicy(secure.Options{
+		HSTSMaxAge:            defaults.HSTSMaxAge(),
+		FrameOptions:          "DENY",
+		ContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+		NoSniff:               true,
+	})}
+}
+
+func (m SecurityMiddleware) WrapHandler(handler httputils.APIFunc) httputils.APIFunc {
+	return func(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		m.Policy.Apply(w, r)
+		return handler(w, r, vars)
+	}
+}
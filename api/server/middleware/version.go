@@ -7,6 +7,7 @@ import (
 
 	"github.com/cloudway/platform/api"
 	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
 	"github.com/cloudway/platform/broker"
 )
 
@@ -29,9 +30,13 @@ func NewVersionMiddleware(broker *broker.Broker) VersionMiddleware {
 	return VersionMiddleware{Broker: broker}
 }
 
-// WrapHandler returns a new handler function wrapping the previous one in the request chain
+// WrapHandler returns a new handler function wrapping the previous one in the request chain.
+// The server API version is returned in all responses for clients to negotiate the version,
+// and the version requested by the client is passed to handlers in the request context.
 func (m VersionMiddleware) WrapHandler(handler httputils.APIFunc) httputils.APIFunc {
 	return func(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		w.Header().Set(types.APIVersionHeader, api.Version)
+
 		apiVersion := vars["version"]
 		if apiVersion == "" {
 			apiVersion = api.Version
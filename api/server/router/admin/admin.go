@@ -0,0 +1,530 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/server/router"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
+)
+
+type adminRouter struct {
+	*broker.Broker
+	routes []router.Route
+}
+
+func NewRouter(broker *broker.Broker) router.Router {
+	r := &adminRouter{Broker: broker}
+
+	r.routes = []router.Route{
+		router.NewGetRoute("/admin/config", r.adminOnly(r.getConfig)),
+		router.NewPutRoute("/admin/config", r.adminOnly(r.updateConfig)),
+		router.NewGetRoute("/admin/nodes", r.adminOnly(r.getNodes)),
+		router.NewPostRoute("/admin/upgrade", r.adminOnly(r.upgrade)),
+		router.NewPostRoute("/admin/upgrade/pause", r.adminOnly(r.pauseUpgrade)),
+		router.NewPostRoute("/admin/upgrade/resume", r.adminOnly(r.resumeUpgrade)),
+		router.NewPostRoute("/admin/upgrade/abort", r.adminOnly(r.abortUpgrade)),
+		router.NewPostRoute("/admin/users/{name}/unlock", r.adminOnly(r.unlockUser)),
+		router.NewPutRoute("/admin/users/{name}/password", r.adminOnly(r.resetPassword)),
+		router.NewPutRoute("/admin/namespaces/{namespace}/domain", r.adminOnly(r.setDomain)),
+		router.NewDeleteRoute("/admin/namespaces/{namespace}/domain", r.adminOnly(r.removeDomain)),
+		router.NewPostRoute("/admin/applications/{name}/deploy/lock", r.adminOnly(r.lockDeployments)),
+		router.NewDeleteRoute("/admin/applications/{name}/deploy/lock", r.adminOnly(r.unlockDeployments)),
+		router.NewGetRoute("/admin/invites", r.adminOnly(r.getInvites)),
+		router.NewPostRoute("/admin/invites", r.adminOnly(r.createInvite)),
+		router.NewDeleteRoute("/admin/invites/{id}", r.adminOnly(r.removeInvite)),
+		router.NewGetRoute("/admin/serviceaccounts", r.adminOnly(r.getServiceAccounts)),
+		router.NewPostRoute("/admin/serviceaccounts", r.adminOnly(r.createServiceAccount)),
+		router.NewDeleteRoute("/admin/serviceaccounts/{name}", r.adminOnly(r.removeServiceAccount)),
+		router.NewGetRoute("/admin/notices", r.adminOnly(r.getNotices)),
+		router.NewPostRoute("/admin/notices", r.adminOnly(r.createNotice)),
+		router.NewDeleteRoute("/admin/notices/{id}", r.adminOnly(r.removeNotice)),
+	}
+
+	return r
+}
+
+func (ar *adminRouter) Routes() []router.Route {
+	return ar.routes
+}
+
+// adminOnly rejects requests from users other than platform administrators.
+func (ar *adminRouter) adminOnly(handler httputils.APIFunc) httputils.APIFunc {
+	return func(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		if !ar.IsAdmin(httputils.UserFromContext(r.Context())) {
+			return httputils.NewStatusError(http.StatusForbidden)
+		}
+		return handler(w, r, vars)
+	}
+}
+
+var sensitiveKeys = regexp.MustCompile(`(?i)password|secret|token|(^|[._])(\w*_)?key$`)
+
+const redacted = "[REDACTED]"
+
+// getConfig returns the configuration as a map of "section.key" to value.
+// Keys in the default section are not qualified. Credentials and encrypted
+// values are redacted.
+func (ar *adminRouter) getConfig(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	result := make(map[string]string)
+	for _, section := range config.GetSections() {
+		for key, value := range config.GetRawSection(section) {
+			if section != "default" {
+				key = section + "." + key
+			}
+			result[key] = redactValue(key, value)
+		}
+	}
+	return httputils.WriteJSON(w, http.StatusOK, result)
+}
+
+// redactValue redacts credentials, encrypted values, PEM encoded keys and
+// user information embedded in URLs.
+func redactValue(key, value string) string {
+	if sensitiveKeys.MatchString(key) || config.IsEncrypted(value) || strings.Contains(value, "-----BEGIN") {
+		return redacted
+	}
+	if u, err := url.Parse(value); err == nil && u.User != nil && u.Scheme != "" {
+		u.User = nil
+		return u.Scheme + "://" + redacted + "@" + strings.TrimPrefix(u.String(), u.Scheme+"://")
+	}
+	return value
+}
+
+// updateConfig applies configuration changes in the form of "section.key"
+// to value. An empty value removes the key. The new configuration is saved
+// and takes effect immediately.
+func (ar *adminRouter) updateConfig(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	var changes map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		return err
+	}
+	for key, value := range changes {
+		if value == redacted || (strings.Contains(value, redacted) && value == redactValue(key, config.Get(key))) {
+			delete(changes, key) // unchanged credential
+		}
+	}
+
+	if err := config.Update(changes, validateConfig); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// validateConfig checks the updated configuration before it's saved.
+func validateConfig(old, c *config.Config) error {
+	errs := c.Validate().Errors()
+	if c.Get("scm.type") != old.Get("scm.type") {
+		errs = append(errs, &config.ValidationError{
+			Key:     "scm.type",
+			Message: "cannot be changed without restart",
+		})
+	}
+	if len(errs) != 0 {
+		return invalidConfigError{errs}
+	}
+	return nil
+}
+
+type invalidConfigError struct {
+	config.ValidationErrors
+}
+
+func (e invalidConfigError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// getNodes returns status of container engine nodes.
+func (ar *adminRouter) getNodes(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	nodes := ar.Nodes(r.Context())
+	result := make([]*types.NodeStatus, len(nodes))
+	for i, n := range nodes {
+		st := &types.NodeStatus{
+			Host:        n.Host,
+			Reachable:   n.Reachable,
+			Schedulable: n.Schedulable,
+			Error:       n.Error,
+			LastCheck:   n.LastCheck,
+			Failures:    n.Failures,
+			Headroom:    n.Headroom,
+		}
+		if info := n.Info; info != nil {
+			st.Name = info.Name
+			st.Version = info.Version
+			st.OS = info.OS
+			st.Arch = info.Arch
+			st.NCPU = info.NCPU
+			st.MemTotal = info.MemTotal
+			st.Containers = info.Containers
+			st.ContainersRunning = info.ContainersRunning
+			st.ContainersStopped = info.ContainersStopped
+			st.UsernsRemap = info.UsernsRemap
+		}
+		result[i] = st
+	}
+	return httputils.WriteJSON(w, http.StatusOK, result)
+}
+
+// upgrade upgrades application containers and streams the progress. The
+// upgrade is aborted after the application being upgraded if the client
+// disconnected.
+func (ar *adminRouter) upgrade(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var opts broker.UpgradeOptions
+	var err error
+
+	if batch := r.FormValue("batch"); batch != "" {
+		if opts.BatchSize, err = strconv.Atoi(batch); err != nil || opts.BatchSize <= 0 {
+			http.Error(w, "Invalid batch size: "+batch, http.StatusBadRequest)
+			return nil
+		}
+	}
+	if canary := r.FormValue("canary"); canary != "" {
+		if opts.Canary, err = strconv.Atoi(canary); err != nil || opts.Canary < 0 {
+			http.Error(w, "Invalid canary size: "+canary, http.StatusBadRequest)
+			return nil
+		}
+	}
+	if timeout := r.FormValue("timeout"); timeout != "" {
+		if opts.Timeout, err = time.ParseDuration(timeout); err != nil {
+			http.Error(w, "Invalid timeout: "+timeout, http.StatusBadRequest)
+			return nil
+		}
+	}
+
+	log := httputils.NewServerLog(w, r)
+	if err = ar.Upgrade(r.Context(), opts, log); err != nil {
+		log.SendError(err)
+	}
+	return nil
+}
+
+func (ar *adminRouter) pauseUpgrade(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := ar.PauseUpgrade(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *adminRouter) resumeUpgrade(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := ar.ResumeUpgrade(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *adminRouter) abortUpgrade(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := ar.AbortUpgrade(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// unlockUser unlocks a user account locked due to too many failed login
+// attempts.
+func (ar *adminRouter) unlockUser(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := ar.Users.Unlock(vars["name"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// lockDeployments locks deployments of any application, which is
+// addressed by the full name "name-namespace".
+func (ar *adminRouter) lockDeployments(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	name, namespace, ok := broker.SplitFullName(vars["name"])
+	if !ok {
+		return broker.ApplicationNotFoundError(vars["name"])
+	}
+	user := httputils.UserFromContext(r.Context())
+	if err := ar.LockDeployments(name, namespace, user.Name, r.FormValue("reason")); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *adminRouter) unlockDeployments(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	name, namespace, ok := broker.SplitFullName(vars["name"])
+	if !ok {
+		return broker.ApplicationNotFoundError(vars["name"])
+	}
+	if err := ar.UnlockDeployments(name, namespace); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// resetPassword resets the password of a user, the password must conform
+// to the password policy.
+func (ar *adminRouter) resetPassword(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	var req types.ResetPassword
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	if err := ar.Users.SetPassword(vars["name"], req.Password); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// setDomain delegates a subdomain zone to the namespace, applications in
+// the namespace are served under the domain.
+func (ar *adminRouter) setDomain(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	var req types.NamespaceDomain
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	if req.Domain == "" {
+		return broker.InvalidDomainError{Message: "the domain is required"}
+	}
+	return ar.setNamespaceDomain(w, r, vars["namespace"], req.Domain)
+}
+
+func (ar *adminRouter) removeDomain(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	return ar.setNamespaceDomain(w, r, vars["namespace"], "")
+}
+
+func (ar *adminRouter) setNamespaceDomain(w http.ResponseWriter, r *http.Request, namespace, domain string) error {
+	owner, err := ar.Users.FindByNamespace(namespace)
+	if err != nil {
+		return err
+	}
+	if err = ar.NewUserBroker(owner, r.Context()).SetDomain(domain); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *adminRouter) getInvites(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	invites, err := ar.Users.ListInvites()
+	if err != nil {
+		return err
+	}
+	result := make([]*types.Invite, len(invites))
+	for i, inv := range invites {
+		result[i] = convertInvite(inv)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, result)
+}
+
+// createInvite creates a single-use invitation to register a user. The
+// invitation link is returned and mailed to the invited email address.
+func (ar *adminRouter) createInvite(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	var req types.CreateInvite
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	inv := &userdb.Invite{
+		Email:     strings.TrimSpace(req.Email),
+		Namespace: req.Namespace,
+		CreatedBy: httputils.UserFromContext(r.Context()).Name,
+	}
+
+	var ttl time.Duration
+	var err error
+	if req.DiskQuota != "" {
+		if inv.DiskQuota, err = units.RAMInBytes(req.DiskQuota); err != nil || inv.DiskQuota <= 0 {
+			http.Error(w, "Invalid disk quota: "+req.DiskQuota, http.StatusBadRequest)
+			return nil
+		}
+	}
+	if req.TTL != "" {
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+			http.Error(w, "Invalid expiration time: "+req.TTL, http.StatusBadRequest)
+			return nil
+		}
+	}
+
+	token, link, err := ar.CreateInvite(inv, ttl)
+	if err != nil {
+		return err
+	}
+	result := convertInvite(inv)
+	result.Token = token
+	result.URL = link
+	return httputils.WriteJSON(w, http.StatusCreated, result)
+}
+
+func (ar *adminRouter) removeInvite(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := ar.Users.RemoveInvite(vars["id"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func convertInvite(inv *userdb.Invite) *types.Invite {
+	return &types.Invite{
+		ID:        inv.ID,
+		Email:     inv.Email,
+		Namespace: inv.Namespace,
+		DiskQuota: inv.DiskQuota,
+		CreatedBy: inv.CreatedBy,
+		CreatedAt: inv.CreatedAt,
+		Expires:   inv.Expires,
+	}
+}
+
+func (ar *adminRouter) getServiceAccounts(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	accounts, err := ar.Users.ListServiceAccounts()
+	if err != nil {
+		return err
+	}
+	result := make([]*types.ServiceAccount, len(accounts))
+	for i, sa := range accounts {
+		result[i] = convertServiceAccount(sa)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, result)
+}
+
+// createServiceAccount creates a service account bound to a namespace or an
+// application. The token is only returned in the response.
+func (ar *adminRouter) createServiceAccount(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	var req types.CreateServiceAccount
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	sa := &userdb.ServiceAccount{
+		Name:        req.Name,
+		Namespace:   req.Namespace,
+		Application: req.Application,
+		Scopes:      req.Scopes,
+		CreatedBy:   httputils.UserFromContext(r.Context()).Name,
+	}
+	token, err := ar.CreateServiceAccount(sa)
+	if err != nil {
+		return err
+	}
+	result := convertServiceAccount(sa)
+	result.Token = token
+	return httputils.WriteJSON(w, http.StatusCreated, result)
+}
+
+func (ar *adminRouter) removeServiceAccount(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := ar.Users.RemoveServiceAccount(vars["name"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func convertServiceAccount(sa *userdb.ServiceAccount) *types.ServiceAccount {
+	return &types.ServiceAccount{
+		Name:        sa.Name,
+		Namespace:   sa.Namespace,
+		Application: sa.Application,
+		Scopes:      sa.Scopes,
+		CreatedBy:   sa.CreatedBy,
+		CreatedAt:   sa.CreatedAt,
+	}
+}
+
+// getNotices returns all notices including scheduled and expired ones.
+func (ar *adminRouter) getNotices(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	notices, err := ar.Users.ListNotices()
+	if err != nil {
+		return err
+	}
+	result := make([]*types.Notice, len(notices))
+	for i, n := range notices {
+		result[i] = convertNotice(n)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, result)
+}
+
+// createNotice creates a notice shown to all users in the console and
+// command line clients.
+func (ar *adminRouter) createNotice(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	var req types.CreateNotice
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	n := &userdb.Notice{
+		Kind:      req.Kind,
+		Message:   req.Message,
+		URL:       req.URL,
+		CreatedBy: httputils.UserFromContext(r.Context()).Name,
+	}
+	if req.Starts != nil {
+		n.Starts = *req.Starts
+	}
+	if req.Expires != nil {
+		n.Expires = *req.Expires
+	}
+
+	if err := ar.CreateNotice(n); err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusCreated, convertNotice(n))
+}
+
+func (ar *adminRouter) removeNotice(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := ar.RemoveNotice(vars["id"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func convertNotice(n *userdb.Notice) *types.Notice {
+	result := &types.Notice{
+		ID:        n.ID,
+		Kind:      n.Kind,
+		Message:   n.Message,
+		URL:       n.URL,
+		Starts:    n.Starts,
+		CreatedBy: n.CreatedBy,
+		CreatedAt: n.CreatedAt,
+	}
+	if !n.Expires.IsZero() {
+		expires := n.Expires
+		result.Expires = &expires
+	}
+	return result
+}
@@ -1,7 +1,6 @@
 package applications
 
 import (
-	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -9,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -40,29 +40,73 @@ func NewRouter(broker *broker.Broker) router.Router {
 	r := &applicationsRouter{Broker: broker}
 
 	r.routes = []router.Route{
-		router.NewGetRoute("/applications/", r.list),
-		router.NewPostRoute("/applications/", r.create),
-		router.NewGetRoute(appPath, r.info),
-		router.NewDeleteRoute(appPath, r.delete),
-		router.NewPostRoute(appPath+"/start", r.start),
-		router.NewPostRoute(appPath+"/stop", r.stop),
-		router.NewPostRoute(appPath+"/restart", r.restart),
-		router.NewGetRoute(appPath+"/status", r.status),
-		router.NewGetRoute("/applications/status/", r.allStatus),
-		router.NewGetRoute(appPath+"/procs", r.procs),
-		router.NewGetRoute(appPath+"/stats", r.stats),
-		router.NewPostRoute(appPath+"/deploy", r.deploy),
-		router.NewGetRoute(appPath+"/deploy", r.getDeployments),
-		router.NewGetRoute(appPath+"/repo", r.download),
-		router.NewPutRoute(appPath+"/repo", r.upload),
-		router.NewGetRoute(appPath+"/data", r.dump),
-		router.NewPutRoute(appPath+"/data", r.restore),
-		router.NewPostRoute(appPath+"/scale", r.scale),
-		router.NewPostRoute(appPath+"/services/", r.createService),
-		router.NewDeleteRoute(servicePath, r.removeService),
-		router.NewGetRoute(servicePath+"/env/", r.environ),
-		router.NewPostRoute(servicePath+"/env/", r.setenv),
-		router.NewGetRoute(servicePath+"/env/{key:.*}", r.getenv),
+		router.NewGetRoute("/applications/", r.shared(ownerOnly, r.list)),
+		router.NewPostRoute("/applications/", r.shared(ownerOnly, r.create)),
+		router.NewPostRoute("/applications/import", r.shared(ownerOnly, r.importApplication)),
+		router.NewGetRoute(appPath, r.shared(readAccess, r.info)),
+		router.NewDeleteRoute(appPath, r.shared(ownerOnly, r.delete)),
+		router.NewGetRoute(appPath+"/export", r.shared(ownerOnly, r.export)),
+		router.NewPostRoute(appPath+"/rename", r.shared(ownerOnly, r.rename)),
+		router.NewPostRoute(appPath+"/start", r.shared(deployAccess, r.start)),
+		router.NewPostRoute(appPath+"/stop", r.shared(deployAccess, r.stop)),
+		router.NewPostRoute(appPath+"/restart", r.shared(deployAccess, r.restart)),
+		router.NewPostRoute(appPath+"/maintenance", r.shared(ownerOnly, r.maintenance)),
+		router.NewPostRoute(appPath+"/reconcile", r.shared(ownerOnly, r.reconcile)),
+		router.NewPutRoute(appPath+"/protection", r.shared(ownerOnly, r.protection)),
+		router.NewGetRoute(appPath+"/status", r.shared(readAccess, r.status)),
+		router.NewGetRoute(appPath+"/routes", r.shared(readAccess, r.listRoutes)),
+		router.NewGetRoute("/applications/status/", r.shared(ownerOnly, r.allStatus)),
+		router.NewGetRoute("/trash", r.listTrash),
+		router.NewPostRoute("/trash/{name:[^/]+}/restore", r.restoreTrash),
+		router.NewDeleteRoute("/trash/{name:[^/]+}", r.purgeTrash),
+		router.NewGetRoute(appPath+"/procs", r.shared(readAccess, r.procs)),
+		router.NewGetRoute(appPath+"/stats", r.shared(readAccess, r.stats)),
+		router.NewGetRoute(appPath+"/du", r.shared(readAccess, r.diskUsage)),
+		router.NewPostRoute(appPath+"/deploy", r.shared(deployAccess, r.deploy)),
+		router.NewGetRoute(appPath+"/deploy", r.shared(readAccess, r.getDeployments)),
+		router.NewGetRoute(appPath+"/deploy/stream", r.shared(deployAccess, r.deployStream)),
+		router.NewGetRoute(appPath+"/deploy/history", r.shared(readAccess, r.deploymentHistory)),
+		router.NewGetRoute(appPath+"/deploy/history/{id:[^/]+}/log", r.shared(readAccess, r.deploymentLog)),
+		router.NewPostRoute(appPath+"/deploy/history/{id:[^/]+}/rollback", r.shared(deployAccess, r.rollback)),
+		router.NewPostRoute(appPath+"/deploy/lock", r.shared(lockAccess, r.lockDeployments)),
+		router.NewDeleteRoute(appPath+"/deploy/lock", r.shared(lockAccess, r.unlockDeployments)),
+		router.NewPutRoute(appPath+"/deploy/windows", r.shared(ownerOnly, r.setDeployWindows)),
+		router.NewPostRoute(appPath+"/traffic", r.shared(deployAccess, r.setTraffic)),
+		router.NewGetRoute(appPath+"/access-policy", r.shared(readAccess, r.getAccessPolicy)),
+		router.NewPutRoute(appPath+"/access-policy", r.shared(ownerOnly, r.setAccessPolicy)),
+		router.NewDeleteRoute(appPath+"/access-policy", r.shared(ownerOnly, r.removeAccessPolicy)),
+		router.NewGetRoute(appPath+"/http-settings", r.shared(readAccess, r.getHTTPSettings)),
+		router.NewPutRoute(appPath+"/http-settings", r.shared(ownerOnly, r.setHTTPSettings)),
+		router.NewGetRoute(appPath+"/logging", r.shared(readAccess, r.getLogging)),
+		router.NewPutRoute(appPath+"/logging", r.shared(ownerOnly, r.setLogging)),
+		router.NewPutRoute(appPath+"/labels", r.shared(ownerOnly, r.setLabels)),
+		router.NewGetRoute(appPath+"/repo", r.shared(readAccess, r.download)),
+		router.NewPutRoute(appPath+"/repo", r.shared(uploadAccess, r.upload)),
+		router.NewPutRoute(appPath+"/build", r.shared(uploadAccess, r.build)),
+		router.NewGetRoute(appPath+"/artifacts", r.shared(readAccess, r.listArtifacts)),
+		router.NewPostRoute(appPath+"/artifacts/{id:[^/]+}/deploy", r.shared(deployAccess, r.redeployArtifact)),
+		router.NewGetRoute(appPath+"/data", r.shared(ownerOnly, r.dump)),
+		router.NewPutRoute(appPath+"/data", r.shared(ownerOnly, r.restore)),
+		router.NewPostRoute(appPath+"/uploads", r.shared(uploadAccess, r.createUpload)),
+		router.NewGetRoute(appPath+"/uploads/{id:[^/]+}", r.shared(uploadAccess, r.getUpload)),
+		router.NewPutRoute(appPath+"/uploads/{id:[^/]+}", r.shared(uploadAccess, r.appendUpload)),
+		router.NewDeleteRoute(appPath+"/uploads/{id:[^/]+}", r.shared(uploadAccess, r.removeUpload)),
+		router.NewPostRoute(appPath+"/scale", r.shared(ownerOnly, r.scale)),
+		router.NewGetRoute(appPath+"/collaborators", r.shared(readAccess, r.listCollaborators)),
+		router.NewPutRoute(appPath+"/collaborators/{user:[^/]+}", r.shared(ownerOnly, r.addCollaborator)),
+		router.NewDeleteRoute(appPath+"/collaborators/{user:[^/]+}", r.shared(ownerOnly, r.removeCollaborator)),
+		router.NewGetRoute(appPath+"/links", r.shared(readAccess, r.listLinks)),
+		router.NewPutRoute(appPath+"/links/{app:[^/]+}/{service:[^/]+}", r.shared(ownerOnly, r.linkService)),
+		router.NewDeleteRoute(appPath+"/links/{app:[^/]+}/{service:[^/]+}", r.shared(ownerOnly, r.unlinkService)),
+		router.NewPostRoute(appPath+"/services/", r.shared(ownerOnly, r.createService)),
+		router.NewDeleteRoute(servicePath, r.shared(ownerOnly, r.removeService)),
+		router.NewGetRoute(servicePath+"/env/", r.shared(readAccess, r.environ)),
+		router.NewPostRoute(servicePath+"/env/", r.shared(ownerOnly, r.setenv)),
+		router.NewGetRoute(servicePath+"/env/{key:.*}", r.shared(readAccess, r.getenv)),
+		router.NewGetRoute(servicePath+"/files", r.shared(ownerOnly, r.downloadFiles)),
+		router.NewPutRoute(servicePath+"/files", r.shared(ownerOnly, r.uploadFiles)),
+		router.NewGetRoute(servicePath+"/data", r.shared(ownerOnly, r.dump)),
+		router.NewPutRoute(servicePath+"/data", r.shared(ownerOnly, r.restore)),
 	}
 
 	return r
@@ -72,23 +116,48 @@ func (ar *applicationsRouter) Routes() []router.Route {
 	return ar.routes
 }
 
+// NewUserBroker returns the broker of the user, or the broker of the owner
+// if a collaborator is accessing a shared application.
 func (ar *applicationsRouter) NewUserBroker(r *http.Request) *broker.UserBroker {
 	ctx := r.Context()
+	if br, ok := ctx.Value(sharedBrokerKey{}).(*broker.UserBroker); ok {
+		return br
+	}
 	user := httputils.UserFromContext(ctx)
 	return ar.Broker.NewUserBroker(user, ctx)
 }
 
 func (ar *applicationsRouter) list(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
-	apps, err := ar.NewUserBroker(r).GetApplications()
+	sel, err := labelSelector(r)
 	if err != nil {
 		return err
 	}
 
-	var names []string
-	for name := range apps {
-		names = append(names, name)
+	// the listing is polled by clients with the ETag, the user record can
+	// be served from cache
+	br := ar.NewUserBroker(r)
+	if err := br.RefreshCached(); err != nil {
+		return err
+	}
+
+	apps := br.User.Basic().Applications
+	names := make([]string, 0, len(apps))
+	for name, app := range apps {
+		if sel.Matches(app.Labels) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return httputils.WriteJSONWithETag(w, r, names)
+}
+
+// labelSelector parses the "label" query parameters of the request, such
+// as "?label=env=prod&label=team".
+func labelSelector(r *http.Request) (broker.LabelSelector, error) {
+	if err := httputils.ParseForm(r); err != nil {
+		return nil, err
 	}
-	return httputils.WriteJSON(w, http.StatusOK, names)
+	return broker.ParseLabelSelector(r.Form["label"])
 }
 
 func (ar *applicationsRouter) info(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
@@ -108,18 +177,38 @@ func (ar *applicationsRouter) info(w http.ResponseWriter, r *http.Request, vars
 		return httputils.NewStatusError(http.StatusNotFound)
 	}
 
-	info, err := ar.getInfo(name, namespace, app)
+	info, err := ar.getInfo(name, namespace, br.User.Basic().Domain, app)
 	if err != nil {
 		return err
 	}
 
 	cs, _ := ar.FindApplications(r.Context(), name, namespace)
 	info.Scaling = len(cs)
+	if len(cs) != 0 {
+		res := cs[0].Resources()
+		info.MemoryLimit, info.CPULimit = res.Memory, res.CPUs
+	}
+
+	if lock := ar.ApplicationLock(name, namespace); lock != nil {
+		info.Lock = &types.OperationLock{Operation: lock.Operation, Since: lock.Since}
+	}
+
+	// the proxy may not be configured, in which case no routes reported
+	info.Routes, _ = ar.getRoutes(r.Context(), name, namespace)
+
+	// deployment state is supplemental, failures are not reported
+	if current, err := ar.CurrentDeploymentBranch(name, namespace); err == nil {
+		info.Branch = convertBranchJson(current)
+	}
+	if d, err := ar.LatestDeployment(name, namespace); err == nil && d != nil {
+		info.LastDeployment = convertDeployment(d)
+	}
+	info.DiskQuota = ar.NamespaceDiskQuota(namespace)
 
 	return httputils.WriteJSON(w, http.StatusOK, &info)
 }
 
-func (ar *applicationsRouter) getInfo(name, namespace string, app *userdb.Application) (info *types.ApplicationInfo, err error) {
+func (ar *applicationsRouter) getInfo(name, namespace, domain string, app *userdb.Application) (info *types.ApplicationInfo, err error) {
 	info = &types.ApplicationInfo{
 		Name:      name,
 		Namespace: namespace,
@@ -127,19 +216,37 @@ func (ar *applicationsRouter) getInfo(name, namespace string, app *userdb.Applic
 		Scaling:   1,
 	}
 
+	if m := app.Maintenance; m != nil {
+		info.Maintenance = &types.Maintenance{By: m.By, Since: m.Since, Stopped: m.Stopped}
+	}
+	info.Protected = app.Protected
+	info.Labels = app.Labels
+	if l := app.DeployLock; l != nil {
+		info.DeployLock = &types.DeployLock{By: l.By, Reason: l.Reason, Since: l.Since}
+	}
+	info.DeployWindows = app.DeployWindows
+	info.Canary = convertCanary(app.Canary)
+	info.AccessPolicy = convertAccessPolicy(app.AccessPolicy)
+	if s := app.HTTPSettings; s != nil {
+		info.HTTPSettings = (*types.HTTPSettings)(s)
+	}
+	if l := app.Logging; l != nil {
+		info.Logging = (*types.LogConfig)(l)
+	}
+
 	base, err := url.Parse(defaults.ApiURL())
 	if err != nil {
 		return
 	}
 
-	host, port := base.Host, ""
-	if i := strings.IndexRune(host, ':'); i != -1 {
-		host, port = host[:i], host[i:]
+	port := ""
+	if i := strings.IndexRune(base.Host, ':'); i != -1 {
+		port = base.Host[i:]
 	}
-	info.URL = fmt.Sprintf("%s://%s-%s.%s%s", base.Scheme, name, namespace, defaults.Domain(), port)
-	info.SSHURL = fmt.Sprintf("ssh://%s-%s@%s%s", name, namespace, host, ":2200") // FIXME
+	info.URL = fmt.Sprintf("%s://%s%s", base.Scheme, defaults.AppHost(name, namespace, domain), port)
+	info.SSHURL = defaults.SSHURL(name, namespace)
 
-	info.SCMType = ar.SCM.Type()
+	info.SCMType = ar.SCM().Type()
 	cloneURL := config.Get("scm.clone_url")
 	if cloneURL != "" {
 		cloneURL = strings.Replace(cloneURL, "<namespace>", namespace, -1)
@@ -162,8 +269,6 @@ func (ar *applicationsRouter) getInfo(name, namespace string, app *userdb.Applic
 	return
 }
 
-var namePattern = regexp.MustCompile("^[a-z][a-z_0-9]*$")
-
 func (ar *applicationsRouter) create(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := httputils.ParseForm(r); err != nil {
 		return err
@@ -179,51 +284,69 @@ func (ar *applicationsRouter) create(w http.ResponseWriter, r *http.Request, var
 		return err
 	}
 
-	opts := container.CreateOptions{
-		Name:    req.Name,
-		Repo:    req.Repo,
-		Scaling: 1,
-		Log:     serverlog.New(w),
-	}
-
-	if !namePattern.MatchString(opts.Name) {
-		msg := "The application name can only contains lower case letters, digits or underscores."
-		http.Error(w, msg, http.StatusBadRequest)
-		return nil
+	// validate options before any container is created
+	if err := br.ValidateCreateApplication(&req); err != nil {
+		return err
 	}
 
-	if req.Framework == "" {
-		msg := "The application framework cannot be empty."
-		http.Error(w, msg, http.StatusBadRequest)
-		return nil
+	opts := container.CreateOptions{
+		Name:     req.Name,
+		Repo:     req.Repo,
+		RepoUser: req.RepoUsername,
+		RepoPass: req.RepoPassword,
+		Labels:   req.Labels,
+		Scaling:  req.Scaling,
+		Env:      req.Env,
+		Log:      httputils.NewServerLog(w, r),
 	}
 
 	tags := append([]string{req.Framework}, req.Services...)
 
+	if isDryRun(r) {
+		plan, err := br.PlanCreateApplication(opts, tags)
+		if err != nil {
+			return err
+		}
+		return httputils.WriteJSON(w, http.StatusOK, plan)
+	}
+
 	app, cs, err := br.CreateApplication(opts, tags)
 	if err != nil {
-		serverlog.SendError(w, err)
+		opts.Log.SendError(err)
 		return nil
 	}
 
 	if err = br.StartContainers(cs, opts.Log); err != nil {
-		serverlog.SendError(w, err)
+		opts.Log.SendError(err)
 		return nil
 	}
 
-	if info, err := ar.getInfo(req.Name, br.Namespace(), app); err != nil {
-		serverlog.SendError(w, err)
+	if info, err := ar.getInfo(req.Name, br.Namespace(), br.User.Basic().Domain, app); err != nil {
+		opts.Log.SendError(err)
 	} else {
-		serverlog.SendObject(w, info)
+		opts.Log.SendObject(info)
 	}
 
 	return nil
 }
 
 func (ar *applicationsRouter) delete(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
 	br := ar.NewUserBroker(r)
+	if err := br.CheckProtection(vars["name"], confirmation(r)); err != nil {
+		return err
+	}
 
-	if err := br.RemoveApplication(vars["name"]); err != nil {
+	var err error
+	if _, purge := r.Form["purge"]; purge {
+		err = br.PurgeApplication(vars["name"])
+	} else {
+		err = br.RemoveApplication(vars["name"])
+	}
+	if err != nil {
 		return err
 	} else {
 		w.WriteHeader(http.StatusNoContent)
@@ -231,6 +354,70 @@ func (ar *applicationsRouter) delete(w http.ResponseWriter, r *http.Request, var
 	}
 }
 
+func (ar *applicationsRouter) listTrash(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	trash, err := ar.NewUserBroker(r).GetTrash()
+	if err != nil {
+		return err
+	}
+
+	result := make([]*types.TrashedApplication, len(trash))
+	for i, t := range trash {
+		result[i] = &types.TrashedApplication{
+			Name:      t.Name,
+			Plugins:   t.Application.Plugins,
+			DeletedAt: t.DeletedAt,
+			ExpiresAt: t.ExpiresAt,
+			HasData:   t.HasData,
+		}
+	}
+	return httputils.WriteJSON(w, http.StatusOK, result)
+}
+
+func (ar *applicationsRouter) restoreTrash(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	br := ar.NewUserBroker(r)
+	log := httputils.NewServerLog(w, r)
+
+	app, _, err := br.RestoreApplication(vars["name"], log)
+	if err != nil {
+		log.SendError(err)
+		return nil
+	}
+
+	if info, err := ar.getInfo(vars["name"], br.Namespace(), br.User.Basic().Domain, app); err != nil {
+		log.SendError(err)
+	} else {
+		log.SendObject(info)
+	}
+	return nil
+}
+
+func (ar *applicationsRouter) purgeTrash(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := ar.NewUserBroker(r).PurgeTrash(vars["name"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *applicationsRouter) rename(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	newName := r.FormValue("newname")
+	if !types.ValidApplicationName(newName) {
+		verr := &types.ValidationError{Message: "Invalid application name"}
+		verr.Add("newname", "The application name can only contains lower case letters, digits or underscores.")
+		return verr
+	}
+
+	if err := ar.NewUserBroker(r).RenameApplication(vars["name"], newName); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
 func (ar *applicationsRouter) createService(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := httputils.CheckForJSON(r); err != nil {
 		return err
@@ -245,17 +432,17 @@ func (ar *applicationsRouter) createService(w http.ResponseWriter, r *http.Reque
 
 	opts := container.CreateOptions{
 		Name: vars["name"],
-		Log:  serverlog.New(w),
+		Log:  httputils.NewServerLog(w, r),
 	}
 
 	cs, err := br.CreateServices(opts, tags)
 	if err != nil {
-		serverlog.SendError(w, err)
+		opts.Log.SendError(err)
 		return nil
 	}
 
 	if err := br.StartContainers(cs, opts.Log); err != nil {
-		serverlog.SendError(w, err)
+		opts.Log.SendError(err)
 		return nil
 	}
 
@@ -275,9 +462,10 @@ func (ar *applicationsRouter) removeService(w http.ResponseWriter, r *http.Reque
 }
 
 func (ar *applicationsRouter) start(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
-	err := ar.NewUserBroker(r).StartApplication(vars["name"], serverlog.New(w))
+	log := httputils.NewServerLog(w, r)
+	err := ar.NewUserBroker(r).StartApplication(vars["name"], log)
 	if err != nil {
-		serverlog.SendError(w, err)
+		log.SendError(err)
 	}
 	return nil
 }
@@ -287,31 +475,284 @@ func (ar *applicationsRouter) stop(w http.ResponseWriter, r *http.Request, vars
 }
 
 func (ar *applicationsRouter) restart(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
-	err := ar.NewUserBroker(r).RestartApplication(vars["name"], serverlog.New(w))
+	log := httputils.NewServerLog(w, r)
+	err := ar.NewUserBroker(r).RestartApplication(vars["name"], log)
+	if err != nil {
+		log.SendError(err)
+	}
+	return nil
+}
+
+func (ar *applicationsRouter) maintenance(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	on, err := strconv.ParseBool(r.FormValue("on"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	stop, _ := strconv.ParseBool(r.FormValue("stop"))
+
+	log := httputils.NewServerLog(w, r)
+	err = ar.NewUserBroker(r).SetMaintenance(vars["name"], on, stop, log)
 	if err != nil {
-		serverlog.SendError(w, err)
+		log.SendError(err)
 	}
 	return nil
 }
 
+func (ar *applicationsRouter) reconcile(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	log := httputils.NewServerLog(w, r)
+	_, err := ar.NewUserBroker(r).ReconcileApplication(vars["name"], log)
+	if err != nil {
+		log.SendError(err)
+	}
+	return nil
+}
+
+func (ar *applicationsRouter) lockDeployments(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(r.Context())
+	namespace := ar.NewUserBroker(r).Namespace()
+	err := ar.LockDeployments(vars["name"], namespace, user.Name, r.FormValue("reason"))
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *applicationsRouter) unlockDeployments(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	namespace := ar.NewUserBroker(r).Namespace()
+	if err := ar.UnlockDeployments(vars["name"], namespace); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *applicationsRouter) setDeployWindows(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var windows []string
+	if err := json.NewDecoder(r.Body).Decode(&windows); err != nil {
+		return err
+	}
+	if err := ar.NewUserBroker(r).SetDeployWindows(vars["name"], windows); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *applicationsRouter) setTraffic(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var opts types.TrafficOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+		return err
+	}
+
+	canary, err := ar.NewUserBroker(r).SetTraffic(vars["name"], broker.CanaryOptions{
+		Artifact:    opts.Artifact,
+		Weight:      opts.Weight,
+		Containers:  opts.Containers,
+		AutoPromote: opts.AutoPromote,
+		Promote:     opts.Promote,
+		Rollback:    opts.Rollback,
+	})
+	if err != nil {
+		return err
+	}
+	if canary == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return httputils.WriteJSON(w, http.StatusOK, convertCanary(canary))
+}
+
+func convertCanary(c *userdb.Canary) *types.Canary {
+	if c == nil {
+		return nil
+	}
+	return &types.Canary{
+		Artifact:    c.Artifact,
+		Containers:  c.Containers,
+		Weight:      c.Weight,
+		AutoPromote: c.AutoPromote,
+		By:          c.By,
+		Since:       c.Since,
+	}
+}
+
+func (ar *applicationsRouter) getAccessPolicy(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	apps, err := ar.NewUserBroker(r).GetApplications()
+	if err != nil {
+		return err
+	}
+	app := apps[vars["name"]]
+	if app == nil {
+		return httputils.NewStatusError(http.StatusNotFound)
+	}
+
+	policy := convertAccessPolicy(app.AccessPolicy)
+	if policy == nil {
+		policy = &types.AccessPolicy{}
+	}
+	return httputils.WriteJSON(w, http.StatusOK, policy)
+}
+
+func (ar *applicationsRouter) setAccessPolicy(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var policy types.AccessPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		return err
+	}
+	if err := ar.NewUserBroker(r).SetAccessPolicy(vars["name"], &policy); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *applicationsRouter) setLabels(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var labels map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&labels); err != nil {
+		return err
+	}
+	if err := ar.NewUserBroker(r).SetLabels(vars["name"], labels); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *applicationsRouter) removeAccessPolicy(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := ar.NewUserBroker(r).SetAccessPolicy(vars["name"], nil); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *applicationsRouter) getHTTPSettings(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	apps, err := ar.NewUserBroker(r).GetApplications()
+	if err != nil {
+		return err
+	}
+	app := apps[vars["name"]]
+	if app == nil {
+		return httputils.NewStatusError(http.StatusNotFound)
+	}
+
+	settings := &types.HTTPSettings{}
+	if app.HTTPSettings != nil {
+		settings = (*types.HTTPSettings)(app.HTTPSettings)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, settings)
+}
+
+func (ar *applicationsRouter) setHTTPSettings(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var settings types.HTTPSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		return err
+	}
+	if err := ar.NewUserBroker(r).SetHTTPSettings(vars["name"], (*userdb.HTTPSettings)(&settings)); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *applicationsRouter) getLogging(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	apps, err := ar.NewUserBroker(r).GetApplications()
+	if err != nil {
+		return err
+	}
+	app := apps[vars["name"]]
+	if app == nil {
+		return httputils.NewStatusError(http.StatusNotFound)
+	}
+
+	logging := &types.LogConfig{}
+	if app.Logging != nil {
+		logging = (*types.LogConfig)(app.Logging)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, logging)
+}
+
+func (ar *applicationsRouter) setLogging(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var logging types.LogConfig
+	if err := json.NewDecoder(r.Body).Decode(&logging); err != nil {
+		return err
+	}
+	if err := ar.NewUserBroker(r).SetLogging(vars["name"], (*userdb.LogConfig)(&logging)); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// convertAccessPolicy converts the access policy without password hashes.
+func convertAccessPolicy(p *userdb.AccessPolicy) *types.AccessPolicy {
+	if p == nil {
+		return nil
+	}
+	policy := &types.AccessPolicy{
+		Allow:               p.Allow,
+		Deny:                p.Deny,
+		MaintenanceRedirect: p.MaintenanceRedirect,
+	}
+	for _, u := range p.BasicAuth {
+		policy.BasicAuth = append(policy.BasicAuth, types.BasicAuthUser{User: u.User})
+	}
+	return policy
+}
+
+func (ar *applicationsRouter) protection(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	protected, err := strconv.ParseBool(r.FormValue("protected"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	if err = ar.NewUserBroker(r).SetProtection(vars["name"], protected); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// confirmation returns the confirmation of destructive operations on
+// protected applications, which is the application name given by the
+// confirmation header or the "force" parameter.
+func confirmation(r *http.Request) string {
+	if confirm := r.Header.Get(types.ConfirmHeader); confirm != "" {
+		return confirm
+	}
+	return r.FormValue("force")
+}
+
 func (ar *applicationsRouter) status(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	var (
 		br   = ar.NewUserBroker(r)
 		name = vars["name"]
 	)
-	if err := br.Refresh(); err != nil {
+	if err := br.RefreshCached(); err != nil {
 		return err
 	}
-	status, err := ar.getStatus(r.Context(), name, br.Namespace())
+	_, size := r.URL.Query()["size"]
+	status, err := ar.getStatus(r.Context(), name, br.Namespace(), size)
 	if err != nil {
 		return err
 	}
+	if etag, err := httputils.ETag(withoutUptime(status)); err == nil {
+		if httputils.CheckNotModified(w, r, "W/"+etag) {
+			return nil
+		}
+	}
 	return httputils.WriteJSON(w, http.StatusOK, status)
 }
 
 func (ar *applicationsRouter) allStatus(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	sel, err := labelSelector(r)
+	if err != nil {
+		return err
+	}
+
 	br := ar.NewUserBroker(r)
-	if err := br.Refresh(); err != nil {
+	if err := br.RefreshCached(); err != nil {
 		return err
 	}
 
@@ -322,11 +763,15 @@ func (ar *applicationsRouter) allStatus(w http.ResponseWriter, r *http.Request,
 		mu        sync.Mutex
 		wg        sync.WaitGroup
 	)
-	wg.Add(len(apps))
-	for name := range apps {
+	_, size := r.URL.Query()["size"]
+	for name, app := range apps {
+		if !sel.Matches(app.Labels) {
+			continue
+		}
+		wg.Add(1)
 		go func(name string, wg *sync.WaitGroup) {
 			defer wg.Done()
-			st, err := ar.getStatus(r.Context(), name, namespace)
+			st, err := ar.getStatus(r.Context(), name, namespace, size)
 			if err == nil {
 				mu.Lock()
 				status[name] = st
@@ -335,10 +780,32 @@ func (ar *applicationsRouter) allStatus(w http.ResponseWriter, r *http.Request,
 		}(name, &wg)
 	}
 	wg.Wait()
+
+	tag := make(map[string][]types.ContainerStatus, len(status))
+	for name, st := range status {
+		tag[name] = withoutUptime(st)
+	}
+	if etag, err := httputils.ETag(tag); err == nil {
+		if httputils.CheckNotModified(w, r, "W/"+etag) {
+			return nil
+		}
+	}
 	return httputils.WriteJSON(w, http.StatusOK, status)
 }
 
-func (ar *applicationsRouter) getStatus(ctx context.Context, name, namespace string) ([]*types.ContainerStatus, error) {
+// withoutUptime returns a copy of container status without the uptime,
+// which changes on every request, to compute a weak entity tag so that
+// clients can poll the application status cheaply.
+func withoutUptime(status []*types.ContainerStatus) []types.ContainerStatus {
+	result := make([]types.ContainerStatus, len(status))
+	for i, st := range status {
+		result[i] = *st
+		result[i].Uptime = 0
+	}
+	return result
+}
+
+func (ar *applicationsRouter) getStatus(ctx context.Context, name, namespace string, size bool) ([]*types.ContainerStatus, error) {
 	cs, err := ar.FindAll(ctx, name, namespace)
 	if err != nil {
 		return nil, err
@@ -354,7 +821,7 @@ func (ar *applicationsRouter) getStatus(ctx context.Context, name, namespace str
 		status[i] = st
 
 		st.IPAddress = c.IP()
-		st.State = c.ActiveState(ctx)
+		st.State = ar.ActiveState(ctx, c)
 		if plugin != nil {
 			st.Ports = plugin.GetPrivatePorts()
 		}
@@ -363,6 +830,10 @@ func (ar *applicationsRouter) getStatus(ctx context.Context, name, namespace str
 		if err == nil {
 			st.Uptime = int64(time.Now().UTC().Sub(started))
 		}
+
+		if size {
+			st.DiskUsage, _ = c.DiskUsage(ctx)
+		}
 	}
 	return status, nil
 }
@@ -433,25 +904,67 @@ func (ar *applicationsRouter) stats(w http.ResponseWriter, r *http.Request, vars
 }
 
 func (ar *applicationsRouter) deploy(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
-	user := httputils.UserFromContext(r.Context())
+	namespace := ar.NewUserBroker(r).Namespace()
 	name, branch := vars["name"], r.FormValue("branch")
 
-	err := ar.Deploy(name, user.Namespace, branch, serverlog.New(w))
+	log := httputils.NewServerLog(w, r)
+	err := ar.Deploy(r.Context(), name, namespace, branch, log)
 	if err != nil {
-		serverlog.SendError(w, err)
+		log.SendError(err)
 	}
 	return nil
 }
 
+// deployStream deploys the application and reports progress as server-sent
+// events, as an alternative to the multiplexed stream used by deploy.
+func (ar *applicationsRouter) deployStream(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	namespace := ar.NewUserBroker(r).Namespace()
+	name, branch := vars["name"], r.FormValue("branch")
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	es := serverlog.NewEventStream(w)
+	status := types.DeploymentStatus{Name: name, Branch: branch, State: "deploying"}
+	es.SendObject(serverlog.EventStatus, &status)
+
+	if err := ar.Deploy(r.Context(), name, namespace, branch, es.Log()); err != nil {
+		status.State = "failed"
+		es.SendError(err)
+	} else {
+		status.State = "deployed"
+	}
+
+	es.SendObject(serverlog.EventStatus, &status)
+	es.Send(serverlog.EventEnd, nil)
+	return nil
+}
+
 func (ar *applicationsRouter) getDeployments(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
-	user := httputils.UserFromContext(r.Context())
+	namespace := ar.NewUserBroker(r).Namespace()
 	name := vars["name"]
 
-	current, err := ar.SCM.GetDeploymentBranch(user.Namespace, name)
+	if refresh, _ := strconv.ParseBool(r.FormValue("refresh")); refresh {
+		scm.InvalidateCache(ar.SCM(), namespace, name)
+	}
+
+	opts := scm.BranchOptions{FilterText: r.FormValue("filterText")}
+	if limit := r.FormValue("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return httputils.InvalidParameterError{Name: "limit", Value: limit}
+		}
+		opts.Limit = n
+	}
+
+	current, err := ar.SCM().GetDeploymentBranch(namespace, name)
 	if err != nil {
 		return err
 	}
-	branches, err := ar.SCM.GetDeploymentBranches(user.Namespace, name)
+
+	branches, err := ar.SCM().GetDeploymentBranches(namespace, name, opts)
 	if err != nil {
 		return err
 	}
@@ -464,11 +977,68 @@ func (ar *applicationsRouter) getDeployments(w http.ResponseWriter, r *http.Requ
 	return httputils.WriteJSON(w, http.StatusOK, &resp)
 }
 
+func (ar *applicationsRouter) deploymentHistory(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	history, err := ar.NewUserBroker(r).GetDeployments(vars["name"])
+	if err != nil {
+		return err
+	}
+	result := make([]*types.DeploymentRecord, len(history))
+	for i, d := range history {
+		result[i] = convertDeployment(d)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, result)
+}
+
+func (ar *applicationsRouter) deploymentLog(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	log, err := ar.NewUserBroker(r).GetDeploymentLog(vars["name"], vars["id"])
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, err = io.Copy(w, log)
+	return err
+}
+
+func (ar *applicationsRouter) rollback(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	log := httputils.NewServerLog(w, r)
+	d, err := ar.NewUserBroker(r).Rollback(vars["name"], vars["id"], log)
+	if err != nil {
+		log.SendError(err)
+	} else {
+		log.SendObject(convertDeployment(d))
+	}
+	return nil
+}
+
+func convertDeployment(d *broker.Deployment) *types.DeploymentRecord {
+	result := &types.DeploymentRecord{
+		ID:         d.ID,
+		Source:     d.Source,
+		Branch:     d.Branch,
+		Commit:     d.Commit,
+		Version:    d.Version,
+		Artifact:   d.Artifact,
+		RollbackOf: d.RollbackOf,
+		DeployedBy: d.DeployedBy,
+		StartedAt:  d.StartedAt,
+		State:      d.State,
+		Error:      d.Error,
+	}
+	if !d.FinishedAt.IsZero() {
+		finished := d.FinishedAt
+		result.FinishedAt = &finished
+	}
+	return result
+}
+
 func convertBranchJson(br *scm.Branch) *types.Branch {
 	return &types.Branch{
-		Id:        br.Id,
-		DisplayId: br.DisplayId,
-		Type:      br.Type,
+		Id:               br.Id,
+		DisplayId:        br.DisplayId,
+		Type:             br.Type,
+		LatestCommit:     br.LatestCommit,
+		LatestCommitTime: br.LatestCommitTime,
 	}
 }
 
@@ -481,55 +1051,168 @@ func convertBranchesJson(branches []*scm.Branch) []*types.Branch {
 }
 
 func (ar *applicationsRouter) download(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if _, _, err := httputils.ArchiveOptions(r); err != nil {
+		return err
+	}
+
 	tr, err := ar.NewUserBroker(r).Download(vars["name"])
 	if err != nil {
 		return err
 	}
 	defer tr.Close()
+	return httputils.WriteArchive(w, r, tr, vars["name"])
+}
 
-	w.Header().Set("Content-Type", "application/tar+gzip") // TODO: parse Accept header
-	w.WriteHeader(http.StatusOK)
+func (ar *applicationsRouter) upload(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	_, binary := r.Form["binary"]
 
-	zw := gzip.NewWriter(w)
-	if _, err = io.Copy(zw, tr); err == nil {
-		err = zw.Close()
+	br := ar.NewUserBroker(r)
+	content, done, err := ar.openContent(br, r, vars["name"])
+	if err != nil {
+		return err
 	}
-	return err
+	defer content.Close()
+
+	log := httputils.NewServerLog(w, r)
+	err = br.Upload(vars["name"], content, binary, log)
+	if err != nil {
+		log.SendError(err)
+	} else {
+		done()
+	}
+	return nil
 }
 
-func (ar *applicationsRouter) upload(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+func (ar *applicationsRouter) build(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := httputils.ParseForm(r); err != nil {
 		return err
 	}
 
-	_, binary := r.Form["binary"]
+	meta := broker.Artifact{
+		Filename:   r.Form.Get("filename"),
+		Commit:     r.Form.Get("commit"),
+		Version:    r.Form.Get("version"),
+		UploadedBy: httputils.UserFromContext(r.Context()).Name,
+	}
+	if sa := httputils.ServiceAccountFromContext(r.Context()); sa != nil {
+		meta.UploadedBy = sa.Name
+	}
 
-	err := ar.NewUserBroker(r).Upload(vars["name"], r.Body, binary, serverlog.New(w))
+	content, err := httputils.SpoolBody(r)
 	if err != nil {
-		serverlog.SendError(w, err)
+		return err
+	}
+	defer content.Close()
+
+	log := httputils.NewServerLog(w, r)
+	artifact, err := ar.NewUserBroker(r).DeployArtifact(vars["name"], content, &meta, log)
+	if err != nil {
+		log.SendError(err)
+	} else {
+		log.SendObject(convertArtifact(artifact))
 	}
 	return nil
 }
 
-func (ar *applicationsRouter) dump(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
-	tr, err := ar.NewUserBroker(r).Dump(vars["name"])
+func (ar *applicationsRouter) listArtifacts(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	artifacts, err := ar.NewUserBroker(r).GetArtifacts(vars["name"])
 	if err != nil {
 		return err
 	}
-	defer tr.Close()
+	result := make([]*types.Artifact, len(artifacts))
+	for i, a := range artifacts {
+		result[i] = convertArtifact(a)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, result)
+}
 
-	w.Header().Set("Content-Type", "application/tar+gzip")
-	w.WriteHeader(http.StatusOK)
+func (ar *applicationsRouter) redeployArtifact(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	log := httputils.NewServerLog(w, r)
+	artifact, err := ar.NewUserBroker(r).RedeployArtifact(vars["name"], vars["id"], log)
+	if err != nil {
+		log.SendError(err)
+	} else {
+		log.SendObject(convertArtifact(artifact))
+	}
+	return nil
+}
 
-	zw := gzip.NewWriter(w)
-	if _, err = io.Copy(zw, tr); err == nil {
-		err = zw.Close()
+func convertArtifact(a *broker.Artifact) *types.Artifact {
+	result := &types.Artifact{
+		ID:         a.ID,
+		Filename:   a.Filename,
+		Format:     a.Format,
+		Source:     a.Source,
+		Commit:     a.Commit,
+		Version:    a.Version,
+		Size:       a.Size,
+		Checksum:   a.Checksum,
+		UploadedBy: a.UploadedBy,
+		UploadedAt: a.UploadedAt,
+		Image:      a.Image,
+	}
+	if !a.DeployedAt.IsZero() {
+		deployed := a.DeployedAt
+		result.DeployedAt = &deployed
 	}
-	return err
+	return result
+}
+
+// dump dumps data of the application, or a single service if the service
+// is given by the route.
+func (ar *applicationsRouter) dump(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if _, _, err := httputils.ArchiveOptions(r); err != nil {
+		return err
+	}
+
+	var tr io.ReadCloser
+	var err error
+	basename := vars["name"] + "-data"
+	if service, ok := vars["service"]; ok {
+		tr, err = ar.NewUserBroker(r).DumpService(vars["name"], service)
+		if service == "_" {
+			service = "app"
+		}
+		basename = vars["name"] + "-" + service + "-data"
+	} else {
+		tr, err = ar.NewUserBroker(r).Dump(vars["name"])
+	}
+	if err != nil {
+		return err
+	}
+	defer tr.Close()
+	return httputils.WriteArchive(w, r, tr, basename)
 }
 
+// restore restores data of the application, or a single service if the
+// service is given by the route.
 func (ar *applicationsRouter) restore(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
-	return ar.NewUserBroker(r).Restore(vars["name"], r.Body)
+	br := ar.NewUserBroker(r)
+	if err := br.CheckProtection(vars["name"], confirmation(r)); err != nil {
+		return err
+	}
+	content, done, err := ar.openContent(br, r, vars["name"])
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	log := httputils.NewServerLog(w, r)
+	if service, ok := vars["service"]; ok {
+		err = br.RestoreService(vars["name"], service, content, log)
+	} else {
+		err = br.Restore(vars["name"], content, log)
+	}
+	if err != nil {
+		log.SendError(err)
+	} else {
+		done()
+	}
+	return nil
 }
 
 func (ar *applicationsRouter) scale(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
@@ -552,31 +1235,51 @@ func (ar *applicationsRouter) scale(w http.ResponseWriter, r *http.Request, vars
 		return nil
 	}
 
-	if up || down {
-		cs, err := ar.FindApplications(r.Context(), name, user.Namespace)
-		if err != nil {
+	cs, err := ar.FindApplications(r.Context(), name, user.Namespace)
+	if err != nil {
+		return err
+	}
+	if up {
+		num = len(cs) + num
+	} else if down {
+		num = len(cs) - num
+	}
+
+	br := ar.NewUserBroker(r)
+	if num < len(cs) {
+		if err = br.CheckProtection(name, confirmation(r)); err != nil {
 			return err
 		}
-		if up {
-			num = len(cs) + num
-		} else {
-			num = len(cs) - num
+	}
+
+	if isDryRun(r) {
+		plan, err := br.PlanScaleApplication(name, num)
+		if err != nil {
+			return err
 		}
+		return httputils.WriteJSON(w, http.StatusOK, plan)
 	}
 
-	br := ar.NewUserBroker(r)
-	cs, err := br.ScaleApplication(name, num)
+	cs, err = br.ScaleApplication(name, num)
 	if err != nil {
 		return err
 	}
 
-	err = br.StartContainers(cs, serverlog.New(w))
+	log := httputils.NewServerLog(w, r)
+	err = br.StartContainers(cs, log)
 	if err != nil {
-		serverlog.SendError(w, err)
+		log.SendError(err)
 	}
 	return nil
 }
 
+// isDryRun returns true if the request only validates the operation and
+// returns planned actions, as requested by the "dry-run" query parameter.
+func isDryRun(r *http.Request) bool {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry-run"))
+	return dryRun
+}
+
 func (ar *applicationsRouter) getContainers(ctx context.Context, namespace string, vars map[string]string) (cs []container.Container, err error) {
 	name, service := vars["name"], vars["service"]
 	if service == "" || service == "_" {
@@ -608,9 +1311,9 @@ func (ar *applicationsRouter) environ(w http.ResponseWriter, r *http.Request, va
 	}
 
 	ctx := r.Context()
-	user := httputils.UserFromContext(ctx)
+	namespace := ar.NewUserBroker(r).Namespace()
 
-	container, err := ar.getContainer(ctx, user.Namespace, vars)
+	container, err := ar.getContainer(ctx, namespace, vars)
 	if err != nil {
 		return err
 	}
@@ -628,9 +1331,9 @@ func (ar *applicationsRouter) environ(w http.ResponseWriter, r *http.Request, va
 
 func (ar *applicationsRouter) getenv(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	ctx := r.Context()
-	user := httputils.UserFromContext(ctx)
+	namespace := ar.NewUserBroker(r).Namespace()
 
-	container, err := ar.getContainer(ctx, user.Namespace, vars)
+	container, err := ar.getContainer(ctx, namespace, vars)
 	if err != nil {
 		return err
 	}
@@ -645,6 +1348,10 @@ func (ar *applicationsRouter) getenv(w http.ResponseWriter, r *http.Request, var
 
 var validEnvKey = regexp.MustCompile(`^[a-zA-Z_0-9]+$`)
 
+// setenvTimeout limits the time taken to update environment variables
+// in each container.
+const setenvTimeout = 30 * time.Second
+
 func (ar *applicationsRouter) setenv(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := httputils.ParseForm(r); err != nil {
 		return err
@@ -684,11 +1391,17 @@ func (ar *applicationsRouter) setenv(w http.ResponseWriter, r *http.Request, var
 		}
 	}
 
-	for _, container := range cs {
-		if err = container.ExecE(ctx, "root", nil, nil, args...); err != nil {
-			return err
-		}
+	opts := container.ExecOptions{User: "root", Timeout: setenvTimeout}
+	err = broker.Parallel(cs, func(c container.Container) error {
+		return c.ExecWithOptions(ctx, opts, args...)
+	})
+	if err != nil {
+		return err
 	}
 
+	// environment variables of the application are restored by reconciliation
+	if service := vars["service"]; service == "" || service == "_" {
+		return ar.NewUserBroker(r).SaveEnv(vars["name"], env, rm)
+	}
 	return nil
 }
@@ -0,0 +1,110 @@
+package applications
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/broker"
+)
+
+// permission describes the access required by collaborators, and the scope
+// required by service accounts, to use an application route.
+type permission struct {
+	access string
+	scope  string
+}
+
+var (
+	readAccess   = permission{userdb.AccessRead, userdb.ScopeRead}
+	deployAccess = permission{userdb.AccessDeploy, userdb.ScopeDeploy}
+	uploadAccess = permission{userdb.AccessDeploy, userdb.ScopeUpload}
+	ownerOnly    = permission{}
+
+	// service accounts can't lock deployments
+	lockAccess = permission{userdb.AccessDeploy, ""}
+)
+
+type sharedBrokerKey struct{}
+
+// shared wraps the handler of an application route. Collaborators address
+// shared applications with full names, the request is then performed on
+// behalf of the owner if the collaborator has the required access. Service
+// accounts must have the required scope on the application.
+func (ar *applicationsRouter) shared(perm permission, handler httputils.APIFunc) httputils.APIFunc {
+	return func(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		ctx := r.Context()
+		user := httputils.UserFromContext(ctx)
+		actor := user.Name
+		if sa := httputils.ServiceAccountFromContext(ctx); sa != nil {
+			if !serviceAccountAllows(sa, perm.scope, vars["name"]) {
+				return broker.AccessDeniedError(vars["name"])
+			}
+			actor = sa.Name
+		}
+
+		// the actor is recorded in the deployment history
+		ctx = broker.WithActor(ctx, actor)
+		if !broker.IsSharedName(vars["name"]) {
+			return handler(w, r.WithContext(ctx), vars)
+		}
+
+		br, name, err := ar.SharedApplicationBroker(ctx, user, vars["name"], perm.access)
+		if err != nil {
+			return err
+		}
+		vars["name"] = name
+		ctx = context.WithValue(ctx, sharedBrokerKey{}, br)
+		return handler(w, r.WithContext(ctx), vars)
+	}
+}
+
+// serviceAccountAllows checks the scope of the service account on the
+// application, which is addressed by the short name or the full name in the
+// namespace of the service account.
+func serviceAccountAllows(sa *userdb.ServiceAccount, scope, name string) bool {
+	if scope == "" {
+		return false
+	}
+	if suffix := "-" + sa.Namespace; strings.HasSuffix(name, suffix) {
+		name = strings.TrimSuffix(name, suffix)
+	} else if broker.IsSharedName(name) {
+		return false
+	}
+	return sa.Allows(scope, name)
+}
+
+func (ar *applicationsRouter) listCollaborators(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	cs, err := ar.NewUserBroker(r).GetCollaborators(vars["name"])
+	if err != nil {
+		return err
+	}
+	result := make([]types.Collaborator, len(cs))
+	for i, c := range cs {
+		result[i] = types.Collaborator{User: c.User, Access: c.Access}
+	}
+	return httputils.WriteJSON(w, http.StatusOK, result)
+}
+
+func (ar *applicationsRouter) addCollaborator(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	access := r.FormValue("access")
+	if access == "" {
+		access = userdb.AccessRead
+	}
+	if err := ar.NewUserBroker(r).AddCollaborator(vars["name"], vars["user"], access); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *applicationsRouter) removeCollaborator(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := ar.NewUserBroker(r).RemoveCollaborator(vars["name"], vars["user"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,40 @@
+package applications
+
+import (
+	"net/http"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
+)
+
+func (ar *applicationsRouter) diskUsage(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	br := ar.NewUserBroker(r)
+	name := vars["name"]
+
+	usage, err := br.DiskUsage(name)
+	if err != nil {
+		return err
+	}
+
+	cs, err := ar.FindAll(r.Context(), name, br.Namespace())
+	if err != nil {
+		return err
+	}
+
+	result := &types.DiskUsage{
+		Containers: make([]*types.ContainerDiskUsage, 0, len(usage)),
+		Quota:      ar.NamespaceDiskQuota(br.Namespace()),
+	}
+	for _, u := range usage {
+		cu := &types.ContainerDiskUsage{Size: u.Size, Data: u.Data}
+		for _, c := range cs {
+			if c.ID() == u.ID {
+				ar.initContainerJSON(c, &cu.ContainerJSONBase)
+				break
+			}
+		}
+		result.Containers = append(result.Containers, cu)
+		result.Total += u.Size
+	}
+	return httputils.WriteJSON(w, http.StatusOK, result)
+}
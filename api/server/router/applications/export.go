@@ -0,0 +1,57 @@
+package applications
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/cloudway/platform/api/server/httputils"
+)
+
+// export sends the application bundle, which is always a gzip'd tar
+// archive since it can only be imported in this format.
+func (ar *applicationsRouter) export(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	bundle, err := ar.NewUserBroker(r).ExportApplication(vars["name"])
+	if err != nil {
+		return err
+	}
+	defer bundle.Close()
+
+	filename := vars["name"] + "-export-" + time.Now().UTC().Format("20060102-150405") + ".tar.gz"
+	w.Header().Set("Content-Type", httputils.ArchiveTarGzip)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.WriteHeader(http.StatusOK)
+	_, err = io.Copy(w, bundle)
+	return err
+}
+
+// importApplication recreates an application from the bundle in the
+// request body. The application is named by the bundle, or the "name"
+// parameter if given.
+func (ar *applicationsRouter) importApplication(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	br := ar.NewUserBroker(r)
+	content, err := httputils.SpoolBody(r)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	log := httputils.NewServerLog(w, r)
+	name, app, err := br.ImportApplication(content, r.FormValue("name"), log)
+	if err != nil {
+		log.SendError(err)
+		return nil
+	}
+
+	if info, err := ar.getInfo(name, br.Namespace(), br.User.Basic().Domain, app); err != nil {
+		log.SendError(err)
+	} else {
+		log.SendObject(info)
+	}
+	return nil
+}
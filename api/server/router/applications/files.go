@@ -0,0 +1,83 @@
+package applications
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/pkg/archive"
+)
+
+func (ar *applicationsRouter) downloadFiles(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	br := ar.NewUserBroker(r)
+	path, filter := fileOptions(r)
+
+	if _, list := r.Form["list"]; list {
+		files, err := br.ListFiles(vars["name"], vars["service"], path)
+		if err != nil {
+			return err
+		}
+		result := make([]*types.FileInfo, len(files))
+		for i, f := range files {
+			result[i] = &types.FileInfo{Name: f.Name, Size: f.Size, ModTime: f.ModTime, IsDir: f.IsDir}
+		}
+		return httputils.WriteJSON(w, http.StatusOK, result)
+	}
+
+	tr, err := br.DownloadFiles(vars["name"], vars["service"], path, filter)
+	if err != nil {
+		return err
+	}
+	defer tr.Close()
+
+	w.Header().Set("Content-Type", "application/tar+gzip")
+	w.WriteHeader(http.StatusOK)
+
+	zw, err := gzip.NewWriterLevel(w, defaults.GzipLevel())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(zw, tr); err == nil {
+		err = zw.Close()
+	}
+	return err
+}
+
+func (ar *applicationsRouter) uploadFiles(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	var content io.Reader = r.Body
+	if r.Header.Get("Content-Type") == "application/tar+gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		content = zr
+	}
+
+	br := ar.NewUserBroker(r)
+	path, filter := fileOptions(r)
+	return br.UploadFiles(vars["name"], vars["service"], path, content, filter)
+}
+
+func fileOptions(r *http.Request) (string, *archive.Filter) {
+	path := r.Form.Get("path")
+	if path == "" {
+		path = "."
+	}
+	filter := &archive.Filter{
+		Includes: r.Form["include"],
+		Excludes: r.Form["exclude"],
+	}
+	return path, filter
+}
@@ -0,0 +1,36 @@
+package applications
+
+import (
+	"net/http"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
+)
+
+func (ar *applicationsRouter) listLinks(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	links, err := ar.NewUserBroker(r).GetLinks(vars["name"])
+	if err != nil {
+		return err
+	}
+	result := make([]types.ServiceLink, len(links))
+	for i, l := range links {
+		result[i] = types.ServiceLink{App: l.App, Service: l.Service}
+	}
+	return httputils.WriteJSON(w, http.StatusOK, result)
+}
+
+func (ar *applicationsRouter) linkService(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := ar.NewUserBroker(r).LinkService(vars["name"], vars["app"], vars["service"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *applicationsRouter) unlinkService(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := ar.NewUserBroker(r).UnlinkService(vars["name"], vars["app"], vars["service"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
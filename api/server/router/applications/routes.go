@@ -0,0 +1,84 @@
+package applications
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/proxy"
+)
+
+// routeCheckTimeout is the maximum time to wait for a backend connection
+// when checking route health.
+const routeCheckTimeout = 2 * time.Second
+
+func (ar *applicationsRouter) listRoutes(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	br := ar.NewUserBroker(r)
+	routes, err := ar.getRoutes(r.Context(), vars["name"], br.Namespace())
+	if err == proxy.ErrMisconfigured {
+		return httputils.NewStatusError(http.StatusServiceUnavailable)
+	}
+	if err != nil {
+		return err
+	}
+	if routes == nil {
+		routes = []*types.Route{}
+	}
+	return httputils.WriteJSON(w, http.StatusOK, routes)
+}
+
+// getRoutes returns the frontend routes registered at the proxy for all
+// containers of the application, and checks whether the backends are
+// currently reachable.
+func (ar *applicationsRouter) getRoutes(ctx context.Context, name, namespace string) ([]*types.Route, error) {
+	cs, err := ar.FindAll(ctx, name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if len(cs) == 0 {
+		return nil, broker.ApplicationNotFoundError(name)
+	}
+
+	px, err := proxy.New(config.Get("proxy.url"))
+	if err != nil {
+		return nil, err
+	}
+	defer px.Close()
+
+	var routes []*types.Route
+	for _, c := range cs {
+		mappings, err := px.Endpoints(c.ID())
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range mappings {
+			routes = append(routes, &types.Route{
+				Frontend:  m.Frontend,
+				Backend:   m.Backend,
+				Container: c.ID(),
+				Service:   c.ServiceName(),
+			})
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(routes))
+	for _, rt := range routes {
+		go func(rt *types.Route) {
+			defer wg.Done()
+			if err := proxy.CheckBackend(rt.Backend, routeCheckTimeout); err != nil {
+				rt.Error = err.Error()
+			} else {
+				rt.Healthy = true
+			}
+		}(rt)
+	}
+	wg.Wait()
+
+	return routes, nil
+}
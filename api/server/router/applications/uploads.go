@@ -0,0 +1,130 @@
+package applications
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config/defaults"
+)
+
+func (ar *applicationsRouter) createUpload(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var req types.CreateUpload
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	if limit := defaults.MaxUploadSize(); limit > 0 && req.Size > limit {
+		return httputils.RequestTooLargeError(limit)
+	}
+
+	upload, err := ar.NewUserBroker(r).CreateUpload(vars["name"], req.Size)
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusCreated, convertUpload(upload))
+}
+
+func (ar *applicationsRouter) getUpload(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	upload, err := ar.NewUserBroker(r).GetUpload(vars["name"], vars["id"])
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, convertUpload(upload))
+}
+
+// appendUpload appends a chunk to the upload. The chunk position is given
+// by the Content-Range header, e.g. "bytes 0-1048575/5368709120". If the
+// header is absent, the request body is appended at the current offset.
+func (ar *applicationsRouter) appendUpload(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	br := ar.NewUserBroker(r)
+	name, id := vars["name"], vars["id"]
+
+	var offset int64
+	if cr := r.Header.Get("Content-Range"); cr != "" {
+		start, end, err := parseContentRange(cr)
+		if err != nil {
+			return err
+		}
+		offset = start
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(r.Body, end-start+1), r.Body}
+	} else {
+		upload, err := br.GetUpload(name, id)
+		if err != nil {
+			return err
+		}
+		offset = upload.Offset
+	}
+
+	upload, err := br.AppendUpload(name, id, offset, r.Body)
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, convertUpload(upload))
+}
+
+func (ar *applicationsRouter) removeUpload(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := ar.NewUserBroker(r).RemoveUpload(vars["name"], vars["id"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// openContent returns the archive content of the request, which is either
+// a completed upload given by the "upload" parameter, or the request body.
+// The done function removes the upload after the content is consumed.
+func (ar *applicationsRouter) openContent(br *broker.UserBroker, r *http.Request, name string) (content io.ReadCloser, done func(), err error) {
+	if id := r.FormValue("upload"); id != "" {
+		content, err = br.OpenUpload(name, id)
+		done = func() { br.RemoveUpload(name, id) }
+	} else {
+		content, err = httputils.SpoolBody(r)
+		done = func() {}
+	}
+	return
+}
+
+// parseContentRange parses the Content-Range header of the form
+// "bytes start-end/total", the total may be "*".
+func parseContentRange(s string) (start, end int64, err error) {
+	bad := fmt.Errorf("Bad parameter: invalid Content-Range header '%s'", s)
+	if !strings.HasPrefix(s, "bytes ") {
+		return 0, 0, bad
+	}
+	s = strings.TrimSpace(strings.TrimPrefix(s, "bytes "))
+	if i := strings.IndexByte(s, '/'); i != -1 {
+		s = s[:i]
+	}
+	i := strings.IndexByte(s, '-')
+	if i == -1 {
+		return 0, 0, bad
+	}
+	if start, err = strconv.ParseInt(s[:i], 10, 64); err != nil {
+		return 0, 0, bad
+	}
+	if end, err = strconv.ParseInt(s[i+1:], 10, 64); err != nil {
+		return 0, 0, bad
+	}
+	if start < 0 || end < start {
+		return 0, 0, bad
+	}
+	return start, end, nil
+}
+
+func convertUpload(u *broker.Upload) *types.Upload {
+	return &types.Upload{
+		ID:        u.ID,
+		Size:      u.Size,
+		Offset:    u.Offset,
+		UpdatedAt: u.UpdatedAt,
+	}
+}
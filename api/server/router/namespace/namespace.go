@@ -19,6 +19,7 @@ func NewRouter(broker *broker.Broker) router.Router {
 	r.routes = []router.Route{
 		router.NewGetRoute("/namespace", r.get),
 		router.NewPostRoute("/namespace", r.set),
+		router.NewPutRoute("/namespace", r.rename),
 		router.NewDeleteRoute("/namespace", r.delete),
 	}
 
@@ -42,6 +43,7 @@ func (nr *namespaceRouter) get(w http.ResponseWriter, r *http.Request, vars map[
 	}
 	return httputils.WriteJSON(w, http.StatusOK, map[string]interface{}{
 		"Namespace": br.Namespace(),
+		"Domain":    br.User.Basic().Domain,
 	})
 }
 
@@ -52,6 +54,13 @@ func (nr *namespaceRouter) set(w http.ResponseWriter, r *http.Request, vars map[
 	return nr.NewUserBroker(r).CreateNamespace(r.FormValue("namespace"))
 }
 
+func (nr *namespaceRouter) rename(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+	return nr.NewUserBroker(r).RenameNamespace(r.FormValue("namespace"))
+}
+
 func (nr *namespaceRouter) delete(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := httputils.ParseForm(r); err != nil {
 		return err
@@ -5,6 +5,7 @@ import (
 
 	"github.com/cloudway/platform/api/server/httputils"
 	"github.com/cloudway/platform/api/server/router"
+	"github.com/cloudway/platform/api/types"
 	"github.com/cloudway/platform/broker"
 	"github.com/cloudway/platform/pkg/manifest"
 )
@@ -68,7 +69,7 @@ func (pr *pluginsRouter) info(w http.ResponseWriter, r *http.Request, vars map[s
 }
 
 func (pr *pluginsRouter) create(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
-	return pr.NewUserBroker(r).InstallPlugin(r.Body)
+	return pr.NewUserBroker(r).InstallPlugin(r.Body, r.Header.Get(types.SignatureHeader))
 }
 
 func (pr *pluginsRouter) remove(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
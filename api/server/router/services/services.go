@@ -0,0 +1,126 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/server/router"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+const servicePath = "/services/{name:[^/]+}"
+
+type servicesRouter struct {
+	*broker.Broker
+	routes []router.Route
+}
+
+func NewRouter(broker *broker.Broker) router.Router {
+	r := &servicesRouter{Broker: broker}
+
+	r.routes = []router.Route{
+		router.NewGetRoute("/services/", r.list),
+		router.NewPostRoute("/services/", r.create),
+		router.NewGetRoute(servicePath, r.info),
+		router.NewDeleteRoute(servicePath, r.delete),
+		router.NewPostRoute(servicePath+"/start", r.start),
+		router.NewPostRoute(servicePath+"/stop", r.stop),
+		router.NewPostRoute(servicePath+"/restart", r.restart),
+	}
+
+	return r
+}
+
+func (sr *servicesRouter) Routes() []router.Route {
+	return sr.routes
+}
+
+func (sr *servicesRouter) NewUserBroker(r *http.Request) *broker.UserBroker {
+	ctx := r.Context()
+	user := httputils.UserFromContext(ctx)
+	return sr.Broker.NewUserBroker(user, ctx)
+}
+
+func (sr *servicesRouter) list(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	names, err := sr.NewUserBroker(r).GetStandaloneServices()
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, names)
+}
+
+func (sr *servicesRouter) create(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	var req types.CreateStandaloneService
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	if err := req.Validate().Err(); err != nil {
+		return err
+	}
+
+	log := httputils.NewServerLog(w, r)
+	if _, err := sr.NewUserBroker(r).CreateStandaloneService(req.Name, req.Plugin, log); err != nil {
+		log.SendError(err)
+	}
+	return nil
+}
+
+func (sr *servicesRouter) info(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	br := sr.NewUserBroker(r)
+	svc, cs, err := br.GetStandaloneService(vars["name"])
+	if err != nil {
+		return err
+	}
+
+	info := &types.StandaloneService{
+		Name:      vars["name"],
+		Namespace: br.Namespace(),
+		CreatedAt: svc.CreatedAt,
+		State:     manifest.StateStopped.String(),
+	}
+	if len(cs) != 0 {
+		info.State = cs[0].ActiveState(r.Context()).String()
+		info.ServiceName = cs[0].ServiceName()
+	}
+	if p, err := sr.Hub.GetPluginInfo(svc.Plugin); err == nil {
+		info.Plugin = p
+	} else {
+		info.Plugin = &manifest.Plugin{Tag: svc.Plugin}
+	}
+	return httputils.WriteJSON(w, http.StatusOK, info)
+}
+
+func (sr *servicesRouter) delete(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := sr.NewUserBroker(r).RemoveStandaloneService(vars["name"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (sr *servicesRouter) start(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	log := httputils.NewServerLog(w, r)
+	if err := sr.NewUserBroker(r).StartStandaloneService(vars["name"], log); err != nil {
+		log.SendError(err)
+	}
+	return nil
+}
+
+func (sr *servicesRouter) stop(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	return sr.NewUserBroker(r).StopStandaloneService(vars["name"])
+}
+
+func (sr *servicesRouter) restart(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	log := httputils.NewServerLog(w, r)
+	if err := sr.NewUserBroker(r).RestartStandaloneService(vars["name"], log); err != nil {
+		log.SendError(err)
+	}
+	return nil
+}
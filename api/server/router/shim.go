@@ -0,0 +1,159 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/cloudway/platform/api"
+	"github.com/cloudway/platform/api/server/httputils"
+)
+
+// JSONShim transforms a JSON document decoded into generic values, such
+// as map[string]interface{} for objects, and returns the new document.
+type JSONShim func(doc interface{}) (interface{}, error)
+
+// VersionShim adapts requests and responses of a route for clients using
+// an API version before the given version, so that the handler only deals
+// with the current request and response shapes. The request shim converts
+// the request body from the old shape to the current shape, and the
+// response shim converts the response body back to the old shape.
+type VersionShim struct {
+	Before   string
+	Request  JSONShim
+	Response JSONShim
+}
+
+// Shim makes a new route that applies the version shims to requests with
+// an API version in the request path. Request shims are applied from the
+// oldest version, and response shims are applied from the newest version.
+// Only JSON request and response bodies are shimmed, error responses are
+// passed through unchanged.
+func Shim(r Route, shims ...VersionShim) Route {
+	sorted := make([]VersionShim, len(shims))
+	copy(sorted, shims)
+	sort.Sort(shimsByVersion(sorted))
+
+	handler := r.Handler()
+	return localRoute{
+		method: r.Method(),
+		path:   r.Path(),
+		handler: func(w http.ResponseWriter, req *http.Request, vars map[string]string) error {
+			// requests without version always use the current shapes
+			version := vars["version"]
+			var active []VersionShim
+			for _, s := range sorted {
+				if version != "" && api.CompareVersions(version, s.Before) < 0 {
+					active = append(active, s)
+				}
+			}
+			if len(active) == 0 {
+				return handler(w, req, vars)
+			}
+			return serveShimmed(w, req, vars, handler, active)
+		},
+	}
+}
+
+type shimsByVersion []VersionShim
+
+func (a shimsByVersion) Len() int      { return len(a) }
+func (a shimsByVersion) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a shimsByVersion) Less(i, j int) bool {
+	return api.CompareVersions(a[i].Before, a[j].Before) < 0
+}
+
+func serveShimmed(w http.ResponseWriter, r *http.Request, vars map[string]string, handler httputils.APIFunc, shims []VersionShim) error {
+	if isJSON(r.Header) && r.Body != nil {
+		body, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(body)) != 0 {
+			for _, s := range shims {
+				if s.Request != nil {
+					if body, err = shimJSON(body, s.Request); err != nil {
+						return badRequestError{err}
+					}
+				}
+			}
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+		r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+
+	sw := &shimWriter{ResponseWriter: w}
+	if err := handler(sw, r, vars); err != nil {
+		return err
+	}
+	if sw.code == 0 {
+		sw.code = http.StatusOK
+	}
+
+	body := sw.buf.Bytes()
+	if sw.code/100 == 2 && len(body) != 0 && isJSON(w.Header()) {
+		var err error
+		for i := len(shims) - 1; i >= 0; i-- {
+			if shims[i].Response != nil {
+				if body, err = shimJSON(body, shims[i].Response); err != nil {
+					return err
+				}
+			}
+		}
+		w.Header().Del("Content-Length")
+	}
+	w.WriteHeader(sw.code)
+	_, err := w.Write(body)
+	return err
+}
+
+func isJSON(h http.Header) bool {
+	ct := h.Get("Content-Type")
+	return ct != "" && httputils.MatchesContentType(ct, "application/json")
+}
+
+func shimJSON(data []byte, shim JSONShim) ([]byte, error) {
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	doc, err := shim(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// shimWriter buffers the response so that the body can be shimmed after
+// the handler completed.
+type shimWriter struct {
+	http.ResponseWriter
+	code int
+	buf  bytes.Buffer
+}
+
+func (w *shimWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *shimWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.buf.Write(p)
+}
+
+type badRequestError struct {
+	error
+}
+
+func (badRequestError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
@@ -0,0 +1,242 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/broker"
+)
+
+// Events are written as JSON lines. Clients follow the event stream until
+// disconnected, or poll for new events with the ID of the last received
+// event. A poll returns as soon as any event is available, or an empty
+// response after the timeout.
+
+const (
+	defaultPollTimeout = 30 * time.Second
+	maxPollTimeout     = 5 * time.Minute
+)
+
+type badRequestError struct {
+	error
+}
+
+func (badRequestError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// eventFilters selects events by type, action, application, namespace and
+// service. Events match if any value of each given key matches.
+type eventFilters map[string][]string
+
+func (f eventFilters) match(key, value string) bool {
+	values, ok := f[key]
+	if !ok {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (f eventFilters) matchEvent(e *types.Event) bool {
+	return f.match("type", e.Type) &&
+		f.match("action", e.Action) &&
+		f.match("application", e.Application) &&
+		f.match("namespace", e.Namespace) &&
+		f.match("service", e.Service)
+}
+
+func parseEventFilters(s string) (eventFilters, error) {
+	filters := make(eventFilters)
+	if s == "" {
+		return filters, nil
+	}
+	if err := json.Unmarshal([]byte(s), &filters); err != nil {
+		return nil, fmt.Errorf("Invalid filters: %v", err)
+	}
+	for key := range filters {
+		switch key {
+		case "type", "action", "application", "namespace", "service":
+		default:
+			return nil, fmt.Errorf("Invalid filter: %s", key)
+		}
+	}
+	return filters, nil
+}
+
+// eventAccess checks whether the user can see events of applications. Users
+// see events of their own applications and applications shared with them,
+// and administrators see all events.
+type eventAccess struct {
+	*broker.Broker
+	user   *userdb.BasicUser
+	admin  bool
+	shared map[string]bool
+}
+
+func (a *eventAccess) allowed(name, namespace string) bool {
+	if a.admin || (a.user.Namespace != "" && namespace == a.user.Namespace) {
+		return true
+	}
+
+	fullname := name + "-" + namespace
+	if ok, cached := a.shared[fullname]; cached {
+		return ok
+	}
+	ok := false
+	if owner, err := a.Users.FindByNamespace(namespace); err == nil {
+		if app := owner.Basic().Applications[name]; app != nil {
+			for _, c := range app.Collaborators {
+				if c.User == a.user.Name {
+					ok = true
+					break
+				}
+			}
+		}
+	}
+	a.shared[fullname] = ok
+	return ok
+}
+
+func convertEvent(e *broker.Event) *types.Event {
+	event := &types.Event{
+		ID:          e.ID,
+		Type:        "application",
+		Action:      string(e.Type),
+		Application: e.Name,
+		Namespace:   e.Namespace,
+		Attributes:  e.Attributes,
+		Time:        e.Time,
+	}
+	if c := e.Container; c != nil {
+		event.Type = "container"
+		event.Service = c.ServiceName()
+		event.Container = c.ID()
+	}
+	return event
+}
+
+func (s *systemRouter) getEvents(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	filters, err := parseEventFilters(r.FormValue("filters"))
+	if err != nil {
+		return badRequestError{err}
+	}
+	follow := r.FormValue("follow") == "1" || r.FormValue("follow") == "true"
+
+	var since uint64
+	replay := r.FormValue("since") != ""
+	if replay {
+		if since, err = strconv.ParseUint(r.FormValue("since"), 10, 64); err != nil {
+			return badRequestError{fmt.Errorf("Invalid event ID: %s", r.FormValue("since"))}
+		}
+	}
+
+	timeout := defaultPollTimeout
+	if v := r.FormValue("timeout"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs < 0 {
+			return badRequestError{fmt.Errorf("Invalid timeout: %s", v)}
+		}
+		timeout = time.Duration(secs) * time.Second
+		if timeout > maxPollTimeout {
+			timeout = maxPollTimeout
+		}
+	}
+
+	user := httputils.UserFromContext(r.Context())
+	access := &eventAccess{
+		Broker: s.Broker,
+		user:   user,
+		admin:  s.IsAdmin(user),
+		shared: make(map[string]bool),
+	}
+
+	// subscribe before reading recent events so no event is missed
+	events := s.Events.Subscribe()
+	defer s.Events.Unsubscribe(events)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	enc := json.NewEncoder(w)
+	last, sent := since, 0
+	send := func(e *broker.Event) error {
+		if e.ID <= last {
+			return nil
+		}
+		last = e.ID
+		event := convertEvent(e)
+		if !filters.matchEvent(event) || !access.allowed(e.Name, e.Namespace) {
+			return nil
+		}
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		sent++
+		return nil
+	}
+
+	if replay {
+		for _, e := range s.Events.Since(since) {
+			if err := send(&e); err != nil {
+				return nil
+			}
+		}
+	}
+
+	var expired <-chan time.Time
+	if !follow {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		if !follow && sent != 0 {
+			return nil
+		}
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := send(&e); err != nil {
+				return nil
+			}
+			// return all pending events to the polling client at once
+			for !follow && len(events) != 0 {
+				e = <-events
+				if err := send(&e); err != nil {
+					return nil
+				}
+			}
+		case <-expired:
+			return nil
+		case <-r.Context().Done():
+			return nil
+		}
+	}
+}
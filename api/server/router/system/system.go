@@ -3,6 +3,7 @@ package system
 import (
 	"net/http"
 	osruntime "runtime"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 
@@ -10,21 +11,27 @@ import (
 	"github.com/cloudway/platform/api/server/httputils"
 	"github.com/cloudway/platform/api/server/router"
 	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/auth/userdb"
 	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/pkg/ratelimit"
 )
 
 type systemRouter struct {
 	*broker.Broker
-	routes []router.Route
+	routes  []router.Route
+	limiter *ratelimit.Limiter
 }
 
 func NewRouter(broker *broker.Broker) router.Router {
-	r := &systemRouter{Broker: broker}
+	r := &systemRouter{Broker: broker, limiter: ratelimit.New(time.Minute)}
 
 	r.routes = []router.Route{
 		router.NewGetRoute("/version", r.getVersion),
 		router.NewGetRoute("/swagger.json", r.getSwaggerJson),
 		router.NewPostRoute("/auth", r.postAuth),
+		router.NewGetRoute("/notices", r.getNotices),
+		router.Cancellable(router.NewGetRoute("/events", r.getEvents)),
 	}
 
 	return r
@@ -59,7 +66,16 @@ func (s *systemRouter) postAuth(w http.ResponseWriter, r *http.Request, vars map
 		return nil
 	}
 
+	if !s.limiter.Allow(ratelimit.ClientIP(r, defaults.TrustedProxies()), defaults.LoginRateLimit()) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "Too many login attempts, please try again later", http.StatusTooManyRequests)
+		return nil
+	}
+
 	_, token, err := s.Authz.Authenticate(username, password)
+	if userdb.IsAccountLocked(err) {
+		return err
+	}
 	if err != nil {
 		logrus.WithField("username", username).WithError(err).Debug("Login failed")
 		http.Error(w, "Login failed", http.StatusUnauthorized)
@@ -70,3 +86,24 @@ func (s *systemRouter) postAuth(w http.ResponseWriter, r *http.Request, vars map
 		"Token": token,
 	})
 }
+
+// getNotices returns notices that should be shown to users now.
+func (s *systemRouter) getNotices(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	notices := s.Notices()
+	result := make([]*types.Notice, len(notices))
+	for i, n := range notices {
+		result[i] = &types.Notice{
+			ID:        n.ID,
+			Kind:      n.Kind,
+			Message:   n.Message,
+			URL:       n.URL,
+			Starts:    n.Starts,
+			CreatedAt: n.CreatedAt,
+		}
+		if !n.Expires.IsZero() {
+			expires := n.Expires
+			result[i].Expires = &expires
+		}
+	}
+	return httputils.WriteJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,287 @@
+package user
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/server/router"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/broker"
+)
+
+type userRouter struct {
+	*broker.Broker
+	routes []router.Route
+}
+
+func NewRouter(broker *broker.Broker) router.Router {
+	r := &userRouter{Broker: broker}
+
+	r.routes = []router.Route{
+		router.NewGetRoute("/user", r.info),
+		router.NewDeleteRoute("/user", r.deleteAccount),
+		router.NewPostRoute("/user/restore", r.restoreAccount),
+		router.NewPutRoute("/user/email", r.changeEmail),
+		router.NewPostRoute("/user/email/confirm", r.confirmEmail),
+		router.NewGetRoute("/user/preferences", r.getPreferences),
+		router.NewPutRoute("/user/preferences", r.setPreferences),
+		router.NewGetRoute("/user/keys", r.listKeys),
+		router.NewPostRoute("/user/keys", r.addKey),
+		router.NewDeleteRoute("/user/keys/{fingerprint:.*}", r.removeKey),
+		router.NewGetRoute("/user/tokens", r.listTokens),
+		router.NewPostRoute("/user/tokens", r.createToken),
+		router.NewDeleteRoute("/user/tokens/{id}", r.removeToken),
+		router.NewGetRoute("/user/registries", r.listRegistries),
+		router.NewPostRoute("/user/registries", r.addRegistry),
+		router.NewDeleteRoute("/user/registries/{server}", r.removeRegistry),
+	}
+
+	return r
+}
+
+func (ur *userRouter) Routes() []router.Route {
+	return ur.routes
+}
+
+func (ur *userRouter) NewUserBroker(r *http.Request) *broker.UserBroker {
+	ctx := r.Context()
+	user := httputils.UserFromContext(ctx)
+	return ur.Broker.NewUserBroker(user, ctx)
+}
+
+func (ur *userRouter) info(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	br := ur.NewUserBroker(r)
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
+	user := br.User.Basic()
+	info := types.UserInfo{
+		Name:         user.Name,
+		Namespace:    user.Namespace,
+		PendingEmail: user.PendingEmail,
+	}
+	if !user.DeleteAt.IsZero() {
+		info.DeleteAt = &user.DeleteAt
+	}
+	return httputils.WriteJSON(w, http.StatusOK, &info)
+}
+
+// deleteAccount schedules deletion of the account, which must be confirmed
+// with the user name by the confirmation header.
+func (ur *userRouter) deleteAccount(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	at, err := ur.NewUserBroker(r).DeleteAccount(r.Header.Get(types.ConfirmHeader))
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusAccepted, &types.AccountDeletion{DeleteAt: at})
+}
+
+func (ur *userRouter) restoreAccount(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := ur.NewUserBroker(r).RestoreAccount(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// changeEmail sends a confirmation link to the new email address.
+func (ur *userRouter) changeEmail(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	var req types.ChangeEmail
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	if err := ur.NewUserBroker(r).ChangeEmail(req.Email); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+// confirmEmail changes the email address with the confirmation token. The
+// user must login again with the new email address.
+func (ur *userRouter) confirmEmail(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	var req types.ConfirmEmail
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	if _, err := ur.ConfirmEmail(req.Token); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ur *userRouter) getPreferences(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	prefs, err := ur.NewUserBroker(r).GetPreferences()
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, (*types.UserPreferences)(prefs))
+}
+
+// setPreferences replaces preferences of the user, omitted preferences are
+// reset to defaults.
+func (ur *userRouter) setPreferences(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	var req types.UserPreferences
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	prefs := userdb.Preferences(req)
+	if err := ur.NewUserBroker(r).SetPreferences(&prefs); err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, &req)
+}
+
+func (ur *userRouter) listKeys(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	keys, err := ur.NewUserBroker(r).ListSSHKeys()
+	if err != nil {
+		return err
+	}
+
+	result := make([]*types.SSHKey, len(keys))
+	for i, key := range keys {
+		result[i] = convertSSHKey(key)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, result)
+}
+
+func (ur *userRouter) addKey(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	var req types.CreateSSHKey
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	key, err := ur.NewUserBroker(r).AddSSHKey(req.Label, req.Key)
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusCreated, convertSSHKey(key))
+}
+
+func (ur *userRouter) removeKey(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	return ur.NewUserBroker(r).RemoveSSHKey(vars["fingerprint"])
+}
+
+func (ur *userRouter) listTokens(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	tokens, err := ur.NewUserBroker(r).ListAccessTokens()
+	if err != nil {
+		return err
+	}
+
+	result := make([]*types.AccessToken, len(tokens))
+	for i, t := range tokens {
+		result[i] = convertAccessToken(t)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, result)
+}
+
+// createToken creates a personal access token, the token is only returned
+// in the response.
+func (ur *userRouter) createToken(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	var req types.CreateAccessToken
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	token, t, err := ur.NewUserBroker(r).CreateAccessToken(req.Label, req.Scopes)
+	if err != nil {
+		return err
+	}
+	result := convertAccessToken(t)
+	result.Token = token
+	return httputils.WriteJSON(w, http.StatusCreated, result)
+}
+
+func (ur *userRouter) removeToken(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	return ur.NewUserBroker(r).RemoveAccessToken(vars["id"])
+}
+
+func convertAccessToken(t *userdb.AccessToken) *types.AccessToken {
+	result := &types.AccessToken{
+		ID:        t.ID,
+		Label:     t.Label,
+		Scopes:    t.Scopes,
+		CreatedAt: t.CreatedAt,
+	}
+	if !t.LastUsed.IsZero() {
+		result.LastUsed = &t.LastUsed
+	}
+	return result
+}
+
+func (ur *userRouter) listRegistries(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	registries, err := ur.NewUserBroker(r).ListRegistries()
+	if err != nil {
+		return err
+	}
+
+	result := make([]*types.Registry, len(registries))
+	for i, reg := range registries {
+		result[i] = convertRegistry(reg)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, result)
+}
+
+func (ur *userRouter) addRegistry(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	var req types.CreateRegistry
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	reg, err := ur.NewUserBroker(r).AddRegistry(req.Server, req.Username, req.Password)
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusCreated, convertRegistry(reg))
+}
+
+func (ur *userRouter) removeRegistry(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	return ur.NewUserBroker(r).RemoveRegistry(vars["server"])
+}
+
+// convertRegistry converts registry credentials without the password.
+func convertRegistry(reg *userdb.Registry) *types.Registry {
+	return &types.Registry{
+		Server:    reg.Server,
+		Username:  reg.Username,
+		CreatedAt: reg.CreatedAt,
+	}
+}
+
+func convertSSHKey(key *userdb.SSHKey) *types.SSHKey {
+	return &types.SSHKey{
+		Label:       key.Label,
+		Key:         key.Text,
+		Fingerprint: key.Fingerprint,
+		CreatedAt:   key.CreatedAt,
+		LastUsed:    key.LastUsed,
+	}
+}
@@ -87,7 +87,7 @@ var _ = BeforeSuite(func() {
 })
 
 var _ = AfterSuite(func() {
-	broker.RemoveUser(TEST_USER)
+	broker.RemoveUser(TEST_USER, true)
 	os.RemoveAll(REPO_ROOT)
 
 	// Close server and wait for serve API to complete
@@ -137,7 +137,7 @@ func (cli *TestClient) NewUserBroker() *br.UserBroker {
 
 func (cli *TestClient) Close() {
 	if cli != nil && cli.user != nil {
-		ExpectWithOffset(1, broker.RemoveUser(TEST_USER)).To(Succeed())
+		ExpectWithOffset(1, broker.RemoveUser(TEST_USER, true)).To(Succeed())
 	}
 }
 
@@ -0,0 +1,49 @@
+package api_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/api/types"
+)
+
+var _ = Describe("Create application", func() {
+	var cli *TestClient
+	var ctx = context.Background()
+
+	BeforeEach(func() {
+		cli = NewTestClientWithNamespace(true)
+	})
+
+	AfterEach(func() {
+		cli.Close()
+	})
+
+	It("should report all invalid fields", func() {
+		opts := types.CreateApplication{
+			Name:      "Invalid-Name",
+			Framework: "nonexist",
+			Services:  []string{"mock"},
+		}
+		_, err := cli.CreateApplication(ctx, opts, nil, nil)
+		Ω(err).Should(BeAssignableToTypeOf(&types.ValidationError{}))
+
+		verr := err.(*types.ValidationError)
+		Ω(verr.Field("name")).ShouldNot(BeEmpty())
+		Ω(verr.Field("framework")).ShouldNot(BeEmpty())
+		Ω(verr.Field("services")).ShouldNot(BeEmpty())
+		Ω(verr.Field("repo")).Should(BeEmpty())
+	})
+
+	It("should reject existing application", func() {
+		opts := types.CreateApplication{Name: "test", Framework: "mock"}
+		_, err := cli.CreateApplication(ctx, opts, nil, nil)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		_, err = cli.CreateApplication(ctx, opts, nil, nil)
+		Ω(err).Should(BeAssignableToTypeOf(&types.ValidationError{}))
+		Ω(err.(*types.ValidationError).Field("name")).ShouldNot(BeEmpty())
+	})
+})
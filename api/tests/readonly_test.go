@@ -0,0 +1,74 @@
+package api_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/server/middleware"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
+)
+
+var _ = Describe("ReadOnly", func() {
+	var (
+		called  bool
+		handler httputils.APIFunc
+		rootDir string
+		tempDir string
+	)
+
+	var request = func(method, path string) error {
+		req, _ := http.NewRequest(method, path, nil)
+		return handler(httptest.NewRecorder(), req, map[string]string{})
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "readonly")
+		Ω(err).ShouldNot(HaveOccurred())
+		rootDir, config.RootDir = config.RootDir, tempDir
+
+		called = false
+		m := middleware.NewReadOnlyMiddleware("/api")
+		handler = m.WrapHandler(func(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+			called = true
+			return nil
+		})
+		Ω(br.SetReadOnly("backup in progress")).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Ω(br.ClearReadOnly()).Should(Succeed())
+		config.RootDir = rootDir
+		os.RemoveAll(tempDir)
+	})
+
+	It("should reject mutating requests", func() {
+		err := request("POST", "/api/applications/")
+		Ω(err).Should(Equal(br.ReadOnlyError("backup in progress")))
+		Ω(httputils.GetHTTPErrorStatusCode(err)).Should(Equal(http.StatusServiceUnavailable))
+		Ω(called).Should(BeFalse())
+	})
+
+	It("should allow read requests", func() {
+		Ω(request("GET", "/api/applications/")).Should(Succeed())
+		Ω(called).Should(BeTrue())
+	})
+
+	It("should allow authentication", func() {
+		Ω(request("POST", "/api/v1.0/auth")).Should(Succeed())
+		Ω(called).Should(BeTrue())
+	})
+
+	It("should allow mutating requests after read-only mode turned off", func() {
+		Ω(br.ClearReadOnly()).Should(Succeed())
+		Ω(request("POST", "/api/applications/")).Should(Succeed())
+		Ω(called).Should(BeTrue())
+	})
+})
@@ -2,9 +2,11 @@ package api_test
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"runtime"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -12,6 +14,8 @@ import (
 	"github.com/cloudway/platform/api"
 	"github.com/cloudway/platform/api/server/httputils"
 	"github.com/cloudway/platform/api/server/middleware"
+	"github.com/cloudway/platform/api/server/router"
+	"github.com/cloudway/platform/api/types"
 )
 
 var _ = Describe("Version", func() {
@@ -84,6 +88,49 @@ var _ = Describe("Version", func() {
 
 			Ω(h(resp, req, vars)).Should(Succeed())
 			Ω(versionFromContext).Should(Equal(api.Version))
+			Ω(resp.Header().Get(types.APIVersionHeader)).Should(Equal(api.Version))
+		})
+	})
+
+	Context("Shim", func() {
+		// the current shape renames "Name" to "Title"
+		var route = router.Shim(router.NewPostRoute("/test", func(w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+			var req struct{ Title string }
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				return err
+			}
+			return httputils.WriteJSON(w, http.StatusOK, req)
+		}), router.VersionShim{
+			Before: "1.1",
+			Request: func(doc interface{}) (interface{}, error) {
+				m := doc.(map[string]interface{})
+				m["Title"] = m["Name"]
+				return m, nil
+			},
+			Response: func(doc interface{}) (interface{}, error) {
+				m := doc.(map[string]interface{})
+				m["Name"] = m["Title"]
+				delete(m, "Title")
+				return m, nil
+			},
+		})
+
+		serve := func(version, body string) string {
+			req, _ := http.NewRequest("POST", "/test", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			Ω(route.Handler()(resp, req, map[string]string{"version": version})).Should(Succeed())
+			Ω(resp.Code).Should(Equal(http.StatusOK))
+			return strings.TrimSpace(resp.Body.String())
+		}
+
+		It("should shim requests and responses of older versions", func() {
+			Ω(serve("1.0", `{"Name":"test"}`)).Should(MatchJSON(`{"Name":"test"}`))
+		})
+
+		It("should not shim requests of current version", func() {
+			Ω(serve("1.1", `{"Title":"test"}`)).Should(MatchJSON(`{"Title":"test"}`))
+			Ω(serve("", `{"Title":"test"}`)).Should(MatchJSON(`{"Title":"test"}`))
 		})
 	})
 })
@@ -6,6 +6,23 @@ import (
 	"github.com/cloudway/platform/pkg/manifest"
 )
 
+// ConfirmHeader is the request header that confirms destructive
+// operations on protected applications. The value must be the
+// application name.
+const ConfirmHeader = "X-Cloudway-Confirm"
+
+// APIVersionHeader is the response header that carries the API version
+// of the server, which clients use to negotiate the API version.
+const APIVersionHeader = "Api-Version"
+
+// NoticeHeader is the response header that carries active platform
+// notices, one header per notice in the form "kind: message".
+const NoticeHeader = "X-Platform-Notice"
+
+// SignatureHeader is the request header that carries the base64 encoded
+// signature of the plugin tarball being installed.
+const SignatureHeader = "X-Plugin-Signature"
+
 // Version information contains response of remote API:
 // GET "/version"
 type Version struct {
@@ -17,19 +34,303 @@ type Version struct {
 	Arch          string
 }
 
+// Readiness contains response of the readiness endpoint:
+// GET "/readyz"
+//
+// The platform is ready if all dependencies are ready.
+type Readiness struct {
+	Ready        bool
+	Dependencies []DependencyStatus
+}
+
+// DependencyStatus is the status of an external service the platform
+// depends on. Status is "ok" or "unavailable", and Latency is the time
+// in milliseconds the check took.
+type DependencyStatus struct {
+	Name    string
+	Status  string
+	Latency int64
+}
+
+// Event contains a platform event streamed by remote API:
+// GET "/events"
+//
+// Type is "container" or "application", and Action is the lifecycle event
+// such as "start", "stop", "create" or "deploy". Events are numbered in
+// the order they are published by the server.
+type Event struct {
+	ID          uint64
+	Type        string
+	Action      string
+	Application string
+	Namespace   string
+	Service     string            `json:",omitempty"`
+	Container   string            `json:",omitempty"`
+	Attributes  map[string]string `json:",omitempty"`
+	Time        time.Time
+}
+
+// DistManifest describes the command line tool binaries available for
+// download from the console:
+// GET "/dist/manifest.json"
+type DistManifest struct {
+	Version  string
+	Binaries []DistBinary
+}
+
+// DistBinary is the command line tool binary for a platform. The SHA256
+// checksum is hex encoded.
+type DistBinary struct {
+	OS     string
+	Arch   string
+	URL    string
+	Size   int64
+	SHA256 string
+}
+
 // ApplicationInfo contains response of remote API:
 // GET "/applications/{name}"
 type ApplicationInfo struct {
-	Name      string
-	Namespace string
+	Name        string
+	Namespace   string
+	CreatedAt   time.Time
+	URL         string
+	SCMType     string
+	CloneURL    string
+	SSHURL      string
+	Framework   *manifest.Plugin
+	Services    []*manifest.Plugin
+	Scaling     int
+	Routes      []*Route       `json:",omitempty"`
+	Maintenance *Maintenance   `json:",omitempty"`
+	Protected   bool           `json:",omitempty"`
+	Lock        *OperationLock `json:",omitempty"`
+
+	Labels map[string]string `json:",omitempty"`
+
+	DeployLock    *DeployLock `json:",omitempty"`
+	DeployWindows []string    `json:",omitempty"`
+
+	Canary *Canary `json:",omitempty"`
+
+	AccessPolicy *AccessPolicy `json:",omitempty"`
+	HTTPSettings *HTTPSettings `json:",omitempty"`
+	Logging      *LogConfig    `json:",omitempty"`
+
+	// The current deployment branch with its latest commit, and the
+	// latest deployment in the history.
+	Branch         *Branch           `json:",omitempty"`
+	LastDeployment *DeploymentRecord `json:",omitempty"`
+
+	// The disk quota of the application in bytes, zero means unlimited.
+	DiskQuota int64 `json:",omitempty"`
+
+	// The memory limit in bytes and the number of CPUs of each application
+	// container, zero means unlimited.
+	MemoryLimit int64   `json:",omitempty"`
+	CPULimit    float64 `json:",omitempty"`
+}
+
+// OperationLock describes an operation in progress on an application,
+// other conflicting operations are rejected until it completed.
+type OperationLock struct {
+	Operation string
+	Since     time.Time
+}
+
+// DeployLock describes who locked deployments of an application and why.
+type DeployLock struct {
+	By     string
+	Reason string `json:",omitempty"`
+	Since  time.Time
+}
+
+// TrafficOptions starts a canary release of an application, changes the
+// percentage of traffic routed to the canary containers, or promotes or
+// rolls back the release in progress.
+type TrafficOptions struct {
+	Artifact    string `json:",omitempty"`
+	Weight      int
+	Containers  int  `json:",omitempty"`
+	AutoPromote bool `json:",omitempty"`
+	Promote     bool `json:",omitempty"`
+	Rollback    bool `json:",omitempty"`
+}
+
+// Canary describes a canary release of an application in progress.
+type Canary struct {
+	Artifact    string
+	Containers  []string
+	Weight      int
+	AutoPromote bool
+	By          string
+	Since       time.Time
+}
+
+// AccessPolicy restricts access to an application at the proxy by client
+// IP addresses or CIDR ranges and basic auth users. Passwords of basic auth
+// users are never returned, and the existing password of a user is kept
+// if not given when the policy changed.
+type AccessPolicy struct {
+	Allow               []string        `json:",omitempty"`
+	Deny                []string        `json:",omitempty"`
+	BasicAuth           []BasicAuthUser `json:",omitempty"`
+	MaintenanceRedirect string          `json:",omitempty"`
+}
+
+type BasicAuthUser struct {
+	User     string
+	Password string `json:",omitempty"`
+}
+
+// HTTPSettings controls how the proxy serves an application. HTTPS
+// redirect and HSTS apply to hosts with TLS certificates. Custom error
+// pages are URLs keyed by HTTP status codes of backend errors. WebSocket
+// and GRPC enable connection upgrades and HTTP/2 pass-through, and the
+// idle timeout of proxied connections is in seconds.
+type HTTPSettings struct {
+	ForceHTTPS            bool
+	HSTSMaxAge            int               `json:",omitempty"`
+	HSTSIncludeSubdomains bool              `json:",omitempty"`
+	ErrorPages            map[string]string `json:",omitempty"`
+	WebSocket             bool              `json:",omitempty"`
+	GRPC                  bool              `json:",omitempty"`
+	IdleTimeout           int               `json:",omitempty"`
+}
+
+// LogConfig ships container logs of an application to an external log
+// collector. The driver is one of "syslog", "fluentd" or "loki", and
+// options are passed to the Docker log driver.
+type LogConfig struct {
+	Driver  string
+	Address string
+	Options map[string]string `json:",omitempty"`
+}
+
+// Maintenance describes the maintenance mode of an application.
+type Maintenance struct {
+	By      string
+	Since   time.Time
+	Stopped bool `json:",omitempty"`
+}
+
+// Route contains response of remote API:
+// GET "/applications/{name}/routes"
+type Route struct {
+	Frontend  string
+	Backend   string
+	Container string
+	Service   string `json:",omitempty"`
+	Healthy   bool
+	Error     string `json:",omitempty"`
+}
+
+// SSHKey contains response of remote API:
+// GET "/user/keys"
+type SSHKey struct {
+	Label       string
+	Key         string
+	Fingerprint string
+	CreatedAt   time.Time
+	LastUsed    time.Time
+}
+
+// AccessToken contains response of remote API:
+// GET, POST "/user/tokens"
+type AccessToken struct {
+	ID        string
+	Label     string
+	Scopes    []string
 	CreatedAt time.Time
-	URL       string
-	SCMType   string
-	CloneURL  string
-	SSHURL    string
-	Framework *manifest.Plugin
-	Services  []*manifest.Plugin
-	Scaling   int
+	LastUsed  *time.Time `json:",omitempty"`
+
+	// The token is only returned when created.
+	Token string `json:",omitempty"`
+}
+
+// CreateAccessToken contains post options of remote API:
+// POST "/user/tokens"
+type CreateAccessToken struct {
+	Label  string
+	Scopes []string
+}
+
+// Registry contains response of remote API:
+// GET, POST "/user/registries"
+type Registry struct {
+	Server    string
+	Username  string
+	CreatedAt time.Time
+}
+
+// CreateRegistry contains post options of remote API:
+// POST "/user/registries"
+type CreateRegistry struct {
+	Server   string
+	Username string
+	Password string
+}
+
+// UserInfo contains response of remote API:
+// GET "/user"
+type UserInfo struct {
+	Name         string
+	Namespace    string
+	PendingEmail string     `json:",omitempty"`
+	DeleteAt     *time.Time `json:",omitempty"`
+}
+
+// UserPreferences contains response and put options of remote API:
+// GET, PUT "/user/preferences"
+type UserPreferences struct {
+	Theme         string `json:",omitempty"`
+	Locale        string `json:",omitempty"`
+	NamespaceView string `json:",omitempty"`
+	TableDensity  string `json:",omitempty"`
+}
+
+// ChangeEmail contains put options of remote API:
+// PUT "/user/email"
+type ChangeEmail struct {
+	Email string
+}
+
+// ConfirmEmail contains post options of remote API:
+// POST "/user/email/confirm"
+type ConfirmEmail struct {
+	Token string
+}
+
+// AccountDeletion contains response of remote API:
+// DELETE "/user"
+type AccountDeletion struct {
+	DeleteAt time.Time
+}
+
+// CreateSSHKey contains post options of remote API:
+// POST "/user/keys"
+type CreateSSHKey struct {
+	Label string
+	Key   string
+}
+
+// TrashedApplication contains response of remote API:
+// GET "/trash"
+type TrashedApplication struct {
+	Name      string
+	Plugins   []string
+	DeletedAt time.Time
+	ExpiresAt time.Time
+	HasData   bool
+}
+
+// FileInfo contains response of remote API:
+// GET "/applications/{name}/services/{service}/files?list"
+type FileInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
 }
 
 // CreateApplication struct contains post options of remote API:
@@ -39,6 +340,79 @@ type CreateApplication struct {
 	Framework string
 	Services  []string
 	Repo      string
+
+	// Credentials to clone a private repository over HTTP
+	RepoUsername string `json:",omitempty"`
+	RepoPassword string `json:",omitempty"`
+
+	// User defined labels of the application
+	Labels map[string]string `json:",omitempty"`
+
+	// The number of framework containers, default to 1
+	Scaling int `json:",omitempty"`
+
+	// Environment variables of the application
+	Env map[string]string `json:",omitempty"`
+}
+
+// Plan describes actions planned by a dry-run request, such as:
+// POST "/applications/?dry-run=1"
+type Plan struct {
+	Actions  []*PlannedAction
+	Warnings []string `json:",omitempty"`
+}
+
+// PlannedAction is an action that would be performed without the dry-run.
+type PlannedAction struct {
+	Action      string // create-namespace, create-container, remove-container, create-repo, populate-repo, deploy or start
+	Service     string `json:",omitempty"`
+	Plugin      string `json:",omitempty"`
+	Container   string `json:",omitempty"`
+	Description string
+}
+
+// DeployArtifact contains query options of remote API:
+// PUT "/applications/{name}/build"
+type DeployArtifact struct {
+	Filename string
+	Commit   string
+	Version  string
+}
+
+// Artifact contains response of remote API:
+// PUT "/applications/{name}/build"
+// GET "/applications/{name}/artifacts"
+// POST "/applications/{name}/artifacts/{id}/deploy"
+type Artifact struct {
+	ID         string
+	Filename   string
+	Format     string
+	Source     string
+	Commit     string `json:",omitempty"`
+	Version    string `json:",omitempty"`
+	Size       int64
+	Checksum   string
+	UploadedBy string `json:",omitempty"`
+	UploadedAt time.Time
+	DeployedAt *time.Time `json:",omitempty"`
+	Image      string     `json:",omitempty"`
+}
+
+// CreateUpload contains request body of remote API:
+// POST "/applications/{name}/uploads"
+type CreateUpload struct {
+	Size int64
+}
+
+// Upload contains response of remote API:
+// POST "/applications/{name}/uploads"
+// GET "/applications/{name}/uploads/{id}"
+// PUT "/applications/{name}/uploads/{id}"
+type Upload struct {
+	ID        string
+	Size      int64
+	Offset    int64
+	UpdatedAt time.Time
 }
 
 // ContainerJSONBase identifies a container.
@@ -57,6 +431,24 @@ type ContainerStatus struct {
 	Ports     []string
 	Uptime    int64
 	State     manifest.ActiveState
+	DiskUsage int64 `json:",omitempty"`
+}
+
+// DiskUsage contains response of remote API:
+// GET "/applications/{name}/du"
+type DiskUsage struct {
+	Containers []*ContainerDiskUsage
+	Total      int64
+	Quota      int64 `json:",omitempty"`
+}
+
+// ContainerDiskUsage describes the disk space used by a container. Size
+// is the size of files created or changed in the container, and Data is
+// the size of the data directory.
+type ContainerDiskUsage struct {
+	ContainerJSONBase
+	Size int64
+	Data int64
 }
 
 // ProcessList contains response of remote API:
@@ -94,6 +486,12 @@ type Branch struct {
 
 	// The branch type, such as "BRANCH" or "TAG"
 	Type string
+
+	// The hash of the latest commit on the branch
+	LatestCommit string `json:",omitempty"`
+
+	// The time of the latest commit, zero if unknown
+	LatestCommitTime time.Time
 }
 
 // Deployments contains response of remote API:
@@ -105,3 +503,174 @@ type Deployments struct {
 	// All deployment branches
 	Branches []*Branch
 }
+
+// DeploymentStatus contains status events of remote API:
+// GET "/applications/{name}/deploy/stream"
+type DeploymentStatus struct {
+	Name   string
+	Branch string
+	State  string
+}
+
+// DeploymentRecord contains response of remote API:
+// GET "/applications/{name}/deploy/history"
+// POST "/applications/{name}/deploy/history/{id}/rollback"
+type DeploymentRecord struct {
+	ID         string
+	Source     string
+	Branch     string `json:",omitempty"`
+	Commit     string `json:",omitempty"`
+	Version    string `json:",omitempty"`
+	Artifact   string `json:",omitempty"`
+	RollbackOf string `json:",omitempty"`
+	DeployedBy string `json:",omitempty"`
+	StartedAt  time.Time
+	FinishedAt *time.Time `json:",omitempty"`
+	State      string
+	Error      string `json:",omitempty"`
+}
+
+// NodeStatus contains response of remote API:
+// GET "/admin/nodes"
+type NodeStatus struct {
+	Name              string `json:",omitempty"`
+	Host              string
+	Reachable         bool
+	Schedulable       bool
+	Error             string `json:",omitempty"`
+	LastCheck         time.Time
+	Failures          int
+	Version           string `json:",omitempty"`
+	OS                string `json:",omitempty"`
+	Arch              string `json:",omitempty"`
+	NCPU              int
+	MemTotal          int64
+	Containers        int
+	ContainersRunning int
+	ContainersStopped int
+	UsernsRemap       bool `json:",omitempty"`
+	Headroom          int
+}
+
+// ResetPassword contains put options of remote API:
+// PUT "/admin/users/{name}/password"
+type ResetPassword struct {
+	Password string
+}
+
+// NamespaceDomain contains put options of remote API:
+// PUT "/admin/namespaces/{namespace}/domain"
+type NamespaceDomain struct {
+	Domain string
+}
+
+// Notice contains response of remote API:
+// GET "/notices"
+// GET "/admin/notices"
+type Notice struct {
+	ID        string
+	Kind      string
+	Message   string
+	URL       string `json:",omitempty"`
+	Starts    time.Time
+	Expires   *time.Time `json:",omitempty"`
+	CreatedBy string     `json:",omitempty"`
+	CreatedAt time.Time
+}
+
+// CreateNotice contains post options of remote API:
+// POST "/admin/notices"
+//
+// The notice starts immediately if Starts is nil, and never expires if
+// Expires is nil.
+type CreateNotice struct {
+	Kind    string `json:",omitempty"`
+	Message string
+	URL     string     `json:",omitempty"`
+	Starts  *time.Time `json:",omitempty"`
+	Expires *time.Time `json:",omitempty"`
+}
+
+// CreateServiceAccount contains post options of remote API:
+// POST "/admin/serviceaccounts"
+type CreateServiceAccount struct {
+	Name        string
+	Namespace   string
+	Application string   `json:",omitempty"`
+	Scopes      []string `json:",omitempty"`
+}
+
+// ServiceAccount contains response of remote API:
+// GET "/admin/serviceaccounts"
+// POST "/admin/serviceaccounts"
+//
+// The token is only returned when the service account is created.
+type ServiceAccount struct {
+	Name        string
+	Token       string `json:",omitempty"`
+	Namespace   string
+	Application string `json:",omitempty"`
+	Scopes      []string
+	CreatedBy   string
+	CreatedAt   time.Time
+}
+
+// CreateInvite contains post options of remote API:
+// POST "/admin/invites"
+type CreateInvite struct {
+	Email     string `json:",omitempty"`
+	Namespace string `json:",omitempty"`
+	DiskQuota string `json:",omitempty"`
+	TTL       string `json:",omitempty"`
+}
+
+// Collaborator is a user granted access to an application, the access is
+// "read" or "deploy".
+type Collaborator struct {
+	User   string
+	Access string
+}
+
+// ServiceLink contains response of remote API:
+// GET "/applications/{name}/links"
+type ServiceLink struct {
+	App     string
+	Service string
+}
+
+// CreateStandaloneService contains request of remote API:
+// POST "/services/"
+type CreateStandaloneService struct {
+	Name   string
+	Plugin string
+}
+
+// StandaloneService contains response of remote API:
+// GET "/services/{name}"
+type StandaloneService struct {
+	Name      string
+	Namespace string
+	CreatedAt time.Time
+	Plugin    *manifest.Plugin
+	State     string
+
+	// The service name used to link the service into applications.
+	ServiceName string
+}
+
+// Invite contains response of remote API:
+// GET "/admin/invites"
+// POST "/admin/invites"
+//
+// Token and URL are only returned when the invitation is created.
+type Invite struct {
+	ID        string
+	Token     string `json:",omitempty"`
+	URL       string `json:",omitempty"`
+	Email     string `json:",omitempty"`
+	Namespace string `json:",omitempty"`
+	DiskQuota int64  `json:",omitempty"`
+	CreatedBy string
+	CreatedAt time.Time
+	Expires   time.Time
+}
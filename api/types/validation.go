@@ -0,0 +1,145 @@
+package types
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ValidationError is the response of remote API when request parameters
+// are invalid, with error messages of invalid fields.
+type ValidationError struct {
+	Message string
+	Fields  []FieldError
+}
+
+// FieldError is the error message of an invalid field.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return e.Message + ": " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// JSONError tells the server to send the error as a JSON object.
+func (e *ValidationError) JSONError() bool {
+	return true
+}
+
+// Add adds the error message of the field.
+func (e *ValidationError) Add(field, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+// Field returns the error message of the field, or an empty string if the
+// field is valid.
+func (e *ValidationError) Field(field string) string {
+	for _, f := range e.Fields {
+		if f.Field == field {
+			return f.Message
+		}
+	}
+	return ""
+}
+
+// Err returns the validation error, or nil if all fields are valid.
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+var applicationNamePattern = regexp.MustCompile("^[a-z][a-z_0-9]*$")
+
+// ValidApplicationName returns true if the application name starts with a
+// lower case letter, followed by lower case letters, digits or underscores.
+func ValidApplicationName(name string) bool {
+	return applicationNamePattern.MatchString(name)
+}
+
+// Validate checks the syntax of create options, the existence of plugins
+// and the application are checked by the server.
+func (opts *CreateApplication) Validate() *ValidationError {
+	verr := &ValidationError{Message: "Invalid application options"}
+
+	if opts.Name == "" {
+		verr.Add("name", "The application name cannot be empty.")
+	} else if !ValidApplicationName(opts.Name) {
+		verr.Add("name", "The application name can only contains lower case letters, digits or underscores.")
+	}
+
+	if opts.Framework == "" {
+		verr.Add("framework", "The application framework cannot be empty.")
+	}
+
+	for _, tag := range opts.Services {
+		if tag == "" || strings.ContainsAny(tag, " \t\r\n") {
+			verr.Add("services", "Invalid service plugin: "+tag)
+			break
+		}
+	}
+
+	if repo := opts.Repo; repo != "" && strings.ToLower(repo) != "empty" {
+		if !validRepoURL(repo) {
+			verr.Add("repo", "Invalid repository URL: "+repo)
+		} else if opts.RepoUsername != "" && !strings.HasPrefix(repo, "http://") && !strings.HasPrefix(repo, "https://") {
+			verr.Add("repo", "Credentials are only supported for HTTP repository URL.")
+		}
+	}
+
+	if opts.Scaling < 0 || opts.Scaling > 10 {
+		verr.Add("scaling", "The scaling number must be between 1 and 10.")
+	}
+
+	for key := range opts.Env {
+		if !envKeyPattern.MatchString(key) {
+			verr.Add("env", "Invalid environment variable key: "+key)
+			break
+		}
+	}
+
+	return verr
+}
+
+// Validate checks the syntax of create options of a standalone service.
+func (opts *CreateStandaloneService) Validate() *ValidationError {
+	verr := &ValidationError{Message: "Invalid service options"}
+
+	if opts.Name == "" {
+		verr.Add("name", "The service name cannot be empty.")
+	} else if !ValidApplicationName(opts.Name) {
+		verr.Add("name", "The service name can only contains lower case letters, digits or underscores.")
+	}
+	if opts.Plugin == "" || strings.ContainsAny(opts.Plugin, " \t\r\n") {
+		verr.Add("plugin", "Invalid service plugin: "+opts.Plugin)
+	}
+
+	return verr
+}
+
+var envKeyPattern = regexp.MustCompile("^[a-zA-Z_][a-zA-Z_0-9]*$")
+
+// validRepoURL returns true if the repository URL is an absolute URL or
+// a scp-like address such as "git@github.com:cloudway/platform.git".
+func validRepoURL(repo string) bool {
+	if strings.ContainsAny(repo, " \t\r\n") {
+		return false
+	}
+	if u, err := url.Parse(repo); err == nil && u.Scheme != "" && (u.Host != "" || u.Scheme == "file") {
+		return true
+	}
+	i := strings.IndexRune(repo, ':')
+	return i > 0 && !strings.ContainsRune(repo[:i], '/') && i < len(repo)-1
+}
@@ -0,0 +1,110 @@
+package userdb
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// emailTokenExpiry is the time to confirm the new email address.
+const emailTokenExpiry = 24 * time.Hour
+
+// The InvalidTokenError indicates that a confirmation token is invalid or
+// has expired.
+type InvalidTokenError struct{}
+
+func (e InvalidTokenError) Error() string {
+	return "The confirmation link is invalid or has expired"
+}
+
+func (e InvalidTokenError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RequestEmailChange records the new email address of the user, which is
+// pending until confirmed with the returned token. Only the hash of the
+// token is saved.
+func (db *UserDatabase) RequestEmailChange(name, email string) (token string, err error) {
+	var other BasicUser
+	if err = db.plugin.Find(email, &other); err == nil {
+		return "", DuplicateUserError(email)
+	} else if !IsUserNotFound(err) {
+		return "", err
+	}
+
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+
+	err = db.Update(name, Args{
+		"pendingemail":     email,
+		"emailtoken":       hashToken(token),
+		"emailtokenexpiry": time.Now().Add(emailTokenExpiry),
+	})
+	return token, err
+}
+
+// ConfirmEmailChange changes the email address of the user, which is the
+// user name, to the pending email address with the given token. Returns
+// the old and new user names.
+func (db *UserDatabase) ConfirmEmailChange(token string) (oldName, newName string, err error) {
+	var user BasicUser
+	if err = db.plugin.Search(Args{"emailtoken": hashToken(token)}, &user); err != nil {
+		if IsUserNotFound(err) {
+			err = InvalidTokenError{}
+		}
+		return
+	}
+	if user.PendingEmail == "" || time.Now().After(user.EmailTokenExpiry) {
+		return "", "", InvalidTokenError{}
+	}
+
+	oldName, newName = user.Name, user.PendingEmail
+	defer db.invalidate(oldName)
+	if err = db.plugin.Rename(oldName, newName); err != nil {
+		return "", "", err
+	}
+
+	err = db.Update(newName, Args{
+		"pendingemail":     "",
+		"emailtoken":       "",
+		"emailtokenexpiry": time.Time{},
+	})
+	return oldName, newName, err
+}
+
+// ScheduleDeletion schedules deletion of the user account at the given time.
+func (db *UserDatabase) ScheduleDeletion(name string, at time.Time) error {
+	return db.Update(name, Args{"deleteat": at})
+}
+
+// CancelDeletion cancels the scheduled deletion of the user account.
+func (db *UserDatabase) CancelDeletion(name string) error {
+	return db.Update(name, Args{"deleteat": time.Time{}})
+}
+
+// FindExpiredAccounts returns names of users whose scheduled deletion time
+// has passed.
+func (db *UserDatabase) FindExpiredAccounts() ([]string, error) {
+	var users []BasicUser
+	filter := Args{"deleteat": Args{"$gt": time.Time{}, "$lte": time.Now()}}
+	if err := db.plugin.Search(filter, &users); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(users))
+	for i := range users {
+		names[i] = users[i].Name
+	}
+	return names, nil
+}
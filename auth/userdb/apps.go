@@ -0,0 +1,86 @@
+package userdb
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// maxUpdateRetries is the number of times ModifyApplication retries when
+// the application record was modified concurrently.
+const maxUpdateRetries = 3
+
+// The ApplicationConflictError indicates that an application record was
+// modified by another process since it was read.
+type ApplicationConflictError string
+
+// The ApplicationNotFoundError indicates that an application record was
+// not found in the user database.
+type ApplicationNotFoundError string
+
+func (e ApplicationConflictError) Error() string {
+	return fmt.Sprintf("Application '%s' was modified concurrently, please try again", string(e))
+}
+
+func (e ApplicationConflictError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+func IsApplicationConflict(err error) bool {
+	_, ok := err.(ApplicationConflictError)
+	return ok
+}
+
+func (e ApplicationNotFoundError) Error() string {
+	return fmt.Sprintf("Application '%s' not found", string(e))
+}
+
+func (e ApplicationNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+// SaveApplication saves the application record of the user. The record is
+// only saved if it has not been modified since read, that is the version
+// in the database is the same as the version of the given record. A new
+// application record has a zero version. The version is incremented after
+// saved. Returns ApplicationConflictError if the record was modified.
+func (db *UserDatabase) SaveApplication(username, name string, app *Application) error {
+	defer db.invalidate(username)
+	if err := db.plugin.UpdateApplication(username, name, app.Version, app); err != nil {
+		return err
+	}
+	app.Version++
+	return nil
+}
+
+// RemoveApplication removes the application record of the user regardless
+// of its version, the application resources must already be destroyed.
+func (db *UserDatabase) RemoveApplication(username, name string) error {
+	defer db.invalidate(username)
+	return db.plugin.UpdateApplication(username, name, -1, nil)
+}
+
+// ModifyApplication reads the latest application record of the user,
+// applies the modification and saves the record. The modification is
+// applied again to the latest record if the record was modified
+// concurrently. Returns the modified application record.
+func (db *UserDatabase) ModifyApplication(username, name string, modify func(*Application) error) (app *Application, err error) {
+	for i := 0; i < maxUpdateRetries; i++ {
+		var user BasicUser
+		if err = db.plugin.Find(username, &user); err != nil {
+			return nil, err
+		}
+		if app = user.Applications[name]; app == nil {
+			return nil, ApplicationNotFoundError(name)
+		}
+		if err = modify(app); err != nil {
+			return nil, err
+		}
+		if err = db.SaveApplication(username, name, app); !IsApplicationConflict(err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return app, nil
+}
@@ -0,0 +1,152 @@
+package userdb
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// defaultCacheTTL is the default time that a user record stays in cache.
+// The user database may be updated by other processes, so the cached
+// records must expire in a short time.
+const defaultCacheTTL = 10 * time.Second
+
+// userCache is a read-through cache for user records. Records are keyed
+// by user name and the concrete type of User, and are invalidated
+// explicitly on writes.
+type userCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[cacheKey]cacheEntry
+}
+
+type cacheKey struct {
+	name string
+	typ  reflect.Type
+}
+
+type cacheEntry struct {
+	user    reflect.Value
+	expires time.Time
+}
+
+func newUserCache(ttl time.Duration) *userCache {
+	return &userCache{ttl: ttl, entries: make(map[cacheKey]cacheEntry)}
+}
+
+// get fills the result with the cached user record. Returns false if the
+// record is not cached or has expired.
+func (c *userCache) get(name string, result User) bool {
+	if c.ttl <= 0 {
+		return false
+	}
+
+	key := cacheKey{name, reflect.TypeOf(result)}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok && time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	c.mu.Unlock()
+
+	if ok {
+		deepCopy(reflect.ValueOf(result).Elem(), entry.user)
+	}
+	return ok
+}
+
+// put saves a copy of the user record into the cache.
+func (c *userCache) put(name string, user User) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	src := reflect.ValueOf(user).Elem()
+	val := reflect.New(src.Type()).Elem()
+	deepCopy(val, src)
+
+	c.mu.Lock()
+	c.entries[cacheKey{name, reflect.TypeOf(user)}] = cacheEntry{val, time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+}
+
+// invalidate removes all cached records of the given user.
+func (c *userCache) invalidate(name string) {
+	c.mu.Lock()
+	for key := range c.entries {
+		if key.name == name {
+			delete(c.entries, key)
+		}
+	}
+	c.mu.Unlock()
+}
+
+// clear removes all cached records.
+func (c *userCache) clear() {
+	c.mu.Lock()
+	c.entries = make(map[cacheKey]cacheEntry)
+	c.mu.Unlock()
+}
+
+// deepCopy copies the source value into destination, allocating new
+// pointers, maps and slices so that the copy doesn't share any mutable
+// state with the source.
+func deepCopy(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(src.Type()))
+			return
+		}
+		v := reflect.New(src.Type().Elem())
+		deepCopy(v.Elem(), src.Elem())
+		dst.Set(v)
+
+	case reflect.Interface:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(src.Type()))
+			return
+		}
+		v := reflect.New(src.Elem().Type()).Elem()
+		deepCopy(v, src.Elem())
+		dst.Set(v)
+
+	case reflect.Struct:
+		// copy unexported fields as is, then copy exported fields deeply
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if f := dst.Field(i); f.CanSet() {
+				deepCopy(f, src.Field(i))
+			}
+		}
+
+	case reflect.Map:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(src.Type()))
+			return
+		}
+		m := reflect.MakeMap(src.Type())
+		for _, k := range src.MapKeys() {
+			v := reflect.New(src.Type().Elem()).Elem()
+			deepCopy(v, src.MapIndex(k))
+			m.SetMapIndex(k, v)
+		}
+		dst.Set(m)
+
+	case reflect.Slice:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(src.Type()))
+			return
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(s.Index(i), src.Index(i))
+		}
+		dst.Set(s)
+
+	default:
+		dst.Set(src)
+	}
+}
@@ -0,0 +1,44 @@
+package userdb
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The DomainInUseError indicates that the domain is already delegated to
+// another namespace.
+type DomainInUseError string
+
+func (e DomainInUseError) Error() string {
+	return fmt.Sprintf("The domain '%s' is already delegated to another namespace", string(e))
+}
+
+func (e DomainInUseError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+// SetDomain delegates the domain to the namespace of the user, applications
+// in the namespace are served under the domain. An empty domain removes the
+// delegation.
+func (db *UserDatabase) SetDomain(name, domain string) error {
+	domain = strings.ToLower(domain)
+	if domain != "" {
+		var other BasicUser
+		err := db.Search(Args{"domain": domain}, &other)
+		if err == nil && other.Name != name {
+			return DomainInUseError(domain)
+		}
+		if err != nil && !IsUserNotFound(err) {
+			return err
+		}
+	}
+	return db.Update(name, Args{"domain": domain})
+}
+
+// FindByDomain finds the user whose namespace is delegated with the domain.
+func (db *UserDatabase) FindByDomain(domain string) (User, error) {
+	var user BasicUser
+	err := db.Search(Args{"domain": strings.ToLower(domain)}, &user)
+	return &user, err
+}
@@ -0,0 +1,97 @@
+package userdb
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Invite is a single-use invitation to register a user account. Only the
+// hash of the invitation token is saved in the database.
+type Invite struct {
+	ID string `bson:"_id"`
+
+	// The email address that must be used to register, any address can
+	// be used if empty.
+	Email string `bson:",omitempty"`
+
+	// The namespace and disk quota assigned to the registered user.
+	Namespace string `bson:",omitempty"`
+	DiskQuota int64  `bson:",omitempty"`
+
+	CreatedBy string
+	CreatedAt time.Time
+	Expires   time.Time
+}
+
+// The InvalidInviteError indicates that an invitation is invalid, has
+// expired or has been used.
+type InvalidInviteError struct{}
+
+func (e InvalidInviteError) Error() string {
+	return "The invitation is invalid or has expired"
+}
+
+func (e InvalidInviteError) HTTPErrorStatusCode() int {
+	return http.StatusForbidden
+}
+
+// CreateInvite saves the invitation that expires after the given time, and
+// returns the invitation token.
+func (db *UserDatabase) CreateInvite(inv *Invite, ttl time.Duration) (token string, err error) {
+	b := make([]byte, 24)
+	if _, err = rand.Read(b); err != nil {
+		return "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+
+	inv.ID = hashToken(token)
+	inv.Email = strings.ToLower(inv.Email)
+	inv.CreatedAt = time.Now()
+	inv.Expires = inv.CreatedAt.Add(ttl)
+	return token, db.plugin.CreateInvite(inv)
+}
+
+// FindInvite returns the invitation with the token. Returns
+// InvalidInviteError if the invitation does not exist or has expired.
+func (db *UserDatabase) FindInvite(token string) (*Invite, error) {
+	inv, err := db.plugin.FindInvite(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if inv == nil || inv.Expires.Before(time.Now()) {
+		return nil, InvalidInviteError{}
+	}
+	return inv, nil
+}
+
+// UseInvite removes the invitation with the token so it can't be used
+// again, and returns the invitation.
+func (db *UserDatabase) UseInvite(token string) (*Invite, error) {
+	inv, err := db.FindInvite(token)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.plugin.RemoveInvite(inv.ID); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// ReturnInvite saves the used invitation back, so it can be used again if
+// registration failed.
+func (db *UserDatabase) ReturnInvite(inv *Invite) error {
+	return db.plugin.CreateInvite(inv)
+}
+
+// ListInvites returns all invitations that have not been used.
+func (db *UserDatabase) ListInvites() ([]*Invite, error) {
+	return db.plugin.ListInvites()
+}
+
+// RemoveInvite revokes the invitation with the given ID.
+func (db *UserDatabase) RemoveInvite(id string) error {
+	return db.plugin.RemoveInvite(id)
+}
@@ -0,0 +1,140 @@
+package userdb
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// The DuplicateKeyError indicates that a SSH key already exists in the database
+type DuplicateKeyError string
+
+// The KeyNotFoundError indicates that a SSH key was not found in the database
+type KeyNotFoundError string
+
+// The InvalidKeyError indicates that a SSH public key cannot be parsed
+type InvalidKeyError struct {
+	Err error
+}
+
+func (e DuplicateKeyError) Error() string {
+	return fmt.Sprintf("The SSH public key already exists: %s", string(e))
+}
+
+func (e DuplicateKeyError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+func (e KeyNotFoundError) Error() string {
+	return fmt.Sprintf("SSH key not found: %s", string(e))
+}
+
+func (e KeyNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+func (e InvalidKeyError) Error() string {
+	return fmt.Sprintf("Invalid SSH public key: %v", e.Err)
+}
+
+func (e InvalidKeyError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// NewSSHKey parses a public key in the authorized_keys format. The key
+// comment is used as label if no label specified.
+func NewSSHKey(label, text string) (*SSHKey, error) {
+	pub, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(text))
+	if err != nil {
+		return nil, InvalidKeyError{err}
+	}
+	if label == "" {
+		label = comment
+	}
+	return &SSHKey{
+		Label:       label,
+		Text:        strings.TrimSpace(text),
+		Fingerprint: fingerprintSHA256(pub),
+		CreatedAt:   time.Now(),
+	}, nil
+}
+
+// fingerprintSHA256 returns the SHA256 fingerprint of the public key, in
+// the same format as OpenSSH.
+func fingerprintSHA256(pub ssh.PublicKey) string {
+	sum := sha256.Sum256(pub.Marshal())
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// AddSSHKey adds a public SSH key to the user. A key can only be owned
+// by one user.
+func (db *UserDatabase) AddSSHKey(name string, key *SSHKey) error {
+	if _, err := db.FindByKey(key.Fingerprint); err == nil {
+		return DuplicateKeyError(key.Fingerprint)
+	} else if !IsUserNotFound(err) {
+		return err
+	}
+
+	defer db.invalidate(name)
+	return db.plugin.PushItem(name, "sshkeys", key)
+}
+
+// RemoveSSHKey removes a public SSH key from the user and returns the
+// removed key.
+func (db *UserDatabase) RemoveSSHKey(name, fingerprint string) (*SSHKey, error) {
+	var user BasicUser
+	if err := db.plugin.Find(name, &user); err != nil {
+		return nil, err
+	}
+
+	for _, key := range user.SSHKeys {
+		if key.Fingerprint == fingerprint {
+			defer db.invalidate(name)
+			removed, err := db.plugin.PullItem(name, "sshkeys", "fingerprint", fingerprint)
+			if err == nil && !removed {
+				err = KeyNotFoundError(fingerprint)
+			}
+			if err != nil {
+				return nil, err
+			}
+			return key, nil
+		}
+	}
+	return nil, KeyNotFoundError(fingerprint)
+}
+
+// FindByKey finds the user who owns the public SSH key with the given
+// fingerprint.
+func (db *UserDatabase) FindByKey(fingerprint string) (*BasicUser, error) {
+	var user BasicUser
+	err := db.Search(Args{"sshkeys.fingerprint": fingerprint}, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// AuthorizeSSHKey returns the user who owns the public SSH key, and
+// records the time that the key was last used.
+func (db *UserDatabase) AuthorizeSSHKey(pub ssh.PublicKey) (*BasicUser, error) {
+	fingerprint := fingerprintSHA256(pub)
+	user, err := db.FindByKey(fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if user.Inactive {
+		return nil, InactiveUserError(user.Name)
+	}
+
+	defer db.invalidate(user.Name)
+	_, err = db.plugin.UpdateItem(user.Name, "sshkeys", "fingerprint", fingerprint, Args{"lastused": time.Now()})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
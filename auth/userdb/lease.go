@@ -0,0 +1,44 @@
+package userdb
+
+import "time"
+
+// Lease is a named lease held by a server for a limited time. Leases are
+// used to coordinate multiple API servers sharing the same database, such
+// as electing a leader for background tasks and locking applications.
+type Lease struct {
+	Name    string `bson:"_id"`
+	Holder  string
+	Value   string `bson:",omitempty"`
+	Since   time.Time
+	Expires time.Time
+}
+
+// AcquireLease acquires the lease for the holder, or renews the lease if it
+// is already held by the holder. Returns false if the lease is held by
+// another holder and has not expired.
+func (db *UserDatabase) AcquireLease(name, holder, value string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	lease := &Lease{
+		Name:    name,
+		Holder:  holder,
+		Value:   value,
+		Since:   now,
+		Expires: now.Add(ttl),
+	}
+	return db.plugin.AcquireLease(lease)
+}
+
+// ReleaseLease releases the lease if it is held by the holder.
+func (db *UserDatabase) ReleaseLease(name, holder string) error {
+	return db.plugin.ReleaseLease(name, holder)
+}
+
+// FindLease returns the lease with the given name, or nil if the lease is
+// not held by anyone.
+func (db *UserDatabase) FindLease(name string) (*Lease, error) {
+	lease, err := db.plugin.FindLease(name)
+	if err != nil || lease == nil || lease.Expires.Before(time.Now()) {
+		return nil, err
+	}
+	return lease, nil
+}
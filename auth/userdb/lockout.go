@@ -0,0 +1,115 @@
+package userdb
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cloudway/platform/config"
+)
+
+// Failed login attempts are recorded in the user record. The account is
+// locked temporarily after "auth.max_failed_logins" consecutive failures.
+// The account is locked for "auth.lockout_duration" at first, and the
+// duration is doubled for each further failure, up to a day. A successful
+// login resets the failure count.
+
+const (
+	defaultMaxFailedLogins = 5
+	defaultLockoutDuration = time.Minute
+	maxLockoutDuration     = 24 * time.Hour
+)
+
+// The AccountLockedError indicates that a user account is locked due to
+// too many failed login attempts.
+type AccountLockedError struct {
+	Name  string
+	Until time.Time
+}
+
+func (e AccountLockedError) Error() string {
+	return fmt.Sprintf("Account %s is locked due to too many failed login attempts, try again after %s",
+		e.Name, e.Until.Format(time.RFC1123))
+}
+
+func (e AccountLockedError) HTTPErrorStatusCode() int {
+	return http.StatusTooManyRequests
+}
+
+func IsAccountLocked(err error) bool {
+	_, ok := err.(AccountLockedError)
+	return ok
+}
+
+// lockoutDuration returns the time to lock the account after the given
+// number of consecutive failed logins, or zero if not locked.
+func lockoutDuration(failures int) time.Duration {
+	max := defaultMaxFailedLogins
+	if n, err := strconv.Atoi(config.Get("auth.max_failed_logins")); err == nil && n > 0 {
+		max = n
+	}
+	if failures < max {
+		return 0
+	}
+
+	d := defaultLockoutDuration
+	if v, err := time.ParseDuration(config.Get("auth.lockout_duration")); err == nil && v > 0 {
+		d = v
+	}
+	for n := failures - max; n > 0 && d < maxLockoutDuration; n-- {
+		d *= 2
+	}
+	if d > maxLockoutDuration {
+		d = maxLockoutDuration
+	}
+	return d
+}
+
+// CheckLocked returns AccountLockedError if the user account is locked.
+func (user *BasicUser) CheckLocked() error {
+	if time.Now().Before(user.LockedUntil) {
+		return AccountLockedError{Name: user.Name, Until: user.LockedUntil}
+	}
+	return nil
+}
+
+// RecordLoginFailure increments the failed login count of the user, and
+// locks the account if there are too many failures. The OnLocked hook is
+// called when the account is locked.
+func (db *UserDatabase) RecordLoginFailure(name string) error {
+	var user BasicUser
+	if err := db.plugin.Find(name, &user); err != nil {
+		return err
+	}
+
+	failures := user.FailedLogins + 1
+	fields := Args{"failedlogins": failures}
+	d := lockoutDuration(failures)
+	if d > 0 {
+		user.LockedUntil = time.Now().Add(d)
+		fields["lockeduntil"] = user.LockedUntil
+	}
+	if err := db.Update(name, fields); err != nil {
+		return err
+	}
+
+	if d > 0 && db.OnLocked != nil {
+		db.OnLocked(name, user.LockedUntil)
+	}
+	return nil
+}
+
+// ResetLoginFailures clears the failed login count after a successful login.
+func (db *UserDatabase) ResetLoginFailures(user *BasicUser) error {
+	if user.FailedLogins == 0 && user.LockedUntil.IsZero() {
+		return nil
+	}
+	user.FailedLogins, user.LockedUntil = 0, time.Time{}
+	return db.Update(user.Name, Args{"failedlogins": 0, "lockeduntil": time.Time{}})
+}
+
+// Unlock unlocks the user account and clears the failed login count.
+func (db *UserDatabase) Unlock(name string) error {
+	return db.Update(name, Args{"failedlogins": 0, "lockeduntil": time.Time{}})
+}
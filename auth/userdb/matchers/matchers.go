@@ -49,3 +49,18 @@ func (matcher BeUserNotFound) FailureMessage(actual interface{}) (message string
 func (matcher BeUserNotFound) NegatedFailureMessage(actual interface{}) (message string) {
 	return format.Message(actual, "not to be a ", userdb.UserNotFoundError(string(matcher)))
 }
+
+type BeApplicationConflict string
+
+func (matcher BeApplicationConflict) Match(actual interface{}) (success bool, err error) {
+	actualErr, ok := actual.(userdb.ApplicationConflictError)
+	return ok && string(actualErr) == string(matcher), nil
+}
+
+func (matcher BeApplicationConflict) FailureMessage(actual interface{}) (message string) {
+	return format.Message(actual, "to be a", userdb.ApplicationConflictError(string(matcher)))
+}
+
+func (matcher BeApplicationConflict) NegatedFailureMessage(actual interface{}) (message string) {
+	return format.Message(actual, "not to be a", userdb.ApplicationConflictError(string(matcher)))
+}
@@ -0,0 +1,805 @@
+// Package memory provides a user database that keeps all records in memory.
+// It's intended for development and testing, and all records are lost when
+// the process exits.
+package memory
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/pkg/migrate"
+)
+
+// UserDB is an in-memory user database plugin. Records are kept as BSON
+// documents, so filters and updates with dotted field paths work the same
+// as the MongoDB plugin. Only the query operators used by the platform are
+// supported, that is $exists, $gt, $gte, $lt, $lte and $ne.
+type UserDB struct {
+	mu      sync.Mutex
+	colls   map[string][]bson.M
+	version int
+}
+
+// Collections of the user database, users are identified by name and other
+// records are identified by "_id".
+const (
+	usersCollection           = "users"
+	leasesCollection          = "leases"
+	invitesCollection         = "invites"
+	noticesCollection         = "notices"
+	serviceAccountsCollection = "serviceaccounts"
+	secretCollection          = "secret"
+)
+
+func init() {
+	prev := userdb.NewPlugin
+	userdb.NewPlugin = func() (userdb.Plugin, error) {
+		dbtype := config.Get("userdb.type")
+		dburl := config.Get("userdb.url")
+
+		if dbtype != "" && dbtype != "memory" {
+			return prev()
+		}
+		if dbtype == "" && !strings.HasPrefix(dburl, "memory://") {
+			return prev()
+		}
+		return New(), nil
+	}
+}
+
+// New creates an empty in-memory user database.
+func New() *UserDB {
+	return &UserDB{colls: make(map[string][]bson.M)}
+}
+
+func (db *UserDB) Create(user userdb.User) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	basic := user.Basic()
+	if db.findOne(usersCollection, bson.M{"name": basic.Name}) >= 0 {
+		return userdb.DuplicateUserError(basic.Name)
+	}
+	if basic.Namespace != "" && db.findOne(usersCollection, bson.M{"namespace": basic.Namespace}) >= 0 {
+		return userdb.DuplicateNamespaceError(basic.Namespace)
+	}
+	return db.insert(usersCollection, user)
+}
+
+func (db *UserDB) SetNamespace(username, namespace string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if namespace != "" {
+		if i := db.findOne(usersCollection, bson.M{"namespace": namespace}); i >= 0 {
+			if db.colls[usersCollection][i]["name"] == username {
+				return nil
+			}
+			return userdb.DuplicateNamespaceError(namespace)
+		}
+	}
+	return db.update(usersCollection, bson.M{"name": username}, bson.M{"namespace": namespace}, userdb.UserNotFoundError(username))
+}
+
+func (db *UserDB) Find(name string, result userdb.User) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	i := db.findOne(usersCollection, bson.M{"name": name})
+	if i < 0 {
+		return userdb.UserNotFoundError(name)
+	}
+	return fromDoc(db.colls[usersCollection][i], result)
+}
+
+func (db *UserDB) Search(filter interface{}, result interface{}) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	f, err := toDoc(filter)
+	if err != nil {
+		return err
+	}
+
+	resultv := reflect.ValueOf(result)
+	if resultv.Kind() == reflect.Ptr && resultv.Elem().Kind() == reflect.Slice {
+		var docs []bson.M
+		for _, doc := range db.colls[usersCollection] {
+			if matches(doc, f) {
+				docs = append(docs, doc)
+			}
+		}
+		return fromDocs(docs, resultv.Elem())
+	}
+
+	i := db.findOne(usersCollection, f)
+	if i < 0 {
+		return userdb.UserNotFoundError(fmt.Sprintf("%v", filter))
+	}
+	return fromDoc(db.colls[usersCollection][i], result)
+}
+
+func (db *UserDB) Remove(name string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if !db.remove(usersCollection, bson.M{"name": name}) {
+		return userdb.UserNotFoundError(name)
+	}
+	return nil
+}
+
+func (db *UserDB) Update(name string, fields interface{}) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.update(usersCollection, bson.M{"name": name}, fields, userdb.UserNotFoundError(name))
+}
+
+func (db *UserDB) Rename(name, newName string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if name != newName && db.findOne(usersCollection, bson.M{"name": newName}) >= 0 {
+		return userdb.DuplicateUserError(newName)
+	}
+	return db.update(usersCollection, bson.M{"name": name}, bson.M{"name": newName}, userdb.UserNotFoundError(name))
+}
+
+func (db *UserDB) PushItem(name, field string, item interface{}) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	i := db.findOne(usersCollection, bson.M{"name": name})
+	if i < 0 {
+		return userdb.UserNotFoundError(name)
+	}
+	value, err := toValue(item)
+	if err != nil {
+		return err
+	}
+	doc := db.colls[usersCollection][i]
+	items, _ := doc[field].([]interface{})
+	doc[field] = append(items, value)
+	return nil
+}
+
+func (db *UserDB) PullItem(name, field, key string, value interface{}) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	i := db.findOne(usersCollection, bson.M{"name": name})
+	if i < 0 {
+		return false, nil
+	}
+	v, err := toValue(value)
+	if err != nil {
+		return false, err
+	}
+	doc := db.colls[usersCollection][i]
+	items, _ := doc[field].([]interface{})
+	kept := []interface{}{}
+	for _, item := range items {
+		if elem, ok := item.(bson.M); !ok || !matchAny([]interface{}{elem[key]}, v) {
+			kept = append(kept, item)
+		}
+	}
+	doc[field] = kept
+	return len(kept) != len(items), nil
+}
+
+func (db *UserDB) UpdateItem(name, field, key string, value interface{}, fields interface{}) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	i := db.findOne(usersCollection, bson.M{"name": name})
+	if i < 0 {
+		return false, nil
+	}
+	v, err := toValue(value)
+	if err != nil {
+		return false, err
+	}
+	values, err := toDoc(fields)
+	if err != nil {
+		return false, err
+	}
+	items, _ := db.colls[usersCollection][i][field].([]interface{})
+	for _, item := range items {
+		if elem, ok := item.(bson.M); ok && matchAny([]interface{}{elem[key]}, v) {
+			for path, fv := range values {
+				set(elem, path, fv)
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (db *UserDB) UpdateApplication(username, name string, version int, app *userdb.Application) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	i := db.findOne(usersCollection, bson.M{"name": username})
+	if i < 0 {
+		return userdb.UserNotFoundError(username)
+	}
+	doc := db.colls[usersCollection][i]
+
+	field := "applications." + name
+	current := 0
+	if v := lookup(doc, field+".version"); len(v) != 0 {
+		current, _ = v[0].(int)
+	}
+	if version >= 0 && version != current {
+		return userdb.ApplicationConflictError(name)
+	}
+
+	if app == nil {
+		unset(doc, field)
+		return nil
+	}
+
+	saved := *app
+	if version >= 0 {
+		saved.Version = version + 1
+	} else {
+		saved.Version++
+	}
+	value, err := toValue(&saved)
+	if err != nil {
+		return err
+	}
+	set(doc, field, value)
+	return nil
+}
+
+func (db *UserDB) AcquireLease(lease *userdb.Lease) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if i := db.findOne(leasesCollection, bson.M{"_id": lease.Name}); i >= 0 {
+		var held userdb.Lease
+		if err := fromDoc(db.colls[leasesCollection][i], &held); err != nil {
+			return false, err
+		}
+		if held.Holder == lease.Holder {
+			err := db.update(leasesCollection, bson.M{"_id": lease.Name},
+				bson.M{"value": lease.Value, "expires": lease.Expires}, nil)
+			return err == nil, err
+		}
+		if held.Expires.After(time.Now()) {
+			return false, nil
+		}
+		db.remove(leasesCollection, bson.M{"_id": lease.Name})
+	}
+
+	err := db.insert(leasesCollection, lease)
+	return err == nil, err
+}
+
+func (db *UserDB) ReleaseLease(name, holder string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.remove(leasesCollection, bson.M{"_id": name, "holder": holder})
+	return nil
+}
+
+func (db *UserDB) FindLease(name string) (*userdb.Lease, error) {
+	lease := new(userdb.Lease)
+	if found, err := db.findByID(leasesCollection, name, lease); !found || err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+func (db *UserDB) CreateInvite(inv *userdb.Invite) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.insert(invitesCollection, inv)
+}
+
+func (db *UserDB) FindInvite(id string) (*userdb.Invite, error) {
+	inv := new(userdb.Invite)
+	if found, err := db.findByID(invitesCollection, id, inv); !found || err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+func (db *UserDB) ListInvites() ([]*userdb.Invite, error) {
+	invites := []*userdb.Invite{}
+	if err := db.list(invitesCollection, &invites); err != nil {
+		return nil, err
+	}
+	sort.Sort(invitesByTime(invites))
+	return invites, nil
+}
+
+type invitesByTime []*userdb.Invite
+
+func (a invitesByTime) Len() int           { return len(a) }
+func (a invitesByTime) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a invitesByTime) Less(i, j int) bool { return a[i].CreatedAt.Before(a[j].CreatedAt) }
+
+type noticesByTime []*userdb.Notice
+
+func (a noticesByTime) Len() int           { return len(a) }
+func (a noticesByTime) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a noticesByTime) Less(i, j int) bool { return a[i].Starts.Before(a[j].Starts) }
+
+type accountsByName []*userdb.ServiceAccount
+
+func (a accountsByName) Len() int           { return len(a) }
+func (a accountsByName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a accountsByName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+func (db *UserDB) RemoveInvite(id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if !db.remove(invitesCollection, bson.M{"_id": id}) {
+		return userdb.InvalidInviteError{}
+	}
+	return nil
+}
+
+func (db *UserDB) CreateNotice(n *userdb.Notice) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.insert(noticesCollection, n)
+}
+
+func (db *UserDB) ListNotices() ([]*userdb.Notice, error) {
+	notices := []*userdb.Notice{}
+	if err := db.list(noticesCollection, &notices); err != nil {
+		return nil, err
+	}
+	sort.Sort(noticesByTime(notices))
+	return notices, nil
+}
+
+func (db *UserDB) RemoveNotice(id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if !db.remove(noticesCollection, bson.M{"_id": id}) {
+		return userdb.NoticeNotFoundError(id)
+	}
+	return nil
+}
+
+func (db *UserDB) CreateServiceAccount(sa *userdb.ServiceAccount) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.findOne(serviceAccountsCollection, bson.M{"_id": sa.Name}) >= 0 {
+		return userdb.DuplicateServiceAccountError(sa.Name)
+	}
+	return db.insert(serviceAccountsCollection, sa)
+}
+
+func (db *UserDB) FindServiceAccount(tokenHash string) (*userdb.ServiceAccount, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	i := db.findOne(serviceAccountsCollection, bson.M{"tokenhash": tokenHash})
+	if i < 0 {
+		return nil, nil
+	}
+	sa := new(userdb.ServiceAccount)
+	return sa, fromDoc(db.colls[serviceAccountsCollection][i], sa)
+}
+
+func (db *UserDB) ListServiceAccounts() ([]*userdb.ServiceAccount, error) {
+	accounts := []*userdb.ServiceAccount{}
+	if err := db.list(serviceAccountsCollection, &accounts); err != nil {
+		return nil, err
+	}
+	sort.Sort(accountsByName(accounts))
+	return accounts, nil
+}
+
+func (db *UserDB) RemoveServiceAccount(name string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if !db.remove(serviceAccountsCollection, bson.M{"_id": name}) {
+		return userdb.ServiceAccountNotFoundError(name)
+	}
+	return nil
+}
+
+// backupRecord is a document in the backup stream, which is a sequence of
+// BSON documents, the same format as the MongoDB plugin.
+type backupRecord struct {
+	Collection string `bson:"c"`
+	Document   bson.M `bson:"d"`
+}
+
+// Backup writes all records except leases to the writer.
+func (db *UserDB) Backup(w io.Writer) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	names := make([]string, 0, len(db.colls))
+	for name := range db.colls {
+		if name != leasesCollection {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, doc := range db.colls[name] {
+			data, err := bson.Marshal(backupRecord{name, doc})
+			if err == nil {
+				_, err = w.Write(data)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Restore replaces all records with records read from a backup.
+func (db *UserDB) Restore(r io.Reader) error {
+	colls := make(map[string][]bson.M)
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		size := binary.LittleEndian.Uint32(header[:])
+		if size < 5 || size > 16*1024*1024 {
+			return errors.New("Invalid backup: bad document size")
+		}
+		data := make([]byte, size)
+		copy(data, header[:])
+		if _, err := io.ReadFull(r, data[4:]); err != nil {
+			return err
+		}
+
+		var rec backupRecord
+		if err := bson.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		colls[rec.Collection] = append(colls[rec.Collection], rec.Document)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.colls = colls
+	return nil
+}
+
+func (db *UserDB) Migrator() *migrate.Migrator {
+	return migrate.New("userdb", db)
+}
+
+// Version implements the migrate.Store interface, there is nothing to
+// migrate in an in-memory database.
+func (db *UserDB) Version() (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.version, nil
+}
+
+// SetVersion implements the migrate.Store interface.
+func (db *UserDB) SetVersion(version int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.version = version
+	return nil
+}
+
+func (db *UserDB) GetSecret(key string, gen func() []byte) ([]byte, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if i := db.findOne(secretCollection, bson.M{"_id": key}); i >= 0 {
+		secret, _ := db.colls[secretCollection][i]["secret"].([]byte)
+		return secret, nil
+	}
+	secret := gen()
+	db.colls[secretCollection] = append(db.colls[secretCollection], bson.M{"_id": key, "secret": secret})
+	return secret, nil
+}
+
+func (db *UserDB) Close() error {
+	return nil
+}
+
+func (db *UserDB) insert(coll string, v interface{}) error {
+	doc, err := toDoc(v)
+	if err != nil {
+		return err
+	}
+	db.colls[coll] = append(db.colls[coll], doc)
+	return nil
+}
+
+func (db *UserDB) findOne(coll string, filter bson.M) int {
+	for i, doc := range db.colls[coll] {
+		if matches(doc, filter) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (db *UserDB) findByID(coll, id string, result interface{}) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	i := db.findOne(coll, bson.M{"_id": id})
+	if i < 0 {
+		return false, nil
+	}
+	return true, fromDoc(db.colls[coll][i], result)
+}
+
+func (db *UserDB) list(coll string, result interface{}) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return fromDocs(db.colls[coll], reflect.ValueOf(result).Elem())
+}
+
+func (db *UserDB) update(coll string, filter bson.M, fields interface{}, notFound error) error {
+	i := db.findOne(coll, filter)
+	if i < 0 {
+		return notFound
+	}
+	values, err := toDoc(fields)
+	if err != nil {
+		return err
+	}
+	for path, v := range values {
+		set(db.colls[coll][i], path, v)
+	}
+	return nil
+}
+
+func (db *UserDB) remove(coll string, filter bson.M) bool {
+	i := db.findOne(coll, filter)
+	if i < 0 {
+		return false
+	}
+	docs := db.colls[coll]
+	db.colls[coll] = append(docs[:i:i], docs[i+1:]...)
+	return true
+}
+
+// toDoc converts a struct or map to a BSON document.
+func toDoc(v interface{}) (bson.M, error) {
+	doc := bson.M{}
+	if v == nil {
+		return doc, nil
+	}
+	data, err := bson.Marshal(v)
+	if err == nil {
+		err = bson.Unmarshal(data, &doc)
+	}
+	return doc, err
+}
+
+// toValue converts a value to the representation in a BSON document.
+func toValue(v interface{}) (interface{}, error) {
+	doc, err := toDoc(bson.M{"v": v})
+	return doc["v"], err
+}
+
+func fromDoc(doc bson.M, result interface{}) error {
+	data, err := bson.Marshal(doc)
+	if err == nil {
+		err = bson.Unmarshal(data, result)
+	}
+	return err
+}
+
+func fromDocs(docs []bson.M, slice reflect.Value) error {
+	elemType := slice.Type().Elem()
+	result := reflect.MakeSlice(slice.Type(), 0, len(docs))
+	for _, doc := range docs {
+		var elem reflect.Value
+		if elemType.Kind() == reflect.Ptr {
+			elem = reflect.New(elemType.Elem())
+		} else {
+			elem = reflect.New(elemType)
+		}
+		if err := fromDoc(doc, elem.Interface()); err != nil {
+			return err
+		}
+		if elemType.Kind() != reflect.Ptr {
+			elem = elem.Elem()
+		}
+		result = reflect.Append(result, elem)
+	}
+	slice.Set(result)
+	return nil
+}
+
+// lookup returns all values in the document at the dotted path, arrays in
+// the path are traversed.
+func lookup(v interface{}, path string) []interface{} {
+	key, rest := path, ""
+	if i := strings.IndexByte(path, '.'); i >= 0 {
+		key, rest = path[:i], path[i+1:]
+	}
+
+	switch v := v.(type) {
+	case bson.M:
+		child, ok := v[key]
+		if !ok {
+			return nil
+		}
+		if rest == "" {
+			// an array matches if any element matches
+			if elems, ok := child.([]interface{}); ok {
+				return append([]interface{}{child}, elems...)
+			}
+			return []interface{}{child}
+		}
+		return lookup(child, rest)
+	case []interface{}:
+		var result []interface{}
+		for _, elem := range v {
+			result = append(result, lookup(elem, path)...)
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+// set sets the value in the document at the dotted path, intermediate
+// documents are created if missing.
+func set(doc bson.M, path string, value interface{}) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := doc[key].(bson.M)
+		if !ok {
+			child = bson.M{}
+			doc[key] = child
+		}
+		doc = child
+	}
+	doc[keys[len(keys)-1]] = value
+}
+
+// unset removes the value in the document at the dotted path.
+func unset(doc bson.M, path string) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := doc[key].(bson.M)
+		if !ok {
+			return
+		}
+		doc = child
+	}
+	delete(doc, keys[len(keys)-1])
+}
+
+func matches(doc bson.M, filter bson.M) bool {
+	for path, cond := range filter {
+		values := lookup(doc, path)
+		if ops, ok := cond.(bson.M); ok && isOperator(ops) {
+			for op, arg := range ops {
+				if !matchOperator(values, op, arg) {
+					return false
+				}
+			}
+		} else if !matchAny(values, cond) {
+			return false
+		}
+	}
+	return true
+}
+
+func isOperator(cond bson.M) bool {
+	for k := range cond {
+		if strings.HasPrefix(k, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(values []interface{}, cond interface{}) bool {
+	if cond == nil && len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if c, ok := compare(v, cond); (ok && c == 0) || reflect.DeepEqual(v, cond) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchOperator(values []interface{}, op string, arg interface{}) bool {
+	switch op {
+	case "$exists":
+		exists, _ := arg.(bool)
+		return (len(values) != 0) == exists
+	case "$ne":
+		return !matchAny(values, arg)
+	}
+
+	for _, v := range values {
+		c, ok := compare(v, arg)
+		if !ok {
+			continue
+		}
+		switch {
+		case op == "$gt" && c > 0, op == "$gte" && c >= 0, op == "$lt" && c < 0, op == "$lte" && c <= 0:
+			return true
+		}
+	}
+	return false
+}
+
+// compare compares values of the same kind, returns false if the values
+// are not comparable.
+func compare(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			switch {
+			case a.Before(b):
+				return -1, true
+			case a.After(b):
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	default:
+		x, ok1 := number(a)
+		y, ok2 := number(b)
+		if ok1 && ok2 {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+var _ userdb.Plugin = (*UserDB)(nil)
@@ -0,0 +1,85 @@
+package memory_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	. "github.com/cloudway/platform/auth/userdb/memory"
+)
+
+func TestMemory(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Memory User Database Suite")
+}
+
+var _ = Describe("Memory user database", func() {
+	var db *UserDB
+
+	BeforeEach(func() {
+		db = New()
+		Expect(db.Create(&userdb.BasicUser{Name: "alice", Namespace: "a"})).To(Succeed())
+		Expect(db.Create(&userdb.BasicUser{Name: "bob", Namespace: "b"})).To(Succeed())
+	})
+
+	It("should refuse duplicate users and namespaces", func() {
+		Expect(db.Create(&userdb.BasicUser{Name: "alice"})).To(Equal(userdb.DuplicateUserError("alice")))
+		Expect(db.Create(&userdb.BasicUser{Name: "carol", Namespace: "a"})).To(Equal(userdb.DuplicateNamespaceError("a")))
+	})
+
+	It("should update and search nested fields", func() {
+		Expect(db.UpdateApplication("alice", "demo", 0, &userdb.Application{Plugins: []string{"mock"}})).To(Succeed())
+		Expect(db.UpdateApplication("alice", "demo", 0, &userdb.Application{})).To(BeAssignableToTypeOf(userdb.ApplicationConflictError("")))
+
+		var user userdb.BasicUser
+		Expect(db.Search(userdb.Args{"applications.demo.plugins": "mock"}, &user)).To(Succeed())
+		Expect(user.Name).To(Equal("alice"))
+		Expect(user.Applications["demo"].Version).To(Equal(1))
+
+		var users []userdb.BasicUser
+		Expect(db.Search(userdb.Args{}, &users)).To(Succeed())
+		Expect(users).To(HaveLen(2))
+	})
+
+	It("should update items of list fields", func() {
+		Expect(db.PushItem("alice", "sshkeys", &userdb.SSHKey{Fingerprint: "a"})).To(Succeed())
+		Expect(db.PushItem("alice", "sshkeys", &userdb.SSHKey{Fingerprint: "b"})).To(Succeed())
+
+		now := time.Now().Truncate(time.Millisecond)
+		Expect(db.UpdateItem("alice", "sshkeys", "fingerprint", "b", userdb.Args{"lastused": now})).To(BeTrue())
+		Expect(db.PullItem("alice", "sshkeys", "fingerprint", "a")).To(BeTrue())
+		Expect(db.PullItem("alice", "sshkeys", "fingerprint", "a")).To(BeFalse())
+
+		var user userdb.BasicUser
+		Expect(db.Find("alice", &user)).To(Succeed())
+		Expect(user.SSHKeys).To(HaveLen(1))
+		Expect(user.SSHKeys[0].Fingerprint).To(Equal("b"))
+		Expect(user.SSHKeys[0].LastUsed.Equal(now)).To(BeTrue())
+	})
+
+	It("should search with comparison operators", func() {
+		Expect(db.Update("bob", userdb.Args{"deleteat": time.Now().Add(-time.Hour)})).To(Succeed())
+
+		var users []userdb.BasicUser
+		filter := userdb.Args{"deleteat": userdb.Args{"$gt": time.Time{}, "$lte": time.Now()}}
+		Expect(db.Search(filter, &users)).To(Succeed())
+		Expect(users).To(HaveLen(1))
+		Expect(users[0].Name).To(Equal("bob"))
+	})
+
+	It("should restore records from backup", func() {
+		var buf bytes.Buffer
+		Expect(db.Backup(&buf)).To(Succeed())
+
+		restored := New()
+		Expect(restored.Restore(&buf)).To(Succeed())
+
+		var user userdb.BasicUser
+		Expect(restored.Find("bob", &user)).To(Succeed())
+		Expect(user.Namespace).To(Equal("b"))
+	})
+})
@@ -0,0 +1,109 @@
+package mongodb
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// backupCollections are collections saved in backups. Leases are not
+// saved as they are only held by running servers.
+var backupCollections = []string{"users", "invites", "notices", "serviceaccounts", "secret", migrationsCollection}
+
+// backupRecord is a document in the backup stream, which is a sequence of
+// BSON documents.
+type backupRecord struct {
+	Collection string   `bson:"c"`
+	Document   bson.Raw `bson:"d"`
+}
+
+func (db *mongodb) Backup(w io.Writer) error {
+	session := db.session.Copy()
+	defer session.Close()
+
+	for _, name := range backupCollections {
+		iter := session.DB("").C(name).Find(nil).Iter()
+		var doc bson.Raw
+		for iter.Next(&doc) {
+			data, err := bson.Marshal(backupRecord{name, doc})
+			if err == nil {
+				_, err = w.Write(data)
+			}
+			if err != nil {
+				iter.Close()
+				return err
+			}
+		}
+		if err := iter.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *mongodb) Restore(r io.Reader) error {
+	session := db.session.Copy()
+	defer session.Close()
+
+	// remove documents instead of dropping collections to keep indexes
+	for _, name := range backupCollections {
+		if _, err := session.DB("").C(name).RemoveAll(nil); err != nil {
+			return err
+		}
+	}
+
+	for {
+		data, err := readDocument(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var rec backupRecord
+		if err = bson.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		if !isBackupCollection(rec.Collection) {
+			return errors.New("Invalid backup: unknown collection " + rec.Collection)
+		}
+		if err = session.DB("").C(rec.Collection).Insert(rec.Document); err != nil {
+			return err
+		}
+	}
+}
+
+// readDocument reads a BSON document, which starts with the length of the
+// document as a little endian 32-bit integer.
+func readDocument(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.LittleEndian.Uint32(header[:])
+	if size < 5 || size > 16*1024*1024 {
+		return nil, errors.New("Invalid backup: bad document size")
+	}
+
+	data := make([]byte, size)
+	copy(data, header[:])
+	if _, err := io.ReadFull(r, data[4:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+func isBackupCollection(name string) bool {
+	for _, c := range backupCollections {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package mongodb
+
+import (
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/cloudway/platform/pkg/migrate"
+)
+
+// Schema versions of stores are saved in the "migrations" collection, one
+// document per store.
+const migrationsCollection = "migrations"
+
+// migrations evolve documents and indexes of the user database. New
+// migrations must be appended with increasing versions, and released
+// migrations must not be changed.
+func (db *mongodb) migrations() []*migrate.Migration {
+	return []*migrate.Migration{
+		{
+			Version:     1,
+			Description: "Index start time of notices",
+			Up: func() error {
+				return db.withCollection("notices", func(c *mgo.Collection) error {
+					return c.EnsureIndexKey("starts")
+				})
+			},
+			Down: func() error {
+				return db.withCollection("notices", func(c *mgo.Collection) error {
+					return c.DropIndex("starts")
+				})
+			},
+		},
+		{
+			Version:     2,
+			Description: "Remove expired invitations automatically",
+			Up: func() error {
+				return db.withCollection("invites", func(c *mgo.Collection) error {
+					return c.EnsureIndex(mgo.Index{Key: []string{"expires"}, ExpireAfter: time.Second})
+				})
+			},
+			Down: func() error {
+				return db.withCollection("invites", func(c *mgo.Collection) error {
+					return c.DropIndex("expires")
+				})
+			},
+		},
+		{
+			Version:     3,
+			Description: "Index token hash of service accounts",
+			Up: func() error {
+				return db.withCollection("serviceaccounts", func(c *mgo.Collection) error {
+					return c.EnsureIndex(mgo.Index{Key: []string{"tokenhash"}, Unique: true})
+				})
+			},
+			Down: func() error {
+				return db.withCollection("serviceaccounts", func(c *mgo.Collection) error {
+					return c.DropIndex("tokenhash")
+				})
+			},
+		},
+	}
+}
+
+func (db *mongodb) Migrator() *migrate.Migrator {
+	return migrate.New("userdb", &schemaStore{db, "userdb"}, db.migrations()...)
+}
+
+// baseline marks a new database as up to date, there is nothing to migrate
+// in an empty database.
+func (db *mongodb) baseline() error {
+	session := db.session.Copy()
+	defer session.Close()
+
+	n, err := session.DB("").C(migrationsCollection).FindId("userdb").Count()
+	if err != nil || n != 0 {
+		return err
+	}
+	if n, err = session.DB("").C("users").Count(); err != nil || n != 0 {
+		return err
+	}
+
+	return db.Migrator().Up(-1, ioutil.Discard)
+}
+
+func (db *mongodb) withCollection(name string, fn func(*mgo.Collection) error) error {
+	session := db.session.Copy()
+	defer session.Close()
+	return fn(session.DB("").C(name))
+}
+
+type schemaStore struct {
+	db   *mongodb
+	name string
+}
+
+func (s *schemaStore) Version() (int, error) {
+	var doc struct{ Version int }
+	err := s.db.withCollection(migrationsCollection, func(c *mgo.Collection) error {
+		return c.FindId(s.name).One(&doc)
+	})
+	if err == mgo.ErrNotFound {
+		err = nil
+	}
+	return doc.Version, err
+}
+
+func (s *schemaStore) SetVersion(version int) error {
+	return s.db.withCollection(migrationsCollection, func(c *mgo.Collection) error {
+		_, err := c.UpsertId(s.name, bson.M{"$set": bson.M{"version": version, "updated": time.Now()}})
+		return err
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -56,7 +57,12 @@ func init() {
 			return nil, err
 		}
 
-		return &mongodb{session}, nil
+		db := &mongodb{session}
+		if err = db.baseline(); err != nil {
+			session.Close()
+			return nil, err
+		}
+		return db, nil
 	}
 }
 
@@ -165,6 +171,299 @@ func (db *mongodb) Update(name string, fields interface{}) error {
 	return err
 }
 
+func (db *mongodb) Rename(name, newName string) error {
+	users := db.acquire()
+	defer db.release(users)
+
+	err := users.Update(bson.M{"name": name}, bson.M{"$set": bson.M{"name": newName}})
+	if err == mgo.ErrNotFound {
+		err = userdb.UserNotFoundError(name)
+	} else if mgo.IsDup(err) {
+		err = userdb.DuplicateUserError(newName)
+	}
+	return err
+}
+
+func (db *mongodb) PushItem(name, field string, item interface{}) error {
+	users := db.acquire()
+	defer db.release(users)
+
+	// the field cannot be null to push an item
+	err := users.Update(
+		bson.M{"name": name, field: nil},
+		bson.M{"$set": bson.M{field: []interface{}{}}})
+	if err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+
+	err = users.Update(bson.M{"name": name}, bson.M{"$push": bson.M{field: item}})
+	if err == mgo.ErrNotFound {
+		err = userdb.UserNotFoundError(name)
+	}
+	return err
+}
+
+func (db *mongodb) PullItem(name, field, key string, value interface{}) (bool, error) {
+	users := db.acquire()
+	defer db.release(users)
+
+	err := users.Update(
+		bson.M{"name": name, field + "." + key: value},
+		bson.M{"$pull": bson.M{field: bson.M{key: value}}})
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (db *mongodb) UpdateItem(name, field, key string, value interface{}, fields interface{}) (bool, error) {
+	users := db.acquire()
+	defer db.release(users)
+
+	var values bson.M
+	data, err := bson.Marshal(fields)
+	if err == nil {
+		err = bson.Unmarshal(data, &values)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// update the matched item with the positional operator
+	update := bson.M{}
+	for k, v := range values {
+		update[field+".$."+k] = v
+	}
+	err = users.Update(
+		bson.M{"name": name, field + "." + key: value},
+		bson.M{"$set": update})
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (db *mongodb) UpdateApplication(username, name string, version int, app *userdb.Application) error {
+	users := db.acquire()
+	defer db.release(users)
+
+	field := "applications." + name
+	selector := bson.M{"name": username}
+	switch {
+	case version == 0:
+		selector[field+".version"] = bson.M{"$exists": false}
+	case version > 0:
+		selector[field+".version"] = version
+	}
+
+	var update bson.M
+	if app == nil {
+		update = bson.M{"$unset": bson.M{field: ""}}
+	} else {
+		saved := *app
+		if version >= 0 {
+			saved.Version = version + 1
+		} else {
+			saved.Version++
+		}
+		update = bson.M{"$set": bson.M{field: &saved}}
+
+		// the applications field cannot be null to set a nested field
+		err := users.Update(
+			bson.M{"name": username, "applications": nil},
+			bson.M{"$set": bson.M{"applications": bson.M{}}})
+		if err != nil && err != mgo.ErrNotFound {
+			return err
+		}
+	}
+
+	err := users.Update(selector, update)
+	if err == mgo.ErrNotFound {
+		if n, er := users.Find(bson.M{"name": username}).Count(); er != nil || n == 0 {
+			err = userdb.UserNotFoundError(username)
+		} else {
+			err = userdb.ApplicationConflictError(name)
+		}
+	}
+	return err
+}
+
+func (db *mongodb) AcquireLease(lease *userdb.Lease) (bool, error) {
+	session := db.session.Copy()
+	c := session.DB("").C("leases")
+	defer session.Close()
+
+	// renew the lease held by the same holder
+	err := c.Update(
+		bson.M{"_id": lease.Name, "holder": lease.Holder},
+		bson.M{"$set": bson.M{"value": lease.Value, "expires": lease.Expires}})
+	if err != mgo.ErrNotFound {
+		return err == nil, err
+	}
+
+	// take over the expired lease, or create a new lease, which fails
+	// with duplicate key if the lease is held by other holder
+	_, err = c.Upsert(bson.M{"_id": lease.Name, "expires": bson.M{"$lt": time.Now()}}, lease)
+	if mgo.IsDup(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (db *mongodb) ReleaseLease(name, holder string) error {
+	session := db.session.Copy()
+	c := session.DB("").C("leases")
+	defer session.Close()
+
+	err := c.Remove(bson.M{"_id": name, "holder": holder})
+	if err == mgo.ErrNotFound {
+		err = nil
+	}
+	return err
+}
+
+func (db *mongodb) FindLease(name string) (*userdb.Lease, error) {
+	session := db.session.Copy()
+	c := session.DB("").C("leases")
+	defer session.Close()
+
+	lease := new(userdb.Lease)
+	err := c.FindId(name).One(lease)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+func (db *mongodb) CreateInvite(inv *userdb.Invite) error {
+	session := db.session.Copy()
+	c := session.DB("").C("invites")
+	defer session.Close()
+
+	return c.Insert(inv)
+}
+
+func (db *mongodb) FindInvite(id string) (*userdb.Invite, error) {
+	session := db.session.Copy()
+	c := session.DB("").C("invites")
+	defer session.Close()
+
+	inv := new(userdb.Invite)
+	err := c.FindId(id).One(inv)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+func (db *mongodb) ListInvites() ([]*userdb.Invite, error) {
+	session := db.session.Copy()
+	c := session.DB("").C("invites")
+	defer session.Close()
+
+	invites := []*userdb.Invite{}
+	err := c.Find(nil).Sort("createdat").All(&invites)
+	return invites, err
+}
+
+func (db *mongodb) RemoveInvite(id string) error {
+	session := db.session.Copy()
+	c := session.DB("").C("invites")
+	defer session.Close()
+
+	err := c.RemoveId(id)
+	if err == mgo.ErrNotFound {
+		err = userdb.InvalidInviteError{}
+	}
+	return err
+}
+
+func (db *mongodb) CreateNotice(n *userdb.Notice) error {
+	session := db.session.Copy()
+	c := session.DB("").C("notices")
+	defer session.Close()
+
+	return c.Insert(n)
+}
+
+func (db *mongodb) ListNotices() ([]*userdb.Notice, error) {
+	session := db.session.Copy()
+	c := session.DB("").C("notices")
+	defer session.Close()
+
+	notices := []*userdb.Notice{}
+	err := c.Find(nil).Sort("starts").All(&notices)
+	return notices, err
+}
+
+func (db *mongodb) RemoveNotice(id string) error {
+	session := db.session.Copy()
+	c := session.DB("").C("notices")
+	defer session.Close()
+
+	err := c.RemoveId(id)
+	if err == mgo.ErrNotFound {
+		err = userdb.NoticeNotFoundError(id)
+	}
+	return err
+}
+
+func (db *mongodb) CreateServiceAccount(sa *userdb.ServiceAccount) error {
+	session := db.session.Copy()
+	c := session.DB("").C("serviceaccounts")
+	defer session.Close()
+
+	err := c.Insert(sa)
+	if mgo.IsDup(err) {
+		err = userdb.DuplicateServiceAccountError(sa.Name)
+	}
+	return err
+}
+
+func (db *mongodb) FindServiceAccount(tokenHash string) (*userdb.ServiceAccount, error) {
+	session := db.session.Copy()
+	c := session.DB("").C("serviceaccounts")
+	defer session.Close()
+
+	sa := new(userdb.ServiceAccount)
+	err := c.Find(bson.M{"tokenhash": tokenHash}).One(sa)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sa, nil
+}
+
+func (db *mongodb) ListServiceAccounts() ([]*userdb.ServiceAccount, error) {
+	session := db.session.Copy()
+	c := session.DB("").C("serviceaccounts")
+	defer session.Close()
+
+	accounts := []*userdb.ServiceAccount{}
+	err := c.Find(nil).Sort("_id").All(&accounts)
+	return accounts, err
+}
+
+func (db *mongodb) RemoveServiceAccount(name string) error {
+	session := db.session.Copy()
+	c := session.DB("").C("serviceaccounts")
+	defer session.Close()
+
+	err := c.RemoveId(name)
+	if err == mgo.ErrNotFound {
+		err = userdb.ServiceAccountNotFoundError(name)
+	}
+	return err
+}
+
 func (db *mongodb) GetSecret(key string, gen func() []byte) ([]byte, error) {
 	session := db.session.Copy()
 	c := session.DB("").C("secret")
@@ -182,6 +481,12 @@ func (db *mongodb) GetSecret(key string, gen func() []byte) ([]byte, error) {
 	return record.Secret, err
 }
 
+func (db *mongodb) Ping() error {
+	session := db.session.Copy()
+	defer session.Close()
+	return session.Ping()
+}
+
 func (db *mongodb) Close() error {
 	db.session.Close()
 	return nil
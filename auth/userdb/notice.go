@@ -0,0 +1,125 @@
+package userdb
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Kinds of notices.
+const (
+	NoticeInfo        = "info"
+	NoticeWarning     = "warning"
+	NoticeMaintenance = "maintenance"
+	NoticeTerms       = "tos"
+)
+
+// Notice is an announcement shown to all users, such as a scheduled
+// maintenance window or an update of the terms of service.
+type Notice struct {
+	ID      string `bson:"_id"`
+	Kind    string
+	Message string
+
+	// The link to details of the notice, such as the updated terms of
+	// service.
+	URL string `bson:",omitempty"`
+
+	// The notice is shown from the start time until it expires. It never
+	// expires if the expiration time is zero.
+	Starts  time.Time
+	Expires time.Time
+
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+// Active returns true if the notice should be shown at the given time.
+func (n *Notice) Active(now time.Time) bool {
+	return !now.Before(n.Starts) && (n.Expires.IsZero() || now.Before(n.Expires))
+}
+
+// The InvalidNoticeError indicates that a notice has invalid fields.
+type InvalidNoticeError string
+
+func (e InvalidNoticeError) Error() string {
+	return string(e)
+}
+
+func (e InvalidNoticeError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// The NoticeNotFoundError indicates that a notice does not exist.
+type NoticeNotFoundError string
+
+func (e NoticeNotFoundError) Error() string {
+	return fmt.Sprintf("Notice %s not found", string(e))
+}
+
+func (e NoticeNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+// CreateNotice saves the notice with a generated ID. The notice is shown
+// immediately if the start time is zero. The message is kept in a single
+// line so it can be sent in response headers.
+func (db *UserDatabase) CreateNotice(n *Notice) error {
+	switch n.Kind {
+	case "":
+		n.Kind = NoticeInfo
+	case NoticeInfo, NoticeWarning, NoticeMaintenance, NoticeTerms:
+	default:
+		return InvalidNoticeError("Invalid notice kind: " + n.Kind)
+	}
+
+	n.Message = strings.Join(strings.Fields(n.Message), " ")
+	if n.Message == "" {
+		return InvalidNoticeError("The notice message is required")
+	}
+
+	n.CreatedAt = time.Now()
+	if n.Starts.IsZero() {
+		n.Starts = n.CreatedAt
+	}
+	if !n.Expires.IsZero() && !n.Expires.After(n.Starts) {
+		return InvalidNoticeError("The notice expires before it starts")
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	n.ID = hex.EncodeToString(b)
+	return db.plugin.CreateNotice(n)
+}
+
+// ListNotices returns all notices including scheduled and expired ones.
+func (db *UserDatabase) ListNotices() ([]*Notice, error) {
+	return db.plugin.ListNotices()
+}
+
+// ActiveNotices returns notices that should be shown now.
+func (db *UserDatabase) ActiveNotices() ([]*Notice, error) {
+	notices, err := db.plugin.ListNotices()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	active := notices[:0]
+	for _, n := range notices {
+		if n.Active(now) {
+			active = append(active, n)
+		}
+	}
+	return active, nil
+}
+
+// RemoveNotice removes the notice with the given ID.
+func (db *UserDatabase) RemoveNotice(id string) error {
+	return db.plugin.RemoveNotice(id)
+}
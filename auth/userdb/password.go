@@ -0,0 +1,191 @@
+package userdb
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/cloudway/platform/config"
+)
+
+// pwnedRangeURL is the range API of Pwned Passwords. Only the first five
+// characters of the password hash are sent to the service.
+const pwnedRangeURL = "https://api.pwnedpasswords.com/range/"
+
+// bcrypt only uses the first 72 bytes of a password.
+const maxPasswordBytes = 72
+
+// commonPasswords are always denied.
+var commonPasswords = []string{
+	"password", "passw0rd", "12345678", "123456789", "1234567890",
+	"11111111", "88888888", "00000000", "qwertyui", "qwerty123",
+	"abcd1234", "abc12345", "iloveyou", "1qaz2wsx", "password1",
+	"admin123", "welcome1", "cloudway",
+}
+
+// The InvalidPasswordError indicates that a password doesn't conform to the
+// password policy.
+type InvalidPasswordError string
+
+func (e InvalidPasswordError) Error() string {
+	return string(e)
+}
+
+func (e InvalidPasswordError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// PasswordPolicy describes rules of user passwords.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+
+	// The minimum number of character classes, which are lower case
+	// letters, upper case letters, digits and other characters.
+	MinClasses int
+
+	// Passwords that are denied, in lower case.
+	DenyList map[string]bool
+
+	// The URL of the Pwned Passwords range API to check breached
+	// passwords. The check is disabled if empty.
+	PwnedURL string
+}
+
+// GetPasswordPolicy returns the password policy from the "password" section
+// of the configuration. The deny list is read from the file configured by
+// "password.deny_list", one password per line, in addition to a built-in
+// list of common passwords.
+func GetPasswordPolicy() *PasswordPolicy {
+	p := &PasswordPolicy{
+		MinLength: 8,
+		MaxLength: 64,
+		DenyList:  make(map[string]bool),
+	}
+
+	if n, err := strconv.Atoi(config.Get("password.min_length")); err == nil && n > 0 {
+		p.MinLength = n
+	}
+	if n, err := strconv.Atoi(config.Get("password.max_length")); err == nil && n > 0 {
+		p.MaxLength = n
+	}
+	if n, err := strconv.Atoi(config.Get("password.min_classes")); err == nil {
+		p.MinClasses = n
+	}
+	if b, _ := strconv.ParseBool(config.Get("password.check_pwned")); b {
+		p.PwnedURL = pwnedRangeURL
+	}
+
+	for _, pw := range commonPasswords {
+		p.DenyList[pw] = true
+	}
+	if path := config.Get("password.deny_list"); path != "" {
+		if err := p.readDenyList(path); err != nil {
+			logrus.WithError(err).Warn("Failed to read password deny list")
+		}
+	}
+	return p
+}
+
+func (p *PasswordPolicy) readDenyList(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && line[0] != '#' {
+			p.DenyList[strings.ToLower(line)] = true
+		}
+	}
+	return scanner.Err()
+}
+
+// Check returns InvalidPasswordError if the password doesn't conform to the
+// policy. The password is accepted if the breach check fails, so users are
+// not locked out when the service is unavailable.
+func (p *PasswordPolicy) Check(password string) error {
+	n := utf8.RuneCountInString(password)
+	if n < p.MinLength {
+		return InvalidPasswordError(fmt.Sprintf("Password must have at least %d characters", p.MinLength))
+	}
+	if n > p.MaxLength || len(password) > maxPasswordBytes {
+		return InvalidPasswordError(fmt.Sprintf("Password must have at most %d characters", p.MaxLength))
+	}
+	if classes := charClasses(password); classes < p.MinClasses {
+		return InvalidPasswordError(fmt.Sprintf("Password must contain at least %d kinds of characters: "+
+			"lower case letters, upper case letters, digits and symbols", p.MinClasses))
+	}
+	if p.DenyList[strings.ToLower(password)] {
+		return InvalidPasswordError("Password is too common")
+	}
+
+	if p.PwnedURL != "" {
+		pwned, err := isPwned(p.PwnedURL, password)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to check breached password")
+		} else if pwned {
+			return InvalidPasswordError("Password has appeared in a data breach, please choose another one")
+		}
+	}
+	return nil
+}
+
+// CheckPassword checks the password against the configured password policy.
+func CheckPassword(password string) error {
+	return GetPasswordPolicy().Check(password)
+}
+
+func charClasses(password string) int {
+	var lower, upper, digit, other int
+	for _, c := range password {
+		switch {
+		case unicode.IsLower(c):
+			lower = 1
+		case unicode.IsUpper(c):
+			upper = 1
+		case unicode.IsDigit(c):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	return lower + upper + digit + other
+}
+
+// isPwned checks the password with the k-anonymity model, only the prefix
+// of the password hash is sent and the matching suffixes are returned.
+func isPwned(rangeURL, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(rangeURL + hash[:5])
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("Unexpected response from %s: %s", rangeURL, resp.Status)
+	}
+
+	suffix := hash[5:] + ":"
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if strings.HasPrefix(strings.ToUpper(scanner.Text()), suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,76 @@
+package userdb
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Console themes, the auto theme follows the color scheme of the system.
+const (
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+	ThemeAuto  = "auto"
+)
+
+// Table densities of the console.
+const (
+	DensityComfortable = "comfortable"
+	DensityCompact     = "compact"
+)
+
+// Preferences are user interface settings shared by the console and the
+// command line tool. Empty fields use the defaults of the client.
+type Preferences struct {
+	Theme  string `bson:",omitempty"`
+	Locale string `bson:",omitempty"`
+
+	// The label key to group applications by in the application list of
+	// the namespace, applications are not grouped if empty.
+	NamespaceView string `bson:",omitempty"`
+
+	TableDensity string `bson:",omitempty"`
+}
+
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+
+// The InvalidPreferenceError indicates that a preference has invalid value.
+type InvalidPreferenceError string
+
+func (e InvalidPreferenceError) Error() string {
+	return string(e)
+}
+
+func (e InvalidPreferenceError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Validate checks values of preferences.
+func (p *Preferences) Validate() error {
+	switch p.Theme {
+	case "", ThemeLight, ThemeDark, ThemeAuto:
+	default:
+		return InvalidPreferenceError(fmt.Sprintf("Invalid theme %q, must be one of light, dark or auto", p.Theme))
+	}
+	if p.Locale != "" && !localePattern.MatchString(p.Locale) {
+		return InvalidPreferenceError(fmt.Sprintf("Invalid locale %q, must be in the form of zh-CN", p.Locale))
+	}
+	if len(p.NamespaceView) > 63 || strings.ContainsAny(p.NamespaceView, "= \t\r\n") {
+		return InvalidPreferenceError(fmt.Sprintf("Invalid namespace view %q, must be a label key", p.NamespaceView))
+	}
+	switch p.TableDensity {
+	case "", DensityComfortable, DensityCompact:
+	default:
+		return InvalidPreferenceError(fmt.Sprintf("Invalid table density %q, must be one of comfortable or compact", p.TableDensity))
+	}
+	return nil
+}
+
+// SetPreferences replaces preferences of the user.
+func (db *UserDatabase) SetPreferences(name string, prefs *Preferences) error {
+	if err := prefs.Validate(); err != nil {
+		return err
+	}
+	return db.Update(name, Args{"preferences": prefs})
+}
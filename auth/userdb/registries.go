@@ -0,0 +1,75 @@
+package userdb
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Registry holds the credentials of a private Docker registry used to pull
+// images of applications in the namespace of the user.
+type Registry struct {
+	Server    string
+	Username  string
+	Password  string
+	CreatedAt time.Time
+}
+
+var registryServerPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]*[a-z0-9])?(:[0-9]{1,5})?$`)
+
+// The InvalidRegistryError indicates that the registry server or the
+// credentials are invalid.
+type InvalidRegistryError string
+
+func (e InvalidRegistryError) Error() string {
+	return string(e)
+}
+
+func (e InvalidRegistryError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// The RegistryNotFoundError indicates that no credentials are saved for
+// the registry server.
+type RegistryNotFoundError string
+
+func (e RegistryNotFoundError) Error() string {
+	return fmt.Sprintf("Registry not found: %s", string(e))
+}
+
+func (e RegistryNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+// AddRegistry saves the credentials of a registry server. The credentials
+// replace the existing one for the same server.
+func (db *UserDatabase) AddRegistry(name string, reg *Registry) error {
+	reg.Server = strings.ToLower(strings.TrimSpace(reg.Server))
+	reg.Username = strings.TrimSpace(reg.Username)
+	if !registryServerPattern.MatchString(reg.Server) {
+		return InvalidRegistryError("Invalid registry server: " + reg.Server)
+	}
+	if reg.Username == "" || reg.Password == "" {
+		return InvalidRegistryError("The username and password of registry must be provided")
+	}
+
+	reg.CreatedAt = time.Now()
+	defer db.invalidate(name)
+	if _, err := db.plugin.PullItem(name, "registries", "server", reg.Server); err != nil {
+		return err
+	}
+	return db.plugin.PushItem(name, "registries", reg)
+}
+
+// RemoveRegistry removes the credentials of a registry server.
+func (db *UserDatabase) RemoveRegistry(name, server string) error {
+	server = strings.ToLower(server)
+	defer db.invalidate(name)
+	removed, err := db.plugin.PullItem(name, "registries", "server", server)
+	if err == nil && !removed {
+		err = RegistryNotFoundError(server)
+	}
+	return err
+}
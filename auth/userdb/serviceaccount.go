@@ -0,0 +1,137 @@
+package userdb
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ServiceAccountTokenPrefix distinguishes service account tokens from user
+// tokens.
+const ServiceAccountTokenPrefix = "cwsa_"
+
+// Scopes of service accounts.
+const (
+	ScopeRead   = "read"
+	ScopeUpload = "upload"
+	ScopeDeploy = "deploy"
+)
+
+// DefaultScopes are granted to service accounts created without scopes,
+// which is enough for CI pipelines to upload and deploy applications.
+var DefaultScopes = []string{ScopeUpload, ScopeDeploy}
+
+var serviceAccountPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// ServiceAccount is a machine account bound to a namespace, or a single
+// application in the namespace. Service accounts authenticate with tokens
+// only and can't log into the console. Only the hash of the token is saved.
+type ServiceAccount struct {
+	Name        string `bson:"_id"`
+	Namespace   string
+	Application string `bson:",omitempty"`
+	Scopes      []string
+	TokenHash   string
+	CreatedBy   string
+	CreatedAt   time.Time
+}
+
+// Allows returns true if the service account is granted the scope on the
+// application.
+func (sa *ServiceAccount) Allows(scope, app string) bool {
+	if sa.Application != "" && sa.Application != app {
+		return false
+	}
+	for _, s := range sa.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// The InvalidServiceAccountError indicates that a service account has an
+// invalid name or scope.
+type InvalidServiceAccountError string
+
+func (e InvalidServiceAccountError) Error() string {
+	return string(e)
+}
+
+func (e InvalidServiceAccountError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// The DuplicateServiceAccountError indicates that a service account with
+// the same name already exists.
+type DuplicateServiceAccountError string
+
+func (e DuplicateServiceAccountError) Error() string {
+	return fmt.Sprintf("Service account already exists: %s", string(e))
+}
+
+func (e DuplicateServiceAccountError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+// The ServiceAccountNotFoundError indicates that a service account does not
+// exist.
+type ServiceAccountNotFoundError string
+
+func (e ServiceAccountNotFoundError) Error() string {
+	return fmt.Sprintf("Service account not found: %s", string(e))
+}
+
+func (e ServiceAccountNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+// CreateServiceAccount saves the service account and returns its token,
+// which can't be retrieved again.
+func (db *UserDatabase) CreateServiceAccount(sa *ServiceAccount) (token string, err error) {
+	if !serviceAccountPattern.MatchString(sa.Name) {
+		return "", InvalidServiceAccountError("Invalid service account name: " + sa.Name)
+	}
+	if len(sa.Scopes) == 0 {
+		sa.Scopes = DefaultScopes
+	}
+	for _, s := range sa.Scopes {
+		if s != ScopeRead && s != ScopeUpload && s != ScopeDeploy {
+			return "", InvalidServiceAccountError("Invalid scope: " + s)
+		}
+	}
+
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", err
+	}
+	token = ServiceAccountTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	sa.TokenHash = hashToken(token)
+	sa.CreatedAt = time.Now()
+	return token, db.plugin.CreateServiceAccount(sa)
+}
+
+// FindServiceAccount returns the service account with the token, or nil if
+// the token is invalid.
+func (db *UserDatabase) FindServiceAccount(token string) (*ServiceAccount, error) {
+	if !strings.HasPrefix(token, ServiceAccountTokenPrefix) {
+		return nil, nil
+	}
+	return db.plugin.FindServiceAccount(hashToken(token))
+}
+
+// ListServiceAccounts returns all service accounts.
+func (db *UserDatabase) ListServiceAccounts() ([]*ServiceAccount, error) {
+	return db.plugin.ListServiceAccounts()
+}
+
+// RemoveServiceAccount removes the service account, its token is revoked
+// immediately.
+func (db *UserDatabase) RemoveServiceAccount(name string) error {
+	return db.plugin.RemoveServiceAccount(name)
+}
@@ -0,0 +1,154 @@
+package userdb
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AccessTokenPrefix distinguishes personal access tokens from login and
+// service account tokens.
+const AccessTokenPrefix = "cwpat_"
+
+// ScopeAll grants an access token full access of the user, the same as the
+// token obtained by login.
+const ScopeAll = "all"
+
+// The last used time of access tokens is saved at most once in this
+// interval, to avoid writing the database on every request.
+const accessTokenTouchInterval = time.Minute
+
+// AccessToken is a personal API token of a user for scripts and tools that
+// can't login with password. Tokens without the "all" scope can only access
+// applications in the namespace of the user with the granted scopes. Only
+// the hash of the token is saved.
+type AccessToken struct {
+	ID        string
+	Label     string
+	Scopes    []string
+	TokenHash string
+	CreatedAt time.Time
+	LastUsed  time.Time `bson:",omitempty"`
+}
+
+// HasScope returns true if the token is granted the scope.
+func (t *AccessToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// The InvalidAccessTokenError indicates that an access token has an invalid
+// label or scope.
+type InvalidAccessTokenError string
+
+func (e InvalidAccessTokenError) Error() string {
+	return string(e)
+}
+
+func (e InvalidAccessTokenError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// The AccessTokenNotFoundError indicates that an access token does not
+// exist.
+type AccessTokenNotFoundError string
+
+func (e AccessTokenNotFoundError) Error() string {
+	return fmt.Sprintf("Access token not found: %s", string(e))
+}
+
+func (e AccessTokenNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+// CreateAccessToken creates an access token of the user and returns the
+// token, which can't be retrieved again.
+func (db *UserDatabase) CreateAccessToken(name, label string, scopes []string) (string, *AccessToken, error) {
+	label = strings.TrimSpace(label)
+	if label == "" || len(label) > 64 {
+		return "", nil, InvalidAccessTokenError("The label of access token must be 1 to 64 characters")
+	}
+	if len(scopes) == 0 {
+		return "", nil, InvalidAccessTokenError("At least one scope must be granted to the access token")
+	}
+	for _, s := range scopes {
+		if s != ScopeAll && s != ScopeRead && s != ScopeUpload && s != ScopeDeploy {
+			return "", nil, InvalidAccessTokenError("Invalid scope: " + s)
+		}
+	}
+
+	b := make([]byte, 40)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	token := AccessTokenPrefix + base64.RawURLEncoding.EncodeToString(b[8:])
+
+	t := &AccessToken{
+		ID:        hex.EncodeToString(b[:8]),
+		Label:     label,
+		Scopes:    scopes,
+		TokenHash: hashToken(token),
+		CreatedAt: time.Now(),
+	}
+	defer db.invalidate(name)
+	if err := db.plugin.PushItem(name, "accesstokens", t); err != nil {
+		return "", nil, err
+	}
+	return token, t, nil
+}
+
+// RemoveAccessToken removes the access token with the given ID, the token
+// is revoked immediately.
+func (db *UserDatabase) RemoveAccessToken(name, id string) error {
+	defer db.invalidate(name)
+	removed, err := db.plugin.PullItem(name, "accesstokens", "id", id)
+	if err == nil && !removed {
+		err = AccessTokenNotFoundError(id)
+	}
+	return err
+}
+
+// AuthorizeAccessToken returns the user who owns the access token and the
+// token, and records the time that the token was last used. Returns nil if
+// the token is invalid.
+func (db *UserDatabase) AuthorizeAccessToken(token string) (*BasicUser, *AccessToken, error) {
+	if !strings.HasPrefix(token, AccessTokenPrefix) {
+		return nil, nil, nil
+	}
+
+	hash := hashToken(token)
+	var user BasicUser
+	if err := db.Search(Args{"accesstokens.tokenhash": hash}, &user); err != nil {
+		if IsUserNotFound(err) {
+			err = nil
+		}
+		return nil, nil, err
+	}
+	if user.Inactive {
+		return nil, nil, InactiveUserError(user.Name)
+	}
+
+	for _, t := range user.AccessTokens {
+		if t.TokenHash != hash {
+			continue
+		}
+		if now := time.Now(); now.Sub(t.LastUsed) > accessTokenTouchInterval {
+			t.LastUsed = now
+			_, err := db.plugin.UpdateItem(user.Name, "accesstokens", "id", t.ID, Args{"lastused": now})
+			db.invalidate(user.Name)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		return &user, t, nil
+	}
+	return nil, nil, nil
+}
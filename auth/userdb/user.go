@@ -18,15 +18,199 @@ type BasicUser struct {
 	Password     []byte
 	Inactive     bool
 	Applications map[string]*Application
+	SSHKeys      []*SSHKey `bson:",omitempty"`
+
+	// Personal access tokens of the user for the API.
+	AccessTokens []*AccessToken `bson:",omitempty"`
+
+	// Credentials of private registries to pull images of applications.
+	Registries []*Registry `bson:",omitempty"`
+
+	// The subdomain zone delegated to the namespace, applications are
+	// served at "<name>.<domain>" instead of under the platform domain.
+	Domain string `bson:",omitempty"`
+
+	// Services created in the namespace that are not owned by any
+	// application, such as a database shared by applications.
+	Services map[string]*StandaloneService `bson:",omitempty"`
+
+	// The maximum disk space of each application in bytes, overrides the
+	// "app.disk_quota" option if not zero.
+	DiskQuota int64 `bson:",omitempty"`
+
+	// Consecutive failed login attempts and the time until which the
+	// account is locked.
+	FailedLogins int       `bson:",omitempty"`
+	LockedUntil  time.Time `bson:",omitempty"`
+
+	// The new email address waiting for confirmation with the token.
+	PendingEmail     string    `bson:",omitempty"`
+	EmailToken       string    `bson:",omitempty"`
+	EmailTokenExpiry time.Time `bson:",omitempty"`
+
+	// The time to delete the account, which can be canceled before.
+	DeleteAt time.Time `bson:",omitempty"`
+
+	// User interface preferences shared by the console and cwcli.
+	Preferences Preferences `bson:",omitempty"`
 }
 
 type Application struct {
+	CreatedAt   time.Time
+	Plugins     []string
+	Hosts       []string `bson:",omitempty"`
+	Secret      string
+	Maintenance *Maintenance `bson:",omitempty"`
+	Protected   bool         `bson:",omitempty"`
+
+	// User defined labels to organize and select applications.
+	Labels map[string]string `bson:",omitempty"`
+
+	// The desired number of framework containers and environment
+	// variables, restored when the application is reconciled.
+	Scaling int               `bson:",omitempty"`
+	Env     map[string]string `bson:",omitempty"`
+
+	// Users granted access to the application other than the owner.
+	Collaborators []Collaborator `bson:",omitempty"`
+
+	// Services of other applications in the same namespace linked into
+	// the application.
+	Links []ServiceLink `bson:",omitempty"`
+
+	// Deployments are refused while locked, or outside of the deploy
+	// windows if any, such as "Mon-Fri 09:00-17:00".
+	DeployLock    *DeployLock `bson:",omitempty"`
+	DeployWindows []string    `bson:",omitempty"`
+
+	// The canary release in progress, which routes part of the traffic
+	// to containers running a new build.
+	Canary *Canary `bson:",omitempty"`
+
+	// Access restrictions enforced by the proxy.
+	AccessPolicy *AccessPolicy `bson:",omitempty"`
+
+	// HTTPS redirect, HSTS and custom error pages applied by the proxy.
+	HTTPSettings *HTTPSettings `bson:",omitempty"`
+
+	// Log shipping configuration applied to containers.
+	Logging *LogConfig `bson:",omitempty"`
+
+	// Version is incremented each time the record is saved, and is used
+	// to detect concurrent modifications.
+	Version int `bson:",omitempty"`
+}
+
+// StandaloneService records a service created in the namespace of the
+// user independent of applications.
+type StandaloneService struct {
 	CreatedAt time.Time
-	Plugins   []string
-	Hosts     []string `bson:",omitempty"`
+	Plugin    string
 	Secret    string
 }
 
+// Maintenance records who put an application into maintenance mode.
+type Maintenance struct {
+	By      string
+	Since   time.Time
+	Stopped bool
+}
+
+// DeployLock records who locked deployments of an application and why.
+type DeployLock struct {
+	By     string
+	Reason string
+	Since  time.Time
+}
+
+// Canary records a canary release of an application. The build artifact
+// is deployed to the canary containers, which receive the given percentage
+// of traffic until the release is promoted or rolled back. If AutoPromote
+// is set, the release is promoted automatically if the canary containers
+// stay healthy, or rolled back if they fail.
+type Canary struct {
+	Artifact    string
+	Previous    string `bson:",omitempty"`
+	Containers  []string
+	Restarts    map[string]int `bson:",omitempty"`
+	Weight      int
+	AutoPromote bool `bson:",omitempty"`
+	By          string
+	Since       time.Time
+}
+
+// AccessPolicy restricts access to an application by client addresses and
+// basic auth users at the proxy, and optionally redirects to another site
+// while in maintenance mode.
+type AccessPolicy struct {
+	Allow               []string        `bson:",omitempty"`
+	Deny                []string        `bson:",omitempty"`
+	BasicAuth           []BasicAuthUser `bson:",omitempty"`
+	MaintenanceRedirect string          `bson:",omitempty"`
+}
+
+// HTTPSettings controls how the proxy serves an application. Custom error
+// pages are keyed by HTTP status codes, and the idle timeout is in seconds.
+type HTTPSettings struct {
+	ForceHTTPS            bool              `bson:",omitempty"`
+	HSTSMaxAge            int               `bson:",omitempty"`
+	HSTSIncludeSubdomains bool              `bson:",omitempty"`
+	ErrorPages            map[string]string `bson:",omitempty"`
+	WebSocket             bool              `bson:",omitempty"`
+	GRPC                  bool              `bson:",omitempty"`
+	IdleTimeout           int               `bson:",omitempty"`
+}
+
+// LogConfig ships container logs of an application to an external log
+// collector with the syslog, fluentd or loki log driver.
+type LogConfig struct {
+	Driver  string
+	Address string
+	Options map[string]string `bson:",omitempty"`
+}
+
+// BasicAuthUser is a basic auth user with the password hash in htpasswd
+// format.
+type BasicAuthUser struct {
+	User string
+	Hash string
+}
+
+// Access levels of application collaborators. The deploy access implies
+// the read access.
+const (
+	AccessRead   = "read"
+	AccessDeploy = "deploy"
+)
+
+// Collaborator is a user granted access to a single application.
+type Collaborator struct {
+	User   string
+	Access string
+}
+
+// ServiceLink links a service of another application in the same namespace,
+// such as a shared database, into an application.
+type ServiceLink struct {
+	App     string
+	Service string
+}
+
+// HasAccess returns true if the access level grants the required access.
+func HasAccess(access, required string) bool {
+	return access == required || (access == AccessDeploy && required == AccessRead)
+}
+
+// SSHKey is a public SSH key that authorizes the user to access
+// application containers and repositories.
+type SSHKey struct {
+	Label       string
+	Text        string
+	Fingerprint string
+	CreatedAt   time.Time
+	LastUsed    time.Time `bson:",omitempty"`
+}
+
 func (user *BasicUser) Basic() *BasicUser {
 	return user
 }
@@ -2,11 +2,15 @@ package userdb
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/pkg/migrate"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -32,6 +36,93 @@ type Plugin interface {
 	// Update user with the new data.
 	Update(name string, fields interface{}) error
 
+	// Rename the user. Returns DuplicateUserError if the new name is
+	// already in use.
+	Rename(name, newName string) error
+
+	// PushItem appends the item to the list field of the user.
+	PushItem(name, field string, item interface{}) error
+
+	// PullItem removes items from the list field of the user whose key
+	// field equals to the value. Returns false if no item was removed.
+	PullItem(name, field, key string, value interface{}) (bool, error)
+
+	// UpdateItem sets fields of the item in the list field of the user
+	// whose key field equals to the value. Returns false if no item
+	// matches.
+	UpdateItem(name, field, key string, value interface{}, fields interface{}) (bool, error)
+
+	// UpdateApplication saves or removes (if app is nil) the application
+	// record of the user if the version of the record in the database is
+	// the given version. A missing record or a record without version
+	// has a zero version. A negative version updates unconditionally.
+	// The saved record has an incremented version. Returns
+	// ApplicationConflictError if the versions are mismatched.
+	UpdateApplication(username, name string, version int, app *Application) error
+
+	// AcquireLease acquires or renews the lease if it is not held by other
+	// holder or has expired. The start time of a renewed lease is kept.
+	// Returns false if the lease is held by other holder.
+	AcquireLease(lease *Lease) (bool, error)
+
+	// ReleaseLease removes the lease if it is held by the holder.
+	ReleaseLease(name, holder string) error
+
+	// FindLease returns the lease with the given name, or nil if the lease
+	// does not exist. Expired leases may be returned.
+	FindLease(name string) (*Lease, error)
+
+	// CreateInvite saves the invitation.
+	CreateInvite(inv *Invite) error
+
+	// FindInvite returns the invitation with the given ID, or nil if the
+	// invitation does not exist.
+	FindInvite(id string) (*Invite, error)
+
+	// ListInvites returns all invitations.
+	ListInvites() ([]*Invite, error)
+
+	// RemoveInvite removes the invitation with the given ID. Returns
+	// InvalidInviteError if the invitation does not exist, so that an
+	// invitation can only be removed once.
+	RemoveInvite(id string) error
+
+	// CreateNotice saves the notice.
+	CreateNotice(n *Notice) error
+
+	// ListNotices returns all notices ordered by start time.
+	ListNotices() ([]*Notice, error)
+
+	// RemoveNotice removes the notice with the given ID. Returns
+	// NoticeNotFoundError if the notice does not exist.
+	RemoveNotice(id string) error
+
+	// CreateServiceAccount saves the service account. Returns
+	// DuplicateServiceAccountError if the name is already in use.
+	CreateServiceAccount(sa *ServiceAccount) error
+
+	// FindServiceAccount returns the service account with the token hash,
+	// or nil if the service account does not exist.
+	FindServiceAccount(tokenHash string) (*ServiceAccount, error)
+
+	// ListServiceAccounts returns all service accounts ordered by name.
+	ListServiceAccounts() ([]*ServiceAccount, error)
+
+	// RemoveServiceAccount removes the service account. Returns
+	// ServiceAccountNotFoundError if the service account does not exist.
+	RemoveServiceAccount(name string) error
+
+	// Backup writes all records in the database to the writer, in a format
+	// specific to the plugin.
+	Backup(w io.Writer) error
+
+	// Restore replaces all records in the database with records read from
+	// a backup.
+	Restore(r io.Reader) error
+
+	// Migrator returns the migrator to evolve the schema of stored data.
+	Migrator() *migrate.Migrator
+
 	// GetSecret returns a secret key used to sign the JWT token. If the
 	// secret key does not exist in the database, a new key is generated
 	// and saved to the database.
@@ -114,9 +205,24 @@ func (e InactiveUserError) HTTPErrorStatusCode() int {
 	return http.StatusUnauthorized
 }
 
+// Pinger is implemented by plugins that can check connectivity to the
+// backing database.
+type Pinger interface {
+	Ping() error
+}
+
 // The UserDatabase type is the central point of user management.
 type UserDatabase struct {
 	plugin Plugin
+	cache  *userCache
+
+	// OnInvalidate is called after a user record is modified. It can be
+	// used to invalidate caches of other processes sharing the database.
+	OnInvalidate func(name string)
+
+	// OnLocked is called after a user account is locked due to too many
+	// failed login attempts.
+	OnLocked func(name string, until time.Time)
 }
 
 func Open() (*UserDatabase, error) {
@@ -124,7 +230,44 @@ func Open() (*UserDatabase, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &UserDatabase{plugin}, nil
+	return NewDatabase(plugin)
+}
+
+// NewDatabase creates a user database backed by the given plugin.
+func NewDatabase(plugin Plugin) (*UserDatabase, error) {
+	var err error
+	ttl := defaultCacheTTL
+	if s := config.Get("userdb.cache_ttl"); s != "" {
+		if ttl, err = time.ParseDuration(s); err != nil {
+			return nil, fmt.Errorf("Invalid userdb.cache_ttl: %s", err)
+		}
+	}
+
+	if pending, err := plugin.Migrator().Pending(); err != nil {
+		logrus.WithError(err).Warn("Failed to check the user database schema")
+	} else if len(pending) != 0 {
+		logrus.Warnf("The user database has %d pending migrations, run 'cwman migrate up' to apply", len(pending))
+	}
+
+	return &UserDatabase{plugin: plugin, cache: newUserCache(ttl)}, nil
+}
+
+// Migrator returns the migrator to evolve the schema of the user database.
+func (db *UserDatabase) Migrator() *migrate.Migrator {
+	return db.plugin.Migrator()
+}
+
+// Invalidate removes cached records of the user, which is called when the
+// user record is modified by other processes.
+func (db *UserDatabase) Invalidate(name string) {
+	db.cache.invalidate(name)
+}
+
+func (db *UserDatabase) invalidate(name string) {
+	db.cache.invalidate(name)
+	if db.OnInvalidate != nil {
+		db.OnInvalidate(name)
+	}
 }
 
 func (db *UserDatabase) Create(user User, password string) error {
@@ -142,15 +285,24 @@ func (db *UserDatabase) Create(user User, password string) error {
 	basic.Inactive = false
 	basic.Applications = nil
 	basic.Password = hashedPassword
+	db.invalidate(basic.Name)
 	return db.plugin.Create(user)
 }
 
+// IsHashedPassword returns true if the password is already hashed by
+// bcrypt, such as passwords exported from the user database.
+func IsHashedPassword(password string) bool {
+	if strings.HasPrefix(password, "$2a$") {
+		_, err := bcrypt.Cost([]byte(password))
+		return err == nil
+	}
+	return false
+}
+
 func hashPassword(password string) ([]byte, error) {
 	// use the password if it's already hashed
-	if strings.HasPrefix(password, "$2a$") {
-		if _, err := bcrypt.Cost([]byte(password)); err == nil {
-			return []byte(password), nil
-		}
+	if IsHashedPassword(password) {
+		return []byte(password), nil
 	}
 
 	// otherwise, generate a hashed password
@@ -158,13 +310,31 @@ func hashPassword(password string) ([]byte, error) {
 }
 
 func (db *UserDatabase) SetNamespace(username, namespace string) error {
+	defer db.invalidate(username)
 	return db.plugin.SetNamespace(username, namespace)
 }
 
+// Find the user by name.
 func (db *UserDatabase) Find(name string, result User) error {
 	return db.plugin.Find(name, result)
 }
 
+// FindCached finds the user by name, the user record may be cached for a
+// short time. Records modified by other processes are only invalidated if
+// "redis.url" is configured, so the cached record must only be used for
+// read-only lookups such as listings, never for authorization or to
+// compute updates.
+func (db *UserDatabase) FindCached(name string, result User) error {
+	if db.cache.get(name, result) {
+		return nil
+	}
+	if err := db.plugin.Find(name, result); err != nil {
+		return err
+	}
+	db.cache.put(name, result)
+	return nil
+}
+
 func (db *UserDatabase) FindByNamespace(namespace string) (User, error) {
 	var user BasicUser
 	err := db.Search(Args{"namespace": namespace}, &user)
@@ -176,13 +346,18 @@ func (db *UserDatabase) Search(filter interface{}, result interface{}) error {
 }
 
 func (db *UserDatabase) Remove(name string) error {
+	defer db.invalidate(name)
 	return db.plugin.Remove(name)
 }
 
 func (db *UserDatabase) Update(name string, fields interface{}) error {
+	defer db.invalidate(name)
 	return db.plugin.Update(name, fields)
 }
 
+// Authenticate the user with the password. Failed attempts are recorded,
+// and AccountLockedError is returned if the account is locked due to too
+// many failed attempts.
 func (db *UserDatabase) Authenticate(name string, password string) (*BasicUser, error) {
 	var user BasicUser
 	if err := db.plugin.Find(name, &user); err != nil {
@@ -192,12 +367,21 @@ func (db *UserDatabase) Authenticate(name string, password string) (*BasicUser,
 	if user.Inactive {
 		return nil, InactiveUserError(name)
 	}
+	if err := user.CheckLocked(); err != nil {
+		return nil, err
+	}
 
 	err := bcrypt.CompareHashAndPassword(user.Password, []byte(password))
 	if err != nil {
+		if rerr := db.RecordLoginFailure(name); rerr != nil {
+			return nil, rerr
+		}
 		return nil, err
 	}
 
+	if err = db.ResetLoginFailures(&user); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
@@ -212,14 +396,51 @@ func (db *UserDatabase) ChangePassword(name string, oldPassword, newPassword str
 		return err
 	}
 
+	if err = CheckPassword(newPassword); err != nil {
+		return err
+	}
+
 	hashedPassword, err := hashPassword(newPassword)
 	if err != nil {
 		return err
 	}
 
+	defer db.invalidate(name)
 	return db.plugin.Update(name, Args{"password": hashedPassword})
 }
 
+// SetPassword resets the password of the user without the old password,
+// which is used by administrators. The account is also unlocked.
+func (db *UserDatabase) SetPassword(name string, password string) error {
+	if err := CheckPassword(password); err != nil {
+		return err
+	}
+
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	defer db.invalidate(name)
+	return db.plugin.Update(name, Args{
+		"password":     hashedPassword,
+		"failedlogins": 0,
+		"lockeduntil":  time.Time{},
+	})
+}
+
+// Backup writes all records in the user database to the writer.
+func (db *UserDatabase) Backup(w io.Writer) error {
+	return db.plugin.Backup(w)
+}
+
+// Restore replaces all records in the user database with records read
+// from a backup. All cached records are discarded.
+func (db *UserDatabase) Restore(r io.Reader) error {
+	defer db.cache.clear()
+	return db.plugin.Restore(r)
+}
+
 // GetSecret returns a secret key used to sign the JWT token. If the
 // secret key does not exist in the database, a new key is generated
 // and saved to the database.
@@ -227,6 +448,15 @@ func (db *UserDatabase) GetSecret(key string, gen func() []byte) ([]byte, error)
 	return db.plugin.GetSecret(key, gen)
 }
 
+// Ping checks connectivity to the backing database, it always succeeds if
+// the plugin doesn't support the check.
+func (db *UserDatabase) Ping() error {
+	if p, ok := db.plugin.(Pinger); ok {
+		return p.Ping()
+	}
+	return nil
+}
+
 func (db *UserDatabase) Close() error {
 	return db.plugin.Close()
 }
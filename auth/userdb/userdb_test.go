@@ -1,11 +1,21 @@
 package userdb_test
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
 
 	"github.com/cloudway/platform/auth/userdb"
 	. "github.com/cloudway/platform/auth/userdb/matchers"
@@ -108,6 +118,23 @@ var _ = Describe("UserDB", func() {
 		})
 	})
 
+	Describe("Set domain", func() {
+		It("should find user by the delegated domain", func() {
+			Expect(db.SetDomain(TEST_USER, "Team.Example.com")).To(Succeed())
+			user, err := db.FindByDomain("team.example.com")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.Basic().Name).To(Equal(TEST_USER))
+			Expect(user.Basic().Domain).To(Equal("team.example.com"))
+		})
+
+		It("should fail when the domain is delegated to another namespace", func() {
+			Expect(db.SetDomain(OTHER_USER, "team.example.com")).To(Succeed())
+			Expect(db.SetDomain(TEST_USER, "team.example.com")).To(Equal(userdb.DomainInUseError("team.example.com")))
+			Expect(db.SetDomain(OTHER_USER, "")).To(Succeed())
+			Expect(db.SetDomain(TEST_USER, "team.example.com")).To(Succeed())
+		})
+	})
+
 	Describe("Find user", func() {
 		It("should success if user exist", func() {
 			var user userdb.BasicUser
@@ -120,6 +147,58 @@ var _ = Describe("UserDB", func() {
 			var user userdb.BasicUser
 			Expect(db.Find(NOSUCH_USER, &user)).To(BeUserNotFound(NOSUCH_USER))
 		})
+
+		It("should return updated user after update", func() {
+			var user userdb.BasicUser
+			Expect(db.Find(TEST_USER, &user)).To(Succeed())
+			Expect(user.Applications).To(BeEmpty())
+
+			apps := map[string]*userdb.Application{"test": {Secret: "secret"}}
+			Expect(db.Update(TEST_USER, userdb.Args{"applications": apps})).To(Succeed())
+
+			user = userdb.BasicUser{}
+			Expect(db.Find(TEST_USER, &user)).To(Succeed())
+			Expect(user.Applications).To(HaveKey("test"))
+		})
+
+		It("should return updated cached user after update", func() {
+			var user userdb.BasicUser
+			Expect(db.FindCached(TEST_USER, &user)).To(Succeed())
+			Expect(user.Applications).To(BeEmpty())
+
+			apps := map[string]*userdb.Application{"test": {Secret: "secret"}}
+			Expect(db.Update(TEST_USER, userdb.Args{"applications": apps})).To(Succeed())
+
+			user = userdb.BasicUser{}
+			Expect(db.FindCached(TEST_USER, &user)).To(Succeed())
+			Expect(user.Applications).To(HaveKey("test"))
+		})
+
+		It("should not share cached user between callers", func() {
+			apps := map[string]*userdb.Application{"test": {Secret: "secret"}}
+			Expect(db.Update(TEST_USER, userdb.Args{"applications": apps})).To(Succeed())
+
+			var user userdb.BasicUser
+			Expect(db.FindCached(TEST_USER, &user)).To(Succeed())
+			delete(user.Applications, "test")
+
+			user = userdb.BasicUser{}
+			Expect(db.FindCached(TEST_USER, &user)).To(Succeed())
+			Expect(user.Applications).To(HaveKey("test"))
+		})
+
+		It("should not serve uncached lookups from cache", func() {
+			var user userdb.BasicUser
+			Expect(db.FindCached(TEST_USER, &user)).To(Succeed())
+
+			// modified by other process without invalidation
+			other, err := userdb.Open()
+			Expect(err).NotTo(HaveOccurred())
+			defer other.Close()
+			Expect(other.SetNamespace(TEST_USER, NEW_NAMESPACE)).To(Succeed())
+
+			assertUserNamespace(TEST_USER, NEW_NAMESPACE)
+		})
 	})
 
 	Describe("Authenticate", func() {
@@ -141,30 +220,461 @@ var _ = Describe("UserDB", func() {
 		})
 	})
 
+	Describe("Account lockout", func() {
+		failLogins := func(n int) {
+			for i := 0; i < n; i++ {
+				_, err := db.Authenticate(TEST_USER, "guessed")
+				Expect(err).To(HaveOccurred())
+			}
+		}
+
+		It("should record failed logins", func() {
+			failLogins(2)
+			var user userdb.BasicUser
+			Expect(db.Find(TEST_USER, &user)).To(Succeed())
+			Expect(user.FailedLogins).To(Equal(2))
+			Expect(user.CheckLocked()).To(Succeed())
+		})
+
+		It("should lock account after too many failed logins", func() {
+			var locked string
+			db.OnLocked = func(name string, until time.Time) { locked = name }
+
+			failLogins(5)
+			Expect(locked).To(Equal(TEST_USER))
+
+			_, err := db.Authenticate(TEST_USER, "test")
+			Expect(userdb.IsAccountLocked(err)).To(BeTrue())
+		})
+
+		It("should double the lockout duration for further failures", func() {
+			failLogins(5)
+			var user userdb.BasicUser
+			Expect(db.Find(TEST_USER, &user)).To(Succeed())
+			first := user.LockedUntil.Sub(time.Now())
+
+			Expect(db.Unlock(TEST_USER)).To(Succeed())
+			Expect(db.Update(TEST_USER, userdb.Args{"failedlogins": 5})).To(Succeed())
+			failLogins(1)
+			Expect(db.Find(TEST_USER, &user)).To(Succeed())
+			Expect(user.LockedUntil.Sub(time.Now())).To(BeNumerically(">", first))
+		})
+
+		It("should reset failed logins after successful login", func() {
+			failLogins(2)
+			_, err := db.Authenticate(TEST_USER, "test")
+			Expect(err).NotTo(HaveOccurred())
+
+			var user userdb.BasicUser
+			Expect(db.Find(TEST_USER, &user)).To(Succeed())
+			Expect(user.FailedLogins).To(BeZero())
+		})
+
+		It("should authenticate after unlocked", func() {
+			failLogins(5)
+			Expect(db.Unlock(TEST_USER)).To(Succeed())
+			_, err := db.Authenticate(TEST_USER, "test")
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
 	Describe("Change password", func() {
 		It("should success with correct old password", func() {
-			Expect(db.ChangePassword(TEST_USER, "test", "changed")).To(Succeed())
+			Expect(db.ChangePassword(TEST_USER, "test", "changed-password")).To(Succeed())
 		})
 
 		It("should fail with incorrect old password", func() {
-			Expect(db.ChangePassword(TEST_USER, "unknown", "changed")).NotTo(Succeed())
+			Expect(db.ChangePassword(TEST_USER, "unknown", "changed-password")).NotTo(Succeed())
 		})
 
 		It("should fail when user does not exist", func() {
-			Expect(db.ChangePassword(NOSUCH_USER, "anything", "changed")).To(BeUserNotFound(NOSUCH_USER))
+			Expect(db.ChangePassword(NOSUCH_USER, "anything", "changed-password")).To(BeUserNotFound(NOSUCH_USER))
 		})
 
 		It("should success to authenticate with new password", func() {
-			Expect(db.ChangePassword(TEST_USER, "test", "changed")).To(Succeed())
-			_, err := db.Authenticate(TEST_USER, "changed")
+			Expect(db.ChangePassword(TEST_USER, "test", "changed-password")).To(Succeed())
+			_, err := db.Authenticate(TEST_USER, "changed-password")
 			Expect(err).NotTo(HaveOccurred())
 		})
 
 		It("should fail to authenticate with old password", func() {
-			Expect(db.ChangePassword(TEST_USER, "test", "changed")).To(Succeed())
+			Expect(db.ChangePassword(TEST_USER, "test", "changed-password")).To(Succeed())
 			_, err := db.Authenticate(TEST_USER, "test")
 			Expect(err).To(HaveOccurred())
 		})
+
+		It("should fail with password violating the password policy", func() {
+			err := db.ChangePassword(TEST_USER, "test", "short")
+			Expect(err).To(BeAssignableToTypeOf(userdb.InvalidPasswordError("")))
+		})
+
+		It("should reset password without old password", func() {
+			Expect(db.SetPassword(TEST_USER, "reset-password")).To(Succeed())
+			_, err := db.Authenticate(TEST_USER, "reset-password")
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("Email change", func() {
+		AfterEach(func() {
+			db.Remove(NEW_USER)
+		})
+
+		It("should rename user after confirmed", func() {
+			token, err := db.RequestEmailChange(TEST_USER, NEW_USER)
+			Expect(err).NotTo(HaveOccurred())
+
+			oldName, newName, err := db.ConfirmEmailChange(token)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(oldName).To(Equal(TEST_USER))
+			Expect(newName).To(Equal(NEW_USER))
+
+			var user userdb.BasicUser
+			Expect(db.Find(NEW_USER, &user)).To(Succeed())
+			Expect(user.Namespace).To(Equal(TEST_NAMESPACE))
+			Expect(user.PendingEmail).To(BeEmpty())
+			Expect(db.Find(TEST_USER, &user)).To(BeUserNotFound(TEST_USER))
+		})
+
+		It("should fail if the email address is in use", func() {
+			_, err := db.RequestEmailChange(TEST_USER, OTHER_USER)
+			Expect(err).To(BeAssignableToTypeOf(userdb.DuplicateUserError("")))
+		})
+
+		It("should fail with invalid token", func() {
+			_, err := db.RequestEmailChange(TEST_USER, NEW_USER)
+			Expect(err).NotTo(HaveOccurred())
+			_, _, err = db.ConfirmEmailChange("invalid")
+			Expect(err).To(BeAssignableToTypeOf(userdb.InvalidTokenError{}))
+		})
+
+		It("should fail with used token", func() {
+			token, err := db.RequestEmailChange(TEST_USER, NEW_USER)
+			Expect(err).NotTo(HaveOccurred())
+			_, _, err = db.ConfirmEmailChange(token)
+			Expect(err).NotTo(HaveOccurred())
+			_, _, err = db.ConfirmEmailChange(token)
+			Expect(err).To(BeAssignableToTypeOf(userdb.InvalidTokenError{}))
+		})
+	})
+
+	Describe("Account deletion", func() {
+		It("should find expired accounts", func() {
+			Expect(db.ScheduleDeletion(TEST_USER, time.Now().Add(-time.Minute))).To(Succeed())
+			Expect(db.ScheduleDeletion(OTHER_USER, time.Now().Add(time.Hour))).To(Succeed())
+			Expect(db.FindExpiredAccounts()).To(Equal([]string{TEST_USER}))
+		})
+
+		It("should not find canceled accounts", func() {
+			Expect(db.ScheduleDeletion(TEST_USER, time.Now().Add(-time.Minute))).To(Succeed())
+			Expect(db.CancelDeletion(TEST_USER)).To(Succeed())
+			Expect(db.FindExpiredAccounts()).To(BeEmpty())
+		})
+	})
+
+	Describe("Preferences", func() {
+		It("should save preferences", func() {
+			prefs := userdb.Preferences{Theme: userdb.ThemeDark, Locale: "en-US", NamespaceView: "env"}
+			Expect(db.SetPreferences(TEST_USER, &prefs)).To(Succeed())
+
+			var user userdb.BasicUser
+			Expect(db.Find(TEST_USER, &user)).To(Succeed())
+			Expect(user.Preferences).To(Equal(prefs))
+		})
+
+		It("should reject invalid preferences", func() {
+			for _, prefs := range []userdb.Preferences{
+				{Theme: "purple"},
+				{Locale: "english"},
+				{NamespaceView: "env=prod"},
+				{TableDensity: "tiny"},
+			} {
+				err := db.SetPreferences(TEST_USER, &prefs)
+				Expect(err).To(BeAssignableToTypeOf(userdb.InvalidPreferenceError("")))
+			}
+		})
+	})
+
+	Describe("Invitations", func() {
+		AfterEach(func() {
+			invites, _ := db.ListInvites()
+			for _, inv := range invites {
+				db.RemoveInvite(inv.ID)
+			}
+		})
+
+		It("should find invitation with the token", func() {
+			token, err := db.CreateInvite(&userdb.Invite{Email: "Invited@Example.com", Namespace: "invited"}, time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+
+			inv, err := db.FindInvite(token)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inv.Email).To(Equal("invited@example.com"))
+			Expect(inv.Namespace).To(Equal("invited"))
+			Expect(inv.ID).NotTo(Equal(token))
+		})
+
+		It("should use invitation only once", func() {
+			token, err := db.CreateInvite(&userdb.Invite{}, time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(db.UseInvite(token)).NotTo(BeNil())
+			_, err = db.UseInvite(token)
+			Expect(err).To(Equal(userdb.InvalidInviteError{}))
+		})
+
+		It("should be able to use returned invitation", func() {
+			token, err := db.CreateInvite(&userdb.Invite{}, time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+			inv, err := db.UseInvite(token)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(db.ReturnInvite(inv)).To(Succeed())
+			Expect(db.UseInvite(token)).NotTo(BeNil())
+		})
+
+		It("should reject expired invitation", func() {
+			token, err := db.CreateInvite(&userdb.Invite{}, -time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = db.FindInvite(token)
+			Expect(err).To(Equal(userdb.InvalidInviteError{}))
+		})
+
+		It("should reject unknown invitation", func() {
+			_, err := db.FindInvite("no-such-invite")
+			Expect(err).To(Equal(userdb.InvalidInviteError{}))
+		})
+
+		It("should list and revoke invitations", func() {
+			token, err := db.CreateInvite(&userdb.Invite{CreatedBy: TEST_USER}, time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+
+			invites, err := db.ListInvites()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(invites).To(HaveLen(1))
+			Expect(invites[0].CreatedBy).To(Equal(TEST_USER))
+
+			Expect(db.RemoveInvite(invites[0].ID)).To(Succeed())
+			Expect(db.ListInvites()).To(BeEmpty())
+			_, err = db.FindInvite(token)
+			Expect(err).To(Equal(userdb.InvalidInviteError{}))
+			Expect(db.RemoveInvite(invites[0].ID)).To(Equal(userdb.InvalidInviteError{}))
+		})
+	})
+
+	Describe("Service accounts", func() {
+		AfterEach(func() {
+			accounts, _ := db.ListServiceAccounts()
+			for _, sa := range accounts {
+				db.RemoveServiceAccount(sa.Name)
+			}
+		})
+
+		It("should find service account with the token", func() {
+			token, err := db.CreateServiceAccount(&userdb.ServiceAccount{Name: "ci", Namespace: "test"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(HavePrefix(userdb.ServiceAccountTokenPrefix))
+
+			sa, err := db.FindServiceAccount(token)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sa.Name).To(Equal("ci"))
+			Expect(sa.Scopes).To(Equal(userdb.DefaultScopes))
+			Expect(sa.TokenHash).NotTo(Equal(token))
+
+			Expect(db.FindServiceAccount(token + "x")).To(BeNil())
+		})
+
+		It("should check scopes of service account", func() {
+			sa := &userdb.ServiceAccount{Application: "app", Scopes: []string{userdb.ScopeDeploy}}
+			Expect(sa.Allows(userdb.ScopeDeploy, "app")).To(BeTrue())
+			Expect(sa.Allows(userdb.ScopeUpload, "app")).To(BeFalse())
+			Expect(sa.Allows(userdb.ScopeDeploy, "other")).To(BeFalse())
+		})
+
+		It("should reject invalid service account", func() {
+			_, err := db.CreateServiceAccount(&userdb.ServiceAccount{Name: "Invalid Name"})
+			Expect(err).To(BeAssignableToTypeOf(userdb.InvalidServiceAccountError("")))
+			_, err = db.CreateServiceAccount(&userdb.ServiceAccount{Name: "ci", Scopes: []string{"admin"}})
+			Expect(err).To(BeAssignableToTypeOf(userdb.InvalidServiceAccountError("")))
+		})
+
+		It("should not create duplicate service account", func() {
+			_, err := db.CreateServiceAccount(&userdb.ServiceAccount{Name: "ci", Namespace: "test"})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = db.CreateServiceAccount(&userdb.ServiceAccount{Name: "ci", Namespace: "test"})
+			Expect(err).To(Equal(userdb.DuplicateServiceAccountError("ci")))
+		})
+
+		It("should revoke token of removed service account", func() {
+			token, err := db.CreateServiceAccount(&userdb.ServiceAccount{Name: "ci", Namespace: "test"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(db.RemoveServiceAccount("ci")).To(Succeed())
+			Expect(db.FindServiceAccount(token)).To(BeNil())
+			Expect(db.RemoveServiceAccount("ci")).To(Equal(userdb.ServiceAccountNotFoundError("ci")))
+		})
+	})
+
+	Describe("Access tokens", func() {
+		It("should authorize user with the token", func() {
+			token, t, err := db.CreateAccessToken(TEST_USER, "script", []string{userdb.ScopeRead})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(HavePrefix(userdb.AccessTokenPrefix))
+			Expect(t.TokenHash).NotTo(Equal(token))
+
+			user, found, err := db.AuthorizeAccessToken(token)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.Name).To(Equal(TEST_USER))
+			Expect(found.ID).To(Equal(t.ID))
+			Expect(found.HasScope(userdb.ScopeRead)).To(BeTrue())
+			Expect(found.HasScope(userdb.ScopeAll)).To(BeFalse())
+			Expect(found.LastUsed).NotTo(BeZero())
+
+			user, _, err = db.AuthorizeAccessToken(token + "x")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user).To(BeNil())
+		})
+
+		It("should reject invalid access token", func() {
+			_, _, err := db.CreateAccessToken(TEST_USER, "", []string{userdb.ScopeAll})
+			Expect(err).To(BeAssignableToTypeOf(userdb.InvalidAccessTokenError("")))
+			_, _, err = db.CreateAccessToken(TEST_USER, "script", nil)
+			Expect(err).To(BeAssignableToTypeOf(userdb.InvalidAccessTokenError("")))
+			_, _, err = db.CreateAccessToken(TEST_USER, "script", []string{"admin"})
+			Expect(err).To(BeAssignableToTypeOf(userdb.InvalidAccessTokenError("")))
+		})
+
+		It("should revoke removed access token", func() {
+			token, t, err := db.CreateAccessToken(TEST_USER, "script", []string{userdb.ScopeAll})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(db.RemoveAccessToken(TEST_USER, t.ID)).To(Succeed())
+
+			user, _, err := db.AuthorizeAccessToken(token)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user).To(BeNil())
+			Expect(db.RemoveAccessToken(TEST_USER, t.ID)).To(Equal(userdb.AccessTokenNotFoundError(t.ID)))
+		})
+	})
+
+	Describe("Registries", func() {
+		It("should replace credentials of the same registry", func() {
+			Expect(db.AddRegistry(TEST_USER, &userdb.Registry{Server: "Registry.Example.com:5000", Username: "foo", Password: "secret"})).To(Succeed())
+			Expect(db.AddRegistry(TEST_USER, &userdb.Registry{Server: "registry.example.com:5000", Username: "bar", Password: "secret"})).To(Succeed())
+
+			var user userdb.BasicUser
+			Expect(db.Find(TEST_USER, &user)).To(Succeed())
+			Expect(user.Registries).To(HaveLen(1))
+			Expect(user.Registries[0].Server).To(Equal("registry.example.com:5000"))
+			Expect(user.Registries[0].Username).To(Equal("bar"))
+		})
+
+		It("should reject invalid registry", func() {
+			err := db.AddRegistry(TEST_USER, &userdb.Registry{Server: "https://example.com", Username: "foo", Password: "secret"})
+			Expect(err).To(BeAssignableToTypeOf(userdb.InvalidRegistryError("")))
+			err = db.AddRegistry(TEST_USER, &userdb.Registry{Server: "example.com", Username: "foo"})
+			Expect(err).To(BeAssignableToTypeOf(userdb.InvalidRegistryError("")))
+		})
+
+		It("should remove registry", func() {
+			Expect(db.AddRegistry(TEST_USER, &userdb.Registry{Server: "example.com", Username: "foo", Password: "secret"})).To(Succeed())
+			Expect(db.RemoveRegistry(TEST_USER, "example.com")).To(Succeed())
+			Expect(db.RemoveRegistry(TEST_USER, "example.com")).To(Equal(userdb.RegistryNotFoundError("example.com")))
+		})
+	})
+
+	Describe("Notices", func() {
+		AfterEach(func() {
+			notices, _ := db.ListNotices()
+			for _, n := range notices {
+				db.RemoveNotice(n.ID)
+			}
+		})
+
+		It("should create notice shown immediately", func() {
+			n := &userdb.Notice{Message: "  Scheduled\n maintenance  "}
+			Expect(db.CreateNotice(n)).To(Succeed())
+			Expect(n.ID).NotTo(BeEmpty())
+			Expect(n.Kind).To(Equal(userdb.NoticeInfo))
+			Expect(n.Message).To(Equal("Scheduled maintenance"))
+
+			notices, err := db.ActiveNotices()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(notices).To(HaveLen(1))
+			Expect(notices[0].ID).To(Equal(n.ID))
+		})
+
+		It("should only return active notices", func() {
+			now := time.Now()
+			Expect(db.CreateNotice(&userdb.Notice{Message: "scheduled", Starts: now.Add(time.Hour)})).To(Succeed())
+			Expect(db.CreateNotice(&userdb.Notice{Message: "expired", Starts: now.Add(-time.Hour), Expires: now.Add(-time.Minute)})).To(Succeed())
+			Expect(db.CreateNotice(&userdb.Notice{Kind: userdb.NoticeTerms, Message: "active", Expires: now.Add(time.Hour)})).To(Succeed())
+
+			Expect(db.ListNotices()).To(HaveLen(3))
+			notices, err := db.ActiveNotices()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(notices).To(HaveLen(1))
+			Expect(notices[0].Message).To(Equal("active"))
+		})
+
+		It("should reject invalid notices", func() {
+			Expect(db.CreateNotice(&userdb.Notice{Kind: "unknown", Message: "message"})).To(BeAssignableToTypeOf(userdb.InvalidNoticeError("")))
+			Expect(db.CreateNotice(&userdb.Notice{Message: " "})).To(BeAssignableToTypeOf(userdb.InvalidNoticeError("")))
+			Expect(db.CreateNotice(&userdb.Notice{Message: "message", Expires: time.Now().Add(-time.Hour)})).To(BeAssignableToTypeOf(userdb.InvalidNoticeError("")))
+			Expect(db.ListNotices()).To(BeEmpty())
+		})
+
+		It("should remove notice", func() {
+			n := &userdb.Notice{Message: "message"}
+			Expect(db.CreateNotice(n)).To(Succeed())
+			Expect(db.RemoveNotice(n.ID)).To(Succeed())
+			Expect(db.ListNotices()).To(BeEmpty())
+			Expect(db.RemoveNotice(n.ID)).To(Equal(userdb.NoticeNotFoundError(n.ID)))
+		})
+	})
+
+	Describe("Password policy", func() {
+		var policy *userdb.PasswordPolicy
+
+		BeforeEach(func() {
+			policy = userdb.GetPasswordPolicy()
+		})
+
+		It("should check password length", func() {
+			policy.MinLength, policy.MaxLength = 8, 12
+			Expect(policy.Check("1234567")).NotTo(Succeed())
+			Expect(policy.Check("12345-abc")).To(Succeed())
+			Expect(policy.Check("1234567890abc")).NotTo(Succeed())
+		})
+
+		It("should check character classes", func() {
+			policy.MinClasses = 3
+			Expect(policy.Check("lowercase1")).NotTo(Succeed())
+			Expect(policy.Check("Uppercase1")).To(Succeed())
+		})
+
+		It("should deny common passwords", func() {
+			Expect(policy.Check("Password")).NotTo(Succeed())
+			policy.DenyList["correct horse"] = true
+			Expect(policy.Check("Correct Horse")).NotTo(Succeed())
+		})
+
+		It("should deny breached passwords", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Path).To(Equal("/range/" + pwnedPrefix("breached-password")))
+				fmt.Fprintf(w, "%s:3\r\n", pwnedSuffix("breached-password"))
+			}))
+			defer server.Close()
+
+			policy.PwnedURL = server.URL + "/range/"
+			Expect(policy.Check("breached-password")).NotTo(Succeed())
+		})
+
+		It("should accept password if breach check failed", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer server.Close()
+
+			policy.PwnedURL = server.URL + "/range/"
+			Expect(policy.Check("unknown-password")).To(Succeed())
+		})
 	})
 
 	Describe("Remove user", func() {
@@ -189,6 +699,203 @@ var _ = Describe("UserDB", func() {
 		})
 	})
 
+	Describe("Application records", func() {
+		var findApp = func(name string) *userdb.Application {
+			var user userdb.BasicUser
+			ExpectWithOffset(1, db.Find(TEST_USER, &user)).To(Succeed())
+			return user.Applications[name]
+		}
+
+		It("should save new application", func() {
+			app := &userdb.Application{Secret: "secret"}
+			Expect(db.SaveApplication(TEST_USER, "test", app)).To(Succeed())
+			Expect(app.Version).To(Equal(1))
+			Expect(findApp("test").Secret).To(Equal("secret"))
+			Expect(findApp("test").Version).To(Equal(1))
+		})
+
+		It("should fail to save new application if already exists", func() {
+			Expect(db.SaveApplication(TEST_USER, "test", &userdb.Application{})).To(Succeed())
+			Expect(db.SaveApplication(TEST_USER, "test", &userdb.Application{})).To(BeApplicationConflict("test"))
+		})
+
+		It("should fail to save stale application", func() {
+			Expect(db.SaveApplication(TEST_USER, "test", &userdb.Application{})).To(Succeed())
+			app1, app2 := findApp("test"), findApp("test")
+
+			app1.Secret = "first"
+			Expect(db.SaveApplication(TEST_USER, "test", app1)).To(Succeed())
+			app2.Secret = "second"
+			Expect(db.SaveApplication(TEST_USER, "test", app2)).To(BeApplicationConflict("test"))
+			Expect(findApp("test").Secret).To(Equal("first"))
+		})
+
+		It("should not overwrite other applications", func() {
+			Expect(db.SaveApplication(TEST_USER, "test1", &userdb.Application{})).To(Succeed())
+			Expect(db.SaveApplication(TEST_USER, "test2", &userdb.Application{})).To(Succeed())
+			Expect(findApp("test1")).NotTo(BeNil())
+			Expect(findApp("test2")).NotTo(BeNil())
+		})
+
+		It("should modify the latest application", func() {
+			Expect(db.SaveApplication(TEST_USER, "test", &userdb.Application{})).To(Succeed())
+			stale := findApp("test")
+			Expect(db.SaveApplication(TEST_USER, "test", findApp("test"))).To(Succeed())
+
+			app, err := db.ModifyApplication(TEST_USER, "test", func(app *userdb.Application) error {
+				app.Protected = true
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(app.Version).To(Equal(stale.Version + 2))
+			Expect(findApp("test").Protected).To(BeTrue())
+		})
+
+		It("should fail to modify nonexistent application", func() {
+			_, err := db.ModifyApplication(TEST_USER, "test", func(*userdb.Application) error { return nil })
+			Expect(err).To(Equal(userdb.ApplicationNotFoundError("test")))
+		})
+
+		It("should remove application", func() {
+			Expect(db.SaveApplication(TEST_USER, "test", &userdb.Application{})).To(Succeed())
+			Expect(db.RemoveApplication(TEST_USER, "test")).To(Succeed())
+			Expect(findApp("test")).To(BeNil())
+		})
+	})
+
+	Describe("Leases", func() {
+		const LEASE = "test-lease"
+
+		AfterEach(func() {
+			db.ReleaseLease(LEASE, "server1")
+			db.ReleaseLease(LEASE, "server2")
+		})
+
+		It("should acquire free lease", func() {
+			Expect(db.AcquireLease(LEASE, "server1", "value", time.Minute)).To(BeTrue())
+
+			lease, err := db.FindLease(LEASE)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lease.Holder).To(Equal("server1"))
+			Expect(lease.Value).To(Equal("value"))
+		})
+
+		It("should renew lease held by the same holder", func() {
+			Expect(db.AcquireLease(LEASE, "server1", "", time.Minute)).To(BeTrue())
+			Expect(db.AcquireLease(LEASE, "server1", "", time.Minute)).To(BeTrue())
+		})
+
+		It("should not acquire lease held by other holder", func() {
+			Expect(db.AcquireLease(LEASE, "server1", "", time.Minute)).To(BeTrue())
+			Expect(db.AcquireLease(LEASE, "server2", "", time.Minute)).To(BeFalse())
+		})
+
+		It("should take over expired lease", func() {
+			Expect(db.AcquireLease(LEASE, "server1", "", time.Millisecond)).To(BeTrue())
+			time.Sleep(10 * time.Millisecond)
+			Expect(db.AcquireLease(LEASE, "server2", "", time.Minute)).To(BeTrue())
+		})
+
+		It("should acquire lease after released", func() {
+			Expect(db.AcquireLease(LEASE, "server1", "", time.Minute)).To(BeTrue())
+			Expect(db.ReleaseLease(LEASE, "server1")).To(Succeed())
+			Expect(db.FindLease(LEASE)).To(BeNil())
+			Expect(db.AcquireLease(LEASE, "server2", "", time.Minute)).To(BeTrue())
+		})
+	})
+
+	Describe("SSH keys", func() {
+		var newKey = func(comment string) *userdb.SSHKey {
+			priv, err := rsa.GenerateKey(rand.Reader, 1024)
+			ExpectWithOffset(1, err).NotTo(HaveOccurred())
+			sshPub, err := ssh.NewPublicKey(&priv.PublicKey)
+			ExpectWithOffset(1, err).NotTo(HaveOccurred())
+			text := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub))) + " " + comment
+			key, err := userdb.NewSSHKey("", text)
+			ExpectWithOffset(1, err).NotTo(HaveOccurred())
+			return key
+		}
+
+		It("should use key comment as default label", func() {
+			key := newKey("test@host")
+			Expect(key.Label).To(Equal("test@host"))
+			Expect(key.Fingerprint).To(HavePrefix("SHA256:"))
+		})
+
+		It("should compute fingerprint in OpenSSH format", func() {
+			key, err := userdb.NewSSHKey("", "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAAgQC8OUSJCq5zXlCnBC81rXgb0mEdHp0pX/exOZQsyarPF5uVKaguqimseQ2zd32MmCk/nhKicbU+3F95+kEtHCfoU9Y3Hx2nbvMH8d2QQb2h8ZfwkZjkqF1rEuqI8Yv5Y0cDXXwxisn04IupcbXux3kYG7XHiqT+dVd7AQZQqsWQfQ== test")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(key.Fingerprint).To(Equal("SHA256:VhBder0fTTnQSQ19GcLOLxhhf1UhScdkOhl0IzN1Z2E"))
+		})
+
+		It("should fail with invalid key", func() {
+			_, err := userdb.NewSSHKey("", "invalid key")
+			Expect(err).To(BeAssignableToTypeOf(userdb.InvalidKeyError{}))
+		})
+
+		It("should find user by key", func() {
+			key := newKey("test@host")
+			Expect(db.AddSSHKey(TEST_USER, key)).To(Succeed())
+
+			user, err := db.FindByKey(key.Fingerprint)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.Name).To(Equal(TEST_USER))
+			Expect(user.SSHKeys).To(HaveLen(1))
+		})
+
+		It("should fail to add duplicate key", func() {
+			key := newKey("test@host")
+			Expect(db.AddSSHKey(TEST_USER, key)).To(Succeed())
+			Expect(db.AddSSHKey(OTHER_USER, key)).To(BeAssignableToTypeOf(userdb.DuplicateKeyError("")))
+		})
+
+		It("should not find user after key removed", func() {
+			key := newKey("test@host")
+			Expect(db.AddSSHKey(TEST_USER, key)).To(Succeed())
+
+			removed, err := db.RemoveSSHKey(TEST_USER, key.Fingerprint)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed.Text).To(Equal(key.Text))
+
+			_, err = db.FindByKey(key.Fingerprint)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should fail to remove nonexistent key", func() {
+			_, err := db.RemoveSSHKey(TEST_USER, "SHA256:nosuchkey")
+			Expect(err).To(BeAssignableToTypeOf(userdb.KeyNotFoundError("")))
+		})
+
+		It("should keep keys added concurrently", func() {
+			key1, key2 := newKey("one@host"), newKey("two@host")
+			done := make(chan error, 2)
+			go func() { done <- db.AddSSHKey(TEST_USER, key1) }()
+			go func() { done <- db.AddSSHKey(TEST_USER, key2) }()
+			Expect(<-done).To(Succeed())
+			Expect(<-done).To(Succeed())
+
+			var user userdb.BasicUser
+			Expect(db.Find(TEST_USER, &user)).To(Succeed())
+			Expect(user.SSHKeys).To(HaveLen(2))
+		})
+
+		It("should record last used time when authorized", func() {
+			key := newKey("test@host")
+			Expect(db.AddSSHKey(TEST_USER, key)).To(Succeed())
+
+			pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key.Text))
+			Expect(err).NotTo(HaveOccurred())
+			user, err := db.AuthorizeSSHKey(pub)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.Name).To(Equal(TEST_USER))
+
+			var found userdb.BasicUser
+			Expect(db.Find(TEST_USER, &found)).To(Succeed())
+			Expect(found.SSHKeys).To(HaveLen(1))
+			Expect(found.SSHKeys[0].LastUsed.IsZero()).To(BeFalse())
+		})
+	})
+
 	Describe("Custom user", func() {
 		type CustomUser struct {
 			userdb.BasicUser `bson:",inline"`
@@ -291,3 +998,16 @@ var _ = Describe("UserDB", func() {
 		})
 	})
 })
+
+func pwnedHash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func pwnedPrefix(password string) string {
+	return pwnedHash(password)[:5]
+}
+
+func pwnedSuffix(password string) string {
+	return pwnedHash(password)[5:]
+}
@@ -0,0 +1,179 @@
+package broker
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// The access policy of an application is stored in the user database and
+// copied to application containers, from which the proxy renders the
+// access restrictions. Passwords of basic auth users are hashed in the
+// "{SHA}" htpasswd format, which is supported by all proxies.
+
+type InvalidAccessPolicyError struct {
+	Message string
+}
+
+func (e InvalidAccessPolicyError) Error() string {
+	return e.Message
+}
+
+func (e InvalidAccessPolicyError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// SetAccessPolicy sets the access policy of the application. The password
+// hash of an existing basic auth user is kept if the password is empty.
+// A nil policy removes all access restrictions.
+func (br *UserBroker) SetAccessPolicy(name string, policy *types.AccessPolicy) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+	user := br.User.Basic()
+	app := user.Applications[name]
+	if app == nil {
+		return ApplicationNotFoundError(name)
+	}
+
+	newPolicy, err := makeAccessPolicy(policy, app.AccessPolicy)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := br.lockApp(name, user.Namespace, "access-policy")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	app, err = br.Users.ModifyApplication(user.Name, name, func(app *userdb.Application) error {
+		app.AccessPolicy = newPolicy
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	user.Applications[name] = app
+
+	cs, err := br.FindApplications(br.ctx, name, user.Namespace)
+	if err != nil {
+		return err
+	}
+	return br.applyAccessPolicy(cs, newPolicy)
+}
+
+// applyAccessPolicy copies the access policy to containers and notifies
+// the proxy.
+func (br *UserBroker) applyAccessPolicy(cs []container.Container, policy *userdb.AccessPolicy) error {
+	p := proxyAccessPolicy(policy)
+	return Parallel(cs, func(c container.Container) error {
+		if err := c.SetAccessPolicy(br.ctx, p); err != nil {
+			return err
+		}
+		if c.ActiveState(br.ctx) == manifest.StateStopped {
+			return nil
+		}
+		return br.notify(ContainerUpdated, c, nil)
+	})
+}
+
+// makeAccessPolicy validates the access policy and hashes passwords of
+// basic auth users.
+func makeAccessPolicy(policy *types.AccessPolicy, old *userdb.AccessPolicy) (*userdb.AccessPolicy, error) {
+	if policy == nil {
+		return nil, nil
+	}
+
+	for _, addrs := range [][]string{policy.Allow, policy.Deny} {
+		for _, addr := range addrs {
+			if net.ParseIP(addr) == nil {
+				if _, _, err := net.ParseCIDR(addr); err != nil {
+					return nil, InvalidAccessPolicyError{fmt.Sprintf("Invalid IP address or CIDR range: %s", addr)}
+				}
+			}
+		}
+	}
+
+	if r := policy.MaintenanceRedirect; r != "" && !isProxyURL(r) {
+		return nil, InvalidAccessPolicyError{fmt.Sprintf("Invalid maintenance redirect URL: %s", r)}
+	}
+
+	hashes := make(map[string]string)
+	if old != nil {
+		for _, u := range old.BasicAuth {
+			hashes[u.User] = u.Hash
+		}
+	}
+
+	result := &userdb.AccessPolicy{
+		Allow:               policy.Allow,
+		Deny:                policy.Deny,
+		MaintenanceRedirect: policy.MaintenanceRedirect,
+	}
+	seen := make(map[string]bool)
+	for _, u := range policy.BasicAuth {
+		if u.User == "" || strings.ContainsAny(u.User, ": \t\r\n") {
+			return nil, InvalidAccessPolicyError{fmt.Sprintf("Invalid basic auth user name: %q", u.User)}
+		}
+		if seen[u.User] {
+			return nil, InvalidAccessPolicyError{fmt.Sprintf("Duplicate basic auth user: %s", u.User)}
+		}
+		seen[u.User] = true
+
+		hash := hashes[u.User]
+		if u.Password != "" {
+			hash = htpasswdHash(u.Password)
+		}
+		if hash == "" {
+			return nil, InvalidAccessPolicyError{fmt.Sprintf("Missing password of basic auth user: %s", u.User)}
+		}
+		result.BasicAuth = append(result.BasicAuth, userdb.BasicAuthUser{User: u.User, Hash: hash})
+	}
+
+	if len(result.Allow) == 0 && len(result.Deny) == 0 && len(result.BasicAuth) == 0 && result.MaintenanceRedirect == "" {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// isProxyURL returns true if the string is an absolute HTTP URL that can
+// be written into proxy configurations safely.
+func isProxyURL(s string) bool {
+	if strings.ContainsAny(s, " \t\r\n;{}\"'\\") {
+		return false
+	}
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func htpasswdHash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// proxyAccessPolicy converts the access policy to the form rendered by
+// the proxy.
+func proxyAccessPolicy(policy *userdb.AccessPolicy) *manifest.AccessPolicy {
+	if policy == nil {
+		return nil
+	}
+	p := &manifest.AccessPolicy{
+		Allow:               policy.Allow,
+		Deny:                policy.Deny,
+		MaintenanceRedirect: policy.MaintenanceRedirect,
+	}
+	for _, u := range policy.BasicAuth {
+		p.BasicAuth = append(p.BasicAuth, u.User+":"+u.Hash)
+	}
+	return p
+}
@@ -0,0 +1,166 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/config/defaults"
+)
+
+// Users can change the email address of their accounts, which is also the
+// user name, after the new address is confirmed. Deleted accounts are kept
+// for the grace period configured by "user.deletion_grace" and can be
+// restored before the grace period expired. Accounts are deleted
+// immediately if the grace period is zero.
+const (
+	defaultDeletionGrace = 7 * 24 * time.Hour
+	accountPurgeInterval = time.Hour
+)
+
+func deletionGrace() time.Duration {
+	if d, err := time.ParseDuration(config.Get("user.deletion_grace")); err == nil {
+		return d
+	}
+	return defaultDeletionGrace
+}
+
+// ChangeEmail sends a confirmation link to the new email address. The email
+// address of the account is changed after confirmed.
+func (br *UserBroker) ChangeEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return InvalidEmailError(email)
+	}
+	email = strings.ToLower(email)
+
+	if config.Get("smtp.host") == "" {
+		return MailNotConfiguredError{}
+	}
+
+	name := br.User.Basic().Name
+	token, err := br.Users.RequestEmailChange(name, email)
+	if err != nil {
+		return err
+	}
+
+	link := defaults.ConsoleURL() + "/email/confirm?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Please confirm the new email address of your Cloudway account %s:\n\n%s\n\n"+
+		"The link expires in 24 hours. If you didn't request the change, please ignore this mail.\n",
+		name, link)
+	return sendMail(email, "Confirm your new email address", body)
+}
+
+// ConfirmEmail changes the email address of the account with the token
+// sent to the new address. The trash of the user is moved to the new name.
+// Returns the new user name.
+func (br *Broker) ConfirmEmail(token string) (string, error) {
+	oldName, newName, err := br.Users.ConfirmEmailChange(token)
+	if err != nil {
+		return "", err
+	}
+
+	logrus.Infof("User %s changed email address to %s", oldName, newName)
+	oldTrash := filepath.Join(trashRoot(), oldName)
+	if _, err := os.Stat(oldTrash); err == nil {
+		if err = os.Rename(oldTrash, filepath.Join(trashRoot(), newName)); err != nil {
+			logrus.WithError(err).Warnf("Failed to move trash of %s", oldName)
+		}
+	}
+	return newName, nil
+}
+
+// DeleteAccount schedules deletion of the account after the grace period.
+// The deletion must be confirmed with the user name. The account can still
+// be used until deleted. Returns the time when the account will be deleted.
+func (br *UserBroker) DeleteAccount(confirm string) (time.Time, error) {
+	name := br.User.Basic().Name
+	if confirm != name {
+		return time.Time{}, AccountDeletionNotConfirmedError(name)
+	}
+
+	grace := deletionGrace()
+	if grace <= 0 {
+		return time.Now(), br.RemoveUser(name, true)
+	}
+
+	at := time.Now().Add(grace)
+	if err := br.Users.ScheduleDeletion(name, at); err != nil {
+		return time.Time{}, err
+	}
+
+	logrus.Infof("User %s scheduled account deletion at %s", name, at)
+	if strings.Contains(name, "@") {
+		body := fmt.Sprintf("Your Cloudway account %s, including all applications and repositories, "+
+			"will be deleted at %s.\n\nYou can restore the account from the console before then.\n",
+			name, at.Format(time.RFC1123))
+		go func() {
+			if err := sendMail(name, "Your Cloudway account will be deleted", body); err != nil {
+				logrus.WithError(err).Warnf("Failed to send notification to %s", name)
+			}
+		}()
+	}
+	return at, nil
+}
+
+// GetPreferences returns user interface preferences of the user.
+func (br *UserBroker) GetPreferences() (*userdb.Preferences, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	prefs := br.User.Basic().Preferences
+	return &prefs, nil
+}
+
+// SetPreferences replaces user interface preferences of the user.
+func (br *UserBroker) SetPreferences(prefs *userdb.Preferences) error {
+	return br.Users.SetPreferences(br.User.Basic().Name, prefs)
+}
+
+// RestoreAccount cancels the scheduled deletion of the account.
+func (br *UserBroker) RestoreAccount() error {
+	return br.Users.CancelDeletion(br.User.Basic().Name)
+}
+
+// StartAccountCleaner deletes accounts whose grace period expired
+// periodically until the context is canceled. Only the elected leader of
+// API servers deletes accounts.
+func (br *Broker) StartAccountCleaner(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(accountPurgeInterval)
+		defer ticker.Stop()
+		for {
+			if br.elected("accounts", 3*accountPurgeInterval) {
+				br.purgeExpiredAccounts()
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (br *Broker) purgeExpiredAccounts() {
+	names, err := br.Users.FindExpiredAccounts()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to find expired accounts")
+		return
+	}
+	for _, name := range names {
+		logrus.Infof("Deleting account %s", name)
+		if err := br.RemoveUser(name, true); err != nil {
+			logrus.WithError(err).Warnf("Failed to delete account %s", name)
+		}
+	}
+}
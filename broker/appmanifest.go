@@ -0,0 +1,270 @@
+package broker
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/hub"
+	"github.com/cloudway/platform/pkg/errors"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// The application manifest (cloudway.yml) in the application repository
+// declares the framework, services, environment variables, scaling and
+// hooks of the application. The manifest is applied when the application
+// is created, and services are reconciled against the manifest on every
+// deployment.
+
+// readAppManifest reads the application manifest from the repository
+// deployed to the framework container. Returns nil if the repository
+// doesn't contain a manifest.
+func (br *Broker) readAppManifest(ctx context.Context, c container.Container) (*manifest.AppManifest, error) {
+	r, err := c.CopyFrom(ctx, c.RepoDir()+"/"+manifest.AppManifestFile)
+	if err != nil {
+		// the manifest file is optional
+		return nil, nil
+	}
+	defer r.Close()
+
+	tr := tar.NewReader(r)
+	if _, err = tr.Next(); err != nil {
+		return nil, err
+	}
+	return manifest.ReadAppManifest(tr)
+}
+
+// applyAppManifest applies the application manifest of a new application.
+// Services are reconciled against the manifest, the application is scaled
+// up and environment variables are set as declared in the manifest. Returns
+// the plugin tags of the application.
+func (br *UserBroker) applyAppManifest(opts container.CreateOptions, tags []string, app *userdb.Application) ([]string, error) {
+	cs, err := br.FindApplications(br.ctx, opts.Name, opts.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if len(cs) == 0 {
+		return nil, ApplicationNotFoundError(opts.Name)
+	}
+
+	m, err := br.readAppManifest(br.ctx, cs[0])
+	if m == nil || err != nil {
+		return tags, err
+	}
+	if err = checkManifestFramework(m, cs[0]); err != nil {
+		return nil, err
+	}
+
+	newTags, _, err := br.reconcileServices(opts, tags, m)
+	if err != nil {
+		return nil, err
+	}
+	if newTags != nil {
+		tags = newTags
+	}
+
+	if m.Scaling > len(cs) {
+		if _, err = br.scaleUp(cs[0], m.Scaling, app); err != nil {
+			return nil, err
+		}
+		app.Scaling = m.Scaling
+	}
+
+	if len(m.Env) != 0 {
+		env := make(map[string]string)
+		for k, v := range app.Env {
+			env[k] = v
+		}
+		for k, v := range m.Env {
+			env[k] = v
+		}
+		app.Env = env
+
+		all, err := br.FindAll(br.ctx, opts.Name, opts.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		err = Parallel(all, func(c container.Container) error {
+			for k, v := range m.Env {
+				if err := c.Setenv(br.ctx, k, v); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+// reconcileAppManifest reconciles services of the deployed application
+// against the application manifest. New services are started after
+// creation.
+func (br *Broker) reconcileAppManifest(ctx context.Context, name, namespace string, log *serverlog.ServerLog) error {
+	owner, err := br.Users.FindByNamespace(namespace)
+	if err != nil {
+		return err
+	}
+	app := owner.Basic().Applications[name]
+	if app == nil {
+		// the manifest of a new application is applied on creation
+		return nil
+	}
+
+	cs, err := br.FindApplications(ctx, name, namespace)
+	if err != nil || len(cs) == 0 {
+		return err
+	}
+	m, err := br.readAppManifest(ctx, cs[0])
+	if m == nil || err != nil {
+		return err
+	}
+	if err = checkManifestFramework(m, cs[0]); err != nil {
+		return err
+	}
+
+	ub := br.NewUserBroker(owner, ctx)
+	opts := container.CreateOptions{
+		Name:      name,
+		Namespace: namespace,
+		Secret:    app.Secret,
+		Hosts:     app.Hosts,
+		Labels:    app.Labels,
+		Log:       log,
+	}
+	tags, created, err := ub.reconcileServices(opts, app.Plugins, m)
+	if tags != nil {
+		_, er := br.Users.ModifyApplication(owner.Basic().Name, name, func(app *userdb.Application) error {
+			app.Plugins = tags
+			return nil
+		})
+		if err == nil {
+			err = er
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return ub.StartContainers(created, log)
+}
+
+// reconcileServices creates services declared in the application manifest
+// but missing in the application, and removes services no longer declared
+// in the manifest. Services are identified by plugin names, so changing the
+// plugin version in the manifest doesn't recreate the service. Returns the
+// new plugin tags of the application, or nil if services are up to date.
+func (br *UserBroker) reconcileServices(opts container.CreateOptions, tags []string, m *manifest.AppManifest) (newTags []string, created []container.Container, err error) {
+	var (
+		declared = make(map[string]bool)
+		names    []string
+		plugins  []*manifest.Plugin
+	)
+	for _, tag := range m.Services {
+		n, p, err := br.getPluginInfoWithNames(tag)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", manifest.AppManifestFile, err)
+		}
+		if !p.IsService() {
+			return nil, nil, fmt.Errorf("%s: '%s' is not a service plugin", manifest.AppManifestFile, tag)
+		}
+		if key := pluginName(p.Tag); !declared[key] {
+			declared[key] = true
+			names, plugins = append(names, n), append(plugins, p)
+		}
+	}
+
+	cs, err := br.FindAll(br.ctx, opts.Name, opts.Namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	existing := make(map[string]bool)
+	var removed []container.Container
+	for _, c := range cs {
+		if !c.Category().IsService() {
+			continue
+		}
+		if key := pluginName(c.PluginTag()); declared[key] {
+			existing[key] = true
+		} else {
+			removed = append(removed, c)
+		}
+	}
+
+	var addNames []string
+	var addPlugins []*manifest.Plugin
+	for i, p := range plugins {
+		if !existing[pluginName(p.Tag)] {
+			addNames, addPlugins = append(addNames, names[i]), append(addPlugins, p)
+		}
+	}
+	if len(addPlugins) == 0 && len(removed) == 0 {
+		return nil, nil, nil
+	}
+
+	// remove undeclared services
+	for _, c := range removed {
+		if err = checkLinks(br.User.Basic().Applications, opts.Name, c.ServiceName()); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, c := range removed {
+		fmt.Fprintf(opts.Log, "Removing service %s\n", c.PluginTag())
+	}
+	var errors errors.Errors
+	errors.Add(Parallel(removed, func(c container.Container) error {
+		return br.notify(ContainerDestroyed, c, c.Destroy(br.ctx))
+	}))
+	for _, tag := range tags {
+		keep := true
+		for _, c := range removed {
+			if tag == c.PluginTag() {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			newTags = append(newTags, tag)
+		}
+	}
+	if err = errors.Err(); err != nil {
+		return newTags, nil, err
+	}
+
+	// create declared services
+	for _, p := range addPlugins {
+		fmt.Fprintf(opts.Log, "Adding service %s\n", p.Tag)
+	}
+	created, err = br.createContainers(opts, addNames, addPlugins)
+	for _, c := range created {
+		newTags = append(newTags, c.PluginTag())
+	}
+	return newTags, created, err
+}
+
+// checkManifestFramework checks that the framework declared in the
+// application manifest matches the framework of the application.
+func checkManifestFramework(m *manifest.AppManifest, c container.Container) error {
+	if m.Framework != "" && pluginName(m.Framework) != pluginName(c.PluginTag()) {
+		return fmt.Errorf("%s: the framework '%s' doesn't match the application framework '%s'",
+			manifest.AppManifestFile, m.Framework, c.PluginTag())
+	}
+	return nil
+}
+
+// pluginName returns the plugin name without service name and version.
+func pluginName(tag string) string {
+	_, namespace, name, _, err := hub.ParseTag(tag)
+	if err != nil {
+		return tag
+	}
+	if namespace != "" {
+		return namespace + "/" + name
+	}
+	return name
+}
@@ -2,24 +2,29 @@ package broker
 
 import (
 	"archive/tar"
+	"bufio"
+	"bytes"
 	"compress/gzip"
 	"context"
 	"crypto/rand"
 	"crypto/sha1"
 	"encoding/hex"
-	errs "errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/docker/go-units"
 
+	"github.com/cloudway/platform/api/types"
 	"github.com/cloudway/platform/auth/userdb"
 	"github.com/cloudway/platform/config/defaults"
 	"github.com/cloudway/platform/container"
@@ -39,6 +44,18 @@ func (br *UserBroker) GetApplications() (apps map[string]*userdb.Application, er
 }
 
 func (br *UserBroker) CreateApplication(opts container.CreateOptions, tags []string) (app *userdb.Application, containers []container.Container, err error) {
+	if err = checkApplicationName(opts.Name); err != nil {
+		return
+	}
+	populate := func(opts *container.CreateOptions, framework *manifest.Plugin) error {
+		return populateRepo(br.SCM(), opts, framework)
+	}
+	return br.createApplication(opts, tags, populate)
+}
+
+// createApplication creates an application and populates the repository
+// with the given function.
+func (br *UserBroker) createApplication(opts container.CreateOptions, tags []string, populate func(*container.CreateOptions, *manifest.Plugin) error) (app *userdb.Application, containers []container.Container, err error) {
 	if err = br.Refresh(); err != nil {
 		return
 	}
@@ -51,47 +68,42 @@ func (br *UserBroker) CreateApplication(opts container.CreateOptions, tags []str
 		err = ApplicationExistError{opts.Name, user.Namespace}
 		return
 	}
+	if user.Services[opts.Name] != nil {
+		err = ServiceExistError{opts.Name, user.Namespace}
+		return
+	}
+	if err = ValidateLabels(opts.Labels); err != nil {
+		return
+	}
+
+	namespace := user.Namespace
+	if namespace == "" {
+		namespace = opts.Namespace
+	}
+	unlock, err := br.lockApp(opts.Name, namespace, "create")
+	if err != nil {
+		return
+	}
+	defer unlock()
 
 	if opts.Scaling == 0 {
 		opts.Scaling = 1
 	}
 
 	// check plugins
-	var (
-		names     = make([]string, len(tags))
-		plugins   = make([]*manifest.Plugin, len(tags))
-		framework *manifest.Plugin
-	)
-	for i, tag := range tags {
-		n, p, er := br.getPluginInfoWithNames(tag)
-		if er != nil {
-			err = er
-			return
-		}
-		if p.IsFramework() {
-			if framework != nil {
-				err = fmt.Errorf("Multiple framework plugins specified: %s and %s", p.Name, framework.Name)
-				return
-			}
-			framework = p
-			n = ""
-		} else if !p.IsService() {
-			err = fmt.Errorf("'%s' must be a framework or service plugin", tag)
-			return
-		}
-		names[i], plugins[i], tags[i] = n, p, p.Tag
-	}
-	if framework == nil {
-		err = fmt.Errorf("No framework plugin specified")
+	names, plugins, framework, err := br.checkPlugins(tags)
+	if err != nil {
 		return
 	}
 
 	// Generate shared secret for application. The shared secret is a simple
 	// mechanism for a scalable application to communicate securely between
 	// containers, or used as a randomize seed to generate shared tokens.
-	opts.Secret, err = generateSharedSecret()
-	if err != nil {
-		return
+	if opts.Secret == "" {
+		opts.Secret, err = generateSharedSecret()
+		if err != nil {
+			return
+		}
 	}
 
 	// cleanup on failure
@@ -100,13 +112,13 @@ func (br *UserBroker) CreateApplication(opts container.CreateOptions, tags []str
 	defer func() {
 		if !success {
 			for _, c := range containers {
-				c.Destroy(br.ctx)
+				br.notify(ContainerDestroyed, c, c.Destroy(br.ctx))
 			}
 			if repoCreated {
-				br.SCM.RemoveRepo(opts.Namespace, opts.Name)
+				br.SCM().RemoveRepo(opts.Namespace, opts.Name)
 			}
 			if namespaceCreated {
-				br.SCM.RemoveNamespace(opts.Namespace)
+				br.SCM().RemoveNamespace(opts.Namespace)
 			}
 		}
 	}()
@@ -132,54 +144,152 @@ func (br *UserBroker) CreateApplication(opts container.CreateOptions, tags []str
 	leftovers, err := br.FindAll(br.ctx, opts.Name, opts.Namespace)
 	if err == nil {
 		for _, c := range leftovers {
-			c.Destroy(br.ctx)
+			br.notify(ContainerDestroyed, c, c.Destroy(br.ctx))
 		}
 	}
 
 	// create all containers
+	opts.Log.Progress("create", 1, 4, "Creating containers")
 	containers, err = br.createContainers(opts, names, plugins)
 	if err != nil {
 		return
 	}
 
 	// create repository for the application
-	err = br.SCM.CreateRepo(opts.Namespace, opts.Name, true)
+	opts.Log.Progress("create", 2, 4, "Creating repository")
+	err = br.SCM().CreateRepo(opts.Namespace, opts.Name, true)
 	if err != nil {
 		return
 	}
 	repoCreated = true
 
 	// populate and deploy application
-	if err = populateRepo(br.SCM, &opts, framework); err != nil {
+	opts.Log.Progress("create", 3, 4, "Populating repository")
+	if err = populate(&opts, framework); err != nil {
 		return
 	}
-	if err = br.Deploy(opts.Name, opts.Namespace, "", opts.Log); err != nil {
+	opts.Log.Progress("create", 4, 4, "Deploying application")
+	if err = br.deploy(br.ctx, opts.Name, opts.Namespace, "", opts.Log); err != nil {
 		return
 	}
 
-	// add application to the user database
+	// apply the application manifest in the repository
 	app = &userdb.Application{
 		CreatedAt: time.Now(),
-		Plugins:   tags,
+		Hosts:     opts.Hosts,
 		Secret:    opts.Secret,
+		Labels:    opts.Labels,
+		Scaling:   opts.Scaling,
+		Env:       opts.Env,
 	}
-	apps[opts.Name] = app
-	err = br.Users.Update(user.Name, userdb.Args{"applications": apps})
+	if app.Plugins, err = br.applyAppManifest(opts, tags, app); err != nil {
+		return
+	}
+	if containers, err = br.FindAll(br.ctx, opts.Name, opts.Namespace); err != nil {
+		return
+	}
+
+	// add application to the user database
+	err = br.Users.SaveApplication(user.Name, opts.Name, app)
 	if err != nil {
 		return
 	}
+	apps[opts.Name] = app
+	updateDNS(app.Hosts, nil)
 
 	success = true
+	br.notifyApp(ApplicationCreated, opts.Name, opts.Namespace, map[string]string{"plugins": strings.Join(app.Plugins, ",")})
+	return
+}
+
+// ValidateCreateApplication validates options of a new application before
+// any container is created. In addition to the syntax of options, the
+// application must not exist and plugins must be installed. Returns a
+// *types.ValidationError with messages of all invalid fields.
+func (br *UserBroker) ValidateCreateApplication(opts *types.CreateApplication) error {
+	verr := opts.Validate()
+
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+	user := br.User.Basic()
+	if verr.Field("name") == "" {
+		if err := checkApplicationName(opts.Name); err != nil {
+			verr.Add("name", err.Error())
+		} else if user.Applications[opts.Name] != nil {
+			verr.Add("name", ApplicationExistError{opts.Name, user.Namespace}.Error())
+		} else if user.Services[opts.Name] != nil {
+			verr.Add("name", ServiceExistError{opts.Name, user.Namespace}.Error())
+		}
+	}
+
+	if opts.Framework != "" {
+		if _, p, err := br.getPluginInfoWithNames(opts.Framework); err != nil {
+			verr.Add("framework", err.Error())
+		} else if !p.IsFramework() {
+			verr.Add("framework", fmt.Sprintf("'%s' is not a framework plugin", opts.Framework))
+		}
+	}
+	if verr.Field("services") == "" {
+		for _, tag := range opts.Services {
+			if _, p, err := br.getPluginInfoWithNames(tag); err != nil {
+				verr.Add("services", err.Error())
+				break
+			} else if !p.IsService() {
+				verr.Add("services", fmt.Sprintf("'%s' is not a service plugin", tag))
+				break
+			}
+		}
+	}
+
+	if err := ValidateLabels(opts.Labels); err != nil {
+		verr.Add("labels", err.Error())
+	}
+	return verr.Err()
+}
+
+// checkPlugins resolves plugin tags of a new application, which must have
+// exactly one framework plugin. The tags are replaced with full plugin tags,
+// and service names are returned for service plugins.
+func (br *UserBroker) checkPlugins(tags []string) (names []string, plugins []*manifest.Plugin, framework *manifest.Plugin, err error) {
+	names = make([]string, len(tags))
+	plugins = make([]*manifest.Plugin, len(tags))
+	for i, tag := range tags {
+		n, p, er := br.getPluginInfoWithNames(tag)
+		if er != nil {
+			err = er
+			return
+		}
+		if p.IsFramework() {
+			if framework != nil {
+				err = fmt.Errorf("Multiple framework plugins specified: %s and %s", p.Name, framework.Name)
+				return
+			}
+			framework = p
+			n = ""
+		} else if !p.IsService() {
+			err = fmt.Errorf("'%s' must be a framework or service plugin", tag)
+			return
+		}
+		names[i], plugins[i], tags[i] = n, p, p.Tag
+	}
+	if framework == nil {
+		err = fmt.Errorf("No framework plugin specified")
+	}
 	return
 }
 
-func populateRepo(scm scm.SCM, opts *container.CreateOptions, framework *manifest.Plugin) error {
+func populateRepo(s scm.SCM, opts *container.CreateOptions, framework *manifest.Plugin) error {
 	if strings.ToLower(opts.Repo) == "empty" {
 		return nil
 	} else if opts.Repo == "" {
-		return populateFromTemplate(scm, opts, filepath.Join(framework.Path, "template"))
+		return populateFromTemplate(s, opts, filepath.Join(framework.Path, "template"))
 	} else {
-		return scm.PopulateURL(opts.Namespace, opts.Name, opts.Repo)
+		var cred *scm.Credentials
+		if opts.RepoUser != "" {
+			cred = &scm.Credentials{Username: opts.RepoUser, Password: opts.RepoPass}
+		}
+		return scm.PopulateURL(s, opts.Namespace, opts.Name, opts.Repo, cred)
 	}
 }
 
@@ -211,8 +321,65 @@ func populateFromTemplate(scm scm.SCM, opts *container.CreateOptions, template s
 	return err
 }
 
-func (br *Broker) Deploy(name, namespace, branch string, log *serverlog.ServerLog) error {
-	return br.SCM.Deploy(br.Engine, namespace, name, branch, log)
+// Deploy deploys the application from the given branch. The deployment is
+// aborted if the context is canceled.
+func (br *Broker) Deploy(ctx context.Context, name, namespace, branch string, log *serverlog.ServerLog) error {
+	unlock, err := br.lockApp(name, namespace, "deploy")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return br.deploy(ctx, name, namespace, branch, log)
+}
+
+func (br *Broker) deploy(ctx context.Context, name, namespace, branch string, log *serverlog.ServerLog) (err error) {
+	if err = CheckReadOnly(); err != nil {
+		return err
+	}
+	if err = br.checkDeployAllowed(name, namespace); err != nil {
+		return err
+	}
+	if err = br.checkDiskQuota(ctx, name, namespace); err != nil {
+		return err
+	}
+
+	commit := br.resolveCommit(namespace, name, branch)
+	d := &Deployment{
+		Name:      name,
+		Namespace: namespace,
+		Source:    DeploySourceBranch,
+		Branch:    branch,
+		Commit:    commit,
+	}
+	log, finish := br.startDeployment(ctx, d, log)
+	defer func() { finish(err) }()
+
+	br.setDeployStatus(namespace, name, commit, scm.StatusPending, "Deployment in progress")
+	if err = br.SCM().Deploy(ctx, br.Engine, namespace, name, branch, log); err != nil {
+		br.setDeployStatus(namespace, name, commit, scm.StatusFailed, err.Error())
+		return err
+	}
+	br.setDeployStatus(namespace, name, commit, scm.StatusSuccess, "Deployed to "+name+"-"+namespace)
+	if current, err := br.SCM().GetDeploymentBranch(namespace, name); err == nil {
+		d.Branch = current.DisplayId
+	}
+	d.Artifact = br.archiveBuild(ctx, name, namespace, commit, "")
+	br.publishBuild(ctx, name, namespace, d.Artifact, log)
+	br.notifyApp(ApplicationDeployed, name, namespace, map[string]string{"branch": branch, "commit": commit})
+
+	// services are reconciled against the application manifest
+	if err = br.reconcileAppManifest(ctx, name, namespace, log); err != nil {
+		return err
+	}
+
+	// application containers are restarted after deployment
+	cs, err := br.FindApplications(ctx, name, namespace)
+	if err == nil {
+		for _, c := range cs {
+			br.notify(ContainerStarted, c, nil)
+		}
+	}
+	return nil
 }
 
 func generateSharedSecret() (string, error) {
@@ -236,6 +403,12 @@ func (br *UserBroker) CreateServices(opts container.CreateOptions, tags []string
 		return nil, ApplicationNotFoundError(opts.Name)
 	}
 
+	unlock, err := br.lockApp(opts.Name, user.Namespace, "service creation")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	// check service plugins
 	var (
 		names   = make([]string, len(tags))
@@ -255,18 +428,27 @@ func (br *UserBroker) CreateServices(opts container.CreateOptions, tags []string
 	opts.Namespace = user.Namespace
 	opts.Secret = app.Secret
 	opts.Hosts = app.Hosts
+	opts.Labels = app.Labels
 
 	containers, err = br.createContainers(opts, names, plugins)
 	if err != nil {
 		return nil, err
 	}
 
-	app.Plugins = append(app.Plugins, tags...)
-	err = br.Users.Update(user.Name, userdb.Args{"applications": user.Applications})
+	app, err = br.Users.ModifyApplication(user.Name, opts.Name, func(app *userdb.Application) error {
+		app.Plugins = append(app.Plugins, tags...)
+		return nil
+	})
+	if err == nil {
+		user.Applications[opts.Name] = app
+	}
 	return containers, err
 }
 
 func (br *UserBroker) createContainers(opts container.CreateOptions, serviceNames []string, plugins []*manifest.Plugin) (containers []container.Container, err error) {
+	if err = br.CheckSchedulable(); err != nil {
+		return
+	}
 	for i, plugin := range plugins {
 		opts.Plugin = plugin
 		opts.ServiceName = serviceNames[i]
@@ -280,7 +462,49 @@ func (br *UserBroker) createContainers(opts container.CreateOptions, serviceName
 	return
 }
 
-func (br *UserBroker) RemoveApplication(name string) (err error) {
+// Create creates containers with the registry credentials of the namespace
+// owner, so images can be built from base images in private registries,
+// the log shipping configuration of the application, so it's preserved
+// when containers are recreated, and the domain delegated to the namespace.
+// Credentials configured for the platform are added by the engine.
+func (br *Broker) Create(ctx context.Context, opts container.CreateOptions) ([]container.Container, error) {
+	owner, err := br.Users.FindByNamespace(opts.Namespace)
+	if err != nil {
+		return br.Engine.Create(ctx, opts)
+	}
+
+	user := owner.Basic()
+	if opts.Registries == nil {
+		for _, reg := range user.Registries {
+			opts.Registries = append(opts.Registries, container.RegistryAuth{
+				Server:   reg.Server,
+				Username: reg.Username,
+				Password: reg.Password,
+			})
+		}
+	}
+	if app := user.Applications[opts.Name]; app != nil && app.Logging != nil && opts.Logging == nil {
+		opts.Logging = (*container.LogConfig)(app.Logging)
+	}
+	if opts.Domain == "" {
+		opts.Domain = user.Domain
+	}
+	return br.Engine.Create(ctx, opts)
+}
+
+// RemoveApplication removes the application. The application repository
+// and data are kept in the trash for the retention period if the trash
+// is enabled, and can be restored by RestoreApplication.
+func (br *UserBroker) RemoveApplication(name string) error {
+	return br.removeApplication(name, false)
+}
+
+// PurgeApplication removes the application permanently, bypassing trash.
+func (br *UserBroker) PurgeApplication(name string) error {
+	return br.removeApplication(name, true)
+}
+
+func (br *UserBroker) removeApplication(name string, purge bool) (err error) {
 	if err = br.Refresh(); err != nil {
 		return err
 	}
@@ -291,6 +515,23 @@ func (br *UserBroker) RemoveApplication(name string) (err error) {
 	if apps[name] == nil {
 		return ApplicationNotFoundError(name)
 	}
+	if err = checkLinks(apps, name, ""); err != nil {
+		return err
+	}
+
+	unlock, err := br.lockApp(name, user.Namespace, "removal")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// keep the application in trash, the application is not removed
+	// if failed to archive
+	if retention := trashRetention(); !purge && retention > 0 {
+		if err = br.moveToTrash(name, apps[name], retention); err != nil {
+			return err
+		}
+	}
 
 	var errors errors.Errors
 
@@ -300,17 +541,104 @@ func (br *UserBroker) RemoveApplication(name string) (err error) {
 	if err != nil {
 		errors.Add(err)
 	} else {
-		for _, c := range containers {
-			errors.Add(c.Destroy(br.ctx))
-		}
+		errors.Add(Parallel(containers, func(c container.Container) error {
+			return br.notify(ContainerDestroyed, c, c.Destroy(br.ctx))
+		}))
 	}
 
-	// remove application repository
-	errors.Add(br.SCM.RemoveRepo(user.Namespace, name))
+	// remove application repository and build artifacts
+	errors.Add(br.SCM().RemoveRepo(user.Namespace, name))
+	errors.Add(removeArtifacts(name, user.Namespace))
+	errors.Add(removeDeployments(name, user.Namespace))
 
 	// remove application from user database
+	hosts := apps[name].Hosts
+	delete(apps, name)
+	errors.Add(br.Users.RemoveApplication(user.Name, name))
+
+	if err = errors.Err(); err == nil {
+		updateDNS(nil, hosts)
+		br.notifyApp(ApplicationRemoved, name, user.Namespace, nil)
+	}
+	return err
+}
+
+// RenameApplication changes the name of an application. The repository is
+// renamed in the SCM, containers are recreated with the new name, and the
+// old application URL is redirected to the new URL for a grace period.
+func (br *UserBroker) RenameApplication(name, newName string) (err error) {
+	if err = br.Refresh(); err != nil {
+		return err
+	}
+
+	user := br.User.Basic()
+	apps := user.Applications
+
+	app := apps[name]
+	if app == nil {
+		return ApplicationNotFoundError(name)
+	}
+	if newName == name {
+		return nil
+	}
+	if apps[newName] != nil {
+		return ApplicationExistError{newName, user.Namespace}
+	}
+	if user.Services[newName] != nil {
+		return ServiceExistError{newName, user.Namespace}
+	}
+	if err = checkApplicationName(newName); err != nil {
+		return err
+	}
+	if err = checkLinks(apps, name, ""); err != nil {
+		return err
+	}
+
+	unlock, err := br.lockApp(name, user.Namespace, "rename")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	unlockNew, err := br.lockApp(newName, user.Namespace, "rename")
+	if err != nil {
+		return err
+	}
+	defer unlockNew()
+
+	// rename the repository first, which also checks the conflict in SCM
+	if err = scm.RenameRepo(br.SCM(), user.Namespace, name, newName); err != nil {
+		return err
+	}
+
+	// update user database, restore the repository if failed
+	newApp := *app
+	newApp.Version = 0
+	if err = br.Users.SaveApplication(user.Name, newName, &newApp); err != nil {
+		if er := scm.RenameRepo(br.SCM(), user.Namespace, newName, name); er != nil {
+			logrus.WithError(er).Errorf("Failed to restore repository %s-%s", name, user.Namespace)
+		}
+		return err
+	}
+
 	delete(apps, name)
-	errors.Add(br.Users.Update(user.Name, userdb.Args{"applications": apps}))
+	apps[newName] = &newApp
+
+	var errors errors.Errors
+	errors.Add(br.Users.RemoveApplication(user.Name, name))
+	errors.Add(br.renameContainers(name, user.Namespace, newName, user.Namespace, user.Domain))
+	if err = renameArtifacts(name, user.Namespace, newName, user.Namespace); err != nil {
+		logrus.WithError(err).Warnf("Failed to rename artifacts of %s-%s", name, user.Namespace)
+	}
+	if err = renameDeployments(name, user.Namespace, newName, user.Namespace); err != nil {
+		logrus.WithError(err).Warnf("Failed to rename deployment history of %s-%s", name, user.Namespace)
+	}
+
+	redirects := map[string]string{
+		appHost(name, user.Namespace, user.Domain): appURL(newName, user.Namespace, user.Domain),
+	}
+	if err = addRedirects(redirects); err != nil {
+		logrus.WithError(err).Warn("Failed to add redirect for renamed application")
+	}
 
 	return errors.Err()
 }
@@ -326,6 +654,15 @@ func (br *UserBroker) RemoveService(name, service string) (err error) {
 	if app == nil {
 		return ApplicationNotFoundError(name)
 	}
+	if err = checkLinks(user.Applications, name, service); err != nil {
+		return err
+	}
+
+	unlock, err := br.lockApp(name, user.Namespace, "service removal")
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	var errors errors.Errors
 	var containers []container.Container
@@ -338,19 +675,26 @@ func (br *UserBroker) RemoveService(name, service string) (err error) {
 		return fmt.Errorf("service '%s' not found in application '%s'", service, name)
 	}
 
-	for _, c := range containers {
-		errors.Add(c.Destroy(br.ctx))
+	errors.Add(Parallel(containers, func(c container.Container) error {
+		return br.notify(ContainerDestroyed, c, c.Destroy(br.ctx))
+	}))
 
-		tag := c.PluginTag()
-		for i := range app.Plugins {
-			if tag == app.Plugins[i] {
-				app.Plugins = append(app.Plugins[:i], app.Plugins[i+1:]...)
-				break
+	app, err = br.Users.ModifyApplication(user.Name, name, func(app *userdb.Application) error {
+		for _, c := range containers {
+			tag := c.PluginTag()
+			for i := range app.Plugins {
+				if tag == app.Plugins[i] {
+					app.Plugins = append(app.Plugins[:i], app.Plugins[i+1:]...)
+					break
+				}
 			}
 		}
+		return nil
+	})
+	if err == nil {
+		user.Applications[name] = app
 	}
-
-	errors.Add(br.Users.Update(user.Name, userdb.Args{"applications": user.Applications}))
+	errors.Add(err)
 	return errors.Err()
 }
 
@@ -380,6 +724,13 @@ func (br *UserBroker) ScaleApplication(name string, num int) ([]container.Contai
 	if app == nil {
 		return nil, ApplicationNotFoundError(name)
 	}
+
+	unlock, err := br.lockApp(name, user.Namespace, "scaling")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	cs, err := br.FindApplications(br.ctx, name, user.Namespace)
 	if err != nil {
 		return nil, err
@@ -388,16 +739,32 @@ func (br *UserBroker) ScaleApplication(name string, num int) ([]container.Contai
 		return nil, ApplicationNotFoundError(name)
 	}
 
+	var containers []container.Container
 	if len(cs) < num {
-		return br.scaleUp(cs[0], num, app.Secret, app.Hosts)
+		containers, err = br.scaleUp(cs[0], num, app)
 	} else if len(cs) > num {
-		return nil, br.scaleDown(cs, len(cs)-num)
-	} else {
-		return nil, nil
+		err = br.scaleDown(cs, len(cs)-num)
+	}
+	if err != nil {
+		return containers, err
 	}
+
+	// record the scaling number to be restored by reconciliation
+	app, err = br.Users.ModifyApplication(user.Name, name, func(app *userdb.Application) error {
+		app.Scaling = num
+		return nil
+	})
+	if err == nil {
+		user.Applications[name] = app
+	}
+	return containers, err
 }
 
-func (br *UserBroker) scaleUp(replica container.Container, num int, secret string, hosts []string) (containers []container.Container, err error) {
+func (br *UserBroker) scaleUp(replica container.Container, num int, app *userdb.Application) (containers []container.Container, err error) {
+	if err = br.CheckSchedulable(); err != nil {
+		return
+	}
+
 	meta, err := br.Hub.GetPluginInfo(replica.PluginTag())
 	if err != nil {
 		return
@@ -406,11 +773,12 @@ func (br *UserBroker) scaleUp(replica container.Container, num int, secret strin
 	opts := container.CreateOptions{
 		Name:      replica.Name(),
 		Namespace: replica.Namespace(),
-		Hosts:     hosts,
+		Hosts:     app.Hosts,
 		Plugin:    meta,
 		Home:      replica.Home(),
 		User:      replica.User(),
-		Secret:    secret,
+		Secret:    app.Secret,
+		Labels:    app.Labels,
 		Scaling:   num,
 	}
 
@@ -418,6 +786,16 @@ func (br *UserBroker) scaleUp(replica container.Container, num int, secret strin
 	if err != nil {
 		return
 	}
+	if app.AccessPolicy != nil {
+		if err = br.applyAccessPolicy(containers, app.AccessPolicy); err != nil {
+			return
+		}
+	}
+	if app.HTTPSettings != nil {
+		if err = br.applyHTTPSettings(containers, app.HTTPSettings); err != nil {
+			return
+		}
+	}
 
 	repo, err := replica.CopyFrom(br.ctx, replica.RepoDir()+"/.")
 	if err != nil {
@@ -425,22 +803,23 @@ func (br *UserBroker) scaleUp(replica container.Container, num int, secret strin
 	}
 	defer repo.Close()
 
-	err = br.DistributeRepo(br.ctx, containers, repo, true)
+	err = br.DistributeRepo(br.ctx, containers, repo, true, nil)
 	return
 }
 
 func (br *UserBroker) scaleDown(containers []container.Container, num int) error {
-	for i := 0; i < num; i++ {
-		if err := containers[i].Destroy(br.ctx); err != nil {
-			return err
-		}
-	}
-	return nil
+	return Parallel(containers[:num], func(c container.Container) error {
+		return br.notify(ContainerDestroyed, c, c.Destroy(br.ctx))
+	})
 }
 
 func (br *UserBroker) AddHost(name, host string) error {
-	if host == "" || strings.HasSuffix(host, "."+defaults.Domain()) {
-		return errs.New("Invalid domain name")
+	host = strings.ToLower(host)
+	if !domainPattern.MatchString(host) || len(host) > 253 {
+		return InvalidDomainError{host, "not a valid host name"}
+	}
+	if strings.HasSuffix(host, "."+defaults.Domain()) {
+		return InvalidDomainError{host, "hosts under the platform domain are reserved"}
 	}
 
 	if err := br.Refresh(); err != nil {
@@ -459,21 +838,41 @@ func (br *UserBroker) AddHost(name, host string) error {
 		}
 	}
 
+	if err := br.checkDelegatedHost(host); err != nil {
+		return err
+	}
+
 	cs, err := br.FindAll(br.ctx, name, user.Namespace)
 	if err != nil {
 		return err
 	}
 
-	for _, c := range cs {
+	err = Parallel(cs, func(c container.Container) error {
 		if c.Category().IsFramework() {
-			c.AddHost(br.ctx, host)
+			return br.notify(ContainerUpdated, c, c.AddHost(br.ctx, host))
 		} else if c.Category().IsService() {
-			c.AddHost(br.ctx, c.ServiceName()+"."+host)
+			return br.notify(ContainerUpdated, c, c.AddHost(br.ctx, c.ServiceName()+"."+host))
 		}
+		return nil
+	})
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to add host %s to application %s", host, name)
 	}
 
-	app.Hosts = append(app.Hosts, host)
-	return br.Users.Update(user.Name, userdb.Args{"applications": user.Applications})
+	app, err = br.Users.ModifyApplication(user.Name, name, func(app *userdb.Application) error {
+		for _, h := range app.Hosts {
+			if host == h {
+				return nil
+			}
+		}
+		app.Hosts = append(app.Hosts, host)
+		return nil
+	})
+	if err == nil {
+		user.Applications[name] = app
+		updateDNS([]string{host}, nil)
+	}
+	return err
 }
 
 func (br *UserBroker) RemoveHost(name, host string) error {
@@ -488,9 +887,8 @@ func (br *UserBroker) RemoveHost(name, host string) error {
 	}
 
 	var removed bool
-	for i, h := range app.Hosts {
+	for _, h := range app.Hosts {
 		if host == h {
-			app.Hosts = append(app.Hosts[:i], app.Hosts[i+1:]...)
 			removed = true
 		}
 	}
@@ -503,30 +901,54 @@ func (br *UserBroker) RemoveHost(name, host string) error {
 		return err
 	}
 
-	for _, c := range cs {
+	err = Parallel(cs, func(c container.Container) error {
 		if c.Category().IsFramework() {
-			c.RemoveHost(br.ctx, host)
+			return br.notify(ContainerUpdated, c, c.RemoveHost(br.ctx, host))
 		} else if c.Category().IsService() {
-			c.RemoveHost(br.ctx, c.ServiceName()+"."+host)
+			return br.notify(ContainerUpdated, c, c.RemoveHost(br.ctx, c.ServiceName()+"."+host))
 		}
+		return nil
+	})
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to remove host %s from application %s", host, name)
 	}
 
-	return br.Users.Update(user.Name, userdb.Args{"applications": user.Applications})
+	app, err = br.Users.ModifyApplication(user.Name, name, func(app *userdb.Application) error {
+		hosts := app.Hosts[:0]
+		for _, h := range app.Hosts {
+			if host != h {
+				hosts = append(hosts, h)
+			}
+		}
+		app.Hosts = hosts
+		return nil
+	})
+	if err == nil {
+		user.Applications[name] = app
+		updateDNS(nil, []string{host})
+	}
+	return err
 }
 
 func (br *UserBroker) StartApplication(name string, log *serverlog.ServerLog) error {
-	return br.startApplication(name, func(c container.Container) error {
-		return c.Start(br.ctx, log)
+	return br.startApplication(name, "start", log, func(c container.Container) error {
+		return br.notify(ContainerStarted, c, c.Start(br.ctx, log))
 	})
 }
 
 func (br *UserBroker) RestartApplication(name string, log *serverlog.ServerLog) error {
-	return br.startApplication(name, func(c container.Container) error {
-		return c.Restart(br.ctx, log)
+	return br.startApplication(name, "restart", log, func(c container.Container) error {
+		return br.notify(ContainerStarted, c, c.Restart(br.ctx, log))
 	})
 }
 
-func (br *UserBroker) startApplication(name string, fn func(container.Container) error) error {
+func (br *UserBroker) startApplication(name, op string, log *serverlog.ServerLog, fn func(container.Container) error) error {
+	unlock, err := br.lockApp(name, br.Namespace(), op)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	containers, err := br.FindAll(br.ctx, name, br.Namespace())
 	if err != nil {
 		return err
@@ -534,10 +956,19 @@ func (br *UserBroker) startApplication(name string, fn func(container.Container)
 	if len(containers) == 0 {
 		return ApplicationNotFoundError(name)
 	}
-	return startContainers(containers, fn)
+	if err = br.checkDiskQuota(br.ctx, name, br.Namespace()); err != nil {
+		return err
+	}
+	return startContainers(containers, withProgress(log, len(containers), fn))
 }
 
 func (br *UserBroker) StopApplication(name string) error {
+	unlock, err := br.lockApp(name, br.Namespace(), "stop")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	containers, err := br.FindAll(br.ctx, name, br.Namespace())
 	if err != nil {
 		return err
@@ -545,15 +976,36 @@ func (br *UserBroker) StopApplication(name string) error {
 	if len(containers) == 0 {
 		return ApplicationNotFoundError(name)
 	}
-	return runParallel(err, containers, func(c container.Container) error { return c.Stop(br.ctx) })
+	return runParallel(err, containers, func(c container.Container) error {
+		return br.notify(ContainerStopped, c, c.Stop(br.ctx))
+	})
 }
 
 func (br *UserBroker) StartContainers(containers []container.Container, log *serverlog.ServerLog) error {
-	return startContainers(containers, func(c container.Container) error {
-		return c.Start(br.ctx, log)
-	})
+	return startContainers(containers, withProgress(log, len(containers), func(c container.Container) error {
+		return br.notify(ContainerStarted, c, c.Start(br.ctx, log))
+	}))
 }
 
+// withProgress wraps the container function to report the number of
+// containers that have been started.
+func withProgress(log *serverlog.ServerLog, total int, fn func(container.Container) error) func(container.Container) error {
+	var step int32
+	return func(c container.Container) error {
+		err := fn(c)
+		if err == nil {
+			n := atomic.AddInt32(&step, 1)
+			log.Progress("start", int(n), total, "Started "+c.Name())
+		}
+		return err
+	}
+}
+
+// startContainers starts containers in the order of service dependencies.
+// Services without dependencies are started first, followed by services
+// depending on other services, and framework containers are started at
+// last. Starting a service container waits until the service is ready to
+// accept connections, so dependent containers never see a missing service.
 func startContainers(containers []container.Container, fn func(container.Container) error) error {
 	err := container.ResolveServiceDependencies(containers)
 	if err != nil {
@@ -589,10 +1041,24 @@ func makeSchedule(containers []container.Container) *schedule {
 	return sch
 }
 
-func runParallel(err error, cs []container.Container, fn func(container.Container) error) error {
-	if err != nil {
-		return err
-	}
+// MaxParallel is the maximum number of containers to be operated
+// concurrently by a single broker operation.
+var MaxParallel = 8
+
+// ContainerError reports an error occurred when operating a container.
+type ContainerError struct {
+	Name string
+	Err  error
+}
+
+func (e ContainerError) Error() string {
+	return e.Name + ": " + e.Err.Error()
+}
+
+// Parallel runs the function over the containers concurrently, at most
+// MaxParallel containers at a time. Errors from each container are
+// aggregated into a composite error.
+func Parallel(cs []container.Container, fn func(container.Container) error) error {
 	if len(cs) == 0 {
 		return nil
 	}
@@ -600,27 +1066,43 @@ func runParallel(err error, cs []container.Container, fn func(container.Containe
 		return fn(cs[0])
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(len(cs))
+	limit := MaxParallel
+	if limit <= 0 {
+		limit = 1
+	}
+	sem := make(chan struct{}, limit)
 
+	var wg sync.WaitGroup
 	var errors errors.Errors
 	var errLock sync.Mutex
 
 	for _, c := range cs {
-		go func(wg *sync.WaitGroup, c container.Container) {
-			defer wg.Done()
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(c container.Container) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
 			if err := fn(c); err != nil {
 				errLock.Lock()
-				errors.Add(err)
+				errors.Add(ContainerError{Name: c.Name(), Err: err})
 				errLock.Unlock()
 			}
-		}(&wg, c)
+		}(c)
 	}
 
 	wg.Wait()
 	return errors.Err()
 }
 
+func runParallel(err error, cs []container.Container, fn func(container.Container) error) error {
+	if err != nil {
+		return err
+	}
+	return Parallel(cs, fn)
+}
+
 func runSerial(err error, cs []container.Container, fn func(container.Container) error) error {
 	if err == nil {
 		for _, c := range cs {
@@ -646,19 +1128,44 @@ func (br *UserBroker) Download(name string) (io.ReadCloser, error) {
 }
 
 // Upload application repository from a archive file.
-func (br *UserBroker) Upload(name string, content io.Reader, binary bool, log *serverlog.ServerLog) error {
+func (br *UserBroker) Upload(name string, content io.Reader, binary bool, log *serverlog.ServerLog) (err error) {
+	unlock, err := br.lockApp(name, br.Namespace(), "upload")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err = br.checkDeployAllowed(name, br.Namespace()); err != nil {
+		return err
+	}
+	if err = br.checkDiskQuota(br.ctx, name, br.Namespace()); err != nil {
+		return err
+	}
+
+	d := &Deployment{
+		Name:       name,
+		Namespace:  br.Namespace(),
+		Source:     DeploySourceUpload,
+		DeployedBy: br.User.Basic().Name,
+	}
+	log, finish := br.startDeployment(br.ctx, d, log)
+	defer func() { finish(err) }()
+
 	if binary {
-		containers, err := br.FindApplications(br.ctx, name, br.Namespace())
-		if err != nil {
-			return err
-		}
-		if len(containers) == 0 {
-			return ApplicationNotFoundError(name)
+		var artifact *Artifact
+		artifact, err = br.uploadArtifact(name, content, &Artifact{Filename: "repo.tar.gz"}, log)
+		if artifact != nil {
+			d.Artifact = artifact.ID
 		}
-		return br.DistributeRepo(br.ctx, containers, content, false)
-	} else {
-		return br.DeployRepo(br.ctx, name, br.Namespace(), content, log)
+		return err
+	}
+	if err = br.DeployRepo(br.ctx, name, br.Namespace(), content, log); err != nil {
+		return err
 	}
+	d.Artifact = br.archiveBuild(br.ctx, name, br.Namespace(), "", br.User.Basic().Name)
+	br.publishBuild(br.ctx, name, br.Namespace(), d.Artifact, log)
+	br.notifyApp(ApplicationDeployed, name, br.Namespace(), map[string]string{"source": "upload"})
+	return nil
 }
 
 func (br *UserBroker) Dump(name string) (io.ReadCloser, error) {
@@ -682,9 +1189,9 @@ func (br *UserBroker) Dump(name string) (io.ReadCloser, error) {
 	// save snapshot archives
 	for _, c := range containers {
 		if c.Category().IsFramework() {
-			err = saveSnapshot(br.ctx, c, filepath.Join(tempdir, "app", "data.tar"))
+			err = saveSnapshot(br.ctx, c, filepath.Join(tempdir, filepath.FromSlash(snapshotAppFile)))
 		} else if c.Category().IsService() {
-			err = saveSnapshot(br.ctx, c, filepath.Join(tempdir, "services", c.ServiceName()+".tar"))
+			err = saveSnapshot(br.ctx, c, filepath.Join(tempdir, filepath.FromSlash(snapshotServicePath(c.ServiceName()))))
 		}
 		if err != nil {
 			return nil, err
@@ -712,7 +1219,17 @@ func (br *UserBroker) Dump(name string) (io.ReadCloser, error) {
 	return deleteReadCloser{tempfile}, nil
 }
 
-func (br *UserBroker) Restore(name string, source io.Reader) error {
+// Restore restores application data from the archive created by Dump. The
+// archive is extracted and validated before any container is touched, so a
+// truncated or malformed archive doesn't corrupt the application. Each
+// container replaces its data directory with the snapshot atomically.
+func (br *UserBroker) Restore(name string, source io.Reader, log *serverlog.ServerLog) error {
+	unlock, err := br.lockApp(name, br.Namespace(), "restore")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// find all containers
 	containers, err := br.FindAll(br.ctx, name, br.Namespace())
 	if err != nil {
@@ -730,31 +1247,213 @@ func (br *UserBroker) Restore(name string, source io.Reader) error {
 	}
 	defer os.RemoveAll(tempdir)
 
-	// extract snapshot archives
-	zr, err := gzip.NewReader(source)
+	// extract and validate snapshot archives
+	fmt.Fprintln(log, "Validating data archive")
+	if err = extractSnapshots(tempdir, source, containers); err != nil {
+		return err
+	}
+
+	// restore snapshot archive to containers
+	for i, c := range containers {
+		var filename, title string
+		if c.Category().IsFramework() {
+			filename, title = snapshotAppFile, "application data"
+		} else if c.Category().IsService() {
+			filename, title = snapshotServicePath(c.ServiceName()), "service "+c.ServiceName()
+		} else {
+			continue
+		}
+
+		log.Progress("restore", i+1, len(containers), title)
+		fi, err := os.Stat(filepath.Join(tempdir, filename))
+		if err != nil {
+			fmt.Fprintf(log, "Skipped %s, not found in the archive\n", title)
+			continue
+		}
+		fmt.Fprintf(log, "Restoring %s (%s)\n", title, units.HumanSize(float64(fi.Size())))
+		if err = restoreSnapshot(br.ctx, c, filepath.Join(tempdir, filename)); err != nil {
+			return fmt.Errorf("Failed to restore %s: %v", title, err)
+		}
+	}
+
+	return nil
+}
+
+// DumpService dumps data of a single service of the application, or the
+// application data if the service is "_". The result is the snapshot
+// archive of the service, which is restored by RestoreService.
+func (br *UserBroker) DumpService(name, service string) (io.ReadCloser, error) {
+	cs, err := br.findContainers(name, service)
+	if err != nil {
+		return nil, err
+	}
+
+	tempfile, err := ioutil.TempFile("", "snapshot")
+	if err != nil {
+		return nil, err
+	}
+	if err = cs[0].ExecE(br.ctx, "", nil, tempfile, "cwctl", "dump"); err != nil {
+		tempfile.Close()
+		os.Remove(tempfile.Name())
+		return nil, err
+	}
+
+	tempfile.Seek(0, os.SEEK_SET)
+	return deleteReadCloser{tempfile}, nil
+}
+
+// RestoreService restores data of a single service of the application, or
+// the application data if the service is "_", from the snapshot archive
+// created by DumpService, which may be gzip'd or plain tar. Data of other
+// services is untouched. The snapshot is validated before any container is
+// touched.
+func (br *UserBroker) RestoreService(name, service string, source io.Reader, log *serverlog.ServerLog) error {
+	unlock, err := br.lockApp(name, br.Namespace(), "restore")
 	if err != nil {
 		return err
 	}
-	err = archive.ExtractFiles(tempdir, zr)
+	defer unlock()
+
+	cs, err := br.findContainers(name, service)
 	if err != nil {
 		return err
 	}
 
-	// restore snapshot archive to containers
+	tempdir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempdir)
+
+	fmt.Fprintln(log, "Validating data archive")
+	in := bufio.NewReader(source)
+	if magic, _ := in.Peek(2); bytes.Equal(magic, gzipMagic) {
+		if source, err = gzip.NewReader(in); err != nil {
+			return InvalidArchiveError(err.Error())
+		}
+	} else {
+		source = in
+	}
+	filename := filepath.Join(tempdir, "data.tar")
+	if err = extractSnapshot(filename, source); err != nil {
+		return InvalidArchiveError(err.Error())
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+
+	title := "service " + service
+	if service == "" || service == "_" {
+		title = "application data"
+	}
+	for i, c := range cs {
+		log.Progress("restore", i+1, len(cs), title)
+		fmt.Fprintf(log, "Restoring %s (%s)\n", title, units.HumanSize(float64(fi.Size())))
+		if err = restoreSnapshot(br.ctx, c, filename); err != nil {
+			return fmt.Errorf("Failed to restore %s: %v", title, err)
+		}
+	}
+	return nil
+}
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Paths of snapshot archives in the data archive.
+const (
+	snapshotAppFile    = "app/data.tar"
+	snapshotServiceDir = "services"
+)
+
+func snapshotServicePath(service string) string {
+	return snapshotServiceDir + "/" + service + ".tar"
+}
+
+// extractSnapshots extracts snapshot archives from the gzip'd data archive
+// into the directory. The archive must only contain the application
+// snapshot and snapshots of services of the application, and each snapshot
+// must be a complete tar archive.
+func extractSnapshots(dir string, source io.Reader, containers []container.Container) error {
+	expected := make(map[string]bool)
 	for _, c := range containers {
 		if c.Category().IsFramework() {
-			err = restoreSnapshot(br.ctx, c, filepath.Join(tempdir, "app", "data.tar"))
+			expected[snapshotAppFile] = true
 		} else if c.Category().IsService() {
-			err = restoreSnapshot(br.ctx, c, filepath.Join(tempdir, "services", c.ServiceName()+".tar"))
+			expected[snapshotServicePath(c.ServiceName())] = true
+		}
+	}
+
+	zr, err := gzip.NewReader(source)
+	if err != nil {
+		return InvalidArchiveError(err.Error())
+	}
+
+	found := 0
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
 		}
 		if err != nil {
-			logrus.WithError(err).Warn("Failed to restore snapshot")
+			return InvalidArchiveError(err.Error())
+		}
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if hdr.Typeflag == tar.TypeDir && (name == "." || name == path.Dir(snapshotAppFile) || name == snapshotServiceDir) {
+			continue
+		}
+		if (hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA) || !expected[name] {
+			return InvalidArchiveError(fmt.Sprintf("unexpected entry %s, the archive must contain %s or %s/SERVICE.tar of existing services",
+				hdr.Name, snapshotAppFile, snapshotServiceDir))
+		}
+
+		if err = extractSnapshot(filepath.Join(dir, filepath.FromSlash(name)), tr); err != nil {
+			return InvalidArchiveError(fmt.Sprintf("%s: %v", hdr.Name, err))
 		}
+		found++
 	}
 
+	// read to the end of the gzip stream to verify the checksum
+	if _, err = io.Copy(ioutil.Discard, zr); err != nil {
+		return InvalidArchiveError(err.Error())
+	}
+	if found == 0 {
+		return InvalidArchiveError("no snapshot found")
+	}
 	return nil
 }
 
+// extractSnapshot writes the snapshot to the file, and verifies that the
+// snapshot is a complete tar archive.
+func extractSnapshot(filename string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(io.TeeReader(r, f))
+	for {
+		if _, err = tr.Next(); err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(ioutil.Discard, tr); err != nil {
+			return err
+		}
+	}
+
+	// copy the padding after end of the tar archive
+	_, err = io.Copy(f, r)
+	return err
+}
+
 func saveSnapshot(ctx context.Context, c container.Container, filename string) error {
 	if _, err := os.Stat(filename); err == nil {
 		return nil // file exists, don't overwrite
@@ -21,7 +21,7 @@ var _ = Describe("Applications", func() {
 	})
 
 	AfterEach(func() {
-		Expect(broker.RemoveUser(TESTUSER)).To(Succeed())
+		Expect(broker.RemoveUser(TESTUSER, true)).To(Succeed())
 	})
 
 	Describe("Create", func() {
@@ -55,5 +55,63 @@ var _ = Describe("Applications", func() {
 
 			Expect(br.RemoveApplication("test")).To(Succeed())
 		})
+
+		It("should plan application creation without creating anything", func() {
+			br := broker.NewUserBroker(&user, context.Background())
+
+			tags := []string{"mock", "mockdb"}
+			plan, err := br.PlanCreateApplication(container.CreateOptions{Name: "test"}, tags)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tags).To(Equal([]string{"mock", "mockdb"}))
+
+			var created []string
+			for _, a := range plan.Actions {
+				if a.Action == "create-container" {
+					created = append(created, a.Plugin)
+				}
+			}
+			Expect(created).To(ConsistOf(HavePrefix("mock:"), HavePrefix("mockdb:")))
+
+			apps, err := br.GetApplications()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(apps).NotTo(HaveKey("test"))
+
+			cs, err := br.FindAll(context.Background(), "test", NAMESPACE)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cs).To(BeEmpty())
+		})
+
+		It("should reject invalid plugins in a dry-run", func() {
+			br := broker.NewUserBroker(&user, context.Background())
+			_, err := br.PlanCreateApplication(container.CreateOptions{Name: "test"}, []string{"mockdb"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Reconcile", func() {
+		It("should recreate missing service containers", func() {
+			br := broker.NewUserBroker(&user, context.Background())
+
+			_, _, err := br.CreateApplication(container.CreateOptions{Name: "test"}, []string{"mock", "mockdb"})
+			Expect(err).NotTo(HaveOccurred())
+			defer br.RemoveApplication("test")
+
+			cs, err := br.FindService(context.Background(), "test", NAMESPACE, "mockdb")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cs).To(HaveLen(1))
+			Expect(cs[0].Destroy(context.Background())).To(Succeed())
+
+			n, err := br.ReconcileApplication("test", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(Equal(1))
+
+			cs, err = br.FindService(context.Background(), "test", NAMESPACE, "mockdb")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cs).To(HaveLen(1))
+
+			n, err = br.ReconcileApplication("test", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(BeZero())
+		})
 	})
 })
@@ -0,0 +1,566 @@
+package broker
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/archive"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// Build artifacts are prebuilt application binaries uploaded by CI jobs or
+// binary uploads, and snapshots of application repositories built from
+// source. Each artifact is kept in the artifact store with its metadata,
+// and can be deployed to application containers again without building.
+//
+// Artifacts are kept per application according to the retention policy:
+// the latest "artifact.retain_count" artifacts are kept, and artifacts
+// older than "artifact.retain_age" are removed if configured. The
+// currently deployed artifact is never removed.
+const (
+	artifactMetaFile      = "artifact.json"
+	defaultArtifactName   = "artifact"
+	defaultRetainCount    = 10
+	artifactPurgeInterval = time.Hour
+)
+
+// Artifact formats. An archive is extracted into the application
+// repository, other files such as jar or war files are copied into the
+// repository as is.
+const (
+	ArtifactTarGz = "tar.gz"
+	ArtifactTar   = "tar"
+	ArtifactZip   = "zip"
+	ArtifactFile  = "file"
+)
+
+// Artifact sources.
+const (
+	ArtifactUpload = "upload" // uploaded prebuilt binary
+	ArtifactBuild  = "build"  // repository built from source
+)
+
+var artifactIDPattern = regexp.MustCompile(`^[0-9]{14}-[0-9a-f]{8}$`)
+
+// Artifact describes a build artifact in the artifact store.
+type Artifact struct {
+	ID         string
+	Name       string
+	Namespace  string
+	Filename   string
+	Format     string
+	Source     string
+	Commit     string
+	Version    string
+	Size       int64
+	Checksum   string
+	UploadedBy string
+	UploadedAt time.Time
+	DeployedAt time.Time
+
+	// The reference of the application image published to the registry
+	// for the build, empty if the image is not published.
+	Image string `json:",omitempty"`
+}
+
+// The InvalidArtifactError indicates that the format of a build artifact
+// cannot be recognized.
+type InvalidArtifactError string
+
+func (e InvalidArtifactError) Error() string {
+	return fmt.Sprintf("Unrecognized build artifact: %s, expecting a tar, zip, jar or war file", string(e))
+}
+
+func (e InvalidArtifactError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+type ArtifactNotFoundError string
+
+func (e ArtifactNotFoundError) Error() string {
+	return fmt.Sprintf("Artifact '%s' not found", string(e))
+}
+
+func (e ArtifactNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+func artifactRoot() string {
+	return filepath.Join(config.RootDir, "var", "artifacts")
+}
+
+func artifactDir(name, namespace string) string {
+	return filepath.Join(artifactRoot(), namespace, name)
+}
+
+func artifactRetention() (count int, age time.Duration) {
+	count = defaultRetainCount
+	if n, err := strconv.Atoi(config.Get("artifact.retain_count")); err == nil && n > 0 {
+		count = n
+	}
+	if d, err := time.ParseDuration(config.Get("artifact.retain_age")); err == nil && d > 0 {
+		age = d
+	}
+	return
+}
+
+// GetArtifacts returns artifacts of the application, the latest first.
+func (br *UserBroker) GetArtifacts(name string) ([]*Artifact, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	if br.User.Basic().Applications[name] == nil {
+		return nil, ApplicationNotFoundError(name)
+	}
+	return readArtifacts(name, br.Namespace())
+}
+
+// DeployArtifact saves the build artifact into the artifact store and
+// deploys it to the application containers. The filename, commit, version
+// and uploader of the artifact are taken from the given metadata.
+func (br *UserBroker) DeployArtifact(name string, content io.Reader, meta *Artifact, log *serverlog.ServerLog) (artifact *Artifact, err error) {
+	unlock, err := br.lockApp(name, br.Namespace(), "deploy")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err = br.checkDeployAllowed(name, br.Namespace()); err != nil {
+		return nil, err
+	}
+	if err = br.checkDiskQuota(br.ctx, name, br.Namespace()); err != nil {
+		return nil, err
+	}
+
+	d := &Deployment{
+		Name:       name,
+		Namespace:  br.Namespace(),
+		Source:     DeploySourceArtifact,
+		DeployedBy: meta.UploadedBy,
+	}
+	log, finish := br.startDeployment(br.ctx, d, log)
+	defer func() { finish(err) }()
+
+	artifact, err = br.uploadArtifact(name, content, meta, log)
+	if artifact != nil {
+		d.Artifact, d.Commit, d.Version = artifact.ID, artifact.Commit, artifact.Version
+	}
+	return artifact, err
+}
+
+func (br *UserBroker) uploadArtifact(name string, content io.Reader, meta *Artifact, log *serverlog.ServerLog) (*Artifact, error) {
+	containers, err := br.FindApplications(br.ctx, name, br.Namespace())
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, ApplicationNotFoundError(name)
+	}
+
+	artifact := *meta
+	artifact.Name = name
+	artifact.Namespace = br.Namespace()
+	if artifact.Source == "" {
+		artifact.Source = ArtifactUpload
+	}
+	if artifact.UploadedBy == "" {
+		artifact.UploadedBy = br.User.Basic().Name
+	}
+	if err = saveArtifact(&artifact, content); err != nil {
+		return nil, err
+	}
+	return &artifact, br.deployArtifact(containers, &artifact, log)
+}
+
+// RedeployArtifact deploys a previous artifact of the application, which
+// rolls back the application to an earlier build.
+func (br *UserBroker) RedeployArtifact(name, id string, log *serverlog.ServerLog) (*Artifact, error) {
+	return br.redeployArtifact(name, id, &Deployment{Source: DeploySourceArtifact}, log)
+}
+
+// redeployArtifact deploys a previous artifact of the application and
+// records the deployment.
+func (br *UserBroker) redeployArtifact(name, id string, d *Deployment, log *serverlog.ServerLog) (artifact *Artifact, err error) {
+	unlock, err := br.lockApp(name, br.Namespace(), "deploy")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err = br.checkDeployAllowed(name, br.Namespace()); err != nil {
+		return nil, err
+	}
+	artifact, err = readArtifact(name, br.Namespace(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Name, d.Namespace = name, br.Namespace()
+	d.Artifact, d.Commit, d.Version = artifact.ID, artifact.Commit, artifact.Version
+	if d.DeployedBy == "" {
+		d.DeployedBy = br.User.Basic().Name
+	}
+	log, finish := br.startDeployment(br.ctx, d, log)
+	defer func() { finish(err) }()
+
+	containers, err := br.FindApplications(br.ctx, name, br.Namespace())
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, ApplicationNotFoundError(name)
+	}
+	return artifact, br.deployArtifact(containers, artifact, log)
+}
+
+func (br *UserBroker) deployArtifact(containers []container.Container, artifact *Artifact, log *serverlog.ServerLog) error {
+	fmt.Fprintf(log.Stdout(), "Deploying build artifact %s (%s, %d bytes)\n", artifact.ID, artifact.Filename, artifact.Size)
+
+	repo, zip, err := openArtifactRepo(artifact)
+	if err != nil {
+		return err
+	}
+	err = br.DistributeRepo(br.ctx, containers, repo, zip, log)
+	repo.Close()
+	if err != nil {
+		return err
+	}
+
+	artifact.DeployedAt = time.Now().UTC()
+	if err = writeArtifactMeta(artifact); err != nil {
+		return err
+	}
+	pruneArtifacts(artifact.Name, artifact.Namespace)
+	br.notifyApp(ApplicationDeployed, artifact.Name, artifact.Namespace, map[string]string{"artifact": artifact.ID})
+	return nil
+}
+
+// archiveBuild saves the application repository built from source into the
+// artifact store, so the build can be deployed again without building, and
+// returns the ID of the artifact. Failures are logged but not returned, as
+// the deployment is already done.
+func (br *Broker) archiveBuild(ctx context.Context, name, namespace, commit, uploadedBy string) string {
+	containers, err := br.FindApplications(ctx, name, namespace)
+	if err != nil || len(containers) == 0 {
+		return ""
+	}
+
+	artifact := Artifact{
+		Name:       name,
+		Namespace:  namespace,
+		Filename:   "repo.tar",
+		Format:     ArtifactTar,
+		Source:     ArtifactBuild,
+		Commit:     commit,
+		UploadedBy: uploadedBy,
+	}
+
+	c := containers[0]
+	repo, err := c.CopyFrom(ctx, c.RepoDir()+"/.")
+	if err == nil {
+		if err = saveArtifact(&artifact, repo); err == nil {
+			artifact.DeployedAt = artifact.UploadedAt
+			err = writeArtifactMeta(&artifact)
+		}
+		repo.Close()
+	}
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to archive build of %s-%s", name, namespace)
+		return ""
+	}
+	pruneArtifacts(name, namespace)
+	return artifact.ID
+}
+
+// saveArtifact writes the artifact content and metadata into the artifact
+// store. The ID, size and checksum of the artifact are filled, and the
+// format is detected from the content if not given.
+func saveArtifact(artifact *Artifact, content io.Reader) (err error) {
+	dir := artifactDir(artifact.Name, artifact.Namespace)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmpdir, err := ioutil.TempDir(dir, ".upload")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	artifact.Filename = filepath.Base(artifact.Filename)
+	if artifact.Filename == "." || artifact.Filename == string(filepath.Separator) {
+		artifact.Filename = ""
+	}
+
+	// sniff the format from the beginning of the content
+	in := bufio.NewReaderSize(content, 512)
+	if artifact.Format == "" {
+		head, _ := in.Peek(512)
+		if artifact.Format = artifactFormat(artifact.Filename, head); artifact.Format == "" {
+			return InvalidArtifactError(artifact.Filename)
+		}
+	}
+	if artifact.Filename == "" {
+		artifact.Filename = defaultArtifactName + "." + artifact.Format
+	}
+
+	f, err := os.OpenFile(filepath.Join(tmpdir, artifact.Filename), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	artifact.Size, err = io.Copy(io.MultiWriter(f, h), in)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	artifact.Checksum = hex.EncodeToString(h.Sum(nil))
+	artifact.UploadedAt = time.Now().UTC()
+	artifact.ID = artifact.UploadedAt.Format("20060102150405") + "-" + artifact.Checksum[:8]
+
+	metadata, err := json.Marshal(artifact)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(tmpdir, artifactMetaFile), metadata, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpdir, filepath.Join(dir, artifact.ID))
+}
+
+func writeArtifactMeta(artifact *Artifact) error {
+	metadata, err := json.Marshal(artifact)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(artifactDir(artifact.Name, artifact.Namespace), artifact.ID)
+	return ioutil.WriteFile(filepath.Join(dir, artifactMetaFile), metadata, 0600)
+}
+
+// readArtifact reads metadata of an artifact. The application name and
+// namespace are taken from the store location, as the application may be
+// renamed after the artifact is saved.
+func readArtifact(name, namespace, id string) (*Artifact, error) {
+	if !artifactIDPattern.MatchString(id) {
+		return nil, ArtifactNotFoundError(id)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(artifactDir(name, namespace), id, artifactMetaFile))
+	if os.IsNotExist(err) {
+		return nil, ArtifactNotFoundError(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	artifact := new(Artifact)
+	if err = json.Unmarshal(data, artifact); err != nil {
+		return nil, err
+	}
+	artifact.Name, artifact.Namespace = name, namespace
+	return artifact, nil
+}
+
+// readArtifacts returns all artifacts of the application, the latest first.
+func readArtifacts(name, namespace string) ([]*Artifact, error) {
+	dirs, err := ioutil.ReadDir(artifactDir(name, namespace))
+	if os.IsNotExist(err) {
+		return []*Artifact{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*Artifact{}
+	for _, fi := range dirs {
+		if artifactIDPattern.MatchString(fi.Name()) {
+			if artifact, err := readArtifact(name, namespace, fi.Name()); err == nil {
+				result = append(result, artifact)
+			}
+		}
+	}
+	sort.Sort(artifactsByTime(result))
+	return result, nil
+}
+
+type artifactsByTime []*Artifact
+
+func (a artifactsByTime) Len() int           { return len(a) }
+func (a artifactsByTime) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a artifactsByTime) Less(i, j int) bool { return a[i].UploadedAt.After(a[j].UploadedAt) }
+
+// pruneArtifacts removes artifacts of the application that are out of the
+// retention policy, except the currently deployed one.
+func pruneArtifacts(name, namespace string) {
+	artifacts, err := readArtifacts(name, namespace)
+	if err != nil || len(artifacts) == 0 {
+		return
+	}
+
+	current := artifacts[0]
+	for _, a := range artifacts {
+		if a.DeployedAt.After(current.DeployedAt) {
+			current = a
+		}
+	}
+
+	count, age := artifactRetention()
+	for i, a := range artifacts {
+		if a == current {
+			continue
+		}
+		if i >= count || (age > 0 && time.Since(a.UploadedAt) > age) {
+			logrus.Debugf("Removing artifact %s of %s-%s", a.ID, name, namespace)
+			if err = os.RemoveAll(filepath.Join(artifactDir(name, namespace), a.ID)); err != nil {
+				logrus.WithError(err).Warnf("Failed to remove artifact %s of %s-%s", a.ID, name, namespace)
+			}
+		}
+	}
+}
+
+func renameArtifacts(name, namespace, newName, newNamespace string) error {
+	src, dst := artifactDir(name, namespace), artifactDir(newName, newNamespace)
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+func removeArtifacts(name, namespace string) error {
+	return os.RemoveAll(artifactDir(name, namespace))
+}
+
+// StartArtifactCleaner removes artifacts out of the retention policy
+// periodically until the context is canceled. Only the elected leader of
+// API servers removes artifacts.
+func (br *Broker) StartArtifactCleaner(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(artifactPurgeInterval)
+		defer ticker.Stop()
+		for {
+			if br.elected("artifacts", 3*artifactPurgeInterval) {
+				purgeArtifacts()
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func purgeArtifacts() {
+	namespaces, err := ioutil.ReadDir(artifactRoot())
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	for _, ns := range namespaces {
+		apps, err := ioutil.ReadDir(filepath.Join(artifactRoot(), ns.Name()))
+		if err != nil {
+			continue
+		}
+		for _, app := range apps {
+			pruneArtifacts(app.Name(), ns.Name())
+
+			// leftovers of incomplete uploads
+			dirs, _ := ioutil.ReadDir(artifactDir(app.Name(), ns.Name()))
+			for _, fi := range dirs {
+				if strings.HasPrefix(fi.Name(), ".upload") && fi.ModTime().Add(artifactPurgeInterval).Before(now) {
+					os.RemoveAll(filepath.Join(artifactDir(app.Name(), ns.Name()), fi.Name()))
+				}
+			}
+		}
+	}
+}
+
+// artifactFormat determines the artifact format from the file name and
+// the leading bytes of the content. Java archives are zip files but are
+// deployed as is.
+func artifactFormat(filename string, head []byte) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jar", ".war", ".ear":
+		return ArtifactFile
+	}
+
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return ArtifactTarGz
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return ArtifactZip
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return ArtifactTar
+	case filename != "":
+		return ArtifactFile
+	default:
+		return ""
+	}
+}
+
+// openArtifactRepo returns the artifact content as an application
+// repository archive, and whether the archive is uncompressed.
+func openArtifactRepo(artifact *Artifact) (repo io.ReadCloser, zip bool, err error) {
+	path := filepath.Join(artifactDir(artifact.Name, artifact.Namespace), artifact.ID, artifact.Filename)
+	if artifact.Format == ArtifactFile {
+		pr, pw := io.Pipe()
+		go func() {
+			tw := tar.NewWriter(pw)
+			err := archive.CopyFile(tw, path, artifact.Filename, 0644)
+			if err == nil {
+				err = tw.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		return pr, true, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	switch artifact.Format {
+	case ArtifactTarGz:
+		return f, false, nil
+	case ArtifactZip:
+		return &closeReader{archive.FromZip(f, artifact.Size), f}, true, nil
+	default:
+		return f, true, nil
+	}
+}
+
+// closeReader closes the underlying file after the reader is closed.
+type closeReader struct {
+	io.ReadCloser
+	f *os.File
+}
+
+func (r *closeReader) Close() error {
+	r.ReadCloser.Close()
+	return r.f.Close()
+}
@@ -0,0 +1,329 @@
+package broker
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/pkg/archive"
+	"github.com/cloudway/platform/pkg/files"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// A platform backup is a gzipped tar archive containing:
+//
+//	backup.json       metadata of the backup
+//	userdb            records of the user database
+//	master.key        the master key, only if requested
+//	config/           configuration files
+//	hub/              installed plugins
+//	apps/USER/NAME/   repository and data of applications, in the
+//	                  same format as the trash
+const (
+	backupVersion    = 1
+	backupMetaFile   = "backup.json"
+	backupUserDBFile = "userdb"
+	backupKeyFile    = "master.key"
+	backupConfigDir  = "config"
+	backupHubDir     = "hub"
+	backupAppsDir    = "apps"
+)
+
+// BackupInfo describes a platform backup.
+type BackupInfo struct {
+	Version      int
+	Created      time.Time
+	Users        int
+	Applications []string
+	Skipped      []string `json:",omitempty"`
+}
+
+// BackupOptions controls contents of a platform backup.
+type BackupOptions struct {
+	// Include the master key, which is required to decrypt encrypted
+	// configuration values. The backup must be kept safe if included.
+	MasterKey bool
+
+	// Don't include application repositories and data.
+	SkipApps bool
+}
+
+// RestoreOptions controls what to restore from a platform backup.
+type RestoreOptions struct {
+	// Keep the current configuration files and master key.
+	SkipConfig bool
+
+	// Don't recreate applications.
+	SkipApps bool
+}
+
+// Backup writes a snapshot of the user database, configuration, plugins
+// and applications to the writer. Applications that failed to archive
+// are skipped and listed in the backup metadata.
+func (br *Broker) Backup(ctx context.Context, w io.Writer, opts BackupOptions, log *serverlog.ServerLog) error {
+	zw, err := gzip.NewWriterLevel(w, defaults.GzipLevel())
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(zw)
+
+	info := BackupInfo{Version: backupVersion, Created: time.Now()}
+
+	var users []*userdb.BasicUser
+	if err := br.Users.Search(userdb.Args{}, &users); err != nil {
+		return err
+	}
+	sort.Sort(usersByName(users))
+	info.Users = len(users)
+
+	fmt.Fprintln(log, "Saving user database")
+	if err := br.backupUserDB(tw); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(log, "Saving configuration and plugins")
+	confDir := filepath.Join(config.RootDir, "conf")
+	if err := archive.CopyFileTree(tw, backupConfigDir, confDir, []string{backupKeyFile}, true); err != nil {
+		return err
+	}
+	if opts.MasterKey {
+		if err := archive.CopyFile(tw, config.MasterKeyFile(), backupKeyFile, 0600); err != nil {
+			return err
+		}
+	}
+	if err := archive.CopyFileTree(tw, backupHubDir, br.Hub.InstallDir(), nil, false); err != nil {
+		return err
+	}
+
+	if !opts.SkipApps {
+		var total, done int
+		for _, user := range users {
+			total += len(user.Applications)
+		}
+		for _, user := range users {
+			ub := br.NewUserBroker(user, ctx)
+			for _, name := range sortedKeys(user.Applications) {
+				done++
+				log.Progress("backup", done, total, name)
+				id := user.Name + "/" + name
+				if err := ub.backupApplication(tw, name, user.Applications[name]); err != nil {
+					logrus.WithError(err).Warnf("Failed to back up application %s", id)
+					fmt.Fprintf(log.Stderr(), "Skipped application %s: %v\n", id, err)
+					info.Skipped = append(info.Skipped, id)
+				} else {
+					info.Applications = append(info.Applications, id)
+				}
+			}
+		}
+	}
+
+	meta, err := json.MarshalIndent(&info, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = archive.AddFile(tw, backupMetaFile, 0644, meta); err != nil {
+		return err
+	}
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(log, "Backup completed: %d users, %d applications, %d skipped\n",
+		info.Users, len(info.Applications), len(info.Skipped))
+	return nil
+}
+
+type usersByName []*userdb.BasicUser
+
+func (a usersByName) Len() int           { return len(a) }
+func (a usersByName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a usersByName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+func (br *Broker) backupUserDB(tw *tar.Writer) error {
+	f, err := ioutil.TempFile("", "userdb")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err = br.Users.Backup(f); err != nil {
+		return err
+	}
+	return archive.CopyFile(tw, f.Name(), backupUserDBFile, 0600)
+}
+
+func (br *UserBroker) backupApplication(tw *tar.Writer, name string, app *userdb.Application) error {
+	tempdir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempdir)
+
+	meta := TrashedApplication{
+		Name:        name,
+		Namespace:   br.Namespace(),
+		Application: app,
+	}
+	if err = br.archiveApplication(tempdir, &meta); err != nil {
+		return err
+	}
+	dst := filepath.ToSlash(filepath.Join(backupAppsDir, br.User.Basic().Name, name))
+	return archive.CopyFileTree(tw, dst, tempdir, nil, false)
+}
+
+// RestoreBackup restores the platform from a backup. The user database is
+// replaced with the backup, and applications are recreated with their
+// repository and data. Applications with existing containers are kept as
+// is. The restored configuration takes effect after servers restarted.
+func (br *Broker) RestoreBackup(ctx context.Context, r io.Reader, opts RestoreOptions, log *serverlog.ServerLog) error {
+	tempdir, err := ioutil.TempDir("", "restore")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempdir)
+
+	fmt.Fprintln(log, "Extracting backup")
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	if err = archive.ExtractFiles(tempdir, zr); err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(tempdir, backupMetaFile))
+	if err != nil {
+		return fmt.Errorf("Invalid backup: %v", err)
+	}
+	var info BackupInfo
+	if err = json.Unmarshal(data, &info); err != nil {
+		return fmt.Errorf("Invalid backup: %v", err)
+	}
+	if info.Version > backupVersion {
+		return fmt.Errorf("Unsupported backup version %d", info.Version)
+	}
+
+	if !opts.SkipConfig {
+		fmt.Fprintln(log, "Restoring configuration")
+		if err = restoreDir(filepath.Join(tempdir, backupConfigDir), filepath.Join(config.RootDir, "conf")); err != nil {
+			return err
+		}
+		if key := filepath.Join(tempdir, backupKeyFile); fileExists(key) {
+			if err = files.CopyFiles(key, config.MasterKeyFile()); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Fprintln(log, "Restoring plugins")
+	if err = restoreDir(filepath.Join(tempdir, backupHubDir), br.Hub.InstallDir()); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(log, "Restoring user database")
+	f, err := os.Open(filepath.Join(tempdir, backupUserDBFile))
+	if err != nil {
+		return fmt.Errorf("Invalid backup: %v", err)
+	}
+	err = br.Users.Restore(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	if opts.SkipApps {
+		return nil
+	}
+
+	var failed int
+	for i, id := range info.Applications {
+		log.Progress("restore", i+1, len(info.Applications), id)
+		if err = br.restoreApplication(ctx, filepath.Join(tempdir, backupAppsDir, id), log); err != nil {
+			logrus.WithError(err).Warnf("Failed to restore application %s", id)
+			fmt.Fprintf(log.Stderr(), "Failed to restore application %s: %v\n", id, err)
+			failed++
+		}
+	}
+
+	fmt.Fprintf(log, "Restore completed: %d users, %d applications restored, %d failed\n",
+		info.Users, len(info.Applications)-failed, failed)
+	if failed != 0 {
+		return fmt.Errorf("Failed to restore %d applications", failed)
+	}
+	return nil
+}
+
+func (br *Broker) restoreApplication(ctx context.Context, dir string, log *serverlog.ServerLog) error {
+	meta, err := readTrashMeta(dir)
+	if err != nil {
+		return err
+	}
+
+	var user userdb.BasicUser
+	if err = br.Users.Find(filepath.Base(filepath.Dir(dir)), &user); err != nil {
+		return err
+	}
+	cs, err := br.FindAll(ctx, meta.Name, user.Namespace)
+	if err != nil {
+		return err
+	}
+	if len(cs) != 0 {
+		fmt.Fprintf(log, "Application %s already exists, skipped\n", meta.Name)
+		return nil
+	}
+
+	// the application record is restored with the user database, remove
+	// it so the application can be recreated
+	if err = br.Users.RemoveApplication(user.Name, meta.Name); err != nil {
+		return err
+	}
+	ub := br.NewUserBroker(&user, ctx)
+	if _, _, err = ub.restoreArchived(dir, meta, log); err != nil {
+		return err
+	}
+	ub.syncSSHKeys(user.Namespace)
+	return nil
+}
+
+// restoreDir replaces files in the destination directory with files in the
+// source directory. Other files in the destination directory are kept.
+func restoreDir(src, dst string) error {
+	if !fileExists(src) {
+		return nil
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	return files.CopyFiles(src, dst)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func sortedKeys(apps map[string]*userdb.Application) []string {
+	keys := make([]string, 0, len(apps))
+	for k := range apps {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -3,27 +3,45 @@ package broker
 import (
 	"context"
 	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
 
 	"github.com/cloudway/platform/auth"
 	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/container"
 	"github.com/cloudway/platform/hub"
+	"github.com/cloudway/platform/pkg/migrate"
 	"github.com/cloudway/platform/scm"
 
 	// Load all plugings
+	_ "github.com/cloudway/platform/auth/userdb/memory"
 	_ "github.com/cloudway/platform/auth/userdb/mongodb"
 	_ "github.com/cloudway/platform/container/docker"
 	_ "github.com/cloudway/platform/scm/bitbucket"
+	_ "github.com/cloudway/platform/scm/memory"
 	_ "github.com/cloudway/platform/scm/mock"
 )
 
 // Broker maintains all external services.
 type Broker struct {
 	container.Engine
-	Users *userdb.UserDatabase
-	Authz *auth.Authenticator
-	SCM   scm.SCM
-	Hub   *hub.PluginHub
+	Users   *userdb.UserDatabase
+	Authz   *auth.Authenticator
+	Hub     *hub.PluginHub
+	Events  *EventBus
+	nodes   *nodeMonitor
+	crashes *crashDetector
+	pulls   *imagePuller
+	notices noticeCache
+
+	upgradeMu sync.Mutex
+	upgrader  *Upgrader
+
+	scmMu     sync.RWMutex
+	scmClient scm.SCM
 }
 
 // UserBroker performs user specific operations.
@@ -34,30 +52,94 @@ type UserBroker struct {
 }
 
 func New(engine container.Engine) (broker *Broker, err error) {
-	broker = new(Broker)
-	broker.Engine = engine
+	users, err := userdb.Open()
+	if err != nil {
+		return
+	}
+	s, err := scm.New()
+	if err != nil {
+		return
+	}
+	h, err := hub.New()
+	if err != nil {
+		return
+	}
 
-	broker.Users, err = userdb.Open()
+	broker, err = NewWith(engine, users, s, h)
 	if err != nil {
 		return
 	}
+	if url := redisURL(); url != "" {
+		broker.startCacheSync(url)
+	}
+	config.OnReload(broker.reloadSCM)
+	return broker, nil
+}
+
+// NewWith creates a broker that maintains the given services instead of
+// services configured by the platform configuration, which is used to run
+// the broker against fake services in tests.
+func NewWith(engine container.Engine, users *userdb.UserDatabase, s scm.SCM, h *hub.PluginHub) (broker *Broker, err error) {
+	broker = new(Broker)
+	broker.Engine = engine
+	broker.Events = NewEventBus()
+	broker.nodes = newNodeMonitor()
+	broker.crashes = newCrashDetector()
+	broker.pulls = newImagePuller()
+
+	broker.Users = users
+	broker.Users.OnLocked = broker.notifyLocked
+	broker.scmClient = s
+	broker.Hub = h
 
 	broker.Authz, err = auth.NewAuthenticator(broker.Users)
 	if err != nil {
-		return
+		return nil, err
 	}
+	return broker, nil
+}
 
-	broker.SCM, err = scm.New()
+// reloadSCM recreates the SCM client to pick up changed settings after the
+// configuration reloaded. Changing the SCM type requires a restart.
+func (br *Broker) reloadSCM() {
+	s, err := scm.New()
 	if err != nil {
+		logrus.WithError(err).Error("Failed to reload SCM configuration")
 		return
 	}
 
-	broker.Hub, err = hub.New()
-	if err != nil {
+	br.scmMu.Lock()
+	defer br.scmMu.Unlock()
+	if s.Type() != br.scmClient.Type() {
+		logrus.Warnf("The SCM type changed from %s to %s, restart required", br.scmClient.Type(), s.Type())
 		return
 	}
+	br.scmClient = s
+}
 
-	return broker, nil
+// SCM returns the SCM client, which is replaced when the configuration
+// reloaded.
+func (br *Broker) SCM() scm.SCM {
+	br.scmMu.RLock()
+	defer br.scmMu.RUnlock()
+	return br.scmClient
+}
+
+// Migrators returns migrators of all data stores maintained by the broker.
+func (br *Broker) Migrators() []*migrate.Migrator {
+	return []*migrate.Migrator{br.Users.Migrator()}
+}
+
+// IsAdmin returns true if the user is a platform administrator. The
+// administrators are listed in the "admin.users" configuration key.
+func (br *Broker) IsAdmin(user userdb.User) bool {
+	name := user.Basic().Name
+	for _, admin := range strings.Split(config.Get("admin.users"), ",") {
+		if strings.TrimSpace(admin) == name {
+			return true
+		}
+	}
+	return false
 }
 
 func (br *Broker) NewUserBroker(user userdb.User, ctx context.Context) *UserBroker {
@@ -75,6 +157,15 @@ func (br *UserBroker) Refresh() error {
 	return br.Users.Find(username, br.User)
 }
 
+// RefreshCached is similar to Refresh but the user record may be cached
+// for a short time. It must only be used by read-only requests.
+func (br *UserBroker) RefreshCached() error {
+	username := br.User.Basic().Name
+	p := reflect.ValueOf(br.User).Elem()
+	p.Set(reflect.Zero(p.Type()))
+	return br.Users.FindCached(username, br.User)
+}
+
 func (br *UserBroker) Namespace() string {
 	return br.User.Basic().Namespace
 }
@@ -0,0 +1,140 @@
+// Package brokertest provides a fake container engine, and a broker running
+// against it with the in-memory SCM and user database, so tools and plugins
+// can be tested against the broker without Docker, MongoDB or a real SCM
+// server.
+package brokertest
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/cloudway/platform/auth/userdb"
+	memdb "github.com/cloudway/platform/auth/userdb/memory"
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/hub"
+	"github.com/cloudway/platform/pkg/manifest"
+	memscm "github.com/cloudway/platform/scm/memory"
+)
+
+// Broker is a broker running against fake services, which are exposed to
+// inspect the effect of broker operations.
+type Broker struct {
+	*broker.Broker
+	Engine *Engine
+	SCM    *memscm.SCM
+	DB     *memdb.UserDB
+
+	hubDir string
+}
+
+// NewBroker creates a broker with an in-memory container engine, SCM and
+// user database. Plugins are installed in a temporary directory which is
+// removed by Close.
+func NewBroker() (*Broker, error) {
+	dir, err := ioutil.TempDir("", "brokertest")
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Broker{Engine: NewEngine(), SCM: memscm.New(), DB: memdb.New(), hubDir: dir}
+	b.Broker, err = newBroker(b.Engine, b.SCM, b.DB, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return b, nil
+}
+
+func newBroker(engine *Engine, s *memscm.SCM, db *memdb.UserDB, dir string) (*broker.Broker, error) {
+	users, err := userdb.NewDatabase(db)
+	if err != nil {
+		return nil, err
+	}
+	h, err := hub.NewAt(dir)
+	if err != nil {
+		return nil, err
+	}
+	return broker.NewWith(engine, users, s, h)
+}
+
+// Close removes plugins installed in the broker.
+func (b *Broker) Close() error {
+	return os.RemoveAll(b.hubDir)
+}
+
+// NewUser creates a user with the namespace, and returns the broker that
+// performs operations on behalf of the user.
+func (b *Broker) NewUser(name, namespace string) (*broker.UserBroker, error) {
+	user := &userdb.BasicUser{Name: name, Namespace: namespace}
+	if err := b.CreateUser(user, "password"); err != nil {
+		return nil, err
+	}
+	return b.NewUserBroker(user, context.Background()), nil
+}
+
+// InstallPlugin installs a system plugin with the manifest. The plugin has
+// no files other than the manifest.
+func (b *Broker) InstallPlugin(meta *manifest.Plugin) error {
+	dir, err := ioutil.TempDir("", "plugin")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	data, err := yaml.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err = os.Mkdir(filepath.Join(dir, "manifest"), 0755); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "manifest", "plugin.yml"), data, 0644); err != nil {
+		return err
+	}
+	return b.Hub.InstallPlugin("", dir)
+}
+
+// Framework returns the manifest of a framework plugin listening on the
+// HTTP port 8080.
+func Framework(name, version string) *manifest.Plugin {
+	return &manifest.Plugin{
+		Name:        name,
+		DisplayName: name,
+		Version:     version,
+		Vendor:      "cloudway",
+		Category:    manifest.Framework,
+		BaseImage:   "busybox",
+		Endpoints: []*manifest.Endpoint{{
+			PrivateHostName: "HOST",
+			PrivatePortName: "PORT",
+			PrivatePort:     8080,
+			ProxyMappings: []*manifest.ProxyMapping{{
+				Frontend:  "",
+				Backend:   "",
+				Protocols: []string{"http"},
+			}},
+		}},
+	}
+}
+
+// Service returns the manifest of a service plugin listening on the TCP
+// port.
+func Service(name, version string, port int32) *manifest.Plugin {
+	return &manifest.Plugin{
+		Name:        name,
+		DisplayName: name,
+		Version:     version,
+		Vendor:      "cloudway",
+		Category:    manifest.Service,
+		BaseImage:   "busybox",
+		Endpoints: []*manifest.Endpoint{{
+			PrivateHostName: "HOST",
+			PrivatePortName: "PORT",
+			PrivatePort:     port,
+		}},
+	}
+}
@@ -0,0 +1,392 @@
+package brokertest_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	. "github.com/cloudway/platform/broker/brokertest"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+func TestBrokerTest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Broker Test Suite")
+}
+
+var _ = Describe("Fake broker", func() {
+	var (
+		broker  *Broker
+		rootDir string
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		// keep the trash and other state out of the source tree
+		rootDir = config.RootDir
+		config.RootDir, err = ioutil.TempDir("", "brokertest")
+		Expect(err).NotTo(HaveOccurred())
+
+		broker, err = NewBroker()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(broker.InstallPlugin(Framework("mock", "1.0"))).To(Succeed())
+		Expect(broker.InstallPlugin(Service("mockdb", "1.0", 3306))).To(Succeed())
+	})
+
+	AfterEach(func() {
+		broker.Close()
+		os.RemoveAll(config.RootDir)
+		config.RootDir = rootDir
+	})
+
+	It("should create applications with containers", func() {
+		ub, err := broker.NewUser("test@example.com", "test")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, cs, err := ub.CreateApplication(container.CreateOptions{Name: "demo"}, []string{"mock", "mockdb"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs).To(HaveLen(2))
+		Expect(broker.SCM.Repo("test", "demo")).NotTo(BeNil())
+
+		Expect(ub.StartContainers(cs, nil)).To(Succeed())
+		for _, c := range cs {
+			Expect(c.ActiveState(context.Background())).To(Equal(manifest.StateRunning))
+		}
+
+		apps, err := ub.GetApplications()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(apps).To(HaveKey("demo"))
+
+		Expect(ub.RemoveApplication("demo")).To(Succeed())
+		cs, err = broker.Engine.FindAll(context.Background(), "demo", "test")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs).To(BeEmpty())
+	})
+
+	It("should download empty repository", func() {
+		ub, err := broker.NewUser("test@example.com", "test")
+		Expect(err).NotTo(HaveOccurred())
+		_, _, err = ub.CreateApplication(container.CreateOptions{Name: "demo"}, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+
+		r, err := ub.Download("demo")
+		Expect(err).NotTo(HaveOccurred())
+		defer r.Close()
+		hdr, err := tar.NewReader(r).Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hdr.Typeflag).To(Equal(byte(tar.TypeDir)))
+	})
+
+	It("should report readiness of all dependencies", func() {
+		readiness := broker.Readiness(context.Background())
+		Expect(readiness.Ready).To(BeTrue())
+		Expect(readiness.Dependencies).To(HaveLen(3))
+		for _, dep := range readiness.Dependencies {
+			Expect(dep.Status).To(Equal("ok"), dep.Name)
+		}
+	})
+
+	It("should recreate containers with log shipping configuration", func() {
+		ub, err := broker.NewUser("test@example.com", "test")
+		Expect(err).NotTo(HaveOccurred())
+		_, cs, err := ub.CreateApplication(container.CreateOptions{Name: "demo"}, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ub.StartContainers(cs, nil)).To(Succeed())
+
+		err = ub.SetLogging("demo", &userdb.LogConfig{Driver: "gelf", Address: "udp://logs:12201"})
+		Expect(err).To(BeAssignableToTypeOf(br.InvalidLogConfigError{}))
+		err = ub.SetLogging("demo", &userdb.LogConfig{Driver: "syslog", Address: "logs:514"})
+		Expect(err).To(BeAssignableToTypeOf(br.InvalidLogConfigError{}))
+
+		logging := &userdb.LogConfig{Driver: "syslog", Address: "udp://logs:514"}
+		Expect(ub.SetLogging("demo", logging)).To(Succeed())
+		cs, err = broker.Engine.FindAll(context.Background(), "demo", "test")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs).To(HaveLen(1))
+		Expect(cs[0].ActiveState(context.Background())).To(Equal(manifest.StateRunning))
+		Expect(cs[0].(*Container).Logging().Address).To(Equal("udp://logs:514"))
+
+		By("Containers created afterwards should use the configuration")
+		cs, err = ub.ScaleApplication("demo", 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs).To(HaveLen(1))
+		Expect(cs[0].(*Container).Logging().Driver).To(Equal("syslog"))
+		Expect(cs[0].(*Container).Deployments()).To(Equal(1))
+	})
+
+	It("should serve applications under the domain delegated to the namespace", func() {
+		ub, err := broker.NewUser("test@example.com", "test")
+		Expect(err).NotTo(HaveOccurred())
+		_, cs, err := ub.CreateApplication(container.CreateOptions{Name: "demo"}, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ub.StartContainers(cs, nil)).To(Succeed())
+
+		err = ub.SetDomain("not a domain")
+		Expect(err).To(BeAssignableToTypeOf(br.InvalidDomainError{}))
+
+		Expect(ub.SetDomain("Team.Example.org.")).To(Succeed())
+		cs, err = broker.Engine.FindAll(context.Background(), "demo", "test")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs).To(HaveLen(1))
+		Expect(cs[0].FQDN()).To(Equal("demo.team.example.org"))
+		Expect(cs[0].ActiveState(context.Background())).To(Equal(manifest.StateRunning))
+
+		By("The domain can't be delegated to another namespace")
+		other, err := broker.NewUser("other@example.com", "other")
+		Expect(err).NotTo(HaveOccurred())
+		err = other.SetDomain("team.example.org")
+		Expect(err).To(BeAssignableToTypeOf(userdb.DomainInUseError("")))
+
+		By("Remove the delegation")
+		Expect(ub.SetDomain("")).To(Succeed())
+		cs, err = broker.Engine.FindAll(context.Background(), "demo", "test")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs[0].FQDN()).To(Equal(defaults.AppHost("demo", "test", "")))
+	})
+
+	Context("Resumable uploads", func() {
+		var (
+			ub      *br.UserBroker
+			rootDir string
+		)
+
+		BeforeEach(func() {
+			var err error
+			rootDir = config.RootDir
+			config.RootDir, err = ioutil.TempDir("", "uploads")
+			Expect(err).NotTo(HaveOccurred())
+
+			ub, err = broker.NewUser("test@example.com", "test")
+			Expect(err).NotTo(HaveOccurred())
+			_, _, err = ub.CreateApplication(container.CreateOptions{Name: "demo"}, []string{"mock"})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(config.RootDir)
+			config.RootDir = rootDir
+		})
+
+		It("should resume interrupted uploads from the received offset", func() {
+			upload, err := ub.CreateUpload("demo", 11)
+			Expect(err).NotTo(HaveOccurred())
+
+			// the connection breaks after 6 bytes
+			pr, pw := io.Pipe()
+			go func() {
+				pw.Write([]byte("hello "))
+				pw.CloseWithError(errors.New("connection reset"))
+			}()
+			upload, err = ub.AppendUpload("demo", upload.ID, 0, pr)
+			Expect(err).To(HaveOccurred())
+			Expect(upload.Offset).To(BeEquivalentTo(6))
+
+			_, err = ub.OpenUpload("demo", upload.ID)
+			Expect(err).To(BeAssignableToTypeOf(br.IncompleteUploadError{}))
+
+			_, err = ub.AppendUpload("demo", upload.ID, 0, strings.NewReader("hello world"))
+			Expect(err).To(Equal(br.UploadOffsetError{ID: upload.ID, Offset: 0, Expected: 6}))
+
+			upload, err = ub.AppendUpload("demo", upload.ID, 6, strings.NewReader("world"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(upload.Complete()).To(BeTrue())
+
+			content, err := ub.OpenUpload("demo", upload.ID)
+			Expect(err).NotTo(HaveOccurred())
+			data, err := ioutil.ReadAll(content)
+			content.Close()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal("hello world"))
+
+			Expect(ub.RemoveUpload("demo", upload.ID)).To(Succeed())
+			_, err = ub.GetUpload("demo", upload.ID)
+			Expect(err).To(Equal(br.UploadNotFoundError(upload.ID)))
+		})
+
+		It("should reject content beyond the upload size", func() {
+			upload, err := ub.CreateUpload("demo", 5)
+			Expect(err).NotTo(HaveOccurred())
+
+			upload, err = ub.AppendUpload("demo", upload.ID, 0, strings.NewReader("hello world"))
+			Expect(err).To(BeAssignableToTypeOf(br.IncompleteUploadError{}))
+			Expect(upload.Offset).To(BeEquivalentTo(5))
+		})
+
+		It("should not find uploads of other applications", func() {
+			upload, err := ub.CreateUpload("demo", 5)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = ub.GetUpload("other", upload.ID)
+			Expect(err).To(Equal(br.UploadNotFoundError(upload.ID)))
+			_, err = ub.GetUpload("demo", "../../etc/passwd")
+			Expect(err).To(Equal(br.UploadNotFoundError("../../etc/passwd")))
+		})
+	})
+
+	Context("Data restore", func() {
+		var (
+			ub       *br.UserBroker
+			restored map[string]string
+		)
+
+		BeforeEach(func() {
+			var err error
+			ub, err = broker.NewUser("test@example.com", "test")
+			Expect(err).NotTo(HaveOccurred())
+			_, _, err = ub.CreateApplication(container.CreateOptions{Name: "demo"}, []string{"mock", "mockdb"})
+			Expect(err).NotTo(HaveOccurred())
+
+			// each container dumps its ID, and records the restored data
+			restored = make(map[string]string)
+			broker.Engine.ExecHandler = func(c *Container, cmd []string, stdin io.Reader, stdout io.Writer) error {
+				switch strings.Join(cmd, " ") {
+				case "cwctl dump":
+					tw := tar.NewWriter(stdout)
+					tw.WriteHeader(&tar.Header{Name: "data", Mode: 0644, Size: int64(len(c.ID()))})
+					tw.Write([]byte(c.ID()))
+					return tw.Close()
+				case "cwctl restore":
+					tr := tar.NewReader(stdin)
+					if _, err := tr.Next(); err != nil {
+						return err
+					}
+					data, err := ioutil.ReadAll(tr)
+					restored[c.ID()] = string(data)
+					return err
+				}
+				return nil
+			}
+		})
+
+		var dump = func() []byte {
+			r, err := ub.Dump("demo")
+			Expect(err).NotTo(HaveOccurred())
+			defer r.Close()
+
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			_, err = io.Copy(zw, r)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(zw.Close()).To(Succeed())
+			return buf.Bytes()
+		}
+
+		It("should restore snapshots to containers", func() {
+			data := dump()
+			Expect(ub.Restore("demo", bytes.NewReader(data), serverlog.Discard)).To(Succeed())
+
+			cs, err := broker.Engine.FindAll(context.Background(), "demo", "test")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(restored).To(HaveLen(len(cs)))
+			for _, c := range cs {
+				Expect(restored).To(HaveKeyWithValue(c.ID(), c.ID()))
+			}
+		})
+
+		It("should not restore truncated archive", func() {
+			data := dump()
+			err := ub.Restore("demo", bytes.NewReader(data[:len(data)/2]), serverlog.Discard)
+			Expect(err).To(BeAssignableToTypeOf(br.InvalidArchiveError("")))
+			Expect(restored).To(BeEmpty())
+		})
+
+		It("should not restore archive with unexpected entries", func() {
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			tw := tar.NewWriter(zw)
+			tw.WriteHeader(&tar.Header{Name: "services/unknown.tar", Mode: 0644})
+			tw.Close()
+			zw.Close()
+
+			err := ub.Restore("demo", &buf, serverlog.Discard)
+			Expect(err).To(BeAssignableToTypeOf(br.InvalidArchiveError("")))
+			Expect(restored).To(BeEmpty())
+		})
+
+		It("should restore a single service", func() {
+			cs, err := broker.Engine.FindAll(context.Background(), "demo", "test")
+			Expect(err).NotTo(HaveOccurred())
+			var db container.Container
+			for _, c := range cs {
+				if c.Category().IsService() {
+					db = c
+				}
+			}
+			Expect(db).NotTo(BeNil())
+
+			r, err := ub.DumpService("demo", db.ServiceName())
+			Expect(err).NotTo(HaveOccurred())
+			data, err := ioutil.ReadAll(r)
+			r.Close()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ub.RestoreService("demo", db.ServiceName(), bytes.NewReader(data), serverlog.Discard)).To(Succeed())
+			Expect(restored).To(Equal(map[string]string{db.ID(): db.ID()}))
+
+			err = ub.RestoreService("demo", db.ServiceName(), bytes.NewReader(data[:520]), serverlog.Discard)
+			Expect(err).To(BeAssignableToTypeOf(br.InvalidArchiveError("")))
+		})
+
+		It("should import exported application into another namespace", func() {
+			cs, err := broker.Engine.FindAll(context.Background(), "demo", "test")
+			Expect(err).NotTo(HaveOccurred())
+			dumped := make(map[string]bool)
+			for _, c := range cs {
+				dumped[c.ID()] = true
+				if c.Category().IsFramework() {
+					var repo bytes.Buffer
+					tw := tar.NewWriter(&repo)
+					tw.WriteHeader(&tar.Header{Name: "README", Mode: 0644, Size: 5})
+					tw.Write([]byte("hello"))
+					tw.Close()
+					Expect(c.CopyTo(context.Background(), c.RepoDir(), &repo)).To(Succeed())
+					Expect(c.Setenv(context.Background(), "GREETING", "hello")).To(Succeed())
+					Expect(c.Setenv(context.Background(), "CLOUDWAY_APP_DNS", "demo-test.example.com")).To(Succeed())
+				}
+			}
+
+			r, err := ub.ExportApplication("demo")
+			Expect(err).NotTo(HaveOccurred())
+			bundle, err := ioutil.ReadAll(r)
+			r.Close()
+			Expect(err).NotTo(HaveOccurred())
+
+			other, err := broker.NewUser("other@example.com", "other")
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, err = other.ImportApplication(bytes.NewReader(bundle[:len(bundle)/2]), "", serverlog.Discard)
+			Expect(err).To(BeAssignableToTypeOf(br.InvalidArchiveError("")))
+			Expect(broker.SCM.Repo("other", "demo")).To(BeNil())
+
+			name, app, err := other.ImportApplication(bytes.NewReader(bundle), "", serverlog.Discard)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal("demo"))
+			Expect(app.Env).To(Equal(map[string]string{"GREETING": "hello"}))
+			Expect(broker.SCM.Repo("other", "demo").Content).NotTo(BeEmpty())
+
+			// the data dumped from original containers is restored
+			imported, err := broker.Engine.FindAll(context.Background(), "demo", "other")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(imported).To(HaveLen(len(cs)))
+			for _, c := range imported {
+				Expect(dumped).To(HaveKey(restored[c.ID()]))
+			}
+		})
+	})
+})
@@ -0,0 +1,762 @@
+package brokertest
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// Engine is an in-memory container engine. Containers are records of the
+// creation options and the state changed by the broker, no process is
+// running in containers. Commands executed in containers are recorded and
+// handled by the ExecHandler if set.
+type Engine struct {
+	mu         sync.Mutex
+	containers []*Container
+	images     map[string]bool
+	nextID     int
+
+	// ExecHandler handles commands executed in containers, the command
+	// succeeds if the handler is nil.
+	ExecHandler func(c *Container, cmd []string, stdin io.Reader, stdout io.Writer) error
+}
+
+// NewEngine creates an in-memory container engine without containers.
+func NewEngine() *Engine {
+	return &Engine{images: make(map[string]bool)}
+}
+
+func (e *Engine) ServerVersion(ctx context.Context) (string, error) {
+	return "memory", nil
+}
+
+func (e *Engine) NodeInfo(ctx context.Context) (*container.NodeInfo, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	info := &container.NodeInfo{Name: "memory", Version: "memory", Containers: len(e.containers)}
+	for _, c := range e.containers {
+		if c.state == manifest.StateRunning {
+			info.ContainersRunning++
+		} else {
+			info.ContainersStopped++
+		}
+	}
+	return info, nil
+}
+
+func (e *Engine) PullImage(ctx context.Context, image string, out io.Writer) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.images[image] = true
+	return nil
+}
+
+// PublishImage records the image as present on the node.
+func (e *Engine) PublishImage(ctx context.Context, id, ref string, out io.Writer) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.images[ref] = true
+	return nil
+}
+
+// Pulled returns true if the image has been pulled or published.
+func (e *Engine) Pulled(image string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.images[image]
+}
+
+func (e *Engine) Create(ctx context.Context, opts container.CreateOptions) ([]container.Container, error) {
+	if opts.Plugin == nil {
+		return nil, fmt.Errorf("The plugin is required to create containers")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	n := 1
+	switch opts.Plugin.Category {
+	case manifest.Framework:
+		if opts.ServiceName != "" {
+			return nil, fmt.Errorf("The application name cannot contains a serivce name: %s", opts.ServiceName)
+		}
+		scaling := opts.Scaling
+		if scaling <= 0 {
+			return nil, fmt.Errorf("Invalid scaling value, it must be greater than 0")
+		}
+		existing := len(e.find(opts.Name, opts.Namespace, manifest.Framework, ""))
+		if scaling <= existing {
+			return nil, fmt.Errorf("Application containers already reached maximum scaling value. "+
+				"(maximum scaling = %d, existing containers = %d", scaling, existing)
+		}
+		n = scaling - existing
+	case manifest.Service:
+		if opts.ServiceName == "" {
+			opts.ServiceName = opts.Plugin.Name
+		}
+		if len(e.find(opts.Name, opts.Namespace, manifest.Service, opts.ServiceName)) != 0 {
+			return nil, fmt.Errorf("%s: service already exists in '%s' application", opts.ServiceName, opts.Name)
+		}
+	default:
+		return nil, fmt.Errorf("%s:%s is not a valid plugin", opts.Plugin.Name, opts.Plugin.Version)
+	}
+
+	var result []container.Container
+	for i := 0; i < n; i++ {
+		c := e.newContainer(opts)
+		e.containers = append(e.containers, c)
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+func (e *Engine) newContainer(opts container.CreateOptions) *Container {
+	e.nextID++
+	c := &Container{
+		Engine:      e,
+		id:          fmt.Sprintf("%012x", e.nextID),
+		name:        opts.Name,
+		namespace:   opts.Namespace,
+		serviceName: opts.ServiceName,
+		plugin:      opts.Plugin,
+		flags:       opts.Flags,
+		user:        opts.User,
+		home:        opts.Home,
+		ip:          fmt.Sprintf("172.17.%d.%d", e.nextID/254, e.nextID%254+1),
+		state:       manifest.StateNew,
+		env:         make(map[string]string),
+		hosts:       append([]string(nil), opts.Hosts...),
+		labels:      make(map[string]string),
+		files:       make(map[string][]byte),
+		logging:     opts.Logging,
+		domain:      opts.Domain,
+	}
+	if c.user == "" {
+		if c.plugin.User != "" {
+			c.user = c.plugin.User
+		} else {
+			c.user = defaults.AppUser()
+		}
+	}
+	if c.home == "" {
+		c.home = defaults.AppHome()
+	}
+	for k, v := range opts.Labels {
+		c.labels[k] = v
+	}
+
+	for k, v := range opts.Env {
+		c.env[k] = v
+	}
+	c.env["CLOUDWAY_APP_NAME"] = c.name
+	c.env["CLOUDWAY_APP_NAMESPACE"] = c.namespace
+	c.env["CLOUDWAY_SHARED_SECRET"] = opts.Secret
+	c.env["CLOUDWAY_APP_USER"] = c.user
+	c.env["CLOUDWAY_APP_DNS"] = c.FQDN()
+	c.env["CLOUDWAY_HOME_DIR"] = c.home
+	if c.serviceName != "" {
+		c.env["CLOUDWAY_SERVICE_NAME"] = c.serviceName
+	}
+	return c
+}
+
+func (e *Engine) Inspect(ctx context.Context, id string) (container.Container, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, c := range e.containers {
+		if c.id == id || strings.HasPrefix(c.id, id) {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("No such container: %s", id)
+}
+
+func (e *Engine) FindInNamespace(ctx context.Context, namespace string) ([]container.Container, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.find("", namespace, "", ""), nil
+}
+
+func (e *Engine) FindAll(ctx context.Context, name, namespace string) ([]container.Container, error) {
+	if name == "" || namespace == "" {
+		return nil, nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.find(name, namespace, "", ""), nil
+}
+
+func (e *Engine) FindApplications(ctx context.Context, name, namespace string) ([]container.Container, error) {
+	if name == "" || namespace == "" {
+		return nil, nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.find(name, namespace, manifest.Framework, ""), nil
+}
+
+func (e *Engine) FindService(ctx context.Context, name, namespace, service string) ([]container.Container, error) {
+	if name == "" || namespace == "" {
+		return nil, nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.find(name, namespace, manifest.Service, service), nil
+}
+
+func (e *Engine) find(name, namespace string, category manifest.Category, service string) []container.Container {
+	var result []container.Container
+	for _, c := range e.containers {
+		if (name == "" || c.name == name) &&
+			(namespace == "" || c.namespace == namespace) &&
+			(category == "" || c.plugin.Category == category) &&
+			(service == "" || c.serviceName == service) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (e *Engine) DistributeRepo(ctx context.Context, containers []container.Container, repo io.Reader, zip bool, log *serverlog.ServerLog) error {
+	content, err := ioutil.ReadAll(repo)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, c := range containers {
+		if c, ok := c.(*Container); ok {
+			c.repo = content
+			c.deployments++
+		}
+	}
+	return nil
+}
+
+func (e *Engine) DeployRepo(ctx context.Context, name, namespace string, in io.Reader, log *serverlog.ServerLog) error {
+	e.mu.Lock()
+	cs := e.find(name, namespace, manifest.Framework, "")
+	e.mu.Unlock()
+	return e.DistributeRepo(ctx, cs, in, false, log)
+}
+
+func (e *Engine) ExecResize(ctx context.Context, execID string, size container.TtySize) error {
+	return nil
+}
+
+func (e *Engine) remove(c *Container) {
+	for i, x := range e.containers {
+		if x == c {
+			e.containers = append(e.containers[:i:i], e.containers[i+1:]...)
+			return
+		}
+	}
+}
+
+var _ container.Engine = (*Engine)(nil)
+
+// Container is a container in the in-memory engine.
+type Container struct {
+	*Engine
+
+	id          string
+	name        string
+	namespace   string
+	serviceName string
+	plugin      *manifest.Plugin
+	flags       uint32
+	user        string
+	home        string
+	ip          string
+	labels      map[string]string
+	domain      string
+
+	state        manifest.ActiveState
+	startedAt    time.Time
+	restartCount int
+	env          map[string]string
+	hosts        []string
+	maintenance  string
+	weight       int
+	policy       *manifest.AccessPolicy
+	settings     *manifest.HTTPSettings
+	logging      *container.LogConfig
+	files        map[string][]byte
+	repo         []byte
+	deployments  int
+	execs        [][]string
+}
+
+func (c *Container) ID() string                  { return c.id }
+func (c *Container) Name() string                { return c.name }
+func (c *Container) Namespace() string           { return c.namespace }
+func (c *Container) Version() string             { return c.plugin.Version }
+func (c *Container) Category() manifest.Category { return c.plugin.Category }
+func (c *Container) Flags() uint32               { return c.flags }
+func (c *Container) ServiceName() string         { return c.serviceName }
+func (c *Container) DependsOn() []string         { return c.plugin.DependsOn }
+func (c *Container) IP() string                  { return c.ip }
+func (c *Container) User() string                { return c.user }
+func (c *Container) Home() string                { return c.home }
+func (c *Container) EnvDir() string              { return c.home + "/.env" }
+func (c *Container) RepoDir() string             { return c.home + "/repo" }
+func (c *Container) DeployDir() string           { return c.home + "/deploy" }
+func (c *Container) DataDir() string             { return c.home + "/data" }
+func (c *Container) LogDir() string              { return c.home + "/logs" }
+
+func (c *Container) PluginTag() string {
+	if c.plugin.Tag != "" {
+		return c.plugin.Tag
+	}
+	return c.plugin.Name + ":" + c.plugin.Version
+}
+
+func (c *Container) Hostname() string {
+	if c.Category().IsService() {
+		return c.serviceName + "." + c.name + "-" + c.namespace
+	}
+	return c.name + "-" + c.namespace
+}
+
+func (c *Container) FQDN() string {
+	host := defaults.AppHost(c.name, c.namespace, c.domain)
+	if c.Category().IsService() {
+		return c.serviceName + "." + host
+	}
+	return host
+}
+
+func (c *Container) StartedAt() string {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	if c.startedAt.IsZero() {
+		return ""
+	}
+	return c.startedAt.Format(time.RFC3339Nano)
+}
+
+func (c *Container) RestartCount() int {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	return c.restartCount
+}
+
+// Resources returns no limits, fake containers are not limited.
+func (c *Container) Resources() container.Resources {
+	return container.Resources{}
+}
+
+// Labels returns labels of the container given in creation options.
+func (c *Container) Labels() map[string]string {
+	return c.labels
+}
+
+// Repo returns the repository archive deployed to the container.
+func (c *Container) Repo() []byte {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	return c.repo
+}
+
+// Deployments returns the number of deployments to the container.
+func (c *Container) Deployments() int {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	return c.deployments
+}
+
+// Execs returns commands executed in the container.
+func (c *Container) Execs() [][]string {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	return append([][]string(nil), c.execs...)
+}
+
+func (c *Container) Start(ctx context.Context, log *serverlog.ServerLog) error {
+	return c.setState(manifest.StateRunning, false)
+}
+
+func (c *Container) Restart(ctx context.Context, log *serverlog.ServerLog) error {
+	return c.setState(manifest.StateRunning, true)
+}
+
+func (c *Container) Stop(ctx context.Context) error {
+	return c.setState(manifest.StateStopped, false)
+}
+
+func (c *Container) setState(state manifest.ActiveState, restart bool) error {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+
+	if state == manifest.StateRunning && (restart || c.state != manifest.StateRunning) {
+		c.startedAt = time.Now()
+		if restart {
+			c.restartCount++
+		}
+	}
+	c.state = state
+	return nil
+}
+
+func (c *Container) Destroy(ctx context.Context) error {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	c.Engine.remove(c)
+	return nil
+}
+
+func (c *Container) Rename(ctx context.Context, name, namespace, domain string) (container.Container, error) {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+
+	nc := *c
+	c.Engine.nextID++
+	nc.id = fmt.Sprintf("%012x", c.Engine.nextID)
+	nc.name, nc.namespace, nc.domain = name, namespace, domain
+	nc.state = manifest.StateNew
+	nc.env = make(map[string]string)
+	for k, v := range c.env {
+		nc.env[k] = v
+	}
+	nc.env["CLOUDWAY_APP_NAME"] = name
+	nc.env["CLOUDWAY_APP_NAMESPACE"] = namespace
+	nc.env["CLOUDWAY_APP_DNS"] = nc.FQDN()
+	nc.files = make(map[string][]byte)
+	for k, v := range c.files {
+		nc.files[k] = v
+	}
+
+	c.Engine.remove(c)
+	c.Engine.containers = append(c.Engine.containers, &nc)
+	return &nc, nil
+}
+
+func (c *Container) SetLogging(ctx context.Context, logging *container.LogConfig) (container.Container, error) {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+
+	nc := *c
+	c.Engine.nextID++
+	nc.id = fmt.Sprintf("%012x", c.Engine.nextID)
+	nc.state = manifest.StateNew
+	nc.logging = logging
+
+	c.Engine.remove(c)
+	c.Engine.containers = append(c.Engine.containers, &nc)
+	return &nc, nil
+}
+
+// Logging returns the log shipping configuration of the container.
+func (c *Container) Logging() *container.LogConfig {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	return c.logging
+}
+
+func (c *Container) Exec(ctx context.Context, user string, stdin io.Reader, stdout, stderr io.Writer, cmd ...string) error {
+	c.Engine.mu.Lock()
+	c.execs = append(c.execs, cmd)
+	handler := c.Engine.ExecHandler
+	c.Engine.mu.Unlock()
+
+	if handler == nil {
+		return nil
+	}
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	return handler(c, cmd, stdin, stdout)
+}
+
+func (c *Container) ExecWithOptions(ctx context.Context, opts container.ExecOptions, cmd ...string) error {
+	return c.Exec(ctx, opts.User, opts.Stdin, opts.Stdout, opts.Stderr, cmd...)
+}
+
+func (c *Container) ExecE(ctx context.Context, user string, in io.Reader, out io.Writer, cmd ...string) error {
+	return c.Exec(ctx, user, in, out, nil, cmd...)
+}
+
+func (c *Container) ExecQ(ctx context.Context, user string, cmd ...string) error {
+	return c.Exec(ctx, user, nil, nil, nil, cmd...)
+}
+
+func (c *Container) Subst(ctx context.Context, user string, in io.Reader, cmd ...string) (string, error) {
+	var out bytes.Buffer
+	err := c.Exec(ctx, user, in, &out, nil, cmd...)
+	return strings.TrimRight(out.String(), "\n"), err
+}
+
+func (c *Container) Run(ctx context.Context, cmd *container.RunCmd) error {
+	if cmd.BeforeStart != nil {
+		if err := cmd.BeforeStart(cmd); err != nil {
+			return err
+		}
+	}
+	err := c.Exec(ctx, "", cmd.Stdin, cmd.Stdout, nil, cmd.Cmd...)
+	if err != nil {
+		cmd.ExitCode = 1
+	}
+	if cmd.OnExit != nil {
+		cmd.OnExit(cmd)
+	}
+	return err
+}
+
+func (c *Container) Processes(ctx context.Context) (*container.ProcessList, error) {
+	return &container.ProcessList{}, nil
+}
+
+func (c *Container) Stats(ctx context.Context, stream bool) (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader("{}\n")), nil
+}
+
+func (c *Container) DiskUsage(ctx context.Context) (int64, error) {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+
+	var size int64
+	for _, data := range c.files {
+		size += int64(len(data))
+	}
+	return size, nil
+}
+
+// CopyTo extracts regular files in the tar archive into the container.
+func (c *Container) CopyTo(ctx context.Context, dir string, content io.Reader) error {
+	tr := tar.NewReader(content)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		c.Engine.mu.Lock()
+		c.files[path.Join("/", dir, hdr.Name)] = data
+		c.Engine.mu.Unlock()
+	}
+}
+
+// isDir returns true if the path is one of the directories present in
+// every container, which may be empty.
+func (c *Container) isDir(file string) bool {
+	for _, dir := range []string{"/", c.home, c.EnvDir(), c.RepoDir(), c.DeployDir(), c.DataDir(), c.LogDir()} {
+		if file == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// CopyFrom returns a tar archive of files at or under the path.
+// Well known directories of the container exist even if they are empty.
+func (c *Container) CopyFrom(ctx context.Context, file string) (io.ReadCloser, error) {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+
+	file = path.Clean(path.Join("/", file))
+	var names []string
+	for name := range c.files {
+		if name == file || strings.HasPrefix(name, file+"/") || file == "/" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 && !c.isDir(file) {
+		return nil, fmt.Errorf("No such file or directory: %s", file)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if len(names) == 0 {
+		hdr := &tar.Header{Name: path.Base(file) + "/", Mode: 0755, Typeflag: tar.TypeDir}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range names {
+		rel := path.Base(file)
+		if name != file {
+			rel = path.Join(rel, strings.TrimPrefix(name, file+"/"))
+		}
+		data := c.files[name]
+		hdr := &tar.Header{Name: rel, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(&buf), nil
+}
+
+func (c *Container) Patch(ctx context.Context, content io.Reader) (container.Container, error) {
+	return c, c.CopyTo(ctx, "/", content)
+}
+
+func (c *Container) Deploy(ctx context.Context, path string, log *serverlog.ServerLog) error {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	c.deployments++
+	return nil
+}
+
+func (c *Container) GetInfo(ctx context.Context, options ...string) (*manifest.SandboxInfo, error) {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+
+	info := &manifest.SandboxInfo{
+		Env:       make(map[string]string),
+		Endpoints: c.plugin.GetEndpoints(c.FQDN(), c.serviceName, c.ip),
+		Plugins:   []*manifest.Plugin{c.plugin},
+		State:     c.state,
+	}
+	for k, v := range c.env {
+		info.Env[k] = v
+	}
+	return info, nil
+}
+
+func (c *Container) Setenv(ctx context.Context, name, value string) error {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	c.env[name] = value
+	return nil
+}
+
+func (c *Container) Getenv(ctx context.Context, name string) (string, error) {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	return c.env[name], nil
+}
+
+func (c *Container) ActiveState(ctx context.Context) manifest.ActiveState {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	return c.state
+}
+
+func (c *Container) AddHost(ctx context.Context, host string, more ...string) error {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+
+	for _, h := range append([]string{host}, more...) {
+		found := false
+		for _, x := range c.hosts {
+			if x == h {
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.hosts = append(c.hosts, h)
+		}
+	}
+	return nil
+}
+
+func (c *Container) RemoveHost(ctx context.Context, host string, more ...string) error {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+
+	removed := make(map[string]bool)
+	for _, h := range append([]string{host}, more...) {
+		removed[h] = true
+	}
+	var hosts []string
+	for _, h := range c.hosts {
+		if !removed[h] {
+			hosts = append(hosts, h)
+		}
+	}
+	c.hosts = hosts
+	return nil
+}
+
+func (c *Container) GetHosts(ctx context.Context) []string {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	return append([]string(nil), c.hosts...)
+}
+
+func (c *Container) SetMaintenance(ctx context.Context, user string) error {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	c.maintenance = user
+	return nil
+}
+
+func (c *Container) Maintenance(ctx context.Context) string {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	return c.maintenance
+}
+
+func (c *Container) SetTrafficWeight(ctx context.Context, weight int) error {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	c.weight = weight
+	return nil
+}
+
+func (c *Container) TrafficWeight(ctx context.Context) int {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	return c.weight
+}
+
+func (c *Container) SetAccessPolicy(ctx context.Context, policy *manifest.AccessPolicy) error {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	c.policy = policy
+	return nil
+}
+
+func (c *Container) AccessPolicy(ctx context.Context) *manifest.AccessPolicy {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	return c.policy
+}
+
+func (c *Container) SetHTTPSettings(ctx context.Context, settings *manifest.HTTPSettings) error {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	c.settings = settings
+	return nil
+}
+
+func (c *Container) HTTPSettings(ctx context.Context) *manifest.HTTPSettings {
+	c.Engine.mu.Lock()
+	defer c.Engine.mu.Unlock()
+	return c.settings
+}
+
+var _ container.Container = (*Container)(nil)
@@ -0,0 +1,466 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// A canary release deploys a build artifact to some of the application
+// containers, the canary generation, and routes a percentage of traffic
+// to them by weights in the proxy. The release is then promoted, which
+// deploys the artifact to all containers, or rolled back, which restores
+// the canary containers from the stable generation.
+//
+// Releases started with automatic promotion are watched by the canary
+// monitor, and rolled back as soon as a canary container fails or
+// restarts, or promoted after staying healthy for the duration configured
+// by "app.canary_promote_after".
+const (
+	defaultCanaryPromoteAfter = 10 * time.Minute
+	canaryCheckInterval       = time.Minute
+)
+
+// CanaryOptions controls the traffic split of an application.
+type CanaryOptions struct {
+	// The build artifact to deploy to the canary containers, which starts
+	// a new canary release.
+	Artifact string
+
+	// The percentage of traffic routed to the canary containers.
+	Weight int
+
+	// The number of canary containers, default to 1.
+	Containers int
+
+	// Promote or rollback the release automatically based on health of
+	// the canary containers.
+	AutoPromote bool
+
+	// Promote or rollback the release in progress.
+	Promote  bool
+	Rollback bool
+}
+
+type InvalidCanaryError struct {
+	Message string
+}
+
+func (e InvalidCanaryError) Error() string {
+	return e.Message
+}
+
+func (e InvalidCanaryError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// The CanaryInProgressError indicates that the operation is not allowed
+// while a canary release of the application is in progress.
+type CanaryInProgressError struct {
+	Name string
+}
+
+func (e CanaryInProgressError) Error() string {
+	return fmt.Sprintf("A canary release of '%s' is in progress, promote or rollback it first", e.Name)
+}
+
+func (e CanaryInProgressError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+type NoCanaryError struct {
+	Name string
+}
+
+func (e NoCanaryError) Error() string {
+	return fmt.Sprintf("No canary release of '%s' is in progress", e.Name)
+}
+
+func (e NoCanaryError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+// SetTraffic starts a canary release of the application, changes the
+// traffic weight of the release in progress, or promotes or rolls back
+// the release. Returns the release in progress, or nil if the release
+// is completed.
+func (br *UserBroker) SetTraffic(name string, opts CanaryOptions) (*userdb.Canary, error) {
+	if opts.Weight < 0 || opts.Weight > 100 {
+		return nil, InvalidCanaryError{"The traffic weight must be a percentage between 0 and 100"}
+	}
+	if opts.Promote && opts.Rollback {
+		return nil, InvalidCanaryError{"Can't promote and rollback at the same time"}
+	}
+
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	user := br.User.Basic()
+	app := user.Applications[name]
+	if app == nil {
+		return nil, ApplicationNotFoundError(name)
+	}
+
+	unlock, err := br.lockApp(name, user.Namespace, "deploy")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// promotion and rollback remove weights, which all proxies can do
+	if !opts.Promote && !opts.Rollback {
+		if err = checkProxyFeature(ProxyWeights); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case opts.Artifact != "":
+		if err = CheckDeployAllowed(name, app, time.Now()); err != nil {
+			return nil, err
+		}
+		if opts.Promote || opts.Rollback {
+			return nil, InvalidCanaryError{"Can't promote or rollback a new canary release"}
+		}
+		return br.startCanary(br.ctx, user.Name, name, user.Namespace, user.Name, opts)
+	case app.Canary == nil:
+		return nil, NoCanaryError{name}
+	case opts.Promote:
+		return nil, br.promoteCanary(br.ctx, user.Name, name, user.Namespace, app.Canary)
+	case opts.Rollback:
+		return nil, br.rollbackCanary(br.ctx, user.Name, name, user.Namespace, app.Canary)
+	default:
+		return br.setCanaryWeight(br.ctx, user.Name, name, user.Namespace, opts.Weight)
+	}
+}
+
+func (br *Broker) startCanary(ctx context.Context, owner, name, namespace, by string, opts CanaryOptions) (*userdb.Canary, error) {
+	artifact, err := readArtifact(name, namespace, opts.Artifact)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := br.FindApplications(ctx, name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	n := opts.Containers
+	if n <= 0 {
+		n = 1
+	}
+	if n >= len(containers) {
+		return nil, InvalidCanaryError{fmt.Sprintf(
+			"A canary release of %d containers requires at least %d application containers, scale the application first",
+			n, n+1)}
+	}
+
+	// select canary containers in a stable order
+	sort.Sort(containersByID(containers))
+	canaries := containers[:n]
+
+	canary := &userdb.Canary{
+		Artifact:    artifact.ID,
+		Restarts:    make(map[string]int),
+		Weight:      opts.Weight,
+		AutoPromote: opts.AutoPromote,
+		By:          by,
+		Since:       time.Now(),
+	}
+	for _, c := range canaries {
+		canary.Containers = append(canary.Containers, c.ID())
+	}
+
+	logrus.Infof("Starting canary release of %s-%s with artifact %s", name, namespace, artifact.ID)
+	repo, zip, err := openArtifactRepo(artifact)
+	if err != nil {
+		return nil, err
+	}
+	err = br.DistributeRepo(ctx, canaries, repo, zip, nil)
+	repo.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// restart counts are recorded after deployed as the deployment may
+	// restart containers
+	for _, c := range canaries {
+		if c2, err := br.Inspect(ctx, c.ID()); err == nil {
+			canary.Restarts[c.ID()] = c2.RestartCount()
+		}
+	}
+
+	if err = br.applyTrafficWeights(ctx, containers, canary); err != nil {
+		return nil, err
+	}
+	return canary, br.saveCanary(owner, name, canary)
+}
+
+type containersByID []container.Container
+
+func (a containersByID) Len() int           { return len(a) }
+func (a containersByID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a containersByID) Less(i, j int) bool { return a[i].ID() < a[j].ID() }
+
+func (br *Broker) setCanaryWeight(ctx context.Context, owner, name, namespace string, weight int) (*userdb.Canary, error) {
+	app, err := br.Users.ModifyApplication(owner, name, func(app *userdb.Application) error {
+		if app.Canary == nil {
+			return NoCanaryError{name}
+		}
+		app.Canary.Weight = weight
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := br.FindApplications(ctx, name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return app.Canary, br.applyTrafficWeights(ctx, containers, app.Canary)
+}
+
+// promoteCanary deploys the canary artifact to the stable containers and
+// routes traffic to all containers evenly.
+func (br *Broker) promoteCanary(ctx context.Context, owner, name, namespace string, canary *userdb.Canary) error {
+	logrus.Infof("Promoting canary release of %s-%s", name, namespace)
+
+	artifact, err := readArtifact(name, namespace, canary.Artifact)
+	if err != nil {
+		return err
+	}
+	containers, err := br.FindApplications(ctx, name, namespace)
+	if err != nil {
+		return err
+	}
+
+	_, stable := splitCanaries(containers, canary)
+	if len(stable) != 0 {
+		repo, zip, err := openArtifactRepo(artifact)
+		if err != nil {
+			return err
+		}
+		err = br.DistributeRepo(ctx, stable, repo, zip, nil)
+		repo.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = br.applyTrafficWeights(ctx, containers, nil); err != nil {
+		return err
+	}
+	if err = br.saveCanary(owner, name, nil); err != nil {
+		return err
+	}
+
+	artifact.DeployedAt = time.Now().UTC()
+	if err = writeArtifactMeta(artifact); err != nil {
+		return err
+	}
+	pruneArtifacts(name, namespace)
+	br.notifyApp(ApplicationDeployed, name, namespace, map[string]string{"artifact": artifact.ID, "canary": "promoted"})
+	return nil
+}
+
+// rollbackCanary restores the canary containers from the repository of a
+// stable container and routes traffic to all containers evenly.
+func (br *Broker) rollbackCanary(ctx context.Context, owner, name, namespace string, canary *userdb.Canary) error {
+	logrus.Infof("Rolling back canary release of %s-%s", name, namespace)
+
+	containers, err := br.FindApplications(ctx, name, namespace)
+	if err != nil {
+		return err
+	}
+
+	canaries, stable := splitCanaries(containers, canary)
+	if len(canaries) != 0 && len(stable) != 0 {
+		repo, err := stable[0].CopyFrom(ctx, stable[0].RepoDir()+"/.")
+		if err != nil {
+			return err
+		}
+		err = br.DistributeRepo(ctx, canaries, repo, false, nil)
+		repo.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = br.applyTrafficWeights(ctx, containers, nil); err != nil {
+		return err
+	}
+	return br.saveCanary(owner, name, nil)
+}
+
+func (br *Broker) saveCanary(owner, name string, canary *userdb.Canary) error {
+	_, err := br.Users.ModifyApplication(owner, name, func(app *userdb.Application) error {
+		app.Canary = canary
+		return nil
+	})
+	return err
+}
+
+// splitCanaries splits application containers into canary and stable
+// generations.
+func splitCanaries(containers []container.Container, canary *userdb.Canary) (canaries, stable []container.Container) {
+	ids := make(map[string]bool)
+	if canary != nil {
+		for _, id := range canary.Containers {
+			ids[id] = true
+		}
+	}
+	for _, c := range containers {
+		if ids[c.ID()] {
+			canaries = append(canaries, c)
+		} else {
+			stable = append(stable, c)
+		}
+	}
+	return
+}
+
+// applyTrafficWeights sets traffic weights of application containers
+// according to the canary release, and notifies the proxy. Weights are
+// reset to default if the canary is nil.
+func (br *Broker) applyTrafficWeights(ctx context.Context, containers []container.Container, canary *userdb.Canary) error {
+	canaries, stable := splitCanaries(containers, canary)
+	var cw, sw int
+	if canary != nil {
+		cw, sw = CanaryWeights(canary.Weight, len(canaries), len(stable))
+	}
+
+	weights := make(map[string]int)
+	for _, c := range canaries {
+		weights[c.ID()] = cw
+	}
+	for _, c := range stable {
+		weights[c.ID()] = sw
+	}
+
+	return Parallel(containers, func(c container.Container) error {
+		if err := c.SetTrafficWeight(ctx, weights[c.ID()]); err != nil {
+			return err
+		}
+		if c.ActiveState(ctx) == manifest.StateStopped {
+			return nil
+		}
+		return br.notify(ContainerUpdated, c, nil)
+	})
+}
+
+// CanaryWeights returns the weights of each canary container and each
+// stable container, so that the given percentage of traffic is routed to
+// the canary containers. A negative weight drains the container.
+func CanaryWeights(percent, canaries, stable int) (canaryWeight, stableWeight int) {
+	switch {
+	case canaries == 0 || stable == 0:
+		return 0, 0
+	case percent <= 0:
+		return -1, 0
+	case percent >= 100:
+		return 0, -1
+	}
+
+	canaryWeight = percent * stable
+	stableWeight = (100 - percent) * canaries
+	g := gcd(canaryWeight, stableWeight)
+	return canaryWeight / g, stableWeight / g
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// StartCanaryMonitor watches canary releases with automatic promotion
+// periodically until the context is canceled. Only the elected leader of
+// API servers performs the promotion or rollback.
+func (br *Broker) StartCanaryMonitor(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(canaryCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if br.elected("canary", 3*canaryCheckInterval) {
+					br.checkCanaries(ctx)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (br *Broker) checkCanaries(ctx context.Context) {
+	var users []*userdb.BasicUser
+	if err := br.Users.Search(userdb.Args{}, &users); err != nil {
+		logrus.WithError(err).Warn("Failed to list users for canary monitor")
+		return
+	}
+
+	promoteAfter := defaultCanaryPromoteAfter
+	if d, err := time.ParseDuration(config.Get("app.canary_promote_after")); err == nil && d > 0 {
+		promoteAfter = d
+	}
+
+	for _, user := range users {
+		for name, app := range user.Applications {
+			if app.Canary != nil && app.Canary.AutoPromote {
+				br.checkCanary(ctx, user.Name, name, user.Namespace, app.Canary, promoteAfter)
+			}
+		}
+	}
+}
+
+func (br *Broker) checkCanary(ctx context.Context, owner, name, namespace string, canary *userdb.Canary, promoteAfter time.Duration) {
+	healthy, reason := br.canaryHealthy(ctx, name, namespace, canary)
+	if healthy && time.Since(canary.Since) < promoteAfter {
+		return
+	}
+
+	unlock, err := br.lockApp(name, namespace, "deploy")
+	if err != nil {
+		return // try again later
+	}
+	defer unlock()
+
+	if healthy {
+		err = br.promoteCanary(ctx, owner, name, namespace, canary)
+	} else {
+		logrus.Warnf("Canary release of %s-%s is unhealthy: %s", name, namespace, reason)
+		err = br.rollbackCanary(ctx, owner, name, namespace, canary)
+	}
+	if err != nil {
+		logrus.WithError(err).Errorf("Failed to complete canary release of %s-%s", name, namespace)
+	}
+}
+
+// canaryHealthy returns true if all canary containers are running and
+// not restarted since the release started.
+func (br *Broker) canaryHealthy(ctx context.Context, name, namespace string, canary *userdb.Canary) (bool, string) {
+	for _, id := range canary.Containers {
+		c, err := br.Inspect(ctx, id)
+		if err != nil {
+			return false, fmt.Sprintf("container %s not found", id)
+		}
+		if state := br.ActiveState(ctx, c); state != manifest.StateRunning {
+			return false, fmt.Sprintf("container %s is %s", id, state)
+		}
+		if c.RestartCount() > canary.Restarts[id] {
+			return false, fmt.Sprintf("container %s restarted", id)
+		}
+	}
+	return true, ""
+}
@@ -0,0 +1,134 @@
+package broker
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/garyburd/redigo/redis"
+
+	"github.com/cloudway/platform/config"
+)
+
+// Multiple API servers can share the same user database behind a load
+// balancer. Shared state is kept in the user database as leases: servers
+// take turns to run background tasks by electing a leader, and operations
+// on applications are locked across servers. If "redis.url" is configured,
+// cached user records are invalidated on all servers when modified.
+
+// serverID identifies this server as the lease holder.
+var serverID = newServerID()
+
+func newServerID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// elected returns true if this server is the leader to run the background
+// task. The leadership is renewed each time the task runs, and is taken
+// over by another server if not renewed in the given time.
+func (br *Broker) elected(task string, ttl time.Duration) bool {
+	ok, err := br.Users.AcquireLease("leader:"+task, serverID, "", ttl)
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to elect leader for %s", task)
+	}
+	return ok
+}
+
+// holdLease acquires the lease for a long running operation and renews the
+// lease until released, so the lease is not expired while the operation
+// is in progress, and is expired soon if the server crashed.
+func (br *Broker) holdLease(name, value string, ttl time.Duration) (release func(), ok bool, err error) {
+	holder := newServerID()
+	if ok, err = br.Users.AcquireLease(name, holder, value, ttl); !ok || err != nil {
+		return nil, ok, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := br.Users.AcquireLease(name, holder, value, ttl); err != nil {
+					logrus.WithError(err).Warnf("Failed to renew lease %s", name)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	release = func() {
+		close(done)
+		if err := br.Users.ReleaseLease(name, holder); err != nil {
+			logrus.WithError(err).Warnf("Failed to release lease %s", name)
+		}
+	}
+	return release, true, nil
+}
+
+// invalidateChannel is the Redis channel to publish modified user names.
+const invalidateChannel = "cloudway:userdb:invalidate"
+
+// startCacheSync publishes user names modified by this server to Redis, and
+// invalidates cached user records modified by other servers.
+func (br *Broker) startCacheSync(url string) {
+	pool := &redis.Pool{
+		MaxIdle:     2,
+		IdleTimeout: 5 * time.Minute,
+		Dial:        func() (redis.Conn, error) { return redis.DialURL(url) },
+	}
+
+	br.Users.OnInvalidate = func(name string) {
+		conn := pool.Get()
+		defer conn.Close()
+		if _, err := conn.Do("PUBLISH", invalidateChannel, serverID+" "+name); err != nil {
+			logrus.WithError(err).Warn("Failed to publish user cache invalidation")
+		}
+	}
+
+	go func() {
+		for {
+			if err := br.receiveInvalidations(url); err != nil {
+				logrus.WithError(err).Warn("Lost connection to Redis, retrying")
+			}
+			time.Sleep(5 * time.Second)
+		}
+	}()
+}
+
+func (br *Broker) receiveInvalidations(url string) error {
+	conn, err := redis.DialURL(url)
+	if err != nil {
+		return err
+	}
+	psc := redis.PubSubConn{Conn: conn}
+	defer psc.Close()
+
+	if err = psc.Subscribe(invalidateChannel); err != nil {
+		return err
+	}
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Message:
+			msg := strings.SplitN(string(v.Data), " ", 2)
+			if len(msg) == 2 && msg[0] != serverID {
+				br.Users.Invalidate(msg[1])
+			}
+		case error:
+			return v
+		}
+	}
+}
+
+func redisURL() string {
+	return config.Get("redis.url")
+}
@@ -0,0 +1,144 @@
+package broker
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cloudway/platform/auth/userdb"
+)
+
+// An application can be shared with other users, the collaborators, who
+// address the application with its full name "name-namespace". A
+// collaborator with read access can view the application, and one with
+// deploy access can also deploy, start and stop the application. Other
+// operations are reserved to the owner.
+
+// GetCollaborators returns collaborators of the application.
+func (br *UserBroker) GetCollaborators(name string) ([]userdb.Collaborator, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	app := br.User.Basic().Applications[name]
+	if app == nil {
+		return nil, ApplicationNotFoundError(name)
+	}
+	if app.Collaborators == nil {
+		return []userdb.Collaborator{}, nil
+	}
+	return app.Collaborators, nil
+}
+
+// AddCollaborator grants the user access to the application, or changes
+// the access level if the user is already a collaborator.
+func (br *UserBroker) AddCollaborator(name, username, access string) error {
+	if access != userdb.AccessRead && access != userdb.AccessDeploy {
+		return InvalidAccessError(access)
+	}
+
+	owner := br.User.Basic().Name
+	if username == owner {
+		return InvalidCollaboratorError(username)
+	}
+	var other userdb.BasicUser
+	if err := br.Users.Find(username, &other); err != nil {
+		return err
+	}
+
+	return br.modifyCollaborators(name, func(cs []userdb.Collaborator) ([]userdb.Collaborator, error) {
+		for i := range cs {
+			if cs[i].User == username {
+				cs[i].Access = access
+				return cs, nil
+			}
+		}
+		return append(cs, userdb.Collaborator{User: username, Access: access}), nil
+	})
+}
+
+// RemoveCollaborator revokes access of the user to the application.
+func (br *UserBroker) RemoveCollaborator(name, username string) error {
+	return br.modifyCollaborators(name, func(cs []userdb.Collaborator) ([]userdb.Collaborator, error) {
+		for i := range cs {
+			if cs[i].User == username {
+				return append(cs[:i], cs[i+1:]...), nil
+			}
+		}
+		return nil, userdb.UserNotFoundError(username)
+	})
+}
+
+func (br *UserBroker) modifyCollaborators(name string, modify func([]userdb.Collaborator) ([]userdb.Collaborator, error)) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+	user := br.User.Basic()
+	if user.Applications[name] == nil {
+		return ApplicationNotFoundError(name)
+	}
+
+	app, err := br.Users.ModifyApplication(user.Name, name, func(app *userdb.Application) (err error) {
+		app.Collaborators, err = modify(app.Collaborators)
+		return err
+	})
+	if err == nil {
+		user.Applications[name] = app
+	}
+	return err
+}
+
+// SharedApplicationBroker returns a broker acting on behalf of the owner of
+// a shared application, after checking that the user is a collaborator of
+// the application with the required access. An empty access restricts the
+// operation to the owner. The full name of the shared application is
+// "name-namespace", the short name is returned.
+func (br *Broker) SharedApplicationBroker(ctx context.Context, user userdb.User, fullname, access string) (*UserBroker, string, error) {
+	name, namespace, ok := SplitFullName(fullname)
+	if !ok {
+		return nil, "", ApplicationNotFoundError(fullname)
+	}
+
+	owner, err := br.Users.FindByNamespace(namespace)
+	if userdb.IsUserNotFound(err) {
+		return nil, "", ApplicationNotFoundError(fullname)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	app := owner.Basic().Applications[name]
+	if app == nil {
+		return nil, "", ApplicationNotFoundError(fullname)
+	}
+
+	username := user.Basic().Name
+	if owner.Basic().Name == username {
+		return br.NewUserBroker(user, ctx), name, nil
+	}
+
+	// users other than collaborators can't see the application
+	for _, c := range app.Collaborators {
+		if c.User == username {
+			if !userdb.HasAccess(c.Access, access) {
+				return nil, "", AccessDeniedError(fullname)
+			}
+			return br.NewUserBroker(owner, ctx), name, nil
+		}
+	}
+	return nil, "", ApplicationNotFoundError(fullname)
+}
+
+// IsSharedName returns true if the application name is a full name used by
+// collaborators.
+func IsSharedName(name string) bool {
+	return strings.Contains(name, "-")
+}
+
+// SplitFullName splits the full name of an application into the name and
+// namespace.
+func SplitFullName(fullname string) (name, namespace string, ok bool) {
+	i := strings.LastIndex(fullname, "-")
+	if i < 0 {
+		return "", "", false
+	}
+	return fullname[:i], fullname[i+1:], true
+}
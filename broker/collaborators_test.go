@@ -0,0 +1,86 @@
+package broker_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+)
+
+var _ = Describe("Collaborators", func() {
+	const OTHERUSER = "broker_test_other@example.com"
+
+	var (
+		owner = userdb.BasicUser{Name: TESTUSER, Namespace: NAMESPACE}
+		other = userdb.BasicUser{Name: OTHERUSER}
+		ctx   = context.Background()
+	)
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&owner, "test")).To(Succeed())
+		Expect(broker.CreateUser(&other, "test")).To(Succeed())
+
+		ub := broker.NewUserBroker(&owner, ctx)
+		_, _, err := ub.CreateApplication(container.CreateOptions{Name: "test"}, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(broker.RemoveUser(TESTUSER, true)).To(Succeed())
+		Expect(broker.RemoveUser(OTHERUSER, true)).To(Succeed())
+	})
+
+	It("should hide the application from other users", func() {
+		_, _, err := broker.SharedApplicationBroker(ctx, &other, "test-"+NAMESPACE, userdb.AccessRead)
+		Expect(err).To(Equal(br.ApplicationNotFoundError("test-" + NAMESPACE)))
+	})
+
+	It("should grant access to collaborators", func() {
+		ub := broker.NewUserBroker(&owner, ctx)
+		Expect(ub.AddCollaborator("test", OTHERUSER, userdb.AccessRead)).To(Succeed())
+		Expect(ub.GetCollaborators("test")).To(Equal([]userdb.Collaborator{{User: OTHERUSER, Access: userdb.AccessRead}}))
+
+		shared, name, err := broker.SharedApplicationBroker(ctx, &other, "test-"+NAMESPACE, userdb.AccessRead)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("test"))
+		Expect(shared.Namespace()).To(Equal(NAMESPACE))
+
+		_, _, err = broker.SharedApplicationBroker(ctx, &other, "test-"+NAMESPACE, userdb.AccessDeploy)
+		Expect(err).To(Equal(br.AccessDeniedError("test-" + NAMESPACE)))
+
+		Expect(ub.AddCollaborator("test", OTHERUSER, userdb.AccessDeploy)).To(Succeed())
+		_, _, err = broker.SharedApplicationBroker(ctx, &other, "test-"+NAMESPACE, userdb.AccessDeploy)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reserve operations to the owner", func() {
+		ub := broker.NewUserBroker(&owner, ctx)
+		Expect(ub.AddCollaborator("test", OTHERUSER, userdb.AccessDeploy)).To(Succeed())
+
+		_, _, err := broker.SharedApplicationBroker(ctx, &other, "test-"+NAMESPACE, "")
+		Expect(err).To(Equal(br.AccessDeniedError("test-" + NAMESPACE)))
+		_, _, err = broker.SharedApplicationBroker(ctx, &owner, "test-"+NAMESPACE, "")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should revoke access of removed collaborators", func() {
+		ub := broker.NewUserBroker(&owner, ctx)
+		Expect(ub.AddCollaborator("test", OTHERUSER, userdb.AccessRead)).To(Succeed())
+		Expect(ub.RemoveCollaborator("test", OTHERUSER)).To(Succeed())
+
+		_, _, err := broker.SharedApplicationBroker(ctx, &other, "test-"+NAMESPACE, userdb.AccessRead)
+		Expect(err).To(HaveOccurred())
+		Expect(ub.RemoveCollaborator("test", OTHERUSER)).To(HaveOccurred())
+	})
+
+	It("should not add invalid collaborators", func() {
+		ub := broker.NewUserBroker(&owner, ctx)
+		Expect(ub.AddCollaborator("test", TESTUSER, userdb.AccessRead)).To(Equal(br.InvalidCollaboratorError(TESTUSER)))
+		Expect(ub.AddCollaborator("test", OTHERUSER, "admin")).To(Equal(br.InvalidAccessError("admin")))
+		Expect(ub.AddCollaborator("test", "nobody@example.com", userdb.AccessRead)).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,168 @@
+package broker
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// A container is crash looping if it restarted the given number of times
+// in the given window, which are configured by "app.crashloop_restarts"
+// and "app.crashloop_window".
+const (
+	defaultCrashLoopRestarts = 5
+	defaultCrashLoopWindow   = 10 * time.Minute
+	crashLoopCheckInterval   = 15 * time.Second
+)
+
+type restartSample struct {
+	time  time.Time
+	count int
+}
+
+// crashDetector tracks restart counts of containers to detect crash loops.
+type crashDetector struct {
+	mu      sync.Mutex
+	samples map[string][]restartSample
+	looping map[string]time.Time
+}
+
+func newCrashDetector() *crashDetector {
+	return &crashDetector{
+		samples: make(map[string][]restartSample),
+		looping: make(map[string]time.Time),
+	}
+}
+
+// record adds a restart count sample of the container and returns true if
+// the container is crash looping.
+func (d *crashDetector) record(id string, count int, now time.Time, restarts int, window time.Duration) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.looping[id]; ok {
+		return false
+	}
+
+	samples := append(d.samples[id], restartSample{now, count})
+	for len(samples) > 1 && now.Sub(samples[0].time) > window {
+		samples = samples[1:]
+	}
+	d.samples[id] = samples
+
+	if count-samples[0].count >= restarts {
+		delete(d.samples, id)
+		d.looping[id] = now
+		return true
+	}
+	return false
+}
+
+// prune removes samples of containers that no longer exist.
+func (d *crashDetector) prune(seen map[string]bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for id := range d.samples {
+		if !seen[id] {
+			delete(d.samples, id)
+		}
+	}
+	for id := range d.looping {
+		if !seen[id] {
+			delete(d.looping, id)
+		}
+	}
+}
+
+// reset clears the crash loop state of the container, which is performed
+// when the container is started or destroyed explicitly.
+func (d *crashDetector) reset(id string) {
+	d.mu.Lock()
+	delete(d.samples, id)
+	delete(d.looping, id)
+	d.mu.Unlock()
+}
+
+func (d *crashDetector) isLooping(id string) bool {
+	d.mu.Lock()
+	_, ok := d.looping[id]
+	d.mu.Unlock()
+	return ok
+}
+
+// StartCrashLoopDetection checks restart counts of all containers
+// periodically until the context is canceled. Only the elected leader of
+// API servers performs the detection. Crash looping containers
+// are stopped to break the loop and a ContainerCrashLooping event is
+// published. The container remains stopped until started explicitly.
+func (br *Broker) StartCrashLoopDetection(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(crashLoopCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if br.elected("crashloop", 3*crashLoopCheckInterval) {
+					br.detectCrashLoops(ctx)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (br *Broker) detectCrashLoops(ctx context.Context) {
+	cs, err := br.FindInNamespace(ctx, "")
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to list containers for crash loop detection")
+		return
+	}
+
+	restarts := defaultCrashLoopRestarts
+	if n, err := strconv.Atoi(config.Get("app.crashloop_restarts")); err == nil && n > 0 {
+		restarts = n
+	}
+	window := defaultCrashLoopWindow
+	if d, err := time.ParseDuration(config.Get("app.crashloop_window")); err == nil && d > 0 {
+		window = d
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool, len(cs))
+	for _, c := range cs {
+		seen[c.ID()] = true
+		if br.crashes.record(c.ID(), c.RestartCount(), now, restarts, window) {
+			br.stopCrashLoop(ctx, c, restarts, window)
+		}
+	}
+	br.crashes.prune(seen)
+}
+
+func (br *Broker) stopCrashLoop(ctx context.Context, c container.Container, restarts int, window time.Duration) {
+	logrus.Errorf("Container %s of %s-%s restarted %d times in %s, stopped as crash looping",
+		c.ID(), c.Name(), c.Namespace(), restarts, window)
+
+	if err := c.Stop(ctx); err != nil {
+		logrus.WithError(err).Errorf("Failed to stop crash looping container %s", c.ID())
+	} else {
+		br.Events.Publish(Event{Type: ContainerStopped, Container: c})
+	}
+	br.Events.Publish(Event{Type: ContainerCrashLooping, Container: c})
+}
+
+// ActiveState returns the active state of the container. Containers stopped
+// by crash loop detection are reported as crash looping.
+func (br *Broker) ActiveState(ctx context.Context, c container.Container) manifest.ActiveState {
+	if br.crashes.isLooping(c.ID()) {
+		return manifest.StateCrashLooping
+	}
+	return c.ActiveState(ctx)
+}
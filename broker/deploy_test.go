@@ -6,11 +6,13 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
 	"github.com/cloudway/platform/container"
 	"github.com/cloudway/platform/pkg/archive"
 	"github.com/cloudway/platform/scm"
@@ -65,7 +67,7 @@ var _ = Describe("Deploy", func() {
 	AfterEach(func() {
 		br := broker.NewUserBroker(&user, context.Background())
 		br.RemoveApplication("test")
-		broker.RemoveUser(TESTUSER)
+		broker.RemoveUser(TESTUSER, true)
 		os.RemoveAll(tempdir)
 		os.RemoveAll(checkdir)
 	})
@@ -131,9 +133,9 @@ var _ = Describe("Deploy", func() {
 		}
 
 		var assertDeployment = func(branch, actual string) {
-			ExpectWithOffset(1, broker.Deploy("test", NAMESPACE, branch, nil)).To(Succeed())
+			ExpectWithOffset(1, broker.Deploy(context.Background(), "test", NAMESPACE, branch, nil)).To(Succeed())
 
-			ref, err := broker.SCM.GetDeploymentBranch(NAMESPACE, "test")
+			ref, err := broker.SCM().GetDeploymentBranch(NAMESPACE, "test")
 			ExpectWithOffset(1, err).NotTo(HaveOccurred())
 			ExpectWithOffset(1, ref.DisplayId).To(Equal(actual))
 
@@ -161,8 +163,12 @@ var _ = Describe("Deploy", func() {
 			Expect(repo.Run("push", "--mirror")).To(Succeed())
 
 			By("Ensure branches created in remote repository")
-			actualBranches, err := broker.SCM.GetDeploymentBranches(NAMESPACE, "test")
+			actualBranches, err := broker.SCM().GetDeploymentBranches(NAMESPACE, "test", scm.BranchOptions{})
 			Expect(err).NotTo(HaveOccurred())
+			for _, ref := range actualBranches {
+				Expect(ref.LatestCommit).NotTo(BeEmpty())
+				ref.LatestCommit, ref.LatestCommitTime = "", time.Time{}
+			}
 			Expect(actualBranches).To(ConsistOf(expectedBranches))
 
 			By("Deploy to given branch")
@@ -174,6 +180,66 @@ var _ = Describe("Deploy", func() {
 
 			By("Deploy to a non-existing branch will reset to default branch")
 			assertDeployment("no-such-branch", "master")
+
+			By("Deployment status should be set on the deployed commit")
+			commit, err := broker.SCM().(scm.StatusReporter).ResolveCommit(NAMESPACE, "test", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commit).NotTo(BeEmpty())
+
+			type statusGetter interface {
+				GetCommitStatus(namespace, name, commit, key string) (*scm.CommitStatus, error)
+			}
+			status, err := broker.SCM().(statusGetter).GetCommitStatus(NAMESPACE, "test", commit, br.DeployStatusKey)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status).NotTo(BeNil())
+			Expect(status.State).To(Equal(scm.StatusSuccess))
+		})
+
+		It("should record deployment history and roll back", func() {
+			By("Clone the application repository")
+			repodir := filepath.Join(REPOROOT, NAMESPACE, "test")
+			repo := mock.NewGitRepo(tempdir)
+			Expect(repo.Run("clone", repodir, tempdir)).To(Succeed())
+
+			By("Create develop branch and push to the remote repository")
+			createBranch(repo, "develop")
+			Expect(ioutil.WriteFile(testfile, []byte("master"), 0644)).To(Succeed())
+			Expect(repo.Run("add", "track")).To(Succeed())
+			Expect(repo.Commit("in master branch")).To(Succeed())
+			Expect(repo.Run("push", "--mirror")).To(Succeed())
+
+			By("Deploy master and develop branches")
+			ctx := br.WithActor(context.Background(), "deployer")
+			Expect(broker.Deploy(ctx, "test", NAMESPACE, "master", nil)).To(Succeed())
+			Expect(broker.Deploy(ctx, "test", NAMESPACE, "develop", nil)).To(Succeed())
+			Eventually(fetchCommittedFile, deployTimeout).Should(Equal("develop"))
+
+			ub := broker.NewUserBroker(&user, context.Background())
+			history, err := ub.GetDeployments("test")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(history)).To(BeNumerically(">=", 2))
+			Expect(history[0].Branch).To(Equal("develop"))
+			Expect(history[0].State).To(Equal(br.DeploymentSuccess))
+			Expect(history[0].DeployedBy).To(Equal("deployer"))
+			Expect(history[1].Branch).To(Equal("master"))
+			Expect(history[1].Artifact).NotTo(BeEmpty())
+
+			By("Roll back to the deployment of master branch")
+			d, err := ub.Rollback("test", history[1].ID, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(d.Source).To(Equal(br.DeploySourceRollback))
+			Expect(d.RollbackOf).To(Equal(history[1].ID))
+			Eventually(fetchCommittedFile, deployTimeout).Should(Equal("master"))
+
+			By("Rollback should be recorded in the history")
+			history, err = ub.GetDeployments("test")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(history[0].ID).To(Equal(d.ID))
+			Expect(history[0].State).To(Equal(br.DeploymentSuccess))
+
+			latest, err := broker.LatestDeployment("test", NAMESPACE)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(latest.ID).To(Equal(d.ID))
 		})
 	})
 
@@ -228,7 +294,7 @@ var _ = Describe("Deploy", func() {
 			Eventually(fetchCommittedFile, deployTimeout).Should(Equal("master"))
 
 			By("Switch deployment branch to develop")
-			Expect(broker.Deploy("test", NAMESPACE, "develop", nil))
+			Expect(broker.Deploy(context.Background(), "test", NAMESPACE, "develop", nil))
 			Eventually(fetchCommittedFile, deployTimeout).Should(Equal("develop"))
 
 			By("Switch local repository to develop branch")
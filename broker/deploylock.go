@@ -0,0 +1,179 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/pkg/timewindow"
+)
+
+// Deployments of an application can be locked, for example during an
+// incident, or restricted to deploy windows. The windows of an application
+// override the default windows configured by "app.deploy_windows", which
+// are separated by semicolons. Deployments are allowed at any time if no
+// windows are configured.
+
+// The DeploymentLockedError indicates that deployments of an application
+// are locked.
+type DeploymentLockedError struct {
+	Name   string
+	By     string
+	Reason string
+	Since  time.Time
+}
+
+func (e DeploymentLockedError) Error() string {
+	msg := fmt.Sprintf("Deployments of '%s' are locked by %s since %s", e.Name, e.By, e.Since.Format(time.RFC1123))
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+func (e DeploymentLockedError) HTTPErrorStatusCode() int {
+	return http.StatusLocked
+}
+
+// The OutsideDeployWindowError indicates that an application can't be
+// deployed at this time.
+type OutsideDeployWindowError struct {
+	Name    string
+	Windows []string
+}
+
+func (e OutsideDeployWindowError) Error() string {
+	return fmt.Sprintf("Deployments of '%s' are only allowed during: %s", e.Name, strings.Join(e.Windows, "; "))
+}
+
+func (e OutsideDeployWindowError) HTTPErrorStatusCode() int {
+	return http.StatusForbidden
+}
+
+type InvalidDeployWindowError struct {
+	Err error
+}
+
+func (e InvalidDeployWindowError) Error() string {
+	return e.Err.Error()
+}
+
+func (e InvalidDeployWindowError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// DefaultDeployWindows returns deploy windows configured for all
+// applications.
+func DefaultDeployWindows() []string {
+	var windows []string
+	for _, w := range strings.Split(config.Get("app.deploy_windows"), ";") {
+		if w = strings.TrimSpace(w); w != "" {
+			windows = append(windows, w)
+		}
+	}
+	return windows
+}
+
+// CheckDeployAllowed returns an error if the application can't be deployed
+// now because deployments are locked, a canary release is in progress, or
+// outside of the deploy windows.
+func CheckDeployAllowed(name string, app *userdb.Application, now time.Time) error {
+	if lock := app.DeployLock; lock != nil {
+		return DeploymentLockedError{Name: name, By: lock.By, Reason: lock.Reason, Since: lock.Since}
+	}
+	if app.Canary != nil {
+		return CanaryInProgressError{Name: name}
+	}
+
+	windows := app.DeployWindows
+	if len(windows) == 0 {
+		windows = DefaultDeployWindows()
+	}
+	if len(windows) == 0 {
+		return nil
+	}
+
+	parsed, err := timewindow.ParseList(windows)
+	if err != nil {
+		// don't block deployments with a bad configuration
+		return nil
+	}
+	if !timewindow.Any(parsed, now) {
+		return OutsideDeployWindowError{Name: name, Windows: windows}
+	}
+	return nil
+}
+
+// checkDeployAllowed checks whether the application in the namespace can
+// be deployed now.
+func (br *Broker) checkDeployAllowed(name, namespace string) error {
+	owner, err := br.Users.FindByNamespace(namespace)
+	if err != nil {
+		return err
+	}
+	app := owner.Basic().Applications[name]
+	if app == nil {
+		// the application is being created
+		return nil
+	}
+	return CheckDeployAllowed(name, app, time.Now())
+}
+
+// LockDeployments locks deployments of the application in the namespace.
+// The lock is replaced if already locked.
+func (br *Broker) LockDeployments(name, namespace, by, reason string) error {
+	lock := &userdb.DeployLock{By: by, Reason: reason, Since: time.Now()}
+	return br.modifyDeployLock(name, namespace, lock)
+}
+
+// UnlockDeployments unlocks deployments of the application in the
+// namespace.
+func (br *Broker) UnlockDeployments(name, namespace string) error {
+	return br.modifyDeployLock(name, namespace, nil)
+}
+
+func (br *Broker) modifyDeployLock(name, namespace string, lock *userdb.DeployLock) error {
+	owner, err := br.Users.FindByNamespace(namespace)
+	if err != nil {
+		if userdb.IsUserNotFound(err) {
+			err = ApplicationNotFoundError(name)
+		}
+		return err
+	}
+	if owner.Basic().Applications[name] == nil {
+		return ApplicationNotFoundError(name)
+	}
+	_, err = br.Users.ModifyApplication(owner.Basic().Name, name, func(app *userdb.Application) error {
+		app.DeployLock = lock
+		return nil
+	})
+	return err
+}
+
+// SetDeployWindows restricts deployments of the application to the given
+// windows. The default windows are used if the list is empty.
+func (br *UserBroker) SetDeployWindows(name string, windows []string) error {
+	if _, err := timewindow.ParseList(windows); err != nil {
+		return InvalidDeployWindowError{err}
+	}
+
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+	user := br.User.Basic()
+	if user.Applications[name] == nil {
+		return ApplicationNotFoundError(name)
+	}
+
+	app, err := br.Users.ModifyApplication(user.Name, name, func(app *userdb.Application) error {
+		app.DeployWindows = windows
+		return nil
+	})
+	if err == nil {
+		user.Applications[name] = app
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package broker
+
+import (
+	"errors"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/dns"
+)
+
+// DNS records of application aliases and domains delegated to namespaces
+// are managed by the DNS provider configured by "dns.url", and point to
+// the proxy given by "dns.target". Records are updated when applications,
+// aliases or domains change. Failures are logged but not returned, as the
+// platform works without the DNS integration, and the drift between the
+// zone and the user database can be reconciled later.
+
+var errNoDNSTarget = errors.New("DNS target not configured, set dns.target to the public address of the proxy")
+
+// dnsNames returns names of records required by the user, including
+// aliases of applications and the wildcard name of the delegated domain.
+func dnsNames(user *userdb.BasicUser) []string {
+	var names []string
+	for _, app := range user.Applications {
+		names = append(names, app.Hosts...)
+	}
+	return append(names, wildcardNames(user.Domain)...)
+}
+
+// updateDNS creates records of added names and removes records of removed
+// names in the zone of the DNS provider.
+func updateDNS(add, remove []string) {
+	if len(add) == 0 && len(remove) == 0 {
+		return
+	}
+
+	p, err := dns.FromConfig()
+	if err == dns.ErrMisconfigured {
+		return
+	}
+	if err == nil {
+		if target, ttl := dns.Target(); target == "" {
+			err = errNoDNSTarget
+		} else {
+			err = dns.Update(p, target, ttl, add, remove)
+		}
+	}
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to update DNS records")
+	}
+}
+
+// DNSDrift compares records required by all applications and namespaces
+// with records in the zone of the DNS provider. Records of the platform
+// domain, the console and the API server are never considered stale.
+func (br *Broker) DNSDrift(p dns.Provider) (*dns.Drift, error) {
+	target, ttl := dns.Target()
+	if target == "" {
+		return nil, errNoDNSTarget
+	}
+
+	var users []*userdb.BasicUser
+	if err := br.Users.Search(userdb.Args{}, &users); err != nil {
+		return nil, err
+	}
+
+	var desired []*dns.Record
+	seen := make(map[string]bool)
+	for _, user := range users {
+		for _, name := range dnsNames(user) {
+			if dns.InZone(name, p.Zone()) && !seen[name] {
+				seen[name] = true
+				desired = append(desired, dns.NewRecord(name, target, ttl))
+			}
+		}
+	}
+
+	actual, err := p.Records()
+	if err != nil {
+		return nil, err
+	}
+	return dns.Diff(desired, actual, target, platformHosts()), nil
+}
+
+func platformHosts() []string {
+	hosts := []string{defaults.Domain(), "*." + defaults.Domain()}
+	for _, host := range []string{defaults.ConsoleHost(), defaults.ApiHost()} {
+		if host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,161 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/pkg/errors"
+)
+
+// A namespace can be delegated with its own subdomain zone, such as
+// "team.example.com", applications in the namespace are then served at
+// "<name>.team.example.com" instead of "<name>-<namespace>" under the
+// platform domain. The wildcard DNS record of the zone must point to the
+// proxy. Delegations are managed by platform administrators.
+
+var domainPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+
+type InvalidDomainError struct {
+	Domain  string
+	Message string
+}
+
+func (e InvalidDomainError) Error() string {
+	return fmt.Sprintf("Invalid domain '%s': %s", e.Domain, e.Message)
+}
+
+func (e InvalidDomainError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// validateDomain checks that the domain doesn't conflict with hosts served
+// by the platform. Applications under the platform domain are served at
+// hosts with a dash in the first label, so delegated subdomains of the
+// platform domain must not contain a dash.
+func validateDomain(domain string) error {
+	if !domainPattern.MatchString(domain) || len(domain) > 253 {
+		return InvalidDomainError{domain, "not a valid domain name"}
+	}
+
+	platform := defaults.Domain()
+	if domain == platform || strings.HasSuffix(platform, "."+domain) {
+		return InvalidDomainError{domain, "the platform domain can't be delegated"}
+	}
+	if strings.HasSuffix(domain, "."+platform) {
+		label := strings.TrimSuffix(domain, "."+platform)
+		if i := strings.LastIndex(label, "."); i != -1 {
+			label = label[i+1:]
+		}
+		if strings.ContainsRune(label, '-') || IsReservedNamespace(label) {
+			return InvalidDomainError{domain, "conflicts with hosts under the platform domain"}
+		}
+	}
+	for _, host := range []string{defaults.ConsoleHost(), defaults.ApiHost()} {
+		if strings.HasSuffix(host, "."+domain) {
+			return InvalidDomainError{domain, "conflicts with the console or API host"}
+		}
+	}
+	return nil
+}
+
+// SetDomain delegates the domain to the namespace of the user, or removes
+// the delegation if the domain is empty. Containers are recreated to serve
+// applications under the new domain, and old application URLs are
+// redirected to new URLs for a grace period.
+func (br *UserBroker) SetDomain(domain string) error {
+	domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+	if domain != "" {
+		if err := validateDomain(domain); err != nil {
+			return err
+		}
+	}
+
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+	user := br.User.Basic()
+	if user.Namespace == "" {
+		return NoNamespaceError(user.Name)
+	}
+	oldDomain := user.Domain
+	if domain == oldDomain {
+		return nil
+	}
+
+	// no other operations can be performed on applications while the
+	// containers are recreated
+	for name := range user.Applications {
+		unlock, err := br.lockApp(name, user.Namespace, "domain")
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+	for name := range user.Services {
+		unlock, err := br.lockApp(name, user.Namespace, "domain")
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	if err := br.Users.SetDomain(user.Name, domain); err != nil {
+		return err
+	}
+	user.Domain = domain
+
+	var errs errors.Errors
+	redirects := make(map[string]string)
+	for name := range user.Applications {
+		if err := br.renameContainers(name, user.Namespace, name, user.Namespace, domain); err != nil {
+			logrus.WithError(err).Errorf("Failed to recreate containers of %s-%s", name, user.Namespace)
+			errs.Add(err)
+		}
+		redirects[appHost(name, user.Namespace, oldDomain)] = appURL(name, user.Namespace, domain)
+	}
+	for name := range user.Services {
+		if err := br.renameContainers(name, user.Namespace, name, user.Namespace, domain); err != nil {
+			logrus.WithError(err).Errorf("Failed to recreate containers of service %s-%s", name, user.Namespace)
+			errs.Add(err)
+		}
+	}
+
+	if err := addRedirects(redirects); err != nil {
+		logrus.WithError(err).Warn("Failed to add redirects for applications under the new domain")
+	}
+	updateDNS(wildcardNames(domain), wildcardNames(oldDomain))
+	return errs.Err()
+}
+
+// checkDelegatedHost rejects custom hosts under the domain delegated to
+// another namespace.
+func (br *UserBroker) checkDelegatedHost(host string) error {
+	for d := host; d != ""; {
+		if owner, err := br.Users.FindByDomain(d); err == nil && owner.Basic().Namespace != br.Namespace() {
+			return InvalidDomainError{host, "the domain is delegated to another namespace"}
+		} else if err != nil && !userdb.IsUserNotFound(err) {
+			return err
+		}
+		i := strings.IndexRune(d, '.')
+		if i == -1 {
+			break
+		}
+		d = d[i+1:]
+	}
+	return nil
+}
+
+// wildcardNames returns the wildcard name of the domain in a list, or an
+// empty list if the domain is empty.
+func wildcardNames(domain string) []string {
+	if domain == "" {
+		return nil
+	}
+	return []string{"*." + domain}
+}
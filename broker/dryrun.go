@@ -0,0 +1,207 @@
+package broker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/go-units"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/container"
+)
+
+// Dry-run requests are validated in the same way as actual operations, and
+// return actions that would be performed without changing anything. The
+// conditions that don't prevent operations, such as the disk quota and the
+// node capacity, are reported as warnings.
+
+// PlanCreateApplication validates options of a new application and returns
+// actions to create the application.
+func (br *UserBroker) PlanCreateApplication(opts container.CreateOptions, tags []string) (*types.Plan, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+
+	user := br.User.Basic()
+	if user.Applications[opts.Name] != nil {
+		return nil, ApplicationExistError{opts.Name, user.Namespace}
+	}
+	if err := checkApplicationName(opts.Name); err != nil {
+		return nil, err
+	}
+	if err := ValidateLabels(opts.Labels); err != nil {
+		return nil, err
+	}
+
+	namespace := user.Namespace
+	if namespace == "" {
+		if opts.Namespace == "" {
+			return nil, NoNamespaceError(user.Name)
+		}
+		namespace = opts.Namespace
+	}
+	if lock := br.ApplicationLock(opts.Name, namespace); lock != nil {
+		return nil, ApplicationBusyError{Name: opts.Name, Operation: lock.Operation}
+	}
+
+	// the tags are replaced with full plugin tags
+	tags = append([]string(nil), tags...)
+	names, plugins, _, err := br.checkPlugins(tags)
+	if err != nil {
+		return nil, err
+	}
+	if err = br.CheckSchedulable(); err != nil {
+		return nil, err
+	}
+
+	scaling := opts.Scaling
+	if scaling == 0 {
+		scaling = 1
+	}
+
+	plan := &types.Plan{}
+	if user.Namespace == "" {
+		plan.Actions = append(plan.Actions, &types.PlannedAction{
+			Action:      "create-namespace",
+			Description: "Create namespace " + namespace,
+		})
+	}
+
+	count := 0
+	for i, p := range plugins {
+		n := 1
+		if p.IsFramework() {
+			n = scaling
+		}
+		for j := 0; j < n; j++ {
+			plan.Actions = append(plan.Actions, &types.PlannedAction{
+				Action:      "create-container",
+				Service:     names[i],
+				Plugin:      p.Tag,
+				Description: "Create " + p.DisplayName + " container",
+			})
+		}
+		count += n
+	}
+
+	var populate string
+	switch {
+	case strings.ToLower(opts.Repo) == "empty":
+		populate = "Leave the repository empty"
+	case opts.Repo == "":
+		populate = "Populate the repository from the framework template"
+	default:
+		populate = "Populate the repository from " + opts.Repo
+	}
+	plan.Actions = append(plan.Actions,
+		&types.PlannedAction{Action: "create-repo", Description: "Create repository " + namespace + "/" + opts.Name},
+		&types.PlannedAction{Action: "populate-repo", Description: populate},
+		&types.PlannedAction{Action: "deploy", Description: "Deploy the application"},
+		&types.PlannedAction{Action: "start", Description: "Start containers"})
+
+	br.checkCapacity(plan, count)
+	return plan, nil
+}
+
+// PlanScaleApplication validates the scaling number of the application and
+// returns actions to scale the application.
+func (br *UserBroker) PlanScaleApplication(name string, num int) (*types.Plan, error) {
+	if num <= 0 || num > 10 {
+		return nil, ScalingError(num)
+	}
+
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+
+	user := br.User.Basic()
+	if user.Applications[name] == nil {
+		return nil, ApplicationNotFoundError(name)
+	}
+	if lock := br.ApplicationLock(name, user.Namespace); lock != nil {
+		return nil, ApplicationBusyError{Name: name, Operation: lock.Operation}
+	}
+
+	cs, err := br.FindApplications(br.ctx, name, user.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if len(cs) == 0 {
+		return nil, ApplicationNotFoundError(name)
+	}
+
+	plan := &types.Plan{}
+	if len(cs) > num {
+		for _, c := range cs[:len(cs)-num] {
+			plan.Actions = append(plan.Actions, &types.PlannedAction{
+				Action:      "remove-container",
+				Plugin:      c.PluginTag(),
+				Container:   c.ID(),
+				Description: "Remove container " + c.ID(),
+			})
+		}
+		return plan, nil
+	}
+	if len(cs) == num {
+		return plan, nil
+	}
+
+	if err = br.CheckSchedulable(); err != nil {
+		return nil, err
+	}
+	for i := len(cs); i < num; i++ {
+		plan.Actions = append(plan.Actions, &types.PlannedAction{
+			Action:      "create-container",
+			Plugin:      cs[0].PluginTag(),
+			Description: "Create container replicated from " + cs[0].ID(),
+		})
+	}
+	plan.Actions = append(plan.Actions, &types.PlannedAction{Action: "start", Description: "Start containers"})
+
+	br.checkCapacity(plan, num-len(cs))
+	br.checkScaleQuota(plan, name, user.Namespace, cs[0], num-len(cs))
+	return plan, nil
+}
+
+// checkCapacity warns if the container engine node has no room for new
+// containers.
+func (br *Broker) checkCapacity(plan *types.Plan, n int) {
+	m := br.nodes
+	m.mu.Lock()
+	headroom := m.status.Headroom
+	m.mu.Unlock()
+
+	if headroom >= 0 && n > headroom {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+			"%d containers will be created, but the node has room for %d containers", n, headroom))
+	}
+}
+
+// checkScaleQuota warns if new replicas of the container would exceed the
+// disk quota of the application, after which the application can no longer
+// be deployed or started.
+func (br *UserBroker) checkScaleQuota(plan *types.Plan, name, namespace string, replica container.Container, n int) {
+	quota := br.NamespaceDiskQuota(namespace)
+	if quota <= 0 {
+		return
+	}
+
+	cs, err := br.FindAll(br.ctx, name, namespace)
+	if err != nil {
+		return
+	}
+	usage, err := totalDiskUsage(br.ctx, cs)
+	if err != nil {
+		return
+	}
+	size, err := replica.DiskUsage(br.ctx)
+	if err != nil {
+		return
+	}
+
+	if usage += size * int64(n); usage > quota {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+			"The application would use about %s of disk space, which exceeds the quota of %s",
+			units.HumanSize(float64(usage)), units.HumanSize(float64(quota))))
+	}
+}
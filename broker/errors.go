@@ -3,6 +3,9 @@ package broker
 import (
 	"fmt"
 	"net/http"
+	"strings"
+
+	"github.com/docker/go-units"
 )
 
 type ApplicationNotFoundError string
@@ -15,6 +18,31 @@ func (e ApplicationNotFoundError) HTTPErrorStatusCode() int {
 	return http.StatusNotFound
 }
 
+type ApplicationProtectedError string
+
+func (e ApplicationProtectedError) Error() string {
+	return fmt.Sprintf("Application '%s' is protected, the operation must be confirmed with the application name", string(e))
+}
+
+func (e ApplicationProtectedError) HTTPErrorStatusCode() int {
+	return http.StatusPreconditionRequired
+}
+
+type ApplicationBusyError struct {
+	Name, Operation string
+}
+
+func (e ApplicationBusyError) Error() string {
+	if e.Operation == "" {
+		return fmt.Sprintf("Application '%s' is busy", e.Name)
+	}
+	return fmt.Sprintf("Application '%s' is busy, %s in progress", e.Name, e.Operation)
+}
+
+func (e ApplicationBusyError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
 type ApplicationExistError struct {
 	Name, Namespace string
 }
@@ -37,12 +65,263 @@ func (e NoNamespaceError) HTTPErrorStatusCode() int {
 	return http.StatusBadRequest
 }
 
-type NamespaceNotEmptyError string
+type NamespaceNotFoundError string
+
+func (e NamespaceNotFoundError) Error() string {
+	return fmt.Sprintf("Namespace not found: %s", string(e))
+}
+
+func (e NamespaceNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+// The NamespaceNotEmptyError indicates that a namespace cannot be removed
+// or changed because it contains applications.
+type NamespaceNotEmptyError struct {
+	Namespace    string
+	Applications []string
+}
 
 func (e NamespaceNotEmptyError) Error() string {
-	return fmt.Sprintf("Cannot remove namespace '%s' because it contains one or more applications", string(e))
+	return fmt.Sprintf("Cannot remove namespace '%s' because it contains applications: %s",
+		e.Namespace, strings.Join(e.Applications, ", "))
 }
 
 func (e NamespaceNotEmptyError) HTTPErrorStatusCode() int {
 	return http.StatusForbidden
 }
+
+type InvalidArchiveError string
+
+func (e InvalidArchiveError) Error() string {
+	return fmt.Sprintf("Invalid archive: %s", string(e))
+}
+
+func (e InvalidArchiveError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+type DiskQuotaExceededError struct {
+	Name         string
+	Usage, Quota int64
+}
+
+func (e DiskQuotaExceededError) Error() string {
+	return fmt.Sprintf("The application '%s' uses %s of disk space, which exceeds the quota of %s. Please remove unused files and try again.",
+		e.Name, units.HumanSize(float64(e.Usage)), units.HumanSize(float64(e.Quota)))
+}
+
+func (e DiskQuotaExceededError) HTTPErrorStatusCode() int {
+	return http.StatusInsufficientStorage
+}
+
+type ReadOnlyError string
+
+func (e ReadOnlyError) Error() string {
+	if e == "" {
+		return "The platform is in read-only mode, please try again later"
+	}
+	return fmt.Sprintf("The platform is in read-only mode: %s", string(e))
+}
+
+func (e ReadOnlyError) HTTPErrorStatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
+type NoSchedulableNodeError struct{}
+
+func (e NoSchedulableNodeError) Error() string {
+	return "No schedulable container engine node available, please try again later"
+}
+
+func (e NoSchedulableNodeError) HTTPErrorStatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
+type UpgradeInProgressError struct{}
+
+func (e UpgradeInProgressError) Error() string {
+	return "Another upgrade is in progress"
+}
+
+func (e UpgradeInProgressError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+type NoUpgradeInProgressError struct{}
+
+func (e NoUpgradeInProgressError) Error() string {
+	return "No upgrade in progress"
+}
+
+func (e NoUpgradeInProgressError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+type UpgradeAbortedError struct{}
+
+func (e UpgradeAbortedError) Error() string {
+	return "Upgrade aborted"
+}
+
+type UpgradeFailedError struct {
+	App, Container string
+	Err            error
+}
+
+func (e UpgradeFailedError) Error() string {
+	return fmt.Sprintf("Upgrade stopped, failed to upgrade container %.12s of %s: %v", e.Container, e.App, e.Err)
+}
+
+type UpgradeHealthError struct {
+	App, Container string
+	Err            error
+}
+
+func (e UpgradeHealthError) Error() string {
+	return fmt.Sprintf("Upgrade stopped, container %.12s of %s is not running: %v", e.Container, e.App, e.Err)
+}
+
+type AccountDeletionNotConfirmedError string
+
+func (e AccountDeletionNotConfirmedError) Error() string {
+	return fmt.Sprintf("Deleting account '%s' must be confirmed with the user name", string(e))
+}
+
+func (e AccountDeletionNotConfirmedError) HTTPErrorStatusCode() int {
+	return http.StatusPreconditionRequired
+}
+
+type MailNotConfiguredError struct{}
+
+func (e MailNotConfiguredError) Error() string {
+	return "Mail is not configured on this platform, please contact the administrator"
+}
+
+func (e MailNotConfiguredError) HTTPErrorStatusCode() int {
+	return http.StatusNotImplemented
+}
+
+type InvalidEmailError string
+
+func (e InvalidEmailError) Error() string {
+	return fmt.Sprintf("Invalid email address: %s", string(e))
+}
+
+func (e InvalidEmailError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+type RegistrationClosedError struct{}
+
+func (e RegistrationClosedError) Error() string {
+	return "Registration is by invitation only"
+}
+
+func (e RegistrationClosedError) HTTPErrorStatusCode() int {
+	return http.StatusForbidden
+}
+
+type InvalidAccessError string
+
+func (e InvalidAccessError) Error() string {
+	return fmt.Sprintf("Invalid access level: %s", string(e))
+}
+
+func (e InvalidAccessError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// The AccessDeniedError indicates that a collaborator does not have the
+// access required by an operation on the application.
+type AccessDeniedError string
+
+func (e AccessDeniedError) Error() string {
+	return fmt.Sprintf("You don't have permission to perform this operation on application '%s'", string(e))
+}
+
+func (e AccessDeniedError) HTTPErrorStatusCode() int {
+	return http.StatusForbidden
+}
+
+type InvalidCollaboratorError string
+
+func (e InvalidCollaboratorError) Error() string {
+	return fmt.Sprintf("The owner %s cannot be a collaborator of the application", string(e))
+}
+
+func (e InvalidCollaboratorError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// The ServiceLinkedError indicates that a service cannot be removed while
+// linked into other applications.
+type ServiceLinkedError struct {
+	App, Service string
+	LinkedBy     []string
+}
+
+func (e ServiceLinkedError) Error() string {
+	return fmt.Sprintf("The service '%s' of application '%s' is linked by %s, unlink it first",
+		e.Service, e.App, strings.Join(e.LinkedBy, ", "))
+}
+
+func (e ServiceLinkedError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+type ServiceNotLinkedError struct {
+	App, Service string
+}
+
+func (e ServiceNotLinkedError) Error() string {
+	return fmt.Sprintf("The service '%s' of application '%s' is not linked", e.Service, e.App)
+}
+
+func (e ServiceNotLinkedError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+type ServiceNotFoundError string
+
+func (e ServiceNotFoundError) Error() string {
+	return fmt.Sprintf("Service '%s' not found", string(e))
+}
+
+func (e ServiceNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+type ServiceExistError struct {
+	Name, Namespace string
+}
+
+func (e ServiceExistError) Error() string {
+	return fmt.Sprintf("The service '%s' already exists in the namespace '%s'", e.Name, e.Namespace)
+}
+
+func (e ServiceExistError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+// The InvalidTokenError indicates that a service account token or personal
+// access token is invalid or has been revoked.
+type InvalidTokenError struct{}
+
+func (e InvalidTokenError) Error() string {
+	return "Invalid or revoked access token"
+}
+
+func (e InvalidTokenError) HTTPErrorStatusCode() int {
+	return http.StatusUnauthorized
+}
+
+type UnsupportedProxyFeatureError ProxyFeature
+
+func (e UnsupportedProxyFeatureError) Error() string {
+	return fmt.Sprintf("The configured proxy does not support %s", ProxyFeature(e))
+}
+
+func (e UnsupportedProxyFeatureError) HTTPErrorStatusCode() int {
+	return http.StatusNotImplemented
+}
@@ -0,0 +1,175 @@
+package broker
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/container"
+)
+
+// EventType is the type of container or application lifecycle event.
+type EventType string
+
+const (
+	// ContainerStarted is published after a container started or restarted.
+	ContainerStarted EventType = "start"
+
+	// ContainerStopped is published after a container stopped.
+	ContainerStopped EventType = "stop"
+
+	// ContainerDestroyed is published after a container destroyed.
+	ContainerDestroyed EventType = "destroy"
+
+	// ContainerUpdated is published after the container configuration,
+	// such as custom hosts, changed.
+	ContainerUpdated EventType = "update"
+
+	// ContainerCrashLooping is published after a container restarted too
+	// many times and stopped by the broker.
+	ContainerCrashLooping EventType = "crashloop"
+
+	// ContainerRenamed is published after a container recreated with new
+	// application name or namespace.
+	ContainerRenamed EventType = "rename"
+
+	// ApplicationCreated is published after an application created.
+	ApplicationCreated EventType = "create"
+
+	// ApplicationRemoved is published after an application removed.
+	ApplicationRemoved EventType = "remove"
+
+	// ApplicationDeployed is published after an application deployed from
+	// source or a build artifact.
+	ApplicationDeployed EventType = "deploy"
+
+	// ApplicationReconciled is published after the drift between the
+	// desired state of an application and actual containers repaired.
+	ApplicationReconciled EventType = "reconcile"
+
+	// ServiceCreated is published after a standalone service created.
+	ServiceCreated EventType = "service-create"
+
+	// ServiceRemoved is published after a standalone service removed.
+	ServiceRemoved EventType = "service-remove"
+)
+
+// Event describes a container or application lifecycle event published by
+// the broker. The container is nil for application events. Events are
+// numbered by the event bus in the order they are published.
+type Event struct {
+	ID         uint64
+	Type       EventType
+	Container  container.Container
+	Name       string
+	Namespace  string
+	Attributes map[string]string
+	Time       time.Time
+}
+
+// eventBufferSize is the number of events buffered for each subscriber.
+// Events are dropped if a subscriber falls behind.
+const eventBufferSize = 256
+
+// eventHistorySize is the number of recent events kept for clients that
+// poll for events.
+const eventHistorySize = 1024
+
+// EventBus delivers lifecycle events to subscribers.
+type EventBus struct {
+	mu      sync.RWMutex
+	subs    map[chan Event]struct{}
+	seq     uint64
+	history []Event
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[chan Event]struct{})}
+}
+
+// Subscribe returns a channel that receives published events. The channel
+// is closed when Unsubscribe is called.
+func (bus *EventBus) Subscribe() <-chan Event {
+	ch := make(chan Event, eventBufferSize)
+	bus.mu.Lock()
+	bus.subs[ch] = struct{}{}
+	bus.mu.Unlock()
+	return ch
+}
+
+// Unsubscribe removes the subscription and closes the channel.
+func (bus *EventBus) Unsubscribe(sub <-chan Event) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	for ch := range bus.subs {
+		if ch == sub {
+			delete(bus.subs, ch)
+			close(ch)
+			break
+		}
+	}
+}
+
+// Publish sends the event to all subscribers without blocking.
+func (bus *EventBus) Publish(event Event) {
+	if bus == nil {
+		return
+	}
+
+	if c := event.Container; c != nil && event.Name == "" {
+		event.Name, event.Namespace = c.Name(), c.Namespace()
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	bus.seq++
+	event.ID = bus.seq
+	if len(bus.history) == eventHistorySize {
+		copy(bus.history, bus.history[1:])
+		bus.history = bus.history[:eventHistorySize-1]
+	}
+	bus.history = append(bus.history, event)
+
+	for ch := range bus.subs {
+		select {
+		case ch <- event:
+		default:
+			logrus.Warnf("Event subscriber is too slow, dropped %s event of %s-%s", event.Type, event.Name, event.Namespace)
+		}
+	}
+}
+
+// Since returns recent events published after the event with the given ID.
+// Older events may have been discarded.
+func (bus *EventBus) Since(id uint64) []Event {
+	bus.mu.RLock()
+	defer bus.mu.RUnlock()
+	i := sort.Search(len(bus.history), func(i int) bool { return bus.history[i].ID > id })
+	events := make([]Event, len(bus.history)-i)
+	copy(events, bus.history[i:])
+	return events
+}
+
+// notify publishes an event of the container if the operation succeeded.
+func (br *Broker) notify(typ EventType, c container.Container, err error) error {
+	if err == nil {
+		if typ == ContainerStarted || typ == ContainerDestroyed {
+			br.crashes.reset(c.ID())
+		}
+		if typ == ContainerStarted && c.Category().IsService() {
+			br.refreshLinks(c)
+		}
+		br.Events.Publish(Event{Type: typ, Container: c})
+	}
+	return err
+}
+
+// notifyApp publishes an event of the application.
+func (br *Broker) notifyApp(typ EventType, name, namespace string, attrs map[string]string) {
+	br.Events.Publish(Event{Type: typ, Name: name, Namespace: namespace, Attributes: attrs})
+}
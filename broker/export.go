@@ -0,0 +1,349 @@
+package broker
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/hub"
+	"github.com/cloudway/platform/pkg/archive"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// An exported application is a gzip'd tar bundle containing the metadata,
+// the repository and data of the application, which can be imported into
+// another Cloudway install to migrate the application between regions or
+// installs. Custom hosts, collaborators and links are specific to the
+// install, and are not exported.
+const (
+	exportFormatVersion = 1
+	exportMetaFile      = "application.json"
+	exportRepoFile      = "repo.tar"
+	exportDataFile      = "data.tar.gz"
+)
+
+// ExportedApplication is the metadata of an exported application.
+type ExportedApplication struct {
+	FormatVersion int
+	Name          string
+	ExportedAt    time.Time
+
+	// Plugin tags with versions, service plugins are in the form of
+	// "service=tag". Plugins defined in the namespace of the user are
+	// resolved in the namespace of the importing user.
+	Plugins []string
+
+	Secret  string
+	Scaling int
+	Env     map[string]string
+	Labels  map[string]string
+	HasData bool
+}
+
+// ExportApplication exports the application into a portable bundle. The
+// application must be running to dump the data.
+func (br *UserBroker) ExportApplication(name string) (io.ReadCloser, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	app := br.User.Basic().Applications[name]
+	if app == nil {
+		return nil, ApplicationNotFoundError(name)
+	}
+	containers, err := br.FindAll(br.ctx, name, br.Namespace())
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, ApplicationNotFoundError(name)
+	}
+
+	tempdir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempdir)
+
+	meta := ExportedApplication{
+		FormatVersion: exportFormatVersion,
+		Name:          name,
+		ExportedAt:    time.Now(),
+		Plugins:       br.exportPlugins(containers),
+		Secret:        app.Secret,
+		Scaling:       app.Scaling,
+		Env:           br.exportEnv(containers, app),
+		Labels:        app.Labels,
+		HasData:       true,
+	}
+
+	repo, err := br.Download(name)
+	if err != nil {
+		return nil, err
+	}
+	err = writeTrashFile(filepath.Join(tempdir, exportRepoFile), repo, false)
+	repo.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := br.Dump(name)
+	if err != nil {
+		return nil, err
+	}
+	err = writeTrashFile(filepath.Join(tempdir, exportDataFile), data, true)
+	data.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := json.MarshalIndent(&meta, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err = ioutil.WriteFile(filepath.Join(tempdir, exportMetaFile), metadata, 0600); err != nil {
+		return nil, err
+	}
+
+	// create the bundle with the metadata as the first entry
+	tempfile, err := ioutil.TempFile("", "export")
+	if err != nil {
+		return nil, err
+	}
+	zw, err := gzip.NewWriterLevel(tempfile, defaults.GzipLevel())
+	if err == nil {
+		tw := tar.NewWriter(zw)
+		for _, file := range []string{exportMetaFile, exportRepoFile, exportDataFile} {
+			if err = archive.CopyFile(tw, filepath.Join(tempdir, file), file, 0600); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		if err == nil {
+			err = zw.Close()
+		}
+	}
+	if err != nil {
+		tempfile.Close()
+		os.Remove(tempfile.Name())
+		return nil, err
+	}
+
+	tempfile.Seek(0, os.SEEK_SET)
+	return deleteReadCloser{tempfile}, nil
+}
+
+// exportPlugins returns plugin tags of the application containers. The
+// namespace of user defined plugins is removed, so the plugins are resolved
+// in the namespace of the importing user.
+func (br *UserBroker) exportPlugins(containers []container.Container) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, c := range containers {
+		tag := c.PluginTag()
+		if _, namespace, name, version, err := hub.ParseTag(tag); err == nil && namespace == br.Namespace() {
+			tag = name
+			if version != "" {
+				tag += ":" + version
+			}
+		}
+		if c.Category().IsService() {
+			tag = c.ServiceName() + "=" + tag
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// exportEnv returns environment variables of the application, which are
+// the variables recorded in the application merged with variables set in
+// the framework container. Variables prefixed with "CLOUDWAY_" are managed
+// by the platform and are not exported.
+func (br *UserBroker) exportEnv(containers []container.Container, app *userdb.Application) map[string]string {
+	env := make(map[string]string)
+	for k, v := range app.Env {
+		env[k] = v
+	}
+	for _, c := range containers {
+		if !c.Category().IsFramework() {
+			continue
+		}
+		if info, err := c.GetInfo(br.ctx, "env"); err == nil {
+			for k, v := range info.Env {
+				if !strings.HasPrefix(k, "CLOUDWAY_") {
+					env[k] = v
+				}
+			}
+		}
+		break
+	}
+	return env
+}
+
+// ImportApplication recreates an application from the bundle created by
+// ExportApplication. The application is named by the bundle, or the given
+// name if not empty. The bundle is extracted and validated before the
+// application is created. Returns the name of the imported application.
+func (br *UserBroker) ImportApplication(source io.Reader, name string, log *serverlog.ServerLog) (string, *userdb.Application, error) {
+	tempdir, err := ioutil.TempDir("", "import")
+	if err != nil {
+		return "", nil, err
+	}
+	defer os.RemoveAll(tempdir)
+
+	fmt.Fprintln(log, "Validating application bundle")
+	meta, err := extractBundle(tempdir, source)
+	if err != nil {
+		return "", nil, err
+	}
+	if name == "" {
+		name = meta.Name
+	}
+	if err = checkApplicationName(name); err != nil {
+		return "", nil, err
+	}
+
+	opts := container.CreateOptions{
+		Name:    name,
+		Secret:  meta.Secret,
+		Scaling: meta.Scaling,
+		Env:     meta.Env,
+		Labels:  meta.Labels,
+		Log:     log,
+	}
+
+	populate := func(opts *container.CreateOptions, _ *manifest.Plugin) error {
+		f, err := os.Open(filepath.Join(tempdir, exportRepoFile))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		return br.SCM().Populate(opts.Namespace, opts.Name, f, fi.Size())
+	}
+
+	app, containers, err := br.createApplication(opts, meta.Plugins, populate)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// data can only be restored to running containers
+	if err = br.StartContainers(containers, log); err != nil {
+		return "", nil, err
+	}
+
+	if meta.HasData {
+		log.Progress("import", 1, 1, "Restoring application data")
+		var f *os.File
+		if f, err = os.Open(filepath.Join(tempdir, exportDataFile)); err == nil {
+			err = br.Restore(name, f, log)
+			f.Close()
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return name, app, nil
+}
+
+// extractBundle extracts the application bundle into the directory, and
+// returns the metadata. The bundle must only contain the metadata, the
+// repository and data archives, and each archive must be complete.
+func extractBundle(dir string, source io.Reader) (*ExportedApplication, error) {
+	zr, err := gzip.NewReader(source)
+	if err != nil {
+		return nil, InvalidArchiveError(err.Error())
+	}
+
+	var meta *ExportedApplication
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, InvalidArchiveError(err.Error())
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			return nil, InvalidArchiveError(fmt.Sprintf("unexpected entry %s in application bundle", hdr.Name))
+		}
+
+		switch name := strings.TrimPrefix(hdr.Name, "./"); name {
+		case exportMetaFile:
+			meta = new(ExportedApplication)
+			if err = json.NewDecoder(tr).Decode(meta); err != nil {
+				return nil, InvalidArchiveError(fmt.Sprintf("%s: %v", name, err))
+			}
+		case exportRepoFile:
+			err = extractSnapshot(filepath.Join(dir, name), tr)
+		case exportDataFile:
+			err = extractCompressed(filepath.Join(dir, name), tr)
+		default:
+			return nil, InvalidArchiveError(fmt.Sprintf("unexpected entry %s in application bundle", hdr.Name))
+		}
+		if err != nil {
+			return nil, InvalidArchiveError(fmt.Sprintf("%s: %v", hdr.Name, err))
+		}
+	}
+
+	// read to the end of the gzip stream to verify the checksum
+	if _, err = io.Copy(ioutil.Discard, zr); err != nil {
+		return nil, InvalidArchiveError(err.Error())
+	}
+
+	if meta == nil {
+		return nil, InvalidArchiveError(exportMetaFile + " not found in application bundle")
+	}
+	if meta.FormatVersion < 1 || meta.FormatVersion > exportFormatVersion {
+		return nil, InvalidArchiveError(fmt.Sprintf("unsupported bundle format version %d", meta.FormatVersion))
+	}
+	if len(meta.Plugins) == 0 {
+		return nil, InvalidArchiveError("no plugins in application bundle")
+	}
+	if _, err = os.Stat(filepath.Join(dir, exportRepoFile)); err != nil {
+		return nil, InvalidArchiveError(exportRepoFile + " not found in application bundle")
+	}
+	if _, err = os.Stat(filepath.Join(dir, exportDataFile)); err != nil {
+		meta.HasData = false
+	}
+	return meta, nil
+}
+
+// extractCompressed writes the gzip'd content to the file, and verifies
+// that the content is complete.
+func extractCompressed(filename string, r io.Reader) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(io.TeeReader(r, f))
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(ioutil.Discard, zr); err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	return err
+}
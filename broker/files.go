@@ -0,0 +1,234 @@
+package broker
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/archive"
+)
+
+// DownloadFiles returns a tar archive that contains the file or directory
+// at the given path in the application or service container. Relative
+// paths are resolved against the home directory of the container. Files
+// are read with the permission of the container user.
+func (br *UserBroker) DownloadFiles(name, service, filepath string, filter *archive.Filter) (io.ReadCloser, error) {
+	cs, err := br.findContainers(name, service)
+	if err != nil {
+		return nil, err
+	}
+
+	c := cs[0]
+	filepath = resolvePath(c, filepath)
+	dir, base := path.Split(filepath)
+	if base == "" {
+		dir, base = filepath, "."
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		opts := container.ExecOptions{User: c.User(), Stdout: w}
+		w.CloseWithError(c.ExecWithOptions(br.ctx, opts, "tar", "-c", "-C", dir, base))
+	}()
+
+	if filter.IsEmpty() {
+		return r, nil
+	}
+	return &filteredReader{archive.FilterFiles(r, filter), r}, nil
+}
+
+// UploadFiles extracts the tar archive into the given path in all
+// application containers or the service container. Relative paths are
+// resolved against the home directory of the container. Files are written
+// with the permission of the container user.
+func (br *UserBroker) UploadFiles(name, service, filepath string, content io.Reader, filter *archive.Filter) error {
+	cs, err := br.findContainers(name, service)
+	if err != nil {
+		return err
+	}
+
+	// save the archive to a temporary file so it can be sent to
+	// multiple containers
+	f, err := ioutil.TempFile("", "upload")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	if !filter.IsEmpty() {
+		content = archive.FilterFiles(content, filter)
+	}
+	if err = checkArchive(io.TeeReader(content, f)); err != nil {
+		return err
+	}
+
+	return Parallel(cs, func(c container.Container) error {
+		r, err := os.Open(f.Name())
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		dir := resolvePath(c, filepath)
+		opts := container.ExecOptions{User: c.User()}
+		if err := c.ExecWithOptions(br.ctx, opts, "mkdir", "-p", dir); err != nil {
+			return err
+		}
+		opts.Stdin = r
+		return c.ExecWithOptions(br.ctx, opts, "tar", "-x", "--no-same-owner", "-C", dir)
+	})
+}
+
+// FileInfo describes a file in a container.
+type FileInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+type byDirName []*FileInfo
+
+func (a byDirName) Len() int      { return len(a) }
+func (a byDirName) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byDirName) Less(i, j int) bool {
+	if a[i].IsDir != a[j].IsDir {
+		return a[i].IsDir
+	}
+	return a[i].Name < a[j].Name
+}
+
+const (
+	listFilesTimeout = 30 * time.Second
+	maxListOutput    = 1024 * 1024
+)
+
+// ListFiles returns files in the directory of the application or service
+// container, directories first. Relative paths are resolved against the
+// home directory of the container.
+func (br *UserBroker) ListFiles(name, service, dir string) ([]*FileInfo, error) {
+	cs, err := br.findContainers(name, service)
+	if err != nil {
+		return nil, err
+	}
+
+	c := cs[0]
+	var out bytes.Buffer
+	opts := container.ExecOptions{
+		User:      c.User(),
+		Stdout:    &out,
+		Timeout:   listFilesTimeout,
+		MaxOutput: maxListOutput,
+	}
+	err = c.ExecWithOptions(br.ctx, opts, "find", resolvePath(c, dir),
+		"-mindepth", "1", "-maxdepth", "1", "-printf", "%y\t%s\t%T@\t%f\n")
+	if err != nil {
+		return nil, err
+	}
+
+	files := []*FileInfo{}
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[1], 10, 64)
+		mtime, _ := strconv.ParseFloat(fields[2], 64)
+		files = append(files, &FileInfo{
+			Name:    fields[3],
+			Size:    size,
+			ModTime: time.Unix(int64(mtime), 0),
+			IsDir:   fields[0] == "d",
+		})
+	}
+
+	sort.Sort(byDirName(files))
+	return files, nil
+}
+
+func (br *UserBroker) findContainers(name, service string) (cs []container.Container, err error) {
+	if service == "" || service == "_" {
+		cs, err = br.FindApplications(br.ctx, name, br.Namespace())
+		if err == nil && len(cs) == 0 {
+			err = ApplicationNotFoundError(name)
+		}
+	} else {
+		cs, err = br.FindService(br.ctx, name, br.Namespace(), service)
+		if err == nil && len(cs) == 0 {
+			err = fmt.Errorf("Service '%s' not found in application '%s'", service, name)
+		}
+	}
+	return cs, err
+}
+
+func resolvePath(c container.Container, p string) string {
+	if !path.IsAbs(p) {
+		p = path.Join(c.Home(), p)
+	}
+	return path.Clean(p)
+}
+
+// checkArchive reads through the tar archive and makes sure that all
+// entries, including link targets, are extracted below the target directory.
+func checkArchive(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return InvalidArchiveError(err.Error())
+		}
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return InvalidArchiveError(hdr.Name)
+		}
+		if hdr.Typeflag == tar.TypeSymlink || hdr.Typeflag == tar.TypeLink {
+			if !isLocalLink(hdr.Linkname) {
+				return InvalidArchiveError(hdr.Name + " -> " + hdr.Linkname)
+			}
+		}
+	}
+	// consume the trailing padding
+	_, err := io.Copy(ioutil.Discard, r)
+	return err
+}
+
+// isLocalLink returns true if the link target can't escape from the target
+// directory, so later entries can't be written through the link.
+func isLocalLink(linkname string) bool {
+	if linkname == "" || path.IsAbs(linkname) {
+		return false
+	}
+	for _, elem := range strings.Split(linkname, "/") {
+		if elem == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+type filteredReader struct {
+	io.ReadCloser
+	source io.Closer
+}
+
+func (r *filteredReader) Close() error {
+	r.source.Close()
+	return r.ReadCloser.Close()
+}
@@ -0,0 +1,113 @@
+package broker_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+)
+
+var _ = Describe("Files", func() {
+	var user = userdb.BasicUser{
+		Name:      TESTUSER,
+		Namespace: NAMESPACE,
+	}
+	var ub *br.UserBroker
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		ub = broker.NewUserBroker(&user, context.Background())
+		_, _, err := ub.CreateApplication(container.CreateOptions{Name: "test"}, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(ub.RemoveApplication("test")).To(Succeed())
+		Expect(broker.RemoveUser(TESTUSER, true)).To(Succeed())
+	})
+
+	var makeArchive = func(headers ...*tar.Header) *bytes.Buffer {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, hdr := range headers {
+			ExpectWithOffset(1, tw.WriteHeader(hdr)).To(Succeed())
+			if hdr.Size != 0 {
+				_, err := tw.Write(bytes.Repeat([]byte{'x'}, int(hdr.Size)))
+				ExpectWithOffset(1, err).NotTo(HaveOccurred())
+			}
+		}
+		ExpectWithOffset(1, tw.Close()).To(Succeed())
+		return &buf
+	}
+
+	It("should list uploaded files with directories first", func() {
+		Expect(ub.UploadFiles("test", "", "data", makeArchive(
+			&tar.Header{Name: "a.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 5},
+			&tar.Header{Name: "z/", Typeflag: tar.TypeDir, Mode: 0755},
+			&tar.Header{Name: "z/b.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 3},
+		), nil)).To(Succeed())
+
+		files, err := ub.ListFiles("test", "", "data")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(2))
+		Expect(files[0].Name).To(Equal("z"))
+		Expect(files[0].IsDir).To(BeTrue())
+		Expect(files[1].Name).To(Equal("a.txt"))
+		Expect(files[1].IsDir).To(BeFalse())
+		Expect(files[1].Size).To(Equal(int64(5)))
+
+		files, err = ub.ListFiles("test", "_", "data/z")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		Expect(files[0].Name).To(Equal("b.txt"))
+	})
+
+	It("should list empty directory", func() {
+		Expect(ub.UploadFiles("test", "", "data", makeArchive(
+			&tar.Header{Name: "empty/", Typeflag: tar.TypeDir, Mode: 0755},
+		), nil)).To(Succeed())
+
+		files, err := ub.ListFiles("test", "", "data/empty")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(BeEmpty())
+	})
+
+	It("should fail to list files of unknown service", func() {
+		_, err := ub.ListFiles("test", "nosuchservice", "data")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject entries outside of the target directory", func() {
+		for _, hdr := range []*tar.Header{
+			{Name: "../file", Typeflag: tar.TypeReg, Mode: 0644},
+			{Name: "/etc/file", Typeflag: tar.TypeReg, Mode: 0644},
+			{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+			{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "../.."},
+			{Name: "dir/link", Typeflag: tar.TypeSymlink, Linkname: "sub/../../.."},
+			{Name: "link", Typeflag: tar.TypeLink, Linkname: "../file"},
+		} {
+			err := ub.UploadFiles("test", "", "data", makeArchive(hdr), nil)
+			Expect(err).To(BeAssignableToTypeOf(br.InvalidArchiveError("")), hdr.Name)
+		}
+	})
+
+	It("should accept links below the target directory", func() {
+		Expect(ub.UploadFiles("test", "", "data", makeArchive(
+			&tar.Header{Name: "file", Typeflag: tar.TypeReg, Mode: 0644, Size: 1},
+			&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "file"},
+		), nil)).To(Succeed())
+	})
+
+	It("should report disk usage of containers", func() {
+		usage, err := ub.DiskUsage("test")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(usage).To(HaveLen(1))
+		Expect(usage[0].Service).To(BeEmpty())
+	})
+})
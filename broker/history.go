@@ -0,0 +1,396 @@
+package broker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/pkg/serverlog"
+	"github.com/cloudway/platform/scm"
+)
+
+// The deployment history records every deployment of an application, from
+// source, uploaded repositories or build artifacts, together with the
+// output of the deployment. Records are kept in the "var/deployments"
+// directory per application, which must be shared by all API servers, and
+// the latest "deployment.history_size" records are kept.
+//
+// A successful deployment references the build artifact that was deployed
+// or archived, so the application can be rolled back to the deployment as
+// long as the artifact is retained.
+const (
+	deploymentMetaExt  = ".json"
+	deploymentLogExt   = ".log"
+	defaultHistorySize = 50
+	deploymentLogLimit = 1 << 20
+)
+
+// Deployment sources.
+const (
+	DeploySourceBranch   = "branch"   // deployed from a branch of the repository
+	DeploySourceUpload   = "upload"   // uploaded repository or binary
+	DeploySourceArtifact = "artifact" // build artifact deployed by CI jobs
+	DeploySourceRollback = "rollback" // rolled back to a previous deployment
+)
+
+// Deployment states.
+const (
+	DeploymentRunning = "running"
+	DeploymentSuccess = "success"
+	DeploymentFailed  = "failed"
+)
+
+var deploymentIDPattern = regexp.MustCompile(`^[0-9]{14}-[0-9a-f]{8}$`)
+
+// Deployment is a record in the deployment history of an application.
+type Deployment struct {
+	ID         string
+	Name       string
+	Namespace  string
+	Source     string
+	Branch     string
+	Commit     string
+	Version    string
+	Artifact   string
+	RollbackOf string
+	DeployedBy string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	State      string
+	Error      string
+}
+
+// Duration returns the time taken by the deployment, or zero if the
+// deployment is still running.
+func (d *Deployment) Duration() time.Duration {
+	if d.FinishedAt.IsZero() {
+		return 0
+	}
+	return d.FinishedAt.Sub(d.StartedAt)
+}
+
+type DeploymentNotFoundError string
+
+func (e DeploymentNotFoundError) Error() string {
+	return fmt.Sprintf("Deployment '%s' not found", string(e))
+}
+
+func (e DeploymentNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+// The RollbackError indicates that the application can't be rolled back
+// to a deployment, because the deployment failed or the build artifact of
+// the deployment has been removed.
+type RollbackError struct {
+	ID     string
+	Reason string
+}
+
+func (e RollbackError) Error() string {
+	return fmt.Sprintf("Can't roll back to deployment '%s': %s", e.ID, e.Reason)
+}
+
+func (e RollbackError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+type actorKey struct{}
+
+// WithActor returns a context that carries the name of the user or service
+// account performing the operation, which is recorded as the deployer in
+// the deployment history.
+func WithActor(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, actorKey{}, name)
+}
+
+func actorFromContext(ctx context.Context, fallback string) string {
+	if ctx != nil {
+		if name, ok := ctx.Value(actorKey{}).(string); ok && name != "" {
+			return name
+		}
+	}
+	return fallback
+}
+
+func deploymentRoot() string {
+	return filepath.Join(config.RootDir, "var", "deployments")
+}
+
+func deploymentDir(name, namespace string) string {
+	return filepath.Join(deploymentRoot(), namespace, name)
+}
+
+func deploymentHistorySize() int {
+	if n, err := strconv.Atoi(config.Get("deployment.history_size")); err == nil && n > 0 {
+		return n
+	}
+	return defaultHistorySize
+}
+
+// GetDeployments returns the deployment history of the application, the
+// latest first.
+func (br *UserBroker) GetDeployments(name string) ([]*Deployment, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	if br.User.Basic().Applications[name] == nil {
+		return nil, ApplicationNotFoundError(name)
+	}
+	return readDeployments(name, br.Namespace())
+}
+
+// LatestDeployment returns the latest deployment of the application, or
+// nil if the application has never been deployed.
+func (br *Broker) LatestDeployment(name, namespace string) (*Deployment, error) {
+	history, err := readDeployments(name, namespace)
+	if err != nil || len(history) == 0 {
+		return nil, err
+	}
+	return history[0], nil
+}
+
+// deploymentBranchTimeout bounds the time waiting for the SCM when the
+// current deployment branch is only informative.
+const deploymentBranchTimeout = 5 * time.Second
+
+// CurrentDeploymentBranch returns the current deployment branch of the
+// application, or an error if the SCM doesn't respond in a short time.
+func (br *Broker) CurrentDeploymentBranch(name, namespace string) (*scm.Branch, error) {
+	type result struct {
+		branch *scm.Branch
+		err    error
+	}
+
+	s := br.SCM()
+	ch := make(chan result, 1)
+	go func() {
+		branch, err := s.GetDeploymentBranch(namespace, name)
+		ch <- result{branch, err}
+	}()
+
+	timer := time.NewTimer(deploymentBranchTimeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-ch:
+		return r.branch, r.err
+	case <-timer.C:
+		return nil, fmt.Errorf("Timed out getting deployment branch of %s-%s", name, namespace)
+	}
+}
+
+// GetDeploymentLog returns the output of a deployment.
+func (br *UserBroker) GetDeploymentLog(name, id string) (io.ReadCloser, error) {
+	if _, err := readDeployment(name, br.Namespace(), id); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(deploymentDir(name, br.Namespace()), id+deploymentLogExt))
+	if os.IsNotExist(err) {
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	}
+	return f, err
+}
+
+// Rollback deploys the build artifact of a previous successful deployment
+// again, and returns the new deployment record.
+func (br *UserBroker) Rollback(name, id string, log *serverlog.ServerLog) (*Deployment, error) {
+	prev, err := readDeployment(name, br.Namespace(), id)
+	if err != nil {
+		return nil, err
+	}
+	if prev.State != DeploymentSuccess {
+		return nil, RollbackError{ID: id, Reason: "the deployment was not successful"}
+	}
+	if prev.Artifact == "" {
+		return nil, RollbackError{ID: id, Reason: "no build artifact was saved for the deployment"}
+	}
+	if _, err = readArtifact(name, br.Namespace(), prev.Artifact); err != nil {
+		if _, ok := err.(ArtifactNotFoundError); ok {
+			err = RollbackError{ID: id, Reason: "the build artifact has been removed"}
+		}
+		return nil, err
+	}
+
+	d := &Deployment{
+		Source:     DeploySourceRollback,
+		Branch:     prev.Branch,
+		RollbackOf: prev.ID,
+	}
+	_, err = br.redeployArtifact(name, prev.Artifact, d, log)
+	return d, err
+}
+
+// startDeployment creates a running record in the deployment history. The
+// returned server log copies the output of deployment into the deployment
+// log, and the finish function records the result of deployment. Failures
+// are logged but not returned, as the history must not fail deployments.
+func (br *Broker) startDeployment(ctx context.Context, d *Deployment, log *serverlog.ServerLog) (*serverlog.ServerLog, func(error)) {
+	b := make([]byte, 4)
+	rand.Read(b)
+	d.StartedAt = time.Now().UTC()
+	d.ID = d.StartedAt.Format("20060102150405") + "-" + hex.EncodeToString(b)
+	d.State = DeploymentRunning
+	d.DeployedBy = actorFromContext(ctx, d.DeployedBy)
+
+	var logfile *os.File
+	dir := deploymentDir(d.Name, d.Namespace)
+	err := os.MkdirAll(dir, 0700)
+	if err == nil {
+		err = writeDeployment(d)
+	}
+	if err == nil {
+		logfile, err = os.OpenFile(filepath.Join(dir, d.ID+deploymentLogExt), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	}
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to record deployment of %s-%s", d.Name, d.Namespace)
+	} else {
+		log = log.Tee(&limitedWriter{w: logfile, n: deploymentLogLimit})
+	}
+
+	finish := func(err error) {
+		if logfile != nil {
+			logfile.Close()
+		}
+		d.FinishedAt = time.Now().UTC()
+		if err != nil {
+			d.State, d.Error = DeploymentFailed, err.Error()
+		} else {
+			d.State = DeploymentSuccess
+		}
+		if err = writeDeployment(d); err != nil {
+			logrus.WithError(err).Warnf("Failed to record deployment of %s-%s", d.Name, d.Namespace)
+		}
+		pruneDeployments(d.Name, d.Namespace)
+	}
+	return log, finish
+}
+
+// limitedWriter discards bytes written after the limit is reached, without
+// failing the writer it's paired with. Standard output and standard error
+// are written concurrently.
+type limitedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+	n  int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.n > 0 {
+		b := p
+		if int64(len(b)) > l.n {
+			b = b[:l.n]
+		}
+		n, _ := l.w.Write(b)
+		l.n -= int64(n)
+	}
+	return len(p), nil
+}
+
+func writeDeployment(d *Deployment) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(deploymentDir(d.Name, d.Namespace), d.ID+deploymentMetaExt), data, 0600)
+}
+
+// readDeployment reads a deployment record. The application name and
+// namespace are taken from the store location, as the application may be
+// renamed after the deployment.
+func readDeployment(name, namespace, id string) (*Deployment, error) {
+	if !deploymentIDPattern.MatchString(id) {
+		return nil, DeploymentNotFoundError(id)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(deploymentDir(name, namespace), id+deploymentMetaExt))
+	if os.IsNotExist(err) {
+		return nil, DeploymentNotFoundError(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d := new(Deployment)
+	if err = json.Unmarshal(data, d); err != nil {
+		return nil, err
+	}
+	d.Name, d.Namespace = name, namespace
+	return d, nil
+}
+
+// readDeployments returns the deployment history of the application, the
+// latest first.
+func readDeployments(name, namespace string) ([]*Deployment, error) {
+	files, err := ioutil.ReadDir(deploymentDir(name, namespace))
+	if os.IsNotExist(err) {
+		return []*Deployment{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*Deployment{}
+	for _, fi := range files {
+		id := strings.TrimSuffix(fi.Name(), deploymentMetaExt)
+		if id != fi.Name() && deploymentIDPattern.MatchString(id) {
+			if d, err := readDeployment(name, namespace, id); err == nil {
+				result = append(result, d)
+			}
+		}
+	}
+	sort.Sort(deploymentsByTime(result))
+	return result, nil
+}
+
+type deploymentsByTime []*Deployment
+
+func (a deploymentsByTime) Len() int           { return len(a) }
+func (a deploymentsByTime) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a deploymentsByTime) Less(i, j int) bool { return a[i].StartedAt.After(a[j].StartedAt) }
+
+// pruneDeployments removes the oldest records out of the history size.
+func pruneDeployments(name, namespace string) {
+	history, err := readDeployments(name, namespace)
+	size := deploymentHistorySize()
+	if err != nil || len(history) <= size {
+		return
+	}
+	dir := deploymentDir(name, namespace)
+	for _, d := range history[size:] {
+		os.Remove(filepath.Join(dir, d.ID+deploymentMetaExt))
+		os.Remove(filepath.Join(dir, d.ID+deploymentLogExt))
+	}
+}
+
+func renameDeployments(name, namespace, newName, newNamespace string) error {
+	src, dst := deploymentDir(name, namespace), deploymentDir(newName, newNamespace)
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+func removeDeployments(name, namespace string) error {
+	return os.RemoveAll(deploymentDir(name, namespace))
+}
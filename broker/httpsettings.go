@@ -0,0 +1,115 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+type InvalidHTTPSettingsError struct {
+	Message string
+}
+
+func (e InvalidHTTPSettingsError) Error() string {
+	return e.Message
+}
+
+func (e InvalidHTTPSettingsError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// The maximum idle timeout of proxied connections in seconds.
+const maxIdleTimeout = 24 * 3600
+
+// SetHTTPSettings sets the HTTPS redirect, HSTS, custom error pages and
+// connection settings of the application, which are copied to application containers and applied
+// by the proxy. A nil settings resets to default.
+func (br *UserBroker) SetHTTPSettings(name string, settings *userdb.HTTPSettings) error {
+	if err := validateHTTPSettings(settings); err != nil {
+		return err
+	}
+
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+	user := br.User.Basic()
+	if user.Applications[name] == nil {
+		return ApplicationNotFoundError(name)
+	}
+
+	unlock, err := br.lockApp(name, user.Namespace, "http-settings")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if s := settings; s != nil && !s.ForceHTTPS && s.HSTSMaxAge == 0 && len(s.ErrorPages) == 0 &&
+		!s.WebSocket && !s.GRPC && s.IdleTimeout == 0 {
+		settings = nil
+	}
+	app, err := br.Users.ModifyApplication(user.Name, name, func(app *userdb.Application) error {
+		app.HTTPSettings = settings
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	user.Applications[name] = app
+
+	cs, err := br.FindApplications(br.ctx, name, user.Namespace)
+	if err != nil {
+		return err
+	}
+	return br.applyHTTPSettings(cs, settings)
+}
+
+// applyHTTPSettings copies the HTTP settings to containers and notifies
+// the proxy.
+func (br *UserBroker) applyHTTPSettings(cs []container.Container, settings *userdb.HTTPSettings) error {
+	var s *manifest.HTTPSettings
+	if settings != nil {
+		s = &manifest.HTTPSettings{
+			ForceHTTPS:            settings.ForceHTTPS,
+			HSTSMaxAge:            settings.HSTSMaxAge,
+			HSTSIncludeSubdomains: settings.HSTSIncludeSubdomains,
+			ErrorPages:            settings.ErrorPages,
+			WebSocket:             settings.WebSocket,
+			GRPC:                  settings.GRPC,
+			IdleTimeout:           settings.IdleTimeout,
+		}
+	}
+	return Parallel(cs, func(c container.Container) error {
+		if err := c.SetHTTPSettings(br.ctx, s); err != nil {
+			return err
+		}
+		if c.ActiveState(br.ctx) == manifest.StateStopped {
+			return nil
+		}
+		return br.notify(ContainerUpdated, c, nil)
+	})
+}
+
+func validateHTTPSettings(settings *userdb.HTTPSettings) error {
+	if settings == nil {
+		return nil
+	}
+	if settings.HSTSMaxAge < 0 {
+		return InvalidHTTPSettingsError{"The HSTS max age must not be negative"}
+	}
+	if settings.IdleTimeout < 0 || settings.IdleTimeout > maxIdleTimeout {
+		return InvalidHTTPSettingsError{fmt.Sprintf("The idle timeout must be between 0 and %d seconds", maxIdleTimeout)}
+	}
+	for code, page := range settings.ErrorPages {
+		if n, err := strconv.Atoi(code); err != nil || n < 400 || n > 599 {
+			return InvalidHTTPSettingsError{fmt.Sprintf("Invalid error status code: %s", code)}
+		}
+		if !isProxyURL(page) {
+			return InvalidHTTPSettingsError{fmt.Sprintf("Invalid error page URL: %s", page)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package broker
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/config/defaults"
+)
+
+// Registration is open to everyone by default. If "auth.registration" is
+// "invite", users can only register with invitations created by platform
+// administrators. An invitation can be used once and may assign a
+// namespace and disk quota to the registered user.
+
+const defaultInviteTTL = 7 * 24 * time.Hour
+
+// RegistrationOpen returns true if users can register without invitation.
+func RegistrationOpen() bool {
+	return config.Get("auth.registration") != "invite"
+}
+
+// CreateInvite creates an invitation that expires after the given time, the
+// default expiration is used if zero. The invitation link is mailed to the
+// invited email address if given. Returns the invitation token and link.
+func (br *Broker) CreateInvite(inv *userdb.Invite, ttl time.Duration) (token, link string, err error) {
+	if inv.Namespace != "" {
+		if _, err = br.Users.FindByNamespace(inv.Namespace); err == nil {
+			return "", "", userdb.DuplicateNamespaceError(inv.Namespace)
+		} else if !userdb.IsUserNotFound(err) {
+			return "", "", err
+		}
+	}
+
+	if ttl <= 0 {
+		ttl = defaultInviteTTL
+	}
+	if token, err = br.Users.CreateInvite(inv, ttl); err != nil {
+		return "", "", err
+	}
+	link = defaults.ConsoleURL() + "/auth/register?invite=" + url.QueryEscape(token)
+
+	if inv.Email != "" && config.Get("smtp.host") != "" {
+		body := fmt.Sprintf("You are invited to register a Cloudway account:\n\n%s\n\n"+
+			"The invitation expires at %s.\n", link, inv.Expires.Format(time.RFC1123))
+		go func() {
+			if err := sendMail(inv.Email, "Invitation to Cloudway", body); err != nil {
+				logrus.WithError(err).Warnf("Failed to send invitation to %s", inv.Email)
+			}
+		}()
+	}
+	return token, link, nil
+}
+
+// CheckInvite checks whether the user with the email address can register
+// with the invitation token. The email address is not checked if empty.
+// Returns nil if registration is open and no invitation is given.
+func (br *Broker) CheckInvite(token, email string) (*userdb.Invite, error) {
+	if token == "" {
+		if RegistrationOpen() {
+			return nil, nil
+		}
+		return nil, RegistrationClosedError{}
+	}
+
+	inv, err := br.Users.FindInvite(token)
+	if err != nil {
+		return nil, err
+	}
+	if inv.Email != "" && email != "" && inv.Email != strings.ToLower(email) {
+		return nil, userdb.InvalidInviteError{}
+	}
+	return inv, nil
+}
+
+// RegisterUser creates a user registered by the user. An invitation is
+// required if registration is not open. The invitation is used and its
+// namespace and disk quota are assigned to the user.
+func (br *Broker) RegisterUser(user userdb.User, password, invite string) error {
+	basic := user.Basic()
+	inv, err := br.CheckInvite(invite, basic.Name)
+	if err != nil {
+		return err
+	}
+	if inv == nil {
+		return br.CreateUser(user, password)
+	}
+
+	if inv, err = br.Users.UseInvite(invite); err != nil {
+		return err
+	}
+	basic.Namespace = inv.Namespace
+	basic.DiskQuota = inv.DiskQuota
+	if err = br.CreateUser(user, password); err != nil {
+		// the invitation can be used again if registration failed
+		if er := br.Users.ReturnInvite(inv); er != nil {
+			logrus.WithError(er).Warn("Failed to restore invitation")
+		}
+		return err
+	}
+	return nil
+}
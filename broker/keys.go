@@ -0,0 +1,119 @@
+package broker
+
+import (
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/auth/userdb"
+)
+
+// ListSSHKeys returns public SSH keys of the user. Keys that were only
+// added to the SCM by earlier versions are migrated to the user database.
+func (br *UserBroker) ListSSHKeys() ([]*userdb.SSHKey, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	if br.migrateSSHKeys() {
+		if err := br.Refresh(); err != nil {
+			return nil, err
+		}
+	}
+	keys := br.User.Basic().SSHKeys
+	if keys == nil {
+		keys = []*userdb.SSHKey{}
+	}
+	return keys, nil
+}
+
+// AddSSHKey adds a public SSH key to the user. The key is also added to
+// the SCM so that it can be used to access repositories.
+func (br *UserBroker) AddSSHKey(label, text string) (*userdb.SSHKey, error) {
+	key, err := userdb.NewSSHKey(label, text)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = br.Refresh(); err != nil {
+		return nil, err
+	}
+	user := br.User.Basic()
+
+	if user.Namespace != "" {
+		if err = br.SCM().AddKey(user.Namespace, key.Text); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = br.Users.AddSSHKey(user.Name, key); err != nil {
+		if user.Namespace != "" {
+			br.SCM().RemoveKey(user.Namespace, key.Text)
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// RemoveSSHKey removes the public SSH key with the given fingerprint
+// from the user and the SCM.
+func (br *UserBroker) RemoveSSHKey(fingerprint string) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+	user := br.User.Basic()
+
+	key, err := br.Users.RemoveSSHKey(user.Name, fingerprint)
+	if err != nil {
+		return err
+	}
+
+	if user.Namespace != "" {
+		if err = br.SCM().RemoveKey(user.Namespace, key.Text); err != nil {
+			logrus.WithError(err).Warnf("Failed to remove SSH key %s from SCM", fingerprint)
+		}
+	}
+	return nil
+}
+
+// syncSSHKeys adds all public SSH keys of the user to the newly created
+// SCM namespace.
+func (br *UserBroker) syncSSHKeys(namespace string) {
+	for _, key := range br.User.Basic().SSHKeys {
+		if err := br.SCM().AddKey(namespace, key.Text); err != nil {
+			logrus.WithError(err).Warnf("Failed to add SSH key %s to SCM", key.Fingerprint)
+		}
+	}
+}
+
+// migrateSSHKeys adds public SSH keys in the SCM namespace of the user that
+// are missing from the user database. Returns true if any key was added.
+func (br *UserBroker) migrateSSHKeys() bool {
+	user := br.User.Basic()
+	if user.Namespace == "" {
+		return false
+	}
+
+	scmKeys, err := br.SCM().ListKeys(user.Namespace)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to list SSH keys from SCM")
+		return false
+	}
+
+	known := make(map[string]bool)
+	for _, key := range user.SSHKeys {
+		known[key.Fingerprint] = true
+	}
+
+	var migrated bool
+	for _, k := range scmKeys {
+		key, err := userdb.NewSSHKey(k.Label, k.Text)
+		if err != nil || known[key.Fingerprint] {
+			continue
+		}
+		if err = br.Users.AddSSHKey(user.Name, key); err != nil {
+			logrus.WithError(err).Warnf("Failed to migrate SSH key %s", key.Fingerprint)
+			continue
+		}
+		known[key.Fingerprint] = true
+		migrated = true
+	}
+	return migrated
+}
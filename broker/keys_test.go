@@ -0,0 +1,69 @@
+package broker_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/cloudway/platform/auth/userdb"
+)
+
+var _ = Describe("SSH keys", func() {
+	var user *userdb.BasicUser
+
+	BeforeEach(func() {
+		user = &userdb.BasicUser{Name: TESTUSER}
+		Expect(broker.CreateUser(user, "test")).To(Succeed())
+		br := broker.NewUserBroker(user, context.Background())
+		Expect(br.CreateNamespace(NAMESPACE)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(broker.RemoveUser(TESTUSER, true)).To(Succeed())
+	})
+
+	var newKeyText = func(comment string) string {
+		priv, err := rsa.GenerateKey(rand.Reader, 1024)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		pub, err := ssh.NewPublicKey(&priv.PublicKey)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))) + " " + comment
+	}
+
+	It("should add and remove keys in the SCM", func() {
+		br := broker.NewUserBroker(user, context.Background())
+		key, err := br.AddSSHKey("", newKeyText("test@host"))
+		Expect(err).NotTo(HaveOccurred())
+
+		keys, err := broker.SCM().ListKeys(NAMESPACE)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keys).To(HaveLen(1))
+
+		Expect(br.RemoveSSHKey(key.Fingerprint)).To(Succeed())
+		keys, err = broker.SCM().ListKeys(NAMESPACE)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keys).To(BeEmpty())
+	})
+
+	It("should migrate keys only stored in the SCM", func() {
+		text := newKeyText("old@host")
+		Expect(broker.SCM().AddKey(NAMESPACE, text)).To(Succeed())
+
+		br := broker.NewUserBroker(user, context.Background())
+		keys, err := br.ListSSHKeys()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keys).To(HaveLen(1))
+		Expect(keys[0].Text).To(Equal(text))
+
+		Expect(br.RemoveSSHKey(keys[0].Fingerprint)).To(Succeed())
+		Expect(br.ListSSHKeys()).To(BeEmpty())
+		scmKeys, err := broker.SCM().ListKeys(NAMESPACE)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scmKeys).To(BeEmpty())
+	})
+})
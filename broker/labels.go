@@ -0,0 +1,137 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/cloudway/platform/auth/userdb"
+)
+
+// Labels of an application are user defined key/value pairs stored in the
+// user database, which are used to organize and select applications. The
+// labels are also added to containers of the application when created, so
+// that containers created before the labels were changed keep old labels
+// until they are recreated.
+
+const (
+	maxLabels           = 64
+	maxLabelKeyLength   = 63
+	maxLabelValueLength = 255
+)
+
+var labelKeyPattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._/-]*[a-zA-Z0-9])?$`)
+
+type InvalidLabelError struct {
+	Message string
+}
+
+func (e InvalidLabelError) Error() string {
+	return e.Message
+}
+
+func (e InvalidLabelError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// ValidateLabels checks that label keys are made of letters, digits, dots,
+// dashes, underscores and slashes, and values are printable.
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return InvalidLabelError{fmt.Sprintf("Too many labels, at most %d labels can be set", maxLabels)}
+	}
+	for k, v := range labels {
+		if len(k) > maxLabelKeyLength || !labelKeyPattern.MatchString(k) {
+			return InvalidLabelError{fmt.Sprintf("Invalid label key: %q", k)}
+		}
+		if len(v) > maxLabelValueLength || strings.IndexFunc(v, func(r rune) bool { return !unicode.IsPrint(r) }) != -1 {
+			return InvalidLabelError{fmt.Sprintf("Invalid value of label %s: %q", k, v)}
+		}
+	}
+	return nil
+}
+
+// SetLabels replaces labels of the application. Empty labels remove all
+// labels from the application.
+func (br *UserBroker) SetLabels(name string, labels map[string]string) error {
+	if err := ValidateLabels(labels); err != nil {
+		return err
+	}
+	if len(labels) == 0 {
+		labels = nil
+	}
+
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+	user := br.User.Basic()
+	if user.Applications[name] == nil {
+		return ApplicationNotFoundError(name)
+	}
+
+	app, err := br.Users.ModifyApplication(user.Name, name, func(app *userdb.Application) error {
+		app.Labels = labels
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	user.Applications[name] = app
+	return nil
+}
+
+// LabelSelector selects applications by labels. Each requirement of the
+// selector is in the form of "key=value", "key!=value" or "key", the last
+// one selects applications having the label with any value.
+type LabelSelector []labelRequirement
+
+type labelRequirement struct {
+	key, value string
+	op         string
+}
+
+// ParseLabelSelector parses label requirements, all of them must be met by
+// selected applications.
+func ParseLabelSelector(reqs []string) (LabelSelector, error) {
+	var sel LabelSelector
+	for _, s := range reqs {
+		var r labelRequirement
+		if i := strings.Index(s, "!="); i != -1 {
+			r = labelRequirement{key: s[:i], value: s[i+2:], op: "!="}
+		} else if i := strings.IndexRune(s, '='); i != -1 {
+			r = labelRequirement{key: s[:i], value: s[i+1:], op: "="}
+		} else {
+			r = labelRequirement{key: s}
+		}
+		r.key = strings.TrimSpace(r.key)
+		if !labelKeyPattern.MatchString(r.key) {
+			return nil, InvalidLabelError{fmt.Sprintf("Invalid label selector: %q", s)}
+		}
+		sel = append(sel, r)
+	}
+	return sel, nil
+}
+
+// Matches returns true if the labels meet all requirements of the selector.
+func (sel LabelSelector) Matches(labels map[string]string) bool {
+	for _, r := range sel {
+		v, ok := labels[r.key]
+		switch r.op {
+		case "=":
+			if !ok || v != r.value {
+				return false
+			}
+		case "!=":
+			if ok && v == r.value {
+				return false
+			}
+		default:
+			if !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package broker_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+)
+
+var _ = Describe("Labels", func() {
+	var (
+		user = userdb.BasicUser{Name: TESTUSER, Namespace: NAMESPACE}
+		ctx  = context.Background()
+	)
+
+	Describe("Selector", func() {
+		labels := map[string]string{"env": "prod", "team": "web"}
+
+		It("should match labels with values", func() {
+			sel, err := br.ParseLabelSelector([]string{"env=prod", "team"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sel.Matches(labels)).To(BeTrue())
+			Expect(sel.Matches(map[string]string{"env": "prod"})).To(BeFalse())
+			Expect(sel.Matches(nil)).To(BeFalse())
+		})
+
+		It("should exclude labels with values", func() {
+			sel, err := br.ParseLabelSelector([]string{"env!=test"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sel.Matches(labels)).To(BeTrue())
+			Expect(sel.Matches(nil)).To(BeTrue())
+			Expect(sel.Matches(map[string]string{"env": "test"})).To(BeFalse())
+		})
+
+		It("should match all labels with empty selector", func() {
+			sel, err := br.ParseLabelSelector(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sel.Matches(nil)).To(BeTrue())
+		})
+
+		It("should reject invalid selector", func() {
+			_, err := br.ParseLabelSelector([]string{"=prod"})
+			Expect(err).To(BeAssignableToTypeOf(br.InvalidLabelError{}))
+		})
+	})
+
+	Describe("Application", func() {
+		BeforeEach(func() {
+			Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(broker.RemoveUser(TESTUSER, true)).To(Succeed())
+		})
+
+		It("should create application with labels", func() {
+			ub := broker.NewUserBroker(&user, ctx)
+			opts := container.CreateOptions{Name: "test", Labels: map[string]string{"env": "prod"}}
+			app, _, err := ub.CreateApplication(opts, []string{"mock"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(app.Labels).To(Equal(map[string]string{"env": "prod"}))
+		})
+
+		It("should reject invalid labels", func() {
+			ub := broker.NewUserBroker(&user, ctx)
+			opts := container.CreateOptions{Name: "test", Labels: map[string]string{"env prod": ""}}
+			_, _, err := ub.CreateApplication(opts, []string{"mock"})
+			Expect(err).To(BeAssignableToTypeOf(br.InvalidLabelError{}))
+		})
+
+		It("should replace labels", func() {
+			ub := broker.NewUserBroker(&user, ctx)
+			_, _, err := ub.CreateApplication(container.CreateOptions{Name: "test"}, []string{"mock"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ub.SetLabels("test", map[string]string{"team": "web"})).To(Succeed())
+			apps, err := ub.GetApplications()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(apps["test"].Labels).To(Equal(map[string]string{"team": "web"}))
+
+			Expect(ub.SetLabels("test", nil)).To(Succeed())
+			apps, err = ub.GetApplications()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(apps["test"].Labels).To(BeNil())
+		})
+	})
+})
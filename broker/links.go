@@ -0,0 +1,237 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+)
+
+// A service of an application, such as a database, or a standalone service
+// can be linked into applications in the same namespace. The connection
+// environment variables exported by the service are set in framework
+// containers of the linking application, and updated after the service
+// container started. A linked service cannot be removed until all links to
+// it are removed.
+
+// GetLinks returns services linked into the application.
+func (br *UserBroker) GetLinks(name string) ([]userdb.ServiceLink, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	app := br.User.Basic().Applications[name]
+	if app == nil {
+		return nil, ApplicationNotFoundError(name)
+	}
+	if app.Links == nil {
+		return []userdb.ServiceLink{}, nil
+	}
+	return app.Links, nil
+}
+
+// LinkService links the service of another application in the same
+// namespace into the application.
+func (br *UserBroker) LinkService(name, app, service string) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
+	user := br.User.Basic()
+	if user.Applications[name] == nil {
+		return ApplicationNotFoundError(name)
+	}
+	if user.Applications[app] == nil && user.Services[app] == nil {
+		return ApplicationNotFoundError(app)
+	}
+	if app == name {
+		return fmt.Errorf("Cannot link the service '%s' into its own application", service)
+	}
+
+	svc, err := br.linkedService(br.ctx, app, user.Namespace, service)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := br.lockApp(name, user.Namespace, "service linking")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	link := userdb.ServiceLink{App: app, Service: service}
+	err = br.modifyLinks(name, func(links []userdb.ServiceLink) ([]userdb.ServiceLink, error) {
+		for _, l := range links {
+			if l == link {
+				return links, nil
+			}
+		}
+		return append(links, link), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	info, err := svc.GetInfo(br.ctx, "env")
+	if err != nil {
+		return err
+	}
+	return br.setLinkEnv(br.ctx, name, user.Namespace, info.Env)
+}
+
+// UnlinkService removes the link to the service of another application,
+// connection environment variables of the service are removed from the
+// application.
+func (br *UserBroker) UnlinkService(name, app, service string) error {
+	unlock, err := br.lockApp(name, br.Namespace(), "service unlinking")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	link := userdb.ServiceLink{App: app, Service: service}
+	err = br.modifyLinks(name, func(links []userdb.ServiceLink) ([]userdb.ServiceLink, error) {
+		for i, l := range links {
+			if l == link {
+				return append(links[:i], links[i+1:]...), nil
+			}
+		}
+		return nil, ServiceNotLinkedError{App: app, Service: service}
+	})
+	if err != nil {
+		return err
+	}
+
+	// the service may have been gone with its environment
+	svc, err := br.linkedService(br.ctx, app, br.Namespace(), service)
+	if err != nil {
+		return nil
+	}
+	info, err := svc.GetInfo(br.ctx, "env")
+	if err != nil || len(info.Env) == 0 {
+		return err
+	}
+
+	args := []string{"/usr/bin/cwctl", "setenv", "-d"}
+	for k := range info.Env {
+		args = append(args, k)
+	}
+	cs, err := br.FindApplications(br.ctx, name, br.Namespace())
+	if err != nil {
+		return err
+	}
+	opts := container.ExecOptions{User: "root"}
+	return Parallel(cs, func(c container.Container) error {
+		return c.ExecWithOptions(br.ctx, opts, args...)
+	})
+}
+
+func (br *UserBroker) modifyLinks(name string, modify func([]userdb.ServiceLink) ([]userdb.ServiceLink, error)) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+	user := br.User.Basic()
+	if user.Applications[name] == nil {
+		return ApplicationNotFoundError(name)
+	}
+
+	app, err := br.Users.ModifyApplication(user.Name, name, func(app *userdb.Application) (err error) {
+		app.Links, err = modify(app.Links)
+		return err
+	})
+	if err == nil {
+		user.Applications[name] = app
+	}
+	return err
+}
+
+func (br *Broker) linkedService(ctx context.Context, app, namespace, service string) (container.Container, error) {
+	cs, err := br.FindService(ctx, app, namespace, service)
+	if err != nil {
+		return nil, err
+	}
+	if len(cs) == 0 || !cs[0].Category().IsService() {
+		return nil, fmt.Errorf("service '%s' not found in application '%s'", service, app)
+	}
+	return cs[0], nil
+}
+
+// setLinkEnv sets connection environment variables of a linked service in
+// framework containers of the application.
+func (br *Broker) setLinkEnv(ctx context.Context, name, namespace string, env map[string]string) error {
+	if len(env) == 0 {
+		return nil
+	}
+	cs, err := br.FindApplications(ctx, name, namespace)
+	if err != nil {
+		return err
+	}
+	return Parallel(cs, func(c container.Container) error {
+		for k, v := range env {
+			if err := c.Setenv(ctx, k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// checkLinks returns an error if the service of the application is linked
+// into other applications. All services of the application are checked if
+// the service is empty.
+func checkLinks(apps map[string]*userdb.Application, app, service string) error {
+	var linkedBy []string
+	var linked string
+	for name, a := range apps {
+		for _, l := range a.Links {
+			if l.App == app && (service == "" || l.Service == service) {
+				linkedBy = append(linkedBy, name)
+				linked = l.Service
+				break
+			}
+		}
+	}
+	if len(linkedBy) == 0 {
+		return nil
+	}
+	sort.Strings(linkedBy)
+	return ServiceLinkedError{App: app, Service: linked, LinkedBy: linkedBy}
+}
+
+// refreshLinks updates connection environment variables in applications
+// linking the service after the service container started, as the private
+// address of the service may be changed.
+func (br *Broker) refreshLinks(c container.Container) {
+	owner, err := br.Users.FindByNamespace(c.Namespace())
+	if err != nil {
+		return
+	}
+
+	var consumers []string
+	for name, app := range owner.Basic().Applications {
+		for _, l := range app.Links {
+			if l.App == c.Name() && l.Service == c.ServiceName() {
+				consumers = append(consumers, name)
+				break
+			}
+		}
+	}
+	if len(consumers) == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	info, err := c.GetInfo(ctx, "env")
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to get environment of linked service %s", c.ID())
+		return
+	}
+	for _, name := range consumers {
+		if err := br.setLinkEnv(ctx, name, c.Namespace(), info.Env); err != nil {
+			logrus.WithError(err).Warnf("Failed to update linked service environment of %s-%s", name, c.Namespace())
+		}
+	}
+}
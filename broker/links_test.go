@@ -0,0 +1,62 @@
+package broker_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+)
+
+var _ = Describe("Links", func() {
+	var (
+		user = userdb.BasicUser{Name: TESTUSER, Namespace: NAMESPACE}
+		ctx  = context.Background()
+	)
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+
+		ub := broker.NewUserBroker(&user, ctx)
+		_, _, err := ub.CreateApplication(container.CreateOptions{Name: "db"}, []string{"mock", "mockdb"})
+		Expect(err).NotTo(HaveOccurred())
+		_, _, err = ub.CreateApplication(container.CreateOptions{Name: "web"}, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(broker.RemoveUser(TESTUSER, true)).To(Succeed())
+	})
+
+	It("should link services of other applications", func() {
+		ub := broker.NewUserBroker(&user, ctx)
+		Expect(ub.LinkService("web", "db", "mockdb")).To(Succeed())
+		Expect(ub.GetLinks("web")).To(Equal([]userdb.ServiceLink{{App: "db", Service: "mockdb"}}))
+
+		Expect(ub.UnlinkService("web", "db", "mockdb")).To(Succeed())
+		Expect(ub.GetLinks("web")).To(BeEmpty())
+		Expect(ub.UnlinkService("web", "db", "mockdb")).To(Equal(br.ServiceNotLinkedError{App: "db", Service: "mockdb"}))
+	})
+
+	It("should not remove linked services", func() {
+		ub := broker.NewUserBroker(&user, ctx)
+		Expect(ub.LinkService("web", "db", "mockdb")).To(Succeed())
+
+		linked := br.ServiceLinkedError{App: "db", Service: "mockdb", LinkedBy: []string{"web"}}
+		Expect(ub.RemoveService("db", "mockdb")).To(Equal(linked))
+		Expect(ub.RemoveApplication("db")).To(Equal(linked))
+
+		Expect(ub.UnlinkService("web", "db", "mockdb")).To(Succeed())
+		Expect(ub.RemoveService("db", "mockdb")).To(Succeed())
+	})
+
+	It("should not link missing services", func() {
+		ub := broker.NewUserBroker(&user, ctx)
+		Expect(ub.LinkService("web", "db", "nosuchdb")).NotTo(Succeed())
+		Expect(ub.LinkService("web", "nosuchapp", "mockdb")).To(Equal(br.ApplicationNotFoundError("nosuchapp")))
+		Expect(ub.LinkService("db", "db", "mockdb")).NotTo(Succeed())
+	})
+})
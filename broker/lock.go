@@ -0,0 +1,49 @@
+package broker
+
+import (
+	"time"
+)
+
+// lockTTL is the time that an application lock is kept after the server
+// holding the lock crashed.
+const lockTTL = 5 * time.Minute
+
+// OperationLock describes an operation in progress on an application.
+type OperationLock struct {
+	Operation string
+	Since     time.Time
+}
+
+func lockKey(name, namespace string) string {
+	return "lock:" + name + "-" + namespace
+}
+
+// lockApp locks the application for the given operation and returns the
+// function to unlock it. Returns ApplicationBusyError if another operation
+// is in progress. Operations on an application that is locked by another
+// operation are rejected instead of waiting, so callers get immediate
+// feedback. The lock is shared by all API servers.
+func (br *Broker) lockApp(name, namespace, op string) (unlock func(), err error) {
+	unlock, ok, err := br.holdLease(lockKey(name, namespace), op, lockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		busy := ApplicationBusyError{Name: name}
+		if cur := br.ApplicationLock(name, namespace); cur != nil {
+			busy.Operation = cur.Operation
+		}
+		return nil, busy
+	}
+	return unlock, nil
+}
+
+// ApplicationLock returns the operation in progress on the application, or
+// nil if the application is not locked.
+func (br *Broker) ApplicationLock(name, namespace string) *OperationLock {
+	lease, err := br.Users.FindLease(lockKey(name, namespace))
+	if err != nil || lease == nil {
+		return nil
+	}
+	return &OperationLock{Operation: lease.Value, Since: lease.Since}
+}
@@ -0,0 +1,145 @@
+package broker
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+type InvalidLogConfigError struct {
+	Message string
+}
+
+func (e InvalidLogConfigError) Error() string {
+	return e.Message
+}
+
+func (e InvalidLogConfigError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// SetLogging sets the log shipping configuration of the application. The
+// log driver of an existing container can't be changed, so containers of
+// the application are recreated with the new configuration, and containers
+// that were running are restarted. A nil configuration resets to the
+// default log driver.
+func (br *UserBroker) SetLogging(name string, logging *userdb.LogConfig) error {
+	if logging != nil && logging.Driver == "" && logging.Address == "" && len(logging.Options) == 0 {
+		logging = nil
+	}
+	if err := validateLogConfig(logging); err != nil {
+		return err
+	}
+
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+	user := br.User.Basic()
+	if user.Applications[name] == nil {
+		return ApplicationNotFoundError(name)
+	}
+
+	unlock, err := br.lockApp(name, user.Namespace, "logging")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	app, err := br.Users.ModifyApplication(user.Name, name, func(app *userdb.Application) error {
+		app.Logging = logging
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	user.Applications[name] = app
+
+	cs, err := br.FindAll(br.ctx, name, user.Namespace)
+	if err != nil {
+		return err
+	}
+	return br.applyLogging(cs, (*container.LogConfig)(logging))
+}
+
+// applyLogging recreates containers with the log shipping configuration.
+func (br *UserBroker) applyLogging(cs []container.Container, logging *container.LogConfig) error {
+	var mu sync.Mutex
+	var running []container.Container
+	err := Parallel(cs, func(c container.Container) error {
+		wasRunning := c.ActiveState(br.ctx) != manifest.StateStopped
+		nc, err := c.SetLogging(br.ctx, logging)
+		if err != nil {
+			return err
+		}
+		br.notify(ContainerDestroyed, c, nil)
+		if wasRunning {
+			mu.Lock()
+			running = append(running, nc)
+			mu.Unlock()
+		}
+		return nil
+	})
+
+	if len(running) != 0 {
+		er := startContainers(running, func(c container.Container) error {
+			return br.notify(ContainerStarted, c, c.Start(br.ctx, nil))
+		})
+		if err == nil {
+			err = er
+		}
+	}
+	return err
+}
+
+func validateLogConfig(logging *userdb.LogConfig) error {
+	if logging == nil {
+		return nil
+	}
+
+	// the collector address must be given by the address, not options
+	addrKey, ok := container.LogAddressOptions[logging.Driver]
+	if !ok {
+		return InvalidLogConfigError{fmt.Sprintf("Unsupported log driver: %s", logging.Driver)}
+	}
+	if !isLogAddress(logging.Driver, logging.Address) {
+		return InvalidLogConfigError{fmt.Sprintf("Invalid %s address: %s", logging.Driver, logging.Address)}
+	}
+	for k, v := range logging.Options {
+		if k == addrKey || (k != "tag" && !strings.HasPrefix(k, logging.Driver+"-")) {
+			return InvalidLogConfigError{fmt.Sprintf("Invalid %s log option: %s", logging.Driver, k)}
+		}
+		if strings.ContainsAny(v, "\r\n") {
+			return InvalidLogConfigError{fmt.Sprintf("Invalid value of log option %s", k)}
+		}
+	}
+	return nil
+}
+
+// isLogAddress checks the address of the log collector. The syslog address
+// is in the form of "udp://host:port", "tcp://host:port" or
+// "tcp+tls://host:port", the fluentd address is "host:port", and the Loki
+// address is the URL of the push API.
+func isLogAddress(driver, address string) bool {
+	if driver == container.LogDriverFluentd {
+		_, port, err := net.SplitHostPort(strings.TrimPrefix(address, "tcp://"))
+		return err == nil && port != ""
+	}
+
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	switch driver {
+	case container.LogDriverSyslog:
+		return u.Scheme == "udp" || u.Scheme == "tcp" || u.Scheme == "tcp+tls"
+	default:
+		return u.Scheme == "http" || u.Scheme == "https"
+	}
+}
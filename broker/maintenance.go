@@ -0,0 +1,89 @@
+package broker
+
+import (
+	"time"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// SetMaintenance turns the maintenance mode of an application on or off.
+// In maintenance mode the proxy serves a maintenance page instead of the
+// application. If stop is true the application containers are stopped,
+// and they are started again when the maintenance mode is turned off.
+// The maintenance mode can't be turned on if not supported by the proxy.
+func (br *UserBroker) SetMaintenance(name string, on, stop bool, log *serverlog.ServerLog) error {
+	if on {
+		if err := checkProxyFeature(ProxyMaintenance); err != nil {
+			return err
+		}
+	}
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
+	user := br.User.Basic()
+	app := user.Applications[name]
+	if app == nil {
+		return ApplicationNotFoundError(name)
+	}
+
+	unlock, err := br.lockApp(name, user.Namespace, "maintenance")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cs, err := br.FindAll(br.ctx, name, user.Namespace)
+	if err != nil {
+		return err
+	}
+
+	var by string
+	var restart bool
+	var maintenance *userdb.Maintenance
+	if on {
+		by = user.Name
+		maintenance = &userdb.Maintenance{By: by, Since: time.Now(), Stopped: stop}
+	} else {
+		restart = app.Maintenance != nil && app.Maintenance.Stopped
+	}
+
+	err = Parallel(cs, func(c container.Container) error {
+		if err := c.SetMaintenance(br.ctx, by); err != nil {
+			return err
+		}
+		switch {
+		case on && stop:
+			return br.notify(ContainerStopped, c, c.Stop(br.ctx))
+		case restart:
+			// the maintenance page is kept until the container started
+			return nil
+		case c.ActiveState(br.ctx) == manifest.StateStopped:
+			return br.notify(ContainerStopped, c, nil)
+		default:
+			return br.notify(ContainerUpdated, c, nil)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	app, err = br.Users.ModifyApplication(user.Name, name, func(app *userdb.Application) error {
+		app.Maintenance = maintenance
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	user.Applications[name] = app
+	if !restart {
+		return nil
+	}
+
+	return startContainers(cs, withProgress(log, len(cs), func(c container.Container) error {
+		return br.notify(ContainerStarted, c, c.Start(br.ctx, log))
+	}))
+}
@@ -3,6 +3,16 @@ package broker
 import (
 	"errors"
 	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	mulerr "github.com/cloudway/platform/pkg/errors"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/scm"
 )
 
 var namespacePattern = regexp.MustCompile("^[a-z][a-z_0-9]*$")
@@ -14,6 +24,9 @@ func (br *UserBroker) CreateNamespace(namespace string) (err error) {
 	if !namespacePattern.MatchString(namespace) {
 		return errors.New("The namespace can only contains lower case letters, digits, or underscores")
 	}
+	if err = checkNamespaceName(namespace); err != nil {
+		return err
+	}
 
 	if err = br.Refresh(); err != nil {
 		return err
@@ -27,8 +40,8 @@ func (br *UserBroker) CreateNamespace(namespace string) (err error) {
 	}
 
 	// make sure no applications exists in the old namespace
-	if len(user.Applications) != 0 {
-		return NamespaceNotEmptyError(user.Namespace)
+	if err = br.checkNamespaceEmpty(br.ctx, user); err != nil {
+		return err
 	}
 
 	// update the namespace in the user database,
@@ -40,10 +53,10 @@ func (br *UserBroker) CreateNamespace(namespace string) (err error) {
 
 	// recreate namespace in the SCM
 	if oldNamespace != "" {
-		err = br.SCM.RemoveNamespace(oldNamespace)
+		err = br.SCM().RemoveNamespace(oldNamespace)
 	}
 	if err == nil {
-		err = br.SCM.CreateNamespace(namespace)
+		err = br.SCM().CreateNamespace(namespace)
 	}
 
 	// restore user database if failed to recreate SCM namespace
@@ -53,6 +66,7 @@ func (br *UserBroker) CreateNamespace(namespace string) (err error) {
 	}
 
 	user.Namespace = namespace
+	br.syncSSHKeys(namespace)
 	return nil
 }
 
@@ -66,19 +80,26 @@ func (br *UserBroker) RemoveNamespace(force bool) (err error) {
 		return nil
 	}
 
-	if !force && len(user.Applications) != 0 {
-		return NamespaceNotEmptyError(user.Namespace)
+	if !force {
+		if err = br.checkNamespaceEmpty(br.ctx, user); err != nil {
+			return err
+		}
 	}
 
-	// remove all applications in the namespace
+	// remove all applications and standalone services in the namespace
 	for app := range user.Applications {
 		if err = br.RemoveApplication(app); err != nil {
 			return err
 		}
 	}
+	for svc := range user.Services {
+		if err = br.RemoveStandaloneService(svc); err != nil {
+			return err
+		}
+	}
 
 	// remove the namespace from SCM
-	err = br.SCM.RemoveNamespace(user.Namespace)
+	err = br.SCM().RemoveNamespace(user.Namespace)
 	if err != nil {
 		return err
 	}
@@ -86,6 +107,15 @@ func (br *UserBroker) RemoveNamespace(force bool) (err error) {
 	// remove the namespace from plugin hub
 	br.Hub.RemoveNamespace(user.Namespace)
 
+	// the delegated domain is released with the namespace
+	if user.Domain != "" {
+		if err = br.Users.SetDomain(user.Name, ""); err != nil {
+			return err
+		}
+		updateDNS(nil, wildcardNames(user.Domain))
+		user.Domain = ""
+	}
+
 	// update namespace in the user database
 	err = br.Users.SetNamespace(user.Name, "")
 	if err != nil {
@@ -95,3 +125,155 @@ func (br *UserBroker) RemoveNamespace(force bool) (err error) {
 	user.Namespace = ""
 	return nil
 }
+
+// RenameNamespace changes the namespace of the user. Repositories,
+// containers and user defined plugins are moved to the new namespace, and
+// old application URLs are redirected to new URLs for a grace period.
+func (br *UserBroker) RenameNamespace(namespace string) (err error) {
+	if !namespacePattern.MatchString(namespace) {
+		return errors.New("The namespace can only contains lower case letters, digits, or underscores")
+	}
+	if err = checkNamespaceName(namespace); err != nil {
+		return err
+	}
+
+	if err = br.Refresh(); err != nil {
+		return err
+	}
+
+	user := br.User.Basic()
+	oldNamespace := user.Namespace
+	if oldNamespace == "" {
+		return NoNamespaceError(user.Name)
+	}
+	if namespace == oldNamespace {
+		return nil
+	}
+
+	// no other operations can be performed on applications while renaming
+	for name := range user.Applications {
+		unlock, err := br.lockApp(name, oldNamespace, "rename")
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+	for name := range user.Services {
+		unlock, err := br.lockApp(name, oldNamespace, "rename")
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	// update the namespace in the user database,
+	// may conflict if namespace already exists
+	if err = br.Users.SetNamespace(user.Name, namespace); err != nil {
+		return err
+	}
+
+	// rename namespace in the SCM, restore user database if failed
+	if err = scm.RenameNamespace(br.SCM(), oldNamespace, namespace); err != nil {
+		br.Users.SetNamespace(user.Name, oldNamespace)
+		return err
+	}
+	user.Namespace = namespace
+	br.syncSSHKeys(namespace)
+
+	// move user defined plugins and update plugin tags of applications
+	if err = br.Hub.RenameNamespace(oldNamespace, namespace); err != nil {
+		logrus.WithError(err).Warnf("Failed to rename plugin namespace %s to %s", oldNamespace, namespace)
+	}
+	for name := range user.Applications {
+		app, err := br.Users.ModifyApplication(user.Name, name, func(app *userdb.Application) error {
+			for i, tag := range app.Plugins {
+				if strings.HasPrefix(tag, oldNamespace+"/") {
+					app.Plugins[i] = namespace + "/" + tag[len(oldNamespace)+1:]
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		user.Applications[name] = app
+	}
+	if len(user.Services) != 0 {
+		for _, svc := range user.Services {
+			if strings.HasPrefix(svc.Plugin, oldNamespace+"/") {
+				svc.Plugin = namespace + "/" + svc.Plugin[len(oldNamespace)+1:]
+			}
+		}
+		if err = br.Users.Update(user.Name, userdb.Args{"services": user.Services}); err != nil {
+			return err
+		}
+	}
+
+	// recreate containers with the new namespace
+	var errs mulerr.Errors
+	redirects := make(map[string]string)
+	for name := range user.Applications {
+		if err := br.renameContainers(name, oldNamespace, name, namespace, user.Domain); err != nil {
+			logrus.WithError(err).Errorf("Failed to rename containers of %s-%s", name, oldNamespace)
+			errs.Add(err)
+		}
+		if err := renameArtifacts(name, oldNamespace, name, namespace); err != nil {
+			logrus.WithError(err).Warnf("Failed to rename artifacts of %s-%s", name, oldNamespace)
+		}
+		if err := renameDeployments(name, oldNamespace, name, namespace); err != nil {
+			logrus.WithError(err).Warnf("Failed to rename deployment history of %s-%s", name, oldNamespace)
+		}
+		if user.Domain == "" {
+			// hosts under the delegated domain don't change
+			redirects[appHost(name, oldNamespace, "")] = appURL(name, namespace, "")
+		}
+	}
+	for name := range user.Services {
+		if err := br.renameContainers(name, oldNamespace, name, namespace, user.Domain); err != nil {
+			logrus.WithError(err).Errorf("Failed to rename containers of service %s-%s", name, oldNamespace)
+			errs.Add(err)
+		}
+	}
+
+	if err = addRedirects(redirects); err != nil {
+		logrus.WithError(err).Warn("Failed to add redirects for renamed applications")
+	}
+	return errs.Err()
+}
+
+// renameContainers recreates containers of an application with the new
+// name, namespace and domain. Containers that were running are restarted.
+func (br *UserBroker) renameContainers(name, namespace, newName, newNamespace, domain string) error {
+	cs, err := br.FindAll(br.ctx, name, namespace)
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	var running []container.Container
+	err = Parallel(cs, func(c container.Container) error {
+		wasRunning := c.ActiveState(br.ctx) != manifest.StateStopped
+		nc, err := c.Rename(br.ctx, newName, newNamespace, domain)
+		if err != nil {
+			return err
+		}
+		br.notify(ContainerDestroyed, c, nil)
+		br.notify(ContainerRenamed, nc, nil)
+		if wasRunning {
+			mu.Lock()
+			running = append(running, nc)
+			mu.Unlock()
+		}
+		return nil
+	})
+
+	if len(running) != 0 {
+		er := startContainers(running, func(c container.Container) error {
+			return br.notify(ContainerStarted, c, c.Start(br.ctx, nil))
+		})
+		if err == nil {
+			err = er
+		}
+	}
+	return err
+}
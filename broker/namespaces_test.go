@@ -9,6 +9,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/container"
 )
 
@@ -21,7 +22,7 @@ var _ = Describe("Namespaces", func() {
 	})
 
 	AfterEach(func() {
-		Expect(broker.RemoveUser(TESTUSER)).To(Succeed())
+		Expect(broker.RemoveUser(TESTUSER, true)).To(Succeed())
 	})
 
 	var createTestApp = func() {
@@ -49,6 +50,20 @@ var _ = Describe("Namespaces", func() {
 				br := broker.NewUserBroker(user, context.Background())
 				Expect(br.CreateNamespace("invalid-namespace")).NotTo(Succeed())
 			})
+
+			It("should fail with reserved namespace", func() {
+				br := broker.NewUserBroker(user, context.Background())
+				Expect(br.CreateNamespace("www")).To(MatchError("The namespace name 'www' is reserved"))
+			})
+
+			It("should use configured reserved namespaces", func() {
+				config.Set("user.reserved_namespaces", "foo, bar")
+				defer config.Set("user.reserved_namespaces", "")
+
+				br := broker.NewUserBroker(user, context.Background())
+				Expect(br.CreateNamespace("bar")).NotTo(Succeed())
+				Expect(br.CreateNamespace("www")).To(Succeed())
+			})
 		})
 
 		Context("when changing namespace", func() {
@@ -0,0 +1,160 @@
+package broker
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
+)
+
+// The node is marked unschedulable after the given number of consecutive
+// failed health checks.
+const maxHealthFailures = 3
+
+const defaultHealthInterval = 30 * time.Second
+
+// NodeStatus describes the health of a container engine node.
+type NodeStatus struct {
+	// The engine host address
+	Host string
+
+	// Whether the engine responded to the last health check
+	Reachable bool
+
+	// Whether new containers can be created on the node
+	Schedulable bool
+
+	// The error of last failed health check
+	Error string
+
+	// The time of last health check
+	LastCheck time.Time
+
+	// The number of consecutive failed health checks
+	Failures int
+
+	// The node informations reported by the engine, nil if unreachable
+	Info *container.NodeInfo
+
+	// The number of containers can be created on the node, -1 if unlimited.
+	// The maximum number of containers is configured by "node.max_containers".
+	Headroom int
+}
+
+type nodeMonitor struct {
+	mu     sync.Mutex
+	status NodeStatus
+
+	// whether plugin images are pulled since the node became reachable
+	warmed bool
+}
+
+func newNodeMonitor() *nodeMonitor {
+	host := config.Get("docker.host")
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+	if host == "" {
+		host = "unix:///var/run/docker.sock"
+	}
+	return &nodeMonitor{status: NodeStatus{Host: host, Reachable: true, Schedulable: true, Headroom: -1}}
+}
+
+// CheckNodes performs health check on container engine nodes.
+func (br *Broker) CheckNodes(ctx context.Context) []NodeStatus {
+	info, err := br.NodeInfo(ctx)
+
+	m := br.nodes
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := &m.status
+	st.LastCheck = time.Now()
+	if err != nil {
+		st.Reachable = false
+		st.Error = err.Error()
+		st.Info = nil
+		st.Failures++
+		m.warmed = false
+		if st.Failures >= maxHealthFailures && st.Schedulable {
+			logrus.WithError(err).Errorf("Container engine %s is unhealthy, marked as unschedulable", st.Host)
+			st.Schedulable = false
+		}
+	} else {
+		if !st.Schedulable {
+			logrus.Infof("Container engine %s recovered, marked as schedulable", st.Host)
+		}
+		if !m.warmed {
+			m.warmed = true
+			br.PrepullImages(context.Background())
+		}
+		st.Reachable = true
+		st.Schedulable = true
+		st.Error = ""
+		st.Info = info
+		st.Failures = 0
+		st.Headroom = -1
+		if max, err := strconv.Atoi(config.Get("node.max_containers")); err == nil && max > 0 {
+			if st.Headroom = max - info.Containers; st.Headroom < 0 {
+				st.Headroom = 0
+			}
+		}
+	}
+	return []NodeStatus{*st}
+}
+
+// Nodes returns status of container engine nodes. A health check is
+// performed if no check was performed before.
+func (br *Broker) Nodes(ctx context.Context) []NodeStatus {
+	m := br.nodes
+	m.mu.Lock()
+	checked := !m.status.LastCheck.IsZero()
+	st := m.status
+	m.mu.Unlock()
+
+	if !checked {
+		return br.CheckNodes(ctx)
+	}
+	return []NodeStatus{st}
+}
+
+// StartHealthCheck checks health of container engine nodes periodically
+// until the context is canceled. The interval is configured by the
+// "node.health_interval" key.
+func (br *Broker) StartHealthCheck(ctx context.Context) {
+	interval := defaultHealthInterval
+	if d, err := time.ParseDuration(config.Get("node.health_interval")); err == nil && d > 0 {
+		interval = d
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			br.CheckNodes(ctx)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// CheckSchedulable returns an error if no node is available to create
+// new containers.
+func (br *Broker) CheckSchedulable() error {
+	m := br.nodes
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.status.Schedulable {
+		return NoSchedulableNodeError{}
+	}
+	return nil
+}
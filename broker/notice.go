@@ -0,0 +1,71 @@
+package broker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/auth/userdb"
+)
+
+// Active notices are sent with every API response and rendered in every
+// console page, so they are cached for a short time to avoid a database
+// query per request. Other servers see changes after the cache expired.
+const noticeCacheTTL = 30 * time.Second
+
+type noticeCache struct {
+	mu      sync.Mutex
+	notices []*userdb.Notice
+	expires time.Time
+}
+
+// Notices returns notices that should be shown to users now. Errors are
+// logged and no notices are returned, as notices are not essential.
+func (br *Broker) Notices() []*userdb.Notice {
+	c := &br.notices
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.After(c.expires) {
+		notices, err := br.Users.ActiveNotices()
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to load notices")
+		}
+		c.notices, c.expires = notices, now.Add(noticeCacheTTL)
+	}
+
+	// scheduled notices may start or expire before the cache expired
+	var active []*userdb.Notice
+	for _, n := range c.notices {
+		if n.Active(now) {
+			active = append(active, n)
+		}
+	}
+	return active
+}
+
+// CreateNotice creates a notice shown to all users.
+func (br *Broker) CreateNotice(n *userdb.Notice) error {
+	if err := br.Users.CreateNotice(n); err != nil {
+		return err
+	}
+	br.invalidateNotices()
+	return nil
+}
+
+// RemoveNotice removes a notice.
+func (br *Broker) RemoveNotice(id string) error {
+	if err := br.Users.RemoveNotice(id); err != nil {
+		return err
+	}
+	br.invalidateNotices()
+	return nil
+}
+
+func (br *Broker) invalidateNotices() {
+	br.notices.mu.Lock()
+	br.notices.expires = time.Time{}
+	br.notices.mu.Unlock()
+}
@@ -0,0 +1,62 @@
+package broker
+
+import (
+	"bytes"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/config/defaults"
+)
+
+// notifyLocked notifies the user that the account is locked due to too many
+// failed login attempts. The notification is mailed to users named by email
+// address if a SMTP server is configured.
+func (br *Broker) notifyLocked(name string, until time.Time) {
+	logrus.WithField("user", name).Warnf("Account locked until %s due to too many failed login attempts", until)
+	if !strings.Contains(name, "@") {
+		return
+	}
+
+	subject := "Your Cloudway account has been locked"
+	body := fmt.Sprintf("There were too many failed login attempts to your account %s.\n\n"+
+		"The account is locked until %s. If you didn't try to login, someone may be "+
+		"guessing your password, please contact the administrator.\n",
+		name, until.Format(time.RFC1123))
+
+	go func() {
+		if err := sendMail(name, subject, body); err != nil {
+			logrus.WithError(err).Warnf("Failed to send notification to %s", name)
+		}
+	}()
+}
+
+// sendMail sends a plain text mail with SMTP settings from the current
+// configuration. Does nothing if no SMTP server is configured.
+func sendMail(to, subject, body string) error {
+	host := config.Get("smtp.host")
+	if host == "" {
+		return nil
+	}
+	port := config.GetOrDefault("smtp.port", "25")
+	from := config.GetOrDefault("smtp.from", "Cloudway <daemon@"+defaults.Domain()+">")
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(body)
+
+	sender := from
+	if i := strings.LastIndex(from, "<"); i >= 0 {
+		sender = strings.TrimSuffix(from[i+1:], ">")
+	}
+
+	auth := smtp.PlainAuth("", config.Get("smtp.username"), config.Get("smtp.password"), host)
+	return smtp.SendMail(host+":"+port, auth, sender, []string{to}, msg.Bytes())
+}
@@ -1,6 +1,7 @@
 package broker
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -8,6 +9,7 @@ import (
 	"sort"
 
 	"github.com/cloudway/platform/hub"
+	"github.com/cloudway/platform/pkg/archive"
 	"github.com/cloudway/platform/pkg/manifest"
 )
 
@@ -99,8 +101,9 @@ func (br *UserBroker) getPluginInfoWithNames(tag string) (service string, plugin
 	return
 }
 
-// InstallPlugin installs a user defined plugin.
-func (br *UserBroker) InstallPlugin(ar io.Reader) error {
+// InstallPlugin installs a user defined plugin. The plugin signature is
+// verified against the trust store, if configured.
+func (br *UserBroker) InstallPlugin(ar io.Reader, signature string) error {
 	if br.Namespace() == "" {
 		return NoNamespaceError(br.User.Basic().Name)
 	}
@@ -116,7 +119,17 @@ func (br *UserBroker) InstallPlugin(ar io.Reader) error {
 	if err != nil {
 		return err
 	}
-	return br.Hub.InstallPlugin(br.Namespace(), tempfile.Name())
+	if err = hub.VerifyPlugin(tempfile.Name(), signature, false); err != nil {
+		return err
+	}
+	if err = br.Hub.InstallPlugin(br.Namespace(), tempfile.Name()); err != nil {
+		return err
+	}
+	if meta, err := archive.ReadManifest(tempfile.Name()); err == nil {
+		meta.Tag = br.Namespace() + "/" + meta.Name + ":" + meta.Version
+		br.PrepullImage(context.Background(), meta)
+	}
+	return nil
 }
 
 // RemovePlugin removes a user defined plugin.
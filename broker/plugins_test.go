@@ -67,7 +67,7 @@ var _ = Describe("Plugins", func() {
 		broker.Hub = originHub
 		config.Set("hub.dir", hubdir)
 		os.RemoveAll(testhubdir)
-		Ω(broker.RemoveUser(TESTUSER)).Should(Succeed())
+		Ω(broker.RemoveUser(TESTUSER, true)).Should(Succeed())
 	})
 
 	var preparePlugin = func(meta *manifest.Plugin) (path string, err error) {
@@ -161,7 +161,7 @@ var _ = Describe("Plugins", func() {
 		}
 		tw.Close()
 
-		return br.InstallPlugin(buf)
+		return br.InstallPlugin(buf, "")
 	}
 
 	var getTags = func(plugins []*manifest.Plugin) []string {
@@ -0,0 +1,77 @@
+package broker
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/pkg/conf"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// imagePuller pulls plugin base images in background, so that the first
+// application creation on a node doesn't stall for pulling images.
+type imagePuller struct {
+	mu      sync.Mutex
+	pulling map[string]bool
+}
+
+func newImagePuller() *imagePuller {
+	return &imagePuller{pulling: make(map[string]bool)}
+}
+
+// start marks the image as being pulled, returns false if the image is
+// already being pulled.
+func (p *imagePuller) start(image string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pulling[image] {
+		return false
+	}
+	p.pulling[image] = true
+	return true
+}
+
+func (p *imagePuller) done(image string) {
+	p.mu.Lock()
+	delete(p.pulling, image)
+	p.mu.Unlock()
+}
+
+// prepullEnabled returns false if image pre-pulling is disabled by the
+// "hub.prepull" configuration.
+func prepullEnabled() bool {
+	enabled, ok := conf.BoolStrings[strings.ToLower(config.Get("hub.prepull"))]
+	return enabled || !ok
+}
+
+// PrepullImages pulls base images of all system plugins in background.
+// It's performed when the container engine becomes reachable.
+func (br *Broker) PrepullImages(ctx context.Context) {
+	if !prepullEnabled() {
+		return
+	}
+	for _, meta := range br.Hub.ListPlugins("", "") {
+		br.PrepullImage(ctx, meta)
+	}
+}
+
+// PrepullImage pulls the base image of the plugin in background. It's
+// performed after the plugin installed or upgraded.
+func (br *Broker) PrepullImage(ctx context.Context, meta *manifest.Plugin) {
+	image := meta.BaseImage
+	if image == "" || !prepullEnabled() || !br.pulls.start(image) {
+		return
+	}
+
+	go func() {
+		defer br.pulls.done(image)
+		logrus.Debugf("Pulling base image %s of plugin %s", image, meta.Tag)
+		if err := br.PullImage(ctx, image, nil); err != nil {
+			logrus.WithError(err).Warnf("Failed to pull base image %s of plugin %s", image, meta.Tag)
+		}
+	}()
+}
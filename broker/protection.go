@@ -0,0 +1,49 @@
+package broker
+
+import (
+	"github.com/cloudway/platform/auth/userdb"
+)
+
+// SetProtection marks or unmarks an application as protected. Destructive
+// operations on a protected application, such as removing, scaling down
+// and restoring data, must be confirmed explicitly.
+func (br *UserBroker) SetProtection(name string, protected bool) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
+	user := br.User.Basic()
+	app := user.Applications[name]
+	if app == nil {
+		return ApplicationNotFoundError(name)
+	}
+	if app.Protected == protected {
+		return nil
+	}
+
+	app, err := br.Users.ModifyApplication(user.Name, name, func(app *userdb.Application) error {
+		app.Protected = protected
+		return nil
+	})
+	if err == nil {
+		user.Applications[name] = app
+	}
+	return err
+}
+
+// CheckProtection returns an error if the application is protected and the
+// operation is not confirmed with the application name.
+func (br *UserBroker) CheckProtection(name, confirm string) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
+	app := br.User.Basic().Applications[name]
+	if app == nil {
+		return ApplicationNotFoundError(name)
+	}
+	if app.Protected && confirm != name {
+		return ApplicationProtectedError(name)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package broker
+
+import (
+	"net/url"
+
+	"github.com/cloudway/platform/config"
+)
+
+// ProxyFeature is a set of features supported by a proxy driver beyond
+// routing requests to backends. Proxy drivers register their features by
+// the scheme of the proxy URL, so the broker can refuse operations that
+// can't be applied by the configured proxy.
+type ProxyFeature int
+
+const (
+	// ProxyMaintenance serves the maintenance page for applications in
+	// maintenance mode.
+	ProxyMaintenance ProxyFeature = 1 << iota
+
+	// ProxyRedirect redirects old application hosts to new URLs after
+	// applications or namespaces renamed.
+	ProxyRedirect
+
+	// ProxyWeights splits traffic between backends by weights for canary
+	// releases.
+	ProxyWeights
+)
+
+func (f ProxyFeature) String() string {
+	switch f {
+	case ProxyMaintenance:
+		return "maintenance mode"
+	case ProxyRedirect:
+		return "redirects"
+	case ProxyWeights:
+		return "traffic weights"
+	default:
+		return "the feature"
+	}
+}
+
+var proxyFeatures = make(map[string]ProxyFeature)
+
+// RegisterProxyFeatures registers features supported by the proxy driver
+// of the given scheme.
+func RegisterProxyFeatures(scheme string, features ProxyFeature) {
+	proxyFeatures[scheme] = features
+}
+
+// checkProxyFeature returns an error if the configured proxy doesn't
+// support the feature.
+func checkProxyFeature(feature ProxyFeature) error {
+	u, err := url.Parse(config.Get("proxy.url"))
+	if err != nil || proxyFeatures[u.Scheme]&feature != feature {
+		return UnsupportedProxyFeatureError(feature)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// Images of application containers are published to the repository
+// configured by "build.image_repository" after the build phase, such as
+// "registry.example.com:5000/cloudway", so other nodes can pull the build
+// without building again. Images are tagged by the build artifact ID in
+// the form of "<repository>/<namespace>/<name>:<artifact>". Credentials of
+// the registry are configured in the "registry:<server>" section.
+func imageRepository() string {
+	return strings.TrimSuffix(config.Get("build.image_repository"), "/")
+}
+
+// buildImageRef returns the image reference of the build artifact published
+// to the registry.
+func buildImageRef(repository, name, namespace, id string) string {
+	return fmt.Sprintf("%s/%s/%s:%s", repository, namespace, name, id)
+}
+
+// publishBuild publishes the image of the application container built for
+// the artifact if the image repository is configured, and saves the image
+// reference in the artifact. Failures are written to the log but not
+// returned, as the deployment is already done.
+func (br *Broker) publishBuild(ctx context.Context, name, namespace, id string, log *serverlog.ServerLog) {
+	repository := imageRepository()
+	if repository == "" || id == "" {
+		return
+	}
+
+	err := br.publishImage(ctx, name, namespace, id, repository, log)
+	if err != nil {
+		fmt.Fprintf(log.Stderr(), "Failed to publish build image: %v\n", err)
+		logrus.WithError(err).Warnf("Failed to publish build image of %s-%s", name, namespace)
+	}
+}
+
+func (br *Broker) publishImage(ctx context.Context, name, namespace, id, repository string, log *serverlog.ServerLog) error {
+	artifact, err := readArtifact(name, namespace, id)
+	if err != nil {
+		return err
+	}
+	containers, err := br.FindApplications(ctx, name, namespace)
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return ApplicationNotFoundError(name)
+	}
+
+	ref := buildImageRef(repository, name, namespace, id)
+	fmt.Fprintf(log.Stdout(), "Publishing build image %s\n", ref)
+	if err = br.PublishImage(ctx, containers[0].ID(), ref, log.Stdout()); err != nil {
+		return err
+	}
+
+	artifact.Image = ref
+	return writeArtifactMeta(artifact)
+}
@@ -0,0 +1,147 @@
+package broker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/go-units"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
+)
+
+// DiskUsage describes the disk space used by a container. Size is the
+// size of files created or changed in the container, and Data is the
+// size of the data directory.
+type DiskUsage struct {
+	ID      string
+	Service string
+	Size    int64
+	Data    int64
+}
+
+type byService []*DiskUsage
+
+func (a byService) Len() int           { return len(a) }
+func (a byService) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byService) Less(i, j int) bool { return a[i].Service < a[j].Service }
+
+// DiskQuota returns the maximum disk space in bytes that can be used by
+// all containers of an application, as configured by the "app.disk_quota"
+// option. Zero means no limit.
+func DiskQuota() int64 {
+	quota := config.Get("app.disk_quota")
+	if quota == "" {
+		return 0
+	}
+	size, err := units.RAMInBytes(quota)
+	if err != nil {
+		logrus.WithError(err).Warnf("Invalid disk quota: %s", quota)
+		return 0
+	}
+	return size
+}
+
+// NamespaceDiskQuota returns the disk quota of applications in the
+// namespace. The quota assigned to the user owning the namespace overrides
+// the default quota.
+func (br *Broker) NamespaceDiskQuota(namespace string) int64 {
+	if user, err := br.Users.FindByNamespace(namespace); err == nil && user.Basic().DiskQuota > 0 {
+		return user.Basic().DiskQuota
+	}
+	return DiskQuota()
+}
+
+// checkDiskQuota refuses to deploy or start the application if its
+// containers use more disk space than the quota.
+func (br *Broker) checkDiskQuota(ctx context.Context, name, namespace string) error {
+	quota := br.NamespaceDiskQuota(namespace)
+	if quota <= 0 {
+		return nil
+	}
+
+	cs, err := br.FindAll(ctx, name, namespace)
+	if err != nil {
+		return err
+	}
+
+	usage, err := totalDiskUsage(ctx, cs)
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to get disk usage of application %s", name)
+		return nil
+	}
+	if usage > quota {
+		return DiskQuotaExceededError{Name: name, Usage: usage, Quota: quota}
+	}
+	return nil
+}
+
+func totalDiskUsage(ctx context.Context, cs []container.Container) (int64, error) {
+	var total int64
+	err := Parallel(cs, func(c container.Container) error {
+		size, err := c.DiskUsage(ctx)
+		atomic.AddInt64(&total, size)
+		return err
+	})
+	return total, err
+}
+
+// DiskUsage returns the disk space used by all containers in the
+// application.
+func (br *UserBroker) DiskUsage(name string) ([]*DiskUsage, error) {
+	cs, err := br.FindAll(br.ctx, name, br.Namespace())
+	if err != nil {
+		return nil, err
+	}
+	if len(cs) == 0 {
+		return nil, ApplicationNotFoundError(name)
+	}
+
+	var mu sync.Mutex
+	usage := make([]*DiskUsage, 0, len(cs))
+	err = Parallel(cs, func(c container.Container) error {
+		var err error
+		u := &DiskUsage{ID: c.ID(), Service: c.ServiceName()}
+		if u.Size, err = c.DiskUsage(br.ctx); err != nil {
+			return err
+		}
+		if u.Data, err = dataUsage(br.ctx, c); err != nil {
+			return err
+		}
+		mu.Lock()
+		usage = append(usage, u)
+		mu.Unlock()
+		return nil
+	})
+
+	sort.Sort(byService(usage))
+	return usage, err
+}
+
+// dataUsage returns the size in bytes of the data directory in the container.
+func dataUsage(ctx context.Context, c container.Container) (int64, error) {
+	var out bytes.Buffer
+	opts := container.ExecOptions{
+		User:      c.User(),
+		Stdout:    &out,
+		Timeout:   listFilesTimeout,
+		MaxOutput: maxListOutput,
+	}
+	if err := c.ExecWithOptions(ctx, opts, "du", "-sk", c.DataDir()); err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(out.String())
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("Unexpected output from du: %q", out.String())
+	}
+	kb, err := strconv.ParseInt(fields[0], 10, 64)
+	return kb * 1024, err
+}
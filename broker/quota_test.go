@@ -0,0 +1,74 @@
+package broker_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
+)
+
+var _ = Describe("Disk quota", func() {
+	const dataSize = 1024 * 1024
+
+	var user = userdb.BasicUser{
+		Name:      TESTUSER,
+		Namespace: NAMESPACE,
+	}
+	var ub *br.UserBroker
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		ub = broker.NewUserBroker(&user, context.Background())
+		_, _, err := ub.CreateApplication(container.CreateOptions{Name: "test"}, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		config.Set("app.disk_quota", "")
+		Expect(ub.RemoveApplication("test")).To(Succeed())
+		Expect(broker.RemoveUser(TESTUSER, true)).To(Succeed())
+	})
+
+	var totalSize = func() int64 {
+		usage, err := ub.DiskUsage("test")
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		var total int64
+		for _, u := range usage {
+			total += u.Size
+		}
+		return total
+	}
+
+	var writeData = func() {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		ExpectWithOffset(1, tw.WriteHeader(&tar.Header{Name: "big", Mode: 0644, Size: dataSize})).To(Succeed())
+		_, err := tw.Write(make([]byte, dataSize))
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		ExpectWithOffset(1, tw.Close()).To(Succeed())
+		ExpectWithOffset(1, ub.UploadFiles("test", "", "data", &buf, nil)).To(Succeed())
+	}
+
+	It("should count application data in disk usage", func() {
+		before := totalSize()
+		writeData()
+		Expect(totalSize()).To(BeNumerically(">=", before+dataSize))
+	})
+
+	It("should refuse to start application exceeding the quota", func() {
+		writeData()
+		config.Set("app.disk_quota", "512k")
+		err := ub.StartApplication("test", nil)
+		Expect(err).To(BeAssignableToTypeOf(br.DiskQuotaExceededError{}))
+
+		config.Set("app.disk_quota", "")
+		Expect(ub.StartApplication("test", nil)).To(Succeed())
+	})
+})
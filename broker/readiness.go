@@ -0,0 +1,66 @@
+package broker
+
+import (
+	"context"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/scm"
+)
+
+// The time each dependency has to respond to the readiness check.
+const readinessTimeout = 5 * time.Second
+
+// Readiness checks connectivity to the user database, the container engine
+// and the SCM concurrently. Errors are logged rather than reported, since
+// the readiness endpoint is not authenticated.
+func (br *Broker) Readiness(ctx context.Context) *types.Readiness {
+	checks := []struct {
+		name  string
+		check func(context.Context) error
+	}{
+		{"userdb", func(context.Context) error { return br.Users.Ping() }},
+		{"docker", func(ctx context.Context) error { _, err := br.ServerVersion(ctx); return err }},
+		{"scm", func(context.Context) error { return scm.Ping(br.SCM()) }},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	result := &types.Readiness{
+		Ready:        true,
+		Dependencies: make([]types.DependencyStatus, len(checks)),
+	}
+	done := make(chan int, len(checks))
+	for i, c := range checks {
+		go func(i int, name string, check func(context.Context) error) {
+			start := time.Now()
+			errc := make(chan error, 1)
+			go func() { errc <- check(ctx) }()
+
+			var err error
+			select {
+			case err = <-errc:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+
+			status := types.DependencyStatus{Name: name, Status: "ok"}
+			status.Latency = int64(time.Since(start) / time.Millisecond)
+			if err != nil {
+				logrus.WithError(err).Warnf("Readiness check of %s failed", name)
+				status.Status = "unavailable"
+			}
+			result.Dependencies[i] = status
+			done <- i
+		}(i, c.name, c.check)
+	}
+	for range checks {
+		if i := <-done; result.Dependencies[i].Status != "ok" {
+			result.Ready = false
+		}
+	}
+	return result
+}
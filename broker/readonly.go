@@ -0,0 +1,47 @@
+package broker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudway/platform/config"
+)
+
+// The read-only flag is stored in a file under the installation root, so
+// that it is shared by all server processes and survives restarts. The
+// file contains the message shown to users.
+func readOnlyFile() string {
+	return filepath.Join(config.RootDir, "var", "readonly")
+}
+
+// SetReadOnly puts the platform into read-only mode. All operations that
+// modify the platform state are rejected with the given message until
+// ClearReadOnly is called.
+func SetReadOnly(message string) error {
+	filename := readOnlyFile()
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, []byte(message+"\n"), 0644)
+}
+
+// ClearReadOnly turns off the read-only mode.
+func ClearReadOnly() error {
+	err := os.Remove(readOnlyFile())
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return err
+}
+
+// CheckReadOnly returns a ReadOnlyError if the platform is in read-only
+// mode, otherwise returns nil.
+func CheckReadOnly() error {
+	data, err := ioutil.ReadFile(readOnlyFile())
+	if err != nil {
+		return nil
+	}
+	return ReadOnlyError(strings.TrimSpace(string(data)))
+}
@@ -0,0 +1,196 @@
+package broker
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// ReconcileApplication compares the desired state of the application
+// recorded in the user database, that is plugins, scaling and environment
+// variables, with actual containers, and repairs the drift. Missing
+// containers, such as lost after a node crash, are recreated, and stale
+// environment variables are updated. Actions taken are reported to the
+// server log. Returns the number of actions taken.
+func (br *UserBroker) ReconcileApplication(name string, log *serverlog.ServerLog) (int, error) {
+	if err := br.Refresh(); err != nil {
+		return 0, err
+	}
+
+	user := br.User.Basic()
+	app := user.Applications[name]
+	if app == nil {
+		return 0, ApplicationNotFoundError(name)
+	}
+
+	unlock, err := br.lockApp(name, user.Namespace, "reconcile")
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	cs, err := br.FindAll(br.ctx, name, user.Namespace)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		actions   int
+		created   []container.Container
+		framework *manifest.Plugin
+		frameCs   []container.Container
+		names     []string
+		missing   []*manifest.Plugin
+	)
+
+	// find plugins without containers
+	for _, tag := range app.Plugins {
+		p, err := br.Hub.GetPluginInfo(tag)
+		if err != nil {
+			return actions, err
+		}
+		if p.IsFramework() {
+			framework = p
+			continue
+		}
+		found := false
+		for _, c := range cs {
+			if c.Category().IsService() && pluginName(c.PluginTag()) == pluginName(p.Tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fmt.Fprintf(log, "Recreating missing service %s\n", p.Tag)
+			names, missing = append(names, ""), append(missing, p)
+		}
+	}
+	for _, c := range cs {
+		if c.Category().IsFramework() {
+			frameCs = append(frameCs, c)
+		}
+	}
+
+	opts := container.CreateOptions{
+		Name:      name,
+		Namespace: user.Namespace,
+		Secret:    app.Secret,
+		Hosts:     app.Hosts,
+		Labels:    app.Labels,
+		Env:       app.Env,
+		Log:       log,
+	}
+
+	if len(missing) != 0 {
+		cs, err := br.createContainers(opts, names, missing)
+		created = append(created, cs...)
+		if err != nil {
+			return actions, err
+		}
+		actions += len(missing)
+	}
+
+	// restore the scaling of the framework
+	scaling := app.Scaling
+	if scaling == 0 {
+		scaling = len(frameCs)
+	}
+	if scaling == 0 {
+		scaling = 1
+	}
+
+	switch {
+	case len(frameCs) == 0 && framework != nil:
+		// all framework containers are lost, recreate them and deploy the
+		// application from the repository
+		fmt.Fprintf(log, "Recreating %d missing %s containers\n", scaling, framework.Tag)
+		opts.Scaling = scaling
+		cs, err := br.createContainers(opts, []string{""}, []*manifest.Plugin{framework})
+		created = append(created, cs...)
+		if err != nil {
+			return actions, err
+		}
+		if err = br.deploy(br.ctx, name, user.Namespace, "", log); err != nil {
+			return actions, err
+		}
+		actions++
+
+	case len(frameCs) < scaling:
+		fmt.Fprintf(log, "Scaling up from %d to %d containers\n", len(frameCs), scaling)
+		cs, err := br.scaleUp(frameCs[0], scaling, app)
+		created = append(created, cs...)
+		if err != nil {
+			return actions, err
+		}
+		actions++
+
+	case len(frameCs) > scaling:
+		fmt.Fprintf(log, "Scaling down from %d to %d containers\n", len(frameCs), scaling)
+		if err = br.scaleDown(frameCs, len(frameCs)-scaling); err != nil {
+			return actions, err
+		}
+		frameCs = frameCs[len(frameCs)-scaling:]
+		actions++
+	}
+
+	// update stale environment variables, new containers are created with
+	// the desired environment
+	for _, c := range frameCs {
+		for k, v := range app.Env {
+			if cur, err := c.Getenv(br.ctx, k); err == nil && cur == v {
+				continue
+			}
+			fmt.Fprintf(log, "Updating environment variable %s in container %s\n", k, c.ID())
+			if err = c.Setenv(br.ctx, k, v); err != nil {
+				return actions, err
+			}
+			actions++
+		}
+	}
+
+	if err = br.StartContainers(created, log); err != nil {
+		return actions, err
+	}
+
+	if actions == 0 {
+		fmt.Fprintln(log, "The application is up to date")
+	} else {
+		br.notifyApp(ApplicationReconciled, name, user.Namespace, map[string]string{"actions": strconv.Itoa(actions)})
+	}
+	return actions, nil
+}
+
+// SaveEnv records environment variables of the application to be restored
+// by reconciliation. Variables are removed if remove is true.
+func (br *UserBroker) SaveEnv(name string, env map[string]string, remove bool) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
+	user := br.User.Basic()
+	if user.Applications[name] == nil {
+		return ApplicationNotFoundError(name)
+	}
+
+	app, err := br.Users.ModifyApplication(user.Name, name, func(app *userdb.Application) error {
+		if app.Env == nil {
+			app.Env = make(map[string]string)
+		}
+		for k, v := range env {
+			if remove {
+				delete(app.Env, k)
+			} else {
+				app.Env[k] = v
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		user.Applications[name] = app
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package broker
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/config/defaults"
+)
+
+// RedirectSection is the configuration section that contains redirects
+// from old application hosts to new URLs after the application or namespace
+// renamed, in the form of "host = url,expiry". The expiry time is in
+// RFC3339 format.
+const RedirectSection = "proxy-redirect"
+
+const defaultRedirectTTL = 7 * 24 * time.Hour
+
+// ParseRedirect parses a redirect configuration value.
+func ParseRedirect(value string) (target string, expiry time.Time, err error) {
+	i := strings.LastIndex(value, ",")
+	if i == -1 {
+		return "", expiry, fmt.Errorf("Invalid redirect: %s", value)
+	}
+	expiry, err = time.Parse(time.RFC3339, strings.TrimSpace(value[i+1:]))
+	return strings.TrimSpace(value[:i]), expiry, err
+}
+
+// addRedirects adds redirects from old hosts to new URLs, which expire
+// after the grace period configured by "proxy.redirect_ttl". Expired
+// redirects are removed at the same time. Nothing is added if the
+// configured proxy doesn't support redirects.
+func addRedirects(redirects map[string]string) error {
+	if err := checkProxyFeature(ProxyRedirect); err != nil {
+		return err
+	}
+
+	ttl := defaultRedirectTTL
+	if d, err := time.ParseDuration(config.Get("proxy.redirect_ttl")); err == nil {
+		ttl = d
+	}
+
+	now := time.Now()
+	changes := make(map[string]string)
+	for host, value := range config.GetSection(RedirectSection) {
+		if _, expiry, err := ParseRedirect(value); err != nil || expiry.Before(now) {
+			changes[RedirectSection+"."+host] = ""
+		}
+	}
+	if ttl > 0 {
+		expiry := now.Add(ttl).UTC().Format(time.RFC3339)
+		for host, target := range redirects {
+			changes[RedirectSection+"."+host] = target + "," + expiry
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return config.Update(changes, nil)
+}
+
+// appHost returns the default host name of the application, under the
+// domain delegated to the namespace if any.
+func appHost(name, namespace, domain string) string {
+	return defaults.AppHost(name, namespace, domain)
+}
+
+// appURL returns the default URL of the application, which shares the
+// scheme and port with the API URL.
+func appURL(name, namespace, domain string) string {
+	scheme, port := "http", ""
+	if u, err := url.Parse(defaults.ApiURL()); err == nil {
+		scheme = u.Scheme
+		if i := strings.IndexRune(u.Host, ':'); i != -1 {
+			port = u.Host[i:]
+		}
+	}
+	return scheme + "://" + appHost(name, namespace, domain) + port
+}
@@ -0,0 +1,33 @@
+package broker
+
+import (
+	"github.com/cloudway/platform/auth/userdb"
+)
+
+// ListRegistries returns the registry credentials of the user. Passwords
+// are included and must not be sent to clients.
+func (br *UserBroker) ListRegistries() ([]*userdb.Registry, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	registries := br.User.Basic().Registries
+	if registries == nil {
+		registries = []*userdb.Registry{}
+	}
+	return registries, nil
+}
+
+// AddRegistry saves the credentials of a private registry, which are used
+// to pull images of applications in the namespace of the user.
+func (br *UserBroker) AddRegistry(server, username, password string) (*userdb.Registry, error) {
+	reg := &userdb.Registry{Server: server, Username: username, Password: password}
+	if err := br.Users.AddRegistry(br.User.Basic().Name, reg); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+// RemoveRegistry removes the credentials of a private registry.
+func (br *UserBroker) RemoveRegistry(server string) error {
+	return br.Users.RemoveRegistry(br.User.Basic().Name, server)
+}
@@ -0,0 +1,75 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cloudway/platform/config"
+)
+
+// Application names and namespaces are part of application host names
+// under the platform domain, so names of subdomains used by the platform
+// itself are reserved. The reserved names can be configured with the
+// comma separated "app.reserved_names" and "user.reserved_namespaces"
+// options, which replace the default list.
+var defaultReservedNames = []string{
+	"www", "api", "admin", "console", "dashboard", "mail", "webmail",
+	"smtp", "imap", "pop", "pop3", "ftp", "ns", "ns1", "ns2", "dns", "mx",
+	"git", "scm", "ssh", "registry", "hub", "proxy", "static", "assets",
+	"cdn", "status", "docs", "help", "support", "blog", "cloudway",
+}
+
+// ReservedNameError is returned when an application or namespace name is
+// reserved by the platform.
+type ReservedNameError struct {
+	Kind string // "application" or "namespace"
+	Name string
+}
+
+func (e ReservedNameError) Error() string {
+	return fmt.Sprintf("The %s name '%s' is reserved", e.Kind, e.Name)
+}
+
+func (e ReservedNameError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// IsReservedName returns true if the application name is reserved.
+func IsReservedName(name string) bool {
+	return isReserved(config.Get("app.reserved_names"), name)
+}
+
+// IsReservedNamespace returns true if the namespace is reserved.
+func IsReservedNamespace(namespace string) bool {
+	return isReserved(config.Get("user.reserved_namespaces"), namespace)
+}
+
+func isReserved(list, name string) bool {
+	names := defaultReservedNames
+	if list = strings.TrimSpace(list); list != "" {
+		names = strings.Split(list, ",")
+	}
+	for _, n := range names {
+		if strings.ToLower(strings.TrimSpace(n)) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkApplicationName returns an error if the application name is reserved.
+func checkApplicationName(name string) error {
+	if IsReservedName(name) {
+		return ReservedNameError{"application", name}
+	}
+	return nil
+}
+
+// checkNamespaceName returns an error if the namespace is reserved.
+func checkNamespaceName(namespace string) error {
+	if IsReservedNamespace(namespace) {
+		return ReservedNameError{"namespace", namespace}
+	}
+	return nil
+}
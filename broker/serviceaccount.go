@@ -0,0 +1,42 @@
+package broker
+
+import (
+	"github.com/cloudway/platform/auth/userdb"
+)
+
+// CreateServiceAccount creates a service account bound to an existing
+// namespace, or an existing application in the namespace, and returns the
+// token of the service account.
+func (br *Broker) CreateServiceAccount(sa *userdb.ServiceAccount) (string, error) {
+	owner, err := br.Users.FindByNamespace(sa.Namespace)
+	if userdb.IsUserNotFound(err) {
+		return "", NamespaceNotFoundError(sa.Namespace)
+	}
+	if err != nil {
+		return "", err
+	}
+	if sa.Application != "" && owner.Basic().Applications[sa.Application] == nil {
+		return "", ApplicationNotFoundError(sa.Application)
+	}
+	return br.Users.CreateServiceAccount(sa)
+}
+
+// VerifyServiceAccount returns the service account with the token, and the
+// owner of the namespace the service account is bound to, on behalf of
+// whom requests are performed.
+func (br *Broker) VerifyServiceAccount(token string) (*userdb.BasicUser, *userdb.ServiceAccount, error) {
+	sa, err := br.Users.FindServiceAccount(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sa == nil {
+		return nil, nil, InvalidTokenError{}
+	}
+
+	owner, err := br.Users.FindByNamespace(sa.Namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	basic := owner.Basic()
+	return &userdb.BasicUser{Name: basic.Name, Namespace: basic.Namespace}, sa, nil
+}
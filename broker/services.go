@@ -0,0 +1,210 @@
+package broker
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/errors"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// Standalone services, such as a database shared by applications, are
+// created in the namespace independent of applications and have their own
+// lifecycle. Containers of a standalone service are named after the
+// service, so a standalone service cannot have the same name as an
+// application in the namespace. Applications bind to a standalone service
+// by linking the service.
+
+// GetStandaloneServices returns names of standalone services in the
+// namespace of the user.
+func (br *UserBroker) GetStandaloneServices() ([]string, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	user := br.User.Basic()
+	names := make([]string, 0, len(user.Services))
+	for name := range user.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// GetStandaloneService returns the standalone service and its containers.
+func (br *UserBroker) GetStandaloneService(name string) (*userdb.StandaloneService, []container.Container, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, nil, err
+	}
+	svc := br.User.Basic().Services[name]
+	if svc == nil {
+		return nil, nil, ServiceNotFoundError(name)
+	}
+	cs, err := br.FindAll(br.ctx, name, br.Namespace())
+	return svc, cs, err
+}
+
+// CreateStandaloneService creates a service in the namespace of the user
+// from the service plugin, and starts the service.
+func (br *UserBroker) CreateStandaloneService(name, tag string, log *serverlog.ServerLog) (svc *userdb.StandaloneService, err error) {
+	if err = br.Refresh(); err != nil {
+		return nil, err
+	}
+
+	user := br.User.Basic()
+	if user.Namespace == "" {
+		return nil, NoNamespaceError(user.Name)
+	}
+	if err = checkApplicationName(name); err != nil {
+		return nil, err
+	}
+	if user.Applications[name] != nil {
+		return nil, ApplicationExistError{name, user.Namespace}
+	}
+	if user.Services[name] != nil {
+		return nil, ServiceExistError{name, user.Namespace}
+	}
+
+	n, p, err := br.getPluginInfoWithNames(tag)
+	if err != nil {
+		return nil, err
+	}
+	if !p.IsService() {
+		return nil, fmt.Errorf("'%s' is not a service plugin", tag)
+	}
+
+	unlock, err := br.lockApp(name, user.Namespace, "service creation")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	secret, err := generateSharedSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	// purge leftover containers
+	if leftovers, err := br.FindAll(br.ctx, name, user.Namespace); err == nil {
+		for _, c := range leftovers {
+			br.notify(ContainerDestroyed, c, c.Destroy(br.ctx))
+		}
+	}
+
+	opts := container.CreateOptions{
+		Name:      name,
+		Namespace: user.Namespace,
+		Secret:    secret,
+		Log:       log,
+	}
+	containers, err := br.createContainers(opts, []string{n}, []*manifest.Plugin{p})
+	if err == nil {
+		svc = &userdb.StandaloneService{CreatedAt: time.Now(), Plugin: p.Tag, Secret: secret}
+		err = br.Users.Update(user.Name, userdb.Args{"services." + name: svc})
+	}
+	if err != nil {
+		for _, c := range containers {
+			br.notify(ContainerDestroyed, c, c.Destroy(br.ctx))
+		}
+		return nil, err
+	}
+
+	if user.Services == nil {
+		user.Services = make(map[string]*userdb.StandaloneService)
+	}
+	user.Services[name] = svc
+	br.notifyApp(ServiceCreated, name, user.Namespace, map[string]string{"plugin": p.Tag})
+
+	return svc, br.StartContainers(containers, log)
+}
+
+// RemoveStandaloneService removes the standalone service and its data. The
+// service cannot be removed while applications are linked to it.
+func (br *UserBroker) RemoveStandaloneService(name string) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
+	user := br.User.Basic()
+	if user.Services[name] == nil {
+		return ServiceNotFoundError(name)
+	}
+	if err := checkLinks(user.Applications, name, ""); err != nil {
+		return err
+	}
+
+	unlock, err := br.lockApp(name, user.Namespace, "service removal")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var errors errors.Errors
+
+	cs, err := br.FindAll(br.ctx, name, user.Namespace)
+	if err != nil {
+		return err
+	}
+	errors.Add(Parallel(cs, func(c container.Container) error {
+		return br.notify(ContainerDestroyed, c, c.Destroy(br.ctx))
+	}))
+
+	services := make(map[string]*userdb.StandaloneService)
+	for n, svc := range user.Services {
+		if n != name {
+			services[n] = svc
+		}
+	}
+	if err = br.Users.Update(user.Name, userdb.Args{"services": services}); err != nil {
+		errors.Add(err)
+	} else {
+		user.Services = services
+		br.notifyApp(ServiceRemoved, name, user.Namespace, nil)
+	}
+	return errors.Err()
+}
+
+// StartStandaloneService starts containers of the standalone service.
+func (br *UserBroker) StartStandaloneService(name string, log *serverlog.ServerLog) error {
+	return br.controlStandaloneService(name, "start", func(c container.Container) error {
+		return br.notify(ContainerStarted, c, c.Start(br.ctx, log))
+	})
+}
+
+// RestartStandaloneService restarts containers of the standalone service.
+func (br *UserBroker) RestartStandaloneService(name string, log *serverlog.ServerLog) error {
+	return br.controlStandaloneService(name, "restart", func(c container.Container) error {
+		return br.notify(ContainerStarted, c, c.Restart(br.ctx, log))
+	})
+}
+
+// StopStandaloneService stops containers of the standalone service.
+func (br *UserBroker) StopStandaloneService(name string) error {
+	return br.controlStandaloneService(name, "stop", func(c container.Container) error {
+		return br.notify(ContainerStopped, c, c.Stop(br.ctx))
+	})
+}
+
+func (br *UserBroker) controlStandaloneService(name, op string, fn func(container.Container) error) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+	if br.User.Basic().Services[name] == nil {
+		return ServiceNotFoundError(name)
+	}
+
+	unlock, err := br.lockApp(name, br.Namespace(), op)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cs, err := br.FindAll(br.ctx, name, br.Namespace())
+	if err != nil {
+		return err
+	}
+	return Parallel(cs, fn)
+}
@@ -0,0 +1,75 @@
+package broker_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+)
+
+var _ = Describe("Standalone services", func() {
+	var (
+		user = userdb.BasicUser{Name: TESTUSER, Namespace: NAMESPACE}
+		ctx  = context.Background()
+	)
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(broker.RemoveUser(TESTUSER, true)).To(Succeed())
+	})
+
+	It("should create and remove standalone services", func() {
+		ub := broker.NewUserBroker(&user, ctx)
+		_, err := ub.CreateStandaloneService("shared", "mockdb", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ub.GetStandaloneServices()).To(Equal([]string{"shared"}))
+
+		_, cs, err := ub.GetStandaloneService("shared")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs).To(HaveLen(1))
+		Expect(cs[0].Category().IsService()).To(BeTrue())
+
+		Expect(ub.RemoveStandaloneService("shared")).To(Succeed())
+		Expect(ub.GetStandaloneServices()).To(BeEmpty())
+		cs, err = broker.FindAll(ctx, "shared", NAMESPACE)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs).To(BeEmpty())
+	})
+
+	It("should not share names with applications", func() {
+		ub := broker.NewUserBroker(&user, ctx)
+		_, err := ub.CreateStandaloneService("shared", "mockdb", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, _, err = ub.CreateApplication(container.CreateOptions{Name: "shared"}, []string{"mock"})
+		Expect(err).To(Equal(br.ServiceExistError{"shared", NAMESPACE}))
+		_, err = ub.CreateStandaloneService("shared", "mockdb", nil)
+		Expect(err).To(Equal(br.ServiceExistError{"shared", NAMESPACE}))
+	})
+
+	It("should outlive applications linking the service", func() {
+		ub := broker.NewUserBroker(&user, ctx)
+		_, err := ub.CreateStandaloneService("shared", "mockdb", nil)
+		Expect(err).NotTo(HaveOccurred())
+		_, _, err = ub.CreateApplication(container.CreateOptions{Name: "web"}, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, cs, err := ub.GetStandaloneService("shared")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ub.LinkService("web", "shared", cs[0].ServiceName())).To(Succeed())
+
+		_, ok := ub.RemoveStandaloneService("shared").(br.ServiceLinkedError)
+		Expect(ok).To(BeTrue())
+
+		Expect(ub.RemoveApplication("web")).To(Succeed())
+		Expect(ub.GetStandaloneServices()).To(Equal([]string{"shared"}))
+		Expect(ub.RemoveStandaloneService("shared")).To(Succeed())
+	})
+})
@@ -0,0 +1,47 @@
+package broker
+
+import (
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/scm"
+)
+
+// DeployStatusKey identifies the deployment status attached to commits.
+const DeployStatusKey = "cloudway-deploy"
+
+// resolveCommit returns the commit hash to be deployed, or an empty string
+// if the SCM doesn't support commit statuses.
+func (br *Broker) resolveCommit(namespace, name, branch string) string {
+	r, ok := br.SCM().(scm.StatusReporter)
+	if !ok {
+		return ""
+	}
+	commit, err := r.ResolveCommit(namespace, name, branch)
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to resolve deployment commit of %s-%s", name, namespace)
+		return ""
+	}
+	return commit
+}
+
+// setDeployStatus reports deployment status on the deployed commit. Failure
+// to set status is logged but doesn't affect the deployment.
+func (br *Broker) setDeployStatus(namespace, name, commit, state, description string) {
+	if commit == "" {
+		return
+	}
+
+	status := &scm.CommitStatus{
+		State:       state,
+		Key:         DeployStatusKey,
+		Name:        "Cloudway deployment",
+		URL:         defaults.ConsoleURL() + "/applications/" + name,
+		Description: description,
+	}
+
+	r := br.SCM().(scm.StatusReporter)
+	if err := r.SetCommitStatus(namespace, name, commit, status); err != nil {
+		logrus.WithError(err).Warnf("Failed to set deployment status of %s-%s", name, namespace)
+	}
+}
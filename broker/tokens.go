@@ -0,0 +1,52 @@
+package broker
+
+import (
+	"github.com/cloudway/platform/auth/userdb"
+)
+
+// ListAccessTokens returns personal access tokens of the user.
+func (br *UserBroker) ListAccessTokens() ([]*userdb.AccessToken, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	tokens := br.User.Basic().AccessTokens
+	if tokens == nil {
+		tokens = []*userdb.AccessToken{}
+	}
+	return tokens, nil
+}
+
+// CreateAccessToken creates a personal access token with the scopes, and
+// returns the token which can't be retrieved again.
+func (br *UserBroker) CreateAccessToken(label string, scopes []string) (string, *userdb.AccessToken, error) {
+	return br.Users.CreateAccessToken(br.User.Basic().Name, label, scopes)
+}
+
+// RemoveAccessToken revokes the personal access token with the given ID.
+func (br *UserBroker) RemoveAccessToken(id string) error {
+	return br.Users.RemoveAccessToken(br.User.Basic().Name, id)
+}
+
+// VerifyAccessToken returns the owner of the personal access token. Tokens
+// without the "all" scope are restricted as service accounts of the owner
+// with the scopes of the token, which is also returned.
+func (br *Broker) VerifyAccessToken(token string) (*userdb.BasicUser, *userdb.ServiceAccount, error) {
+	user, t, err := br.Users.AuthorizeAccessToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, InvalidTokenError{}
+	}
+
+	owner := &userdb.BasicUser{Name: user.Name, Namespace: user.Namespace}
+	if t.HasScope(userdb.ScopeAll) {
+		return owner, nil, nil
+	}
+	sa := &userdb.ServiceAccount{
+		Name:      user.Name,
+		Namespace: user.Namespace,
+		Scopes:    t.Scopes,
+	}
+	return owner, sa, nil
+}
@@ -0,0 +1,320 @@
+package broker
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// Removed applications are kept in the trash for the retention period
+// configured by "app.trash_retention", and can be restored before the
+// retention period expired. The trash is disabled if the retention
+// period is zero.
+const (
+	defaultTrashRetention = 72 * time.Hour
+	trashPurgeInterval    = time.Hour
+)
+
+const (
+	trashMetaFile = "app.json"
+	trashRepoFile = "repo.tar"
+	trashDataFile = "data.tar.gz"
+)
+
+// TrashedApplication describes an application in the trash.
+type TrashedApplication struct {
+	Name        string
+	Namespace   string
+	DeletedAt   time.Time
+	ExpiresAt   time.Time
+	HasData     bool
+	Application *userdb.Application
+}
+
+func trashRetention() time.Duration {
+	if d, err := time.ParseDuration(config.Get("app.trash_retention")); err == nil {
+		return d
+	}
+	return defaultTrashRetention
+}
+
+func trashRoot() string {
+	return filepath.Join(config.RootDir, "var", "trash")
+}
+
+func (br *UserBroker) trashDir(name string) string {
+	return filepath.Join(trashRoot(), br.User.Basic().Name, name)
+}
+
+// moveToTrash archives the application repository and data into the
+// trash.
+func (br *UserBroker) moveToTrash(name string, app *userdb.Application, retention time.Duration) (err error) {
+	dir := br.trashDir(name)
+	tmpdir := dir + ".tmp"
+	os.RemoveAll(tmpdir)
+	if err = os.MkdirAll(tmpdir, 0700); err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	now := time.Now()
+	meta := TrashedApplication{
+		Name:        name,
+		Namespace:   br.Namespace(),
+		DeletedAt:   now,
+		ExpiresAt:   now.Add(retention),
+		Application: app,
+	}
+	if err = br.archiveApplication(tmpdir, &meta); err != nil {
+		return err
+	}
+
+	// replace the previously removed application with the same name
+	os.RemoveAll(dir)
+	return os.Rename(tmpdir, dir)
+}
+
+// archiveApplication writes the application repository, data and metadata
+// into the directory. The application data can only be dumped from running
+// containers, so the data is not archived if the application is stopped.
+func (br *UserBroker) archiveApplication(dir string, meta *TrashedApplication) error {
+	name := meta.Name
+
+	// archive the repository
+	repo, err := br.Download(name)
+	if err != nil {
+		return err
+	}
+	err = writeTrashFile(filepath.Join(dir, trashRepoFile), repo, false)
+	repo.Close()
+	if err != nil {
+		return err
+	}
+
+	// archive the application data
+	if data, er := br.Dump(name); er != nil {
+		logrus.WithError(er).Warnf("Failed to dump data of %s, the data is not archived", name)
+	} else {
+		err = writeTrashFile(filepath.Join(dir, trashDataFile), data, true)
+		data.Close()
+		if err != nil {
+			return err
+		}
+		meta.HasData = true
+	}
+
+	metadata, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, trashMetaFile), metadata, 0600)
+}
+
+func writeTrashFile(filename string, r io.Reader, compress bool) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if !compress {
+		_, err = io.Copy(f, r)
+		return err
+	}
+
+	zw := gzip.NewWriter(f)
+	if _, err = io.Copy(zw, r); err == nil {
+		err = zw.Close()
+	}
+	return err
+}
+
+func readTrashMeta(dir string) (*TrashedApplication, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, trashMetaFile))
+	if err != nil {
+		return nil, err
+	}
+	meta := new(TrashedApplication)
+	if err = json.Unmarshal(data, meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// GetTrash returns applications in the trash of the user, sorted by name.
+// Expired applications are not returned.
+func (br *UserBroker) GetTrash() ([]*TrashedApplication, error) {
+	dirs, err := ioutil.ReadDir(filepath.Join(trashRoot(), br.User.Basic().Name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var result []*TrashedApplication
+	for _, fi := range dirs {
+		meta, err := readTrashMeta(filepath.Join(trashRoot(), br.User.Basic().Name, fi.Name()))
+		if err == nil && meta.ExpiresAt.After(now) {
+			result = append(result, meta)
+		}
+	}
+	sort.Sort(trashByName(result))
+	return result, nil
+}
+
+type trashByName []*TrashedApplication
+
+func (a trashByName) Len() int           { return len(a) }
+func (a trashByName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+func (a trashByName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+func (br *UserBroker) getTrashed(name string) (*TrashedApplication, error) {
+	meta, err := readTrashMeta(br.trashDir(name))
+	if err != nil || meta.ExpiresAt.Before(time.Now()) {
+		return nil, ApplicationNotFoundError(name)
+	}
+	return meta, nil
+}
+
+// RestoreApplication recreates a removed application from the trash. The
+// repository and data are restored, containers are started, and the
+// application is removed from the trash.
+func (br *UserBroker) RestoreApplication(name string, log *serverlog.ServerLog) (app *userdb.Application, containers []container.Container, err error) {
+	meta, err := br.getTrashed(name)
+	if err != nil {
+		return
+	}
+
+	dir := br.trashDir(name)
+	app, containers, err = br.restoreArchived(dir, meta, log)
+	if err == nil {
+		os.RemoveAll(dir)
+	}
+	return
+}
+
+// restoreArchived recreates an application from the repository and data
+// archived in the directory, and starts containers.
+func (br *UserBroker) restoreArchived(dir string, meta *TrashedApplication, log *serverlog.ServerLog) (app *userdb.Application, containers []container.Container, err error) {
+	name := meta.Name
+	opts := container.CreateOptions{
+		Name:    name,
+		Secret:  meta.Application.Secret,
+		Hosts:   meta.Application.Hosts,
+		Labels:  meta.Application.Labels,
+		Scaling: 1,
+		Log:     log,
+	}
+
+	populate := func(opts *container.CreateOptions, _ *manifest.Plugin) error {
+		f, err := os.Open(filepath.Join(dir, trashRepoFile))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		return br.SCM().Populate(opts.Namespace, opts.Name, f, fi.Size())
+	}
+
+	app, containers, err = br.createApplication(opts, meta.Application.Plugins, populate)
+	if err != nil {
+		return
+	}
+
+	// data can only be restored to running containers
+	if err = br.StartContainers(containers, log); err != nil {
+		return
+	}
+
+	if meta.HasData {
+		log.Progress("restore", 1, 1, "Restoring application data")
+		var f *os.File
+		if f, err = os.Open(filepath.Join(dir, trashDataFile)); err == nil {
+			err = br.Restore(name, f, log)
+			f.Close()
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// PurgeTrash permanently removes an application from the trash.
+func (br *UserBroker) PurgeTrash(name string) error {
+	if _, err := br.getTrashed(name); err != nil {
+		return err
+	}
+	return os.RemoveAll(br.trashDir(name))
+}
+
+// StartTrashCleaner removes expired applications from the trash
+// periodically until the context is canceled. Only the elected leader of
+// API servers removes expired applications.
+func (br *Broker) StartTrashCleaner(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+		for {
+			if br.elected("trash", 3*trashPurgeInterval) {
+				purgeExpiredTrash()
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func purgeExpiredTrash() {
+	users, err := ioutil.ReadDir(trashRoot())
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	for _, u := range users {
+		dirs, err := ioutil.ReadDir(filepath.Join(trashRoot(), u.Name()))
+		if err != nil {
+			continue
+		}
+		for _, fi := range dirs {
+			dir := filepath.Join(trashRoot(), u.Name(), fi.Name())
+			meta, err := readTrashMeta(dir)
+			if err != nil {
+				// leftover of an incomplete removal
+				if fi.ModTime().Add(trashPurgeInterval).Before(now) {
+					os.RemoveAll(dir)
+				}
+				continue
+			}
+			if meta.ExpiresAt.Before(now) {
+				logrus.Infof("Purging removed application %s-%s from trash", meta.Name, meta.Namespace)
+				if err = os.RemoveAll(dir); err != nil {
+					logrus.WithError(err).Warnf("Failed to purge %s", dir)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package broker
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/archive"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+const defaultUpgradeTimeout = 2 * time.Minute
+
+// UpgradeOptions controls how application containers are upgraded.
+type UpgradeOptions struct {
+	// The number of applications upgraded in a batch, default to 1.
+	BatchSize int
+
+	// The number of applications upgraded in the first batch as canary.
+	// The upgrade is paused after the canary batch and must be resumed
+	// to upgrade remaining applications.
+	Canary int
+
+	// The maximum time to wait for an upgraded container to be running,
+	// default to 2 minutes.
+	Timeout time.Duration
+
+	// Called after the canary batch upgraded and the upgrade paused. It
+	// can resume or abort the upgrade interactively.
+	AfterCanary func(u *Upgrader)
+}
+
+// Upgrader upgrades support files in application containers. Applications
+// are upgraded in batches, and containers must be running after upgraded
+// before proceeding to the next batch.
+type Upgrader struct {
+	container.Engine
+	opts UpgradeOptions
+
+	mu      sync.Mutex
+	paused  bool
+	aborted bool
+	resumec chan struct{}
+}
+
+func NewUpgrader(engine container.Engine, opts UpgradeOptions) *Upgrader {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultUpgradeTimeout
+	}
+	return &Upgrader{Engine: engine, opts: opts}
+}
+
+// Pause pauses the upgrade after the current batch completed.
+func (u *Upgrader) Pause() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.paused && !u.aborted {
+		u.paused = true
+		u.resumec = make(chan struct{})
+	}
+}
+
+// Resume resumes a paused upgrade.
+func (u *Upgrader) Resume() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.paused {
+		u.paused = false
+		close(u.resumec)
+	}
+}
+
+// Abort stops the upgrade after the current batch completed.
+func (u *Upgrader) Abort() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.aborted = true
+	if u.paused {
+		u.paused = false
+		close(u.resumec)
+	}
+}
+
+// Paused returns true if the upgrade is paused.
+func (u *Upgrader) Paused() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.paused
+}
+
+// wait blocks while the upgrade is paused.
+func (u *Upgrader) wait(ctx context.Context) error {
+	for {
+		u.mu.Lock()
+		aborted, paused, resumec := u.aborted, u.paused, u.resumec
+		u.mu.Unlock()
+
+		if aborted {
+			return UpgradeAbortedError{}
+		}
+		if !paused {
+			return nil
+		}
+
+		select {
+		case <-resumec:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Run upgrades all application containers, progress is reported to the
+// server log.
+func (u *Upgrader) Run(ctx context.Context, log *serverlog.ServerLog) error {
+	ar, err := makeSupportArchive()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(ar)
+
+	containers, err := u.FindInNamespace(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	// group containers by applications
+	apps := make(map[string][]container.Container)
+	for _, c := range containers {
+		key := c.Name() + "-" + c.Namespace()
+		apps[key] = append(apps[key], c)
+	}
+
+	names := make([]string, 0, len(apps))
+	for name := range apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var batches [][]string
+	if u.opts.Canary > 0 && u.opts.Canary < len(names) {
+		batches = append(batches, names[:u.opts.Canary])
+		names = names[u.opts.Canary:]
+	}
+	for len(names) > 0 {
+		n := u.opts.BatchSize
+		if n > len(names) {
+			n = len(names)
+		}
+		batches = append(batches, names[:n])
+		names = names[n:]
+	}
+
+	total, done := len(apps), 0
+	for i, batch := range batches {
+		if err := u.wait(ctx); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(log, "Upgrading batch %d/%d: %s\n", i+1, len(batches), strings.Join(batch, ", "))
+		for _, app := range batch {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := u.upgradeApp(app, apps[app], ar, log); err != nil {
+				return err
+			}
+			done++
+			log.Progress("upgrade", done, total, app)
+		}
+
+		if i == 0 && u.opts.Canary > 0 && len(batches) > 1 {
+			fmt.Fprintln(log, "Canary upgraded, the upgrade is paused until resumed")
+			u.Pause()
+			if u.opts.AfterCanary != nil {
+				u.opts.AfterCanary(u)
+			}
+		}
+	}
+
+	fmt.Fprintf(log, "%d applications upgraded\n", total)
+	return nil
+}
+
+// upgradeApp stops containers of the application, copies support files,
+// restarts containers and waits until all containers are running. The
+// containers are upgraded on a detached context so they are not left
+// stopped when the upgrade is canceled in the middle.
+func (u *Upgrader) upgradeApp(app string, cs []container.Container, ar string, log *serverlog.ServerLog) error {
+	ctx := context.Background()
+
+	logrus.Infof("upgrading application %s", app)
+	logError(container.ResolveServiceDependencies(cs))
+
+	for i, c := range cs {
+		logrus.Infof("upgrading container %s.%s-%s", c.ServiceName(), c.Name(), c.Namespace())
+		nc, err := upgradeContainer(ctx, c, ar, log)
+		if err != nil {
+			return UpgradeFailedError{App: app, Container: c.ID(), Err: err}
+		}
+		cs[i] = nc
+	}
+
+	for _, c := range cs {
+		if err := u.waitRunning(ctx, c); err != nil {
+			return UpgradeHealthError{App: app, Container: c.ID(), Err: err}
+		}
+	}
+	return nil
+}
+
+// upgradeContainer stops the container, patches support files and starts
+// the container again. The container is restarted even if patching failed.
+func upgradeContainer(ctx context.Context, c container.Container, ar string, log *serverlog.ServerLog) (container.Container, error) {
+	file, err := os.Open(ar)
+	if err != nil {
+		return c, err
+	}
+	defer file.Close()
+
+	if err = c.Stop(ctx); err != nil {
+		return c, err
+	}
+	if nc, perr := c.Patch(ctx, file); perr != nil {
+		err = perr
+	} else {
+		// containers with read-only root filesystem are recreated
+		c = nc
+	}
+	if serr := c.Start(ctx, log); err == nil {
+		err = serr
+	}
+	return c, err
+}
+
+// waitRunning waits until the container is running or failed.
+func (u *Upgrader) waitRunning(ctx context.Context, c container.Container) error {
+	ctx, cancel := context.WithTimeout(ctx, u.opts.Timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		// inspect again to get the latest container state
+		if c2, err := u.Inspect(ctx, c.ID()); err == nil {
+			switch state := c2.ActiveState(ctx); state {
+			case manifest.StateRunning:
+				return nil
+			case manifest.StateFailed, manifest.StateStopped:
+				return fmt.Errorf("container is %s", state)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func makeSupportArchive() (string, error) {
+	file, err := ioutil.TempFile("", "tmp")
+	if err != nil {
+		return "", err
+	}
+
+	tw := tar.NewWriter(file)
+	src := filepath.Join(config.RootDir, "sandbox")
+	err = archive.CopyFileTree(tw, "", src, nil, false)
+
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", err
+	} else {
+		tw.Close()
+		file.Close()
+		return file.Name(), nil
+	}
+}
+
+func logError(err error) {
+	if err != nil {
+		logrus.Error(err)
+	}
+}
+
+// Upgrade upgrades all application containers. Only one upgrade can be
+// performed at a time across all API servers, which can be paused, resumed
+// or aborted by other requests to the server performing the upgrade.
+func (br *Broker) Upgrade(ctx context.Context, opts UpgradeOptions, log *serverlog.ServerLog) error {
+	u := NewUpgrader(br.Engine, opts)
+
+	br.upgradeMu.Lock()
+	if br.upgrader != nil {
+		br.upgradeMu.Unlock()
+		return UpgradeInProgressError{}
+	}
+	br.upgrader = u
+	br.upgradeMu.Unlock()
+
+	release, ok, err := br.holdLease("upgrade", serverID, lockTTL)
+	if err == nil && !ok {
+		err = UpgradeInProgressError{}
+	}
+	if err != nil {
+		br.upgradeMu.Lock()
+		br.upgrader = nil
+		br.upgradeMu.Unlock()
+		return err
+	}
+	defer release()
+
+	defer func() {
+		br.upgradeMu.Lock()
+		br.upgrader = nil
+		br.upgradeMu.Unlock()
+	}()
+
+	return u.Run(ctx, log)
+}
+
+func (br *Broker) currentUpgrade() (*Upgrader, error) {
+	br.upgradeMu.Lock()
+	defer br.upgradeMu.Unlock()
+	if br.upgrader == nil {
+		return nil, NoUpgradeInProgressError{}
+	}
+	return br.upgrader, nil
+}
+
+// PauseUpgrade pauses the upgrade in progress.
+func (br *Broker) PauseUpgrade() error {
+	u, err := br.currentUpgrade()
+	if err == nil {
+		u.Pause()
+	}
+	return err
+}
+
+// ResumeUpgrade resumes the paused upgrade.
+func (br *Broker) ResumeUpgrade() error {
+	u, err := br.currentUpgrade()
+	if err == nil {
+		u.Resume()
+	}
+	return err
+}
+
+// AbortUpgrade aborts the upgrade in progress.
+func (br *Broker) AbortUpgrade() error {
+	u, err := br.currentUpgrade()
+	if err == nil {
+		u.Abort()
+	}
+	return err
+}
@@ -0,0 +1,334 @@
+package broker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/config"
+)
+
+// Resumable uploads let clients send large archives, such as repositories
+// and data dumps, in chunks over unstable connections. An upload is created
+// with the total size, chunks are appended at the current offset, and the
+// completed upload is consumed by the repository or data restore. Bytes
+// received before a connection broke are kept, so the client resumes from
+// the offset reported by the server.
+//
+// Uploads are kept in the "var/uploads" directory, which must be shared by
+// all API servers behind a load balancer. Incomplete uploads are removed
+// after "upload.expire" of inactivity.
+const (
+	uploadMetaExt        = ".json"
+	uploadDataExt        = ".data"
+	defaultUploadExpire  = 24 * time.Hour
+	uploadPurgeInterval  = time.Hour
+	uploadAppendLeaseTTL = 5 * time.Minute
+)
+
+var uploadIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Upload describes a resumable upload of an application archive.
+type Upload struct {
+	ID        string
+	Name      string
+	Namespace string
+	Size      int64
+	Offset    int64
+	CreatedBy string
+	UpdatedAt time.Time
+}
+
+// Complete returns true if all bytes of the upload are received.
+func (u *Upload) Complete() bool {
+	return u.Offset == u.Size
+}
+
+type UploadNotFoundError string
+
+func (e UploadNotFoundError) Error() string {
+	return fmt.Sprintf("Upload '%s' not found", string(e))
+}
+
+func (e UploadNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+// The UploadOffsetError indicates that a chunk doesn't start at the current
+// offset of the upload, the client should resume from the expected offset.
+type UploadOffsetError struct {
+	ID       string
+	Offset   int64
+	Expected int64
+}
+
+func (e UploadOffsetError) Error() string {
+	return fmt.Sprintf("Upload '%s' is at offset %d, not %d", e.ID, e.Expected, e.Offset)
+}
+
+func (e UploadOffsetError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+// The IncompleteUploadError indicates that an upload is consumed before
+// all bytes are received, or more bytes than the declared size are sent.
+type IncompleteUploadError struct {
+	ID     string
+	Reason string
+}
+
+func (e IncompleteUploadError) Error() string {
+	return fmt.Sprintf("Upload '%s' %s", e.ID, e.Reason)
+}
+
+func (e IncompleteUploadError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+func uploadRoot() string {
+	return filepath.Join(config.RootDir, "var", "uploads")
+}
+
+func uploadPath(namespace, id, ext string) string {
+	return filepath.Join(uploadRoot(), namespace, id+ext)
+}
+
+func uploadExpire() time.Duration {
+	if d, err := time.ParseDuration(config.Get("upload.expire")); err == nil && d > 0 {
+		return d
+	}
+	return defaultUploadExpire
+}
+
+// CreateUpload creates an empty upload of the given size for the
+// application.
+func (br *UserBroker) CreateUpload(name string, size int64) (*Upload, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	if br.User.Basic().Applications[name] == nil {
+		return nil, ApplicationNotFoundError(name)
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("Bad parameter: invalid upload size %d", size)
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	upload := &Upload{
+		ID:        hex.EncodeToString(b),
+		Name:      name,
+		Namespace: br.Namespace(),
+		Size:      size,
+		CreatedBy: br.User.Basic().Name,
+		UpdatedAt: time.Now(),
+	}
+
+	if err := os.MkdirAll(filepath.Join(uploadRoot(), upload.Namespace), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(uploadPath(upload.Namespace, upload.ID, uploadDataExt), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	if err = writeUpload(upload); err != nil {
+		removeUpload(upload.Namespace, upload.ID)
+		return nil, err
+	}
+	return upload, nil
+}
+
+// GetUpload returns the upload of the application.
+func (br *UserBroker) GetUpload(name, id string) (*Upload, error) {
+	return readUpload(name, br.Namespace(), id)
+}
+
+// AppendUpload writes the content to the upload at the given offset, which
+// must be the current offset of the upload. If the content is interrupted,
+// the bytes received are kept and the upload can be resumed from the new
+// offset.
+func (br *UserBroker) AppendUpload(name, id string, offset int64, content io.Reader) (*Upload, error) {
+	upload, err := readUpload(name, br.Namespace(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	release, ok, err := br.holdLease("upload:"+id, "append", uploadAppendLeaseTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, IncompleteUploadError{ID: id, Reason: "is being written by another request"}
+	}
+	defer release()
+
+	// re-read the upload since it may be appended while acquiring lease
+	if upload, err = readUpload(name, br.Namespace(), id); err != nil {
+		return nil, err
+	}
+	if offset != upload.Offset {
+		return nil, UploadOffsetError{ID: id, Offset: offset, Expected: upload.Offset}
+	}
+
+	f, err := os.OpenFile(uploadPath(upload.Namespace, id, uploadDataExt), os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err = f.Truncate(offset); err != nil {
+		return nil, err
+	}
+	if _, err = f.Seek(offset, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+
+	// read one more byte to detect the content exceeds the declared size
+	remaining := upload.Size - offset
+	n, copyErr := io.Copy(f, io.LimitReader(content, remaining+1))
+	if n > remaining {
+		n = remaining
+		copyErr = IncompleteUploadError{ID: id, Reason: "exceeds the declared size"}
+		f.Truncate(upload.Size)
+	}
+	if err = f.Sync(); err != nil {
+		return nil, err
+	}
+
+	upload.Offset += n
+	upload.UpdatedAt = time.Now()
+	if err = writeUpload(upload); err != nil {
+		return nil, err
+	}
+	if copyErr != nil {
+		logrus.WithError(copyErr).Debugf("Upload %s interrupted at offset %d", id, upload.Offset)
+		return upload, copyErr
+	}
+	return upload, nil
+}
+
+// OpenUpload opens the content of a completed upload.
+func (br *UserBroker) OpenUpload(name, id string) (io.ReadCloser, error) {
+	upload, err := readUpload(name, br.Namespace(), id)
+	if err != nil {
+		return nil, err
+	}
+	if !upload.Complete() {
+		reason := fmt.Sprintf("is incomplete, %d of %d bytes received", upload.Offset, upload.Size)
+		return nil, IncompleteUploadError{ID: id, Reason: reason}
+	}
+	return os.Open(uploadPath(upload.Namespace, id, uploadDataExt))
+}
+
+// RemoveUpload removes the upload of the application.
+func (br *UserBroker) RemoveUpload(name, id string) error {
+	if _, err := readUpload(name, br.Namespace(), id); err != nil {
+		return err
+	}
+	return removeUpload(br.Namespace(), id)
+}
+
+func readUpload(name, namespace, id string) (*Upload, error) {
+	if !uploadIDPattern.MatchString(id) {
+		return nil, UploadNotFoundError(id)
+	}
+	data, err := ioutil.ReadFile(uploadPath(namespace, id, uploadMetaExt))
+	if os.IsNotExist(err) {
+		return nil, UploadNotFoundError(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	upload := new(Upload)
+	if err = json.Unmarshal(data, upload); err != nil {
+		return nil, err
+	}
+	if upload.Name != name {
+		return nil, UploadNotFoundError(id)
+	}
+	return upload, nil
+}
+
+func writeUpload(upload *Upload) error {
+	metadata, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	// write to a temporary file and rename, so the metadata is never
+	// observed partially written by other servers
+	filename := uploadPath(upload.Namespace, upload.ID, uploadMetaExt)
+	if err = ioutil.WriteFile(filename+".tmp", metadata, 0600); err != nil {
+		return err
+	}
+	return os.Rename(filename+".tmp", filename)
+}
+
+func removeUpload(namespace, id string) error {
+	err := os.Remove(uploadPath(namespace, id, uploadMetaExt))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Remove(uploadPath(namespace, id, uploadDataExt))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// StartUploadCleaner removes expired uploads periodically until the context
+// is canceled. Only the elected leader of API servers removes uploads.
+func (br *Broker) StartUploadCleaner(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(uploadPurgeInterval)
+		defer ticker.Stop()
+		for {
+			if br.elected("uploads", 3*uploadPurgeInterval) {
+				purgeUploads()
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func purgeUploads() {
+	namespaces, err := ioutil.ReadDir(uploadRoot())
+	if err != nil {
+		return
+	}
+
+	expire := uploadExpire()
+	for _, ns := range namespaces {
+		files, err := ioutil.ReadDir(filepath.Join(uploadRoot(), ns.Name()))
+		if err != nil {
+			continue
+		}
+		for _, fi := range files {
+			id := strings.TrimSuffix(fi.Name(), uploadDataExt)
+			if id == fi.Name() || time.Since(fi.ModTime()) < expire {
+				continue
+			}
+			logrus.Infof("Removing expired upload %s of namespace %s", id, ns.Name())
+			if err := removeUpload(ns.Name(), id); err != nil {
+				logrus.WithError(err).Warnf("Failed to remove upload %s", id)
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package broker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
+)
+
+// RoleAdmin is the role of platform administrators, who are listed in the
+// "admin.users" option.
+const RoleAdmin = "admin"
+
+// Actions taken to import a user.
+const (
+	ImportCreate    = "create"
+	ImportUpdate    = "update"
+	ImportUnchanged = "unchanged"
+)
+
+// UserRecord describes a user in batch import and export.
+type UserRecord struct {
+	Name      string
+	Namespace string
+	DiskQuota int64
+	Roles     []string
+
+	// The plain text or bcrypt hashed password. A random password is
+	// generated for new users if empty, users can then recover their
+	// password or have it reset by an administrator.
+	Password string
+}
+
+type importUser struct {
+	userdb.BasicUser `bson:",inline"`
+	Email            string
+}
+
+// ImportUser creates the user described by the record, or updates an
+// existing user so that importing the same records again has no further
+// effect. The password of an existing user is never changed, and roles
+// are only granted, not revoked. The namespace of an existing user can
+// only be set if the user has no namespace. Nothing is changed if dryRun
+// is true, but the record is still validated. Returns the action taken.
+func (br *Broker) ImportUser(rec *UserRecord, dryRun bool) (action string, err error) {
+	rec.Name = strings.ToLower(strings.TrimSpace(rec.Name))
+	if rec.Name == "" {
+		return "", fmt.Errorf("The user name is required")
+	}
+	for _, role := range rec.Roles {
+		if role != RoleAdmin {
+			return "", fmt.Errorf("Unknown role: %s", role)
+		}
+	}
+
+	var user importUser
+	err = br.Users.Find(rec.Name, &user)
+	if userdb.IsUserNotFound(err) {
+		return ImportCreate, br.importNewUser(rec, dryRun)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	basic := &user.BasicUser
+	fields := userdb.Args{}
+	if rec.DiskQuota != 0 && rec.DiskQuota != basic.DiskQuota {
+		fields["diskquota"] = rec.DiskQuota
+	}
+	setNamespace := rec.Namespace != "" && rec.Namespace != basic.Namespace
+	if setNamespace && basic.Namespace != "" {
+		return "", fmt.Errorf("The user %s already has namespace %s", rec.Name, basic.Namespace)
+	}
+	grantAdmin := hasRole(rec.Roles, RoleAdmin) && !br.IsAdmin(basic)
+
+	if len(fields) == 0 && !setNamespace && !grantAdmin {
+		return ImportUnchanged, nil
+	}
+	if setNamespace {
+		if err = br.checkNamespaceFree(rec.Namespace); err != nil {
+			return "", err
+		}
+	}
+	if dryRun {
+		return ImportUpdate, nil
+	}
+
+	if len(fields) != 0 {
+		if err = br.Users.Update(rec.Name, fields); err != nil {
+			return "", err
+		}
+	}
+	if setNamespace {
+		if err = br.NewUserBroker(&user, context.Background()).CreateNamespace(rec.Namespace); err != nil {
+			return "", err
+		}
+	}
+	if grantAdmin {
+		if err = grantAdminRole(rec.Name); err != nil {
+			return "", err
+		}
+	}
+	return ImportUpdate, nil
+}
+
+func (br *Broker) importNewUser(rec *UserRecord, dryRun bool) error {
+	password := rec.Password
+	if password == "" {
+		b := make([]byte, 18)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		password = base64.RawURLEncoding.EncodeToString(b)
+	} else if !userdb.IsHashedPassword(password) {
+		if err := userdb.CheckPassword(password); err != nil {
+			return err
+		}
+	}
+
+	if rec.Namespace != "" {
+		if !namespacePattern.MatchString(rec.Namespace) {
+			return fmt.Errorf("Invalid namespace: %s", rec.Namespace)
+		}
+		if err := checkNamespaceName(rec.Namespace); err != nil {
+			return err
+		}
+		if err := br.checkNamespaceFree(rec.Namespace); err != nil {
+			return err
+		}
+	}
+	if dryRun {
+		return nil
+	}
+
+	user := &importUser{Email: rec.Name}
+	user.Name = rec.Name
+	user.Namespace = rec.Namespace
+	user.DiskQuota = rec.DiskQuota
+	if err := br.CreateUser(user, password); err != nil {
+		return err
+	}
+	if hasRole(rec.Roles, RoleAdmin) {
+		return grantAdminRole(rec.Name)
+	}
+	return nil
+}
+
+func (br *Broker) checkNamespaceFree(namespace string) error {
+	_, err := br.Users.FindByNamespace(namespace)
+	if err == nil {
+		return userdb.DuplicateNamespaceError(namespace)
+	}
+	if userdb.IsUserNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// ExportUsers returns records of all users ordered by name. Hashed
+// passwords are only included if requested.
+func (br *Broker) ExportUsers(withPasswords bool) ([]*UserRecord, error) {
+	var users []userdb.BasicUser
+	if err := br.Users.Search(userdb.Args{}, &users); err != nil {
+		return nil, err
+	}
+
+	records := make([]*UserRecord, len(users))
+	for i := range users {
+		user := &users[i]
+		rec := &UserRecord{
+			Name:      user.Name,
+			Namespace: user.Namespace,
+			DiskQuota: user.DiskQuota,
+		}
+		if br.IsAdmin(user) {
+			rec.Roles = []string{RoleAdmin}
+		}
+		if withPasswords {
+			rec.Password = string(user.Password)
+		}
+		records[i] = rec
+	}
+	sort.Sort(recordsByName(records))
+	return records, nil
+}
+
+type recordsByName []*UserRecord
+
+func (a recordsByName) Len() int           { return len(a) }
+func (a recordsByName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a recordsByName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func grantAdminRole(name string) error {
+	admins := config.Get("admin.users")
+	if admins != "" {
+		admins += ","
+	}
+	return config.Update(map[string]string{"admin.users": admins + name}, nil)
+}
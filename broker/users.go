@@ -2,13 +2,22 @@ package broker
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"sort"
 
 	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
 	"github.com/cloudway/platform/pkg/errors"
 )
 
 func (br *Broker) CreateUser(user userdb.User, password string) (err error) {
 	basic := user.Basic()
+	if basic.Namespace != "" {
+		if err = checkNamespaceName(basic.Namespace); err != nil {
+			return err
+		}
+	}
 
 	// create the user in the database
 	err = br.Users.Create(user, password)
@@ -18,7 +27,7 @@ func (br *Broker) CreateUser(user userdb.User, password string) (err error) {
 
 	// create the namespace in the SCM
 	if basic.Namespace != "" {
-		err = br.SCM.CreateNamespace(basic.Namespace)
+		err = br.SCM().CreateNamespace(basic.Namespace)
 		if err != nil {
 			br.Users.Remove(basic.Name)
 			return err
@@ -28,7 +37,10 @@ func (br *Broker) CreateUser(user userdb.User, password string) (err error) {
 	return nil
 }
 
-func (br *Broker) RemoveUser(username string) (err error) {
+// RemoveUser removes the user and the namespace of the user. Unless forced,
+// the user is not removed if any application exists in the namespace, and
+// NamespaceNotEmptyError is returned with names of the applications.
+func (br *Broker) RemoveUser(username string, force bool) (err error) {
 	ctx := context.Background()
 
 	var user userdb.BasicUser
@@ -37,6 +49,12 @@ func (br *Broker) RemoveUser(username string) (err error) {
 		return err
 	}
 
+	if !force {
+		if err = br.checkNamespaceEmpty(ctx, &user); err != nil {
+			return err
+		}
+	}
+
 	var errors errors.Errors
 
 	if user.Namespace != "" {
@@ -45,24 +63,57 @@ func (br *Broker) RemoveUser(username string) (err error) {
 		if err != nil {
 			errors.Add(err)
 		} else {
-			for _, c := range cs {
-				errors.Add(c.Destroy(ctx))
-			}
+			errors.Add(Parallel(cs, func(c container.Container) error {
+				return br.notify(ContainerDestroyed, c, c.Destroy(ctx))
+			}))
 		}
 
 		// remove the namespace from SCM
-		errors.Add(br.SCM.RemoveNamespace(user.Namespace))
+		errors.Add(br.SCM().RemoveNamespace(user.Namespace))
 
 		// remove the namespace from the plugin hub
 		br.Hub.RemoveNamespace(user.Namespace)
 	}
 
+	// remove applications in the trash
+	errors.Add(os.RemoveAll(filepath.Join(trashRoot(), user.Name)))
+
 	// remove user from user database
 	errors.Add(br.Users.Remove(user.Name))
 
 	return errors.Err()
 }
 
+// checkNamespaceEmpty returns NamespaceNotEmptyError if any application
+// record or container exists in the namespace of the user.
+func (br *Broker) checkNamespaceEmpty(ctx context.Context, user *userdb.BasicUser) error {
+	if user.Namespace == "" {
+		return nil
+	}
+
+	names := make(map[string]bool)
+	for name := range user.Applications {
+		names[name] = true
+	}
+	cs, err := br.FindInNamespace(ctx, user.Namespace)
+	if err != nil {
+		return err
+	}
+	for _, c := range cs {
+		names[c.Name()] = true
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	apps := make([]string, 0, len(names))
+	for name := range names {
+		apps = append(apps, name)
+	}
+	sort.Strings(apps)
+	return NamespaceNotEmptyError{Namespace: user.Namespace, Applications: apps}
+}
+
 func (br *Broker) GetUser(username string) (userdb.User, error) {
 	var user userdb.BasicUser
 	err := br.Users.Find(username, &user)
@@ -9,6 +9,8 @@ import (
 
 	"github.com/cloudway/platform/auth/userdb"
 	. "github.com/cloudway/platform/auth/userdb/matchers"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
 )
 
 var _ = Describe("Users", func() {
@@ -37,10 +39,31 @@ var _ = Describe("Users", func() {
 
 			By("Removing the user")
 
-			Expect(broker.RemoveUser(TESTUSER)).To(Succeed())
+			Expect(broker.RemoveUser(TESTUSER, true)).To(Succeed())
 			Expect(broker.Users.Find(TESTUSER, &user)).To(BeUserNotFound(TESTUSER))
 			Expect(path).NotTo(BeADirectory())
 		})
+
+		It("should not remove user with applications unless forced", func() {
+			newuser := userdb.BasicUser{
+				Name:      TESTUSER,
+				Namespace: NAMESPACE,
+			}
+			Expect(broker.CreateUser(&newuser, "test")).To(Succeed())
+
+			ub := broker.NewUserBroker(&newuser, context.Background())
+			_, _, err := ub.CreateApplication(container.CreateOptions{Name: "test"}, []string{"mock"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(broker.RemoveUser(TESTUSER, false)).To(Equal(br.NamespaceNotEmptyError{
+				Namespace:    NAMESPACE,
+				Applications: []string{"test"},
+			}))
+			Expect(broker.Users.Find(TESTUSER, &userdb.BasicUser{})).To(Succeed())
+
+			Expect(broker.RemoveUser(TESTUSER, true)).To(Succeed())
+			Expect(broker.Users.Find(TESTUSER, &userdb.BasicUser{})).To(BeUserNotFound(TESTUSER))
+		})
 	})
 
 	Describe("Refresh user broker", func() {
@@ -58,7 +81,7 @@ var _ = Describe("Users", func() {
 		})
 
 		AfterEach(func() {
-			broker.RemoveUser(TESTUSER)
+			broker.RemoveUser(TESTUSER, true)
 		})
 
 		It("should load fresh values from database", func() {
@@ -75,7 +98,7 @@ var _ = Describe("Users", func() {
 
 		It("should fail after removed user", func() {
 			br := broker.NewUserBroker(&user, context.Background())
-			Expect(br.RemoveUser(TESTUSER)).To(Succeed())
+			Expect(br.RemoveUser(TESTUSER, true)).To(Succeed())
 			Expect(br.Refresh()).To(BeUserNotFound(TESTUSER))
 		})
 	})
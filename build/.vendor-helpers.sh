@@ -30,7 +30,9 @@ clone() {
     echo -n 'clone, '
     case "$vcs" in
       git)
-        if [ ${#rev} -ne 40 ]; then
+        # tags and branches can be cloned shallowly, commit hashes,
+        # including abbreviated ones, need the full history
+        if ! [[ "$rev" =~ ^[0-9a-f]{7,40}$ ]]; then
           git clone --depth=1 --branch="$rev" --no-checkout "$url" "$target"
         else
           git clone --no-checkout "$url" "$target"
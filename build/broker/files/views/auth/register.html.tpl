@@ -19,6 +19,7 @@
             <input type="password" class="form-control" name="confirm_password" placeholder="确认密码" value="{{.confirmPassword}}" />
             {{with .errs}}{{with $errlist := index . "confirm_password"}}{{range $errlist}}<span class="help-block">{{.}}</span>{{end}}{{end}}{{end}}
           </div>
+          {{with .invite}}<input type="hidden" name="invite" value="{{.}}" />{{end}}
           <input type="hidden" name="{{.xsrfName}}" value="{{.xsrfToken}}" />
           <div class="row">
             <div class="col-md-offset-1 col-md-10">
@@ -13,6 +13,50 @@
   {{template "_appnav" .}}
 </div>
 
+<div class="panel panel-default">
+  <div class="panel-heading">部署状态</div>
+  <div class="table-responsive">
+  <table class="table">
+    <tr>
+      <th style="width:12em;">部署分支</th>
+      <td>
+        {{- with .app.Branch}}
+        <span class="label label-default"><i class="fa fa-code-fork"></i> {{.DisplayId}}</span>
+        {{- with .LatestCommit}} <code title="{{.}}">{{printf "%.8s" .}}</code>{{end}}
+        {{- else}}
+        <span class="text-muted">未知</span>
+        {{- end}}
+      </td>
+    </tr>
+    <tr>
+      <th>最近部署</th>
+      <td>
+        {{- with .app.LastDeployment}}
+        {{- if eq .State "success"}}<i class="fa fa-check-circle text-success"></i> 部署成功
+        {{- else if eq .State "failed"}}<i class="fa fa-times-circle text-danger"></i> 部署失败
+        {{- else}}<i class="fa fa-spinner fa-spin text-info"></i> 正在部署
+        {{- end}}
+        <span class="text-muted">
+          {{if .DeployedBy}}{{.DeployedBy}}{{else}}系统{{end}}
+          于 <span title="{{formatDate .StartedAt}}">{{humanDuration .StartedAt}}</span>
+        </span>
+        {{- else}}
+        <span class="text-muted">尚未部署</span>
+        {{- end}}
+      </td>
+    </tr>
+    <tr>
+      <th>资源限制</th>
+      <td>
+        磁盘配额 {{if .app.DiskQuota}}{{humanSize .app.DiskQuota}}{{else}}不限{{end}}，
+        内存 {{if .app.Resources.Memory}}{{humanSize .app.Resources.Memory}}{{else}}不限{{end}}，
+        CPU {{if .app.Resources.CPUs}}{{.app.Resources.CPUs}} 核{{else}}不限{{end}}
+      </td>
+    </tr>
+  </table>
+  </div>
+</div>
+
 <div class="panel panel-default">
   <div class="panel-heading">应用框架</div>
   <div class="table-responsive">
@@ -32,7 +76,7 @@
     {{- range .app.Frameworks}}
     <tr>
       <td>{{printf "%.12s" .ID}}</td>
-      <td><img class="plugin-logo" src="{{logo .PluginTag .Logo}}"/> {{.DisplayName}}</td>
+      <td><img class="plugin-logo" src="{{logo .PluginTag .Logo}}"/> {{.DisplayName}}{{with .Version}} <span class="text-muted">{{.}}</span>{{end}}</td>
       <td>{{.IP}}</td>
       <td>{{.Ports}}</td>
       <td><span id="{{.ID}}" class="label state state-{{.State}}">{{.State}}</span></td>
@@ -47,9 +91,11 @@
   </div>
   <div class="panel-footer" style="padding-top:5px; padding-bottom:5px;">
     <form id="scaling-form" class="form-inline" action="/applications/{{$name}}/scale" method="post">
+      <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
       <div class="form-group">
         <span class="form-control-static">弹性伸缩&nbsp;</span>
         <input id="scaling" type="hidden" name="scale" value="{{.app.Scale}}"/>
+        <input id="scaling-confirm" type="hidden" name="confirm" value=""/>
         <div class="btn-group">
           <button id="scaleup" class="btn btn-default btn-xs" {{if ge .app.Scale 10}}disabled="disabled"{{end}} type="button">
             <i class="fa fa-plus"></i>
@@ -59,6 +105,9 @@
           </button>
         </div>
         <span class="badge">{{.app.Scale}}</span>
+        {{- if .app.Protected}}
+        <i class="fa fa-lock text-muted" title="已开启删除保护"></i>
+        {{- end}}
       </div>
     </form>
   </div>
@@ -80,12 +129,13 @@
     {{- range .}}
     <tr>
       <td>{{printf "%.12s" .ID}}</td>
-      <td><img class="plugin-logo" src="{{logo .PluginTag .Logo}}"/> {{.DisplayName}}</td>
+      <td><img class="plugin-logo" src="{{logo .PluginTag .Logo}}"/> {{.DisplayName}}{{with .Version}} <span class="text-muted">{{.}}</span>{{end}}</td>
       <td>{{.IP}}</td>
       <td>{{.Ports}}</td>
       <td><span id="{{.ID}}" class="label state state-{{.State}}">{{.State}}</span></td>
       <td>
         <form class="form-inline" action="/applications/{{$name}}/services/{{.Name}}/delete" method="post">
+          <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
           <a href='/shell/{{printf "%.12s" .ID}}/open' class="btn btn-link" type="button" style="padding:0;margin:0;" title="终端">
             <i class="fa fa-tty"></i>
           </a>
@@ -151,6 +201,13 @@ $('#scaleup').on('click', function(e) {
   $('#scaling-form').trigger('submit')
 })
 $('#scaledown').on('click', function(e) {
+  {{- if .app.Protected}}
+  var confirm = window.prompt('应用已开启删除保护，请输入应用名称确认减少实例');
+  if (!confirm) {
+    return
+  }
+  $('#scaling-confirm').val(confirm)
+  {{- end}}
   $('#scaling').val(Number($('#scaling').val())-1)
   $('#scaling-form').trigger('submit')
 })
@@ -112,9 +112,23 @@ html, body {
 {{template "_select_plugin"}}
 
 <script>
+  // showFieldErrors marks invalid fields of the form with error messages
+  function showFieldErrors(fields) {
+    $('#form-div').removeClass('hidden');
+    $('#term-div').addClass('hidden');
+    $.each(fields, function(i, f) {
+      var group = $('#' + f.Field).closest('.form-group');
+      group.addClass('has-error');
+      group.append($('<span class="help-block field-error"></span>').text(f.Message));
+    });
+  }
+
   $('#create-form').submit(function(e) {
     e.preventDefault();
 
+    $('#create-form .form-group').removeClass('has-error');
+    $('#create-form .field-error').remove();
+
     var wsurl = '{{.ws}}?' + $('#create-form').serialize();
     var ws = new WebSocket(wsurl);
     var term, err
@@ -137,7 +151,10 @@ html, body {
       if (data.msg) {
         term.write(data.msg);
       }
-      if (data.err) {
+      if (data.fields) {
+        showFieldErrors(data.fields);
+        err = true;
+      } else if (data.err) {
         term.write("\x1b[31;1m" + data.err + "\x1b[0m\n");
         err = true;
       }
@@ -0,0 +1,81 @@
+{{define "pagetitle"}}应用控制台 - {{.app.Name}} - 数据{{end}}
+
+{{$name := .app.Name}}
+{{$service := .service}}
+{{$path := .path}}
+<div class="panel panel-default">
+  {{template "_appnav" .}}
+</div>
+
+{{- with .services}}
+<div class="panel panel-default">
+  <div class="panel-heading">磁盘用量</div>
+  <div class="table-responsive">
+  <table class="table">
+    <tr>
+      <th>服务</th>
+      <th style="width:12em;">数据大小</th>
+    </tr>
+    {{- range .}}
+    <tr>
+      <td><a href="/applications/{{$name}}/data?service={{.Name}}">{{.Label}}</a></td>
+      <td>{{humanSize .Size}}</td>
+    </tr>
+    {{- end}}
+  </table>
+  </div>
+</div>
+{{- end}}
+
+<div class="panel panel-default">
+  <div class="panel-heading">
+    <ol class="breadcrumb" style="margin:0;padding:0;background:none;">
+      <li><a href="/applications/{{$name}}/data?service={{$service}}">data</a></li>
+      {{- range .breadcrumbs}}
+      <li><a href="/applications/{{$name}}/data?service={{$service}}&path={{.Path}}">{{.Name}}</a></li>
+      {{- end}}
+      <li class="pull-right" style="list-style:none;">
+        <a href="/applications/{{$name}}/data/download?service={{$service}}&path={{$path}}" title="下载"><i class="fa fa-download"></i></a>
+      </li>
+    </ol>
+  </div>
+  <div class="table-responsive">
+  <table class="table">
+    <tr>
+      <th>名称</th>
+      <th style="width:10em;">大小</th>
+      <th style="width:12em;">修改时间</th>
+      <th style="width:2em;"></th>
+    </tr>
+    {{- range .files}}
+    <tr>
+      {{- if .IsDir}}
+      <td><i class="fa fa-folder-o"></i> <a href="/applications/{{$name}}/data?service={{$service}}&path={{if $path}}{{$path}}/{{end}}{{.Name}}">{{.Name}}</a></td>
+      <td>-</td>
+      {{- else}}
+      <td><i class="fa fa-file-o"></i> {{.Name}}</td>
+      <td>{{humanSize .Size}}</td>
+      {{- end}}
+      <td>{{formatDate .ModTime}}</td>
+      <td>
+        <a href="/applications/{{$name}}/data/download?service={{$service}}&path={{if $path}}{{$path}}/{{end}}{{.Name}}" title="下载"><i class="fa fa-download"></i></a>
+      </td>
+    </tr>
+    {{- else}}
+    <tr><td colspan="4">目录为空</td></tr>
+    {{- end}}
+  </table>
+  </div>
+  <div class="panel-body">
+    <form class="form-inline" action="/applications/{{$name}}/data/upload" method="post" enctype="multipart/form-data">
+      <div class="form-group">
+        <input type="file" name="file"/>
+      </div>
+      <input type="hidden" name="service" value="{{$service}}"/>
+      <input type="hidden" name="path" value="{{$path}}"/>
+      <input type="hidden" name="csrf_token" value="{{.csrf_token}}"/>
+      <button class="btn btn-success btn-sm" type="submit"><i class="fa fa-upload"></i> 上传文件</button>
+      <span class="help-block" style="display:inline;">文件大小不能超过 {{humanSize .max_upload_size}}</span>
+    </form>
+  </div>
+</div>
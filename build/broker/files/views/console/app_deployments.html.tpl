@@ -0,0 +1,65 @@
+{{define "pagetitle"}}应用控制台 - {{.app.Name}} - 部署历史{{end}}
+
+{{$name := .app.Name}}
+<div class="panel panel-default">
+  {{template "_appnav" .}}
+</div>
+
+<div class="panel panel-default">
+  <div class="panel-heading">部署历史</div>
+  <div class="panel-body">
+    {{- if .deployments}}
+    <ul class="deploy-timeline">
+      {{- range .deployments}}
+      <li class="deploy-{{.State}}">
+        <div class="deploy-marker">
+          {{- if eq .State "success"}}<i class="fa fa-check-circle text-success"></i>
+          {{- else if eq .State "failed"}}<i class="fa fa-times-circle text-danger"></i>
+          {{- else}}<i class="fa fa-spinner fa-spin text-info"></i>
+          {{- end}}
+        </div>
+        <div class="deploy-entry">
+          <div class="pull-right">
+            <a class="btn btn-link btn-sm" href="/applications/{{$name}}/deployments/{{.ID}}/log" target="_blank"><i class="fa fa-file-text-o"></i> 日志</a>
+            {{- if .CanRollback}}
+            <form action="/applications/{{$name}}/deployments/{{.ID}}/rollback" method="post" style="display:inline;"
+                  onsubmit="return confirm('确定要将应用回滚到此次部署吗？');">
+              <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
+              <button class="btn btn-default btn-sm" type="submit"><i class="fa fa-undo"></i> 回滚到此版本</button>
+            </form>
+            {{- end}}
+          </div>
+          <div>
+            <strong>
+              {{- if eq .Source "branch"}}从分支部署
+              {{- else if eq .Source "upload"}}上传部署
+              {{- else if eq .Source "artifact"}}部署构建产物
+              {{- else if eq .Source "rollback"}}回滚
+              {{- else}}{{.Source}}
+              {{- end}}
+            </strong>
+            {{- with .Branch}} <span class="label label-default"><i class="fa fa-code-fork"></i> {{.}}</span>{{end}}
+            {{- with .Commit}} <code title="{{.}}">{{printf "%.8s" .}}</code>{{end}}
+            {{- with .Version}} <span class="label label-info">{{.}}</span>{{end}}
+            {{- if .Current}} <span class="label label-success">当前版本</span>{{end}}
+          </div>
+          <div class="text-muted">
+            {{if .DeployedBy}}{{.DeployedBy}}{{else}}系统{{end}}
+            于 <span title="{{formatDate .StartedAt}}">{{humanDuration .StartedAt}}</span>
+            {{- if eq .State "running"}}开始部署，正在进行中
+            {{- else}}{{if eq .State "success"}}部署成功{{else}}部署失败{{end}}，耗时 {{.Elapsed}}
+            {{- end}}
+            {{- with .RollbackOf}}，回滚至 {{.}}{{end}}
+          </div>
+          {{- with .Error}}
+          <div class="text-danger">{{.}}</div>
+          {{- end}}
+        </div>
+      </li>
+      {{- end}}
+    </ul>
+    {{- else}}
+    <p class="text-muted">还没有部署记录</p>
+    {{- end}}
+  </div>
+</div>
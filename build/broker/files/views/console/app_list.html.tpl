@@ -1,10 +1,72 @@
 {{define "pagetitle"}}应用控制台 - 应用{{end}}
+{{define "prelude"}}
+<script type="text/javascript" src="/static/js/xterm.js"></script>
+<script type="text/javascript" src="/static/js/xterm/fit.js"></script>
+<link rel="stylesheet" href="/static/css/xterm.css" />
+<style>
+#group-modal .modal-dialog {
+  position: relative;
+  display: table;
+  overflow: auto;
+  width: auto;
+}
+#group-term {
+  width: 800px;
+  height: 450px;
+}
+</style>
+{{end}}
 
 <div class="row container">
-{{if .apps}}
-  {{range .apps}}
+{{if or .apps .label}}
+  <div class="row container">
+    <div class="col-md-10 col-lg-offset-1" style="margin-bottom:15px;">
+      <form class="form-inline" action="/applications" method="get">
+        <div class="form-group">
+          <label for="label">标签：</label>
+          <input type="text" id="label" name="label" class="form-control" placeholder="env=prod team" value="{{.label}}"/>
+        </div>
+        <div class="form-group">
+          <label for="group">分组：</label>
+          <select id="group" name="group" class="form-control">
+            <option value="">不分组</option>
+            {{- range .labelKeys}}
+            <option value="{{.}}"{{if eq . $.group}} selected{{end}}>{{.}}</option>
+            {{- end}}
+          </select>
+        </div>
+        <button class="btn btn-default" type="submit"><i class="fa fa-filter"></i> 筛选</button>
+        {{- if or .label .group}}
+        <a class="btn btn-link" href="/applications?group=">清除</a>
+        {{- end}}
+      </form>
+    </div>
+  </div>
+
+  {{range .groups}}
+  <form class="group-form">
+  <div class="row container">
+    <div class="col-md-10 col-lg-offset-1">
+      <h4 style="display:inline-block;">
+        {{- if not $.group}}全部应用
+        {{- else if .Label}}{{$.group}}={{.Label}}
+        {{- else}}未设置 {{$.group}}
+        {{- end}}
+        <small>({{len .Apps}})</small>
+      </h4>
+      <div class="btn-group btn-group-sm pull-right">
+        <button type="button" class="btn btn-default group-action" data-action="start"><i class="fa fa-play"></i> 启动</button>
+        <button type="button" class="btn btn-default group-action" data-action="stop"><i class="fa fa-stop"></i> 停止</button>
+        <button type="button" class="btn btn-default group-action" data-action="restart"><i class="fa fa-refresh"></i> 重启</button>
+        <button type="button" class="btn btn-default group-action" data-action="deploy"><i class="fa fa-cloud-upload"></i> 部署</button>
+      </div>
+      <hr style="margin-top:5px; margin-bottom:15px;"/>
+    </div>
+  </div>
+  {{range .Apps}}
   <div class="row container">
     <div class="col-md-2 col-lg-offset-1">
+      <input type="checkbox" name="app" value="{{.Name}}" checked/>
       <a href="/applications/{{.Name}}" style="font-size:160%; color:#555;">{{.Name}}</a>
       <sup> <a href="{{.URL}}" target="_blank"><i class="fa fa-external-link"></i></a></sup>
     </div>
@@ -14,6 +76,9 @@
         {{range .Plugins}}
         <span class="label label-info">{{.}}</span>
         {{end}}
+        {{range $k, $v := .Labels}}
+        <a class="label label-default" href="/applications?label={{$k}}={{$v}}">{{$k}}={{$v}}</a>
+        {{end}}
       </div>
       <div class="text-muted" style="margin-top:5px;">
         <small>创建于: {{humanDuration .CreatedAt}}</small>
@@ -22,6 +87,14 @@
   </div>
   <hr style="margin-top:10px; margin-bottom:15px;"/>
   {{end}}
+  </form>
+  {{else}}
+  <div class="row container">
+    <div class="col-md-10 col-lg-offset-1">
+      <p class="text-muted">没有匹配标签的应用</p>
+    </div>
+  </div>
+  {{end}}
   <div class="col-md-2 col-lg-offset-1">
     <a class="btn btn-primary" href="/applications/create/form"><i class="fa fa-plus"></i> 创建应用</a>
   </div>
@@ -31,3 +104,72 @@
   </div>
 {{end}}
 </div>
+
+<div class="modal" id="group-modal" role="dialog">
+  <div class="modal-dialog" role="document">
+    <div class="modal-content">
+      <div class="modal-header">
+        <h4>批量操作</h4>
+      </div>
+      <div class="modal-body">
+        <div style="padding:8px; background:black;">
+          <div id="group-term"></div>
+        </div>
+      </div>
+      <div class="modal-footer">
+        <button id="group-close-btn" type="button" class="btn btn-default">关闭</button>
+      </div>
+    </div>
+  </div>
+</div>
+
+<script>
+var groupQuery;
+
+$('.group-action').on('click', function(e) {
+  var form = $(this).closest('form');
+  if (form.find('input[name=app]:checked').length == 0) {
+    return;
+  }
+  groupQuery = form.serialize() + '&action=' + $(this).data('action');
+  $('#group-modal').modal({backdrop: 'static'});
+});
+
+$('#group-modal').on('show.bs.modal', function(e) {
+  $('#group-close-btn').prop('disabled', true);
+
+  var ws = new WebSocket("{{.ws}}?" + groupQuery);
+  var term, err;
+
+  ws.onopen = function(evt) {
+    var container = document.getElementById('group-term');
+    container.innerHTML = '';
+    term = new Terminal({convertEol:true});
+    term.cursorHidden = true;
+    term.open(container);
+    term.fit();
+  };
+
+  ws.onmessage = function(evt) {
+    var data = JSON.parse(evt.data);
+    if (data.msg) {
+      term.write(data.msg);
+    }
+    if (data.err) {
+      term.write("\x1b[31;1m" + data.err + "\x1b[0m\n");
+      err = true;
+    }
+  };
+
+  ws.onclose = function(evt) {
+    if (!err) {
+      term.write("\n\x1b[32;1m操作完成\x1b[0m\n");
+    }
+    $('#group-close-btn').prop('disabled', false);
+  };
+});
+
+$('#group-close-btn').on('click', function(e) {
+  window.location.reload();
+});
+</script>
@@ -35,6 +35,7 @@
             {{- range .}}
             <li>
               <form class="form-inline" action="/applications/{{$name}}/host/delete" method="post">
+                <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
                 <div class="form-group">
                   <button class="btn btn-link" type="submit" style="padding:0;margin:0;" title="删除">
                     <i class="fa fa-minus"></i>
@@ -73,6 +74,7 @@ $ git push origin master</pre>
         {{- if .app.Branches }}
         <p>此外，如有必要，也可以点击以下按钮主动触发应用部署，并且可以选择当前分支以实现快速版本切换。</p>
         <form id="deploy-form" class="form-inline" action="/applications/{{$name}}/deploy" method="post">
+          <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
           <div class="form-group">
             <label for="branch">当前分支：</label>
             <div class="input-group">
@@ -86,7 +88,7 @@ $ git push origin master</pre>
                   {{- range .app.Branches }}
                   {{- if eq .Type "BRANCH" }}
                   <li class="branch-select" data-input="#branch">
-                    <a name="{{.Id}}" href="#">{{.DisplayId}}</a>
+                    <a name="{{.Id}}" href="#">{{.DisplayId}}{{if .LatestCommit}} <small class="text-muted">{{printf "%.7s" .LatestCommit}}</small>{{end}}</a>
                   </li>
                   {{- end }}
                   {{- end }}
@@ -94,14 +96,16 @@ $ git push origin master</pre>
                   {{- range .app.Branches }}
                   {{- if eq .Type "TAG" }}
                   <li class="branch-select" data-input="#branch">
-                    <a name="{{.Id}}" href="#">{{.DisplayId}}</a>
+                    <a name="{{.Id}}" href="#">{{.DisplayId}}{{if .LatestCommit}} <small class="text-muted">{{printf "%.7s" .LatestCommit}}</small>{{end}}</a>
                   </li>
                   {{- end }}
                   {{- end }}
+                  <li role="separator" class="divider"></li>
+                  <li><a href="/applications/{{$name}}/settings?refresh=1"><i class="fa fa-refresh"></i> 刷新列表</a></li>
                 </ul>
               </div>
             </div>
-            <button id="deploy-btn" class="btn btn-success btn-sm" type="submit">
+            <button id="deploy-btn" class="btn btn-success btn-sm" type="submit"{{if .app.DeployLock}} disabled{{end}}>
               <i class="fa fa-cloud-upload"></i> 立即部署
             </button>
           </div>
@@ -109,7 +113,8 @@ $ git push origin master</pre>
         {{- else }}
         <p>此外，如有必要，也可以点击以下按钮主动触发应用部署。</p>
         <form id="deploy-form" class="form-inline" action="/applications/{{$name}}/deploy" method="post">
-          <button id="deploy-btn" class="btn btn-success btn-sm" type="submit">
+          <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
+          <button id="deploy-btn" class="btn btn-success btn-sm" type="submit"{{if .app.DeployLock}} disabled{{end}}>
             <i class="fa fa-cloud-upload"></i> 立即部署
           </button>
         </form>
@@ -117,27 +122,184 @@ $ git push origin master</pre>
       </div>
     </div>
 
+    <hr/>
+    <div class="row">
+      <div class="col-md-2">部署锁定</div>
+      <div class="col-md-6">
+        {{- with .app.DeployLock}}
+        <p>应用部署已由 {{.By}} 于 {{formatDate .Since}} 锁定{{with .Reason}}，原因：{{.}}{{end}}。解除锁定之前，所有部署请求都将被拒绝。</p>
+        <form action="/applications/{{$name}}/deploylock" method="post">
+          <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
+          <input type="hidden" name="on" value="0"/>
+          <button class="btn btn-success btn-sm" type="submit"><i class="fa fa-unlock"></i> 解除锁定</button>
+        </form>
+        {{- else}}
+        <p>锁定部署后，所有部署请求都将被拒绝，例如在处理故障期间防止意外部署。</p>
+        <form class="form-inline" action="/applications/{{$name}}/deploylock" method="post">
+          <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
+          <input type="hidden" name="on" value="1"/>
+          <div class="form-group">
+            <input type="text" class="form-control input-sm" name="reason" placeholder="锁定原因"/>
+          </div>
+          <button class="btn btn-warning btn-sm" type="submit"><i class="fa fa-lock"></i> 锁定部署</button>
+        </form>
+        {{- end}}
+      </div>
+    </div>
+
+    <hr/>
+    <div class="row">
+      <div class="col-md-2">访问控制</div>
+      <div class="col-md-6">
+        <p>限制可以访问应用的客户端 IP 地址，或要求访问者输入用户名和密码。拒绝列表优先于允许列表，允许列表为空时允许所有地址访问。</p>
+        <form action="/applications/{{$name}}/accesspolicy" method="post">
+          <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
+          <div class="form-group">
+            <label for="access-allow">允许的 IP 地址或网段</label>
+            <textarea class="form-control input-sm" id="access-allow" name="allow" rows="2" placeholder="例如 10.0.0.0/8，每行一个">
+              {{- with .app.AccessPolicy}}{{range .Allow}}{{.}}{{"\n"}}{{end}}{{end -}}
+            </textarea>
+          </div>
+          <div class="form-group">
+            <label for="access-deny">拒绝的 IP 地址或网段</label>
+            <textarea class="form-control input-sm" id="access-deny" name="deny" rows="2" placeholder="每行一个">
+              {{- with .app.AccessPolicy}}{{range .Deny}}{{.}}{{"\n"}}{{end}}{{end -}}
+            </textarea>
+          </div>
+          <div class="form-group">
+            <label for="access-auth">基本认证用户</label>
+            <textarea class="form-control input-sm" id="access-auth" name="auth" rows="2" placeholder="用户名:密码，每行一个，省略密码则保留原密码">
+              {{- with .app.AccessPolicy}}{{range .BasicAuth}}{{.User}}{{"\n"}}{{end}}{{end -}}
+            </textarea>
+          </div>
+          <div class="form-group">
+            <label for="access-redirect">维护模式跳转地址</label>
+            <input type="text" class="form-control input-sm" id="access-redirect" name="redirect" placeholder="维护模式下跳转到该地址而不显示维护页面"
+                   value="{{with .app.AccessPolicy}}{{.MaintenanceRedirect}}{{end}}"/>
+          </div>
+          <button class="btn btn-primary btn-sm" type="submit"><i class="fa fa-shield"></i> 保存</button>
+        </form>
+      </div>
+    </div>
+
+    <hr/>
+    <div class="row">
+      <div class="col-md-2">HTTPS</div>
+      <div class="col-md-6">
+        <p>为已安装证书的域名强制使用 HTTPS 访问，并设置 HSTS 响应头。应用返回错误时可以跳转到自定义错误页面。
+          使用 WebSocket 或 gRPC 的应用需要开启相应的连接选项，gRPC 仅支持已安装证书的域名。</p>
+        <form action="/applications/{{$name}}/https" method="post">
+          <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
+          <div class="checkbox">
+            <label><input type="checkbox" name="force" value="1"{{with .app.HTTPSettings}}{{if .ForceHTTPS}} checked{{end}}{{end}}/> 将 HTTP 请求重定向到 HTTPS</label>
+          </div>
+          <div class="form-group">
+            <label for="https-hsts">HSTS 有效期（秒）</label>
+            <input type="number" min="0" class="form-control input-sm" id="https-hsts" name="hsts" placeholder="0 表示不启用"
+                   value="{{with .app.HTTPSettings}}{{with .HSTSMaxAge}}{{.}}{{end}}{{end}}"/>
+          </div>
+          <div class="checkbox">
+            <label><input type="checkbox" name="subdomains" value="1"{{with .app.HTTPSettings}}{{if .HSTSIncludeSubdomains}} checked{{end}}{{end}}/> HSTS 包含子域名</label>
+          </div>
+          <div class="form-group">
+            <label for="https-errorpages">自定义错误页面</label>
+            <textarea class="form-control input-sm" id="https-errorpages" name="errorpages" rows="2" placeholder="状态码 页面地址，每行一个，例如 502 https://example.com/502.html">
+              {{- with .app.HTTPSettings}}{{range $code, $url := .ErrorPages}}{{$code}} {{$url}}{{"\n"}}{{end}}{{end -}}
+            </textarea>
+          </div>
+          <div class="checkbox">
+            <label><input type="checkbox" name="websocket" value="1"{{with .app.HTTPSettings}}{{if .WebSocket}} checked{{end}}{{end}}/> 允许 WebSocket 连接</label>
+          </div>
+          <div class="checkbox">
+            <label><input type="checkbox" name="grpc" value="1"{{with .app.HTTPSettings}}{{if .GRPC}} checked{{end}}{{end}}/> 使用 HTTP/2 转发 gRPC 请求</label>
+          </div>
+          <div class="form-group">
+            <label for="https-timeout">空闲超时（秒）</label>
+            <input type="number" min="0" class="form-control input-sm" id="https-timeout" name="timeout" placeholder="0 表示使用默认值"
+                   value="{{with .app.HTTPSettings}}{{with .IdleTimeout}}{{.}}{{end}}{{end}}"/>
+          </div>
+          <button class="btn btn-primary btn-sm" type="submit"><i class="fa fa-lock"></i> 保存</button>
+        </form>
+      </div>
+    </div>
+
     <hr/>
     <div class="row">
       <div class="col-md-2">应用管理</div>
       <div class="col-md-6">
         <p>使用云途命令行工具可以完全控制应用运行环境。请从以下地址下载命令行工具：</p>
         <ul>
-          <li><a href='{{download "linux"}}'>Linux</a></li>
-          <li><a href='{{download "darwin"}}'>Mac OS X</a></li>
-          <li><a href='{{download "freebsd"}}'>FreeBSD</a></li>
-          <li><a href='{{download "windows"}}'>Windows</a></li>
+          <li>Linux：<a href='{{download "linux" "amd64"}}'>x86-64</a> | <a href='{{download "linux" "arm64"}}'>ARM64</a> |
+            <a href='{{download "linux" "arm"}}'>ARM</a> | <a href='{{download "linux" "386"}}'>x86</a></li>
+          <li>Mac OS X：<a href='{{download "darwin" "amd64"}}'>x86-64</a></li>
+          <li>FreeBSD：<a href='{{download "freebsd" "amd64"}}'>x86-64</a></li>
+          <li>Windows：<a href='{{download "windows" "amd64"}}'>x86-64</a> | <a href='{{download "windows" "386"}}'>x86</a></li>
         </ul>
+        <p>各平台文件的 SHA-256 校验和可从<a href="/dist/manifest.json">版本清单</a>中获得。</p>
         <p>下载后运行<code>cwcli help</code>可查看命令帮助，运行<code>cwcli help app</code>可查看应用管理命令的更多帮助。</p>
       </div>
     </div>
 
+    <hr/>
+    <div class="row">
+      <div class="col-md-2">维护模式</div>
+      <div class="col-md-6">
+        {{- with .app.Maintenance}}
+        <p>应用正处于维护模式，由 {{.By}} 于 {{formatDate .Since}} 开启{{if .Stopped}}，应用已停止运行{{end}}。访问者将看到维护页面。</p>
+        <form action="/applications/{{$name}}/maintenance" method="post">
+          <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
+          <input type="hidden" name="on" value="0"/>
+          <button class="btn btn-success btn-sm" type="submit"><i class="fa fa-play"></i> 结束维护</button>
+        </form>
+        {{- else}}
+        <p>开启维护模式后，访问者将看到维护页面，而应用可以继续运行或者停止运行。</p>
+        <form class="form-inline" action="/applications/{{$name}}/maintenance" method="post">
+          <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
+          <input type="hidden" name="on" value="1"/>
+          <div class="checkbox">
+            <label><input type="checkbox" name="stop" value="1"/> 停止应用</label>
+          </div>
+          <button class="btn btn-warning btn-sm" type="submit"><i class="fa fa-wrench"></i> 开启维护</button>
+        </form>
+        {{- end}}
+      </div>
+    </div>
+
+    <hr/>
+    <div class="row">
+      <div class="col-md-2">删除保护</div>
+      <div class="col-md-6">
+        {{- if .app.Protected}}
+        <p>应用已开启删除保护，删除应用、减少实例以及恢复数据时需要输入应用名称进行确认。</p>
+        <form action="/applications/{{$name}}/protection" method="post">
+          <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
+          <input type="hidden" name="on" value="0"/>
+          <button class="btn btn-default btn-sm" type="submit"><i class="fa fa-unlock"></i> 关闭保护</button>
+        </form>
+        {{- else}}
+        <p>开启删除保护后，删除应用、减少实例以及恢复数据时需要输入应用名称进行确认，以防止误操作。</p>
+        <form action="/applications/{{$name}}/protection" method="post">
+          <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
+          <input type="hidden" name="on" value="1"/>
+          <button class="btn btn-success btn-sm" type="submit"><i class="fa fa-lock"></i> 开启保护</button>
+        </form>
+        {{- end}}
+      </div>
+    </div>
+
     <hr/>
     <div class="row">
       <div class="col-md-2">删除应用</div>
       <div class="col-md-6">
         <p>此操作无法恢复，请确定已做好备份</p>
         <form action="/applications/{{$name}}/delete" method="post">
+          <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
+          {{- if .app.Protected}}
+          <div class="form-group">
+            <label for="confirm">应用已开启删除保护，请输入应用名称确认删除</label>
+            <input type="text" class="form-control" id="confirm" name="confirm" required/>
+          </div>
+          {{- end}}
           <button class="btn btn-danger" type="button" data-toggle="modal"
                   data-target="#confirm-modal" data-message="与应用相关的所有数据都将被删除，并且无法恢复，是否继续？">
             <i class="fa fa-trash-o fa-lg"></i> 删除应用...
@@ -152,6 +314,7 @@ $ git push origin master</pre>
   <div class="modal-dialog" role="document">
     <div class="modal-content">
       <form action="/applications/{{$name}}/host" method="post">
+        <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
         <div class="modal-header">
           <button type="button" class="close" data-dismiss="modal"><span>&times;</span></button>
           <h4 class="modal-title">增加域名</h4>
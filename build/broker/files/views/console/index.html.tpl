@@ -35,7 +35,9 @@
     </div>
   </div>
 
+  {{if .registration_open}}
   <div class="col-md-12">
     <a class="btn btn-primary" href="/auth/register" role="button">立即注册</a>
   </div>
+  {{end}}
 </div>
@@ -1,5 +1,5 @@
 <!DOCTYPE html>
-<html>
+<html{{if .loggedin}}{{with .user.Preferences.Locale}} lang="{{.}}"{{end}}{{end}}>
 <head>
   <meta charset="utf-8">
   <meta http-equiv="X-UA-Compatible" content="IE-edge">
@@ -12,7 +12,12 @@
   <link rel="stylesheet" href="/static/css/main.css" />
   {{template "prelude" .}}
 </head>
-<body class="container" style="padding-top: 15px;">
+<body class="container{{if .loggedin}}{{with .user.Preferences}} theme-{{or .Theme "light"}} density-{{or .TableDensity "comfortable"}}{{end}}{{end}}" style="padding-top: 15px;">
+  <script type="text/javascript">
+    if (document.body.className.indexOf("theme-auto") >= 0 && window.matchMedia && window.matchMedia("(prefers-color-scheme: dark)").matches) {
+      document.body.className += " theme-dark";
+    }
+  </script>
   <nav class="navbar navbar-default">
     <div class="container-fluid">
       <div class="navbar-header">
@@ -27,7 +32,7 @@
       <div id="navbar" class="collapse navbar-collapse">
         <ul class="nav navbar-nav navbar-right">
           {{if not .loggedin}}
-          <li><a href="/auth/register">注册</a></li>
+          {{if .registration_open}}<li><a href="/auth/register">注册</a></li>{{end}}
           <li><a href="/auth/login"><i class="fa fa-sign-in"></i> 登录</a></li>
           {{else}}
           <li class="dropdown">
@@ -52,6 +57,14 @@
     </div>
   </nav>
 
+  {{with .readonly}}<div class="alert alert-warning">{{.}}</div>{{end}}
+  {{range $n := .notices}}
+  <div class="alert {{if eq $n.Kind "info" "tos"}}alert-info{{else}}alert-warning{{end}}">
+    {{if eq $n.Kind "maintenance"}}<i class="fa fa-wrench"></i>{{else if eq $n.Kind "tos"}}<i class="fa fa-file-text-o"></i>{{end}}
+    {{$n.Message}}
+    {{with $n.URL}}<a class="alert-link" href="{{.}}" target="_blank">{{if eq $n.Kind "tos"}}查看服务条款{{else}}详情{{end}}</a>{{end}}
+  </div>
+  {{end}}
   {{with .flash_success}}<div class="alert alert-success">{{.}}</div>{{end}}
   {{with .flash_error}}<div class="alert alert-danger">{{.}}</div>{{end}}
   {{template "yield" .}}
@@ -1,10 +1,20 @@
 <div class="panel-heading">
   <div class="row">
     <div class="col-md-8">
-      <h4><a href="{{.app.URL}}" target="_blank">{{.app.Name}}-{{.user.Namespace}}</a></h4>
+      <h4>
+        <a href="{{.app.URL}}" target="_blank">{{.app.Name}}-{{.user.Namespace}}</a>
+        {{- with .app.Maintenance}}
+        <span class="label label-warning" title="{{.By}} 于 {{formatDate .Since}} 开启">维护中</span>
+        {{- end}}
+        {{- with .app.DeployLock}}
+        <span class="label label-danger" title="{{.By}} 于 {{formatDate .Since}} 锁定{{with .Reason}}：{{.}}{{end}}">部署已锁定</span>
+        {{- end}}
+      </h4>
     </div>
     <div class="col-md-4 conditional-text-align">
       <a class="btn btn-default" href="/applications/{{.app.Name}}"><i class="glyphicon glyphicon-list-alt"></i> 概览</a>
+      <a class="btn btn-default" href="/applications/{{.app.Name}}/deployments"><i class="fa fa-history"></i> 部署</a>
+      <a class="btn btn-default" href="/applications/{{.app.Name}}/data"><i class="fa fa-database"></i> 数据</a>
       <a class="btn btn-default" href="/applications/{{.app.Name}}/settings"><i class="fa fa-wrench"></i> 设置</a>
     </div>
   </div>
@@ -4,13 +4,16 @@
     <div class="alert alert-danger">{{.error}}</div>
     {{end}}
     <form method="post">
+      <div class="form-group">
+        <input class="form-control" name="label" type="text" placeholder="标签（默认使用密钥注释）"/>
+      </div>
       <div class="form-group">
         <textarea class="form-control" name="content" cols="100" rows="5"></textarea>
       </div>
       <input type="hidden" name="csrf_token" value="{{.csrf_token}}"/>
       <div class="row text-right">
         <button class="btn btn-success" type="submit">保存</button>
-        <a class="btn btn-link" href="/settings">取消</a>
+        <a class="btn btn-link" href="/settings/keys">取消</a>
       </div>
     </form>
   </div>
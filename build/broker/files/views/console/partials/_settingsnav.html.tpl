@@ -0,0 +1,7 @@
+<ul class="nav nav-tabs settings-nav">
+  <li{{if eq .settings_tab "general"}} class="active"{{end}}><a href="/settings">通用</a></li>
+  {{- if .user.Namespace}}
+  <li{{if eq .settings_tab "keys"}} class="active"{{end}}><a href="/settings/keys">SSH密钥</a></li>
+  {{- end}}
+  <li{{if eq .settings_tab "tokens"}} class="active"{{end}}><a href="/settings/tokens">访问令牌</a></li>
+</ul>
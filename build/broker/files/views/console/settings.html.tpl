@@ -1,12 +1,15 @@
 {{define "pagetitle"}}应用控制台 - 设置{{end}}
 
 <div class="row container">
+  {{template "_settingsnav" .}}
+
 {{if .user.Namespace}}
   <div class="panel panel-info col-md-12" style="margin-bottom: 20px;">
     <h4>名字空间</h4>
     <p>通过名字空间组织你的应用，并赋予每个应用一个唯一的域名</p>
     <div class="col-md">
       <form class="form-inline" action="/settings/namespace/delete" method="post">
+        <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
         <p class="form-control-static">当前正在使用的名字空间：{{.user.Namespace}}</p>
         {{if eq (len .user.Applications) 0}}
         <button class="btn btn-link" type="submit" title="删除">
@@ -17,46 +20,6 @@
     </div>
   </div>
 
-  <div class="panel panel-info col-md-12">
-    <h4>SSH公共密钥</h4>
-    <p>通过SSH公共密钥安全上传代码</p>
-
-    <div class="col-md-12" style="margin-top: 10px;">
-      {{if ne (len .sshkeys) 0}}
-      <div class="panel panel-default">
-        <div class="table-responsive">
-          <table class="table ssh-keys-table">
-            <tr>
-              <th class="ssh-label-col">标签</th>
-              <th class="ssh-key-col">内容</th>
-              <th style="width:2em;"></th>
-            </tr>
-            {{range .sshkeys}}
-            <tr>
-              <td class="ssh-label-col"><span>{{.Label}}</span></td>
-              <td class="ssh-key-col"><span>{{.Text}}</span></td>
-              <td>
-                <form action="/settings/sshkey/delete?key={{.Text}}" method="post">
-                  <button class="btn btn-link" type="submit" title="删除" style="padding:0;margin:0;">
-                    <i class="fa fa-minus"></i>
-                  </button>
-                </form>
-              </td>
-            </tr>
-            {{end}}
-          </table>
-        </div>
-      </div>
-      {{end}}
-
-      <div class="row" style="margin-bottom: 20px;">
-        <div class="col-md-2">
-          <a class="btn btn-primary" href="/settings/sshkey"><i class="fa fa-plus"></i> 增加新密钥...</a>
-        </div>
-      </div>
-    </div>
-  </div>
-
 {{else}}
 
   <div class="panel panel-info col-md-12">
@@ -81,4 +44,111 @@
     {{end}}
   </div>
 {{end}}
+
+  <div class="panel panel-info col-md-12" style="margin-top: 20px;">
+    <h4>界面偏好</h4>
+    <p>偏好设置保存在账户中，在控制台和命令行工具之间共享</p>
+    {{if .prefs_error}}
+    <div class="alert alert-danger">{{.prefs_error}}</div>
+    {{end}}
+
+    <form class="form-horizontal" action="/settings/preferences" method="post" style="margin-bottom:20px;">
+      {{with .user.Preferences}}
+      <div class="form-group">
+        <label for="theme" class="col-md-2 control-label">主题</label>
+        <div class="col-md-4">
+          <select id="theme" name="theme" class="form-control">
+            <option value="light"{{if eq .Theme "" "light"}} selected{{end}}>浅色</option>
+            <option value="dark"{{if eq .Theme "dark"}} selected{{end}}>深色</option>
+            <option value="auto"{{if eq .Theme "auto"}} selected{{end}}>跟随系统</option>
+          </select>
+        </div>
+      </div>
+      <div class="form-group">
+        <label for="locale" class="col-md-2 control-label">语言</label>
+        <div class="col-md-4">
+          <select id="locale" name="locale" class="form-control">
+            <option value="zh-CN"{{if eq .Locale "" "zh-CN"}} selected{{end}}>简体中文</option>
+            <option value="en-US"{{if eq .Locale "en-US"}} selected{{end}}>English</option>
+          </select>
+        </div>
+      </div>
+      <div class="form-group">
+        <label for="namespace_view" class="col-md-2 control-label">应用列表</label>
+        <div class="col-md-4">
+          <select id="namespace_view" name="namespace_view" class="form-control">
+            <option value="">不分组</option>
+            {{- $view := .NamespaceView}}
+            {{- range $.labelKeys}}
+            <option value="{{.}}"{{if eq . $view}} selected{{end}}>按 {{.}} 分组</option>
+            {{- end}}
+          </select>
+        </div>
+      </div>
+      <div class="form-group">
+        <label for="table_density" class="col-md-2 control-label">表格密度</label>
+        <div class="col-md-4">
+          <select id="table_density" name="table_density" class="form-control">
+            <option value="comfortable"{{if eq .TableDensity "" "comfortable"}} selected{{end}}>宽松</option>
+            <option value="compact"{{if eq .TableDensity "compact"}} selected{{end}}>紧凑</option>
+          </select>
+        </div>
+      </div>
+      {{end}}
+      <div class="form-group">
+        <div class="col-md-offset-2 col-md-4">
+          <button class="btn btn-primary" type="submit">保存偏好</button>
+        </div>
+      </div>
+      <input type="hidden" name="csrf_token" value="{{$.csrf_token}}" />
+    </form>
+  </div>
+
+  <div class="panel panel-info col-md-12" style="margin-top: 20px;">
+    <h4>账户</h4>
+    {{if .account_error}}
+    <div class="alert alert-danger">{{.account_error}}</div>
+    {{end}}
+
+    <div class="row">
+      <div class="col-md-6" style="margin-bottom:20px;">
+        <p>更改登录邮件地址，确认链接将发送至新的邮件地址</p>
+        {{if .user.PendingEmail}}
+        <p class="text-muted">等待确认：{{.user.PendingEmail}}</p>
+        {{end}}
+        <form action="/settings/email" method="post">
+          <div class="input-group">
+            <input name="email" type="email" class="form-control" placeholder="{{.user.Name}}" />
+            <span class="input-group-btn">
+              <button class="btn btn-primary" type="submit">更改邮件地址...</button>
+            </span>
+          </div>
+          <input type="hidden" name="csrf_token" value="{{$.csrf_token}}" />
+        </form>
+      </div>
+    </div>
+
+    <div class="row">
+      <div class="col-md-6" style="margin-bottom:20px;">
+        {{if .user.DeleteAt.IsZero}}
+        <p>删除账户以及所有应用、代码仓库和数据，删除前可以恢复账户</p>
+        <form action="/settings/delete" method="post">
+          <div class="input-group">
+            <input name="password" type="password" class="form-control" placeholder="输入密码以确认" />
+            <span class="input-group-btn">
+              <button class="btn btn-danger" type="submit">删除账户...</button>
+            </span>
+          </div>
+          <input type="hidden" name="csrf_token" value="{{$.csrf_token}}" />
+        </form>
+        {{else}}
+        <div class="alert alert-warning">账户将于 {{formatDate .user.DeleteAt}} 删除</div>
+        <form action="/settings/restore" method="post">
+          <input type="hidden" name="csrf_token" value="{{$.csrf_token}}" />
+          <button class="btn btn-primary" type="submit">恢复账户</button>
+        </form>
+        {{end}}
+      </div>
+    </div>
+  </div>
 </div>
@@ -0,0 +1,52 @@
+{{define "pagetitle"}}应用控制台 - SSH密钥{{end}}
+
+<div class="row container">
+  {{template "_settingsnav" .}}
+
+  <div class="panel panel-info col-md-12">
+    <h4>SSH公共密钥</h4>
+    <p>通过SSH公共密钥安全上传代码</p>
+
+    <div class="col-md-12" style="margin-top: 10px;">
+      {{if ne (len .sshkeys) 0}}
+      <div class="panel panel-default">
+        <div class="table-responsive">
+          <table class="table ssh-keys-table">
+            <tr>
+              <th class="ssh-label-col">标签</th>
+              <th class="ssh-key-col">指纹</th>
+              <th>添加时间</th>
+              <th>最近使用</th>
+              <th style="width:2em;"></th>
+            </tr>
+            {{range .sshkeys}}
+            <tr>
+              <td class="ssh-label-col"><span>{{.Label}}</span></td>
+              <td class="ssh-key-col"><span title="{{.Text}}">{{.Fingerprint}}</span></td>
+              <td>{{formatDate .CreatedAt}}</td>
+              <td>{{if .LastUsed.IsZero}}从未使用{{else}}{{humanDuration .LastUsed}}{{end}}</td>
+              <td>
+                <form action="/settings/sshkey/delete?fingerprint={{.Fingerprint}}" method="post">
+                  <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
+                  <button class="btn btn-link" type="submit" title="删除" style="padding:0;margin:0;">
+                    <i class="fa fa-minus"></i>
+                  </button>
+                </form>
+              </td>
+            </tr>
+            {{end}}
+          </table>
+        </div>
+      </div>
+      {{else}}
+      <p class="text-muted">还没有添加SSH公共密钥</p>
+      {{end}}
+
+      <div class="row" style="margin-bottom: 20px;">
+        <div class="col-md-2">
+          <a class="btn btn-primary" href="/settings/sshkey"><i class="fa fa-plus"></i> 增加新密钥...</a>
+        </div>
+      </div>
+    </div>
+  </div>
+</div>
@@ -0,0 +1,88 @@
+{{define "pagetitle"}}应用控制台 - 访问令牌{{end}}
+
+<div class="row container">
+  {{template "_settingsnav" .}}
+
+  <div class="panel panel-info col-md-12">
+    <h4>个人访问令牌</h4>
+    <p>脚本和工具可以使用访问令牌代替密码调用API，令牌只能访问授权范围内的操作，撤销后立即失效</p>
+
+    {{with .new_token}}
+    <div class="alert alert-success">
+      <p>新的访问令牌已创建，请立即复制保存，离开此页面后将无法再次查看：</p>
+      <pre class="access-token">{{.}}</pre>
+    </div>
+    {{end}}
+
+    <div class="col-md-12" style="margin-top: 10px;">
+      {{if ne (len .tokens) 0}}
+      <div class="panel panel-default">
+        <div class="table-responsive">
+          <table class="table access-tokens-table">
+            <tr>
+              <th>标签</th>
+              <th>授权范围</th>
+              <th>创建时间</th>
+              <th>最近使用</th>
+              <th style="width:2em;"></th>
+            </tr>
+            {{range .tokens}}
+            <tr>
+              <td><span>{{.Label}}</span></td>
+              <td>
+                {{- range .Scopes}}
+                <span class="label label-default">{{.}}</span>
+                {{- end}}
+              </td>
+              <td>{{formatDate .CreatedAt}}</td>
+              <td>{{if .LastUsed.IsZero}}从未使用{{else}}{{humanDuration .LastUsed}}{{end}}</td>
+              <td>
+                <form action="/settings/tokens/delete" method="post">
+                  <input type="hidden" name="id" value="{{.ID}}"/>
+                  <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
+                  <button class="btn btn-link" type="submit" title="撤销" style="padding:0;margin:0;">
+                    <i class="fa fa-minus"></i>
+                  </button>
+                </form>
+              </td>
+            </tr>
+            {{end}}
+          </table>
+        </div>
+      </div>
+      {{else}}
+      <p class="text-muted">还没有创建访问令牌</p>
+      {{end}}
+    </div>
+
+    <div class="col-md-12" style="margin-bottom: 20px;">
+      <h4>创建访问令牌</h4>
+      {{if .error}}
+      <div class="alert alert-danger">{{.error}}</div>
+      {{end}}
+      <form class="form-horizontal" action="/settings/tokens" method="post">
+        <div class="form-group">
+          <label for="label" class="col-md-2 control-label">标签</label>
+          <div class="col-md-4">
+            <input id="label" name="label" type="text" class="form-control" maxlength="64" placeholder="例如：CI部署"/>
+          </div>
+        </div>
+        <div class="form-group">
+          <label class="col-md-2 control-label">授权范围</label>
+          <div class="col-md-8">
+            <label class="checkbox-inline"><input type="checkbox" name="scope" value="read" checked/> read（查看应用）</label>
+            <label class="checkbox-inline"><input type="checkbox" name="scope" value="upload"/> upload（上传代码）</label>
+            <label class="checkbox-inline"><input type="checkbox" name="scope" value="deploy"/> deploy（部署应用）</label>
+            <label class="checkbox-inline"><input type="checkbox" name="scope" value="all"/> all（完全访问账户）</label>
+          </div>
+        </div>
+        <div class="form-group">
+          <div class="col-md-offset-2 col-md-4">
+            <button class="btn btn-primary" type="submit"><i class="fa fa-plus"></i> 创建令牌</button>
+          </div>
+        </div>
+        <input type="hidden" name="csrf_token" value="{{$.csrf_token}}"/>
+      </form>
+    </div>
+  </div>
+</div>
@@ -61,7 +61,7 @@ $(document).on('ready', function() {
     if (geometry.cols != cols || geometry.rows != rows) {
       cols = geometry.cols;
       rows = geometry.rows;
-      $.post("/shell/"+execId+"/resize", {cols: cols, rows: rows});
+      $.post("/shell/"+execId+"/resize", {cols: cols, rows: rows, csrf_token: "{{.csrf_token}}"});
     }
   };
 
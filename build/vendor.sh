@@ -59,6 +59,7 @@ clone git github.com/gorilla/securecookie ff356348f74133a59d3e93aa24b5b4551b6fe9
 clone git github.com/gorilla/sessions 56ba4b0a11da87516629a57408a5f7e4c8ea7b0b
 clone git github.com/justinas/nosurf 2e708f28095ba17463e41438bbfd53abae8b6794
 clone git github.com/kardianos/osext c2c54e542fb797ad986b31721e1baedf214ca413
+clone git github.com/miekg/dns 79bfde677fa8
 clone git github.com/opencontainers/runc 8e22b1d36b2ec794e16fb47cf662c50e2553cb9f
 clone git github.com/oxtoacart/bpool 4e1c5567d7c2dd59fa4c7c83d34c2f3528b025d6
 clone git github.com/sevlyar/go-daemon 3bf5e993af87194518e81cf9a81c1c53190a8911
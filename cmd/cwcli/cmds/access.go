@@ -0,0 +1,92 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/mflag"
+	"github.com/cloudway/platform/pkg/opts"
+)
+
+func (cli *CWCli) CmdAppAccess(args ...string) error {
+	var (
+		allow, deny, auth []string
+		redirect          string
+		clear             bool
+	)
+
+	cmd := cli.Subcmd("app:access", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.Var(opts.NewListOptsRef(&allow, nil), []string{"-allow"}, "Allow the IP address or CIDR range")
+	cmd.Var(opts.NewListOptsRef(&deny, nil), []string{"-deny"}, "Deny the IP address or CIDR range")
+	cmd.Var(opts.NewListOptsRef(&auth, nil), []string{"-auth"}, "Require basic auth with USER[:PASSWORD]")
+	cmd.StringVar(&redirect, []string{"-maintenance-redirect"}, "", "Redirect to the URL in maintenance mode")
+	cmd.BoolVar(&clear, []string{"-clear"}, false, "Remove all access restrictions")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if clear {
+		return cli.RemoveAccessPolicy(ctx, name)
+	}
+
+	policy, err := cli.GetAccessPolicy(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	if cmd.IsSet("-allow") {
+		policy.Allow, changed = allow, true
+	}
+	if cmd.IsSet("-deny") {
+		policy.Deny, changed = deny, true
+	}
+	if cmd.IsSet("-auth") {
+		policy.BasicAuth, changed = nil, true
+		for _, a := range auth {
+			kv := strings.SplitN(a, ":", 2)
+			u := types.BasicAuthUser{User: kv[0]}
+			if len(kv) == 2 {
+				u.Password = kv[1]
+			}
+			policy.BasicAuth = append(policy.BasicAuth, u)
+		}
+	}
+	if cmd.IsSet("-maintenance-redirect") {
+		policy.MaintenanceRedirect, changed = redirect, true
+	}
+
+	if changed {
+		return cli.SetAccessPolicy(ctx, name, policy)
+	}
+	printAccessPolicy(cli.stdout, policy)
+	return nil
+}
+
+func printAccessPolicy(w io.Writer, p *types.AccessPolicy) {
+	if len(p.Allow) != 0 {
+		fmt.Fprintf(w, "Allow:      %s\n", strings.Join(p.Allow, ", "))
+	}
+	if len(p.Deny) != 0 {
+		fmt.Fprintf(w, "Deny:       %s\n", strings.Join(p.Deny, ", "))
+	}
+	if len(p.BasicAuth) != 0 {
+		users := make([]string, len(p.BasicAuth))
+		for i, u := range p.BasicAuth {
+			users[i] = u.User
+		}
+		fmt.Fprintf(w, "Basic auth: %s\n", strings.Join(users, ", "))
+	}
+	if p.MaintenanceRedirect != "" {
+		fmt.Fprintf(w, "Redirect:   %s in maintenance\n", p.MaintenanceRedirect)
+	}
+}
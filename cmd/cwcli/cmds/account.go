@@ -0,0 +1,133 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+func (cli *CWCli) CmdAccount(args ...string) error {
+	var email, confirm string
+	var remove, restore, yes bool
+
+	cmd := cli.Subcmd("account", "", "--email EMAIL", "--confirm TOKEN", "--delete [-y]", "--restore")
+	cmd.Require(mflag.Exact, 0)
+	cmd.StringVar(&email, []string{"-email"}, "", "Change the email address, a confirmation link is sent to the new address")
+	cmd.StringVar(&confirm, []string{"-confirm"}, "", "Confirm the new email address with the token")
+	cmd.BoolVar(&remove, []string{"-delete"}, false, "Delete the account with all applications")
+	cmd.BoolVar(&restore, []string{"-restore"}, false, "Restore the account scheduled for deletion")
+	cmd.BoolVar(&yes, []string{"y"}, false, "Confirm 'yes' to delete the account")
+	cmd.ParseFlags(args, false)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	var ctx = context.Background()
+
+	switch {
+	case email != "":
+		if err := cli.ChangeEmail(ctx, email); err != nil {
+			return err
+		}
+		fmt.Fprintf(cli.stdout, "A confirmation link has been sent to %s\n", email)
+		return nil
+
+	case confirm != "":
+		if err := cli.ConfirmEmail(ctx, confirm); err != nil {
+			return err
+		}
+		fmt.Fprintln(cli.stdout, "Email address changed, please login with the new address")
+		cli.logout()
+		return nil
+
+	case remove:
+		info, err := cli.GetUserInfo(ctx)
+		if err != nil {
+			return err
+		}
+		if !yes && !cli.confirm("You will lost all your applications and data") {
+			return nil
+		}
+		at, err := cli.DeleteAccount(ctx, info.Name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cli.stdout, "The account will be deleted at %s\n", at.Local().Format(time.RFC1123))
+		return nil
+
+	case restore:
+		return cli.RestoreAccount(ctx)
+	}
+
+	info, err := cli.GetUserInfo(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.stdout, "Name:      %s\n", info.Name)
+	fmt.Fprintf(cli.stdout, "Namespace: %s\n", info.Namespace)
+	if info.PendingEmail != "" {
+		fmt.Fprintf(cli.stdout, "Pending:   %s (waiting for confirmation)\n", info.PendingEmail)
+	}
+	if info.DeleteAt != nil {
+		fmt.Fprintf(cli.stdout, "Deleting:  %s\n", info.DeleteAt.Local().Format(time.RFC1123))
+	}
+	return nil
+}
+
+// preferenceFields returns preferences by the keys used in command line.
+func preferenceFields(prefs *types.UserPreferences) map[string]*string {
+	return map[string]*string{
+		"theme":          &prefs.Theme,
+		"locale":         &prefs.Locale,
+		"namespace-view": &prefs.NamespaceView,
+		"table-density":  &prefs.TableDensity,
+	}
+}
+
+var preferenceKeys = []string{"theme", "locale", "namespace-view", "table-density"}
+
+func (cli *CWCli) CmdAccountPrefs(args ...string) error {
+	cmd := cli.Subcmd("account:prefs", "", "KEY=VALUE...")
+	output := outputFlag(cmd)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	prefs, err := cli.GetPreferences(ctx)
+	if err != nil {
+		return err
+	}
+	fields := preferenceFields(prefs)
+
+	if cmd.NArg() == 0 {
+		// cwcli account:prefs
+		return cli.writeOutput(output, prefs, func() {
+			for _, key := range preferenceKeys {
+				fmt.Fprintf(cli.stdout, "%s=%s\n", key, *fields[key])
+			}
+		})
+	}
+
+	// cwcli account:prefs key1=val1 key2=val2 ..., an empty value resets
+	// the preference to default
+	for _, kv := range cmd.Args() {
+		sep := strings.IndexRune(kv, '=')
+		if sep <= 0 {
+			return fmt.Errorf("Invalid preference %q, must be in the form of KEY=VALUE", kv)
+		}
+		field := fields[kv[:sep]]
+		if field == nil {
+			return fmt.Errorf("Unknown preference %q, must be one of %s", kv[:sep], strings.Join(preferenceKeys, ", "))
+		}
+		*field = kv[sep+1:]
+	}
+	return cli.SetPreferences(ctx, prefs)
+}
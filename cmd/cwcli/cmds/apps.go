@@ -18,11 +18,13 @@ import (
 
 	"github.com/docker/go-units"
 
+	"github.com/cloudway/platform/api/client"
 	"github.com/cloudway/platform/api/types"
 	"github.com/cloudway/platform/cmd/cwcli/cmds/ansi"
 	"github.com/cloudway/platform/cmd/cwcli/cmds/prettyjson"
 	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/pkg/archive"
+	"github.com/cloudway/platform/pkg/gopass"
 	"github.com/cloudway/platform/pkg/manifest"
 	"github.com/cloudway/platform/pkg/mflag"
 	"github.com/cloudway/platform/pkg/opts"
@@ -43,24 +45,33 @@ Additional commands, type "cwcli help COMMAND" for more details:
   app:ps             Show application processes
   app:stats          Display application live resource usage statistics
   app:service        Manage application services
+  app:collab         Manage application collaborators
   app:clone          Clone application source code
   app:deploy         Deploy an application
   app:upload         Upload an application repository
   app:dump           Dump application data
   app:restore        Restore application data
+  app:export         Export an application to a portable bundle
+  app:import         Import an application from a bundle
+  app:migrate        Migrate an application to another server context
+  app:cp             Copy files between local host and application
   app:scale          Scale an application
   app:info           Show application information
   app:env            Get or set application environment variables
+  app:label          Get or set application labels
   app:open           Open the application in a web brower
   app:ssh            Log into application console via SSH
 `
 
 func (cli *CWCli) CmdApps(args ...string) error {
 	var help bool
+	var labels []string
 
 	cmd := cli.Subcmd("app", "")
 	cmd.Require(mflag.Exact, 0)
+	cmd.Var(opts.NewListOptsRef(&labels, nil), []string{"l", "-label"}, "List applications with the label, in the form of KEY=VALUE, KEY!=VALUE or KEY")
 	cmd.BoolVar(&help, []string{"-help"}, false, "Print usage")
+	output := outputFlag(cmd)
 	cmd.ParseFlags(args, false)
 
 	if help {
@@ -72,15 +83,15 @@ func (cli *CWCli) CmdApps(args ...string) error {
 		return err
 	}
 
-	if apps, err := cli.GetApplications(context.Background()); err != nil {
+	apps, err := cli.GetApplications(context.Background(), labels...)
+	if err != nil {
 		return err
-	} else {
+	}
+	return cli.writeOutput(output, apps, func() {
 		for _, name := range apps {
 			fmt.Fprintln(cli.stdout, name)
 		}
-	}
-
-	return nil
+	})
 }
 
 func (cli *CWCli) getAppName(cmd *mflag.FlagSet) string {
@@ -166,9 +177,13 @@ func (cli *CWCli) CmdAppInfo(args ...string) error {
 	cmd := cli.Subcmd("app:info", "")
 	cmd.Require(mflag.Exact, 0)
 	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
-	cmd.BoolVar(&js, []string{"-json"}, false, "Display as JSON")
+	cmd.BoolVar(&js, []string{"-json"}, false, "Display as JSON, same as --output json")
+	output := outputFlag(cmd)
 	cmd.ParseFlags(args, true)
 	name := cli.getAppName(cmd)
+	if js {
+		*output = outputJSON
+	}
 
 	if err := cli.ConnectAndLogin(); err != nil {
 		return err
@@ -179,20 +194,90 @@ func (cli *CWCli) CmdAppInfo(args ...string) error {
 		return err
 	}
 
-	if js {
-		cli.writeJson(&app)
-	} else {
-		fmt.Fprintf(cli.stdout, "Name:       %s\n", app.Name)
-		fmt.Fprintf(cli.stdout, "Namespace:  %s\n", app.Namespace)
-		fmt.Fprintf(cli.stdout, "Created:    %v\n", app.CreatedAt)
-		fmt.Fprintf(cli.stdout, "Framework:  %s\n", app.Framework.DisplayName)
-		fmt.Fprintf(cli.stdout, "Scaling:    %v\n", app.Scaling)
-		fmt.Fprintf(cli.stdout, "URL:        %s\n", app.URL)
-		fmt.Fprintf(cli.stdout, "Source:     %s\n", app.CloneURL)
-		fmt.Fprintf(cli.stdout, "SSH:        %s\n", app.SSHURL)
-		fmt.Fprintf(cli.stdout, "Services:\n")
-		for _, p := range app.Services {
-			fmt.Fprintf(cli.stdout, " - %s\n", p.DisplayName)
+	if *output != outputTable {
+		return cli.writeOutput(output, app, nil)
+	}
+
+	fmt.Fprintf(cli.stdout, "Name:       %s\n", app.Name)
+	fmt.Fprintf(cli.stdout, "Namespace:  %s\n", app.Namespace)
+	fmt.Fprintf(cli.stdout, "Created:    %v\n", app.CreatedAt)
+	fmt.Fprintf(cli.stdout, "Framework:  %s %s\n", app.Framework.DisplayName, app.Framework.Version)
+	fmt.Fprintf(cli.stdout, "Scaling:    %v\n", app.Scaling)
+	fmt.Fprintf(cli.stdout, "URL:        %s\n", app.URL)
+	fmt.Fprintf(cli.stdout, "Source:     %s\n", app.CloneURL)
+	fmt.Fprintf(cli.stdout, "SSH:        %s\n", app.SSHURL)
+	if b := app.Branch; b != nil {
+		fmt.Fprintf(cli.stdout, "Branch:     %s", b.DisplayId)
+		if b.LatestCommit != "" {
+			fmt.Fprintf(cli.stdout, " (%s)", shortCommit(b.LatestCommit))
+		}
+		fmt.Fprintln(cli.stdout)
+	}
+	if d := app.LastDeployment; d != nil {
+		fmt.Fprintf(cli.stdout, "Deployed:   %s at %v", d.State, d.StartedAt)
+		if d.DeployedBy != "" {
+			fmt.Fprintf(cli.stdout, " by %s", d.DeployedBy)
+		}
+		fmt.Fprintln(cli.stdout)
+	}
+	if app.DiskQuota > 0 {
+		fmt.Fprintf(cli.stdout, "Disk quota: %s\n", units.BytesSize(float64(app.DiskQuota)))
+	}
+	if app.MemoryLimit > 0 {
+		fmt.Fprintf(cli.stdout, "Memory:     %s\n", units.BytesSize(float64(app.MemoryLimit)))
+	}
+	if app.CPULimit > 0 {
+		fmt.Fprintf(cli.stdout, "CPUs:       %g\n", app.CPULimit)
+	}
+	if m := app.Maintenance; m != nil {
+		fmt.Fprintf(cli.stdout, "Maintenance: enabled by %s since %v\n", m.By, m.Since)
+	}
+	if app.Protected {
+		fmt.Fprintf(cli.stdout, "Protected:  yes\n")
+	}
+	if len(app.Labels) != 0 {
+		fmt.Fprintf(cli.stdout, "Labels:\n")
+		for _, kv := range sortedLabels(app.Labels) {
+			fmt.Fprintf(cli.stdout, " - %s\n", kv)
+		}
+	}
+	if l := app.Lock; l != nil {
+		fmt.Fprintf(cli.stdout, "Busy:       %s in progress since %v\n", l.Operation, l.Since)
+	}
+	if l := app.DeployLock; l != nil {
+		fmt.Fprintf(cli.stdout, "Deploy:     locked by %s since %v", l.By, l.Since)
+		if l.Reason != "" {
+			fmt.Fprintf(cli.stdout, ": %s", l.Reason)
+		}
+		fmt.Fprintln(cli.stdout)
+	}
+	if len(app.DeployWindows) != 0 {
+		fmt.Fprintf(cli.stdout, "Windows:    %s\n", strings.Join(app.DeployWindows, "; "))
+	}
+	if c := app.Canary; c != nil {
+		printCanary(cli.stdout, c)
+	}
+	if p := app.AccessPolicy; p != nil {
+		printAccessPolicy(cli.stdout, p)
+	}
+	if s := app.HTTPSettings; s != nil {
+		printHTTPSettings(cli.stdout, s)
+	}
+	if l := app.Logging; l != nil {
+		printLogging(cli.stdout, l)
+	}
+	fmt.Fprintf(cli.stdout, "Services:\n")
+	for _, p := range app.Services {
+		fmt.Fprintf(cli.stdout, " - %s %s\n", p.DisplayName, p.Version)
+	}
+	if len(app.Routes) != 0 {
+		fmt.Fprintf(cli.stdout, "Routes:\n")
+		for _, rt := range app.Routes {
+			state := "healthy"
+			if !rt.Healthy {
+				state = "unhealthy: " + rt.Error
+			}
+			fmt.Fprintf(cli.stdout, " - %s -> %s (%s)\n", rt.Frontend, rt.Backend, state)
 		}
 	}
 
@@ -316,6 +401,16 @@ func (cli *CWCli) upload(name, path string, binary bool) error {
 	tw.Close()
 	zw.Close()
 
+	// send large archives in resumable chunks
+	if fi, err := tempfile.Stat(); err == nil && fi.Size() > client.UploadChunkSize {
+		ctx := context.Background()
+		upload, err := cli.resumableUpload(ctx, name, "", tempfile, fi.Size())
+		if err != nil {
+			return err
+		}
+		return cli.UploadFrom(ctx, name, upload.ID, binary, cli.stdout, cli.stderr)
+	}
+
 	// rewind for read
 	if _, err = tempfile.Seek(0, os.SEEK_SET); err != nil {
 		return err
@@ -324,13 +419,25 @@ func (cli *CWCli) upload(name, path string, binary bool) error {
 	return cli.Upload(context.Background(), name, tempfile, binary, cli.stdout, cli.stderr)
 }
 
+// resumableUpload sends the file in chunks, retrying failed chunks from
+// the offset received by the server.
+func (cli *CWCli) resumableUpload(ctx context.Context, name, id string, f *os.File, size int64) (*types.Upload, error) {
+	return cli.ResumableUpload(ctx, name, id, f, size, func(u *types.Upload, err error) {
+		fmt.Fprintf(cli.stderr, "Upload interrupted at %s of %s, retrying: %v\n",
+			units.BytesSize(float64(u.Offset)), units.BytesSize(float64(u.Size)), err)
+	})
+}
+
 func (cli *CWCli) CmdAppDump(args ...string) (err error) {
-	var output string
+	var output, service string
+	var noCompress bool
 
 	cmd := cli.Subcmd("app:dump", "")
 	cmd.Require(mflag.Exact, 0)
 	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.StringVar(&service, []string{"s", "-service"}, "", "Dump data of the service only")
 	cmd.StringVar(&output, []string{"o"}, "", "Specify the output file")
+	cmd.BoolVar(&noCompress, []string{"-no-compress"}, false, "Dump a plain tar archive, useful if the data is already compressed")
 	cmd.ParseFlags(args, true)
 	name := cli.getAppName(cmd)
 
@@ -349,7 +456,7 @@ func (cli *CWCli) CmdAppDump(args ...string) (err error) {
 		defer out.Close()
 	}
 
-	r, err := cli.Dump(context.Background(), name)
+	r, err := cli.DumpService(context.Background(), name, service, client.ArchiveOptions{NoCompress: noCompress})
 	if err != nil {
 		return err
 	}
@@ -360,12 +467,69 @@ func (cli *CWCli) CmdAppDump(args ...string) (err error) {
 }
 
 func (cli *CWCli) CmdAppRestore(args ...string) (err error) {
-	var input string
+	var input, service, resume string
+	var force bool
 
 	cmd := cli.Subcmd("app:restore", "")
 	cmd.Require(mflag.Exact, 0)
 	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.StringVar(&service, []string{"s", "-service"}, "", "Restore data of the service only")
 	cmd.StringVar(&input, []string{"i"}, "", "Specify the input file")
+	cmd.StringVar(&resume, []string{"-resume"}, "", "Resume an interrupted upload of the input file")
+	cmd.BoolVar(&force, []string{"-force"}, false, "Restore data even if the application is protected")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	if resume != "" && input == "" {
+		return errors.New("--resume requires an input file")
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	if force {
+		cli.ConfirmApplication(name)
+	}
+
+	var in *os.File
+	if input == "" {
+		in = os.Stdin
+	} else {
+		in, err = os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		// send large files in resumable chunks, which can be resumed by
+		// another run if all retries failed
+		var fi os.FileInfo
+		if fi, err = in.Stat(); err != nil {
+			return err
+		}
+		if resume != "" || fi.Size() > client.UploadChunkSize {
+			ctx := context.Background()
+			upload, err := cli.resumableUpload(ctx, name, resume, in, fi.Size())
+			if err != nil {
+				if upload != nil && upload.ID != "" {
+					fmt.Fprintf(cli.stderr, "Upload incomplete, run with --resume %s to continue\n", upload.ID)
+				}
+				return err
+			}
+			return cli.RestoreFrom(ctx, name, service, upload.ID, cli.stdout, cli.stderr)
+		}
+	}
+
+	return cli.RestoreService(context.Background(), name, service, in, cli.stdout, cli.stderr)
+}
+
+func (cli *CWCli) CmdAppExport(args ...string) (err error) {
+	var output string
+
+	cmd := cli.Subcmd("app:export", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.StringVar(&output, []string{"o"}, "", "Specify the output file")
 	cmd.ParseFlags(args, true)
 	name := cli.getAppName(cmd)
 
@@ -373,6 +537,40 @@ func (cli *CWCli) CmdAppRestore(args ...string) (err error) {
 		return err
 	}
 
+	var out *os.File
+	if output == "" {
+		out = os.Stdout
+	} else {
+		out, err = os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	r, err := cli.ExportApplication(context.Background(), name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	_, err = io.Copy(out, r)
+	return err
+}
+
+func (cli *CWCli) CmdAppImport(args ...string) (err error) {
+	var input, name string
+
+	cmd := cli.Subcmd("app:import", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.StringVar(&name, []string{"a", "-app"}, "", "Name of the imported application, defaults to the name in the bundle")
+	cmd.StringVar(&input, []string{"i"}, "", "Specify the input file")
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
 	var in *os.File
 	if input == "" {
 		in = os.Stdin
@@ -384,7 +582,155 @@ func (cli *CWCli) CmdAppRestore(args ...string) (err error) {
 		defer in.Close()
 	}
 
-	return cli.Restore(context.Background(), name, in)
+	info, err := cli.ImportApplication(context.Background(), name, in, cli.stdout, cli.stderr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.stdout, "Application %s imported\n", info.Name)
+	return nil
+}
+
+func (cli *CWCli) CmdAppMigrate(args ...string) (err error) {
+	var to, newName string
+
+	cmd := cli.Subcmd("app:migrate", "--to CONTEXT")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.StringVar(&to, []string{"-to"}, "", "The context of the target server")
+	cmd.StringVar(&newName, []string{"-name"}, "", "Name of the application on the target server, defaults to the current name")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	if to == "" {
+		return errors.New("Missing the target context, specify it with --to option")
+	}
+	target, err := cli.withContext(to)
+	if err != nil {
+		return err
+	}
+	if err = cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	if err = target.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	// the bundle is saved to a temporary file, which is kept to retry the
+	// import if failed
+	f, err := ioutil.TempFile("", name+"-export-")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ctx := context.Background()
+	fmt.Fprintf(cli.stdout, "Exporting %s from %s\n", name, cli.host)
+	r, err := cli.ExportApplication(ctx, name)
+	if err == nil {
+		_, err = io.Copy(f, r)
+		r.Close()
+	}
+	if err == nil {
+		_, err = f.Seek(0, os.SEEK_SET)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	fmt.Fprintf(cli.stdout, "Importing %s into %s\n", name, target.host)
+	info, err := target.ImportApplication(ctx, newName, f, cli.stdout, cli.stderr)
+	if err != nil {
+		fmt.Fprintf(cli.stderr, "The exported application is saved to %s, run 'cwcli --context %s app:import -i %s' to retry.\n", f.Name(), to, f.Name())
+		return err
+	}
+	os.Remove(f.Name())
+
+	fmt.Fprintf(cli.stdout, "Application %s migrated to %s, the application on %s is not removed\n", info.Name, to, cli.host)
+	return nil
+}
+
+func (cli *CWCli) CmdAppCopy(args ...string) error {
+	var service string
+	var includes, excludes []string
+
+	cmd := cli.Subcmd("app:cp", "LOCALPATH :REMOTEDIR", ":REMOTEPATH LOCALDIR")
+	cmd.Require(mflag.Exact, 2)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.StringVar(&service, []string{"s", "-service"}, "", "Service name")
+	cmd.Var(opts.NewListOptsRef(&includes, nil), []string{"-include"}, "Only copy files matching the pattern")
+	cmd.Var(opts.NewListOptsRef(&excludes, nil), []string{"-exclude"}, "Do not copy files matching the pattern")
+	cmd.ParseFlags(args, true)
+
+	src, dst := cmd.Arg(0), cmd.Arg(1)
+	srcRemote, dstRemote := strings.HasPrefix(src, ":"), strings.HasPrefix(dst, ":")
+	if srcRemote == dstRemote {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if srcRemote {
+		return cli.copyFrom(ctx, name, service, src[1:], dst, includes, excludes)
+	} else {
+		return cli.copyTo(ctx, name, service, src, dst[1:], includes, excludes)
+	}
+}
+
+func (cli *CWCli) copyFrom(ctx context.Context, name, service, src, dst string, includes, excludes []string) error {
+	r, err := cli.DownloadFiles(ctx, name, service, src, includes, excludes)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	dir, err := filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	return archive.ExtractFiles(dir, zr)
+}
+
+func (cli *CWCli) copyTo(ctx context.Context, name, service, src, dst string, includes, excludes []string) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		var err error
+		zw := gzip.NewWriter(w)
+		tw := tar.NewWriter(zw)
+		base := filepath.Base(src)
+		if fi.IsDir() {
+			err = archive.CopyFileTree(tw, base, src, nil, false)
+		} else {
+			err = archive.CopyFile(tw, src, base, 0)
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		if err == nil {
+			err = zw.Close()
+		}
+		w.CloseWithError(err)
+	}()
+
+	return cli.UploadFiles(ctx, name, service, dst, r, includes, excludes)
 }
 
 func (cli *CWCli) CmdAppSSH(args ...string) error {
@@ -444,18 +790,54 @@ func (cli *CWCli) CmdAppSSH(args ...string) error {
 
 func (cli *CWCli) CmdAppCreate(args ...string) error {
 	var req types.CreateApplication
-	var noclone, binary bool
+	var labels []string
+	var manifestFile string
+	var noclone, binary, dryRun bool
 
 	cmd := cli.Subcmd("app:create", "[OPTIONS] NAME")
 	cmd.Require(mflag.Exact, 1)
 	cmd.StringVar(&req.Framework, []string{"F", "-framework"}, "", "Application framework")
 	cmd.Var(opts.NewListOptsRef(&req.Services, nil), []string{"s", "-service"}, "Service plugins")
 	cmd.StringVar(&req.Repo, []string{"-repo"}, "", "Populate from a repository")
+	cmd.StringVar(&req.RepoUsername, []string{"-repo-user"}, "", "User name to access a private repository")
+	cmd.Var(opts.NewListOptsRef(&labels, nil), []string{"l", "-label"}, "Set label in the form of KEY=VALUE")
+	cmd.StringVar(&manifestFile, []string{"-from-manifest"}, "", "Create from an application manifest file (cloudway.yml)")
 	cmd.BoolVar(&noclone, []string{"n", "-no-clone"}, false, "Do not clone source code")
 	cmd.BoolVar(&binary, []string{"-binary"}, false, "Download binary repository")
+	cmd.BoolVar(&dryRun, []string{"-dry-run"}, false, "Show planned actions without creating the application")
 	cmd.ParseFlags(args, true)
 	req.Name = cmd.Arg(0)
 
+	if len(labels) != 0 {
+		var err error
+		if req.Labels, err = parseLabels(labels); err != nil {
+			return err
+		}
+	}
+
+	if manifestFile != "" {
+		if err := applyAppManifest(&req, manifestFile); err != nil {
+			return err
+		}
+	}
+
+	// fail early on invalid options, plugins are checked by the server
+	if err := req.Validate().Err(); err != nil {
+		return err
+	}
+
+	if dryRun {
+		if err := cli.ConnectAndLogin(); err != nil {
+			return err
+		}
+		plan, err := cli.PlanCreateApplication(context.Background(), req)
+		if err != nil {
+			return err
+		}
+		printPlan(cli.stdout, plan)
+		return nil
+	}
+
 	if !noclone {
 		if _, err := os.Stat(req.Name); !os.IsNotExist(err) {
 			if err == nil {
@@ -465,6 +847,17 @@ func (cli *CWCli) CmdAppCreate(args ...string) error {
 		}
 	}
 
+	if req.RepoUsername != "" {
+		if req.RepoPassword = os.Getenv("CLOUDWAY_REPO_PASSWORD"); req.RepoPassword == "" {
+			fmt.Fprintf(cli.stdout, "Repository password: ")
+			pass, err := gopass.GetPasswdMasked()
+			if err != nil {
+				return err
+			}
+			req.RepoPassword = string(pass)
+		}
+	}
+
 	if err := cli.ConnectAndLogin(); err != nil {
 		return err
 	}
@@ -483,23 +876,62 @@ func (cli *CWCli) CmdAppCreate(args ...string) error {
 	return nil
 }
 
+// applyAppManifest fills create options from the application manifest,
+// options given in the command line take precedence.
+func applyAppManifest(req *types.CreateApplication, filename string) error {
+	m, err := manifest.LoadAppManifest(filename)
+	if err != nil {
+		return err
+	}
+	if req.Framework == "" {
+		req.Framework = m.Framework
+	}
+	if len(req.Services) == 0 {
+		req.Services = m.Services
+	}
+	if req.Scaling == 0 {
+		req.Scaling = m.Scaling
+	}
+	req.Env = m.Env
+	return nil
+}
+
 func (cli *CWCli) CmdAppRemove(args ...string) error {
-	var yes bool
+	var yes, purge, force bool
 
 	cmd := cli.Subcmd("app:remove", "NAME")
 	cmd.Require(mflag.Exact, 1)
 	cmd.BoolVar(&yes, []string{"y"}, false, "Confirm 'yes' to remove the application")
+	cmd.BoolVar(&purge, []string{"-purge"}, false, "Remove permanently without keeping in trash")
+	cmd.BoolVar(&force, []string{"-force"}, false, "Remove the application even if protected")
 	cmd.ParseFlags(args, true)
 
-	if !yes && !cli.confirm("You will lost all your application data") {
+	if purge && !yes && !cli.confirm("You will lost all your application data") {
 		return nil
 	}
 	if err := cli.ConnectAndLogin(); err != nil {
 		return err
 	}
+	if force {
+		cli.ConfirmApplication(cmd.Arg(0))
+	}
+	if purge {
+		return cli.PurgeApplication(context.Background(), cmd.Arg(0))
+	}
 	return cli.RemoveApplication(context.Background(), cmd.Arg(0))
 }
 
+func (cli *CWCli) CmdAppRename(args ...string) error {
+	cmd := cli.Subcmd("app:rename", "NAME NEWNAME")
+	cmd.Require(mflag.Exact, 2)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.RenameApplication(context.Background(), cmd.Arg(0), cmd.Arg(1))
+}
+
 func (cli *CWCli) CmdAppStart(args ...string) error {
 	cmd := cli.Subcmd("app:start", "")
 	cmd.Require(mflag.Exact, 0)
@@ -539,6 +971,70 @@ func (cli *CWCli) CmdAppRestart(args ...string) error {
 	return cli.RestartApplication(context.Background(), name, cli.stdout, cli.stderr)
 }
 
+func (cli *CWCli) CmdAppReconcile(args ...string) error {
+	cmd := cli.Subcmd("app:reconcile", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.ReconcileApplication(context.Background(), name, cli.stdout, cli.stderr)
+}
+
+func (cli *CWCli) CmdAppMaintenance(args ...string) error {
+	var stop bool
+
+	cmd := cli.Subcmd("app:maintenance", "on|off")
+	cmd.Require(mflag.Exact, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.BoolVar(&stop, []string{"-stop"}, false, "Stop the application while in maintenance")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	var on bool
+	switch cmd.Arg(0) {
+	case "on":
+		on = true
+	case "off":
+		on = false
+	default:
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.SetMaintenance(context.Background(), name, on, stop, cli.stdout, cli.stderr)
+}
+
+func (cli *CWCli) CmdAppProtect(args ...string) error {
+	cmd := cli.Subcmd("app:protect", "on|off")
+	cmd.Require(mflag.Exact, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	var on bool
+	switch cmd.Arg(0) {
+	case "on":
+		on = true
+	case "off":
+		on = false
+	default:
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.SetProtection(context.Background(), name, on)
+}
+
 func (cli *CWCli) CmdAppStatus(args ...string) error {
 	var all, js bool
 	var name string
@@ -547,8 +1043,12 @@ func (cli *CWCli) CmdAppStatus(args ...string) error {
 	cmd.Require(mflag.Exact, 0)
 	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
 	cmd.BoolVar(&all, []string{"-all"}, false, "Display all application status")
-	cmd.BoolVar(&js, []string{"-json"}, false, "Display as JSON")
+	cmd.BoolVar(&js, []string{"-json"}, false, "Display as JSON, same as --output json")
+	output := outputFlag(cmd)
 	cmd.ParseFlags(args, true)
+	if js {
+		*output = outputJSON
+	}
 
 	if !all {
 		name = cli.getAppName(cmd)
@@ -569,9 +1069,7 @@ func (cli *CWCli) CmdAppStatus(args ...string) error {
 		if err != nil {
 			return err
 		}
-		if js {
-			cli.writeJson(status)
-		} else {
+		return cli.writeOutput(output, status, func() {
 			tab := NewTable(header...)
 			tab.SetColor(0, ansi.NewColor(ansi.FgYellow))
 			tab.SetColor(1, ansi.NewColor(ansi.FgCyan))
@@ -582,15 +1080,13 @@ func (cli *CWCli) CmdAppStatus(args ...string) error {
 				}
 			}
 			tab.Display(cli.stdout, 3)
-		}
+		})
 	} else {
 		st, err := cli.GetApplicationStatus(context.Background(), name)
 		if err != nil {
 			return err
 		}
-		if js {
-			cli.writeJson(st)
-		} else {
+		return cli.writeOutput(output, st, func() {
 			tab := NewTable(header...)
 			tab.SetColor(0, ansi.NewColor(ansi.FgYellow))
 			tab.SetColor(1, ansi.NewColor(ansi.FgCyan))
@@ -598,10 +1094,8 @@ func (cli *CWCli) CmdAppStatus(args ...string) error {
 				addRow(tab, s)
 			}
 			tab.Display(cli.stdout, 3)
-		}
+		})
 	}
-
-	return nil
 }
 
 func wrapState(state manifest.ActiveState) string {
@@ -623,9 +1117,13 @@ func (cli *CWCli) CmdAppPs(args ...string) error {
 	cmd := cli.Subcmd("app:ps", "")
 	cmd.Require(mflag.Exact, 0)
 	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
-	cmd.BoolVar(&js, []string{"-json"}, false, "Display as JSON")
+	cmd.BoolVar(&js, []string{"-json"}, false, "Display as JSON, same as --output json")
+	output := outputFlag(cmd)
 	cmd.ParseFlags(args, true)
 	name := cli.getAppName(cmd)
+	if js {
+		*output = outputJSON
+	}
 
 	if err := cli.ConnectAndLogin(); err != nil {
 		return err
@@ -636,25 +1134,17 @@ func (cli *CWCli) CmdAppPs(args ...string) error {
 		return err
 	}
 
-	if len(procs) == 0 {
-		return nil
-	}
-
-	if js {
-		cli.writeJson(procs)
-		return nil
-	}
-
-	for _, pl := range procs {
-		io.WriteString(cli.stdout, ansi.Warning(pl.ID[:12])+" "+ansi.Info(pl.DisplayName+"\n"))
-		tab := NewTable(pl.Headers...)
-		for _, row := range pl.Processes {
-			tab.AddRow(row...)
+	return cli.writeOutput(output, procs, func() {
+		for _, pl := range procs {
+			io.WriteString(cli.stdout, ansi.Warning(pl.ID[:12])+" "+ansi.Info(pl.DisplayName+"\n"))
+			tab := NewTable(pl.Headers...)
+			for _, row := range pl.Processes {
+				tab.AddRow(row...)
+			}
+			tab.Display(cli.stdout, 1)
+			fmt.Fprintln(cli.stdout)
 		}
-		tab.Display(cli.stdout, 1)
-		fmt.Fprintln(cli.stdout)
-	}
-	return nil
+	})
 }
 
 func (cli *CWCli) CmdAppStats(args ...string) error {
@@ -704,14 +1194,17 @@ func (cli *CWCli) CmdAppStats(args ...string) error {
 }
 
 func (cli *CWCli) CmdAppDeploy(args ...string) error {
-	var branch string
+	var branch, filter string
 	var show bool
+	var limit int
 
 	cmd := cli.Subcmd("app:deploy", "")
 	cmd.Require(mflag.Exact, 0)
 	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
 	cmd.StringVar(&branch, []string{"b", "-branch"}, "", "The branch to deploy")
 	cmd.BoolVar(&show, []string{"-show"}, false, "Show application deployments")
+	cmd.StringVar(&filter, []string{"-filter"}, "", "Only show branches that contain the text")
+	cmd.IntVar(&limit, []string{"-limit"}, 0, "Maximum number of branches to show")
 	cmd.ParseFlags(args, true)
 	name := cli.getAppName(cmd)
 
@@ -720,7 +1213,7 @@ func (cli *CWCli) CmdAppDeploy(args ...string) error {
 	}
 
 	if show {
-		deployments, err := cli.GetApplicationDeployments(context.Background(), name)
+		deployments, err := cli.GetApplicationDeployments(context.Background(), name, filter, limit)
 		if err != nil {
 			return err
 		}
@@ -732,6 +1225,12 @@ func (cli *CWCli) CmdAppDeploy(args ...string) error {
 			} else {
 				display = "  " + display
 			}
+			if len(ref.LatestCommit) >= 7 {
+				display += "  " + ref.LatestCommit[:7]
+				if !ref.LatestCommitTime.IsZero() {
+					display += "  " + units.HumanDuration(time.Since(ref.LatestCommitTime)) + " ago"
+				}
+			}
 			fmt.Fprintf(cli.stdout, "%s\n", display)
 		}
 
@@ -757,8 +1256,12 @@ func (cli *CWCli) CmdAppDeploy(args ...string) error {
 }
 
 func (cli *CWCli) CmdAppScale(args ...string) error {
+	var force, dryRun bool
+
 	cmd := cli.Subcmd("app:scale", "NAME [+|-]SCALING")
 	cmd.Require(mflag.Exact, 2)
+	cmd.BoolVar(&force, []string{"-force"}, false, "Scale down even if the application is protected")
+	cmd.BoolVar(&dryRun, []string{"-dry-run"}, false, "Show planned actions without scaling the application")
 	cmd.ParseFlags(args, true)
 
 	name, scale := cmd.Arg(0), cmd.Arg(1)
@@ -768,9 +1271,32 @@ func (cli *CWCli) CmdAppScale(args ...string) error {
 	if err := cli.ConnectAndLogin(); err != nil {
 		return err
 	}
+	if force {
+		cli.ConfirmApplication(name)
+	}
+	if dryRun {
+		plan, err := cli.PlanScaleApplication(context.Background(), name, scale)
+		if err != nil {
+			return err
+		}
+		printPlan(cli.stdout, plan)
+		return nil
+	}
 	return cli.ScaleApplication(context.Background(), name, scale, cli.stdout, cli.stderr)
 }
 
+func printPlan(w io.Writer, plan *types.Plan) {
+	if len(plan.Actions) == 0 {
+		fmt.Fprintln(w, "Nothing to do")
+	}
+	for i, a := range plan.Actions {
+		fmt.Fprintf(w, "%2d. %s\n", i+1, a.Description)
+	}
+	for _, warning := range plan.Warnings {
+		fmt.Fprintf(w, "%s %s\n", ansi.Warning("Warning:"), warning)
+	}
+}
+
 func (cli *CWCli) CmdAppEnv(args ...string) error {
 	var service string
 	var del bool
@@ -783,6 +1309,7 @@ func (cli *CWCli) CmdAppEnv(args ...string) error {
 	cmd.BoolVar(&del, []string{"d"}, false, "Remove the environment variable")
 	cmd.BoolVar(&all, []string{"A", "-all"}, false, "Show all environment variables")
 	cmd.BoolVar(&showPassword, []string{"p", "-show-password"}, false, "Show password environment variable values")
+	output := outputFlag(cmd)
 	cmd.ParseFlags(args, true)
 	name := cli.getAppName(cmd)
 
@@ -824,9 +1351,11 @@ func (cli *CWCli) CmdAppEnv(args ...string) error {
 			}
 		}
 
-		for k, v := range env {
-			fmt.Fprintf(cli.stdout, "%s=%s\n", k, v)
-		}
+		return cli.writeOutput(output, env, func() {
+			for k, v := range env {
+				fmt.Fprintf(cli.stdout, "%s=%s\n", k, v)
+			}
+		})
 
 	case cmd.NArg() == 1 && !strings.ContainsRune(cmd.Arg(0), '='):
 		// cwcli app:env key
@@ -871,6 +1400,7 @@ func (cli *CWCli) CmdAppService(args ...string) error {
 	cmd.Require(mflag.Exact, 0)
 	cmd.BoolVar(&help, []string{"-help"}, false, "Print usage")
 	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	output := outputFlag(cmd)
 	cmd.ParseFlags(args, false)
 
 	if help {
@@ -888,10 +1418,11 @@ func (cli *CWCli) CmdAppService(args ...string) error {
 	if err != nil {
 		return err
 	}
-	for _, p := range app.Services {
-		fmt.Fprintf(cli.stdout, "%-12.12s%s\n", p.Name, p.DisplayName)
-	}
-	return nil
+	return cli.writeOutput(output, app.Services, func() {
+		for _, p := range app.Services {
+			fmt.Fprintf(cli.stdout, "%-12.12s%s\n", p.Name, p.DisplayName)
+		}
+	})
 }
 
 func (cli *CWCli) CmdAppServiceAdd(args ...string) error {
@@ -0,0 +1,93 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/docker/go-units"
+
+	"github.com/cloudway/platform/cmd/cwcli/cmds/ansi"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+const appArtifactsUsage = `Usage: cwcli app:artifacts [COMMAND]
+
+List build artifacts of the application, the latest first. The currently
+deployed artifact is marked with an asterisk.
+
+Additional commands, type "cwcli help app:artifacts COMMAND" for more details:
+
+  deploy             Deploy a previous build artifact
+`
+
+func (cli *CWCli) CmdAppArtifacts(args ...string) error {
+	var help bool
+
+	cmd := cli.Subcmd("app:artifacts", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.BoolVar(&help, []string{"-help"}, false, "Print usage")
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	output := outputFlag(cmd)
+	cmd.ParseFlags(args, false)
+
+	if help {
+		fmt.Fprintln(cli.stdout, appArtifactsUsage)
+		os.Exit(0)
+	}
+
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	artifacts, err := cli.GetArtifacts(context.Background(), name)
+	if err != nil {
+		return err
+	}
+
+	return cli.writeOutput(output, artifacts, func() {
+		current := -1
+		for i, a := range artifacts {
+			if a.DeployedAt != nil && (current < 0 || a.DeployedAt.After(*artifacts[current].DeployedAt)) {
+				current = i
+			}
+		}
+
+		tab := NewTable("", "ID", "SOURCE", "FILE", "SIZE", "COMMIT", "VERSION", "UPLOADED")
+		tab.SetColor(1, ansi.NewColor(ansi.FgCyan))
+		for i, a := range artifacts {
+			mark := ""
+			if i == current {
+				mark = "*"
+			}
+			tab.AddRow(mark, a.ID, a.Source, a.Filename, units.HumanSize(float64(a.Size)),
+				shortCommit(a.Commit), a.Version, units.HumanDuration(time.Since(a.UploadedAt))+" ago")
+		}
+		tab.Display(cli.stdout, 2)
+	})
+}
+
+func (cli *CWCli) CmdAppArtifactsDeploy(args ...string) error {
+	cmd := cli.Subcmd("app:artifacts deploy", "ID")
+	cmd.Require(mflag.Exact, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	_, err := cli.RedeployArtifact(context.Background(), name, cmd.Arg(0), cli.stdout, cli.stderr)
+	return err
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 10 {
+		return commit[:10]
+	}
+	return commit
+}
@@ -40,11 +40,16 @@ func (cli *CWCli) CmdLogout(args ...string) error {
 	cmd.Require(mflag.Exact, 0)
 	cmd.ParseFlags(args, true)
 
+	cli.logout()
+	return nil
+}
+
+// logout removes the saved token of the current host or context.
+func (cli *CWCli) logout() {
 	if cli.host != "" {
-		config.RemoveOption(cli.host, "token")
+		config.RemoveOption(cli.tokenSection(), "token")
 		config.Save()
 	}
-	return nil
 }
 
 func (c *CWCli) authenticate(prompt, username, password string) (err error) {
@@ -82,7 +87,7 @@ func (c *CWCli) authenticate(prompt, username, password string) (err error) {
 	}
 
 	c.SetToken(token)
-	config.AddOption(c.host, "token", token)
+	config.AddOption(c.tokenSection(), "token", token)
 	config.Save()
 	return nil
 }
@@ -0,0 +1,87 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+const appCollabUsage = `Usage: cwcli app:collab [COMMAND]
+
+List users granted access to the application. Collaborators address the
+application with its full name "name-namespace".
+
+Additional commands, type "cwcli help app:collab COMMAND" for more details:
+
+  add                Grant a user access to the application
+  remove             Revoke access of a user to the application
+`
+
+func (cli *CWCli) CmdAppCollab(args ...string) error {
+	var help bool
+
+	cmd := cli.Subcmd("app:collab", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.BoolVar(&help, []string{"-help"}, false, "Print usage")
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	output := outputFlag(cmd)
+	cmd.ParseFlags(args, false)
+
+	if help {
+		fmt.Fprintln(cli.stdout, appCollabUsage)
+		os.Exit(0)
+	}
+
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	collaborators, err := cli.GetCollaborators(context.Background(), name)
+	if err != nil {
+		return err
+	}
+	return cli.writeOutput(output, collaborators, func() {
+		for _, c := range collaborators {
+			fmt.Fprintf(cli.stdout, "%-8s%s\n", c.Access, c.User)
+		}
+	})
+}
+
+func (cli *CWCli) CmdAppCollabAdd(args ...string) error {
+	var deploy bool
+
+	cmd := cli.Subcmd("app:collab add", "USER")
+	cmd.Require(mflag.Exact, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.BoolVar(&deploy, []string{"-deploy"}, false, "Allow the user to deploy, start and stop the application")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	access := "read"
+	if deploy {
+		access = "deploy"
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.AddCollaborator(context.Background(), name, cmd.Arg(0), access)
+}
+
+func (cli *CWCli) CmdAppCollabRemove(args ...string) error {
+	cmd := cli.Subcmd("app:collab remove", "USER")
+	cmd.Require(mflag.Exact, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.RemoveCollaborator(context.Background(), name, cmd.Arg(0))
+}
@@ -2,12 +2,14 @@ package cmds
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
 
+	"github.com/cloudway/platform/api"
 	"github.com/cloudway/platform/api/client"
 	"github.com/cloudway/platform/cmd/cwcli/cmds/ansi"
 	"github.com/cloudway/platform/config"
@@ -24,41 +26,89 @@ type Command struct {
 type CWCli struct {
 	*cli.Cli
 	host string
+
+	// The name of the server context in use, if the host is taken from
+	// the context.
+	contextName string
+
 	*client.APIClient
 	stdout, stderr io.Writer
 	handlers       map[string]func(...string) error
+	shownNotices   map[string]bool
 }
 
 // Commands lists the top level commands and their short usage
 var CommandUsage = []Command{
 	{"login", "Login to a Cloudway server"},
 	{"logout", "Log out from a Cloudway server"},
+	{"context", "Manage named server contexts"},
+	{"context add", "Add a server context"},
+	{"context use", "Set the current context"},
+	{"context remove", "Remove a server context"},
 	{"namespace", "Get or set application namespace"},
+	{"account", "Show, change or delete the user account"},
+	{"account:prefs", "Get or set user interface preferences"},
 	{"app", "Manage applications"},
 	{"app:create", "Create application"},
 	{"app:remove", "Permanently remove an application"},
+	{"app:rename", "Rename an application"},
+	{"trash", "List, restore or purge removed applications"},
 	{"app:start", "Start an application"},
 	{"app:stop", "Stop an application"},
 	{"app:restart", "Restart an application"},
+	{"app:maintenance", "Turn application maintenance mode on or off"},
+	{"app:reconcile", "Repair drift between application state and containers"},
+	{"app:protect", "Turn application deletion protection on or off"},
 	{"app:status", "Show application status"},
 	{"app:ps", "Show application processes"},
 	{"app:stats", "Display application live resource usage statistics"},
 	{"app:service", "Manage application services"},
 	{"app:service add", "Add services to the application"},
 	{"app:service remove", "Remove service from the application"},
+	{"app:collab", "Manage application collaborators"},
+	{"app:collab add", "Grant a user access to the application"},
+	{"app:collab remove", "Revoke access of a user to the application"},
+	{"app:link", "Manage services linked from other applications"},
+	{"app:link add", "Link a service of another application"},
+	{"app:link remove", "Remove the link to a service of another application"},
 	{"app:clone", "Clone application source code"},
 	{"app:deploy", "Deploy an application"},
+	{"app:lock", "Lock deployments of an application"},
+	{"app:unlock", "Unlock deployments of an application"},
+	{"app:windows", "Restrict deployments to time windows"},
+	{"app:traffic", "Split traffic for canary releases"},
+	{"app:access", "Restrict access to an application"},
+	{"app:https", "Configure HTTPS, error pages and proxied connections"},
+	{"app:logging", "Ship application logs to an external collector"},
 	{"app:upload", "Upload an application repository"},
+	{"app:artifacts", "List application build artifacts"},
+	{"app:artifacts deploy", "Deploy a previous build artifact"},
 	{"app:dump", "Dump application data"},
 	{"app:restore", "Restore application data"},
+	{"app:export", "Export an application to a portable bundle"},
+	{"app:import", "Import an application from a bundle"},
+	{"app:migrate", "Migrate an application to another server context"},
+	{"app:cp", "Copy files between local host and application"},
 	{"app:scale", "Scale an application"},
 	{"app:info", "Show application information"},
 	{"app:env", "Get or set application environment variables"},
+	{"app:label", "Get or set application labels"},
 	{"app:open", "Open the application in a web brower"},
 	{"app:ssh", "Log into application console via SSH"},
+	{"service", "Manage standalone services"},
+	{"service:create", "Create a standalone service"},
+	{"service:remove", "Remove a standalone service"},
+	{"service:start", "Start a standalone service"},
+	{"service:stop", "Stop a standalone service"},
+	{"service:restart", "Restart a standalone service"},
+	{"service:bind", "Link a standalone service into an application"},
+	{"service:unbind", "Remove the link to a standalone service"},
 	{"plugin", "Show plugin information"},
 	{"plugin:install", "Install a user defined plugin"},
 	{"plugin:remove", "Remove a user defined plugin"},
+	{"notices", "Show platform announcements"},
+	{"completion", "Print the shell completion script"},
+	{"events", "Show platform events of applications"},
 	{"version", "Show the version information"},
 }
 
@@ -70,44 +120,87 @@ func init() {
 	}
 }
 
-func Init(host string, stdout, stderr io.Writer) *CWCli {
+// Init creates the client connecting to the host, or the server of the
+// named context if the host is empty.
+func Init(host, contextName string, stdout, stderr io.Writer) *CWCli {
 	c := new(CWCli)
 	c.Cli = cli.New("cwcli", c)
 	c.Description = "Cloudway client interface"
 	c.host = host
+	c.contextName = contextName
 	c.stdout = stdout
 	c.stderr = stderr
 
 	c.handlers = map[string]func(...string) error{
-		"login":              c.CmdLogin,
-		"logout":             c.CmdLogout,
-		"namespace":          c.CmdNamespace,
-		"app":                c.CmdApps,
-		"app:create":         c.CmdAppCreate,
-		"app:remove":         c.CmdAppRemove,
-		"app:start":          c.CmdAppStart,
-		"app:stop":           c.CmdAppStop,
-		"app:restart":        c.CmdAppRestart,
-		"app:status":         c.CmdAppStatus,
-		"app:ps":             c.CmdAppPs,
-		"app:stats":          c.CmdAppStats,
-		"app:service":        c.CmdAppService,
-		"app:service add":    c.CmdAppServiceAdd,
-		"app:service remove": c.CmdAppServiceRemove,
-		"app:clone":          c.CmdAppClone,
-		"app:deploy":         c.CmdAppDeploy,
-		"app:upload":         c.CmdAppUpload,
-		"app:dump":           c.CmdAppDump,
-		"app:restore":        c.CmdAppRestore,
-		"app:scale":          c.CmdAppScale,
-		"app:info":           c.CmdAppInfo,
-		"app:env":            c.CmdAppEnv,
-		"app:open":           c.CmdAppOpen,
-		"app:ssh":            c.CmdAppSSH,
-		"plugin":             c.CmdPlugin,
-		"plugin:install":     c.CmdPluginInstall,
-		"plugin:remove":      c.CmdPluginRemove,
-		"version":            c.CmdVersion,
+		"login":                c.CmdLogin,
+		"logout":               c.CmdLogout,
+		"context":              c.CmdContext,
+		"context add":          c.CmdContextAdd,
+		"context use":          c.CmdContextUse,
+		"context remove":       c.CmdContextRemove,
+		"namespace":            c.CmdNamespace,
+		"account":              c.CmdAccount,
+		"account:prefs":        c.CmdAccountPrefs,
+		"app":                  c.CmdApps,
+		"app:create":           c.CmdAppCreate,
+		"app:remove":           c.CmdAppRemove,
+		"app:start":            c.CmdAppStart,
+		"app:stop":             c.CmdAppStop,
+		"app:restart":          c.CmdAppRestart,
+		"app:maintenance":      c.CmdAppMaintenance,
+		"app:reconcile":        c.CmdAppReconcile,
+		"app:status":           c.CmdAppStatus,
+		"app:ps":               c.CmdAppPs,
+		"app:stats":            c.CmdAppStats,
+		"app:service":          c.CmdAppService,
+		"app:service add":      c.CmdAppServiceAdd,
+		"app:service remove":   c.CmdAppServiceRemove,
+		"app:collab":           c.CmdAppCollab,
+		"app:collab add":       c.CmdAppCollabAdd,
+		"app:collab remove":    c.CmdAppCollabRemove,
+		"app:link":             c.CmdAppLink,
+		"app:link add":         c.CmdAppLinkAdd,
+		"app:link remove":      c.CmdAppLinkRemove,
+		"app:clone":            c.CmdAppClone,
+		"app:deploy":           c.CmdAppDeploy,
+		"app:lock":             c.CmdAppLock,
+		"app:unlock":           c.CmdAppUnlock,
+		"app:windows":          c.CmdAppWindows,
+		"app:traffic":          c.CmdAppTraffic,
+		"app:access":           c.CmdAppAccess,
+		"app:https":            c.CmdAppHTTPS,
+		"app:logging":          c.CmdAppLogging,
+		"app:upload":           c.CmdAppUpload,
+		"app:artifacts":        c.CmdAppArtifacts,
+		"app:artifacts deploy": c.CmdAppArtifactsDeploy,
+		"app:dump":             c.CmdAppDump,
+		"app:restore":          c.CmdAppRestore,
+		"app:export":           c.CmdAppExport,
+		"app:import":           c.CmdAppImport,
+		"app:migrate":          c.CmdAppMigrate,
+		"app:cp":               c.CmdAppCopy,
+		"app:scale":            c.CmdAppScale,
+		"app:info":             c.CmdAppInfo,
+		"app:env":              c.CmdAppEnv,
+		"app:label":            c.CmdAppLabel,
+		"app:open":             c.CmdAppOpen,
+		"app:ssh":              c.CmdAppSSH,
+		"service":              c.CmdService,
+		"service:create":       c.CmdServiceCreate,
+		"service:remove":       c.CmdServiceRemove,
+		"service:start":        c.CmdServiceStart,
+		"service:stop":         c.CmdServiceStop,
+		"service:restart":      c.CmdServiceRestart,
+		"service:bind":         c.CmdServiceBind,
+		"service:unbind":       c.CmdServiceUnbind,
+		"plugin":               c.CmdPlugin,
+		"plugin:install":       c.CmdPluginInstall,
+		"plugin:remove":        c.CmdPluginRemove,
+		"notices":              c.CmdNotices,
+		"events":               c.CmdEvents,
+		"completion":           c.CmdCompletion,
+		"__complete":           c.CmdComplete,
+		"version":              c.CmdVersion,
 	}
 
 	return c
@@ -130,16 +223,29 @@ func (c *CWCli) Connect() (err error) {
 		return nil
 	}
 
+	if c.host == "" && c.contextName != "" {
+		if c.host, err = contextHost(c.contextName); err != nil {
+			return err
+		}
+	}
 	if c.host == "" {
 		c.host = c.getAppConfig("host")
 		if c.host == "" {
 			c.host = gitGetConfig("cloudway.host")
 		}
+		if c.host == "" {
+			if name := config.Get("context"); name != "" {
+				if c.host, err = contextHost(name); err != nil {
+					return err
+				}
+				c.contextName = name
+			}
+		}
 		if c.host == "" {
 			c.host = config.Get("host")
 		}
 		if c.host == "" {
-			return errors.New("No remote host specified, please run cwcli with -H option")
+			return errors.New("No remote host specified, please run cwcli with -H or --context option")
 		}
 	}
 
@@ -147,8 +253,29 @@ func (c *CWCli) Connect() (err error) {
 		"Accept": "application/json",
 	}
 
-	c.APIClient, err = client.NewAPIClient(c.host+"/api", "", nil, headers)
-	return err
+	// request the API version of this client so that the server can keep
+	// compatibility with the client after breaking changes
+	var version string
+	if client.IsReleaseVersion(api.Version) {
+		version = api.Version
+	}
+	c.APIClient, err = client.NewAPIClient(c.host+"/api", version, nil, headers)
+	if err != nil {
+		return err
+	}
+
+	if os.Getenv("CWCLI_DEBUG") == "1" {
+		c.APIClient.SetTraceWriter(c.stderr)
+	}
+	if err = c.APIClient.NegotiateAPIVersion(context.Background()); err != nil {
+		c.APIClient = nil
+		return err
+	}
+	if ansi.IsTerminal {
+		c.APIClient.SetProgressHandler(c.showProgress)
+	}
+	c.APIClient.SetNoticeHandler(c.showNotices)
+	return nil
 }
 
 func (c *CWCli) ConnectAndLogin() (err error) {
@@ -156,7 +283,7 @@ func (c *CWCli) ConnectAndLogin() (err error) {
 		return err
 	}
 
-	token := config.GetOption(c.host, "token")
+	token := config.GetOption(c.tokenSection(), "token")
 	if token != "" {
 		c.SetToken(token)
 	} else {
@@ -165,6 +292,16 @@ func (c *CWCli) ConnectAndLogin() (err error) {
 	return err
 }
 
+// tokenSection returns the configuration section saving the access token,
+// which is the context if the host is taken from the context, so different
+// users can login to the same host with different contexts.
+func (c *CWCli) tokenSection() string {
+	if c.contextName != "" {
+		return contextSection(c.contextName)
+	}
+	return c.host
+}
+
 func (cli *CWCli) confirm(prompt string) bool {
 	reader := bufio.NewReader(os.Stdin)
 	for {
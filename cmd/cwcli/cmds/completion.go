@@ -0,0 +1,223 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+const completionUsage = `Usage: cwcli completion SHELL
+
+Print the completion script for the shell, which is one of bash, zsh or fish.
+Commands, application names and context names are completed, application
+names are retrieved from the server if you are logged in.
+
+To load completions in the current shell:
+
+  bash:  source <(cwcli completion bash)
+  zsh:   source <(cwcli completion zsh)
+  fish:  cwcli completion fish | source
+
+Add the command to ~/.bashrc, ~/.zshrc or ~/.config/fish/config.fish to
+load completions in every new shell.
+`
+
+// The completion scripts pass words in the command line to the hidden
+// __complete command, the last word is the one being completed, and the
+// command prints candidates one per line.
+
+const bashCompletion = `# bash completion for cwcli
+_cwcli() {
+    local line="${COMP_LINE:0:$COMP_POINT}"
+    local -a words
+    read -r -a words <<< "$line"
+    if [[ "$line" == *" " ]]; then
+        words+=("")
+    fi
+    local cur="${words[${#words[@]}-1]}"
+
+    local IFS=$'\n'
+    COMPREPLY=($(compgen -W "$(cwcli __complete "${words[@]:1}" 2>/dev/null)" -- "$cur"))
+
+    # colons are word breaks in bash, remove the completed part before colon
+    if [[ "$cur" == *:* && "$COMP_WORDBREAKS" == *:* ]]; then
+        local prefix="${cur%"${cur##*:}"}"
+        local i=${#COMPREPLY[@]}
+        while [[ $((--i)) -ge 0 ]]; do
+            COMPREPLY[$i]="${COMPREPLY[$i]#"$prefix"}"
+        done
+    fi
+}
+complete -o default -F _cwcli cwcli
+`
+
+const zshCompletion = `#compdef cwcli
+# zsh completion for cwcli
+_cwcli() {
+    local -a candidates
+    candidates=(${(f)"$(cwcli __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+    if (( ${#candidates} )); then
+        compadd -a candidates
+    else
+        _files
+    fi
+}
+compdef _cwcli cwcli
+`
+
+const fishCompletion = `# fish completion for cwcli
+function __cwcli_complete
+    set -l words (commandline -opc)
+    set -e words[1]
+    cwcli __complete $words (commandline -ct) 2>/dev/null
+end
+complete -c cwcli -f -a '(__cwcli_complete)'
+`
+
+func (cli *CWCli) CmdCompletion(args ...string) error {
+	var help bool
+
+	cmd := cli.Subcmd("completion", "SHELL")
+	cmd.Require(mflag.Max, 1)
+	cmd.BoolVar(&help, []string{"-help"}, false, "Print usage")
+	cmd.ParseFlags(args, false)
+
+	if help || cmd.NArg() == 0 {
+		fmt.Fprintln(cli.stdout, completionUsage)
+		os.Exit(0)
+	}
+
+	var script string
+	switch cmd.Arg(0) {
+	case "bash":
+		script = bashCompletion
+	case "zsh":
+		script = zshCompletion
+	case "fish":
+		script = fishCompletion
+	default:
+		return fmt.Errorf("Unsupported shell '%s', must be one of bash, zsh or fish", cmd.Arg(0))
+	}
+	_, err := io.WriteString(cli.stdout, script)
+	return err
+}
+
+// CmdComplete prints completion candidates of the last word, it's called
+// by completion scripts and is not listed in the usage.
+func (cli *CWCli) CmdComplete(words ...string) error {
+	for _, c := range cli.complete(words) {
+		fmt.Fprintln(cli.stdout, c)
+	}
+	return nil
+}
+
+func (cli *CWCli) complete(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur, words := words[len(words)-1], words[:len(words)-1]
+
+	// skip global options, remembering the server to complete from
+	var command []string
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		switch {
+		case len(command) != 0:
+			command = append(command, w)
+		case w == "-H" || w == "--host":
+			if i++; i < len(words) {
+				if host, err := ParseHost(words[i]); err == nil {
+					cli.host = host
+				}
+			}
+		case w == "--context":
+			if i++; i < len(words) {
+				cli.contextName = strings.ToLower(words[i])
+			}
+		case strings.HasPrefix(w, "-"):
+		default:
+			command = append(command, w)
+		}
+	}
+
+	var prev string
+	if len(words) != 0 {
+		prev = words[len(words)-1]
+	}
+
+	switch prev {
+	case "-H", "--host":
+		return nil
+	case "--context", "--to":
+		return contextNames()
+	case "--output":
+		return []string{outputTable, outputJSON, outputYAML}
+	case "-a", "--app":
+		return cli.completeApps()
+	}
+
+	switch {
+	case len(command) == 0:
+		return completeCommands("")
+	case strings.HasPrefix(cur, "-"):
+		return nil
+	case len(command) == 1 && command[0] == "help":
+		return completeCommands("")
+	case len(command) == 1 && command[0] == "completion":
+		return []string{"bash", "fish", "zsh"}
+	case len(command) == 1:
+		return completeCommands(command[0] + " ")
+	case len(command) == 2 && command[0] == "help":
+		return completeCommands(command[1] + " ")
+	case len(command) == 2 && command[0] == "context" && (command[1] == "use" || command[1] == "remove"):
+		return contextNames()
+	default:
+		return nil
+	}
+}
+
+// completeCommands returns names of commands with the prefix, and the prefix
+// removed. Subcommands are only returned for a non-empty prefix.
+func completeCommands(prefix string) []string {
+	var names []string
+	if prefix == "" {
+		names = append(names, "help")
+	}
+	for _, cmd := range CommandUsage {
+		if !strings.HasPrefix(cmd.Name, prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(cmd.Name, prefix)
+		if !strings.Contains(name, " ") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completeApps returns names of applications on the server. Nothing is
+// returned if the user is not logged in, since a password prompt is not
+// possible while completing.
+func (cli *CWCli) completeApps() []string {
+	if err := cli.Connect(); err != nil {
+		return nil
+	}
+	token := config.GetOption(cli.tokenSection(), "token")
+	if token == "" {
+		return nil
+	}
+	cli.SetToken(token)
+
+	apps, err := cli.GetApplications(context.Background())
+	if err != nil {
+		return nil
+	}
+	return apps
+}
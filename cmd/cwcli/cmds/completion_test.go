@@ -0,0 +1,13 @@
+package cmds
+
+import "fmt"
+
+func Example_completeCommands() {
+	for _, name := range completeCommands("app:collab ") {
+		fmt.Println(name)
+	}
+
+	// Output:
+	// add
+	// remove
+}
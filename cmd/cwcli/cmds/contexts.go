@@ -0,0 +1,190 @@
+package cmds
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cloudway/platform/cmd/cwcli/cmds/ansi"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+const contextUsage = `Usage: cwcli context [COMMAND]
+
+List named server contexts. A context saves the URL and access token of a
+Cloudway server, so commands can be run against multiple servers, such as
+installs in different regions, with "cwcli --context NAME COMMAND". The
+current context is used if no host or context is specified.
+
+Additional commands, type "cwcli help context COMMAND" for more details:
+
+  add                Add a server context
+  use                Set the current context
+  remove             Remove a server context
+`
+
+// Contexts are saved in the "context:NAME" sections of the client
+// configuration, and the current context is saved in the "context" key.
+// Context names are case insensitive as section names.
+const contextSectionPrefix = "context:"
+
+func contextSection(name string) string {
+	return contextSectionPrefix + name
+}
+
+// contextHost returns the host URL of the named context.
+func contextHost(name string) (string, error) {
+	host := config.GetOption(contextSection(name), "host")
+	if host == "" {
+		return "", fmt.Errorf("Context '%s' not found, run 'cwcli context add' to add it", name)
+	}
+	return host, nil
+}
+
+// contextNames returns names of contexts sorted by name.
+func contextNames() []string {
+	var names []string
+	for _, section := range config.GetSections() {
+		if strings.HasPrefix(section, contextSectionPrefix) {
+			names = append(names, strings.TrimPrefix(section, contextSectionPrefix))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseHost normalizes the host URL given in command line, which defaults
+// to the http scheme.
+func ParseHost(host string) (string, error) {
+	if strings.Contains(host, "://") {
+		if u, err := url.Parse(host); err != nil {
+			return "", err
+		} else {
+			u.Path = ""
+			return u.String(), nil
+		}
+	} else {
+		return "http://" + host, nil
+	}
+}
+
+// contextInfo is the context listed in the JSON or YAML output.
+type contextInfo struct {
+	Name     string
+	Host     string
+	Current  bool
+	LoggedIn bool
+}
+
+func (cli *CWCli) CmdContext(args ...string) error {
+	var help bool
+
+	cmd := cli.Subcmd("context", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.BoolVar(&help, []string{"-help"}, false, "Print usage")
+	output := outputFlag(cmd)
+	cmd.ParseFlags(args, false)
+
+	if help {
+		fmt.Fprintln(cli.stdout, contextUsage)
+		os.Exit(0)
+	}
+
+	current := config.Get("context")
+	contexts := []contextInfo{}
+	for _, name := range contextNames() {
+		section := contextSection(name)
+		contexts = append(contexts, contextInfo{
+			Name:     name,
+			Host:     config.GetOption(section, "host"),
+			Current:  name == current,
+			LoggedIn: config.GetOption(section, "token") != "",
+		})
+	}
+
+	return cli.writeOutput(output, contexts, func() {
+		tab := NewTable("", "NAME", "HOST", "LOGGED IN")
+		tab.SetColor(1, ansi.NewColor(ansi.FgCyan))
+		for _, c := range contexts {
+			mark, login := "", "no"
+			if c.Current {
+				mark = "*"
+			}
+			if c.LoggedIn {
+				login = "yes"
+			}
+			tab.AddRow(mark, c.Name, c.Host, login)
+		}
+		tab.Display(cli.stdout, 2)
+	})
+}
+
+func (cli *CWCli) CmdContextAdd(args ...string) error {
+	var use bool
+
+	cmd := cli.Subcmd("context add", "NAME HOST")
+	cmd.Require(mflag.Exact, 2)
+	cmd.BoolVar(&use, []string{"-use"}, false, "Set as the current context")
+	cmd.ParseFlags(args, true)
+
+	name := strings.ToLower(cmd.Arg(0))
+	if name == "" || strings.ContainsAny(name, "[]=: \t") {
+		return fmt.Errorf("Invalid context name '%s'", name)
+	}
+	host, err := ParseHost(cmd.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	// the token of the previous host is no longer valid
+	section := contextSection(name)
+	if config.GetOption(section, "host") != host {
+		config.RemoveOption(section, "token")
+	}
+	config.AddOption(section, "host", host)
+	if use {
+		config.Set("context", name)
+	}
+	return config.Save()
+}
+
+func (cli *CWCli) CmdContextUse(args ...string) error {
+	cmd := cli.Subcmd("context use", "NAME")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	name := strings.ToLower(cmd.Arg(0))
+	if _, err := contextHost(name); err != nil {
+		return err
+	}
+	config.Set("context", name)
+	return config.Save()
+}
+
+func (cli *CWCli) CmdContextRemove(args ...string) error {
+	cmd := cli.Subcmd("context remove", "NAME")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	name := strings.ToLower(cmd.Arg(0))
+	if _, err := contextHost(name); err != nil {
+		return err
+	}
+	config.RemoveSection(contextSection(name))
+	if config.Get("context") == name {
+		config.Remove("context")
+	}
+	return config.Save()
+}
+
+// withContext returns a new client connected to the server of the named
+// context, with the same output streams.
+func (cli *CWCli) withContext(name string) (*CWCli, error) {
+	if _, err := contextHost(name); err != nil {
+		return nil, err
+	}
+	return Init("", name, cli.stdout, cli.stderr), nil
+}
@@ -0,0 +1,66 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+func (cli *CWCli) CmdAppLock(args ...string) error {
+	var reason string
+
+	cmd := cli.Subcmd("app:lock", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.StringVar(&reason, []string{"m", "-reason"}, "", "The reason to lock deployments")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.LockDeployments(context.Background(), name, reason)
+}
+
+func (cli *CWCli) CmdAppUnlock(args ...string) error {
+	cmd := cli.Subcmd("app:unlock", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.UnlockDeployments(context.Background(), name)
+}
+
+func (cli *CWCli) CmdAppWindows(args ...string) error {
+	var clear bool
+
+	cmd := cli.Subcmd("app:windows", "[WINDOW...]")
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.BoolVar(&clear, []string{"-clear"}, false, "Remove deploy windows of the application")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if clear || cmd.NArg() != 0 {
+		return cli.SetDeployWindows(ctx, name, cmd.Args())
+	}
+
+	app, err := cli.GetApplicationInfo(ctx, name)
+	if err != nil {
+		return err
+	}
+	if len(app.DeployWindows) != 0 {
+		fmt.Fprintln(cli.stdout, strings.Join(app.DeployWindows, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package cmds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/api/client"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/mflag"
+	"github.com/cloudway/platform/pkg/opts"
+)
+
+func (cli *CWCli) CmdEvents(args ...string) error {
+	var (
+		filters  []string
+		since    string
+		noFollow bool
+		jsonFmt  bool
+	)
+
+	cmd := cli.Subcmd("events", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.Var(opts.NewListOptsRef(&filters, nil), []string{"f", "-filter"}, "Filter events in the form of KEY=VALUE, the key is type, action, application or service")
+	cmd.StringVar(&since, []string{"-since"}, "", "Show recent events after the event ID")
+	cmd.BoolVar(&noFollow, []string{"-no-follow"}, false, "Exit after receiving events instead of following")
+	cmd.BoolVar(&jsonFmt, []string{"-json"}, false, "Print events as JSON lines")
+	cmd.ParseFlags(args, true)
+
+	options := client.EventsOptions{Follow: !noFollow}
+	if len(filters) != 0 {
+		options.Filters = make(map[string][]string)
+		for _, f := range filters {
+			kv := strings.SplitN(f, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("Invalid filter, must be in the form of KEY=VALUE: %s", f)
+			}
+			options.Filters[kv[0]] = append(options.Filters[kv[0]], kv[1])
+		}
+	}
+	if since != "" {
+		var id uint64
+		if _, err := fmt.Sscan(since, &id); err != nil {
+			return fmt.Errorf("Invalid event ID: %s", since)
+		}
+		options.Since = &id
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(cli.stdout)
+	return cli.Events(context.Background(), options, func(e *types.Event) error {
+		if jsonFmt {
+			return enc.Encode(e)
+		}
+		printEvent(cli.stdout, e)
+		return nil
+	})
+}
+
+func printEvent(w io.Writer, e *types.Event) {
+	name := e.Application + "-" + e.Namespace
+	if e.Service != "" {
+		name = e.Service + "." + name
+	}
+	line := fmt.Sprintf("%s [%d] %s %s %s", e.Time.Local().Format(time.RFC3339), e.ID, e.Type, e.Action, name)
+	if id := e.Container; id != "" {
+		if len(id) > 12 {
+			id = id[:12]
+		}
+		line += " " + id
+	}
+	keys := make([]string, 0, len(e.Attributes))
+	for k := range e.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		line += fmt.Sprintf(" %s=%s", k, e.Attributes[k])
+	}
+	fmt.Fprintln(w, line)
+}
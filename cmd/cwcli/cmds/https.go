@@ -0,0 +1,116 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/mflag"
+	"github.com/cloudway/platform/pkg/opts"
+)
+
+func (cli *CWCli) CmdAppHTTPS(args ...string) error {
+	var (
+		force, subdomains, clear bool
+		websocket, grpc          bool
+		maxAge, timeout          int
+		errorPages               []string
+	)
+
+	cmd := cli.Subcmd("app:https", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.BoolVar(&force, []string{"-force"}, false, "Redirect HTTP requests to HTTPS")
+	cmd.IntVar(&maxAge, []string{"-hsts"}, 0, "Set HSTS header with the max age in seconds, 0 to disable")
+	cmd.BoolVar(&subdomains, []string{"-hsts-subdomains"}, false, "Include subdomains in HSTS")
+	cmd.Var(opts.NewListOptsRef(&errorPages, nil), []string{"-error-page"}, "Custom error page in the form of CODE=URL")
+	cmd.BoolVar(&websocket, []string{"-websocket"}, false, "Allow WebSocket connections")
+	cmd.BoolVar(&grpc, []string{"-grpc"}, false, "Pass gRPC requests to the application over HTTP/2")
+	cmd.IntVar(&timeout, []string{"-idle-timeout"}, 0, "Set idle timeout of connections in seconds, 0 for default")
+	cmd.BoolVar(&clear, []string{"-clear"}, false, "Reset to default settings")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if clear {
+		return cli.SetHTTPSettings(ctx, name, &types.HTTPSettings{})
+	}
+
+	settings, err := cli.GetHTTPSettings(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	if cmd.IsSet("-force") {
+		settings.ForceHTTPS, changed = force, true
+	}
+	if cmd.IsSet("-hsts") {
+		settings.HSTSMaxAge, changed = maxAge, true
+	}
+	if cmd.IsSet("-hsts-subdomains") {
+		settings.HSTSIncludeSubdomains, changed = subdomains, true
+	}
+	if cmd.IsSet("-websocket") {
+		settings.WebSocket, changed = websocket, true
+	}
+	if cmd.IsSet("-grpc") {
+		settings.GRPC, changed = grpc, true
+	}
+	if cmd.IsSet("-idle-timeout") {
+		settings.IdleTimeout, changed = timeout, true
+	}
+	if cmd.IsSet("-error-page") {
+		settings.ErrorPages, changed = make(map[string]string), true
+		for _, p := range errorPages {
+			kv := strings.SplitN(p, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("Invalid error page, must be in the form of CODE=URL: %s", p)
+			}
+			settings.ErrorPages[kv[0]] = kv[1]
+		}
+	}
+
+	if changed {
+		return cli.SetHTTPSettings(ctx, name, settings)
+	}
+	printHTTPSettings(cli.stdout, settings)
+	return nil
+}
+
+func printHTTPSettings(w io.Writer, s *types.HTTPSettings) {
+	if s.ForceHTTPS {
+		fmt.Fprintln(w, "HTTPS:      forced")
+	}
+	if s.HSTSMaxAge > 0 {
+		fmt.Fprintf(w, "HSTS:       max-age=%d", s.HSTSMaxAge)
+		if s.HSTSIncludeSubdomains {
+			fmt.Fprint(w, "; includeSubDomains")
+		}
+		fmt.Fprintln(w)
+	}
+	if s.WebSocket {
+		fmt.Fprintln(w, "WebSocket:  enabled")
+	}
+	if s.GRPC {
+		fmt.Fprintln(w, "gRPC:       enabled")
+	}
+	if s.IdleTimeout > 0 {
+		fmt.Fprintf(w, "Timeout:    %ds\n", s.IdleTimeout)
+	}
+	codes := make([]string, 0, len(s.ErrorPages))
+	for code := range s.ErrorPages {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "Error page: %s %s\n", code, s.ErrorPages[code])
+	}
+}
@@ -0,0 +1,89 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func (cli *CWCli) CmdAppLabel(args ...string) error {
+	var del bool
+
+	cmd := cli.Subcmd("app:label", "", "KEY=VALUE...", "-d KEY...")
+	cmd.String([]string{"a", "-app"}, "", "Application name")
+	cmd.BoolVar(&del, []string{"d"}, false, "Remove the label")
+	output := outputFlag(cmd)
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	app, err := cli.GetApplicationInfo(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if cmd.NArg() == 0 {
+		if del {
+			cmd.Usage()
+			return nil
+		}
+		// cwcli app:label
+		return cli.writeOutput(output, app.Labels, func() {
+			for _, kv := range sortedLabels(app.Labels) {
+				fmt.Fprintln(cli.stdout, kv)
+			}
+		})
+	}
+
+	labels := app.Labels
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	if del {
+		// cwcli app:label -d key1 key2 ...
+		for _, key := range cmd.Args() {
+			delete(labels, key)
+		}
+	} else {
+		// cwcli app:label key1=val1 key2=val2 ...
+		add, err := parseLabels(cmd.Args())
+		if err != nil {
+			return err
+		}
+		for k, v := range add {
+			labels[k] = v
+		}
+	}
+	return cli.SetLabels(ctx, name, labels)
+}
+
+// parseLabels parses labels in the form of KEY=VALUE.
+func parseLabels(args []string) (map[string]string, error) {
+	labels := make(map[string]string, len(args))
+	for _, kv := range args {
+		sep := strings.IndexRune(kv, '=')
+		if sep <= 0 {
+			return nil, fmt.Errorf("Invalid label %q, must be in the form of KEY=VALUE", kv)
+		}
+		labels[kv[:sep]] = kv[sep+1:]
+	}
+	return labels, nil
+}
+
+// sortedLabels returns labels in the form of KEY=VALUE sorted by keys.
+func sortedLabels(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = k + "=" + labels[k]
+	}
+	return keys
+}
@@ -0,0 +1,82 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+const appLinkUsage = `Usage: cwcli app:link [COMMAND]
+
+List services of other applications linked into the application. Linking a
+service, such as a shared database, sets the connection environment variables
+of the service in the application. A linked service cannot be removed until
+all links to it are removed.
+
+Additional commands, type "cwcli help app:link COMMAND" for more details:
+
+  add                Link a service of another application
+  remove             Remove the link to a service of another application
+`
+
+func (cli *CWCli) CmdAppLink(args ...string) error {
+	var help bool
+
+	cmd := cli.Subcmd("app:link", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.BoolVar(&help, []string{"-help"}, false, "Print usage")
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	output := outputFlag(cmd)
+	cmd.ParseFlags(args, false)
+
+	if help {
+		fmt.Fprintln(cli.stdout, appLinkUsage)
+		os.Exit(0)
+	}
+
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	links, err := cli.GetLinks(context.Background(), name)
+	if err != nil {
+		return err
+	}
+	return cli.writeOutput(output, links, func() {
+		for _, l := range links {
+			fmt.Fprintf(cli.stdout, "%-12.12s%s\n", l.Service, l.App)
+		}
+	})
+}
+
+func (cli *CWCli) CmdAppLinkAdd(args ...string) error {
+	cmd := cli.Subcmd("app:link add", "APP SERVICE")
+	cmd.Require(mflag.Exact, 2)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.LinkService(context.Background(), name, cmd.Arg(0), cmd.Arg(1))
+}
+
+func (cli *CWCli) CmdAppLinkRemove(args ...string) error {
+	cmd := cli.Subcmd("app:link remove", "APP SERVICE")
+	cmd.Require(mflag.Exact, 2)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.UnlinkService(context.Background(), name, cmd.Arg(0), cmd.Arg(1))
+}
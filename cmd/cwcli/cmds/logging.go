@@ -0,0 +1,86 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/mflag"
+	"github.com/cloudway/platform/pkg/opts"
+)
+
+func (cli *CWCli) CmdAppLogging(args ...string) error {
+	var (
+		driver, address string
+		options         []string
+		clear           bool
+	)
+
+	cmd := cli.Subcmd("app:logging", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.StringVar(&driver, []string{"-driver"}, "", "Log driver, one of syslog, fluentd or loki")
+	cmd.StringVar(&address, []string{"-address"}, "", "Address of the log collector")
+	cmd.Var(opts.NewListOptsRef(&options, nil), []string{"-opt"}, "Log driver option in the form of KEY=VALUE")
+	cmd.BoolVar(&clear, []string{"-clear"}, false, "Reset to the default log driver")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if clear {
+		return cli.SetLogging(ctx, name, &types.LogConfig{})
+	}
+
+	logging, err := cli.GetLogging(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	if cmd.IsSet("-driver") {
+		logging.Driver, changed = driver, true
+	}
+	if cmd.IsSet("-address") {
+		logging.Address, changed = address, true
+	}
+	if cmd.IsSet("-opt") {
+		logging.Options, changed = make(map[string]string), true
+		for _, o := range options {
+			kv := strings.SplitN(o, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("Invalid log option, must be in the form of KEY=VALUE: %s", o)
+			}
+			logging.Options[kv[0]] = kv[1]
+		}
+	}
+
+	if changed {
+		fmt.Fprintln(cli.stdout, "Containers are recreated to apply the log driver...")
+		return cli.SetLogging(ctx, name, logging)
+	}
+	if logging.Driver == "" {
+		fmt.Fprintln(cli.stdout, "Logging:    default")
+		return nil
+	}
+	printLogging(cli.stdout, logging)
+	return nil
+}
+
+func printLogging(w io.Writer, l *types.LogConfig) {
+	fmt.Fprintf(w, "Logging:    %s %s\n", l.Driver, l.Address)
+	keys := make([]string, 0, len(l.Options))
+	for k := range l.Options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "Log option: %s=%s\n", k, l.Options[k])
+	}
+}
@@ -4,17 +4,17 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/pkg/mflag"
 )
 
 func (cli *CWCli) CmdNamespace(args ...string) error {
-	var set string
+	var set, rename string
 	var remove, force bool
 
-	cmd := cli.Subcmd("namespace", "", "--set NAMESPACE", "--remove [-force]")
+	cmd := cli.Subcmd("namespace", "", "--set NAMESPACE", "--rename NAMESPACE", "--remove [-force]")
 	cmd.Require(mflag.Exact, 0)
 	cmd.StringVar(&set, []string{"-set"}, "", "Set to new namespace")
+	cmd.StringVar(&rename, []string{"-rename"}, "", "Rename to new namespace, keeping applications")
 	cmd.BoolVar(&remove, []string{"-remove"}, false, "Remove the namespace")
 	cmd.BoolVar(&force, []string{"-force"}, false, "Force to remove the namespace")
 	cmd.ParseFlags(args, false)
@@ -25,7 +25,7 @@ func (cli *CWCli) CmdNamespace(args ...string) error {
 
 	var ctx = context.Background()
 
-	if set == "" && !remove {
+	if set == "" && rename == "" && !remove {
 		namespace, err := cli.GetNamespace(ctx)
 		if err == nil {
 			fmt.Println(namespace)
@@ -47,11 +47,15 @@ func (cli *CWCli) CmdNamespace(args ...string) error {
 		}
 	}
 
-	// logout after namespace changed
-	if cli.host != "" {
-		config.RemoveOption(cli.host, "token")
-		config.Save()
+	if rename != "" {
+		err := cli.RenameNamespace(ctx, rename)
+		if err != nil {
+			return err
+		}
 	}
 
+	// logout after namespace changed
+	cli.logout()
+
 	return nil
 }
@@ -0,0 +1,64 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/cmd/cwcli/cmds/ansi"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+func (cli *CWCli) CmdNotices(args ...string) error {
+	cmd := cli.Subcmd("notices", "")
+	cmd.Require(mflag.Exact, 0)
+	output := outputFlag(cmd)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.Connect(); err != nil {
+		return err
+	}
+
+	// notices are listed below, don't repeat them from response headers
+	cli.SetNoticeHandler(nil)
+
+	notices, err := cli.Notices(context.Background())
+	if err != nil {
+		return err
+	}
+	if *output != outputTable {
+		return cli.writeOutput(output, notices, nil)
+	}
+	if len(notices) == 0 {
+		fmt.Fprintln(cli.stdout, "No notices")
+		return nil
+	}
+
+	kind := ansi.NewColor(ansi.FgYellow)
+	for _, n := range notices {
+		fmt.Fprintf(cli.stdout, "%s %s\n", kind.Wrap("["+strings.ToUpper(n.Kind)+"]"), n.Message)
+		if n.URL != "" {
+			fmt.Fprintf(cli.stdout, "    %s\n", n.URL)
+		}
+		if n.Expires != nil {
+			fmt.Fprintf(cli.stdout, "    until %s\n", n.Expires.Local().Format(time.RFC1123))
+		}
+	}
+	return nil
+}
+
+// showNotices prints notices received from server to stderr. Each notice
+// is only shown once even if received in several responses.
+func (cli *CWCli) showNotices(notices []string) {
+	if cli.shownNotices == nil {
+		cli.shownNotices = make(map[string]bool)
+	}
+	color := ansi.NewColor(ansi.FgYellow)
+	for _, n := range notices {
+		if !cli.shownNotices[n] {
+			cli.shownNotices[n] = true
+			fmt.Fprintln(cli.stderr, color.Wrap("Notice: "+n))
+		}
+	}
+}
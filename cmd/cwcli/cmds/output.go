@@ -0,0 +1,105 @@
+package cmds
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+// Output formats of listing commands. The table format is for humans, the
+// JSON and YAML formats are for scripts and keep field names of the API.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// outputFormat is the value of the --output option.
+type outputFormat string
+
+func (f *outputFormat) String() string {
+	return string(*f)
+}
+
+func (f *outputFormat) Set(value string) error {
+	switch value {
+	case outputTable, outputJSON, outputYAML:
+		*f = outputFormat(value)
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q, must be one of table, json or yaml", value)
+	}
+}
+
+// outputFlag adds the --output option to the command, the returned format
+// is valid after flags are parsed.
+func outputFlag(cmd *mflag.FlagSet) *outputFormat {
+	format := outputFormat(outputTable)
+	cmd.Var(&format, []string{"-output"}, "Output format: table, json or yaml")
+	return &format
+}
+
+// writeOutput writes the object in the output format, or calls the table
+// function to display the object as a table.
+func (cli *CWCli) writeOutput(format *outputFormat, obj interface{}, table func()) error {
+	switch *format {
+	case outputJSON:
+		cli.writeJson(obj)
+		return nil
+	case outputYAML:
+		return cli.writeYaml(obj)
+	default:
+		table()
+		return nil
+	}
+}
+
+// writeYaml writes the object as YAML. The object is converted through
+// JSON so that field names are the same as in the JSON output.
+func (cli *CWCli) writeYaml(obj interface{}) error {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err = dec.Decode(&v); err != nil {
+		return err
+	}
+
+	b, err = yaml.Marshal(yamlValue(v))
+	if err != nil {
+		return err
+	}
+	_, err = cli.stdout.Write(b)
+	return err
+}
+
+// yamlValue converts JSON numbers in the decoded value to integers or
+// floats, so that large integers such as sizes are not written in the
+// exponent form.
+func yamlValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = yamlValue(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = yamlValue(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,22 @@
+package cmds
+
+import "os"
+
+func Example_writeYaml() {
+	cli := &CWCli{stdout: os.Stdout}
+	cli.writeYaml(struct {
+		Name   string
+		Size   int64
+		Labels map[string]string
+	}{
+		Name:   "demo",
+		Size:   1 << 30,
+		Labels: map[string]string{"env": "prod"},
+	})
+
+	// Output:
+	// Labels:
+	//   env: prod
+	// Name: demo
+	// Size: 1073741824
+}
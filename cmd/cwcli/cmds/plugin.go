@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"os"
 
+	"github.com/cloudway/platform/hub"
 	"github.com/cloudway/platform/pkg/archive"
 	"github.com/cloudway/platform/pkg/manifest"
 	"github.com/cloudway/platform/pkg/mflag"
@@ -30,6 +31,7 @@ func (cli *CWCli) CmdPlugin(args ...string) (err error) {
 	cmd.BoolVar(&framework, []string{"F", "-framework"}, false, "Show framework plugins")
 	cmd.BoolVar(&service, []string{"s", "-service"}, false, "Show service plugins")
 	cmd.BoolVar(&userDefined, []string{"u", "-user"}, false, "Show user defined plugins")
+	output := outputFlag(cmd)
 	cmd.ParseFlags(args, false)
 
 	if help {
@@ -57,14 +59,19 @@ func (cli *CWCli) CmdPlugin(args ...string) (err error) {
 		if err != nil {
 			return err
 		}
-		for _, p := range plugins {
-			fmt.Fprintf(cli.stdout, "%-15s %s\n", p.Name, p.DisplayName)
-		}
+		return cli.writeOutput(output, plugins, func() {
+			for _, p := range plugins {
+				fmt.Fprintf(cli.stdout, "%-15s %s\n", p.Name, p.DisplayName)
+			}
+		})
 	} else {
 		plugin, err := cli.GetPluginInfo(context.Background(), cmd.Arg(0))
 		if err != nil {
 			return err
 		}
+		if *output != outputTable {
+			return cli.writeOutput(output, plugin, nil)
+		}
 
 		fmt.Fprintf(cli.stdout, "Name:           %s\n", plugin.Name)
 		fmt.Fprintf(cli.stdout, "Display Name:   %s\n", plugin.DisplayName)
@@ -89,6 +96,7 @@ func (cli *CWCli) CmdPluginInstall(args ...string) (err error) {
 	}
 
 	var file *os.File
+	var signature string
 
 	if st, err := os.Stat(path); err != nil {
 		return err
@@ -104,6 +112,10 @@ func (cli *CWCli) CmdPluginInstall(args ...string) (err error) {
 			return err
 		}
 	} else {
+		// the detached signature is verified by the server
+		if signature, err = hub.ReadSignature(path); err != nil {
+			return err
+		}
 		file, err = os.Open(path)
 		if err != nil {
 			return err
@@ -111,7 +123,7 @@ func (cli *CWCli) CmdPluginInstall(args ...string) (err error) {
 		defer file.Close()
 	}
 
-	return cli.InstallPlugin(context.Background(), file)
+	return cli.InstallPlugin(context.Background(), file, signature)
 }
 
 func makeArchive(path string) (file *os.File, err error) {
@@ -0,0 +1,20 @@
+package cmds
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudway/platform/cmd/cwcli/cmds/ansi"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+const progressBarWidth = 30
+
+// showProgress renders a progress bar for the progress record received
+// from server. Each record is displayed on its own line since progress
+// records are interleaved with server logs.
+func (cli *CWCli) showProgress(p *serverlog.Progress) {
+	filled := p.Percent * progressBarWidth / 100
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+	fmt.Fprintf(cli.stdout, "%s [%s] %3d%% %s\n", ansi.Info(p.Phase), bar, p.Percent, p.Message)
+}
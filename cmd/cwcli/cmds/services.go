@@ -0,0 +1,175 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+const serviceUsage = `Usage: cwcli service [NAME]
+
+List standalone services in the namespace, or show information of a service.
+Standalone services, such as a database shared by applications, are not
+owned by any application and have their own lifecycle.
+
+Additional commands, type "cwcli help COMMAND" for more details:
+
+  service:create     Create a standalone service
+  service:remove     Remove a standalone service
+  service:start      Start a standalone service
+  service:stop       Stop a standalone service
+  service:restart    Restart a standalone service
+  service:bind       Link a standalone service into an application
+  service:unbind     Remove the link to a standalone service
+`
+
+func (cli *CWCli) CmdService(args ...string) error {
+	var help bool
+
+	cmd := cli.Subcmd("service", "[NAME]")
+	cmd.Require(mflag.Max, 1)
+	cmd.BoolVar(&help, []string{"-help"}, false, "Print usage")
+	output := outputFlag(cmd)
+	cmd.ParseFlags(args, false)
+
+	if help {
+		fmt.Fprintln(cli.stdout, serviceUsage)
+		os.Exit(0)
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	if cmd.NArg() == 0 {
+		names, err := cli.GetStandaloneServices(context.Background())
+		if err != nil {
+			return err
+		}
+		return cli.writeOutput(output, names, func() {
+			for _, name := range names {
+				fmt.Fprintln(cli.stdout, name)
+			}
+		})
+	}
+
+	info, err := cli.GetStandaloneService(context.Background(), cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *output != outputTable {
+		return cli.writeOutput(output, info, nil)
+	}
+	fmt.Fprintf(cli.stdout, "Name:         %s\n", info.Name)
+	fmt.Fprintf(cli.stdout, "Namespace:    %s\n", info.Namespace)
+	fmt.Fprintf(cli.stdout, "Created:      %s\n", info.CreatedAt.Local())
+	fmt.Fprintf(cli.stdout, "Plugin:       %s\n", info.Plugin.Tag)
+	fmt.Fprintf(cli.stdout, "State:        %s\n", info.State)
+	return nil
+}
+
+func (cli *CWCli) CmdServiceCreate(args ...string) error {
+	cmd := cli.Subcmd("service:create", "NAME PLUGIN")
+	cmd.Require(mflag.Exact, 2)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	opts := types.CreateStandaloneService{Name: cmd.Arg(0), Plugin: cmd.Arg(1)}
+	return cli.CreateStandaloneService(context.Background(), opts, cli.stdout, cli.stderr)
+}
+
+func (cli *CWCli) CmdServiceRemove(args ...string) error {
+	var yes bool
+
+	cmd := cli.Subcmd("service:remove", "NAME")
+	cmd.Require(mflag.Exact, 1)
+	cmd.BoolVar(&yes, []string{"y"}, false, "Confirm 'yes' to remove the service")
+	cmd.ParseFlags(args, true)
+
+	if !yes && !cli.confirm("You will lost all your service data") {
+		return nil
+	}
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.RemoveStandaloneService(context.Background(), cmd.Arg(0))
+}
+
+func (cli *CWCli) CmdServiceStart(args ...string) error {
+	cmd := cli.Subcmd("service:start", "NAME")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.StartStandaloneService(context.Background(), cmd.Arg(0), cli.stdout, cli.stderr)
+}
+
+func (cli *CWCli) CmdServiceStop(args ...string) error {
+	cmd := cli.Subcmd("service:stop", "NAME")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.StopStandaloneService(context.Background(), cmd.Arg(0))
+}
+
+func (cli *CWCli) CmdServiceRestart(args ...string) error {
+	cmd := cli.Subcmd("service:restart", "NAME")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.RestartStandaloneService(context.Background(), cmd.Arg(0), cli.stdout, cli.stderr)
+}
+
+func (cli *CWCli) CmdServiceBind(args ...string) error {
+	cmd := cli.Subcmd("service:bind", "NAME")
+	cmd.Require(mflag.Exact, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	app := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	info, err := cli.GetStandaloneService(ctx, cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+	return cli.LinkService(ctx, app, info.Name, info.ServiceName)
+}
+
+func (cli *CWCli) CmdServiceUnbind(args ...string) error {
+	cmd := cli.Subcmd("service:unbind", "NAME")
+	cmd.Require(mflag.Exact, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	app := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	info, err := cli.GetStandaloneService(ctx, cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+	return cli.UnlinkService(ctx, app, info.Name, info.ServiceName)
+}
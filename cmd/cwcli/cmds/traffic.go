@@ -0,0 +1,77 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+func (cli *CWCli) CmdAppTraffic(args ...string) error {
+	var opts types.TrafficOptions
+
+	cmd := cli.Subcmd("app:traffic", "[PERCENT]")
+	cmd.Require(mflag.Max, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.StringVar(&opts.Artifact, []string{"-artifact"}, "", "Start a canary release with the build artifact")
+	cmd.IntVar(&opts.Containers, []string{"-containers"}, 1, "Number of canary containers")
+	cmd.BoolVar(&opts.AutoPromote, []string{"-auto"}, false, "Promote or rollback automatically based on health")
+	cmd.BoolVar(&opts.Promote, []string{"-promote"}, false, "Promote the canary release to all containers")
+	cmd.BoolVar(&opts.Rollback, []string{"-rollback"}, false, "Rollback the canary release")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if cmd.NArg() == 0 && opts.Artifact == "" && !opts.Promote && !opts.Rollback {
+		app, err := cli.GetApplicationInfo(ctx, name)
+		if err != nil {
+			return err
+		}
+		if app.Canary == nil {
+			fmt.Fprintln(cli.stdout, "No canary release in progress")
+		} else {
+			printCanary(cli.stdout, app.Canary)
+		}
+		return nil
+	}
+
+	if cmd.NArg() != 0 {
+		weight, err := strconv.Atoi(cmd.Arg(0))
+		if err != nil || weight < 0 || weight > 100 {
+			return fmt.Errorf("Invalid traffic percentage: %s", cmd.Arg(0))
+		}
+		opts.Weight = weight
+	} else if opts.Artifact != "" {
+		opts.Weight = 10
+	}
+
+	canary, err := cli.SetTraffic(ctx, name, opts)
+	if err != nil {
+		return err
+	}
+	switch {
+	case canary != nil:
+		printCanary(cli.stdout, canary)
+	case opts.Promote:
+		fmt.Fprintln(cli.stdout, "Canary release promoted")
+	case opts.Rollback:
+		fmt.Fprintln(cli.stdout, "Canary release rolled back")
+	}
+	return nil
+}
+
+func printCanary(w io.Writer, c *types.Canary) {
+	fmt.Fprintf(w, "Canary:     %s, %d%% of traffic to %d container(s), started by %s since %v",
+		c.Artifact, c.Weight, len(c.Containers), c.By, c.Since)
+	if c.AutoPromote {
+		fmt.Fprint(w, ", auto promote")
+	}
+	fmt.Fprintln(w)
+}
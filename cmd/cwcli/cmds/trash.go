@@ -0,0 +1,64 @@
+package cmds
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+
+	"github.com/cloudway/platform/cmd/cwcli/cmds/ansi"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+func (cli *CWCli) CmdTrash(args ...string) error {
+	var restore, purge string
+	var yes bool
+
+	cmd := cli.Subcmd("trash", "", "--restore NAME", "--purge NAME [-y]")
+	cmd.Require(mflag.Exact, 0)
+	cmd.StringVar(&restore, []string{"-restore"}, "", "Restore a removed application")
+	cmd.StringVar(&purge, []string{"-purge"}, "", "Permanently remove an application from trash")
+	cmd.BoolVar(&yes, []string{"y"}, false, "Confirm 'yes' to purge the application")
+	output := outputFlag(cmd)
+	cmd.ParseFlags(args, true)
+
+	if purge != "" && !yes && !cli.confirm("You will lost all your application data") {
+		return nil
+	}
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	switch {
+	case restore != "":
+		_, err := cli.RestoreApplication(ctx, restore, cli.stdout, cli.stderr)
+		return err
+
+	case purge != "":
+		return cli.PurgeTrash(ctx, purge)
+
+	default:
+		trash, err := cli.GetTrash(ctx)
+		if err != nil {
+			return err
+		}
+		return cli.writeOutput(output, trash, func() {
+			tab := NewTable("NAME", "PLUGINS", "REMOVED", "EXPIRES IN", "DATA")
+			tab.SetColor(0, ansi.NewColor(ansi.FgCyan))
+			for _, t := range trash {
+				data := "no"
+				if t.HasData {
+					data = "yes"
+				}
+				tab.AddRow(t.Name, strings.Join(t.Plugins, ","),
+					units.HumanDuration(time.Since(t.DeletedAt))+" ago",
+					units.HumanDuration(t.ExpiresAt.Sub(time.Now())),
+					data)
+			}
+			tab.Display(cli.stdout, 3)
+		})
+	}
+}
@@ -3,11 +3,11 @@ package main
 import (
 	"fmt"
 	"net/http"
-	"net/url"
 	"os"
 	"strings"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/cloudway/platform/api/types"
 	"github.com/cloudway/platform/cmd/cwcli/cmds"
 	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/pkg/colorable"
@@ -33,7 +33,7 @@ func main() {
 
 		commands := cmds.CommandUsage
 		for _, cmd := range commands {
-			if !strings.ContainsRune(cmd.Name, ':') {
+			if !strings.ContainsAny(cmd.Name, ": ") {
 				help += fmt.Sprintf("  %-12.12s%s\n", cmd.Name, cmd.Description)
 			}
 		}
@@ -47,6 +47,7 @@ func main() {
 	flgHelp := flag.Bool([]string{"h", "-help"}, false, "Print usage")
 	flgDebug := flag.Bool([]string{"D", "-debug"}, false, "Debugging mode")
 	flgHost := flag.String([]string{"H", "-host"}, "", "Connect to remote host")
+	flgContext := flag.String([]string{"-context"}, "", "Connect to the server of the named context")
 
 	flag.Parse()
 
@@ -63,7 +64,7 @@ func main() {
 
 	var host string
 	if *flgHost != "" {
-		if host, err := parseHost(*flgHost); err != nil {
+		if host, err = cmds.ParseHost(*flgHost); err != nil {
 			fmt.Fprintln(stderr, err)
 			os.Exit(1)
 		} else {
@@ -72,26 +73,18 @@ func main() {
 		}
 	}
 
-	c := cmds.Init(host, stdout, stderr)
+	c := cmds.Init(host, *flgContext, stdout, stderr)
 	if err := c.Run(flag.Args()...); err != nil {
 		if se, ok := err.(rest.ServerError); ok && se.StatusCode() == http.StatusUnauthorized {
 			fmt.Fprintln(stderr, "Your access token has been expired, please login again.")
+		} else if ve, ok := err.(*types.ValidationError); ok {
+			fmt.Fprintln(stderr, ve.Message+":")
+			for _, f := range ve.Fields {
+				fmt.Fprintf(stderr, "  %s: %s\n", f.Field, f.Message)
+			}
 		} else {
 			fmt.Fprintln(stderr, err)
 		}
 		os.Exit(1)
 	}
 }
-
-func parseHost(host string) (string, error) {
-	if strings.Contains(host, "://") {
-		if u, err := url.Parse(host); err != nil {
-			return "", err
-		} else {
-			u.Path = ""
-			return u.String(), nil
-		}
-	} else {
-		return "http://" + host, nil
-	}
-}
@@ -38,6 +38,7 @@ func Init() *CWCtl {
 		"start":   cli.CmdStart,
 		"stop":    cli.CmdStop,
 		"restart": cli.CmdRestart,
+		"hook":    cli.CmdHook,
 		"daemon":  cli.CmdDaemon,
 		"build":   cli.CmdBuild,
 		"status":  cli.CmdStatus,
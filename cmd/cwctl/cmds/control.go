@@ -28,6 +28,13 @@ func (cli *CWCtl) CmdRestart(args ...string) error {
 	return sandbox.New().Restart()
 }
 
+func (cli *CWCtl) CmdHook(args ...string) error {
+	cmd := cli.Subcmd("hook", "NAME")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+	return sandbox.New().RunHook(cmd.Arg(0))
+}
+
 func (cli *CWCtl) CmdStatus(args ...string) error {
 	cmd := cli.Subcmd("status")
 	cmd.Require(mflag.Exact, 0)
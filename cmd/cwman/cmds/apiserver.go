@@ -1,35 +1,52 @@
 package cmds
 
 import (
+	"context"
 	"net"
 	"os"
 	"os/signal"
 	prof "runtime"
 	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 
 	"github.com/cloudway/platform/api/server"
+	"github.com/cloudway/platform/api/server/health"
 	"github.com/cloudway/platform/api/server/middleware"
+	"github.com/cloudway/platform/api/server/router/admin"
 	"github.com/cloudway/platform/api/server/router/applications"
 	"github.com/cloudway/platform/api/server/router/namespace"
 	"github.com/cloudway/platform/api/server/router/plugins"
+	"github.com/cloudway/platform/api/server/router/services"
 	"github.com/cloudway/platform/api/server/router/system"
+	"github.com/cloudway/platform/api/server/router/user"
 	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/config/defaults"
 	"github.com/cloudway/platform/console"
+	"github.com/cloudway/platform/proxy"
 )
 
 const _CONTEXT_ROOT = "/api"
 
 func (cli *CWMan) CmdAPIServer(args ...string) (err error) {
 	var addr string
+	var dev bool
 
 	cmd := cli.Subcmd("api-server")
 	cmd.StringVar(&addr, []string{"-bind"}, ":6616", "API server bind address")
+	cmd.BoolVar(&dev, []string{"-dev"}, false, "Run with in-memory SCM and user database for development")
 	cmd.ParseFlags(args, true)
 
+	if dev {
+		setupDevConfig()
+	}
+	if err = checkConfig(); err != nil {
+		return err
+	}
+
 	stopc := make(chan bool)
 	defer close(stopc)
 
@@ -37,6 +54,13 @@ func (cli *CWMan) CmdAPIServer(args ...string) (err error) {
 	if err != nil {
 		return err
 	}
+	if dev {
+		if err = createDevUser(br); err != nil {
+			return err
+		}
+	}
+	startProxyWatcher(br)
+	startBackgroundTasks(br)
 
 	api := server.New(_CONTEXT_ROOT)
 
@@ -48,6 +72,7 @@ func (cli *CWMan) CmdAPIServer(args ...string) (err error) {
 
 	initMiddlewares(api, br)
 	initRouters(api, br)
+	health.InitRoutes(api.Mux, br)
 
 	if defaults.ApiURL() == defaults.ConsoleURL() {
 		// backward compatibility
@@ -78,9 +103,45 @@ func (cli *CWMan) CmdAPIServer(args ...string) (err error) {
 	return nil
 }
 
+// startProxyWatcher updates the proxy routes on container lifecycle events
+// published by the broker and the docker daemon.
+func startProxyWatcher(br *broker.Broker) {
+	go func() {
+		px, err := proxy.New(config.Get("proxy.url"))
+		for i := 0; i < 3 && err != nil && err != proxy.ErrMisconfigured; i++ {
+			// the proxy may be starting along with the API server
+			time.Sleep(time.Second * 5)
+			px, err = proxy.New(config.Get("proxy.url"))
+		}
+		if err == proxy.ErrMisconfigured {
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Could not connect to proxy, routes will not be updated")
+			return
+		}
+		proxy.Watch(context.Background(), br.Engine, br.Events.Subscribe(), px)
+	}()
+}
+
+// startBackgroundTasks starts the periodic maintenance of the platform.
+func startBackgroundTasks(br *broker.Broker) {
+	br.StartHealthCheck(context.Background())
+	br.StartCrashLoopDetection(context.Background())
+	br.StartTrashCleaner(context.Background())
+	br.StartArtifactCleaner(context.Background())
+	br.StartUploadCleaner(context.Background())
+	br.StartCanaryMonitor(context.Background())
+	br.StartAccountCleaner(context.Background())
+}
+
 func initMiddlewares(s *server.Server, br *broker.Broker) {
+	s.UseMiddleware(middleware.NewReadOnlyMiddleware(_CONTEXT_ROOT))
 	s.UseMiddleware(middleware.NewVersionMiddleware(br))
 	s.UseMiddleware(middleware.NewAuthMiddleware(br, _CONTEXT_ROOT))
+	s.UseMiddleware(middleware.NewNoticeMiddleware(br))
+	s.UseMiddleware(middleware.NewBodyLimitMiddleware())
+	s.UseMiddleware(middleware.NewSecurityMiddleware()) // evaluated first
 }
 
 func initRouters(s *server.Server, br *broker.Broker) {
@@ -88,13 +149,16 @@ func initRouters(s *server.Server, br *broker.Broker) {
 		system.NewRouter(br),
 		plugins.NewRouter(br),
 		namespace.NewRouter(br),
+		user.NewRouter(br),
 		applications.NewRouter(br),
+		services.NewRouter(br),
+		admin.NewRouter(br),
 	)
 }
 
 func trapSignals(cleanup func()) {
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP)
 	go func() {
 		var interruptCount uint32
 		for sig := range c {
@@ -118,6 +182,12 @@ func trapSignals(cleanup func()) {
 					}
 				case syscall.SIGQUIT:
 					dumpStacks()
+				case syscall.SIGHUP:
+					if err := config.Reload(); err != nil {
+						logrus.WithError(err).Error("Failed to reload configuration")
+					} else {
+						logrus.Info("Configuration reloaded")
+					}
 				}
 			}(sig)
 		}
@@ -0,0 +1,173 @@
+package cmds
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/pkg/mflag"
+	"github.com/cloudway/platform/pkg/s3"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+func (cli *CWMan) CmdBackup(args ...string) error {
+	var output string
+	var opts broker.BackupOptions
+
+	cmd := cli.Subcmd("backup")
+	cmd.Require(mflag.Exact, 0)
+	cmd.StringVar(&output, []string{"o", "-output"}, "", "Write the backup to a file or s3://bucket/key")
+	cmd.BoolVar(&opts.MasterKey, []string{"-with-key"}, false, "Include the master key in the backup")
+	cmd.BoolVar(&opts.SkipApps, []string{"-skip-apps"}, false, "Don't include application repositories and data")
+	cmd.ParseFlags(args, true)
+
+	if output == "" {
+		output = "cloudway-backup-" + time.Now().Format("20060102-150405") + ".tar.gz"
+	}
+
+	br, err := broker.New(cli.Engine)
+	if err != nil {
+		return err
+	}
+
+	var f *os.File
+	if s3.IsURL(output) {
+		f, err = ioutil.TempFile("", "backup")
+		if err == nil {
+			defer os.Remove(f.Name())
+		}
+	} else if output == "-" {
+		f = os.Stdout
+	} else {
+		f, err = os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// progress goes to stderr when the backup is written to stdout
+	log := serverlog.Encap(os.Stdout, os.Stderr)
+	if f == os.Stdout {
+		log = serverlog.Encap(os.Stderr, os.Stderr)
+	}
+	if err = br.Backup(context.Background(), f, opts, log); err != nil {
+		return err
+	}
+
+	if s3.IsURL(output) {
+		return uploadBackup(f, output)
+	}
+	if f != os.Stdout {
+		fmt.Fprintf(log, "Backup saved to %s\n", output)
+	}
+	return nil
+}
+
+func (cli *CWMan) CmdRestore(args ...string) error {
+	var yes bool
+	var opts broker.RestoreOptions
+
+	cmd := cli.Subcmd("restore", "FILE")
+	cmd.Require(mflag.Exact, 1)
+	cmd.BoolVar(&yes, []string{"y"}, false, "Confirm 'yes' to replace the current platform data")
+	cmd.BoolVar(&opts.SkipConfig, []string{"-skip-config"}, false, "Keep the current configuration")
+	cmd.BoolVar(&opts.SkipApps, []string{"-skip-apps"}, false, "Don't recreate applications")
+	cmd.ParseFlags(args, true)
+
+	if !yes && !confirmRestore() {
+		return nil
+	}
+
+	var in io.Reader
+	if name := cmd.Arg(0); name == "-" {
+		in = os.Stdin
+	} else if s3.IsURL(name) {
+		bucket, key, err := s3.ParseURL(name)
+		if err != nil {
+			return err
+		}
+		rc, err := s3Client().Get(bucket, key)
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		in = rc
+	} else {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	br, err := broker.New(cli.Engine)
+	if err != nil {
+		return err
+	}
+
+	log := serverlog.Encap(os.Stdout, os.Stderr)
+	if err = br.RestoreBackup(context.Background(), in, opts, log); err != nil {
+		return err
+	}
+	if !opts.SkipConfig {
+		fmt.Println("Restart platform servers to apply the restored configuration.")
+	}
+	return nil
+}
+
+func uploadBackup(f *os.File, url string) error {
+	bucket, key, err := s3.ParseURL(url)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err = f.Seek(0, os.SEEK_SET); err != nil {
+		return err
+	}
+	if err = s3Client().Put(bucket, key, f, fi.Size()); err != nil {
+		return err
+	}
+	fmt.Printf("Backup uploaded to %s\n", url)
+	return nil
+}
+
+// s3Client returns a S3 client configured by the "backup" section.
+func s3Client() *s3.Client {
+	return &s3.Client{
+		Endpoint:  config.Get("backup.s3_endpoint"),
+		Region:    config.Get("backup.s3_region"),
+		AccessKey: config.Get("backup.s3_access_key"),
+		SecretKey: config.Get("backup.s3_secret_key"),
+	}
+}
+
+func confirmRestore() bool {
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Println("The user database will be replaced with the backup.")
+	for {
+		fmt.Print("Continue restoring (yes/no)? ")
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return false
+		}
+		switch strings.TrimSpace(answer) {
+		case "yes":
+			return true
+		case "no", "":
+			return false
+		}
+		fmt.Println("Please answer yes or no.")
+	}
+}
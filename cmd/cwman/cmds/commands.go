@@ -22,11 +22,29 @@ type CWMan struct {
 var CommandUsage = []Command{
 	{"api-server", "Start the API server"},
 	{"console", "Start the console server"},
+	{"dev up", "Start a development platform in one process"},
+	{"dev down", "Remove the development platform"},
 	{"config", "Get or set a configuration value"},
 	{"install", "Install one or more plugins"},
+	{"plugin init", "Create the skeleton of a new plugin"},
+	{"plugin keygen", "Generate a key pair to sign plugins"},
+	{"plugin sign", "Sign plugin tarballs"},
 	{"upgrade", "Upgrade application containers"},
+	{"readonly", "Turn platform read-only mode on or off"},
+	{"notice", "List, create or remove platform notices"},
 	{"useradd", "Add a user"},
 	{"userdel", "Remove a user"},
+	{"userunlock", "Unlock a user locked due to failed logins"},
+	{"user import", "Create or update users from a CSV file"},
+	{"user export", "Export users to a CSV file"},
+	{"invite", "Create an invitation to register a user"},
+	{"backup", "Back up the platform data"},
+	{"migrate status", "Show schema migrations of data stores"},
+	{"migrate up", "Apply pending schema migrations"},
+	{"migrate down", "Revert schema migrations"},
+	{"restore", "Restore the platform data from a backup"},
+	{"dns check", "Report DNS records drifted from applications and namespaces"},
+	{"dns sync", "Create missing and remove stale DNS records"},
 }
 
 var Commands = make(map[string]Command)
@@ -44,17 +62,37 @@ func Init(engine container.Engine) *CWMan {
 	cli.Description = "Cloudway application container management tool"
 
 	cli.handlers = map[string]func(...string) error{
-		"api-server":   cli.CmdAPIServer,
-		"console":      cli.CmdConsole,
-		"update-proxy": cli.CmdUpdateProxy,
-		"sshd":         cli.CmdSshd,
-		"git-ssh":      cli.CmdGitSSH,
-		"config":       cli.CmdConfig,
-		"install":      cli.CmdInstallPlugin,
-		"deploy":       cli.CmdDeploy,
-		"upgrade":      cli.CmdUpgrade,
-		"useradd":      cli.CmdUserAdd,
-		"userdel":      cli.CmdUserDel,
+		"api-server":      cli.CmdAPIServer,
+		"console":         cli.CmdConsole,
+		"dev up":          cli.CmdDevUp,
+		"dev down":        cli.CmdDevDown,
+		"update-proxy":    cli.CmdUpdateProxy,
+		"sshd":            cli.CmdSshd,
+		"git-ssh":         cli.CmdGitSSH,
+		"config":          cli.CmdConfig,
+		"config validate": cli.CmdConfigValidate,
+		"config genkey":   cli.CmdConfigGenKey,
+		"install":         cli.CmdInstallPlugin,
+		"plugin init":     cli.CmdPluginInit,
+		"plugin keygen":   cli.CmdPluginKeygen,
+		"plugin sign":     cli.CmdPluginSign,
+		"deploy":          cli.CmdDeploy,
+		"upgrade":         cli.CmdUpgrade,
+		"readonly":        cli.CmdReadOnly,
+		"notice":          cli.CmdNotice,
+		"useradd":         cli.CmdUserAdd,
+		"userdel":         cli.CmdUserDel,
+		"userunlock":      cli.CmdUserUnlock,
+		"user import":     cli.CmdUserImport,
+		"user export":     cli.CmdUserExport,
+		"invite":          cli.CmdInvite,
+		"backup":          cli.CmdBackup,
+		"migrate status":  cli.CmdMigrateStatus,
+		"migrate up":      cli.CmdMigrateUp,
+		"migrate down":    cli.CmdMigrateDown,
+		"restore":         cli.CmdRestore,
+		"dns check":       cli.CmdDNSCheck,
+		"dns sync":        cli.CmdDNSSync,
 	}
 
 	return cli
@@ -1,15 +1,20 @@
 package cmds
 
 import (
+	"errors"
 	"fmt"
+
+	"github.com/Sirupsen/logrus"
+
 	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/pkg/mflag"
 )
 
 func (cli *CWMan) CmdConfig(args ...string) error {
-	var remove bool
-	cmd := cli.Subcmd("config", "KEY [VALUE]")
+	var remove, encrypt bool
+	cmd := cli.Subcmd("config", "KEY [VALUE]", "validate", "genkey")
 	cmd.BoolVar(&remove, []string{"d"}, false, "Remove the key")
+	cmd.BoolVar(&encrypt, []string{"e"}, false, "Encrypt the value with master key")
 	cmd.Require(mflag.Min, 1)
 	cmd.Require(mflag.Max, 2)
 	cmd.ParseFlags(args, true)
@@ -23,10 +28,69 @@ func (cli *CWMan) CmdConfig(args ...string) error {
 		config.Remove(key)
 		return config.Save()
 	} else if cmd.NArg() == 2 {
-		config.Set(key, cmd.Arg(1))
+		value := cmd.Arg(1)
+		if encrypt {
+			var err error
+			if value, err = config.Encrypt(value); err != nil {
+				return err
+			}
+		}
+		config.Set(key, value)
 		return config.Save()
 	} else {
 		fmt.Println(config.Get(key))
 		return nil
 	}
 }
+
+func (cli *CWMan) CmdConfigValidate(args ...string) error {
+	cmd := cli.Subcmd("config validate")
+	cmd.Require(mflag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	if err := config.Initialize(); err != nil {
+		return err
+	}
+
+	problems := config.Validate()
+	for _, p := range problems {
+		if p.Warning {
+			fmt.Printf("warning: %s\n", p)
+		} else {
+			fmt.Printf("error: %s\n", p)
+		}
+	}
+	if n := len(problems.Errors()); n != 0 {
+		return fmt.Errorf("%d configuration error(s) found", n)
+	}
+	return nil
+}
+
+// checkConfig validates the configuration before starting a server, so that
+// a misconfigured deployment fails early with all problems reported.
+func checkConfig() error {
+	problems := config.Validate()
+	for _, p := range problems {
+		if p.Warning {
+			logrus.Warnf("Configuration: %s", p)
+		} else {
+			logrus.Errorf("Configuration: %s", p)
+		}
+	}
+	if len(problems.Errors()) != 0 {
+		return errors.New("Invalid configuration, run 'cwman config validate' for details")
+	}
+	return nil
+}
+
+func (cli *CWMan) CmdConfigGenKey(args ...string) error {
+	cmd := cli.Subcmd("config genkey")
+	cmd.Require(mflag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	if err := config.GenerateMasterKey(); err != nil {
+		return err
+	}
+	fmt.Printf("Master key saved to %s, keep it safe and out of version control\n", config.MasterKeyFile())
+	return nil
+}
@@ -15,6 +15,10 @@ func (cli *CWMan) CmdConsole(args ...string) (err error) {
 	cmd.StringVar(&addr, []string{"-bind"}, ":3000", "Console bind address")
 	cmd.ParseFlags(args, true)
 
+	if err = checkConfig(); err != nil {
+		return err
+	}
+
 	stopc := make(chan struct{})
 	defer close(stopc)
 
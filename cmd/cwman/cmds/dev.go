@@ -0,0 +1,327 @@
+package cmds
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/api/server"
+	"github.com/cloudway/platform/api/server/health"
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/console"
+	"github.com/cloudway/platform/pkg/mflag"
+	"github.com/cloudway/platform/proxy"
+	"github.com/cloudway/platform/sshd"
+)
+
+// The user created in development mode, who is also an administrator.
+const (
+	devUserName      = "dev@localhost"
+	devUserNamespace = "dev"
+)
+
+// Files in the development state directory.
+const (
+	devPidFile        = "dev.pid"
+	devNamespacesFile = "namespaces"
+	devPluginsDir     = "plugins"
+)
+
+// devDir returns the directory holding the state of the development
+// platform, which is removed by 'cwman dev down'.
+func devDir() string {
+	return filepath.Join(os.TempDir(), "cloudway-dev")
+}
+
+// setupDevConfig configures the in-memory SCM and user database, so the
+// platform runs against the Docker daemon without any external service.
+// The configuration file is not changed.
+func setupDevConfig() {
+	config.Set("scm.type", "memory")
+	config.Set("scm.url", "memory://")
+	config.Set("userdb.type", "memory")
+	config.Set("userdb.url", "memory://")
+	config.Set("admin.users", devUserName)
+
+	logrus.Warn("Running in development mode, all users and repositories are lost on exit")
+}
+
+// createDevUser creates the development user with a random password.
+func createDevUser(br *broker.Broker) error {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	password := base64.RawURLEncoding.EncodeToString(b)
+
+	user := &userdb.BasicUser{Name: devUserName, Namespace: devUserNamespace}
+	if err := br.CreateUser(user, password); err != nil {
+		return err
+	}
+
+	logrus.Infof("Development user: %s, password: %s", devUserName, password)
+	return nil
+}
+
+func (cli *CWMan) CmdDevUp(args ...string) (err error) {
+	var apiAddr, consoleAddr, sshdAddr, proxyAddr, pluginDir string
+
+	cmd := cli.Subcmd("dev up")
+	cmd.StringVar(&apiAddr, []string{"-api"}, ":6616", "API server bind address")
+	cmd.StringVar(&consoleAddr, []string{"-console"}, ":3000", "Console bind address")
+	cmd.StringVar(&sshdAddr, []string{"-sshd"}, ":2200", "SSHD bind address")
+	cmd.StringVar(&proxyAddr, []string{"-proxy"}, ":8000", "Proxy bind address")
+	cmd.StringVar(&pluginDir, []string{"-plugins"}, "", "Directory of plugins to install")
+	cmd.Require(mflag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	dir := devDir()
+	if pid, err := readDevPid(dir); err == nil && syscall.Kill(pid, 0) == nil {
+		return fmt.Errorf("Development platform is already running (pid %d), run 'cwman dev down' first", pid)
+	}
+	if err = os.MkdirAll(filepath.Join(dir, devPluginsDir), 0755); err != nil {
+		return err
+	}
+	pidFile := filepath.Join(dir, devPidFile)
+	if err = ioutil.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(pidFile)
+
+	setupDevConfig()
+	config.Set("domain", "localhost")
+	config.Set("console.url", localURL(consoleAddr))
+	config.Set("api.url", localURL(apiAddr))
+	if _, port, err := net.SplitHostPort(sshdAddr); err == nil && port != "" {
+		config.Set("ssh.port", port)
+	}
+	config.Set("hub.dir", filepath.Join(dir, devPluginsDir))
+	if err = checkConfig(); err != nil {
+		return err
+	}
+
+	stopc := make(chan bool)
+	defer close(stopc)
+
+	br, err := broker.New(cli.Engine)
+	if err != nil {
+		return err
+	}
+	go recordDevNamespaces(filepath.Join(dir, devNamespacesFile), br.Events.Subscribe())
+	if err = createDevUser(br); err != nil {
+		return err
+	}
+	if err = installDevPlugins(br, pluginDir); err != nil {
+		return err
+	}
+	startBackgroundTasks(br)
+
+	// start the proxy
+	px := proxy.NewLocalProxy()
+	go proxy.Watch(context.Background(), nil, br.Events.Subscribe(), px)
+	pl, err := net.Listen("tcp", proxyAddr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := http.Serve(pl, px); err != nil {
+			logrus.WithError(err).Debug("Proxy terminated")
+		}
+	}()
+
+	// start the SSH server
+	go func() {
+		if err := sshd.ServeWith(cli.Engine, br.SCM(), br.Users, sshdAddr); err != nil {
+			logrus.WithError(err).Error("SSH server error")
+		}
+	}()
+
+	// start the console
+	con, err := console.NewConsole(br)
+	if err != nil {
+		return err
+	}
+	cl, err := net.Listen("tcp", consoleAddr)
+	if err != nil {
+		return err
+	}
+	con.Accept(consoleAddr, cl)
+	go func() {
+		if err := con.Serve(); err != nil {
+			logrus.WithError(err).Error("Console server error")
+		}
+	}()
+
+	// start the API server
+	api := server.New(_CONTEXT_ROOT)
+	al, err := net.Listen("tcp", apiAddr)
+	if err != nil {
+		return err
+	}
+	api.Accept(apiAddr, al)
+	initMiddlewares(api, br)
+	initRouters(api, br)
+	health.InitRoutes(api.Mux, br)
+
+	logrus.Infof("Console: %s", localURL(consoleAddr))
+	logrus.Infof("API server: %s%s", localURL(apiAddr), _CONTEXT_ROOT)
+	logrus.Infof("Applications: %s", localURL(proxyAddr))
+
+	waitChan := make(chan error)
+	go api.Wait(waitChan)
+	trapSignals(func() {
+		api.Close()
+		con.Close()
+		pl.Close()
+		<-stopc // wait for CmdDevUp() to return
+	})
+
+	if err = <-waitChan; err != nil {
+		logrus.WithError(err).Error("API server error")
+	}
+	logrus.Info("Development platform terminated")
+	return nil
+}
+
+func (cli *CWMan) CmdDevDown(args ...string) error {
+	cmd := cli.Subcmd("dev down")
+	cmd.Require(mflag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	dir := devDir()
+	if pid, err := readDevPid(dir); err == nil {
+		if syscall.Kill(pid, syscall.SIGTERM) == nil {
+			logrus.Infof("Stopped development platform (pid %d)", pid)
+		}
+	}
+
+	namespaces, err := readDevNamespaces(filepath.Join(dir, devNamespacesFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	ctx := context.Background()
+	for _, namespace := range namespaces {
+		cs, err := cli.FindInNamespace(ctx, namespace)
+		if err != nil {
+			return err
+		}
+		for _, c := range cs {
+			if err = c.Destroy(ctx); err != nil {
+				return err
+			}
+			logrus.Infof("Removed container %s-%s", c.Name(), c.Namespace())
+		}
+	}
+
+	return os.RemoveAll(dir)
+}
+
+func readDevPid(dir string) (int, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, devPidFile))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// recordDevNamespaces appends namespaces of created applications to the
+// file, so containers can be removed after the in-memory stores are lost.
+func recordDevNamespaces(filename string, events <-chan broker.Event) {
+	seen := make(map[string]bool)
+	for event := range events {
+		if event.Type != broker.ApplicationCreated && event.Type != broker.ServiceCreated {
+			continue
+		}
+		if event.Namespace == "" || seen[event.Namespace] {
+			continue
+		}
+		f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err == nil {
+			_, err = fmt.Fprintln(f, event.Namespace)
+			f.Close()
+		}
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to record namespace %s", event.Namespace)
+			continue
+		}
+		seen[event.Namespace] = true
+	}
+}
+
+func readDevNamespaces(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var namespaces []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// an empty namespace would match all containers in the system
+		if ns := strings.TrimSpace(scanner.Text()); ns != "" && !seen[ns] {
+			namespaces = append(namespaces, ns)
+			seen[ns] = true
+		}
+	}
+	return namespaces, scanner.Err()
+}
+
+// installDevPlugins installs all plugins in the directory, which defaults
+// to the plugins directory of the installation or the source tree.
+func installDevPlugins(br *broker.Broker, dir string) error {
+	if dir == "" {
+		for _, d := range []string{filepath.Join(config.RootDir, "plugins"), filepath.Join("build", "plugins")} {
+			if fi, err := os.Stat(d); err == nil && fi.IsDir() {
+				dir = d
+				break
+			}
+		}
+		if dir == "" {
+			logrus.Warn("No plugins directory found, use --plugins to install plugins")
+			return nil
+		}
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "manifest", "plugin.yml"))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return errors.New("No plugins found in " + dir)
+	}
+	for _, path := range paths {
+		path = filepath.Dir(filepath.Dir(path))
+		if err = br.Hub.InstallPlugin("", path); err != nil {
+			return err
+		}
+		logrus.Infof("Installed plugin %s", filepath.Base(path))
+	}
+	return nil
+}
+
+// localURL returns the URL on localhost of the bind address.
+func localURL(addr string) string {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil || port == "" {
+		return "http://localhost"
+	}
+	return "http://localhost:" + port
+}
@@ -0,0 +1,76 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/dns"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+// CmdDNSCheck reports records of application aliases and namespace domains
+// that drifted from the user database.
+func (cli *CWMan) CmdDNSCheck(args ...string) error {
+	cmd := cli.Subcmd("dns check")
+	cmd.Require(mflag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	_, drift, err := cli.dnsDrift()
+	if err != nil {
+		return err
+	}
+	if drift.Empty() {
+		fmt.Println("DNS records are up to date")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tNAME\tTYPE\tVALUE")
+	printRecords(w, "missing", drift.Missing)
+	printRecords(w, "stale", drift.Stale)
+	printRecords(w, "conflict", drift.Conflicts)
+	return w.Flush()
+}
+
+// CmdDNSSync creates missing records and removes stale records. Conflicting
+// records are reported but left unchanged.
+func (cli *CWMan) CmdDNSSync(args ...string) error {
+	cmd := cli.Subcmd("dns sync")
+	cmd.Require(mflag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	p, drift, err := cli.dnsDrift()
+	if err != nil {
+		return err
+	}
+	if err = dns.Apply(p, drift); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created %d records, removed %d records\n", len(drift.Missing), len(drift.Stale))
+	for _, rec := range drift.Conflicts {
+		fmt.Fprintf(os.Stderr, "Warning: %s %s %s is not pointing to the proxy\n", rec.Name, rec.Type, rec.Value)
+	}
+	return nil
+}
+
+func (cli *CWMan) dnsDrift() (dns.Provider, *dns.Drift, error) {
+	p, err := dns.FromConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	br, err := broker.New(cli.Engine)
+	if err != nil {
+		return nil, nil, err
+	}
+	drift, err := br.DNSDrift(p)
+	return p, drift, err
+}
+
+func printRecords(w *tabwriter.Writer, status string, records []*dns.Record) {
+	for _, rec := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", status, rec.Name, rec.Type, rec.Value)
+	}
+}
@@ -2,6 +2,7 @@ package cmds
 
 import (
 	"github.com/Sirupsen/logrus"
+	"github.com/cloudway/platform/auth/userdb"
 	"github.com/cloudway/platform/scm/mock"
 	"net"
 )
@@ -18,6 +19,11 @@ func (cli *CWMan) CmdGitSSH(args ...string) (err error) {
 	defer close(stopc)
 
 	sshServer := mock.NewSSHServer(repo)
+	if users, err := userdb.Open(); err != nil {
+		logrus.WithError(err).Warn("Failed to open user database, SSH keys will be read from repositories only")
+	} else {
+		sshServer.SetUserDatabase(users)
+	}
 
 	l, err := net.Listen("tcp", addr)
 	if err != nil {
@@ -1,25 +1,56 @@
 package cmds
 
 import (
+	"context"
+	"fmt"
+	"os"
+
 	"github.com/cloudway/platform/hub"
+	"github.com/cloudway/platform/pkg/archive"
 	"github.com/cloudway/platform/pkg/mflag"
 )
 
 func (cli *CWMan) CmdInstallPlugin(args ...string) error {
+	var noPull, allowUnsigned bool
+
 	cmd := cli.Subcmd("install", "PATH...")
 	cmd.Require(mflag.Min, 1)
+	cmd.BoolVar(&noPull, []string{"-no-pull"}, false, "Do not pull base images of plugins")
+	cmd.BoolVar(&allowUnsigned, []string{"-allow-unsigned"}, false, "Allow to install unsigned plugins")
 	cmd.ParseFlags(args, true)
 
-	hub, err := hub.New()
+	plugins, err := hub.New()
 	if err != nil {
 		return err
 	}
 
 	for _, path := range cmd.Args() {
-		if err = hub.InstallPlugin("", path); err != nil {
+		sig, err := hub.ReadSignature(path)
+		if err != nil {
+			return err
+		}
+		if err = hub.VerifyPlugin(path, sig, allowUnsigned); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if err = plugins.InstallPlugin("", path); err != nil {
 			return err
 		}
 	}
 
+	// pull base images so that the first application creation doesn't
+	// stall for pulling images
+	if !noPull && cli.Engine != nil {
+		for _, path := range cmd.Args() {
+			meta, err := archive.ReadManifest(path)
+			if err != nil {
+				continue
+			}
+			fmt.Printf("Pulling base image %s of %s:%s\n", meta.BaseImage, meta.Name, meta.Version)
+			if err = cli.PullImage(context.Background(), meta.BaseImage, os.Stdout); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
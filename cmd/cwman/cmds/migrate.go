@@ -0,0 +1,140 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/pkg/mflag"
+	"github.com/cloudway/platform/pkg/migrate"
+)
+
+func (cli *CWMan) CmdMigrateStatus(args ...string) error {
+	cmd := cli.Subcmd("migrate status", "[STORE...]")
+	cmd.ParseFlags(args, true)
+
+	migrators, err := cli.migrators(cmd.Args())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STORE\tVERSION\tSTATUS\tDESCRIPTION")
+	for _, m := range migrators {
+		status, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", m.Name, s.Version, state, s.Description)
+		}
+	}
+	return w.Flush()
+}
+
+func (cli *CWMan) CmdMigrateUp(args ...string) error {
+	var target int
+
+	cmd := cli.Subcmd("migrate up", "[STORE...]")
+	cmd.IntVar(&target, []string{"-to"}, -1, "Apply migrations up to the version, all pending migrations if not specified")
+	cmd.ParseFlags(args, true)
+
+	migrators, err := cli.migrators(cmd.Args())
+	if err != nil {
+		return err
+	}
+	if target >= 0 && len(migrators) != 1 {
+		return fmt.Errorf("a store must be specified with the target version")
+	}
+
+	for _, m := range migrators {
+		if err = m.Up(target, os.Stdout); err != nil {
+			return err
+		}
+		version, err := m.Version()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: at version %d\n", m.Name, version)
+	}
+	return nil
+}
+
+func (cli *CWMan) CmdMigrateDown(args ...string) error {
+	var target int
+
+	cmd := cli.Subcmd("migrate down", "STORE")
+	cmd.Require(mflag.Exact, 1)
+	cmd.IntVar(&target, []string{"-to"}, -1, "Revert migrations after the version, only the last migration if not specified")
+	cmd.ParseFlags(args, true)
+
+	migrators, err := cli.migrators(cmd.Args())
+	if err != nil {
+		return err
+	}
+	m := migrators[0]
+
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if target < 0 {
+		target = previousVersion(m, version)
+	}
+	if err = m.Down(target, os.Stdout); err != nil {
+		return err
+	}
+	if version, err = m.Version(); err != nil {
+		return err
+	}
+	fmt.Printf("%s: at version %d\n", m.Name, version)
+	return nil
+}
+
+// migrators returns migrators of the named stores, or all stores if no
+// name given.
+func (cli *CWMan) migrators(names []string) ([]*migrate.Migrator, error) {
+	br, err := broker.New(cli.Engine)
+	if err != nil {
+		return nil, err
+	}
+
+	all := br.Migrators()
+	if len(names) == 0 {
+		return all, nil
+	}
+
+	var result []*migrate.Migrator
+	for _, name := range names {
+		found := false
+		for _, m := range all {
+			if m.Name == name {
+				result = append(result, m)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown store: %s", name)
+		}
+	}
+	return result, nil
+}
+
+// previousVersion returns the version of the migration applied before the
+// given version.
+func previousVersion(m *migrate.Migrator, version int) int {
+	status, _ := m.Status()
+	prev := 0
+	for _, s := range status {
+		if s.Version < version {
+			prev = s.Version
+		}
+	}
+	return prev
+}
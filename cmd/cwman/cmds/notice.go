@@ -0,0 +1,81 @@
+package cmds
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/broker"
+)
+
+func (cli *CWMan) CmdNotice(args ...string) error {
+	var (
+		n             userdb.Notice
+		start, expire string
+		remove        string
+	)
+
+	cmd := cli.Subcmd("notice", "", "MESSAGE", "--remove ID")
+	cmd.StringVar(&n.Kind, []string{"-kind"}, userdb.NoticeInfo, "Kind of the notice: info, warning, maintenance or tos")
+	cmd.StringVar(&n.URL, []string{"-url"}, "", "Link to details of the notice")
+	cmd.StringVar(&start, []string{"-start"}, "", "Time to show the notice from, in RFC 3339 format")
+	cmd.StringVar(&expire, []string{"-expire"}, "", "Time to show the notice for, such as 24h")
+	cmd.StringVar(&remove, []string{"-remove"}, "", "Remove the notice with the given ID")
+	cmd.ParseFlags(args, true)
+
+	var err error
+	if start != "" {
+		if n.Starts, err = time.Parse(time.RFC3339, start); err != nil {
+			return fmt.Errorf("invalid start time: %s", start)
+		}
+	}
+	if expire != "" {
+		d, err := time.ParseDuration(expire)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid expiration time: %s", expire)
+		}
+		if n.Starts.IsZero() {
+			n.Expires = time.Now().Add(d)
+		} else {
+			n.Expires = n.Starts.Add(d)
+		}
+	}
+
+	br, err := broker.New(cli.Engine)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case remove != "":
+		return br.RemoveNotice(remove)
+
+	case cmd.NArg() != 0:
+		n.Message = strings.Join(cmd.Args(), " ")
+		if err = br.CreateNotice(&n); err != nil {
+			return err
+		}
+		fmt.Println(n.ID)
+		return nil
+
+	default:
+		notices, err := br.Users.ListNotices()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, n := range notices {
+			status := "active"
+			if !n.Active(now) {
+				if now.Before(n.Starts) {
+					status = "scheduled"
+				} else {
+					status = "expired"
+				}
+			}
+			fmt.Printf("%s  %-11s %-9s %s\n", n.ID, n.Kind, status, n.Message)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,97 @@
+package cmds
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/cloudway/platform/hub"
+	"github.com/cloudway/platform/pkg/mflag"
+	"github.com/cloudway/platform/pkg/scaffold"
+)
+
+func (cli *CWMan) CmdPluginInit(args ...string) error {
+	var (
+		kind string
+		port int
+		opts scaffold.Options
+	)
+
+	cmd := cli.Subcmd("plugin init", "DIR")
+	cmd.Require(mflag.Exact, 1)
+	cmd.StringVar(&kind, []string{"-kind"}, string(scaffold.HTTPFramework), "Kind of the plugin, 'http' for a web framework or 'tcp' for a service")
+	cmd.StringVar(&opts.Name, []string{"-name"}, "", "Plugin name, defaults to the directory name")
+	cmd.StringVar(&opts.DisplayName, []string{"-display-name"}, "", "Plugin display name")
+	cmd.StringVar(&opts.Description, []string{"-description"}, "", "Plugin description")
+	cmd.StringVar(&opts.Version, []string{"-version"}, "", "Plugin version")
+	cmd.StringVar(&opts.Vendor, []string{"-vendor"}, "", "Plugin vendor")
+	cmd.StringVar(&opts.BaseImage, []string{"-base-image"}, "", "Base image of application containers")
+	cmd.IntVar(&port, []string{"-port"}, 0, "Private port the plugin listens on")
+	cmd.ParseFlags(args, true)
+
+	dir := cmd.Arg(0)
+	if opts.Name == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		opts.Name = filepath.Base(abs)
+	}
+	opts.Port = int32(port)
+
+	if err := scaffold.Generate(dir, scaffold.Kind(kind), opts); err != nil {
+		return err
+	}
+	fmt.Printf("Plugin %s created in %s, install it with 'cwman install %s'\n", opts.Name, dir, dir)
+	return nil
+}
+
+func (cli *CWMan) CmdPluginKeygen(args ...string) error {
+	cmd := cli.Subcmd("plugin keygen", "FILE")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	file := cmd.Arg(0)
+	priv, pub, err := hub.GenerateSigningKey()
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(file, []byte(priv+"\n"), 0600); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(file+".pub", []byte(pub+"\n"), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Signing key saved to %s, add the public key in %s.pub to the trust store\n", file, file)
+	return nil
+}
+
+func (cli *CWMan) CmdPluginSign(args ...string) error {
+	var keyFile string
+
+	cmd := cli.Subcmd("plugin sign", "PATH...")
+	cmd.Require(mflag.Min, 1)
+	cmd.StringVar(&keyFile, []string{"k", "-key"}, "", "Private key file to sign plugins")
+	cmd.ParseFlags(args, true)
+
+	if keyFile == "" {
+		return errors.New("The signing key is required")
+	}
+	key, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range cmd.Args() {
+		sig, err := hub.SignPlugin(path, string(key))
+		if err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(path+hub.SignatureExt, []byte(sig+"\n"), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Signature saved to %s%s\n", path, hub.SignatureExt)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cloudway/platform/broker"
+)
+
+func (cli *CWMan) CmdReadOnly(args ...string) error {
+	cmd := cli.Subcmd("readonly", "[on [MESSAGE]|off]")
+	cmd.ParseFlags(args, true)
+
+	switch cmd.Arg(0) {
+	case "":
+		if err := broker.CheckReadOnly(); err != nil {
+			fmt.Println(err)
+		} else {
+			fmt.Println("The platform is not in read-only mode")
+		}
+		return nil
+	case "on":
+		return broker.SetReadOnly(strings.Join(cmd.Args()[1:], " "))
+	case "off":
+		return broker.ClearReadOnly()
+	default:
+		cmd.Usage()
+		os.Exit(1)
+		return nil
+	}
+}
@@ -1,12 +1,17 @@
 package cmds
 
-import "github.com/cloudway/platform/sshd"
+import (
+	"strconv"
+
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/sshd"
+)
 
 func (cli *CWMan) CmdSshd(args ...string) error {
 	var addr string
 
 	cmd := cli.Subcmd("sshd")
-	cmd.StringVar(&addr, []string{"-bind"}, "0.0.0.0:2200", "SSHD bind address")
+	cmd.StringVar(&addr, []string{"-bind"}, "0.0.0.0:"+strconv.Itoa(defaults.SSHPort()), "SSHD bind address")
 	cmd.ParseFlags(args, true)
 
 	return sshd.Serve(cli.Engine, addr)
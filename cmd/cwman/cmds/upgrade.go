@@ -1,90 +1,78 @@
 package cmds
 
 import (
-	"archive/tar"
+	"bufio"
 	"context"
-	"io/ioutil"
+	"fmt"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 
-	"github.com/cloudway/platform/config"
-	"github.com/cloudway/platform/container"
-	"github.com/cloudway/platform/pkg/archive"
+	"github.com/cloudway/platform/broker"
 	"github.com/cloudway/platform/pkg/mflag"
 	"github.com/cloudway/platform/pkg/serverlog"
 )
 
 func (cli *CWMan) CmdUpgrade(args ...string) error {
+	var (
+		opts    broker.UpgradeOptions
+		timeout string
+	)
+
 	cmd := cli.Subcmd("upgrade", "")
 	cmd.Require(mflag.Exact, 0)
+	cmd.IntVar(&opts.BatchSize, []string{"-batch"}, 1, "Number of applications upgraded in a batch")
+	cmd.IntVar(&opts.Canary, []string{"-canary"}, 0, "Number of applications upgraded first as canary")
+	cmd.StringVar(&timeout, []string{"-timeout"}, "2m", "Maximum time to wait for an upgraded container to be running")
 	cmd.ParseFlags(args, true)
 
-	// create an archive file that contains the support files
-	ar, err := makeSupportArchive()
-	if err != nil {
-		return err
-	}
-	defer os.Remove(ar)
-
-	ctx := context.Background()
-
-	containers, err := cli.FindInNamespace(ctx, "")
-	if err != nil {
-		return err
+	var err error
+	if opts.Timeout, err = time.ParseDuration(timeout); err != nil {
+		return fmt.Errorf("invalid timeout: %s", timeout)
 	}
 
-	// group containers by applications
-	apps := make(map[string][]container.Container)
-	for _, c := range containers {
-		key := c.Name() + "-" + c.Namespace()
-		apps[key] = append(apps[key], c)
-	}
-
-	// Stop applications, copy support files, and restart applications
-	for app, cs := range apps {
-		logrus.Infof("upgrading application %s", app)
-		logError(container.ResolveServiceDependencies(cs))
-		for _, c := range cs {
-			file, err := os.Open(ar)
-			if err != nil {
-				return err
-			}
-			logrus.Infof("upgrading container %s.%s-%s", c.ServiceName(), c.Name(), c.Namespace())
-			logError(c.Stop(ctx))
-			logError(c.CopyTo(ctx, "/", file))
-			logError(c.Start(ctx, serverlog.Encap(os.Stdout, os.Stderr)))
-			file.Close()
+	// ask for confirmation after canary applications upgraded
+	opts.AfterCanary = func(u *broker.Upgrader) {
+		if confirmContinue() {
+			u.Resume()
+		} else {
+			u.Abort()
 		}
 	}
 
-	return nil
-}
+	u := broker.NewUpgrader(cli.Engine, opts)
 
-func makeSupportArchive() (string, error) {
-	file, err := ioutil.TempFile("", "tmp")
-	if err != nil {
-		return "", err
-	}
-
-	tw := tar.NewWriter(file)
-	src := filepath.Join(config.RootDir, "sandbox")
-	err = archive.CopyFileTree(tw, "", src, nil, false)
+	// abort the upgrade after the current batch on interrupt
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt)
+	defer signal.Stop(sigc)
+	go func() {
+		for range sigc {
+			logrus.Info("Aborting upgrade after the current batch")
+			u.Abort()
+		}
+	}()
 
-	if err != nil {
-		file.Close()
-		os.Remove(file.Name())
-		return "", err
-	} else {
-		tw.Close()
-		file.Close()
-		return file.Name(), nil
-	}
+	return u.Run(context.Background(), serverlog.Encap(os.Stdout, os.Stderr))
 }
 
-func logError(err error) {
-	if err != nil {
-		logrus.Error(err)
+func confirmContinue() bool {
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Print("Continue upgrading remaining applications (yes/no)? ")
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return false
+		}
+		switch strings.TrimSpace(answer) {
+		case "yes":
+			return true
+		case "no", "":
+			return false
+		}
+		fmt.Println("Please answer yes or no.")
 	}
 }
@@ -1,6 +1,11 @@
 package cmds
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/docker/go-units"
+
 	"github.com/cloudway/platform/auth/userdb"
 	"github.com/cloudway/platform/broker"
 	"github.com/cloudway/platform/config/defaults"
@@ -33,13 +38,65 @@ func (cli *CWMan) CmdUserAdd(args ...string) (err error) {
 }
 
 func (cli *CWMan) CmdUserDel(args ...string) error {
+	var force bool
+
 	cmd := cli.Subcmd("userdel", "USERNAME")
 	cmd.Require(mflag.Exact, 1)
+	cmd.BoolVar(&force, []string{"f", "-force"}, false, "Remove the user even if applications exist")
+	cmd.ParseFlags(args, true)
+
+	br, err := broker.New(cli.Engine)
+	if err != nil {
+		return err
+	}
+	return br.RemoveUser(cmd.Arg(0), force)
+}
+
+func (cli *CWMan) CmdUserUnlock(args ...string) error {
+	cmd := cli.Subcmd("userunlock", "USERNAME")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	br, err := broker.New(cli.Engine)
+	if err != nil {
+		return err
+	}
+	return br.Users.Unlock(cmd.Arg(0))
+}
+
+func (cli *CWMan) CmdInvite(args ...string) error {
+	var (
+		inv           userdb.Invite
+		quota, expire string
+	)
+
+	cmd := cli.Subcmd("invite", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.StringVar(&inv.Email, []string{"-email"}, "", "Email address that must be used to register")
+	cmd.StringVar(&inv.Namespace, []string{"-namespace"}, "", "Namespace assigned to the registered user")
+	cmd.StringVar(&quota, []string{"-quota"}, "", "Disk quota of applications of the registered user")
+	cmd.StringVar(&expire, []string{"-expire"}, "168h", "Time before the invitation expires")
 	cmd.ParseFlags(args, true)
 
+	var err error
+	if quota != "" {
+		if inv.DiskQuota, err = units.RAMInBytes(quota); err != nil {
+			return fmt.Errorf("invalid disk quota: %s", quota)
+		}
+	}
+	ttl, err := time.ParseDuration(expire)
+	if err != nil {
+		return fmt.Errorf("invalid expiration time: %s", expire)
+	}
+
 	br, err := broker.New(cli.Engine)
 	if err != nil {
 		return err
 	}
-	return br.RemoveUser(cmd.Arg(0))
+	_, link, err := br.CreateInvite(&inv, ttl)
+	if err != nil {
+		return err
+	}
+	fmt.Println(link)
+	return nil
 }
@@ -0,0 +1,210 @@
+package cmds
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/docker/go-units"
+
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+// User records are read from and written to CSV files with a header row.
+// Only the name column is required when importing. Multiple roles are
+// separated by semicolons.
+var userColumns = []string{"name", "password", "namespace", "quota", "roles"}
+
+func (cli *CWMan) CmdUserImport(args ...string) error {
+	var dryRun bool
+
+	cmd := cli.Subcmd("user import", "FILE")
+	cmd.Require(mflag.Exact, 1)
+	cmd.BoolVar(&dryRun, []string{"n", "-dry-run"}, false, "Validate the records and show what would be done")
+	cmd.ParseFlags(args, true)
+
+	var in io.Reader = os.Stdin
+	if name := cmd.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	records, err := readUserRecords(in)
+	if err != nil {
+		return err
+	}
+
+	br, err := broker.New(cli.Engine)
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]int)
+	var failed int
+	for _, rec := range records {
+		action, err := br.ImportUser(rec, dryRun)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "error: %s: %v\n", rec.Name, err)
+			continue
+		}
+		counts[action]++
+		if action != broker.ImportUnchanged {
+			fmt.Printf("%s %s\n", action, rec.Name)
+		}
+	}
+
+	fmt.Printf("%d created, %d updated, %d unchanged, %d failed",
+		counts[broker.ImportCreate], counts[broker.ImportUpdate], counts[broker.ImportUnchanged], failed)
+	if dryRun {
+		fmt.Print(" (dry run)")
+	}
+	fmt.Println()
+
+	if failed != 0 {
+		return fmt.Errorf("failed to import %d users", failed)
+	}
+	return nil
+}
+
+func (cli *CWMan) CmdUserExport(args ...string) error {
+	var output string
+	var withPasswords bool
+
+	cmd := cli.Subcmd("user export", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.StringVar(&output, []string{"o", "-output"}, "", "Write to a file instead of standard output")
+	cmd.BoolVar(&withPasswords, []string{"-passwords"}, false, "Include hashed passwords")
+	cmd.ParseFlags(args, true)
+
+	br, err := broker.New(cli.Engine)
+	if err != nil {
+		return err
+	}
+	records, err := br.ExportUsers(withPasswords)
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		return writeUserRecords(os.Stdout, records)
+	}
+	f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err = writeUserRecords(f, records); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readUserRecords(r io.Reader) ([]*broker.UserRecord, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !isUserColumn(name) {
+			return nil, fmt.Errorf("unknown column: %s", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("missing column: name")
+	}
+
+	var records []*broker.UserRecord
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		rec := &broker.UserRecord{
+			Name:      field("name"),
+			Password:  field("password"),
+			Namespace: field("namespace"),
+		}
+		if quota := field("quota"); quota != "" {
+			if rec.DiskQuota, err = units.RAMInBytes(quota); err != nil {
+				return nil, fmt.Errorf("%s: invalid quota: %s", rec.Name, quota)
+			}
+		}
+		rec.Roles = strings.FieldsFunc(field("roles"), func(c rune) bool {
+			return c == ';' || c == ' '
+		})
+		records = append(records, rec)
+	}
+}
+
+func isUserColumn(name string) bool {
+	for _, c := range userColumns {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+func writeUserRecords(w io.Writer, records []*broker.UserRecord) error {
+	cw := csv.NewWriter(w)
+	cw.Write(userColumns)
+	for _, rec := range records {
+		cw.Write([]string{
+			rec.Name,
+			rec.Password,
+			rec.Namespace,
+			formatQuota(rec.DiskQuota),
+			strings.Join(rec.Roles, ";"),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatQuota formats the quota in the largest binary unit that represents
+// it exactly, so it's read back as the same value.
+func formatQuota(size int64) string {
+	if size == 0 {
+		return ""
+	}
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"t", units.TiB}, {"g", units.GiB}, {"m", units.MiB}, {"k", units.KiB}} {
+		if size%unit.size == 0 {
+			return strconv.FormatInt(size/unit.size, 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(size, 10)
+}
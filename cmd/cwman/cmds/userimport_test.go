@@ -0,0 +1,34 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func Example_userRecords() {
+	records, err := readUserRecords(strings.NewReader(`# migrated users
+Name, Namespace, Quota, Roles
+alice@example.com, alice, 2g, admin
+bob@example.com, , 1536m,
+"carol@example.com", carol, 1000,
+`))
+	if err != nil {
+		panic(err)
+	}
+	writeUserRecords(os.Stdout, records)
+
+	// Output:
+	// name,password,namespace,quota,roles
+	// alice@example.com,,alice,2g,admin
+	// bob@example.com,,,1536m,
+	// carol@example.com,,carol,1000,
+}
+
+func Example_userRecordsUnknownColumn() {
+	_, err := readUserRecords(strings.NewReader("name,email\n"))
+	fmt.Println(err)
+
+	// Output:
+	// unknown column: email
+}
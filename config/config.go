@@ -55,7 +55,7 @@ func (c *Config) Save() (err error) {
 }
 
 func (c *Config) writeSection(w io.Writer, section string) (err error) {
-	options := c.GetSection(section)
+	options := c.GetRawSection(section)
 	if len(options) == 0 {
 		return
 	}
@@ -80,7 +80,7 @@ func (c *Config) Get(key string) string {
 }
 
 // GetOrDefault get a configuration value, if no such value configured then
-// the default value is returned.
+// the default value is returned. Encrypted values are decrypted.
 func (c *Config) GetOrDefault(key, deflt string) string {
 	if c.cfg != nil {
 		section := conf.DefaultSection
@@ -89,7 +89,7 @@ func (c *Config) GetOrDefault(key, deflt string) string {
 			section, key = parts[0], parts[1]
 		}
 		if value, err := c.cfg.GetString(section, key); err == nil {
-			return value
+			return decrypt(value)
 		}
 	}
 	return deflt
@@ -132,8 +132,19 @@ func (c *Config) GetSections() []string {
 	}
 }
 
-// GetSection get a section in the configuration file.
+// GetSection get a section in the configuration file. Encrypted values
+// are decrypted.
 func (c *Config) GetSection(section string) map[string]string {
+	result := c.GetRawSection(section)
+	for key, value := range result {
+		result[key] = decrypt(value)
+	}
+	return result
+}
+
+// GetRawSection get a section in the configuration file without decrypting
+// values.
+func (c *Config) GetRawSection(section string) map[string]string {
 	result := make(map[string]string)
 
 	if c.cfg == nil {
@@ -167,7 +178,7 @@ func (c *Config) GetOption(section, key string) (value string) {
 	if c.cfg != nil {
 		value, _ = c.cfg.GetString(section, key)
 	}
-	return value
+	return decrypt(value)
 }
 
 // AddOption add a configuration value into the given section.
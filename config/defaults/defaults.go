@@ -1,11 +1,34 @@
 package defaults
 
-import "github.com/cloudway/platform/config"
+import (
+	"compress/gzip"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+
+	"github.com/cloudway/platform/config"
+)
 
 func Domain() string {
 	return config.GetOrDefault("domain", "cloudway.local")
 }
 
+// AppHost returns the default host name of an application. Applications
+// in a namespace delegated with its own domain are served at
+// "<name>.<domain>", other applications are served at
+// "<name>-<namespace>" under the platform domain.
+func AppHost(name, namespace, domain string) string {
+	if domain != "" {
+		return name + "." + domain
+	}
+	return name + "-" + namespace + "." + Domain()
+}
+
 func ConsoleURL() string {
 	// Don't confuse.  The console and API server are combined in a single
 	// service by default, but you can also separate them into two services.
@@ -19,6 +42,53 @@ func ApiURL() (url string) {
 	return
 }
 
+// ConsoleHost returns the host name of the console URL, without port.
+func ConsoleHost() string {
+	return urlHost(ConsoleURL())
+}
+
+// ApiHost returns the host name of the API URL, without port.
+func ApiHost() string {
+	return urlHost(ApiURL())
+}
+
+func urlHost(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(u.Host); err == nil {
+		return host
+	}
+	return strings.Trim(u.Host, "[]")
+}
+
+// SSHHost returns the host name of the SSH server presented to users,
+// which defaults to the host of the API URL.
+func SSHHost() string {
+	if host := config.Get("ssh.host"); host != "" {
+		return host
+	}
+	if host := ApiHost(); host != "" {
+		return host
+	}
+	return "api." + Domain()
+}
+
+// SSHPort returns the port of the SSH server presented to users, which is
+// also the default port the SSH server listens on.
+func SSHPort() int {
+	if n, err := strconv.Atoi(config.Get("ssh.port")); err == nil && n > 0 {
+		return n
+	}
+	return 2200
+}
+
+// SSHURL returns the URL to access application containers with SSH.
+func SSHURL(name, namespace string) string {
+	return fmt.Sprintf("ssh://%s-%s@%s", name, namespace, net.JoinHostPort(SSHHost(), strconv.Itoa(SSHPort())))
+}
+
 func AppHome() string {
 	return config.GetOrDefault("app-home", "/app")
 }
@@ -26,3 +96,75 @@ func AppHome() string {
 func AppUser() string {
 	return config.GetOrDefault("app-user", "cwuser")
 }
+
+// HSTSMaxAge returns the max-age of the Strict-Transport-Security header
+// sent over HTTPS, zero disables the header.
+func HSTSMaxAge() time.Duration {
+	if d, err := time.ParseDuration(config.Get("security.hsts_max_age")); err == nil {
+		return d
+	}
+	return 180 * 24 * time.Hour
+}
+
+// FrameOptions returns the X-Frame-Options header of console pages.
+func FrameOptions() string {
+	return config.GetOrDefault("security.frame_options", "SAMEORIGIN")
+}
+
+// LoginRateLimit returns the number of login attempts allowed per minute
+// from a client address.
+func LoginRateLimit() int {
+	if n, err := strconv.Atoi(config.Get("auth.login_rate_limit")); err == nil && n > 0 {
+		return n
+	}
+	return 20
+}
+
+// TrustedProxies returns the networks of reverse proxies trusted to pass
+// client addresses in request headers, configured by comma separated IP
+// addresses or CIDR ranges. Invalid entries are ignored.
+func TrustedProxies() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, s := range strings.Split(config.Get("security.trusted_proxies"), ",") {
+		s = strings.TrimSpace(s)
+		if ip := net.ParseIP(s); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		} else if _, n, err := net.ParseCIDR(s); err == nil {
+			nets = append(nets, n)
+		}
+	}
+	return nets
+}
+
+// MaxBodySize returns the maximum size in bytes of API request bodies other
+// than archive uploads.
+func MaxBodySize() int64 {
+	if n, err := units.RAMInBytes(config.Get("api.max_body_size")); err == nil && n > 0 {
+		return n
+	}
+	return 1 << 20
+}
+
+// MaxUploadSize returns the maximum size in bytes of archives uploaded to
+// the API server, such as repositories and application data. Zero means
+// unlimited.
+func MaxUploadSize() int64 {
+	if n, err := units.RAMInBytes(config.Get("api.max_upload_size")); err == nil && n > 0 {
+		return n
+	}
+	return 0
+}
+
+// GzipLevel returns the compression level of archives compressed by the API
+// server, from 1 (fastest) to 9 (smallest). Lower levels reduce CPU usage
+// of large downloads and backups.
+func GzipLevel() int {
+	if n, err := strconv.Atoi(config.Get("api.gzip_level")); err == nil && n >= gzip.BestSpeed && n <= gzip.BestCompression {
+		return n
+	}
+	return gzip.DefaultCompression
+}
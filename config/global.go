@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 )
 
 // The root directory of cloudway installation.
@@ -17,6 +18,12 @@ var Debug bool
 // The global configuration file
 var global *Config
 
+// mu guards the global configuration, which may be reloaded at runtime.
+var mu sync.RWMutex
+
+// hooks are called after the global configuration reloaded.
+var hooks []func()
+
 // Error is paniked if the global configuration was not initialized.
 var ErrNotInitialized = errors.New("the configuration was not initialized")
 
@@ -34,11 +41,7 @@ func Initialize() (err error) {
 
 	// Load configuration file
 	filename := filepath.Join(RootDir, "conf", "cloudway.conf")
-	global, err = Open(filename)
-	if err != nil && !os.IsNotExist(err) { // Use defaults if configuration file is missing
-		return err
-	}
-	return nil
+	return load(filename)
 }
 
 // Initialize the client configuration file.
@@ -52,19 +55,109 @@ func InitializeClient() (err error) {
 	}
 
 	filename := filepath.Join(home, ".cloudway")
-	global, err = Open(filename)
+	return load(filename)
+}
+
+func load(filename string) error {
+	c, err := Open(filename)
+	if err != nil && !os.IsNotExist(err) { // Use defaults if configuration file is missing
+		return err
+	}
+	mu.Lock()
+	global = c
+	mu.Unlock()
+	return nil
+}
+
+// current returns the global configuration, or nil if not initialized.
+func current() *Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return global
+}
+
+// Reload reloads the global configuration from file, and notifies the
+// components that registered by OnReload.
+func Reload() error {
+	c := current()
+	if c == nil {
+		return ErrNotInitialized
+	}
+	if err := load(c.filename); err != nil {
+		return err
+	}
+	notifyReload()
+	return nil
+}
+
+// Update applies changes to the configuration file and reloads the global
+// configuration. A key with an empty value is removed. The current and
+// updated configurations are passed to the validate function before saving,
+// and nothing is changed if the validation failed.
+func Update(changes map[string]string, validate func(old, new *Config) error) error {
+	if err := update(changes, validate); err != nil {
+		return err
+	}
+	notifyReload()
+	return nil
+}
+
+func update(changes map[string]string, validate func(old, new *Config) error) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if global == nil {
+		return ErrNotInitialized
+	}
+
+	c, err := Open(global.filename)
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
+	for key, value := range changes {
+		if value == "" {
+			c.Remove(key)
+		} else {
+			c.Set(key, value)
+		}
+	}
+
+	if validate != nil {
+		if err = validate(global, c); err != nil {
+			return err
+		}
+	}
+	if err = c.Save(); err != nil {
+		return err
+	}
+	global = c
 	return nil
 }
 
+// OnReload registers a function to be called after the global configuration
+// reloaded, so that components can pick up changed settings.
+func OnReload(fn func()) {
+	mu.Lock()
+	hooks = append(hooks, fn)
+	mu.Unlock()
+}
+
+func notifyReload() {
+	mu.RLock()
+	fns := hooks
+	mu.RUnlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
+
 // Save global configurations to file.
 func Save() (err error) {
-	if global == nil {
+	c := current()
+	if c == nil {
 		return ErrNotInitialized
 	}
-	return global.Save()
+	return c.Save()
 }
 
 // Get a configuration value as string
@@ -76,82 +169,106 @@ func Get(key string) string {
 // the default value is returned.
 func GetOrDefault(key, deflt string) string {
 	// get value from environment
-	envKey := "CLOUDWAY_" + strings.ToUpper(key)
-	envKey = strings.Replace(envKey, "-", "_", -1)
-	envKey = strings.Replace(envKey, ".", "_", -1)
-	if envValue, ok := os.LookupEnv(envKey); ok {
-		return envValue
+	if envValue, ok := lookupEnv(key); ok {
+		return decrypt(envValue)
 	}
 
 	// get value from configuration file
-	if global != nil {
-		return global.GetOrDefault(key, deflt)
+	if c := current(); c != nil {
+		return c.GetOrDefault(key, deflt)
 	}
 
 	// return the default value
 	return deflt
 }
 
+// lookupEnv get a configuration value from environment variable. The
+// variable name is the upper cased key prefixed with "CLOUDWAY_".
+func lookupEnv(key string) (string, bool) {
+	envKey := "CLOUDWAY_" + strings.ToUpper(key)
+	envKey = strings.Replace(envKey, "-", "_", -1)
+	envKey = strings.Replace(envKey, ".", "_", -1)
+	return os.LookupEnv(envKey)
+}
+
 // Set a configuration of the given key to the given value.
 func Set(key, value string) {
-	if global == nil {
+	c := current()
+	if c == nil {
 		panic(ErrNotInitialized)
 	}
-	global.Set(key, value)
+	c.Set(key, value)
 }
 
 // Remove a key from configuration.
 func Remove(key string) {
-	if global == nil {
+	c := current()
+	if c == nil {
 		panic(ErrNotInitialized)
 	}
-	global.Remove(key)
+	c.Remove(key)
 }
 
 // GetSections returns the list of sections in the configuration.
 func GetSections() []string {
-	if global == nil {
+	c := current()
+	if c == nil {
 		panic(ErrNotInitialized)
 	}
-	return global.GetSections()
+	return c.GetSections()
 }
 
 // GetSection get section in the configuration file.
 func GetSection(section string) map[string]string {
-	if global == nil {
+	c := current()
+	if c == nil {
 		panic(ErrNotInitialized)
 	}
-	return global.GetSection(section)
+	return c.GetSection(section)
 }
 
 // RemoveSection remove a section from configuration.
 func RemoveSection(section string) {
-	if global == nil {
+	c := current()
+	if c == nil {
+		panic(ErrNotInitialized)
+	}
+	c.RemoveSection(section)
+}
+
+// GetRawSection get section in the configuration file without decrypting
+// values.
+func GetRawSection(section string) map[string]string {
+	c := current()
+	if c == nil {
 		panic(ErrNotInitialized)
 	}
-	global.RemoveSection(section)
+	return c.GetRawSection(section)
 }
 
 // GetFrom get a configuration value from the given section.
 func GetOption(section, key string) string {
-	if global == nil {
+	c := current()
+	if c == nil {
 		panic(ErrNotInitialized)
 	}
-	return global.GetOption(section, key)
+	return c.GetOption(section, key)
 }
 
 // AddOption add a configuration value into the given section.
 func AddOption(section, key, value string) {
-	if global == nil {
+	c := current()
+	if c == nil {
 		panic(ErrNotInitialized)
 	}
-	global.AddOption(section, key, value)
+	c.AddOption(section, key, value)
 }
 
 // RemoveOption removes a configuration value from the given section.
 func RemoveOption(section, key string) {
-	if global == nil {
+	c := current()
+	if c == nil {
 		panic(ErrNotInitialized)
 	}
-	global.RemoveOption(section, key)
+	c.RemoveOption(section, key)
 }
@@ -0,0 +1,316 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+
+	"github.com/cloudway/platform/pkg/conf"
+)
+
+// Kind describes the type of a configuration value.
+type Kind int
+
+const (
+	String Kind = iota
+	Bool
+	Int
+	Size
+	Duration
+	URL
+	Hostname
+	Path
+)
+
+// Schema maps known configuration keys to their kinds. Keys in the
+// default section are not qualified.
+var Schema = map[string]Kind{
+	"domain":   Hostname,
+	"app-home": Path,
+	"app-user": String,
+	"network":  String,
+
+	"console.url": URL,
+	"api.url":     URL,
+	"admin.users": String,
+
+	"api.max_body_size":   Size,
+	"api.max_upload_size": Size,
+	"api.gzip_level":      Int,
+
+	"console.session.store": String,
+
+	"auth.max_failed_logins": Int,
+	"auth.lockout_duration":  Duration,
+	"auth.login_rate_limit":  Int,
+	"auth.registration":      String,
+
+	"password.min_length":  Int,
+	"password.max_length":  Int,
+	"password.min_classes": Int,
+	"password.deny_list":   Path,
+	"password.check_pwned": Bool,
+
+	"user.deletion_grace":      Duration,
+	"user.reserved_namespaces": String,
+
+	"app.disk_quota":           Size,
+	"app.restart_policy":       String,
+	"app.crashloop_restarts":   Int,
+	"app.crashloop_window":     Duration,
+	"app.trash_retention":      Duration,
+	"app.deploy_windows":       String,
+	"app.canary_promote_after": Duration,
+	"app.reserved_names":       String,
+	"app.read_only_root":       Bool,
+	"app.seccomp_profile":      Path,
+	"app.allow_root":           Bool,
+
+	"artifact.retain_count": Int,
+	"artifact.retain_age":   Duration,
+
+	"build.image_repository": String,
+
+	"upload.expire": Duration,
+
+	"backup.s3_endpoint":   URL,
+	"backup.s3_region":     String,
+	"backup.s3_access_key": String,
+	"backup.s3_secret_key": String,
+
+	"hub.dir":            Path,
+	"hub.prepull":        Bool,
+	"hub.trusted_keys":   Path,
+	"hub.allow_unsigned": Bool,
+
+	"dns.url":    URL,
+	"dns.key":    String,
+	"dns.secret": String,
+	"dns.target": String,
+	"dns.ttl":    Int,
+
+	"docker.host":     URL,
+	"docker.tls_ca":   String,
+	"docker.tls_cert": String,
+	"docker.tls_key":  String,
+
+	"node.max_containers":  Int,
+	"node.health_interval": Duration,
+
+	"proxy.url":              URL,
+	"proxy.reload":           String,
+	"proxy.maintenance_page": Path,
+	"proxy.redirect_ttl":     Duration,
+
+	"redis.url": URL,
+
+	"security.hsts_max_age":    Duration,
+	"security.frame_options":   String,
+	"security.console_csp":     String,
+	"security.trusted_proxies": String,
+
+	"scm.type":           String,
+	"scm.url":            URL,
+	"scm.clone_url":      String,
+	"scm.username":       String,
+	"scm.password":       String,
+	"scm.token":          String,
+	"scm.timeout":        Duration,
+	"scm.max_idle_conns": Int,
+	"scm.cache_ttl":      Duration,
+
+	"ssh.host": Hostname,
+	"ssh.port": Int,
+
+	"smtp.host":     Hostname,
+	"smtp.port":     Int,
+	"smtp.username": String,
+	"smtp.password": String,
+	"smtp.from":     String,
+
+	"userdb.type":      String,
+	"userdb.url":       URL,
+	"userdb.cache_ttl": Duration,
+}
+
+// Sections with free-form keys, such as proxy mappings, plugin
+// environments and registry credentials, are not checked against the
+// schema.
+var freeSections = []string{"proxy-mapping", "proxy-cert", "proxy-redirect", "plugin:", "registry:"}
+
+// Required lists keys that must be configured for the platform to work.
+var Required = []string{"scm.type", "userdb.url"}
+
+// Requirements lists keys that must be configured together. If the first
+// key is configured then all other keys must also be configured.
+var Requirements = [][]string{
+	{"scm.type", "scm.url"},
+	{"scm.url", "scm.type"},
+	{"scm.username", "scm.password"},
+	{"smtp.host", "smtp.username", "smtp.password"},
+	{"backup.s3_access_key", "backup.s3_secret_key"},
+	{"userdb.type", "userdb.url"},
+	{"dns.url", "dns.target"},
+	{"docker.tls_cert", "docker.tls_key", "docker.host"},
+	{"docker.tls_key", "docker.tls_cert"},
+}
+
+var hostnamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+
+// ValidationError describes a problem of a configuration key.
+type ValidationError struct {
+	Key     string
+	Message string
+
+	// Warnings are reported but do not prevent the server from starting.
+	Warning bool
+}
+
+func (e *ValidationError) Error() string {
+	return e.Key + ": " + e.Message
+}
+
+// ValidationErrors is a list of configuration problems.
+type ValidationErrors []*ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return "Invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+// Errors returns problems excluding warnings.
+func (errs ValidationErrors) Errors() ValidationErrors {
+	var result ValidationErrors
+	for _, e := range errs {
+		if !e.Warning {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func (errs ValidationErrors) Len() int           { return len(errs) }
+func (errs ValidationErrors) Less(i, j int) bool { return errs[i].Key < errs[j].Key }
+func (errs ValidationErrors) Swap(i, j int)      { errs[i], errs[j] = errs[j], errs[i] }
+
+// Validate checks the configuration against the schema and returns all
+// problems found, sorted by key. Values overridden by environment variables
+// are taken into account.
+func (c *Config) Validate() ValidationErrors {
+	get := func(key string) string {
+		if value, ok := lookupEnv(key); ok {
+			return value
+		}
+		return c.Get(key)
+	}
+
+	var errs ValidationErrors
+
+	// report unknown keys, which are most likely typos, and encrypted
+	// values that cannot be decrypted
+	for _, section := range c.GetSections() {
+		free := isFreeSection(section)
+		for key, value := range c.GetRawSection(section) {
+			if section != conf.DefaultSection {
+				key = section + "." + key
+			}
+			if _, err := Decrypt(value); err != nil {
+				errs = append(errs, &ValidationError{Key: key, Message: err.Error()})
+			}
+			if _, ok := Schema[key]; !ok && !free {
+				errs = append(errs, &ValidationError{key, "unknown configuration key", true})
+			}
+		}
+	}
+
+	for key, kind := range Schema {
+		if value := get(key); value != "" {
+			if msg := checkValue(kind, value); msg != "" {
+				errs = append(errs, &ValidationError{Key: key, Message: msg})
+			}
+		}
+	}
+
+	for _, key := range Required {
+		if get(key) == "" {
+			errs = append(errs, &ValidationError{Key: key, Message: "required but not configured"})
+		}
+	}
+
+	for _, req := range Requirements {
+		if get(req[0]) == "" {
+			continue
+		}
+		for _, key := range req[1:] {
+			if get(key) == "" {
+				msg := fmt.Sprintf("required when %s is configured", req[0])
+				errs = append(errs, &ValidationError{Key: key, Message: msg})
+			}
+		}
+	}
+
+	sort.Stable(errs)
+	return errs
+}
+
+// Validate checks the global configuration against the schema.
+func Validate() ValidationErrors {
+	c := current()
+	if c == nil {
+		c = &Config{}
+	}
+	return c.Validate()
+}
+
+func isFreeSection(section string) bool {
+	for _, s := range freeSections {
+		if strings.HasSuffix(s, ":") && strings.HasPrefix(section, s) || section == s {
+			return true
+		}
+	}
+	return false
+}
+
+func checkValue(kind Kind, value string) string {
+	switch kind {
+	case Bool:
+		if _, ok := conf.BoolStrings[strings.ToLower(value)]; !ok {
+			return fmt.Sprintf("invalid boolean value %q", value)
+		}
+	case Int:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Sprintf("invalid integer value %q", value)
+		}
+	case Size:
+		if _, err := units.RAMInBytes(value); err != nil {
+			return err.Error()
+		}
+	case Duration:
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Sprintf("invalid duration %q", value)
+		}
+	case URL:
+		if u, err := url.Parse(value); err != nil || u.Scheme == "" {
+			return fmt.Sprintf("invalid URL %q", value)
+		}
+	case Hostname:
+		if !hostnamePattern.MatchString(value) {
+			return fmt.Sprintf("invalid host name %q", value)
+		}
+	case Path:
+		if !filepath.IsAbs(value) {
+			return fmt.Sprintf("must be an absolute path: %q", value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Encrypted configuration values are wrapped in "ENC[...]", the content
+// is the base64 encoded AES-GCM nonce and cipher text.
+const (
+	encPrefix = "ENC["
+	encSuffix = "]"
+)
+
+// The size of master key in bytes, which selects AES-256.
+const MasterKeySize = 32
+
+var ErrNoMasterKey = errors.New("master key not configured, set CLOUDWAY_MASTER_KEY or run 'cwman config genkey'")
+
+// MasterKey returns the key used to encrypt and decrypt configuration
+// values. The key is taken from the CLOUDWAY_MASTER_KEY environment
+// variable or from the master key file. It can be replaced to fetch the
+// key from an external key management service.
+var MasterKey = func() ([]byte, error) {
+	if env := os.Getenv("CLOUDWAY_MASTER_KEY"); env != "" {
+		return decodeMasterKey(env)
+	}
+
+	data, err := ioutil.ReadFile(MasterKeyFile())
+	if os.IsNotExist(err) {
+		return nil, ErrNoMasterKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeMasterKey(string(data))
+}
+
+// MasterKeyFile returns the path of master key file, which can be set by
+// the CLOUDWAY_MASTER_KEY_FILE environment variable.
+func MasterKeyFile() string {
+	if file := os.Getenv("CLOUDWAY_MASTER_KEY_FILE"); file != "" {
+		return file
+	}
+	return filepath.Join(RootDir, "conf", "master.key")
+}
+
+func decodeMasterKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != MasterKeySize {
+		return nil, errors.New("invalid master key")
+	}
+	return key, nil
+}
+
+// GenerateMasterKey creates a new random master key and saves it to the
+// master key file. An existing key file is never overwritten.
+func GenerateMasterKey() error {
+	key := make([]byte, MasterKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return err
+	}
+
+	filename := MasterKeyFile()
+	if err := os.MkdirAll(filepath.Dir(filename), 0750); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.WriteString(f, base64.StdEncoding.EncodeToString(key)+"\n")
+	return err
+}
+
+// IsEncrypted returns true if the configuration value is encrypted.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encPrefix) && strings.HasSuffix(value, encSuffix)
+}
+
+// Encrypt encrypts a configuration value with the master key.
+func Encrypt(plain string) (string, error) {
+	gcm, err := newCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	data := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return encPrefix + base64.StdEncoding.EncodeToString(data) + encSuffix, nil
+}
+
+// Decrypt decrypts a configuration value with the master key. Values
+// that are not encrypted are returned unchanged.
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(value[len(encPrefix) : len(value)-len(encSuffix)])
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %v", err)
+	}
+
+	gcm, err := newCipher()
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	nonce, data := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, data, nil)
+	if err != nil {
+		return "", errors.New("cannot decrypt value, wrong master key?")
+	}
+	return string(plain), nil
+}
+
+func newCipher() (cipher.AEAD, error) {
+	key, err := MasterKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decrypt returns the decrypted value, or an empty string if the value
+// cannot be decrypted. Such problems are reported by Validate.
+func decrypt(value string) string {
+	if !IsEncrypted(value) {
+		return value
+	}
+	plain, err := Decrypt(value)
+	if err != nil {
+		return ""
+	}
+	return plain
+}
@@ -0,0 +1,90 @@
+package console
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/authboss.v0"
+
+	"github.com/cloudway/platform/auth/userdb"
+)
+
+func (con *Console) initAccountRoutes(gets *mux.Router, posts *mux.Router) {
+	posts.HandleFunc("/settings/email", con.changeEmail)
+	posts.HandleFunc("/settings/delete", con.deleteAccount)
+	posts.HandleFunc("/settings/restore", con.restoreAccount)
+	gets.HandleFunc("/email/confirm", con.confirmEmail)
+}
+
+func (con *Console) changeEmail(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	email := strings.TrimSpace(r.FormValue("email"))
+	if err := con.NewUserBroker(user).ChangeEmail(email); err != nil {
+		con.renderSettings(w, r, user, "account_error", err)
+		return
+	}
+
+	con.flash(w, r, authboss.FlashSuccessKey, "确认链接已发送至 "+email+"，请在24小时内确认")
+	http.Redirect(w, r, "/settings", http.StatusFound)
+}
+
+// confirmEmail changes the email address with the token sent to the new
+// address. The user is logged out and must login with the new address.
+func (con *Console) confirmEmail(w http.ResponseWriter, r *http.Request) {
+	if _, err := con.ConfirmEmail(r.FormValue("token")); err != nil {
+		con.error(w, r, http.StatusBadRequest, err.Error(), "/settings")
+		return
+	}
+
+	con.ab.SessionStoreMaker(w, r).Del(authboss.SessionKey)
+	con.flash(w, r, authboss.FlashSuccessKey, "邮件地址已更改，请使用新的邮件地址登录")
+	http.Redirect(w, r, "/auth/login", http.StatusFound)
+}
+
+// deleteAccount schedules deletion of the account after the password is
+// verified.
+func (con *Console) deleteAccount(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	if _, err := con.Users.Authenticate(user.Name, r.FormValue("password")); err != nil {
+		var msg interface{} = "密码错误"
+		if userdb.IsAccountLocked(err) {
+			msg = err
+		}
+		con.renderSettings(w, r, user, "account_error", msg)
+		return
+	}
+
+	if _, err := con.NewUserBroker(user).DeleteAccount(user.Name); err != nil {
+		con.renderSettings(w, r, user, "account_error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/settings", http.StatusFound)
+}
+
+func (con *Console) restoreAccount(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	err := con.NewUserBroker(user).RestoreAccount()
+	if con.badRequest(w, r, err, "/settings") {
+		return
+	}
+
+	http.Redirect(w, r, "/settings", http.StatusFound)
+}
+
+func (con *Console) flash(w http.ResponseWriter, r *http.Request, key, message string) {
+	con.ab.SessionStoreMaker(w, r).Put(key, message)
+}
@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -19,6 +18,7 @@ import (
 	"golang.org/x/net/websocket"
 	"gopkg.in/authboss.v0"
 
+	"github.com/cloudway/platform/api/types"
 	"github.com/cloudway/platform/auth/userdb"
 	"github.com/cloudway/platform/broker"
 	"github.com/cloudway/platform/config"
@@ -33,11 +33,17 @@ func (con *Console) initApplicationsRoutes(gets *mux.Router, posts *mux.Router)
 	gets.HandleFunc("/applications", con.getApplications)
 	gets.HandleFunc("/applications/create/form", con.createApplicationForm)
 	gets.HandleFunc("/applications/create/ws", con.createApplication)
+	gets.HandleFunc("/applications/group/ws", con.groupOperation)
 	gets.HandleFunc("/applications/{name}", con.getApplication)
 	gets.HandleFunc("/applications/{name}/settings", con.getApplicationSettings)
 	posts.HandleFunc("/applications/{name}/host", con.addHost)
 	posts.HandleFunc("/applications/{name}/host/delete", con.removeHost)
 	posts.HandleFunc("/applications/{name}/reload", con.restartApplication)
+	posts.HandleFunc("/applications/{name}/maintenance", con.setMaintenance)
+	posts.HandleFunc("/applications/{name}/protection", con.setProtection)
+	posts.HandleFunc("/applications/{name}/deploylock", con.setDeployLock)
+	posts.HandleFunc("/applications/{name}/accesspolicy", con.setAccessPolicy)
+	posts.HandleFunc("/applications/{name}/https", con.setHTTPSettings)
 	gets.HandleFunc("/applications/{name}/reload/ws", con.wsRestartApplication)
 	gets.HandleFunc("/applications/{name}/deploy", con.deployApplication)
 	posts.HandleFunc("/applications/{name}/scale", con.scaleApplication)
@@ -56,6 +62,7 @@ type appListData struct {
 	CreatedAt time.Time
 	Framework string
 	Plugins   []string
+	Labels    map[string]string
 }
 
 type appList []*appListData
@@ -64,16 +71,16 @@ func (a appList) Len() int           { return len(a) }
 func (a appList) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a appList) Less(i, j int) bool { return a[i].CreatedAt.After(a[j].CreatedAt) }
 
-func (con *Console) appDNS(name, namespace string) string {
-	return fmt.Sprintf("%s-%s.%s", name, namespace, defaults.Domain())
+func (con *Console) appDNS(name, namespace, domain string) string {
+	return defaults.AppHost(name, namespace, domain)
 }
 
-func (con *Console) appURL(name, namespace string) string {
+func (con *Console) appURL(name, namespace, domain string) string {
 	host, port := con.baseURL.Host, ""
 	if i := strings.IndexRune(host, ':'); i != -1 {
 		port = host[i:]
 	}
-	return fmt.Sprintf("%s://%s-%s.%s%s", con.baseURL.Scheme, name, namespace, defaults.Domain(), port)
+	return fmt.Sprintf("%s://%s%s", con.baseURL.Scheme, defaults.AppHost(name, namespace, domain), port)
 }
 
 func (con *Console) wsURL() string {
@@ -90,8 +97,25 @@ func (con *Console) getApplications(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := r.ParseForm(); con.badRequest(w, r, err, "/applications") {
+		return
+	}
+	sel, err := broker.ParseLabelSelector(strings.Fields(r.Form.Get("label")))
+	if con.badRequest(w, r, err, "/applications") {
+		return
+	}
+	groupBy := r.Form.Get("group")
+	if _, ok := r.Form["group"]; !ok {
+		// the default view of the namespace
+		groupBy = user.Preferences.NamespaceView
+	}
+
 	var apps []*appListData
 	for name, a := range user.Applications {
+		if !sel.Matches(a.Labels) {
+			continue
+		}
+
 		framework := ""
 		plugins := make([]string, 0, len(a.Plugins))
 		for _, tag := range a.Plugins {
@@ -107,16 +131,26 @@ func (con *Console) getApplications(w http.ResponseWriter, r *http.Request) {
 
 		apps = append(apps, &appListData{
 			Name:      name,
-			URL:       con.appURL(name, user.Namespace),
+			URL:       con.appURL(name, user.Namespace, user.Domain),
 			CreatedAt: a.CreatedAt,
 			Framework: framework,
 			Plugins:   plugins,
+			Labels:    a.Labels,
 		})
 	}
 	sort.Sort(appList(apps))
 
 	data := con.layoutUserData(w, r, user)
 	data.MergeKV("apps", apps)
+	data.MergeKV("labelKeys", labelKeys(user.Applications))
+	data.MergeKV("label", r.Form.Get("label"))
+	data.MergeKV("group", groupBy)
+	if groupBy != "" {
+		data.MergeKV("groups", groupApplications(apps, groupBy))
+	} else if len(apps) != 0 {
+		data.MergeKV("groups", []*appGroup{{Apps: apps}})
+	}
+	data.MergeKV("ws", con.wsURL()+"/applications/group/ws")
 	con.mustRender(w, r, "app_list", data)
 }
 
@@ -150,9 +184,14 @@ func (con *Console) createApplication(w http.ResponseWriter, r *http.Request) {
 	}
 
 	h := func(conn *websocket.Conn) {
-		opts, tags, err := parseCreateOptions(r)
+		br := con.NewUserBroker(user)
+		opts, tags, err := parseCreateOptions(br, r)
 		if err != nil {
-			data := map[string]string{"err": err.Error()}
+			data := map[string]interface{}{"err": err.Error()}
+			if verr, ok := err.(*types.ValidationError); ok {
+				data["err"] = "应用参数无效"
+				data["fields"] = verr.Fields
+			}
 			json.NewEncoder(conn).Encode(data)
 			return
 		}
@@ -160,7 +199,6 @@ func (con *Console) createApplication(w http.ResponseWriter, r *http.Request) {
 		jw := jsonWriter{enc: json.NewEncoder(conn)}
 		opts.Log = serverlog.Encap(jw, jw)
 
-		br := con.NewUserBroker(user)
 		_, cs, err := br.CreateApplication(opts, tags)
 		if err == nil {
 			err = br.StartContainers(cs, opts.Log)
@@ -175,32 +213,30 @@ func (con *Console) createApplication(w http.ResponseWriter, r *http.Request) {
 	srv.ServeHTTP(w, r)
 }
 
-var namePattern = regexp.MustCompile("^[a-z][a-z_0-9]*$")
-
-func parseCreateOptions(r *http.Request) (opts container.CreateOptions, tags []string, err error) {
+// parseCreateOptions parses and validates options of a new application,
+// the validation is shared with the remote API.
+func parseCreateOptions(br *broker.UserBroker, r *http.Request) (opts container.CreateOptions, tags []string, err error) {
 	err = r.ParseForm()
 	if err != nil {
 		return
 	}
 
-	opts = container.CreateOptions{
-		Name:    r.Form.Get("name"),
-		Repo:    r.Form.Get("repo"),
-		Scaling: 1,
+	req := types.CreateApplication{
+		Name:      r.Form.Get("name"),
+		Framework: r.Form.Get("framework"),
+		Services:  strings.Fields(r.Form.Get("services")),
+		Repo:      r.Form.Get("repo"),
 	}
-
-	if !namePattern.MatchString(opts.Name) {
-		err = errors.New("应用名称只能包含小写英文字母、数字、或者下划线")
+	if err = br.ValidateCreateApplication(&req); err != nil {
 		return
 	}
 
-	framework := r.Form.Get("framework")
-	services := strings.Fields(r.Form.Get("services"))
-	if framework == "" {
-		err = errors.New("应用框架不能为空")
-		return
+	opts = container.CreateOptions{
+		Name:    req.Name,
+		Repo:    req.Repo,
+		Scaling: 1,
 	}
-	tags = append([]string{framework}, services...)
+	tags = append([]string{req.Framework}, req.Services...)
 	return
 }
 
@@ -268,17 +304,28 @@ func startContainers(br *broker.UserBroker, containers []container.Container) er
 }
 
 type appData struct {
-	Name       string
-	DNS        string
-	URL        string
-	WS         string
-	CloneURL   string
-	Branch     *scm.Branch
-	Branches   []*scm.Branch
-	Frameworks []serviceData
-	Services   []serviceData
-	Hosts      []string
-	Scale      int
+	Name        string
+	DNS         string
+	URL         string
+	SSH         string
+	WS          string
+	CloneURL    string
+	Branch      *scm.Branch
+	Branches    []*scm.Branch
+	Frameworks  []serviceData
+	Services    []serviceData
+	Hosts       []string
+	Scale       int
+	Maintenance *userdb.Maintenance
+	Protected   bool
+	DeployLock  *userdb.DeployLock
+
+	LastDeployment *broker.Deployment
+	DiskQuota      int64
+	Resources      container.Resources
+
+	AccessPolicy *userdb.AccessPolicy
+	HTTPSettings *userdb.HTTPSettings
 }
 
 type serviceData struct {
@@ -288,6 +335,7 @@ type serviceData struct {
 	Logo        string
 	PluginTag   string
 	PluginName  string
+	Version     string
 	Category    manifest.Category
 	IP          string
 	Ports       string
@@ -315,10 +363,14 @@ func (con *Console) showApplication(w http.ResponseWriter, r *http.Request, user
 	}
 
 	appData := &appData{
-		Name: name,
-		DNS:  con.appDNS(name, user.Namespace),
-		URL:  con.appURL(name, user.Namespace),
-		WS:   con.wsURL(),
+		Name:        name,
+		DNS:         con.appDNS(name, user.Namespace, user.Domain),
+		URL:         con.appURL(name, user.Namespace, user.Domain),
+		SSH:         defaults.SSHURL(name, user.Namespace),
+		WS:          con.wsURL(),
+		Maintenance: app.Maintenance,
+		Protected:   app.Protected,
+		DeployLock:  app.DeployLock,
 	}
 
 	cloneURL := config.Get("scm.clone_url")
@@ -346,13 +398,14 @@ func (con *Console) showApplication(w http.ResponseWriter, r *http.Request, user
 			Name:     c.ServiceName(),
 			Category: c.Category(),
 			IP:       c.IP(),
-			State:    c.ActiveState(ctx).String(),
+			State:    con.ActiveState(ctx, c).String(),
 		}
 
 		tag := c.PluginTag()
 		if meta, err := con.Hub.GetPluginInfo(tag); err == nil {
 			service.PluginTag = meta.Tag
 			service.PluginName = meta.Name
+			service.Version = meta.Version
 			service.DisplayName = meta.DisplayName
 			service.Logo = meta.Logo
 			service.Ports = getPrivatePorts(meta)
@@ -364,6 +417,7 @@ func (con *Console) showApplication(w http.ResponseWriter, r *http.Request, user
 
 		if c.Category().IsFramework() {
 			scale++
+			appData.Resources = c.Resources()
 			frameworks = append(frameworks, service)
 		} else {
 			services = append(services, service)
@@ -388,6 +442,12 @@ func (con *Console) showApplication(w http.ResponseWriter, r *http.Request, user
 	appData.Frameworks = frameworks
 	appData.Scale = scale
 
+	if current, err := con.CurrentDeploymentBranch(name, user.Namespace); err == nil {
+		appData.Branch = current
+	}
+	appData.LastDeployment, _ = con.LatestDeployment(name, user.Namespace)
+	appData.DiskQuota = con.NamespaceDiskQuota(user.Namespace)
+
 	data.MergeKV("app", appData)
 	data.MergeKV("available_plugins", plugins)
 	con.mustRender(w, r, "app", data)
@@ -465,10 +525,17 @@ func (con *Console) showApplicationSettings(w http.ResponseWriter, r *http.Reque
 	}
 
 	appData := &appData{
-		Name: name,
-		DNS:  con.appDNS(name, user.Namespace),
-		URL:  con.appURL(name, user.Namespace),
-		WS:   con.wsURL(),
+		Name:        name,
+		DNS:         con.appDNS(name, user.Namespace, user.Domain),
+		URL:         con.appURL(name, user.Namespace, user.Domain),
+		SSH:         defaults.SSHURL(name, user.Namespace),
+		WS:          con.wsURL(),
+		Maintenance: app.Maintenance,
+		Protected:   app.Protected,
+		DeployLock:  app.DeployLock,
+
+		AccessPolicy: app.AccessPolicy,
+		HTTPSettings: app.HTTPSettings,
 	}
 
 	cloneURL := config.Get("scm.clone_url")
@@ -478,14 +545,17 @@ func (con *Console) showApplicationSettings(w http.ResponseWriter, r *http.Reque
 		appData.CloneURL = cloneURL
 	}
 
-	branch, err := con.SCM.GetDeploymentBranch(user.Namespace, name)
+	branch, err := con.SCM().GetDeploymentBranch(user.Namespace, name)
 	if err != nil {
 		logrus.Error(err)
 	} else {
 		appData.Branch = branch
 	}
 
-	branches, err := con.SCM.GetDeploymentBranches(user.Namespace, name)
+	if refresh, _ := strconv.ParseBool(r.FormValue("refresh")); refresh {
+		scm.InvalidateCache(con.SCM(), user.Namespace, name)
+	}
+	branches, err := con.SCM().GetDeploymentBranches(user.Namespace, name, scm.BranchOptions{})
 	if err != nil {
 		logrus.Error(err)
 	} else {
@@ -498,6 +568,131 @@ func (con *Console) showApplicationSettings(w http.ResponseWriter, r *http.Reque
 	con.mustRender(w, r, "app_settings", data)
 }
 
+func (con *Console) setMaintenance(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	on := r.FormValue("on") == "1"
+	stop := r.FormValue("stop") == "1"
+	err := con.NewUserBroker(user).SetMaintenance(name, on, stop, nil)
+	if !con.badRequest(w, r, err, "/applications/"+name+"/settings") {
+		http.Redirect(w, r, "/applications/"+name+"/settings", http.StatusFound)
+	}
+}
+
+func (con *Console) setDeployLock(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	var err error
+	if r.FormValue("on") == "1" {
+		err = con.LockDeployments(name, user.Namespace, user.Name, r.FormValue("reason"))
+	} else {
+		err = con.UnlockDeployments(name, user.Namespace)
+	}
+	if !con.badRequest(w, r, err, "/applications/"+name+"/settings") {
+		http.Redirect(w, r, "/applications/"+name+"/settings", http.StatusFound)
+	}
+}
+
+func (con *Console) setAccessPolicy(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	// basic auth users are entered as "user:password" per line, the
+	// password of an existing user is kept if omitted
+	policy := &types.AccessPolicy{
+		Allow:               strings.Fields(r.FormValue("allow")),
+		Deny:                strings.Fields(r.FormValue("deny")),
+		MaintenanceRedirect: strings.TrimSpace(r.FormValue("redirect")),
+	}
+	for _, line := range strings.Split(r.FormValue("auth"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			kv := strings.SplitN(line, ":", 2)
+			u := types.BasicAuthUser{User: kv[0]}
+			if len(kv) == 2 {
+				u.Password = kv[1]
+			}
+			policy.BasicAuth = append(policy.BasicAuth, u)
+		}
+	}
+
+	name := mux.Vars(r)["name"]
+	err := con.NewUserBroker(user).SetAccessPolicy(name, policy)
+	if !con.badRequest(w, r, err, "/applications/"+name+"/settings") {
+		http.Redirect(w, r, "/applications/"+name+"/settings", http.StatusFound)
+	}
+}
+
+func (con *Console) setHTTPSettings(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	settings := &userdb.HTTPSettings{
+		ForceHTTPS:            r.FormValue("force") == "1",
+		HSTSIncludeSubdomains: r.FormValue("subdomains") == "1",
+		ErrorPages:            make(map[string]string),
+		WebSocket:             r.FormValue("websocket") == "1",
+		GRPC:                  r.FormValue("grpc") == "1",
+	}
+	if v := strings.TrimSpace(r.FormValue("hsts")); v != "" {
+		maxAge, err := strconv.Atoi(v)
+		if err != nil {
+			con.badRequest(w, r, broker.InvalidHTTPSettingsError{Message: "HSTS 有效期必须是整数"}, "/applications/"+name+"/settings")
+			return
+		}
+		settings.HSTSMaxAge = maxAge
+	}
+	if v := strings.TrimSpace(r.FormValue("timeout")); v != "" {
+		timeout, err := strconv.Atoi(v)
+		if err != nil {
+			con.badRequest(w, r, broker.InvalidHTTPSettingsError{Message: "空闲超时必须是整数"}, "/applications/"+name+"/settings")
+			return
+		}
+		settings.IdleTimeout = timeout
+	}
+
+	// error pages are entered as "code url" per line
+	for _, line := range strings.Split(r.FormValue("errorpages"), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			settings.ErrorPages[fields[0]] = fields[1]
+		} else if len(fields) != 0 {
+			con.badRequest(w, r, broker.InvalidHTTPSettingsError{Message: "错误页面格式无效：" + line}, "/applications/"+name+"/settings")
+			return
+		}
+	}
+
+	err := con.NewUserBroker(user).SetHTTPSettings(name, settings)
+	if !con.badRequest(w, r, err, "/applications/"+name+"/settings") {
+		http.Redirect(w, r, "/applications/"+name+"/settings", http.StatusFound)
+	}
+}
+
+func (con *Console) setProtection(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	on := r.FormValue("on") == "1"
+	err := con.NewUserBroker(user).SetProtection(name, on)
+	if !con.badRequest(w, r, err, "/applications/"+name+"/settings") {
+		http.Redirect(w, r, "/applications/"+name+"/settings", http.StatusFound)
+	}
+}
+
 func (con *Console) restartApplication(w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
 	user := con.currentUser(w, r)
@@ -590,7 +785,8 @@ func (con *Console) deployApplication(w http.ResponseWriter, r *http.Request) {
 	h := func(conn *websocket.Conn) {
 		jw := jsonWriter{enc: json.NewEncoder(conn)}
 		log := serverlog.Encap(jw, jw)
-		err := con.Deploy(name, user.Namespace, branch, log)
+		ctx := broker.WithActor(r.Context(), user.Name)
+		err := con.Deploy(ctx, name, user.Namespace, branch, log)
 		if err != nil {
 			data := map[string]string{"err": err.Error()}
 			json.NewEncoder(conn).Encode(data)
@@ -608,7 +804,12 @@ func (con *Console) removeApplication(w http.ResponseWriter, r *http.Request) {
 	}
 
 	name := mux.Vars(r)["name"]
-	err := con.NewUserBroker(user).RemoveApplication(name)
+	br := con.NewUserBroker(user)
+	err := br.CheckProtection(name, r.FormValue("confirm"))
+	if con.badRequest(w, r, err, "/applications/"+name+"/settings") {
+		return
+	}
+	err = br.RemoveApplication(name)
 	if con.badRequest(w, r, err, "/applications") {
 		return
 	} else {
@@ -645,6 +846,12 @@ func (con *Console) scaleApplication(w http.ResponseWriter, r *http.Request) {
 	}
 
 	br := con.NewUserBroker(user)
+	if cs, err := con.FindApplications(r.Context(), name, user.Namespace); err == nil && scale < len(cs) {
+		err = br.CheckProtection(name, r.FormValue("confirm"))
+		if con.badRequest(w, r, err, "/applications/"+name) {
+			return
+		}
+	}
 	cs, err := br.ScaleApplication(name, scale)
 	if con.badRequest(w, r, err, "/applications/"+name) {
 		return
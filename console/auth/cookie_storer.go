@@ -43,10 +43,11 @@ func (s cookieStorer) Put(key, value string) {
 	}
 
 	cookie := &http.Cookie{
-		Expires: time.Now().UTC().AddDate(1, 0, 0),
-		Name:    key,
-		Value:   encoded,
-		Path:    "/",
+		Expires:  time.Now().UTC().AddDate(1, 0, 0),
+		Name:     key,
+		Value:    encoded,
+		Path:     "/",
+		HttpOnly: true,
 	}
 	http.SetCookie(s.w, cookie)
 }
@@ -0,0 +1,22 @@
+package auth
+
+import (
+	"gopkg.in/authboss.v0"
+
+	"github.com/cloudway/platform/auth/userdb"
+)
+
+// PasswordValidator validates passwords of registration and password
+// recovery forms with the configured password policy.
+type PasswordValidator struct{}
+
+func (PasswordValidator) Field() string {
+	return "password"
+}
+
+func (v PasswordValidator) Errors(password string) authboss.ErrorList {
+	if err := userdb.CheckPassword(password); err != nil {
+		return authboss.ErrorList{authboss.FieldError{Name: v.Field(), Err: err}}
+	}
+	return nil
+}
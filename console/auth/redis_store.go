@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"encoding/base32"
+	"net/http"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+const redisSessionPrefix = "cloudway:session:"
+
+// redisStore keeps session values in Redis, only the session ID is stored
+// in the cookie. Sessions are shared by all console servers using the same
+// Redis server.
+type redisStore struct {
+	pool    *redis.Pool
+	codecs  []securecookie.Codec
+	options *sessions.Options
+}
+
+func newRedisStore(url string, keyPairs ...[]byte) *redisStore {
+	pool := &redis.Pool{
+		MaxIdle:     5,
+		IdleTimeout: 5 * time.Minute,
+		Dial:        func() (redis.Conn, error) { return redis.DialURL(url) },
+	}
+
+	s := &redisStore{
+		pool:    pool,
+		codecs:  securecookie.CodecsFromPairs(keyPairs...),
+		options: &sessions.Options{Path: "/", MaxAge: 86400 * 30},
+	}
+	for _, codec := range s.codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(s.options.MaxAge)
+		}
+	}
+	return s
+}
+
+func (s *redisStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+func (s *redisStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.options
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	if err = securecookie.DecodeMulti(name, c.Value, &session.ID, s.codecs...); err != nil {
+		return session, err
+	}
+	if found, err := s.load(session); err != nil || !found {
+		return session, err
+	}
+	session.IsNew = false
+	return session, nil
+}
+
+func (s *redisStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	if session.Options.MaxAge <= 0 {
+		if session.ID != "" {
+			if _, err := conn.Do("DEL", redisSessionPrefix+session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		session.ID = base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32))
+	}
+
+	data, err := securecookie.EncodeMulti(session.Name(), session.Values, s.codecs...)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Do("SETEX", redisSessionPrefix+session.ID, session.Options.MaxAge, data)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+func (s *redisStore) load(session *sessions.Session) (bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	data, err := redis.String(conn.Do("GET", redisSessionPrefix+session.ID))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	err = securecookie.DecodeMulti(session.Name(), data, &session.Values, s.codecs...)
+	return err == nil, err
+}
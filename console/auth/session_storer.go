@@ -2,21 +2,73 @@ package auth
 
 import (
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/gorilla/sessions"
 	"gopkg.in/authboss.v0"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/config/defaults"
 )
 
 const sessionCookieName = "cloudway"
 
-var sessionStore *sessions.CookieStore
+var (
+	sessionStoreKey []byte
+	sessionStore    sessions.Store
+)
 
 func init() {
-	sessionStoreKey, _ := base64.StdEncoding.DecodeString(`AbfYwmmt8UCwUuhd9qvfNA9UCuN1cVcKJN1ofbiky6xCyyBj20whe40rJa3Su0WOWLWcPpO1taqJdsEI/65+JA==`)
+	sessionStoreKey, _ = base64.StdEncoding.DecodeString(`AbfYwmmt8UCwUuhd9qvfNA9UCuN1cVcKJN1ofbiky6xCyyBj20whe40rJa3Su0WOWLWcPpO1taqJdsEI/65+JA==`)
 	sessionStore = sessions.NewCookieStore(sessionStoreKey)
 }
 
+// InitSessionStore creates the session store configured by the
+// "console.session.store" key. Session values are stored in the cookie by
+// default. The "file" store keeps session values in files under the root
+// directory, and the "redis" store keeps session values in the Redis server
+// configured by "redis.url", which can be shared by multiple consoles.
+func InitSessionStore() error {
+	switch typ := config.Get("console.session.store"); typ {
+	case "", "cookie":
+		store := sessions.NewCookieStore(sessionStoreKey)
+		setSessionOptions(store.Options)
+		sessionStore = store
+	case "file":
+		dir := filepath.Join(config.RootDir, "var", "sessions")
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+		store := sessions.NewFilesystemStore(dir, sessionStoreKey)
+		store.MaxLength(0)
+		setSessionOptions(store.Options)
+		sessionStore = store
+	case "redis":
+		url := config.Get("redis.url")
+		if url == "" {
+			return errors.New("redis.url must be configured for the redis session store")
+		}
+		store := newRedisStore(url, sessionStoreKey)
+		setSessionOptions(store.options)
+		sessionStore = store
+	default:
+		return fmt.Errorf("Unsupported session store: %s", typ)
+	}
+	return nil
+}
+
+// setSessionOptions protects session cookies from scripts. The SameSite
+// attribute is added by the console handler.
+func setSessionOptions(opts *sessions.Options) {
+	opts.HttpOnly = true
+	opts.Secure = strings.HasPrefix(defaults.ConsoleURL(), "https:")
+}
+
 type sessionStorer struct {
 	w http.ResponseWriter
 	r *http.Request
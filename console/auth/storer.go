@@ -71,7 +71,9 @@ func (s Storer) Create(key string, attr authboss.Attributes) error {
 
 	user.Name = key
 	user.Inactive = user.Authboss.ConfirmToken != ""
-	return s.CreateUser(&user, user.Authboss.Password)
+
+	invite, _ := attr.String("invite")
+	return s.RegisterUser(&user, user.Authboss.Password, invite)
 }
 
 func (s Storer) Put(key string, attr authboss.Attributes) error {
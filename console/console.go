@@ -17,13 +17,16 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/cloudway/platform/api/server/health"
 	"github.com/cloudway/platform/auth/userdb"
 	"github.com/cloudway/platform/broker"
 	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/config/defaults"
 	"github.com/cloudway/platform/console/auth"
+	"github.com/cloudway/platform/pkg/ratelimit"
 
 	"gopkg.in/authboss.v0"
 	_ "gopkg.in/authboss.v0/auth"
@@ -34,6 +37,7 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/aarondl/tpl"
+	"github.com/docker/go-units"
 	"github.com/gorilla/mux"
 	"github.com/justinas/nosurf"
 	"github.com/oxtoacart/bpool"
@@ -43,6 +47,9 @@ var funcs = template.FuncMap{
 	"formatDate": func(date time.Time) string {
 		return date.Format("2006/01/02 03:04pm")
 	},
+	"humanSize": func(size int64) string {
+		return units.HumanSize(float64(size))
+	},
 	"humanDuration": func(date time.Time) string {
 		return humanDuration(time.Now().UTC().Sub(date))
 	},
@@ -71,10 +78,12 @@ type Console struct {
 	ab        *authboss.Authboss
 	templates tpl.Templates
 	baseURL   *url.URL
+
+	loginLimiter *ratelimit.Limiter
 }
 
 func NewConsole(br *broker.Broker) (con *Console, err error) {
-	con = &Console{Broker: br}
+	con = &Console{Broker: br, loginLimiter: ratelimit.New(time.Minute)}
 
 	rawurl := config.GetOrDefault("console.url", "http://api."+defaults.Domain())
 	con.baseURL, err = url.Parse(rawurl)
@@ -87,13 +96,8 @@ func NewConsole(br *broker.Broker) (con *Console, err error) {
 		return nil, err
 	}
 
-	funcs["download"] = func(os string) template.URL {
-		dlurl := *con.baseURL
-		dlurl.Path = fmt.Sprintf("/dist/%s/amd64/cwcli", os)
-		if os == "windows" {
-			dlurl.Path += ".exe"
-		}
-		return template.URL(dlurl.String())
+	funcs["download"] = func(os, arch string) template.URL {
+		return template.URL(con.distURL(os, arch))
 	}
 
 	viewRoot := filepath.Join(config.RootDir, "views", "console")
@@ -110,6 +114,7 @@ func (con *Console) Accept(addr string, listener net.Listener) {
 func (con *Console) Serve() (err error) {
 	m := mux.NewRouter()
 	con.server.Handler = m
+	health.InitRoutes(m, con.Broker)
 	con.InitRoutes(m)
 
 	logrus.Infof("Console server listen on %s", con.listener.Addr())
@@ -125,16 +130,25 @@ func (con *Console) Close() {
 }
 
 func (con *Console) InitRoutes(m *mux.Router) {
-	m.PathPrefix("/auth/").Handler(con.ab.NewRouter())
+	r := mux.NewRouter()
+
+	// reject all changes while the platform is in read-only mode
+	r.Methods("POST").MatcherFunc(isReadOnly).HandlerFunc(con.readOnly)
 
-	dist := http.FileServer(http.Dir(filepath.Join(config.RootDir, "dist")))
-	m.PathPrefix("/dist/").Handler(http.StripPrefix("/dist/", dist))
+	authRouter := con.ab.NewRouter()
+	r.Path("/auth/login").Methods("POST").Handler(con.limitLogin(authRouter))
+	r.Path("/auth/register").Handler(con.checkRegistration(authRouter))
+	r.PathPrefix("/auth/").Handler(authRouter)
+
+	dist := http.FileServer(http.Dir(distDir()))
+	r.Path(distManifestPath).Methods("GET").HandlerFunc(con.getDistManifest)
+	r.PathPrefix("/dist/").Handler(http.StripPrefix("/dist/", dist))
 
 	static := http.FileServer(http.Dir(filepath.Join(config.RootDir, "static")))
-	m.PathPrefix("/static/").Handler(http.StripPrefix("/static/", static))
+	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", static))
 
-	gets := m.Methods("GET").Subrouter()
-	posts := m.Methods("POST").Subrouter()
+	gets := r.Methods("GET").Subrouter()
+	posts := r.Methods("POST").Subrouter()
 
 	gets.HandleFunc("/", con.index)
 	gets.HandleFunc("/password", con.password)
@@ -143,7 +157,30 @@ func (con *Console) InitRoutes(m *mux.Router) {
 	gets.HandleFunc("/images/plugin/{tag:.*}", con.getPluginLogo)
 
 	con.initSettingsRoutes(gets, posts)
+	con.initAccountRoutes(gets, posts)
 	con.initApplicationsRoutes(gets, posts)
+	con.initDataRoutes(gets, posts)
+	con.initDeploymentRoutes(gets, posts)
+
+	// all console routes are protected by the CSRF handler
+	m.PathPrefix("/").Handler(con.secure(r))
+}
+
+// isReadOnly matches requests that are rejected in read-only mode.
+// Signing in and out is always allowed, as in the API server, other
+// authentication forms such as registration change the user database.
+func isReadOnly(r *http.Request, rm *mux.RouteMatch) bool {
+	switch r.URL.Path {
+	case "/auth/login", "/auth/logout":
+		return false
+	}
+	return broker.CheckReadOnly() != nil
+}
+
+func (con *Console) readOnly(w http.ResponseWriter, r *http.Request) {
+	if err := broker.CheckReadOnly(); err != nil {
+		con.error(w, r, http.StatusServiceUnavailable, err.Error(), r.URL.Path)
+	}
 }
 
 // General Email Regex (RFC 5322 Official Standard)
@@ -171,6 +208,9 @@ func (con *Console) setupAuthboss(br *broker.Broker) error {
 		return nosurf.Token(r)
 	}
 
+	if err = auth.InitSessionStore(); err != nil {
+		return err
+	}
 	ab.CookieStoreMaker = auth.NewCookieStorer
 	ab.SessionStoreMaker = auth.NewSessionStorer
 
@@ -182,20 +222,19 @@ func (con *Console) setupAuthboss(br *broker.Broker) error {
 			MustMatch:       regexp.MustCompile(_EMAIL_RE),
 			MatchError:      "Please enter a valid email address",
 		},
-		authboss.Rules{
-			FieldName:       "password",
-			Required:        true,
-			MinLength:       4,
-			MaxLength:       20,
-			AllowWhitespace: false,
-		},
+		auth.PasswordValidator{},
 	}
 
 	modules := []string{"auth", "register"}
-	if ab.Mailer = initMailer(); ab.Mailer != nil {
+	if mailer := initMailer(); mailer != nil {
+		m := &configMailer{mailer: mailer}
+		config.OnReload(m.reload)
+		ab.Mailer = m
 		modules = append(modules, "confirm", "recover")
 	}
 
+	con.setupLockout(ab)
+
 	con.ab = ab
 	return ab.Init(modules...)
 }
@@ -223,6 +262,31 @@ func initMailer() authboss.Mailer {
 	return authboss.SMTPMailer(host+":"+port, auth)
 }
 
+// configMailer sends mails with SMTP settings from the current configuration.
+// Enabling mail for the first time still requires a restart, as modules that
+// depend on it are only initialized on startup.
+type configMailer struct {
+	mu     sync.RWMutex
+	mailer authboss.Mailer
+}
+
+func (m *configMailer) reload() {
+	if mailer := initMailer(); mailer != nil {
+		m.mu.Lock()
+		m.mailer = mailer
+		m.mu.Unlock()
+	}
+}
+
+func (m *configMailer) Send(email authboss.Email) error {
+	m.mu.RLock()
+	mailer := m.mailer
+	m.mu.RUnlock()
+
+	email.From = config.GetOrDefault("smtp.from", email.From)
+	return mailer.Send(email)
+}
+
 func (con *Console) currentUser(w http.ResponseWriter, r *http.Request) *userdb.BasicUser {
 	user, err := con.ab.CurrentUser(w, r)
 	if err != nil && err != authboss.ErrUserNotFound {
@@ -247,13 +311,18 @@ func (con *Console) layoutData(w http.ResponseWriter, r *http.Request) authboss.
 	if userInter != nil && err == nil {
 		user = userInter.(*auth.AuthbossUser).Basic()
 	}
-	return con.layoutUserData(w, r, user)
+	data := con.layoutUserData(w, r, user)
+	data.MergeKV("invite", r.FormValue("invite"))
+	return data
 }
 
 func (con *Console) layoutUserData(w http.ResponseWriter, r *http.Request, user *userdb.BasicUser) authboss.HTMLData {
 	return authboss.HTMLData{
-		"loggedin": user != nil,
-		"user":     user,
+		"loggedin":               user != nil,
+		"user":                   user,
+		"readonly":               broker.CheckReadOnly(),
+		"registration_open":      broker.RegistrationOpen(),
+		"notices":                con.Notices(),
 		authboss.FlashSuccessKey: con.ab.FlashSuccess(w, r),
 		authboss.FlashErrorKey:   con.ab.FlashError(w, r),
 	}
@@ -0,0 +1,254 @@
+package console
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+
+	"github.com/cloudway/platform/broker"
+)
+
+// maxUploadSize is the maximum size of a file that can be uploaded from
+// the data browser.
+const maxUploadSize = 10 * 1024 * 1024
+
+func (con *Console) initDataRoutes(gets *mux.Router, posts *mux.Router) {
+	gets.HandleFunc("/applications/{name}/data", con.browseData)
+	gets.HandleFunc("/applications/{name}/data/download", con.downloadData)
+	posts.HandleFunc("/applications/{name}/data/upload", con.uploadData)
+}
+
+type dataPath struct {
+	Name string
+	Path string
+}
+
+type dataService struct {
+	Name  string
+	Label string
+	Size  int64
+}
+
+// dataOptions returns the service name, and the path relative to the
+// data directory that cannot escape from it.
+func dataOptions(r *http.Request) (service, relpath string) {
+	service = r.FormValue("service")
+	if service == "" {
+		service = "_"
+	}
+	relpath = strings.TrimPrefix(path.Clean("/"+r.FormValue("path")), "/")
+	return
+}
+
+func dataDir(relpath string) string {
+	return path.Join("data", relpath)
+}
+
+func (con *Console) browseData(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	if user.Applications[name] == nil {
+		con.error(w, r, http.StatusNotFound, "应用未找到", "/applications")
+		return
+	}
+
+	br := con.NewUserBroker(user)
+	service, relpath := dataOptions(r)
+
+	files, err := br.ListFiles(name, service, dataDir(relpath))
+	if con.badRequest(w, r, err, "/applications/"+name) {
+		return
+	}
+
+	var services []dataService
+	if usage, err := br.DiskUsage(name); err != nil {
+		logrus.WithError(err).Warn("Failed to get disk usage")
+	} else {
+		services = serviceUsage(usage)
+	}
+
+	data := con.layoutUserData(w, r, user)
+	data.MergeKV("app", &appData{
+		Name:        name,
+		URL:         con.appURL(name, user.Namespace, user.Domain),
+		Maintenance: user.Applications[name].Maintenance,
+		DeployLock:  user.Applications[name].DeployLock,
+	})
+	data.MergeKV("service", service)
+	data.MergeKV("path", relpath)
+	data.MergeKV("breadcrumbs", breadcrumbs(relpath))
+	data.MergeKV("files", files)
+	data.MergeKV("services", services)
+	data.MergeKV("max_upload_size", int64(maxUploadSize))
+	con.mustRender(w, r, "app_data", data)
+}
+
+// serviceUsage sums up data usage of containers by service, so replicas
+// of the application or service are shown in one row.
+func serviceUsage(usage []*broker.DiskUsage) []dataService {
+	services := make([]dataService, 0, len(usage))
+	index := make(map[string]int)
+	for _, u := range usage {
+		if i, ok := index[u.Service]; ok {
+			services[i].Size += u.Data
+			continue
+		}
+		s := dataService{Name: u.Service, Label: u.Service, Size: u.Data}
+		if s.Name == "" {
+			s.Name, s.Label = "_", "应用"
+		}
+		index[u.Service] = len(services)
+		services = append(services, s)
+	}
+	return services
+}
+
+func breadcrumbs(relpath string) []dataPath {
+	var paths []dataPath
+	if relpath == "" {
+		return paths
+	}
+	for i, name := range strings.Split(relpath, "/") {
+		p := name
+		if i > 0 {
+			p = paths[i-1].Path + "/" + name
+		}
+		paths = append(paths, dataPath{Name: name, Path: p})
+	}
+	return paths
+}
+
+func (con *Console) downloadData(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	service, relpath := dataOptions(r)
+	if relpath == "" {
+		relpath = "."
+	}
+
+	content, err := con.NewUserBroker(user).DownloadFiles(name, service, dataDir(relpath), nil)
+	if con.badRequest(w, r, err, "/applications/"+name+"/data") {
+		return
+	}
+	defer content.Close()
+
+	tr := tar.NewReader(content)
+	hdr, err := tr.Next()
+	if con.badRequest(w, r, err, "/applications/"+name+"/data") {
+		return
+	}
+
+	filename := path.Base(relpath)
+	if filename == "." {
+		filename = "data"
+	}
+
+	if hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeRegA {
+		// send regular file as is
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		w.Header().Set("Content-Length", fmt.Sprint(hdr.Size))
+		io.Copy(w, tr)
+		return
+	}
+
+	// send directory as a compressed archive
+	w.Header().Set("Content-Type", "application/tar+gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".tar.gz"))
+
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	for err == nil {
+		if err = tw.WriteHeader(hdr); err == nil {
+			if _, err = io.Copy(tw, tr); err == nil {
+				hdr, err = tr.Next()
+			}
+		}
+	}
+	if err == io.EOF {
+		tw.Close()
+		zw.Close()
+	} else {
+		logrus.WithError(err).Error("Failed to download data")
+	}
+}
+
+func (con *Console) uploadData(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	service, relpath := dataOptions(r)
+	query := url.Values{"service": {service}, "path": {relpath}}
+	returnPath := "/applications/" + name + "/data?" + query.Encode()
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize+1024*1024)
+	file, header, err := r.FormFile("file")
+	if con.badRequest(w, r, err, returnPath) {
+		return
+	}
+	defer file.Close()
+
+	if !verifyCSRF(r) {
+		con.csrfFailure(w, r)
+		return
+	}
+
+	size, err := file.Seek(0, os.SEEK_END)
+	if err == nil {
+		_, err = file.Seek(0, os.SEEK_SET)
+	}
+	if con.badRequest(w, r, err, returnPath) {
+		return
+	}
+	if size > maxUploadSize {
+		con.badRequest(w, r, errors.New("文件太大"), returnPath)
+		return
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		hdr := &tar.Header{
+			Name: path.Base(header.Filename),
+			Mode: 0644,
+			Size: size,
+		}
+		err := tw.WriteHeader(hdr)
+		if err == nil {
+			_, err = io.Copy(tw, file)
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	err = con.NewUserBroker(user).UploadFiles(name, service, dataDir(relpath), pr, nil)
+	pr.Close()
+	if con.badRequest(w, r, err, returnPath) {
+		return
+	}
+
+	http.Redirect(w, r, returnPath, http.StatusFound)
+}
@@ -0,0 +1,55 @@
+package console
+
+import (
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/broker"
+)
+
+var _ = Describe("Data", func() {
+	It("should sum up disk usage of replicas by service", func() {
+		services := serviceUsage([]*broker.DiskUsage{
+			{ID: "c1", Service: "", Data: 100},
+			{ID: "c2", Service: "", Data: 200},
+			{ID: "c3", Service: "mysql", Data: 1000},
+			{ID: "c4", Service: "redis", Data: 10},
+			{ID: "c5", Service: "redis", Data: 20},
+		})
+		Expect(services).To(Equal([]dataService{
+			{Name: "_", Label: "应用", Size: 300},
+			{Name: "mysql", Label: "mysql", Size: 1000},
+			{Name: "redis", Label: "redis", Size: 30},
+		}))
+	})
+
+	It("should return no services without disk usage", func() {
+		Expect(serviceUsage(nil)).To(BeEmpty())
+	})
+
+	It("should build breadcrumbs of the path", func() {
+		Expect(breadcrumbs("")).To(BeEmpty())
+		Expect(breadcrumbs("a/b/c")).To(Equal([]dataPath{
+			{Name: "a", Path: "a"},
+			{Name: "b", Path: "a/b"},
+			{Name: "c", Path: "a/b/c"},
+		}))
+	})
+
+	It("should not escape from the data directory", func() {
+		r, err := http.NewRequest("GET", "/applications/test/data?path=../../etc", nil)
+		Expect(err).NotTo(HaveOccurred())
+		service, relpath := dataOptions(r)
+		Expect(service).To(Equal("_"))
+		Expect(relpath).To(Equal("etc"))
+		Expect(dataDir(relpath)).To(Equal("data/etc"))
+
+		r, err = http.NewRequest("GET", "/applications/test/data?service=mysql&path=/a/./b/", nil)
+		Expect(err).NotTo(HaveOccurred())
+		service, relpath = dataOptions(r)
+		Expect(service).To(Equal("mysql"))
+		Expect(relpath).To(Equal("a/b"))
+	})
+})
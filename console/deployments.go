@@ -0,0 +1,127 @@
+package console
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+func (con *Console) initDeploymentRoutes(gets *mux.Router, posts *mux.Router) {
+	gets.HandleFunc("/applications/{name}/deployments", con.deploymentHistory)
+	gets.HandleFunc("/applications/{name}/deployments/{id}/log", con.deploymentLog)
+	posts.HandleFunc("/applications/{name}/deployments/{id}/rollback", con.rollback)
+}
+
+type deploymentData struct {
+	*broker.Deployment
+	Elapsed     string
+	Current     bool
+	CanRollback bool
+}
+
+// elapsed formats the duration of a deployment in a short form.
+func elapsed(d time.Duration) string {
+	seconds := int((d + time.Second/2) / time.Second)
+	if seconds < 60 {
+		return fmt.Sprintf("%d秒", seconds)
+	}
+	if seconds < 3600 {
+		return fmt.Sprintf("%d分%d秒", seconds/60, seconds%60)
+	}
+	return fmt.Sprintf("%d小时%d分", seconds/3600, seconds/60%60)
+}
+
+func (con *Console) deploymentHistory(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	app := user.Applications[name]
+	if app == nil {
+		con.error(w, r, http.StatusNotFound, "应用未找到", "/applications")
+		return
+	}
+
+	br := con.NewUserBroker(user)
+	history, err := br.GetDeployments(name)
+	if con.badRequest(w, r, err, "/applications/"+name) {
+		return
+	}
+
+	// deployments can only be rolled back to if the artifacts are retained
+	retained := make(map[string]bool)
+	if artifacts, err := br.GetArtifacts(name); err == nil {
+		for _, a := range artifacts {
+			retained[a.ID] = true
+		}
+	}
+
+	deployments := make([]*deploymentData, len(history))
+	current := false
+	for i, d := range history {
+		dd := &deploymentData{Deployment: d}
+		if d.State != broker.DeploymentRunning {
+			dd.Elapsed = elapsed(d.Duration())
+		}
+		if d.State == broker.DeploymentSuccess {
+			// the latest successful deployment is currently running
+			dd.Current = !current
+			dd.CanRollback = current && retained[d.Artifact]
+			current = true
+		}
+		deployments[i] = dd
+	}
+
+	data := con.layoutUserData(w, r, user)
+	data.MergeKV("app", &appData{
+		Name:        name,
+		URL:         con.appURL(name, user.Namespace, user.Domain),
+		Maintenance: app.Maintenance,
+		DeployLock:  app.DeployLock,
+	})
+	data.MergeKV("deployments", deployments)
+	con.mustRender(w, r, "app_deployments", data)
+}
+
+func (con *Console) deploymentLog(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	log, err := con.NewUserBroker(user).GetDeploymentLog(vars["name"], vars["id"])
+	if con.badRequest(w, r, err, "/applications/"+vars["name"]+"/deployments") {
+		return
+	}
+	defer log.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.Copy(w, log)
+}
+
+// rollback deploys the build of a previous deployment again. The output is
+// saved in the deployment history.
+func (con *Console) rollback(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	path := "/applications/" + vars["name"] + "/deployments"
+	_, err := con.NewUserBroker(user).Rollback(vars["name"], vars["id"], serverlog.Discard)
+	if con.badRequest(w, r, err, path) {
+		return
+	}
+
+	http.Redirect(w, r, path, http.StatusFound)
+}
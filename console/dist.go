@@ -0,0 +1,165 @@
+package console
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudway/platform/api"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/config"
+)
+
+// The dist directory contains cwcli binaries for download, laid out as
+// "{os}/{arch}/cwcli" with the ".exe" suffix for Windows. The manifest of
+// all binaries and their SHA-256 checksums is served at
+// "/dist/manifest.json", for the command line tool to update itself.
+// Checksums are read from the ".sha256" files generated at build time,
+// or computed and cached until the binary is modified.
+
+const distManifestPath = "/dist/manifest.json"
+
+type cachedChecksum struct {
+	modTime time.Time
+	size    int64
+	sum     string
+}
+
+var distChecksums = struct {
+	sync.Mutex
+	m map[string]cachedChecksum
+}{m: make(map[string]cachedChecksum)}
+
+func distDir() string {
+	return filepath.Join(config.RootDir, "dist")
+}
+
+func distBinary(goos string) string {
+	if goos == "windows" {
+		return "cwcli.exe"
+	}
+	return "cwcli"
+}
+
+// distURL returns the download URL of the cwcli binary for the platform.
+func (con *Console) distURL(goos, arch string) string {
+	dlurl := *con.baseURL
+	dlurl.Path = fmt.Sprintf("/dist/%s/%s/%s", goos, arch, distBinary(goos))
+	return dlurl.String()
+}
+
+func (con *Console) getDistManifest(w http.ResponseWriter, r *http.Request) {
+	binaries, err := con.distBinaries()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(&types.DistManifest{
+		Version:  api.Version,
+		Binaries: binaries,
+	})
+}
+
+// distBinaries lists cwcli binaries in the dist directory sorted by
+// platform.
+func (con *Console) distBinaries() ([]types.DistBinary, error) {
+	root := distDir()
+	systems, err := ioutil.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+
+	binaries := []types.DistBinary{}
+	for _, sys := range systems {
+		if !sys.IsDir() {
+			continue
+		}
+		goos := sys.Name()
+		arches, err := ioutil.ReadDir(filepath.Join(root, goos))
+		if err != nil {
+			return nil, err
+		}
+		for _, arch := range arches {
+			filename := filepath.Join(root, goos, arch.Name(), distBinary(goos))
+			fi, err := os.Stat(filename)
+			if err != nil || !fi.Mode().IsRegular() {
+				continue
+			}
+			sum, err := distChecksum(filename, fi)
+			if err != nil {
+				return nil, err
+			}
+			binaries = append(binaries, types.DistBinary{
+				OS:     goos,
+				Arch:   arch.Name(),
+				URL:    con.distURL(goos, arch.Name()),
+				Size:   fi.Size(),
+				SHA256: sum,
+			})
+		}
+	}
+
+	sort.Sort(binaryList(binaries))
+	return binaries, nil
+}
+
+type binaryList []types.DistBinary
+
+func (a binaryList) Len() int      { return len(a) }
+func (a binaryList) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a binaryList) Less(i, j int) bool {
+	if a[i].OS != a[j].OS {
+		return a[i].OS < a[j].OS
+	}
+	return a[i].Arch < a[j].Arch
+}
+
+// distChecksum returns the SHA-256 checksum of a binary.
+func distChecksum(filename string, fi os.FileInfo) (string, error) {
+	distChecksums.Lock()
+	defer distChecksums.Unlock()
+
+	cached, ok := distChecksums.m[filename]
+	if ok && cached.modTime.Equal(fi.ModTime()) && cached.size == fi.Size() {
+		return cached.sum, nil
+	}
+
+	// the checksum file has the format of sha256sum output
+	var sum string
+	if data, err := ioutil.ReadFile(filename + ".sha256"); err == nil {
+		if fields := strings.Fields(string(data)); len(fields) != 0 {
+			sum = strings.ToLower(fields[0])
+		}
+	}
+	if sum == "" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err = io.Copy(h, f); err != nil {
+			return "", err
+		}
+		sum = hex.EncodeToString(h.Sum(nil))
+	}
+
+	distChecksums.m[filename] = cachedChecksum{fi.ModTime(), fi.Size(), sum}
+	return sum, nil
+}
@@ -0,0 +1,165 @@
+package console
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// Applications in the application list can be grouped by values of a
+// label. Operations on a group are run on selected applications one after
+// another, and the output of all operations is combined into a single
+// websocket stream.
+
+type appGroup struct {
+	Label string // the label value shared by applications in the group
+	Apps  []*appListData
+}
+
+// groupApplications groups applications by values of the label. Groups
+// are sorted by label values, and applications without the label are put
+// into the last group with an empty label value.
+func groupApplications(apps []*appListData, key string) []*appGroup {
+	var (
+		groups    []*appGroup
+		byValue   = make(map[string]*appGroup)
+		unlabeled *appGroup
+	)
+	for _, app := range apps {
+		value, ok := app.Labels[key]
+		if !ok || value == "" {
+			if unlabeled == nil {
+				unlabeled = &appGroup{}
+			}
+			unlabeled.Apps = append(unlabeled.Apps, app)
+			continue
+		}
+		g := byValue[value]
+		if g == nil {
+			g = &appGroup{Label: value}
+			byValue[value] = g
+			groups = append(groups, g)
+		}
+		g.Apps = append(g.Apps, app)
+	}
+
+	sort.Sort(groupsByLabel(groups))
+	if unlabeled != nil {
+		groups = append(groups, unlabeled)
+	}
+	return groups
+}
+
+type groupsByLabel []*appGroup
+
+func (a groupsByLabel) Len() int           { return len(a) }
+func (a groupsByLabel) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a groupsByLabel) Less(i, j int) bool { return a[i].Label < a[j].Label }
+
+// labelKeys returns sorted keys of all labels of applications.
+func labelKeys(apps map[string]*userdb.Application) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, app := range apps {
+		for k := range app.Labels {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var groupActions = map[string]string{
+	"start":   "启动",
+	"stop":    "停止",
+	"restart": "重启",
+	"deploy":  "部署",
+}
+
+func (con *Console) groupOperation(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	h := func(conn *websocket.Conn) {
+		enc := json.NewEncoder(conn)
+		names, action, err := parseGroupOperation(r, user)
+		if err != nil {
+			enc.Encode(map[string]string{"err": err.Error()})
+			return
+		}
+
+		jw := jsonWriter{enc: enc}
+		log := serverlog.Encap(jw, jw)
+		br := con.NewUserBroker(user)
+
+		var failed []string
+		for i, name := range names {
+			fmt.Fprintf(jw, "\x1b[1m==> [%d/%d] %s %s\x1b[0m\n", i+1, len(names), groupActions[action], name)
+
+			switch action {
+			case "start":
+				err = br.StartApplication(name, log)
+			case "stop":
+				err = br.StopApplication(name)
+			case "restart":
+				err = br.RestartApplication(name, log)
+			case "deploy":
+				err = con.Deploy(broker.WithActor(r.Context(), user.Name), name, user.Namespace, "", log)
+			}
+
+			if err != nil {
+				failed = append(failed, name)
+				fmt.Fprintf(jw, "\x1b[31;1m%s\x1b[0m\n", err)
+			}
+		}
+
+		if len(failed) != 0 {
+			err = fmt.Errorf("%d/%d 个应用%s失败: %v", len(failed), len(names), groupActions[action], failed)
+			enc.Encode(map[string]string{"err": err.Error()})
+		}
+	}
+
+	srv := websocket.Server{Handler: h}
+	srv.ServeHTTP(w, r)
+}
+
+func parseGroupOperation(r *http.Request, user *userdb.BasicUser) (names []string, action string, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+
+	action = r.Form.Get("action")
+	if _, ok := groupActions[action]; !ok {
+		err = fmt.Errorf("无效的操作: %s", action)
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range r.Form["app"] {
+		if user.Applications[name] == nil {
+			err = fmt.Errorf("应用 %s 不存在", name)
+			return
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		err = errors.New("没有选择应用")
+	}
+	return
+}
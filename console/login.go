@@ -0,0 +1,83 @@
+package console
+
+import (
+	"net/http"
+
+	"github.com/Sirupsen/logrus"
+	"gopkg.in/authboss.v0"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/pkg/ratelimit"
+)
+
+// setupLockout records failed console logins in the user database, and
+// rejects logins to accounts that are locked due to too many failures.
+// The password is verified by authboss before the callbacks are called.
+func (con *Console) setupLockout(ab *authboss.Authboss) {
+	ab.Callbacks.Before(authboss.EventAuth, func(ctx *authboss.Context) (authboss.Interrupt, error) {
+		user, err := con.loginUser(ctx)
+		if err != nil || user == nil {
+			return authboss.InterruptNone, err
+		}
+		if user.CheckLocked() != nil {
+			return authboss.InterruptAccountLocked, nil
+		}
+		return authboss.InterruptNone, nil
+	})
+
+	ab.Callbacks.After(authboss.EventAuth, func(ctx *authboss.Context) error {
+		user, err := con.loginUser(ctx)
+		if err != nil || user == nil {
+			return err
+		}
+		return con.Users.ResetLoginFailures(user)
+	})
+
+	ab.Callbacks.After(authboss.EventAuthFail, func(ctx *authboss.Context) error {
+		user, err := con.loginUser(ctx)
+		if err != nil || user == nil {
+			return err
+		}
+		// failures on a locked account don't extend the lockout
+		if user.CheckLocked() != nil {
+			return nil
+		}
+		return con.Users.RecordLoginFailure(user.Name)
+	})
+}
+
+// loginUser returns the user record of the login attempt, or nil if the
+// user does not exist.
+func (con *Console) loginUser(ctx *authboss.Context) (*userdb.BasicUser, error) {
+	if ctx.User == nil {
+		return nil, nil
+	}
+	name, ok := ctx.User.String(authboss.StoreEmail)
+	if !ok {
+		return nil, nil
+	}
+
+	var user userdb.BasicUser
+	if err := con.Users.Find(name, &user); err != nil {
+		if userdb.IsUserNotFound(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// limitLogin limits the rate of login attempts from a client address.
+func (con *Console) limitLogin(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := ratelimit.ClientIP(r, defaults.TrustedProxies())
+		if !con.loginLimiter.Allow(ip, defaults.LoginRateLimit()) {
+			logrus.WithField("client", ip).Warn("Too many login attempts")
+			w.Header().Set("Retry-After", "60")
+			con.error(w, r, http.StatusTooManyRequests, "登录尝试次数过多，请稍后重试", "/auth/login")
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,58 @@
+package console
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
+)
+
+var _ = Describe("ReadOnly", func() {
+	var rootDir, tempDir string
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "readonly")
+		Expect(err).NotTo(HaveOccurred())
+		rootDir, config.RootDir = config.RootDir, tempDir
+		Expect(broker.SetReadOnly("backup in progress")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(broker.ClearReadOnly()).To(Succeed())
+		config.RootDir = rootDir
+		os.RemoveAll(tempDir)
+	})
+
+	var readOnly = func(path string) bool {
+		r, err := http.NewRequest("POST", path, nil)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		return isReadOnly(r, nil)
+	}
+
+	It("should reject changes", func() {
+		Expect(readOnly("/applications/test/start")).To(BeTrue())
+		Expect(readOnly("/settings/keys")).To(BeTrue())
+	})
+
+	It("should allow authentication", func() {
+		Expect(readOnly("/auth/login")).To(BeFalse())
+		Expect(readOnly("/auth/logout")).To(BeFalse())
+	})
+
+	It("should reject registration and password recovery", func() {
+		Expect(readOnly("/auth/register")).To(BeTrue())
+		Expect(readOnly("/auth/recover")).To(BeTrue())
+		Expect(readOnly("/auth/recover/complete")).To(BeTrue())
+	})
+
+	It("should allow changes after read-only mode turned off", func() {
+		Expect(broker.ClearReadOnly()).To(Succeed())
+		Expect(readOnly("/applications/test/start")).To(BeFalse())
+	})
+})
@@ -0,0 +1,36 @@
+package console
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/broker"
+)
+
+// checkRegistration rejects registration without a valid invitation if
+// registration is closed. The invitation is used by the storer when the
+// user is created.
+func (con *Console) checkRegistration(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var email string
+		if r.Method == "POST" {
+			email = strings.TrimSpace(r.FormValue("email"))
+		}
+
+		_, err := con.CheckInvite(r.FormValue("invite"), email)
+		switch err.(type) {
+		case nil:
+			handler.ServeHTTP(w, r)
+		case broker.RegistrationClosedError:
+			con.error(w, r, http.StatusForbidden, "仅限受邀用户注册，请联系管理员获取邀请", "/")
+		case userdb.InvalidInviteError:
+			con.error(w, r, http.StatusForbidden, "邀请链接无效、已过期或与注册邮箱不符", "/")
+		default:
+			logrus.Error(err)
+			con.error(w, r, http.StatusInternalServerError, err.Error(), "/")
+		}
+	})
+}
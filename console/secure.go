@@ -0,0 +1,74 @@
+package console
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/justinas/nosurf"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/pkg/secure"
+)
+
+const defaultCSP = "default-src 'self'; " +
+	"script-src 'self' 'unsafe-inline' cdn.bootcss.com; " +
+	"style-src 'self' 'unsafe-inline' cdn.bootcss.com; " +
+	"font-src 'self' cdn.bootcss.com; " +
+	"img-src 'self' data: https:"
+
+// The data upload is exempted from the CSRF handler, so the request body
+// size is limited before the multipart form is parsed. The CSRF token is
+// verified by the upload handler.
+var csrfExemptPattern = regexp.MustCompile(`^/applications/[^/]+/data/upload$`)
+
+// secure protects console routes against CSRF and sets security headers.
+func (con *Console) secure(handler http.Handler) http.Handler {
+	csrf := nosurf.New(handler)
+	csrf.SetBaseCookie(http.Cookie{
+		Path:     "/",
+		MaxAge:   nosurf.MaxAge,
+		HttpOnly: true,
+		Secure:   con.baseURL.Scheme == "https",
+	})
+	csrf.ExemptRegexp(csrfExemptPattern)
+	csrf.SetFailureHandler(http.HandlerFunc(con.csrfFailure))
+
+	csp := config.GetOrDefault("security.console_csp", defaultCSP)
+	opts := secure.Options{
+		HSTSMaxAge:            defaults.HSTSMaxAge(),
+		FrameOptions:          defaults.FrameOptions(),
+		ContentSecurityPolicy: csp,
+		NoSniff:               true,
+	}
+	policy := secure.NewPolicy(opts)
+
+	// the web terminal connects to the shell session with WebSocket
+	shell := opts
+	shell.ContentSecurityPolicy = csp + "; connect-src 'self' ws: wss:"
+	policy.Override("/shell/", shell)
+
+	return policy.Handler(secure.SameSiteLax(httpsScheme(csrf)))
+}
+
+// httpsScheme sets the URL scheme of requests received over HTTPS, directly
+// or through a reverse proxy, so the CSRF handler checks the referer of
+// secure requests.
+func httpsScheme(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if secure.IsTLS(r) {
+			r.URL.Scheme = "https"
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func (con *Console) csrfFailure(w http.ResponseWriter, r *http.Request) {
+	con.error(w, r, http.StatusForbidden, "请求已过期，请刷新页面后重试", r.Referer())
+}
+
+// verifyCSRF verifies the CSRF token of requests exempted from the CSRF
+// handler, the request form must be parsed.
+func verifyCSRF(r *http.Request) bool {
+	return nosurf.VerifyToken(nosurf.Token(r), r.FormValue("csrf_token"))
+}
@@ -7,33 +7,36 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
+
+	"github.com/cloudway/platform/auth/userdb"
 )
 
 func (con *Console) initSettingsRoutes(gets *mux.Router, posts *mux.Router) {
 	gets.HandleFunc("/settings", con.settings)
 	posts.HandleFunc("/settings/namespace", con.createNamespace)
 	posts.HandleFunc("/settings/namespace/delete", con.removeNamespace)
+	gets.HandleFunc("/settings/keys", con.sshkeys)
 	gets.HandleFunc("/settings/sshkey", con.addkey)
 	posts.HandleFunc("/settings/sshkey", con.savekey)
 	posts.HandleFunc("/settings/sshkey/delete", con.delkey)
+	posts.HandleFunc("/settings/preferences", con.savePreferences)
+	con.initTokenRoutes(gets, posts)
 }
 
 func (con *Console) settings(w http.ResponseWriter, r *http.Request) {
 	user := con.currentUser(w, r)
-	if user == nil {
-		return
+	if user != nil {
+		con.renderSettings(w, r, user)
 	}
+}
 
+// renderSettings renders the settings page with additional data in key
+// value pairs.
+func (con *Console) renderSettings(w http.ResponseWriter, r *http.Request, user *userdb.BasicUser, kvs ...interface{}) {
 	data := con.layoutUserData(w, r, user)
-	if user.Namespace != "" {
-		keys, err := con.SCM.ListKeys(user.Namespace)
-		if err != nil {
-			logrus.Error(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		data.MergeKV("sshkeys", keys)
-	}
+	data.MergeKV("settings_tab", "general")
+	data.MergeKV("labelKeys", labelKeys(user.Applications))
+	data.MergeKV(kvs...)
 	con.mustRender(w, r, "settings", data)
 }
 
@@ -56,9 +59,7 @@ func (con *Console) createNamespace(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != nil {
-		data := con.layoutUserData(w, r, user)
-		data.MergeKV("error", err)
-		con.mustRender(w, r, "settings", data)
+		con.renderSettings(w, r, user, "error", err)
 		return
 	}
 
@@ -79,6 +80,52 @@ func (con *Console) removeNamespace(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/settings", http.StatusFound)
 }
 
+// savePreferences saves user interface preferences, which are also used
+// by cwcli.
+func (con *Console) savePreferences(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	err := r.ParseForm()
+	if err == nil {
+		prefs := userdb.Preferences{
+			Theme:         r.PostForm.Get("theme"),
+			Locale:        r.PostForm.Get("locale"),
+			NamespaceView: r.PostForm.Get("namespace_view"),
+			TableDensity:  r.PostForm.Get("table_density"),
+		}
+		err = con.NewUserBroker(user).SetPreferences(&prefs)
+	}
+
+	if err != nil {
+		con.renderSettings(w, r, user, "prefs_error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/settings", http.StatusFound)
+}
+
+// sshkeys renders the page of public SSH keys.
+func (con *Console) sshkeys(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	keys, err := con.NewUserBroker(user).ListSSHKeys()
+	if err != nil {
+		logrus.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	data := con.layoutUserData(w, r, user)
+	data.MergeKV("settings_tab", "keys")
+	data.MergeKV("sshkeys", keys)
+	con.mustRender(w, r, "settings_keys", data)
+}
+
 func (con *Console) addkey(w http.ResponseWriter, r *http.Request) {
 	user := con.currentUser(w, r)
 	if user != nil {
@@ -95,8 +142,9 @@ func (con *Console) savekey(w http.ResponseWriter, r *http.Request) {
 
 	err := r.ParseForm()
 	if err == nil {
+		label := r.PostForm.Get("label")
 		content := r.PostForm.Get("content")
-		err = con.SCM.AddKey(user.Namespace, content)
+		_, err = con.NewUserBroker(user).AddSSHKey(label, content)
 	}
 
 	if err != nil {
@@ -106,7 +154,7 @@ func (con *Console) savekey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.Redirect(w, r, "/settings", http.StatusFound)
+	http.Redirect(w, r, "/settings/keys", http.StatusFound)
 }
 
 func (con *Console) delkey(w http.ResponseWriter, r *http.Request) {
@@ -115,11 +163,11 @@ func (con *Console) delkey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	key := r.FormValue("key")
-	err := con.SCM.RemoveKey(user.Namespace, key)
-	if con.badRequest(w, r, err, "/settings") {
+	fingerprint := r.FormValue("fingerprint")
+	err := con.NewUserBroker(user).RemoveSSHKey(fingerprint)
+	if con.badRequest(w, r, err, "/settings/keys") {
 		return
 	}
 
-	http.Redirect(w, r, "/settings", http.StatusFound)
+	http.Redirect(w, r, "/settings/keys", http.StatusFound)
 }
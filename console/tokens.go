@@ -0,0 +1,81 @@
+package console
+
+import (
+	"net/http"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+
+	"github.com/cloudway/platform/auth/userdb"
+)
+
+func (con *Console) initTokenRoutes(gets *mux.Router, posts *mux.Router) {
+	gets.HandleFunc("/settings/tokens", con.accessTokens)
+	posts.HandleFunc("/settings/tokens", con.createToken)
+	posts.HandleFunc("/settings/tokens/delete", con.revokeToken)
+}
+
+func (con *Console) accessTokens(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user != nil {
+		con.renderTokens(w, r, user)
+	}
+}
+
+// renderTokens renders the access tokens page with additional data in key
+// value pairs.
+func (con *Console) renderTokens(w http.ResponseWriter, r *http.Request, user *userdb.BasicUser, kvs ...interface{}) {
+	tokens, err := con.NewUserBroker(user).ListAccessTokens()
+	if err != nil {
+		logrus.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	data := con.layoutUserData(w, r, user)
+	data.MergeKV("settings_tab", "tokens")
+	data.MergeKV("tokens", tokens)
+	data.MergeKV(kvs...)
+	con.mustRender(w, r, "settings_tokens", data)
+}
+
+// createToken creates a personal access token, the token is displayed only
+// once in the response page.
+func (con *Console) createToken(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	var token string
+	err := r.ParseForm()
+	if err == nil {
+		label := r.PostForm.Get("label")
+		scopes := r.PostForm["scope"]
+		token, _, err = con.NewUserBroker(user).CreateAccessToken(label, scopes)
+	}
+
+	if err != nil {
+		con.renderTokens(w, r, user, "error", err)
+		return
+	}
+
+	// the token must not be cached by browsers or proxies
+	w.Header().Set("Cache-Control", "no-store")
+	con.renderTokens(w, r, user, "new_token", token)
+}
+
+func (con *Console) revokeToken(w http.ResponseWriter, r *http.Request) {
+	user := con.currentUser(w, r)
+	if user == nil {
+		return
+	}
+
+	id := r.FormValue("id")
+	err := con.NewUserBroker(user).RemoveAccessToken(id)
+	if con.badRequest(w, r, err, "/settings/tokens") {
+		return
+	}
+
+	http.Redirect(w, r, "/settings/tokens", http.StatusFound)
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/cloudway/platform/pkg/manifest"
 	"github.com/cloudway/platform/pkg/serverlog"
@@ -17,6 +19,18 @@ type Engine interface {
 	// ServerVersion returns the engine server version.
 	ServerVersion(ctx context.Context) (string, error)
 
+	// NodeInfo returns informations of the node that running the engine.
+	NodeInfo(ctx context.Context) (*NodeInfo, error)
+
+	// PullImage pulls the image from registry if it's not present on the
+	// node, pull progress is written to the output if not nil.
+	PullImage(ctx context.Context, image string, out io.Writer) error
+
+	// PublishImage commits the container with the given id into an image
+	// with the reference and pushes the image to the registry, push
+	// progress is written to the output if not nil.
+	PublishImage(ctx context.Context, id, ref string, out io.Writer) error
+
 	// Create create a new application container.
 	Create(ctx context.Context, opts CreateOptions) ([]Container, error)
 
@@ -40,8 +54,10 @@ type Engine interface {
 	// and service name.
 	FindService(ctx context.Context, name, namespace, service string) ([]Container, error)
 
-	// DistributeRepo distribute repository to containers.
-	DistributeRepo(ctx context.Context, containers []Container, repo io.Reader, zip bool) error
+	// DistributeRepo distribute repository to containers. Containers are
+	// deployed one after another, and the distribution is aborted if the
+	// deployment of any container failed.
+	DistributeRepo(ctx context.Context, containers []Container, repo io.Reader, zip bool, log *serverlog.ServerLog) error
 
 	// DeployRepo deploy repository to containers.
 	DeployRepo(ctx context.Context, name, namespace string, in io.Reader, log *serverlog.ServerLog) error
@@ -67,9 +83,25 @@ type Container interface {
 	// Destroy destroys the container.
 	Destroy(ctx context.Context) error
 
+	// Rename recreates the container with the new application name,
+	// namespace and the domain delegated to the namespace, files in the
+	// container are preserved. The original
+	// container is destroyed and the new container is not started.
+	Rename(ctx context.Context, name, namespace, domain string) (Container, error)
+
+	// SetLogging recreates the container with the log shipping
+	// configuration, or the default log driver if nil. Files in the
+	// container are preserved. The original container is destroyed and
+	// the new container is not started.
+	SetLogging(ctx context.Context, logging *LogConfig) (Container, error)
+
 	// Exec execute command in application container.
 	Exec(ctx context.Context, user string, stdin io.Reader, stdout, stderr io.Writer, cmd ...string) error
 
+	// ExecWithOptions execute command in application container with
+	// additional options such as execution timeout and output limit.
+	ExecWithOptions(ctx context.Context, opts ExecOptions, cmd ...string) error
+
 	// ExecE execute the command and accumulate error messages from
 	// standard error of the command.
 	ExecE(ctx context.Context, user string, in io.Reader, out io.Writer, cmd ...string) error
@@ -96,14 +128,26 @@ type Container interface {
 	// as defined by docker API.
 	Stats(ctx context.Context, stream bool) (io.ReadCloser, error)
 
+	// DiskUsage returns the size in bytes of files that have been created
+	// or changed in the container, including files in volumes if the
+	// container is running.
+	DiskUsage(ctx context.Context) (int64, error)
+
 	// CopyTo copy files into container.
 	CopyTo(ctx context.Context, path string, content io.Reader) error
 
 	// CopyFrom copy files from container.
 	CopyFrom(ctx context.Context, path string) (io.ReadCloser, error)
 
-	// Deploy the application.
-	Deploy(ctx context.Context, path string) error
+	// Patch copies files in the tar archive into the root filesystem of
+	// the container. A container with read-only root filesystem is
+	// recreated with the files and the new container is returned,
+	// otherwise the container itself is returned.
+	Patch(ctx context.Context, content io.Reader) (Container, error)
+
+	// Deploy the application and restart the running container. The
+	// output of deployment hooks is written to the server log.
+	Deploy(ctx context.Context, path string, log *serverlog.ServerLog) error
 
 	// GetInfo get application information from container.
 	GetInfo(ctx context.Context, options ...string) (*manifest.SandboxInfo, error)
@@ -127,6 +171,40 @@ type Container interface {
 
 	// GetHosts returns all custom host in the container.
 	GetHosts(ctx context.Context) []string
+
+	// SetMaintenance puts the container into maintenance mode on behalf of
+	// the given user. An empty user turns off the maintenance mode.
+	SetMaintenance(ctx context.Context, user string) error
+
+	// Maintenance returns the user who put the container into maintenance
+	// mode, or an empty string if the container is not in maintenance.
+	Maintenance(ctx context.Context) string
+
+	// SetTrafficWeight sets the relative weight of the container when the
+	// proxy balances traffic between containers of the application. Zero
+	// resets the weight to default, and a negative weight drains the
+	// container from the proxy.
+	SetTrafficWeight(ctx context.Context, weight int) error
+
+	// TrafficWeight returns the relative traffic weight of the container,
+	// or zero if the default weight is used.
+	TrafficWeight(ctx context.Context) int
+
+	// SetAccessPolicy sets the access policy enforced by the proxy for
+	// the container. A nil policy removes access restrictions.
+	SetAccessPolicy(ctx context.Context, policy *manifest.AccessPolicy) error
+
+	// AccessPolicy returns the access policy of the container, or nil if
+	// not restricted.
+	AccessPolicy(ctx context.Context) *manifest.AccessPolicy
+
+	// SetHTTPSettings sets the HTTP settings applied by the proxy for the
+	// container. A nil settings resets to default.
+	SetHTTPSettings(ctx context.Context, settings *manifest.HTTPSettings) error
+
+	// HTTPSettings returns the HTTP settings of the container, or nil if
+	// default.
+	HTTPSettings(ctx context.Context) *manifest.HTTPSettings
 }
 
 // Info contains container informations.
@@ -151,6 +229,57 @@ type Info interface {
 	DataDir() string
 	LogDir() string
 	StartedAt() string
+	RestartCount() int
+	Resources() Resources
+}
+
+// Resources describes resource limits of a container, zero values mean
+// unlimited.
+type Resources struct {
+	// The memory limit in bytes.
+	Memory int64
+
+	// The number of CPUs the container can use.
+	CPUs float64
+}
+
+// NodeInfo contains informations of a container engine node.
+type NodeInfo struct {
+	Name              string
+	Version           string
+	OS                string
+	Arch              string
+	NCPU              int
+	MemTotal          int64
+	Containers        int
+	ContainersRunning int
+	ContainersStopped int
+	UsernsRemap       bool
+}
+
+// Log drivers to ship container logs to external collectors.
+const (
+	LogDriverSyslog  = "syslog"
+	LogDriverFluentd = "fluentd"
+	LogDriverLoki    = "loki"
+)
+
+// LogAddressOptions maps log drivers to the driver option of the collector
+// address. The Loki driver is a Docker plugin that must be installed on
+// all nodes.
+var LogAddressOptions = map[string]string{
+	LogDriverSyslog:  "syslog-address",
+	LogDriverFluentd: "fluentd-address",
+	LogDriverLoki:    "loki-url",
+}
+
+// LogConfig configures the log driver of containers. The address is the
+// endpoint of the log collector, and options are passed to the log driver
+// as is.
+type LogConfig struct {
+	Driver  string
+	Address string
+	Options map[string]string
 }
 
 // CreateOptions contains options when creating container.
@@ -169,8 +298,48 @@ type CreateOptions struct {
 	Scaling     int
 	Hosts       []string
 	Env         map[string]string
+	Labels      map[string]string
 	Repo        string
+	RepoUser    string
+	RepoPass    string
 	Log         *serverlog.ServerLog
+
+	// Credentials of private registries to pull base images from, in
+	// addition to registries configured for the platform.
+	Registries []RegistryAuth
+
+	// The log shipping configuration of the application, containers use
+	// the default log driver if nil.
+	Logging *LogConfig
+
+	// The domain delegated to the namespace, applications are served
+	// under the platform domain if empty.
+	Domain string
+}
+
+// DockerHub is the registry of images without a registry host.
+const DockerHub = "docker.io"
+
+// RegistryAuth holds credentials of a private image registry.
+type RegistryAuth struct {
+	Server   string
+	Username string
+	Password string
+}
+
+// RegistryHost returns the registry host of an image reference, such as
+// "registry.example.com:5000" for "registry.example.com:5000/app:1.0".
+// Images without a registry host are pulled from Docker Hub.
+func RegistryHost(image string) string {
+	i := strings.IndexRune(image, '/')
+	if i == -1 {
+		return DockerHub
+	}
+	host := image[:i]
+	if host != "localhost" && !strings.ContainsAny(host, ".:") {
+		return DockerHub
+	}
+	return strings.ToLower(host)
 }
 
 // ProcessList contains running process list in a container.
@@ -197,6 +366,29 @@ type RunCmd struct {
 	OnExit      func(cmd *RunCmd)
 }
 
+// ExecOptions holds options to execute a command in container.
+type ExecOptions struct {
+	// The user that will run the command
+	User string
+
+	// The standard streams attached to the command. If Stderr is nil,
+	// the standard error of the command is accumulated and reported as
+	// the message of StatusError.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Timeout is a hard limit on the execution time of the command.
+	// The command is aborted and a TimeoutError is returned if it
+	// doesn't complete in time. Zero means no limit.
+	Timeout time.Duration
+
+	// MaxOutput is the maximum number of bytes captured from the standard
+	// output and standard error of the command. The command is aborted and
+	// an OutputLimitError is returned if it writes more. Zero means no limit.
+	MaxOutput int64
+}
+
 // TtySize holds parameters to resize a tty. It can be used to
 // resize container ttys and exec process ttys too.
 type TtySize struct {
@@ -219,6 +411,30 @@ func (e StatusError) Error() string {
 	}
 }
 
+// TimeoutError reports a command that didn't complete in time.
+type TimeoutError struct {
+	Command []string
+	Timeout time.Duration
+}
+
+func (e TimeoutError) Error() string {
+	return fmt.Sprintf("exec command '%s' timed out after %v", strings.Join(e.Command, " "), e.Timeout)
+}
+
+func (e TimeoutError) HTTPErrorStatusCode() int {
+	return http.StatusGatewayTimeout
+}
+
+// OutputLimitError reports a command that produced too much output.
+type OutputLimitError struct {
+	Command []string
+	Limit   int64
+}
+
+func (e OutputLimitError) Error() string {
+	return fmt.Sprintf("exec command '%s' exceeded output limit of %d bytes", strings.Join(e.Command, " "), e.Limit)
+}
+
 var reNamePattern = regexp.MustCompile(`^((\*|[a-z][a-z_0-9]*)\.)?([a-z][a-z_0-9]*)-([a-z][a-z_0-9]*)$`)
 
 // SplitNames is a utility function that split a container specification
@@ -20,12 +20,14 @@ const (
 	APP_NAME_KEY        = "com.cloudway.app.name"
 	APP_NAMESPACE_KEY   = "com.cloudway.app.namespace"
 	APP_HOME_KEY        = "com.cloudway.app.home"
+	APP_DOMAIN_KEY      = "com.cloudway.app.domain"
 	VERSION_KEY         = "com.cloudway.container.version"
 	CATEGORY_KEY        = "com.cloudway.container.category"
 	PLUGIN_KEY          = "com.cloudway.container.plugin"
 	FLAGS_KEY           = "com.cloudway.container.flags"
 	SERVICE_NAME_KEY    = "com.cloudway.service.name"
 	SERVICE_DEPENDS_KEY = "com.cloudway.service.depends"
+	APP_LABEL_PREFIX    = "com.cloudway.app.label."
 )
 
 const (
@@ -45,7 +47,7 @@ type dockerContainer struct {
 
 func init() {
 	container.NewEngine = func() (container.Engine, error) {
-		cli, err := client.NewEnvClient()
+		cli, err := newClient()
 		return DockerEngine{cli}, err
 	}
 }
@@ -59,6 +61,25 @@ func (cli DockerEngine) ServerVersion(ctx context.Context) (string, error) {
 	}
 }
 
+func (cli DockerEngine) NodeInfo(ctx context.Context) (*container.NodeInfo, error) {
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &container.NodeInfo{
+		Name:              info.Name,
+		Version:           info.ServerVersion,
+		OS:                info.OperatingSystem,
+		Arch:              info.Architecture,
+		NCPU:              info.NCPU,
+		MemTotal:          info.MemTotal,
+		Containers:        info.Containers,
+		ContainersRunning: info.ContainersRunning,
+		ContainersStopped: info.ContainersStopped,
+		UsernsRemap:       usernsRemap(info.SecurityOptions),
+	}, nil
+}
+
 // Returns an application container object constructed from the
 // container id in the system.
 func (cli DockerEngine) Inspect(ctx context.Context, id string) (container.Container, error) {
@@ -215,9 +236,14 @@ func (c *dockerContainer) Hostname() string {
 	}
 }
 
-// Returns the fully qualified domain name of the container.
+// Returns the fully qualified domain name of the container, which is under
+// the domain delegated to the namespace if any.
 func (c *dockerContainer) FQDN() string {
-	return c.Hostname() + "." + defaults.Domain()
+	host := defaults.AppHost(c.Name(), c.Namespace(), c.Config.Labels[APP_DOMAIN_KEY])
+	if c.Category().IsService() {
+		return c.ServiceName() + "." + host
+	}
+	return host
 }
 
 // Returns the IP address of the container
@@ -270,3 +296,26 @@ func (c *dockerContainer) LogDir() string {
 func (c *dockerContainer) StartedAt() string {
 	return c.State.StartedAt
 }
+
+func (c *dockerContainer) RestartCount() int {
+	return c.ContainerJSON.RestartCount
+}
+
+// defaultCPUPeriod is the CFS period used by Docker if not specified.
+const defaultCPUPeriod = 100000
+
+func (c *dockerContainer) Resources() container.Resources {
+	var res container.Resources
+	if c.HostConfig == nil {
+		return res
+	}
+	res.Memory = c.HostConfig.Memory
+	if quota := c.HostConfig.CPUQuota; quota > 0 {
+		period := c.HostConfig.CPUPeriod
+		if period <= 0 {
+			period = defaultCPUPeriod
+		}
+		res.CPUs = float64(quota) / float64(period)
+	}
+	return res
+}
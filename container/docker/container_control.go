@@ -24,6 +24,15 @@ func (c *dockerContainer) Start(ctx context.Context, log *serverlog.ServerLog) e
 
 // Restart the application container.
 func (c *dockerContainer) Restart(ctx context.Context, log *serverlog.ServerLog) error {
+	// run the pre-restart hook in the running container, the container
+	// is not restarted if the hook failed
+	if info, err := c.ContainerInspect(ctx, c.ID()); err == nil && info.State.Running && !info.State.Paused {
+		err = c.Exec(ctx, "", nil, log.Stdout(), log.Stderr(), "/usr/bin/cwctl", "hook", "pre-restart")
+		if err != nil {
+			return err
+		}
+	}
+
 	err := c.ContainerRestart(ctx, c.ID(), &waitTimeout)
 	if err != nil {
 		return err
@@ -121,7 +121,7 @@ func (cli DockerEngine) CreateBuilder(ctx context.Context, opts container.Create
 	cfg := configure(&opts)
 
 	cfg.Hostname = cfg.Name + "-" + cfg.Namespace
-	cfg.FQDN = cfg.Hostname + "." + defaults.Domain()
+	cfg.FQDN = defaults.AppHost(cfg.Name, cfg.Namespace, cfg.Domain)
 	cfg.Env["CLOUDWAY_APP_DNS"] = cfg.FQDN
 
 	err = buildImage(cli, ctx, dockerfileTemplate, cfg)
@@ -138,7 +138,7 @@ func createApplicationContainer(cli DockerEngine, ctx context.Context, cfg *crea
 	}
 
 	cfg.Hostname = cfg.Name + "-" + cfg.Namespace
-	cfg.FQDN = cfg.Hostname + "." + defaults.Domain()
+	cfg.FQDN = defaults.AppHost(cfg.Name, cfg.Namespace, cfg.Domain)
 	cfg.Env["CLOUDWAY_APP_DNS"] = cfg.FQDN
 
 	if _, e := os.Stat(filepath.Join(cfg.Plugin.Path, "bin", "build")); os.IsNotExist(e) {
@@ -206,7 +206,7 @@ func createServiceContainer(cli DockerEngine, ctx context.Context, cfg *createCo
 
 	name, namespace, service := cfg.Name, cfg.Namespace, cfg.ServiceName
 	cfg.Hostname = service + "." + name + "-" + namespace
-	cfg.FQDN = cfg.Hostname + "." + defaults.Domain()
+	cfg.FQDN = service + "." + defaults.AppHost(name, namespace, cfg.Domain)
 	cfg.Env["CLOUDWAY_APP_DNS"] = cfg.FQDN
 	cfg.Env["CLOUDWAY_SERVICE_NAME"] = cfg.ServiceName
 
@@ -272,8 +272,14 @@ func buildImage(cli DockerEngine, ctx context.Context, t *template.Template, cfg
 		return
 	}
 
-	// build the image from context
-	options := types.ImageBuildOptions{Dockerfile: "Dockerfile", Remove: true, ForceRemove: true}
+	// build the image from context, the base image may be pulled from
+	// private registries
+	options := types.ImageBuildOptions{
+		Dockerfile:  "Dockerfile",
+		Remove:      true,
+		ForceRemove: true,
+		AuthConfigs: buildAuthConfigs(cfg.Registries),
+	}
 	response, err := cli.ImageBuild(ctx, tarFile, options)
 
 	// read image ID from build response
@@ -386,27 +392,31 @@ func createContainer(cli DockerEngine, ctx context.Context, cfg *createConfig) (
 		config.Labels[SERVICE_DEPENDS_KEY] = strings.Join(cfg.DependsOn, ",")
 	}
 
-	hostConfig := &docker.HostConfig{}
+	if cfg.Domain != "" {
+		config.Labels[APP_DOMAIN_KEY] = cfg.Domain
+	}
+
+	for k, v := range cfg.Labels {
+		config.Labels[APP_LABEL_PREFIX+k] = v
+	}
+
+	hostConfig := &docker.HostConfig{RestartPolicy: restartPolicy()}
 	netConfig := &network.NetworkingConfig{}
 
 	if cfg.Network != "" {
 		hostConfig.NetworkMode = docker.NetworkMode(cfg.Network)
 	}
+	if err := harden(cfg, config, hostConfig); err != nil {
+		return nil, err
+	}
 
 	var baseName = cfg.Name + "-" + cfg.Namespace + "-"
 	if cfg.ServiceName != "" {
 		baseName = cfg.ServiceName + "." + baseName
 	}
+	hostConfig.LogConfig = logConfig(cfg.Logging, strings.TrimSuffix(baseName, "-"))
 
-	var containerName string
-	for i := 1; ; i++ {
-		containerName = baseName + strconv.Itoa(i)
-		_, err := cli.ContainerInspect(ctx, containerName)
-		if err != nil {
-			break
-		}
-	}
-
+	containerName := newContainerName(cli, ctx, baseName)
 	resp, err := cli.ContainerCreate(ctx, config, hostConfig, netConfig, containerName)
 	if err != nil {
 		logrus.WithError(err).Error("failed to create container")
@@ -432,6 +442,17 @@ func createContainer(cli DockerEngine, ctx context.Context, cfg *createConfig) (
 	return c, nil
 }
 
+// newContainerName returns an unused container name with the base name
+// followed by a sequence number.
+func newContainerName(cli DockerEngine, ctx context.Context, baseName string) string {
+	for i := 1; ; i++ {
+		name := baseName + strconv.Itoa(i)
+		if _, err := cli.ContainerInspect(ctx, name); err != nil {
+			return name
+		}
+	}
+}
+
 func createBuilderContainer(cli DockerEngine, ctx context.Context, cfg *createConfig) (*dockerContainer, error) {
 	config := &docker.Config{
 		Image:      cfg.Image,
@@ -462,3 +483,21 @@ func createBuilderContainer(cli DockerEngine, ctx context.Context, cfg *createCo
 		ContainerJSON: &info,
 	}, nil
 }
+
+// restartPolicy returns the restart policy of application containers,
+// which is configured by "app.restart_policy" in the form of "name" or
+// "on-failure:max-retries". Containers are restarted unless explicitly
+// stopped by default.
+func restartPolicy() docker.RestartPolicy {
+	policy := config.GetOrDefault("app.restart_policy", "unless-stopped")
+	name, count := policy, ""
+	if i := strings.IndexByte(policy, ':'); i != -1 {
+		name, count = policy[:i], policy[i+1:]
+	}
+
+	rp := docker.RestartPolicy{Name: name}
+	if count != "" {
+		rp.MaximumRetryCount, _ = strconv.Atoi(count)
+	}
+	return rp
+}
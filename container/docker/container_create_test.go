@@ -1,7 +1,10 @@
 package docker_test
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
+	"io"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -97,6 +100,67 @@ var _ = Describe("Create Container", func() {
 		containers = append(containers, more...)
 	})
 
+	Context("Security", func() {
+		newArchive := func(name string) io.Reader {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 4})
+			tw.Write([]byte("test"))
+			tw.Close()
+			return &buf
+		}
+
+		It("should run framework containers with read-only root filesystem", func() {
+			containers, err = engine.Create(ctx, options)
+			Expect(err).NotTo(HaveOccurred())
+			c := containers[0]
+
+			Expect(c.CopyTo(ctx, "/", newArchive("test.txt"))).NotTo(Succeed())
+			Expect(c.CopyTo(ctx, c.Home()+"/data", newArchive("test.txt"))).To(Succeed())
+		})
+
+		It("should patch containers with read-only root filesystem", func() {
+			containers, err = engine.Create(ctx, options)
+			Expect(err).NotTo(HaveOccurred())
+			c := containers[0]
+			Expect(c.CopyTo(ctx, c.Home()+"/data", newArchive("test.txt"))).To(Succeed())
+
+			nc, err := c.Patch(ctx, newArchive("test.txt"))
+			Expect(err).NotTo(HaveOccurred())
+			containers = []container.Container{nc}
+			Expect(nc.ID()).NotTo(Equal(c.ID()))
+
+			r, err := nc.CopyFrom(ctx, nc.Home()+"/data/test.txt")
+			Expect(err).NotTo(HaveOccurred())
+			r.Close()
+			r, err = nc.CopyFrom(ctx, "/test.txt")
+			Expect(err).NotTo(HaveOccurred())
+			r.Close()
+		})
+
+		It("should allow plugins to request writable root filesystem", func() {
+			p := *plugin
+			p.WritableRoot = true
+			options.Plugin = &p
+
+			containers, err = engine.Create(ctx, options)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containers[0].CopyTo(ctx, "/", newArchive("test.txt"))).To(Succeed())
+		})
+
+		It("should refuse to run as root unless requested by the plugin", func() {
+			options.User = "root"
+			_, err = engine.Create(ctx, options)
+			Expect(err).To(HaveOccurred())
+
+			p := *plugin
+			p.User = "root"
+			options.Plugin = &p
+			containers, err = engine.Create(ctx, options)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
 	Context("Scaling", func() {
 		It("should fail if container exceeding maximum scaling level", func() {
 			containers, err = engine.Create(ctx, options)
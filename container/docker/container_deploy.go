@@ -21,7 +21,7 @@ import (
 	"github.com/docker/engine-api/types"
 )
 
-func (c *dockerContainer) Deploy(ctx context.Context, path string) error {
+func (c *dockerContainer) Deploy(ctx context.Context, path string, log *serverlog.ServerLog) error {
 	// Create context archive containing the repo archive
 	r, w := io.Pipe()
 	go func() {
@@ -37,9 +37,18 @@ func (c *dockerContainer) Deploy(ctx context.Context, path string) error {
 		return err
 	}
 
-	// Send signal to container to complete the deployment
-	c.ContainerKill(ctx, c.ID(), "SIGHUP")
-	return nil
+	// The deployment is completed when a stopped container is started
+	info, err := c.ContainerInspect(ctx, c.ID())
+	if err != nil {
+		return err
+	}
+	if !info.State.Running || info.State.Paused {
+		return nil
+	}
+
+	// Restart the running container to complete the deployment, failure
+	// of deployment hooks is reported back to abort the deployment
+	return c.Exec(ctx, "", nil, log.Stdout(), log.Stderr(), "/usr/bin/cwctl", "restart")
 }
 
 func PrepareRepo(content io.Reader, zip bool) (repodir string, err error) {
@@ -69,7 +78,7 @@ func PrepareRepo(content io.Reader, zip bool) (repodir string, err error) {
 	return
 }
 
-func (cli DockerEngine) DistributeRepo(ctx context.Context, containers []container.Container, repo io.Reader, zip bool) error {
+func (cli DockerEngine) DistributeRepo(ctx context.Context, containers []container.Container, repo io.Reader, zip bool, log *serverlog.ServerLog) error {
 	repodir, err := PrepareRepo(repo, zip)
 	if repodir != "" {
 		defer os.RemoveAll(repodir)
@@ -80,13 +89,12 @@ func (cli DockerEngine) DistributeRepo(ctx context.Context, containers []contain
 
 	for _, c := range containers {
 		if c.Category().IsFramework() {
-			er := c.Deploy(ctx, repodir)
-			if er != nil {
-				err = er
+			if err = c.Deploy(ctx, repodir, log); err != nil {
+				return err
 			}
 		}
 	}
-	return err
+	return nil
 }
 
 func (cli DockerEngine) DeployRepo(ctx context.Context, name, namespace string, in io.Reader, log *serverlog.ServerLog) error {
@@ -108,7 +116,7 @@ func (cli DockerEngine) DeployRepo(ctx context.Context, name, namespace string,
 
 	if base.Flags()&HotDeployable != 0 {
 		// distribute the repository directly
-		return cli.DistributeRepo(ctx, containers, in, false)
+		return cli.DistributeRepo(ctx, containers, in, false, log)
 	} else {
 		// build and distribute the repository
 		return build(cli, ctx, containers, base.(*dockerContainer), in, log)
@@ -161,7 +169,7 @@ func build(cli DockerEngine, ctx context.Context, containers []container.Contain
 	}
 	defer repo.Close()
 
-	return cli.DistributeRepo(ctx, containers, repo, true)
+	return cli.DistributeRepo(ctx, containers, repo, true, log)
 }
 
 func readPluginManifestFromContainer(ctx context.Context, base container.Container) (meta *manifest.Plugin, err error) {
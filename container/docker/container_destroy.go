@@ -20,11 +20,17 @@ func (c *dockerContainer) Destroy(ctx context.Context) error {
 	logrus.Debugf("Removed container %s", c.ID)
 
 	// remove associated image
+	c.removeImage(ctx, image)
+
+	return nil
+}
+
+// removeImage removes the image and its untagged parents if the image is
+// no longer used by any container.
+func (c *dockerContainer) removeImage(ctx context.Context, image string) {
 	if image != "" {
 		options := types.ImageRemoveOptions{Force: false, PruneChildren: true}
 		c.ImageRemove(ctx, image, options)
 		logrus.Debugf("Removed image %s", image)
 	}
-
-	return nil
 }
@@ -3,8 +3,14 @@ package docker
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/cloudway/platform/container"
@@ -14,6 +20,51 @@ import (
 
 // Execute command in application container.
 func (c *dockerContainer) Exec(ctx context.Context, user string, stdin io.Reader, stdout, stderr io.Writer, cmd ...string) error {
+	return c.exec(ctx, user, stdin, stdout, stderr, cmd)
+}
+
+// Execute command in application container with additional options.
+func (c *dockerContainer) ExecWithOptions(ctx context.Context, opts container.ExecOptions, cmd ...string) error {
+	var errbuf bytes.Buffer
+	stdout, stderr := opts.Stdout, opts.Stderr
+	if stderr == nil {
+		stderr = &errbuf
+	}
+
+	var limit *outputLimit
+	if opts.MaxOutput > 0 {
+		limit = &outputLimit{remaining: opts.MaxOutput}
+		if stdout != nil {
+			stdout = &limitedWriter{stdout, limit}
+		}
+		stderr = &limitedWriter{stderr, limit}
+	}
+
+	parent := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	err := c.exec(ctx, opts.User, opts.Stdin, stdout, stderr, cmd)
+	if err == nil {
+		return nil
+	}
+	if limit != nil && limit.exceeded {
+		return container.OutputLimitError{Command: cmd, Limit: opts.MaxOutput}
+	}
+	if ctx.Err() == context.DeadlineExceeded && parent.Err() == nil {
+		return container.TimeoutError{Command: cmd, Timeout: opts.Timeout}
+	}
+	if se, ok := err.(container.StatusError); ok && se.Message == "" && opts.Stderr == nil {
+		se.Message = chomp(&errbuf)
+		err = se
+	}
+	return err
+}
+
+func (c *dockerContainer) exec(ctx context.Context, user string, stdin io.Reader, stdout, stderr io.Writer, cmd []string) error {
 	// FIXME: Output may be closed if no stdin attached at sometimes.
 	// To workaround this problem always attach the stdin. This problem
 	// just occurres in docker swarm cluster, so it may be a docker bug.
@@ -46,6 +97,10 @@ func (c *dockerContainer) Exec(ctx context.Context, user string, stdin io.Reader
 	if err != nil {
 		return err
 	}
+	if err = ctx.Err(); err != nil {
+		// the deferred close of hijacked connection aborts the exec
+		return err
+	}
 
 	inspectResp, err := c.ContainerExecInspect(ctx, execId)
 	if err != nil {
@@ -60,6 +115,10 @@ func (c *dockerContainer) Exec(ctx context.Context, user string, stdin io.Reader
 	}
 }
 
+// pumpStreams copies data between the standard streams and the hijacked
+// connection until the command exits or the context is canceled. The
+// caller is responsible to close the hijacked connection, which unblocks
+// the pumping goroutines if the context was canceled.
 func pumpStreams(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, resp types.HijackedResponse) error {
 	var err error
 
@@ -97,23 +156,44 @@ func pumpStreams(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer,
 					return err
 				}
 			case <-ctx.Done():
+				return ctx.Err()
 			}
 		}
 	case <-ctx.Done():
+		return ctx.Err()
 	}
 
 	return nil
 }
 
+var errOutputLimit = errors.New("output limit exceeded")
+
+// outputLimit is shared by the standard output and standard error writers
+// to limit the total number of bytes captured from a command.
+type outputLimit struct {
+	remaining int64
+	exceeded  bool
+}
+
+type limitedWriter struct {
+	w     io.Writer
+	limit *outputLimit
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > w.limit.remaining {
+		w.limit.exceeded = true
+		return 0, errOutputLimit
+	}
+	w.limit.remaining -= int64(len(p))
+	return w.w.Write(p)
+}
+
 // Execute the command and accumulate error messages from standard error of
 // the command.
 func (c *dockerContainer) ExecE(ctx context.Context, user string, in io.Reader, out io.Writer, cmd ...string) error {
-	var errbuf bytes.Buffer
-	err := c.Exec(ctx, user, in, out, &errbuf, cmd...)
-	if se, ok := err.(container.StatusError); ok && se.Message == "" {
-		se.Message = chomp(&errbuf)
-	}
-	return err
+	opts := container.ExecOptions{User: user, Stdin: in, Stdout: out}
+	return c.ExecWithOptions(ctx, opts, cmd...)
 }
 
 // Silently execute the command and accumulate error messages from standard
@@ -128,11 +208,9 @@ func (c *dockerContainer) ExecQ(ctx context.Context, user string, cmd ...string)
 // as the standard output of the command, with any trailing newlines
 // deleted.
 func (c *dockerContainer) Subst(ctx context.Context, user string, in io.Reader, cmd ...string) (string, error) {
-	var outbuf, errbuf bytes.Buffer
-	err := c.Exec(ctx, user, in, &outbuf, &errbuf, cmd...)
-	if se, ok := err.(container.StatusError); ok && se.Message == "" {
-		se.Message = chomp(&errbuf)
-	}
+	var outbuf bytes.Buffer
+	opts := container.ExecOptions{User: user, Stdin: in, Stdout: &outbuf}
+	err := c.ExecWithOptions(ctx, opts, cmd...)
 	return chomp(&outbuf), err
 }
 
@@ -154,3 +232,69 @@ func (c *dockerContainer) Processes(ctx context.Context) (*container.ProcessList
 func (c *dockerContainer) Stats(ctx context.Context, stream bool) (io.ReadCloser, error) {
 	return c.ContainerStats(ctx, c.ID(), stream)
 }
+
+func (c *dockerContainer) DiskUsage(ctx context.Context) (int64, error) {
+	info, _, err := c.ContainerInspectWithRaw(ctx, c.ID(), true)
+	if err != nil {
+		return 0, err
+	}
+	if info.ContainerJSONBase == nil {
+		return 0, nil
+	}
+
+	var size int64
+	if info.SizeRw != nil {
+		size = *info.SizeRw
+	}
+
+	// files in volumes are not included in the size of the container
+	// layer, they can only be measured in a running container
+	volumes := volumePaths(info.Mounts)
+	if len(volumes) == 0 || info.State == nil || !info.State.Running {
+		return size, nil
+	}
+
+	var out bytes.Buffer
+	opts := container.ExecOptions{
+		User:      "root",
+		Stdout:    &out,
+		Timeout:   diskUsageTimeout,
+		MaxOutput: maxInfoOutput,
+	}
+	args := append([]string{"du", "-skc"}, volumes...)
+	if err = c.ExecWithOptions(ctx, opts, args...); err != nil {
+		return size, err
+	}
+
+	// the last line is the total size in kilobytes
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) == 0 {
+		return size, fmt.Errorf("Unexpected output from du: %q", out.String())
+	}
+	kb, err := strconv.ParseInt(fields[0], 10, 64)
+	return size + kb*1024, err
+}
+
+const diskUsageTimeout = 30 * time.Second
+
+// volumePaths returns the mount points of volumes in the container,
+// excluding bind mounts and volumes nested in other volumes.
+func volumePaths(mounts []types.MountPoint) []string {
+	var paths []string
+	for _, m := range mounts {
+		if m.Name != "" {
+			paths = append(paths, path.Clean(m.Destination))
+		}
+	}
+	sort.Strings(paths)
+
+	var result []string
+	for _, p := range paths {
+		if n := len(result); n != 0 && (p == result[n-1] || strings.HasPrefix(p, result[n-1]+"/")) {
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
@@ -4,10 +4,19 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"time"
 
+	"github.com/cloudway/platform/container"
 	"github.com/cloudway/platform/pkg/manifest"
 )
 
+// Limits for gathering application information, so a misbehaving sandbox
+// cannot block API requests or exhaust server memory.
+const (
+	infoTimeout   = 30 * time.Second
+	maxInfoOutput = 1024 * 1024
+)
+
 // Get application information from container.
 func (c *dockerContainer) GetInfo(ctx context.Context, options ...string) (*manifest.SandboxInfo, error) {
 	var args = []string{"/usr/bin/cwctl", "info", "--ip", c.IP()}
@@ -16,7 +25,13 @@ func (c *dockerContainer) GetInfo(ctx context.Context, options ...string) (*mani
 	}
 
 	var buf bytes.Buffer
-	err := c.ExecE(ctx, "root", nil, &buf, args...)
+	opts := container.ExecOptions{
+		User:      "root",
+		Stdout:    &buf,
+		Timeout:   infoTimeout,
+		MaxOutput: maxInfoOutput,
+	}
+	err := c.ExecWithOptions(ctx, opts, args...)
 	if err != nil {
 		return nil, err
 	}
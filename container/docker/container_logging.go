@@ -0,0 +1,74 @@
+package docker
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/types"
+	docker "github.com/docker/engine-api/types/container"
+	"github.com/docker/engine-api/types/network"
+
+	"github.com/cloudway/platform/container"
+)
+
+// logConfig returns the Docker log driver configuration. Log entries are
+// tagged with the container hostname unless a tag option is given. The
+// default log driver of the daemon is used if logging is not configured.
+func logConfig(logging *container.LogConfig, tag string) docker.LogConfig {
+	if logging == nil || logging.Driver == "" {
+		return docker.LogConfig{}
+	}
+
+	opts := map[string]string{"tag": tag}
+	if key := container.LogAddressOptions[logging.Driver]; key != "" && logging.Address != "" {
+		opts[key] = logging.Address
+	}
+	for k, v := range logging.Options {
+		opts[k] = v
+	}
+	return docker.LogConfig{Type: logging.Driver, Config: opts}
+}
+
+// SetLogging recreates the container with the new log driver. Docker
+// doesn't allow to change the log driver of an existing container, so the
+// container is committed to an image to preserve files, and a new
+// container is created from the image.
+func (c *dockerContainer) SetLogging(ctx context.Context, logging *container.LogConfig) (container.Container, error) {
+	if c.State.Running {
+		if err := c.Stop(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	commit, err := c.ContainerCommit(ctx, c.ID(), types.ContainerCommitOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	config := *c.Config
+	config.Image = commit.ID
+	hostConfig := *c.HostConfig
+	hostConfig.LogConfig = logConfig(logging, strings.TrimSuffix(c.baseName(), "-"))
+
+	resp, err := c.ContainerCreate(ctx, &config, &hostConfig, &network.NetworkingConfig{}, newContainerName(c.DockerEngine, ctx, c.baseName()))
+	if err != nil {
+		return nil, err
+	}
+	nc, err := c.DockerEngine.Inspect(ctx, resp.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// volumes are not committed with the container
+	if err = c.copyVolumes(ctx, nc); err != nil {
+		nc.Destroy(ctx)
+		return nil, err
+	}
+
+	options := types.ContainerRemoveOptions{Force: true, RemoveVolumes: true}
+	if err = c.ContainerRemove(ctx, c.ID(), options); err != nil {
+		logrus.WithError(err).Warnf("Failed to remove container %s after log driver changed", c.ID())
+	}
+	return nc, nil
+}
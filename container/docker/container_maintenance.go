@@ -0,0 +1,14 @@
+package docker
+
+import "context"
+
+const MAINTENANCE_KEY = "CLOUDWAY_MAINTENANCE"
+
+func (c *dockerContainer) SetMaintenance(ctx context.Context, user string) error {
+	return c.Setenv(ctx, MAINTENANCE_KEY, user)
+}
+
+func (c *dockerContainer) Maintenance(ctx context.Context) string {
+	user, _ := c.Getenv(ctx, MAINTENANCE_KEY)
+	return user
+}
@@ -0,0 +1,59 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+const (
+	ACCESS_POLICY_KEY = "CLOUDWAY_ACCESS_POLICY"
+	HTTP_SETTINGS_KEY = "CLOUDWAY_HTTP_SETTINGS"
+)
+
+func (c *dockerContainer) SetAccessPolicy(ctx context.Context, policy *manifest.AccessPolicy) error {
+	if policy == nil {
+		return c.Setenv(ctx, ACCESS_POLICY_KEY, "")
+	}
+	return c.setJSONEnv(ctx, ACCESS_POLICY_KEY, policy)
+}
+
+func (c *dockerContainer) AccessPolicy(ctx context.Context) *manifest.AccessPolicy {
+	var policy manifest.AccessPolicy
+	if !c.getJSONEnv(ctx, ACCESS_POLICY_KEY, &policy) {
+		return nil
+	}
+	return &policy
+}
+
+func (c *dockerContainer) SetHTTPSettings(ctx context.Context, settings *manifest.HTTPSettings) error {
+	if settings == nil {
+		return c.Setenv(ctx, HTTP_SETTINGS_KEY, "")
+	}
+	return c.setJSONEnv(ctx, HTTP_SETTINGS_KEY, settings)
+}
+
+func (c *dockerContainer) HTTPSettings(ctx context.Context) *manifest.HTTPSettings {
+	var settings manifest.HTTPSettings
+	if !c.getJSONEnv(ctx, HTTP_SETTINGS_KEY, &settings) {
+		return nil
+	}
+	return &settings
+}
+
+// setJSONEnv sets the environment variable to the value encoded in JSON.
+func (c *dockerContainer) setJSONEnv(ctx context.Context, name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.Setenv(ctx, name, string(data))
+}
+
+// getJSONEnv decodes the JSON value of the environment variable. Returns
+// false if the variable is not set or invalid.
+func (c *dockerContainer) getJSONEnv(ctx context.Context, name string, v interface{}) bool {
+	value, _ := c.Getenv(ctx, name)
+	return value != "" && json.Unmarshal([]byte(value), v) == nil
+}
@@ -0,0 +1,120 @@
+package docker
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/network"
+
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/container"
+)
+
+// Rename recreates the container with the new application name, namespace
+// and domain. Docker doesn't allow to change labels of an existing
+// container, so the container is committed to an image to preserve
+// files, and a new container is created from the image. The image of the
+// old container is removed after renamed, and the committed image is
+// removed when the new container is destroyed.
+func (c *dockerContainer) Rename(ctx context.Context, name, namespace, domain string) (container.Container, error) {
+	oldName, oldNamespace := c.Name(), c.Namespace()
+
+	if c.State.Running {
+		if err := c.Stop(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	commit, err := c.ContainerCommit(ctx, c.ID(), types.ContainerCommitOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	config := *c.Config
+	config.Image = commit.ID
+
+	config.Labels = make(map[string]string, len(c.Config.Labels))
+	for k, v := range c.Config.Labels {
+		config.Labels[k] = v
+	}
+	config.Labels[APP_NAME_KEY] = name
+	config.Labels[APP_NAMESPACE_KEY] = namespace
+	if domain != "" {
+		config.Labels[APP_DOMAIN_KEY] = domain
+	} else {
+		delete(config.Labels, APP_DOMAIN_KEY)
+	}
+
+	// user defined plugins are tagged with the namespace
+	if tag := config.Labels[PLUGIN_KEY]; strings.HasPrefix(tag, oldNamespace+"/") {
+		config.Labels[PLUGIN_KEY] = namespace + "/" + tag[len(oldNamespace)+1:]
+	}
+
+	hostname := name + "-" + namespace
+	baseName := hostname + "-"
+	fqdn := defaults.AppHost(name, namespace, domain)
+	if service := c.ServiceName(); service != "" {
+		hostname = service + "." + hostname
+		baseName = service + "." + baseName
+		fqdn = service + "." + fqdn
+		config.Hostname = hostname
+	}
+
+	env := map[string]string{
+		"CLOUDWAY_APP_NAME":      name,
+		"CLOUDWAY_APP_NAMESPACE": namespace,
+		"CLOUDWAY_APP_DNS":       fqdn,
+	}
+	config.Env = make([]string, 0, len(c.Config.Env))
+	for _, e := range c.Config.Env {
+		kv := strings.SplitN(e, "=", 2)
+		if _, ok := env[kv[0]]; !ok {
+			config.Env = append(config.Env, e)
+		}
+	}
+	for k, v := range env {
+		config.Env = append(config.Env, k+"="+v)
+	}
+
+	hostConfig := *c.HostConfig
+	netConfig := &network.NetworkingConfig{}
+
+	resp, err := c.ContainerCreate(ctx, &config, &hostConfig, netConfig, newContainerName(c.DockerEngine, ctx, baseName))
+	if err != nil {
+		c.removeImage(ctx, commit.ID)
+		return nil, err
+	}
+	nc, err := c.DockerEngine.Inspect(ctx, resp.ID)
+	if err != nil {
+		c.ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{Force: true, RemoveVolumes: true})
+		c.removeImage(ctx, commit.ID)
+		return nil, err
+	}
+
+	// volumes are not committed with the container
+	if err = c.copyVolumes(ctx, nc); err != nil {
+		nc.Destroy(ctx)
+		return nil, err
+	}
+
+	// update environment files read by the sandbox
+	for k, v := range env {
+		if err = nc.Setenv(ctx, k, v); err != nil {
+			nc.Destroy(ctx)
+			return nil, err
+		}
+	}
+
+	options := types.ContainerRemoveOptions{Force: true, RemoveVolumes: true}
+	if err = c.ContainerRemove(ctx, c.ID(), options); err != nil {
+		logrus.WithError(err).Warnf("Failed to remove container %s after renamed", c.ID())
+	} else {
+		// the image is kept as long as it's a parent of the committed image
+		c.removeImage(ctx, c.Config.Image)
+	}
+
+	logrus.Debugf("Renamed container %s of %s-%s to %s of %s-%s", c.ID(), oldName, oldNamespace, nc.ID(), name, namespace)
+	return nc, nil
+}
@@ -49,6 +49,18 @@ func (c *dockerContainer) Run(ctx context.Context, cmd *container.RunCmd) (err e
 	go func() {
 		defer resp.Close()
 
+		// close the hijacked connection if the context is canceled,
+		// this unblocks the stream copying below
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				resp.Close()
+			case <-done:
+			}
+		}()
+
 		// pipe stream to container and vice-versa
 		go func() {
 			cmd.CopyInput(resp.Conn, cmd.Stdin)
@@ -0,0 +1,272 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/types"
+	docker "github.com/docker/engine-api/types/container"
+	"github.com/docker/engine-api/types/network"
+	"github.com/docker/engine-api/types/strslice"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// Containers are hardened by default. All Linux capabilities are dropped
+// except the minimal set required by the sandbox, privilege escalation is
+// disabled, and the docker default or configured seccomp profile applied.
+// Framework containers also run with a read-only root filesystem, only the
+// application home and temporary directories are writable. Plugins that
+// need more can override these in the plugin manifest.
+//
+// Plugin processes never run as root unless the plugin manifest explicitly
+// requests the root user, which can be refused by the "app.allow_root"
+// policy. If the docker daemon remaps user namespaces, the root user in
+// containers is mapped to an unprivileged user on the host, the plugin can
+// request the host user namespace, which is also subject to the policy.
+
+// defaultCapabilities are capabilities kept in containers, which are used
+// by the sandbox to switch to the application user and manage application
+// files.
+var defaultCapabilities = []string{
+	"CHOWN", "DAC_OVERRIDE", "FOWNER", "KILL", "NET_BIND_SERVICE", "SETGID", "SETUID",
+}
+
+// writableTmpfs are temporary filesystems mounted in containers with
+// read-only root filesystem.
+var writableTmpfs = map[string]string{
+	"/tmp": "rw,exec,nosuid,nodev",
+	"/run": "rw,nosuid,nodev",
+}
+
+// privilegeError indicates that the plugin requests privileges not allowed
+// by the security policy.
+type privilegeError struct {
+	plugin, reason string
+}
+
+func (e privilegeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.plugin, e.reason)
+}
+
+func (e privilegeError) HTTPErrorStatusCode() int {
+	return http.StatusForbidden
+}
+
+// checkPrivileges refuses to run plugin processes as root unless requested
+// by the plugin manifest and allowed by the policy.
+func checkPrivileges(cfg *createConfig) error {
+	plugin := cfg.Plugin
+	if isRoot(cfg.User) && !isRoot(plugin.User) {
+		return privilegeError{plugin.Name, "refuse to run as root, the plugin manifest doesn't request the root user"}
+	}
+	if (isRoot(cfg.User) || plugin.HostUserns) && !allowRoot() {
+		return privilegeError{plugin.Name, "running as root or in the host user namespace is not allowed"}
+	}
+	return nil
+}
+
+func isRoot(user string) bool {
+	return user == "root" || user == "0" || strings.HasPrefix(user, "0:")
+}
+
+// allowRoot returns true if plugins can request the root user or the host
+// user namespace, which is configured by "app.allow_root" and allowed by
+// default.
+func allowRoot() bool {
+	b, err := strconv.ParseBool(config.GetOrDefault("app.allow_root", "true"))
+	return err != nil || b
+}
+
+// usernsRemap returns true if the docker daemon remaps user namespaces.
+func usernsRemap(securityOptions []string) bool {
+	for _, opt := range securityOptions {
+		if opt == "userns" || strings.HasPrefix(opt, "name=userns") {
+			return true
+		}
+	}
+	return false
+}
+
+// harden applies security options to the configuration of a new container.
+func harden(cfg *createConfig, config *docker.Config, hostConfig *docker.HostConfig) error {
+	plugin := cfg.Plugin
+
+	if err := checkPrivileges(cfg); err != nil {
+		return err
+	}
+	if plugin.HostUserns {
+		hostConfig.UsernsMode = "host"
+	}
+
+	hostConfig.CapDrop = strslice.StrSlice{"ALL"}
+	hostConfig.CapAdd = strslice.StrSlice(capabilities(plugin))
+	hostConfig.SecurityOpt = []string{"no-new-privileges"}
+
+	seccomp, err := seccompProfile(plugin)
+	if err != nil {
+		return err
+	}
+	if seccomp != "" {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "seccomp:"+seccomp)
+	}
+
+	if cfg.Category.IsFramework() && readOnlyRoot() && !plugin.WritableRoot {
+		hostConfig.ReadonlyRootfs = true
+		hostConfig.Tmpfs = writableTmpfs
+		// the application home is mounted as a volume populated from the
+		// image, plugins render templates and write runtime files in the
+		// plugin directories
+		config.Volumes = map[string]struct{}{cfg.Home: {}}
+	}
+	return nil
+}
+
+// capabilities returns capabilities kept in the container, including
+// additional capabilities requested by the plugin.
+func capabilities(plugin *manifest.Plugin) []string {
+	caps := append([]string(nil), defaultCapabilities...)
+	for _, c := range plugin.Capabilities {
+		c = strings.TrimPrefix(strings.ToUpper(c), "CAP_")
+		found := false
+		for _, x := range caps {
+			if x == c {
+				found = true
+				break
+			}
+		}
+		if !found {
+			caps = append(caps, c)
+		}
+	}
+	return caps
+}
+
+// seccompProfile returns the seccomp profile applied to the container. The
+// plugin can request "unconfined" to disable seccomp. Otherwise the profile
+// configured by "app.seccomp_profile" is applied, or an empty string is
+// returned to apply the docker default profile.
+func seccompProfile(plugin *manifest.Plugin) (string, error) {
+	if plugin.Seccomp == "unconfined" {
+		return "unconfined", nil
+	}
+	if plugin.Seccomp != "" && plugin.Seccomp != "default" {
+		return "", fmt.Errorf("%s: invalid seccomp profile '%s'", plugin.Name, plugin.Seccomp)
+	}
+
+	path := config.Get("app.seccomp_profile")
+	if path == "" {
+		return "", nil
+	}
+	profile, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(profile), nil
+}
+
+// readOnlyRoot returns true if framework containers run with read-only
+// root filesystem, which is configured by "app.read_only_root" and
+// enabled by default.
+func readOnlyRoot() bool {
+	b, err := strconv.ParseBool(config.GetOrDefault("app.read_only_root", "true"))
+	return err != nil || b
+}
+
+// Patch copies files into the root filesystem of the container. Docker
+// refuses to copy files into a read-only root filesystem, so the container
+// is committed to an image, files are copied into a temporary container
+// created from the image, and the container is recreated from the patched
+// image. Files in volumes are copied to the new container.
+func (c *dockerContainer) Patch(ctx context.Context, content io.Reader) (container.Container, error) {
+	if !c.HostConfig.ReadonlyRootfs {
+		return c, c.CopyTo(ctx, "/", content)
+	}
+
+	if c.State.Running {
+		if err := c.Stop(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	commit, err := c.ContainerCommit(ctx, c.ID(), types.ContainerCommitOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// copy files into a temporary container with writable root filesystem
+	tmpConfig := *c.Config
+	tmpConfig.Image = commit.ID
+	tmpConfig.Volumes = nil
+	tmp, err := c.ContainerCreate(ctx, &tmpConfig, &docker.HostConfig{}, &network.NetworkingConfig{}, "")
+	if err != nil {
+		return nil, err
+	}
+	rmopts := types.ContainerRemoveOptions{Force: true, RemoveVolumes: true}
+	defer c.ContainerRemove(ctx, tmp.ID, rmopts)
+
+	opts := types.CopyToContainerOptions{AllowOverwriteDirWithFile: true}
+	if err = c.CopyToContainer(ctx, tmp.ID, "/", content, opts); err != nil {
+		return nil, err
+	}
+	patched, err := c.ContainerCommit(ctx, tmp.ID, types.ContainerCommitOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	config := *c.Config
+	config.Image = patched.ID
+	hostConfig := *c.HostConfig
+	resp, err := c.ContainerCreate(ctx, &config, &hostConfig, &network.NetworkingConfig{}, newContainerName(c.DockerEngine, ctx, c.baseName()))
+	if err != nil {
+		return nil, err
+	}
+	nc, err := c.DockerEngine.Inspect(ctx, resp.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.copyVolumes(ctx, nc); err != nil {
+		nc.Destroy(ctx)
+		return nil, err
+	}
+
+	if err = c.ContainerRemove(ctx, c.ID(), rmopts); err != nil {
+		logrus.WithError(err).Warnf("Failed to remove container %s after patched", c.ID())
+	}
+	return nc, nil
+}
+
+// copyVolumes copies files in volumes of the container to the new
+// container, volumes are not preserved when the container is committed.
+func (c *dockerContainer) copyVolumes(ctx context.Context, nc container.Container) error {
+	for path := range c.Config.Volumes {
+		r, err := c.CopyFrom(ctx, path+"/.")
+		if err != nil {
+			return err
+		}
+		err = nc.CopyTo(ctx, path+"/", r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// baseName returns the base name of the container, to which a sequence
+// number is appended.
+func (c *dockerContainer) baseName() string {
+	baseName := c.Name() + "-" + c.Namespace() + "-"
+	if service := c.ServiceName(); service != "" {
+		baseName = service + "." + baseName
+	}
+	return baseName
+}
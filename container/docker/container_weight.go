@@ -0,0 +1,22 @@
+package docker
+
+import (
+	"context"
+	"strconv"
+)
+
+const TRAFFIC_WEIGHT_KEY = "CLOUDWAY_TRAFFIC_WEIGHT"
+
+func (c *dockerContainer) SetTrafficWeight(ctx context.Context, weight int) error {
+	var value string
+	if weight != 0 {
+		value = strconv.Itoa(weight)
+	}
+	return c.Setenv(ctx, TRAFFIC_WEIGHT_KEY, value)
+}
+
+func (c *dockerContainer) TrafficWeight(ctx context.Context) int {
+	value, _ := c.Getenv(ctx, TRAFFIC_WEIGHT_KEY)
+	weight, _ := strconv.Atoi(value)
+	return weight
+}
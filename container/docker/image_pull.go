@@ -0,0 +1,74 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/docker/engine-api/types"
+)
+
+// PullImage pulls the image from registry if it's not present on the node.
+// Credentials of private registries are taken from the configuration.
+func (cli DockerEngine) PullImage(ctx context.Context, image string, out io.Writer) error {
+	if _, _, err := cli.ImageInspectWithRaw(ctx, image, false); err == nil {
+		return nil
+	}
+
+	auth, err := registryAuth(image)
+	if err != nil {
+		return err
+	}
+	rd, err := cli.ImagePull(ctx, image, types.ImagePullOptions{RegistryAuth: auth})
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+	return readImageStream(rd, out)
+}
+
+// PublishImage commits the container into an image and pushes the image to
+// the registry with credentials taken from the configuration.
+func (cli DockerEngine) PublishImage(ctx context.Context, id, ref string, out io.Writer) error {
+	_, err := cli.ContainerCommit(ctx, id, types.ContainerCommitOptions{Reference: ref, Pause: true})
+	if err != nil {
+		return err
+	}
+
+	auth, err := registryAuth(ref)
+	if err != nil {
+		return err
+	}
+	rd, err := cli.ImagePush(ctx, ref, types.ImagePushOptions{RegistryAuth: auth})
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+	return readImageStream(rd, out)
+}
+
+// readImageStream reads the JSON messages of pulling or pushing images, and
+// writes status to the output if not nil.
+func readImageStream(rd io.Reader, out io.Writer) error {
+	dec := json.NewDecoder(rd)
+	for {
+		var jm JSONMessage
+		if err := dec.Decode(&jm); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return err
+		}
+		if jm.Error != nil {
+			return jm.Error
+		}
+		if jm.Status != "" && jm.ProgressMessage == "" && out != nil {
+			if jm.ID != "" {
+				fmt.Fprintf(out, "%s: %s\n", jm.ID, jm.Status)
+			} else {
+				fmt.Fprintln(out, jm.Status)
+			}
+		}
+	}
+}
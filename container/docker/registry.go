@@ -0,0 +1,75 @@
+package docker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/docker/engine-api/types"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
+)
+
+// Credentials of private registries shared by all namespaces are configured
+// in "registry:<server>" sections with "username" and "password" keys.
+const registrySectionPrefix = "registry:"
+
+// The address of Docker Hub used as the key of build credentials.
+const dockerHubAddress = "https://index.docker.io/v1/"
+
+// platformRegistries returns credentials of registries configured for the
+// platform.
+func platformRegistries() []container.RegistryAuth {
+	var regs []container.RegistryAuth
+	for _, section := range config.GetSections() {
+		if !strings.HasPrefix(section, registrySectionPrefix) {
+			continue
+		}
+		opts := config.GetSection(section)
+		regs = append(regs, container.RegistryAuth{
+			Server:   strings.TrimPrefix(section, registrySectionPrefix),
+			Username: opts["username"],
+			Password: opts["password"],
+		})
+	}
+	return regs
+}
+
+// buildAuthConfigs returns credentials of all known registries to pull base
+// images while building images. Credentials given by the create options
+// override the platform configuration.
+func buildAuthConfigs(regs []container.RegistryAuth) map[string]types.AuthConfig {
+	auths := make(map[string]types.AuthConfig)
+	for _, list := range [][]container.RegistryAuth{platformRegistries(), regs} {
+		for _, reg := range list {
+			address := reg.Server
+			if address == container.DockerHub {
+				address = dockerHubAddress
+			}
+			auths[address] = types.AuthConfig{
+				Username:      reg.Username,
+				Password:      reg.Password,
+				ServerAddress: address,
+			}
+		}
+	}
+	return auths
+}
+
+// registryAuth returns the encoded credentials to pull or push the image,
+// or an empty string if the registry of the image is not configured.
+func registryAuth(image string) (string, error) {
+	host := container.RegistryHost(image)
+	for _, reg := range platformRegistries() {
+		if reg.Server == host {
+			auth := types.AuthConfig{Username: reg.Username, Password: reg.Password, ServerAddress: reg.Server}
+			buf, err := json.Marshal(auth)
+			if err != nil {
+				return "", err
+			}
+			return base64.URLEncoding.EncodeToString(buf), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,18 @@
+package docker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/container"
+)
+
+var _ = Describe("Registry", func() {
+	It("should get registry host from image name", func() {
+		Expect(container.RegistryHost("ubuntu")).To(Equal(container.DockerHub))
+		Expect(container.RegistryHost("library/ubuntu:16.04")).To(Equal(container.DockerHub))
+		Expect(container.RegistryHost("Registry.Example.com/foo/bar")).To(Equal("registry.example.com"))
+		Expect(container.RegistryHost("example:5000/foo")).To(Equal("example:5000"))
+		Expect(container.RegistryHost("localhost/foo")).To(Equal("localhost"))
+	})
+})
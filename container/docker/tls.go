@@ -0,0 +1,71 @@
+package docker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/docker/engine-api/client"
+
+	"github.com/cloudway/platform/config"
+)
+
+// newClient creates a docker client. The client is configured from the
+// environment unless TLS credentials are provided in the configuration by
+// the "docker.tls_cert" and "docker.tls_key" keys. The credentials can be
+// either file names or PEM encoded content, possibly encrypted so that the
+// configuration file can be shared without leaking the private key.
+func newClient() (*client.Client, error) {
+	certPEM, keyPEM := config.Get("docker.tls_cert"), config.Get("docker.tls_key")
+	if certPEM == "" && keyPEM == "" {
+		return client.NewEnvClient()
+	}
+
+	host := config.Get("docker.host")
+	if host == "" {
+		return nil, errors.New("docker.host must be configured to use TLS")
+	}
+
+	certData, err := readPEM(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	keyData, err := readPEM(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(certData, keyData)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if ca := config.Get("docker.tls_ca"); ca != "" {
+		caData, err := readPEM(ca)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caData) {
+			return nil, errors.New("docker.tls_ca: no valid CA certificate found")
+		}
+	}
+
+	httpClient := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+	return client.NewClient(host, os.Getenv("DOCKER_API_VERSION"), httpClient, nil)
+}
+
+// readPEM returns the PEM content, or reads it from file if the value is
+// a file name.
+func readPEM(value string) ([]byte, error) {
+	if strings.HasPrefix(value, "-----BEGIN ") {
+		return []byte(value), nil
+	}
+	return ioutil.ReadFile(value)
+}
@@ -0,0 +1,199 @@
+package dns
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const cloudflareEndpoint = "https://api.cloudflare.com/client/v4"
+
+// The cloudflare provider manages records with the Cloudflare API v4. The
+// API token must be permitted to edit DNS records of the zone. Records are
+// not proxied by Cloudflare.
+type cloudflareProvider struct {
+	endpoint string
+	zoneID   string
+	zone     string
+	token    string
+	client   *http.Client
+}
+
+func init() {
+	providerRegistry["cloudflare"] = func(u *url.URL, key, secret string) (Provider, error) {
+		p := &cloudflareProvider{
+			endpoint: cloudflareEndpoint,
+			zoneID:   u.Host,
+			token:    secret,
+			client:   http.DefaultClient,
+		}
+		if err := p.init(); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+}
+
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+}
+
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result     json.RawMessage `json:"result"`
+	ResultInfo struct {
+		Page       int `json:"page"`
+		TotalPages int `json:"total_pages"`
+	} `json:"result_info"`
+}
+
+// init looks up the zone name.
+func (p *cloudflareProvider) init() error {
+	var zone struct {
+		Name string `json:"name"`
+	}
+	if _, err := p.do("GET", "/zones/"+p.zoneID, nil, nil, &zone); err != nil {
+		return err
+	}
+	p.zone = canonicalName(zone.Name)
+	return nil
+}
+
+func (p *cloudflareProvider) Zone() string {
+	return p.zone
+}
+
+func (p *cloudflareProvider) Records() ([]*Record, error) {
+	var records []*Record
+	for _, typ := range []string{"A", "AAAA", "CNAME"} {
+		list, err := p.find(url.Values{"type": {typ}})
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range list {
+			records = append(records, &Record{
+				Name:  canonicalName(r.Name),
+				Type:  r.Type,
+				Value: canonicalName(r.Content),
+				TTL:   r.TTL,
+			})
+		}
+	}
+	return records, nil
+}
+
+func (p *cloudflareProvider) SetRecord(rec *Record) error {
+	existing, err := p.find(url.Values{"type": {rec.Type}, "name": {rec.Name}})
+	if err != nil {
+		return err
+	}
+
+	body := &cloudflareRecord{Type: rec.Type, Name: rec.Name, Content: rec.Value, TTL: rec.TTL}
+	if len(existing) == 0 {
+		_, err = p.do("POST", "/zones/"+p.zoneID+"/dns_records", nil, body, nil)
+		return err
+	}
+	for i, r := range existing {
+		if i == 0 {
+			_, err = p.do("PUT", "/zones/"+p.zoneID+"/dns_records/"+r.ID, nil, body, nil)
+		} else {
+			_, err = p.do("DELETE", "/zones/"+p.zoneID+"/dns_records/"+r.ID, nil, nil, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *cloudflareProvider) RemoveRecord(rec *Record) error {
+	existing, err := p.find(url.Values{"type": {rec.Type}, "name": {rec.Name}})
+	if err != nil {
+		return err
+	}
+	for _, r := range existing {
+		if _, err = p.do("DELETE", "/zones/"+p.zoneID+"/dns_records/"+r.ID, nil, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// find returns records matching the query, following all result pages.
+func (p *cloudflareProvider) find(query url.Values) ([]*cloudflareRecord, error) {
+	var records []*cloudflareRecord
+	query.Set("per_page", "100")
+	for page := 1; ; page++ {
+		var list []*cloudflareRecord
+		query.Set("page", strconv.Itoa(page))
+		resp, err := p.do("GET", "/zones/"+p.zoneID+"/dns_records", query, nil, &list)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, list...)
+		if page >= resp.ResultInfo.TotalPages {
+			return records, nil
+		}
+	}
+}
+
+func (p *cloudflareProvider) do(method, path string, query url.Values, body, result interface{}) (*cloudflareResponse, error) {
+	var payload io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		payload = bytes.NewReader(buf)
+	}
+
+	rawurl := p.endpoint + path
+	if len(query) != 0 {
+		rawurl += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, rawurl, payload)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var cfresp cloudflareResponse
+	if err = json.NewDecoder(resp.Body).Decode(&cfresp); err != nil {
+		return nil, fmt.Errorf("Cloudflare API error %d", resp.StatusCode)
+	}
+	if !cfresp.Success {
+		var msgs []string
+		for _, e := range cfresp.Errors {
+			msgs = append(msgs, fmt.Sprintf("%d: %s", e.Code, e.Message))
+		}
+		return nil, fmt.Errorf("Cloudflare API error %d: %s", resp.StatusCode, strings.Join(msgs, "; "))
+	}
+	if result != nil && len(cfresp.Result) != 0 {
+		if err = json.Unmarshal(cfresp.Result, result); err != nil {
+			return nil, err
+		}
+	}
+	return &cfresp, nil
+}
@@ -0,0 +1,239 @@
+// Package dns manages DNS records of application aliases and namespace
+// subdomains with DNS providers, so custom hosts and delegated domains
+// resolve to the proxy without manual configuration.
+//
+// The provider is configured by "dns.url", in the form of
+// "route53://<hosted-zone-id>", "cloudflare://<zone-id>" or
+// "rfc2136://<server>[:port]/<zone>". Credentials are configured by
+// "dns.key" and "dns.secret", which are the access key of Route 53, the
+// API token of Cloudflare, or the TSIG key name and secret of RFC 2136.
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudway/platform/config"
+	mulerr "github.com/cloudway/platform/pkg/errors"
+)
+
+// Record is an address record managed by the platform. Names are fully
+// qualified in lower case, without the trailing dot.
+type Record struct {
+	Name  string
+	Type  string // A, AAAA or CNAME
+	Value string
+	TTL   int
+}
+
+func (r *Record) String() string {
+	return fmt.Sprintf("%s %d IN %s %s", r.Name, r.TTL, r.Type, r.Value)
+}
+
+// Provider manages records in a DNS zone.
+type Provider interface {
+	// Zone returns the name of the zone managed by the provider.
+	Zone() string
+
+	// Records returns A, AAAA and CNAME records in the zone.
+	Records() ([]*Record, error)
+
+	// SetRecord creates the record, or replaces records with the same
+	// name and type.
+	SetRecord(rec *Record) error
+
+	// RemoveRecord removes records with the same name and type.
+	RemoveRecord(rec *Record) error
+}
+
+const defaultTTL = 300
+
+var ErrMisconfigured = errors.New("DNS provider URL not configured")
+
+type UnsupportedSchemeError string
+
+func (scheme UnsupportedSchemeError) Error() string {
+	return fmt.Sprintf("Unsupported DNS provider: %s", string(scheme))
+}
+
+type providerFunc func(u *url.URL, key, secret string) (Provider, error)
+
+var providerRegistry = make(map[string]providerFunc)
+
+// New creates a DNS provider from the URL and credentials.
+func New(rawurl, key, secret string) (Provider, error) {
+	if rawurl == "" {
+		return nil, ErrMisconfigured
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	fn := providerRegistry[u.Scheme]
+	if fn == nil {
+		return nil, UnsupportedSchemeError(u.Scheme)
+	}
+	return fn(u, key, secret)
+}
+
+// FromConfig creates the DNS provider configured for the platform.
+func FromConfig() (Provider, error) {
+	return New(config.Get("dns.url"), config.Get("dns.key"), config.Get("dns.secret"))
+}
+
+// Target returns the address that records point to, which is the public
+// address of the proxy configured by "dns.target", and the TTL of records.
+func Target() (target string, ttl int) {
+	ttl = defaultTTL
+	if n, err := strconv.Atoi(config.Get("dns.ttl")); err == nil && n > 0 {
+		ttl = n
+	}
+	return strings.TrimSuffix(config.Get("dns.target"), "."), ttl
+}
+
+// NewRecord returns the record of the name pointing to the target. A or
+// AAAA record is used if the target is an IP address, otherwise CNAME.
+func NewRecord(name, target string, ttl int) *Record {
+	rec := &Record{Name: canonicalName(name), Value: target, TTL: ttl}
+	if ip := net.ParseIP(target); ip == nil {
+		rec.Type, rec.Value = "CNAME", canonicalName(target)
+	} else if ip.To4() != nil {
+		rec.Type = "A"
+	} else {
+		rec.Type = "AAAA"
+	}
+	return rec
+}
+
+// InZone returns true if the name is in the zone. The apex of the zone is
+// excluded as it can't be a CNAME record.
+func InZone(name, zone string) bool {
+	return strings.HasSuffix(canonicalName(name), "."+canonicalName(zone))
+}
+
+func canonicalName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+func pointsTo(rec *Record, target string) bool {
+	return rec.Value == NewRecord(rec.Name, target, 0).Value
+}
+
+// Update creates records of the added names and removes records of the
+// removed names pointing to the target. Names out of the zone managed by
+// the provider are ignored, and records pointing elsewhere are never
+// removed.
+func Update(p Provider, target string, ttl int, add, remove []string) error {
+	var errs mulerr.Errors
+	for _, name := range add {
+		if InZone(name, p.Zone()) {
+			errs.Add(p.SetRecord(NewRecord(name, target, ttl)))
+		}
+	}
+
+	if len(remove) != 0 {
+		removed := make(map[string]bool)
+		for _, name := range remove {
+			removed[canonicalName(name)] = true
+		}
+		records, err := p.Records()
+		if err != nil {
+			errs.Add(err)
+			return errs.Err()
+		}
+		for _, rec := range records {
+			if removed[rec.Name] && pointsTo(rec, target) {
+				errs.Add(p.RemoveRecord(rec))
+			}
+		}
+	}
+	return errs.Err()
+}
+
+// Drift describes differences between records required by the platform
+// and records in the zone.
+type Drift struct {
+	// Missing records are required but not found in the zone.
+	Missing []*Record
+
+	// Stale records point to the target but are no longer required.
+	Stale []*Record
+
+	// Conflicts are records with required names but pointing elsewhere,
+	// which are not changed automatically.
+	Conflicts []*Record
+}
+
+// Empty returns true if there are no differences.
+func (d *Drift) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Stale) == 0 && len(d.Conflicts) == 0
+}
+
+// Diff compares required records with actual records in the zone. Actual
+// records with names in the keep list, such as the wildcard record of the
+// platform domain, are never considered stale.
+func Diff(desired, actual []*Record, target string, keep []string) *Drift {
+	byName := make(map[string][]*Record)
+	for _, rec := range actual {
+		byName[rec.Name] = append(byName[rec.Name], rec)
+	}
+
+	drift := new(Drift)
+	required := make(map[string]bool)
+	for _, d := range desired {
+		required[d.Name] = true
+		found := false
+		for _, rec := range byName[d.Name] {
+			if pointsTo(rec, target) {
+				found = true
+			}
+		}
+		if !found {
+			if len(byName[d.Name]) == 0 {
+				drift.Missing = append(drift.Missing, d)
+			} else {
+				drift.Conflicts = append(drift.Conflicts, byName[d.Name]...)
+			}
+		}
+	}
+
+	for _, name := range keep {
+		required[canonicalName(name)] = true
+	}
+	for _, rec := range actual {
+		if !required[rec.Name] && pointsTo(rec, target) {
+			drift.Stale = append(drift.Stale, rec)
+		}
+	}
+
+	for _, list := range [][]*Record{drift.Missing, drift.Stale, drift.Conflicts} {
+		sort.Sort(recordsByName(list))
+	}
+	return drift
+}
+
+type recordsByName []*Record
+
+func (a recordsByName) Len() int           { return len(a) }
+func (a recordsByName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a recordsByName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+// Apply creates missing records and removes stale records. Conflicts are
+// left to administrators.
+func Apply(p Provider, drift *Drift) error {
+	var errs mulerr.Errors
+	for _, rec := range drift.Missing {
+		errs.Add(p.SetRecord(rec))
+	}
+	for _, rec := range drift.Stale {
+		errs.Add(p.RemoveRecord(rec))
+	}
+	return errs.Err()
+}
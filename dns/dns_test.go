@@ -0,0 +1,189 @@
+package dns
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeProvider keeps records in memory.
+type fakeProvider struct {
+	records []*Record
+}
+
+func (p *fakeProvider) Zone() string {
+	return "example.org"
+}
+
+func (p *fakeProvider) Records() ([]*Record, error) {
+	return p.records, nil
+}
+
+func (p *fakeProvider) SetRecord(rec *Record) error {
+	p.RemoveRecord(rec)
+	p.records = append(p.records, rec)
+	return nil
+}
+
+func (p *fakeProvider) RemoveRecord(rec *Record) error {
+	var records []*Record
+	for _, r := range p.records {
+		if r.Name != rec.Name || r.Type != rec.Type {
+			records = append(records, r)
+		}
+	}
+	p.records = records
+	return nil
+}
+
+var _ = Describe("DNS", func() {
+	It("should create address records pointing to the target", func() {
+		Ω(NewRecord("App.Example.org.", "10.0.0.1", 60)).Should(Equal(&Record{"app.example.org", "A", "10.0.0.1", 60}))
+		Ω(NewRecord("app.example.org", "2001:db8::1", 60).Type).Should(Equal("AAAA"))
+		Ω(NewRecord("*.team.example.org", "Proxy.Example.com.", 60)).Should(Equal(&Record{"*.team.example.org", "CNAME", "proxy.example.com", 60}))
+	})
+
+	It("should check names in the zone", func() {
+		Ω(InZone("app.example.org", "example.org.")).Should(BeTrue())
+		Ω(InZone("*.team.example.org", "example.org")).Should(BeTrue())
+		Ω(InZone("example.org", "example.org")).Should(BeFalse())
+		Ω(InZone("app.badexample.org", "example.org")).Should(BeFalse())
+	})
+
+	It("should update records pointing to the target only", func() {
+		p := &fakeProvider{records: []*Record{
+			{"old.example.org", "CNAME", "proxy.example.com", 300},
+			{"mail.example.org", "CNAME", "mail.example.net", 300},
+		}}
+		add := []string{"app.example.org", "app.example.net"}
+		remove := []string{"old.example.org", "mail.example.org"}
+		Ω(Update(p, "proxy.example.com", 300, add, remove)).Should(Succeed())
+		Ω(p.records).Should(ConsistOf(
+			&Record{"mail.example.org", "CNAME", "mail.example.net", 300},
+			&Record{"app.example.org", "CNAME", "proxy.example.com", 300},
+		))
+	})
+
+	It("should detect drift between required and actual records", func() {
+		desired := []*Record{
+			NewRecord("app.example.org", "10.0.0.1", 300),
+			NewRecord("*.team.example.org", "10.0.0.1", 300),
+			NewRecord("www.example.org", "10.0.0.1", 300),
+		}
+		actual := []*Record{
+			{"app.example.org", "A", "10.0.0.1", 60},
+			{"www.example.org", "A", "192.168.1.1", 300},
+			{"stale.example.org", "A", "10.0.0.1", 300},
+			{"*.example.org", "A", "10.0.0.1", 300},
+			{"other.example.org", "A", "192.168.1.2", 300},
+		}
+
+		drift := Diff(desired, actual, "10.0.0.1", []string{"*.example.org"})
+		Ω(drift.Missing).Should(Equal([]*Record{desired[1]}))
+		Ω(drift.Stale).Should(Equal([]*Record{actual[2]}))
+		Ω(drift.Conflicts).Should(Equal([]*Record{actual[1]}))
+
+		p := &fakeProvider{records: actual}
+		Ω(Apply(p, drift)).Should(Succeed())
+		drift = Diff(desired, p.records, "10.0.0.1", []string{"*.example.org"})
+		Ω(drift.Missing).Should(BeEmpty())
+		Ω(drift.Stale).Should(BeEmpty())
+		Ω(drift.Conflicts).Should(HaveLen(1))
+	})
+
+	It("should decode escaped names from Route 53", func() {
+		Ω(unescapeName(`\052.team.example.org.`)).Should(Equal("*.team.example.org."))
+		Ω(unescapeName(`app.example.org.`)).Should(Equal("app.example.org."))
+	})
+
+	Context("Cloudflare", func() {
+		var (
+			server  *httptest.Server
+			records map[string]*cloudflareRecord
+			nextID  int
+			p       *cloudflareProvider
+		)
+
+		reply := func(w http.ResponseWriter, result interface{}) {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success":     true,
+				"result":      result,
+				"result_info": map[string]int{"page": 1, "total_pages": 1},
+			})
+		}
+
+		BeforeEach(func() {
+			records = make(map[string]*cloudflareRecord)
+			nextID = 0
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Ω(r.Header.Get("Authorization")).Should(Equal("Bearer secret"))
+				path := strings.TrimPrefix(r.URL.Path, "/zones/zone1")
+				switch {
+				case path == "":
+					reply(w, map[string]string{"name": "example.org"})
+				case path == "/dns_records" && r.Method == "GET":
+					list := []*cloudflareRecord{}
+					for _, rec := range records {
+						if rec.Type == r.FormValue("type") && (r.FormValue("name") == "" || rec.Name == r.FormValue("name")) {
+							list = append(list, rec)
+						}
+					}
+					reply(w, list)
+				case path == "/dns_records" && r.Method == "POST":
+					var rec cloudflareRecord
+					json.NewDecoder(r.Body).Decode(&rec)
+					nextID++
+					rec.ID = string(rune('a' + nextID))
+					records[rec.ID] = &rec
+					reply(w, &rec)
+				case r.Method == "PUT":
+					var rec cloudflareRecord
+					json.NewDecoder(r.Body).Decode(&rec)
+					rec.ID = strings.TrimPrefix(path, "/dns_records/")
+					records[rec.ID] = &rec
+					reply(w, &rec)
+				case r.Method == "DELETE":
+					delete(records, strings.TrimPrefix(path, "/dns_records/"))
+					reply(w, nil)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+					w.Write([]byte(`{"success":false,"errors":[{"code":7003,"message":"not found"}]}`))
+				}
+			}))
+
+			p = &cloudflareProvider{endpoint: server.URL, zoneID: "zone1", token: "secret", client: http.DefaultClient}
+			Ω(p.init()).Should(Succeed())
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should manage records in the zone", func() {
+			Ω(p.Zone()).Should(Equal("example.org"))
+
+			rec := NewRecord("app.example.org", "proxy.example.com", 120)
+			Ω(p.SetRecord(rec)).Should(Succeed())
+			Ω(p.SetRecord(NewRecord("app.example.org", "proxy2.example.com", 120))).Should(Succeed())
+			Ω(records).Should(HaveLen(1))
+
+			list, err := p.Records()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(list).Should(Equal([]*Record{{"app.example.org", "CNAME", "proxy2.example.com", 120}}))
+
+			Ω(p.RemoveRecord(rec)).Should(Succeed())
+			Ω(records).Should(BeEmpty())
+		})
+
+		It("should report API errors", func() {
+			p.zoneID = "unknown"
+			err := p.init()
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring("not found"))
+		})
+	})
+})
@@ -0,0 +1,152 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	mdns "github.com/miekg/dns"
+)
+
+// The rfc2136 provider updates records with dynamic DNS updates sent to
+// the primary server of the zone, such as BIND or PowerDNS. Records are
+// listed by zone transfers. Requests are signed with the TSIG key if
+// configured, the algorithm is given by the "algorithm" query parameter
+// of the URL and defaults to hmac-sha256.
+type rfc2136Provider struct {
+	server    string
+	zone      string
+	key       string
+	secret    string
+	algorithm string
+}
+
+var tsigAlgorithms = map[string]string{
+	"hmac-md5":    mdns.HmacMD5,
+	"hmac-sha1":   mdns.HmacSHA1,
+	"hmac-sha256": mdns.HmacSHA256,
+	"hmac-sha512": mdns.HmacSHA512,
+}
+
+func init() {
+	providerRegistry["rfc2136"] = func(u *url.URL, key, secret string) (Provider, error) {
+		p := &rfc2136Provider{
+			server: u.Host,
+			zone:   canonicalName(strings.Trim(u.Path, "/")),
+			secret: secret,
+		}
+		if p.server == "" || p.zone == "" {
+			return nil, fmt.Errorf("Invalid RFC 2136 provider URL, must be rfc2136://server[:port]/zone")
+		}
+		if _, _, err := net.SplitHostPort(p.server); err != nil {
+			p.server = net.JoinHostPort(p.server, "53")
+		}
+
+		if key != "" {
+			p.key = mdns.Fqdn(strings.ToLower(key))
+			alg := u.Query().Get("algorithm")
+			if alg == "" {
+				alg = "hmac-sha256"
+			}
+			if p.algorithm = tsigAlgorithms[strings.ToLower(alg)]; p.algorithm == "" {
+				return nil, fmt.Errorf("Unsupported TSIG algorithm: %s", alg)
+			}
+		}
+		return p, nil
+	}
+}
+
+func (p *rfc2136Provider) Zone() string {
+	return p.zone
+}
+
+func (p *rfc2136Provider) Records() ([]*Record, error) {
+	m := new(mdns.Msg)
+	m.SetAxfr(mdns.Fqdn(p.zone))
+	t := new(mdns.Transfer)
+	if p.key != "" {
+		m.SetTsig(p.key, p.algorithm, 300, time.Now().Unix())
+		t.TsigSecret = map[string]string{p.key: p.secret}
+	}
+
+	env, err := t.In(m, p.server)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*Record
+	for e := range env {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		for _, rr := range e.RR {
+			rec := &Record{
+				Name: canonicalName(rr.Header().Name),
+				Type: mdns.TypeToString[rr.Header().Rrtype],
+				TTL:  int(rr.Header().Ttl),
+			}
+			switch r := rr.(type) {
+			case *mdns.A:
+				rec.Value = r.A.String()
+			case *mdns.AAAA:
+				rec.Value = r.AAAA.String()
+			case *mdns.CNAME:
+				rec.Value = canonicalName(r.Target)
+			default:
+				continue
+			}
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+func (p *rfc2136Provider) SetRecord(rec *Record) error {
+	rr, err := p.newRR(rec)
+	if err != nil {
+		return err
+	}
+	m := new(mdns.Msg)
+	m.SetUpdate(mdns.Fqdn(p.zone))
+	m.RemoveRRset([]mdns.RR{rr})
+	m.Insert([]mdns.RR{rr})
+	return p.exchange(m)
+}
+
+func (p *rfc2136Provider) RemoveRecord(rec *Record) error {
+	rr, err := p.newRR(rec)
+	if err != nil {
+		return err
+	}
+	m := new(mdns.Msg)
+	m.SetUpdate(mdns.Fqdn(p.zone))
+	m.RemoveRRset([]mdns.RR{rr})
+	return p.exchange(m)
+}
+
+func (p *rfc2136Provider) newRR(rec *Record) (mdns.RR, error) {
+	value := rec.Value
+	if rec.Type == "CNAME" {
+		value = mdns.Fqdn(value)
+	}
+	return mdns.NewRR(fmt.Sprintf("%s %d IN %s %s", mdns.Fqdn(rec.Name), rec.TTL, rec.Type, value))
+}
+
+func (p *rfc2136Provider) exchange(m *mdns.Msg) error {
+	c := new(mdns.Client)
+	if p.key != "" {
+		m.SetTsig(p.key, p.algorithm, 300, time.Now().Unix())
+		c.TsigSecret = map[string]string{p.key: p.secret}
+	}
+
+	r, _, err := c.Exchange(m, p.server)
+	if err != nil {
+		return err
+	}
+	if r.Rcode != mdns.RcodeSuccess {
+		return fmt.Errorf("DNS update of %s refused: %s", p.zone, mdns.RcodeToString[r.Rcode])
+	}
+	return nil
+}
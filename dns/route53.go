@@ -0,0 +1,235 @@
+package dns
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/pkg/s3"
+)
+
+const (
+	route53Endpoint = "https://route53.amazonaws.com"
+	route53Version  = "2013-04-01"
+	route53Xmlns    = "https://route53.amazonaws.com/doc/2013-04-01/"
+)
+
+// The route53 provider manages records in a hosted zone of Amazon Route 53.
+// Requests are signed with the access key, which must be permitted to list
+// and change record sets of the hosted zone. Alias records are ignored.
+type route53Provider struct {
+	zoneID string
+	zone   string
+	signer *s3.Client
+}
+
+func init() {
+	providerRegistry["route53"] = func(u *url.URL, key, secret string) (Provider, error) {
+		p := &route53Provider{
+			zoneID: u.Host,
+			signer: &s3.Client{
+				Endpoint:  route53Endpoint,
+				Region:    "us-east-1",
+				Service:   "route53",
+				AccessKey: key,
+				SecretKey: secret,
+			},
+		}
+		if err := p.init(); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+}
+
+type route53RecordSet struct {
+	Name            string    `xml:"Name"`
+	Type            string    `xml:"Type"`
+	TTL             int       `xml:"TTL,omitempty"`
+	ResourceRecords []string  `xml:"ResourceRecords>ResourceRecord>Value"`
+	AliasTarget     *struct{} `xml:"AliasTarget"`
+}
+
+type route53ListResponse struct {
+	ResourceRecordSets []*route53RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+	IsTruncated        bool                `xml:"IsTruncated"`
+	NextRecordName     string              `xml:"NextRecordName"`
+	NextRecordType     string              `xml:"NextRecordType"`
+}
+
+type route53Change struct {
+	Action            string            `xml:"Action"`
+	ResourceRecordSet *route53RecordSet `xml:"ResourceRecordSet"`
+}
+
+type route53ChangeRequest struct {
+	XMLName xml.Name         `xml:"ChangeResourceRecordSetsRequest"`
+	Xmlns   string           `xml:"xmlns,attr"`
+	Changes []*route53Change `xml:"ChangeBatch>Changes>Change"`
+}
+
+type route53Error struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// init looks up the zone name.
+func (p *route53Provider) init() error {
+	var resp struct {
+		Name string `xml:"HostedZone>Name"`
+	}
+	if err := p.do("GET", "/hostedzone/"+p.zoneID, nil, nil, &resp); err != nil {
+		return err
+	}
+	p.zone = canonicalName(unescapeName(resp.Name))
+	return nil
+}
+
+func (p *route53Provider) Zone() string {
+	return p.zone
+}
+
+func (p *route53Provider) Records() ([]*Record, error) {
+	sets, err := p.list(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*Record
+	for _, set := range sets {
+		for _, value := range set.ResourceRecords {
+			records = append(records, &Record{
+				Name:  canonicalName(unescapeName(set.Name)),
+				Type:  set.Type,
+				Value: canonicalName(value),
+				TTL:   set.TTL,
+			})
+		}
+	}
+	return records, nil
+}
+
+func (p *route53Provider) SetRecord(rec *Record) error {
+	return p.change("UPSERT", &route53RecordSet{
+		Name:            rec.Name,
+		Type:            rec.Type,
+		TTL:             rec.TTL,
+		ResourceRecords: []string{rec.Value},
+	})
+}
+
+// RemoveRecord removes the record set with the name and type, which must
+// be given exactly as it exists to be deleted.
+func (p *route53Provider) RemoveRecord(rec *Record) error {
+	name := strings.Replace(rec.Name, "*", `\052`, -1)
+	query := url.Values{"name": {name}, "type": {rec.Type}, "maxitems": {"1"}}
+	sets, err := p.list(query)
+	if err != nil {
+		return err
+	}
+	for _, set := range sets {
+		if canonicalName(unescapeName(set.Name)) == rec.Name && set.Type == rec.Type {
+			return p.change("DELETE", set)
+		}
+	}
+	return nil
+}
+
+// list returns address record sets, following all result pages unless
+// the maximum items is given in the query.
+func (p *route53Provider) list(query url.Values) ([]*route53RecordSet, error) {
+	var sets []*route53RecordSet
+	for {
+		var resp route53ListResponse
+		if err := p.do("GET", "/hostedzone/"+p.zoneID+"/rrset", query, nil, &resp); err != nil {
+			return nil, err
+		}
+		for _, set := range resp.ResourceRecordSets {
+			if set.AliasTarget == nil && (set.Type == "A" || set.Type == "AAAA" || set.Type == "CNAME") {
+				sets = append(sets, set)
+			}
+		}
+		if !resp.IsTruncated || query.Get("maxitems") != "" {
+			return sets, nil
+		}
+		query = url.Values{"name": {resp.NextRecordName}, "type": {resp.NextRecordType}}
+	}
+}
+
+func (p *route53Provider) change(action string, set *route53RecordSet) error {
+	req := &route53ChangeRequest{
+		Xmlns:   route53Xmlns,
+		Changes: []*route53Change{{Action: action, ResourceRecordSet: set}},
+	}
+	return p.do("POST", "/hostedzone/"+p.zoneID+"/rrset", nil, req, nil)
+}
+
+func (p *route53Provider) do(method, path string, query url.Values, body, result interface{}) error {
+	var payload []byte
+	if body != nil {
+		buf, err := xml.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = append([]byte(xml.Header), buf...)
+	}
+
+	rawurl := route53Endpoint + "/" + route53Version + path
+	if len(query) != 0 {
+		rawurl += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, rawurl, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "text/xml")
+	}
+	sum := sha256.Sum256(payload)
+	p.signer.Sign(req, hex.EncodeToString(sum[:]), time.Now())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		var e route53Error
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		if xml.Unmarshal(data, &e) != nil || e.Message == "" {
+			e.Message = strings.TrimSpace(string(data))
+		}
+		return fmt.Errorf("Route 53 error %d: %s", resp.StatusCode, e.Message)
+	}
+	if result != nil {
+		return xml.NewDecoder(resp.Body).Decode(result)
+	}
+	return nil
+}
+
+// unescapeName decodes octal escapes in names returned by Route 53, such
+// as "\052" for the asterisk of wildcard names.
+func unescapeName(name string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+3 < len(name) {
+			if c, err := strconv.ParseUint(name[i+1:i+4], 8, 8); err == nil {
+				buf.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		buf.WriteByte(name[i])
+	}
+	return buf.String()
+}
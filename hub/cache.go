@@ -0,0 +1,58 @@
+package hub
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/cloudway/platform/pkg/archive"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// pluginCache caches plugin manifests by installation path, so that hot
+// paths don't repeatedly parse manifests from disk. Entries are invalidated
+// when plugins are installed or removed through the hub.
+type pluginCache struct {
+	mu      sync.RWMutex
+	plugins map[string]*manifest.Plugin
+}
+
+func newPluginCache() *pluginCache {
+	return &pluginCache{plugins: make(map[string]*manifest.Plugin)}
+}
+
+// load returns the manifest of plugin installed at the given path, reading
+// it from disk if it's not cached. A copy is returned so that callers may
+// modify it freely.
+func (c *pluginCache) load(path string) (*manifest.Plugin, error) {
+	c.mu.RLock()
+	plugin, ok := c.plugins[path]
+	c.mu.RUnlock()
+
+	if !ok {
+		var err error
+		if plugin, err = archive.ReadManifest(path); err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.plugins[path] = plugin
+		c.mu.Unlock()
+	}
+
+	copy := *plugin
+	return &copy, nil
+}
+
+// invalidate removes cached manifests for all plugins installed under
+// the given directory.
+func (c *pluginCache) invalidate(dir string) {
+	prefix := dir + string(os.PathSeparator)
+
+	c.mu.Lock()
+	for path := range c.plugins {
+		if path == dir || strings.HasPrefix(path, prefix) {
+			delete(c.plugins, path)
+		}
+	}
+	c.mu.Unlock()
+}
@@ -15,14 +15,24 @@ import (
 
 type PluginHub struct {
 	installDir string
+	cache      *pluginCache
 }
 
 func New() (*PluginHub, error) {
-	dir := config.GetOrDefault("hub.dir", "/var/lib/cloudway/plugins")
+	return NewAt(config.GetOrDefault("hub.dir", "/var/lib/cloudway/plugins"))
+}
+
+// NewAt creates a plugin hub with plugins installed in the given directory.
+func NewAt(dir string) (*PluginHub, error) {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, err
 	}
-	return &PluginHub{dir}, nil
+	return &PluginHub{installDir: dir, cache: newPluginCache()}, nil
+}
+
+// InstallDir returns the directory where plugins are installed.
+func (hub *PluginHub) InstallDir() string {
+	return hub.installDir
 }
 
 func (hub *PluginHub) ListPlugins(namespace string, category manifest.Category) []*manifest.Plugin {
@@ -85,8 +95,11 @@ func (hub *PluginHub) GetPluginInfo(tag string) (*manifest.Plugin, error) {
 	if err != nil {
 		return nil, err
 	} else {
-		plugin, err := archive.ReadManifest(path)
-		return tagged(namespace, plugin), err
+		plugin, err := hub.cache.load(path)
+		if err != nil {
+			return nil, err
+		}
+		return tagged(namespace, plugin), nil
 	}
 }
 
@@ -117,6 +130,7 @@ func (hub *PluginHub) InstallPlugin(namespace string, path string) (err error) {
 	}
 
 	installDir := hub.getBaseDir(namespace, meta.Name, meta.Version)
+	defer hub.cache.invalidate(installDir)
 	if err = os.RemoveAll(installDir); err != nil {
 		return err
 	}
@@ -140,6 +154,7 @@ func (hub *PluginHub) RemovePlugin(tag string) error {
 	if _, err := os.Stat(dir); err != nil {
 		return err
 	}
+	defer hub.cache.invalidate(dir)
 	return os.RemoveAll(dir)
 }
 
@@ -147,7 +162,22 @@ func (hub *PluginHub) RemoveNamespace(namespace string) {
 	if namespace == "" || namespace == "_" {
 		return
 	}
-	os.RemoveAll(filepath.Join(hub.installDir, namespace))
+	dir := filepath.Join(hub.installDir, namespace)
+	os.RemoveAll(dir)
+	hub.cache.invalidate(dir)
+}
+
+// RenameNamespace moves user defined plugins to the new namespace.
+func (hub *PluginHub) RenameNamespace(oldNamespace, newNamespace string) error {
+	if oldNamespace == "" || oldNamespace == "_" || newNamespace == "" || newNamespace == "_" {
+		return nil
+	}
+	olddir := filepath.Join(hub.installDir, oldNamespace)
+	if _, err := os.Stat(olddir); os.IsNotExist(err) {
+		return nil
+	}
+	defer hub.cache.invalidate(olddir)
+	return os.Rename(olddir, filepath.Join(hub.installDir, newNamespace))
 }
 
 func (hub *PluginHub) getBaseDir(namespace, name, version string) string {
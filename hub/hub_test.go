@@ -12,6 +12,7 @@ import (
 
 	"gopkg.in/yaml.v2"
 
+	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/pkg/manifest"
 )
 
@@ -23,13 +24,20 @@ func TestHub(t *testing.T) {
 var pluginHub *PluginHub
 
 var _ = BeforeSuite(func() {
+	// start with an empty configuration in a temporary root directory
+	rootdir, err := ioutil.TempDir("", "hubroot")
+	Ω(err).ShouldNot(HaveOccurred())
+	os.Setenv("CLOUDWAY_ROOT", rootdir)
+	Ω(config.Initialize()).Should(Succeed())
+
 	testdir, err := ioutil.TempDir("", "hub")
 	Ω(err).ShouldNot(HaveOccurred())
-	pluginHub = &PluginHub{testdir}
+	pluginHub = &PluginHub{installDir: testdir, cache: newPluginCache()}
 })
 
 var _ = AfterSuite(func() {
 	os.RemoveAll(pluginHub.installDir)
+	os.RemoveAll(config.RootDir)
 })
 
 func emptyTestDir() error {
@@ -211,6 +219,33 @@ var _ = Describe("Hub", func() {
 			Ω(plugin.BaseImage).Should(Equal(meta.BaseImage))
 		})
 
+		It("should return updated information after reinstall", func() {
+			install("", meta)
+
+			plugin, err := pluginHub.GetPluginInfo("mock")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(plugin.DisplayName).Should(Equal("Mock plugin"))
+
+			meta.DisplayName = "Updated mock plugin"
+			install("", meta)
+
+			plugin, err = pluginHub.GetPluginInfo("mock")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(plugin.DisplayName).Should(Equal("Updated mock plugin"))
+		})
+
+		It("should not share cached information between callers", func() {
+			install("", meta)
+
+			plugin, err := pluginHub.GetPluginInfo("mock")
+			Ω(err).ShouldNot(HaveOccurred())
+			plugin.DisplayName = "Modified"
+
+			plugin, err = pluginHub.GetPluginInfo("mock")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(plugin.DisplayName).Should(Equal(meta.DisplayName))
+		})
+
 		It("should fail if the plugin not found", func() {
 			install("", meta)
 
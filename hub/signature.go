@@ -0,0 +1,163 @@
+package hub
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/cloudway/platform/config"
+)
+
+// Plugin packages are signed with ed25519 keys. The signature is computed
+// over the SHA-256 digest of the plugin tarball, and saved base64 encoded in
+// a detached signature file next to the tarball. Signatures are verified
+// against public keys in the trust store configured by "hub.trusted_keys",
+// one base64 encoded key per line. If the trust store is configured, plugins
+// with invalid signatures are always refused, unsigned plugins are refused
+// unless allowed by "hub.allow_unsigned" or explicitly by the installer.
+
+// SignatureExt is the file extension of detached plugin signatures.
+const SignatureExt = ".sig"
+
+// GenerateSigningKey generates a new key pair to sign plugins. Returns the
+// base64 encoded private and public keys.
+func GenerateSigningKey() (privateKey, publicKey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	enc := base64.StdEncoding
+	return enc.EncodeToString(priv), enc.EncodeToString(pub), nil
+}
+
+// SignPlugin signs the plugin tarball with the base64 encoded private key,
+// returns the base64 encoded signature.
+func SignPlugin(path string, privateKey string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(privateKey))
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		return "", fmt.Errorf("invalid plugin signing key")
+	}
+	digest, err := pluginDigest(path)
+	if err != nil {
+		return "", err
+	}
+	sig := ed25519.Sign(ed25519.PrivateKey(key), digest)
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// ReadSignature reads the detached signature of the plugin tarball. Returns
+// an empty string if the plugin is not signed.
+func ReadSignature(path string) (string, error) {
+	sig, err := ioutil.ReadFile(path + SignatureExt)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return strings.TrimSpace(string(sig)), err
+}
+
+// VerifyPlugin verifies the base64 encoded signature of the plugin tarball
+// against the trust store. Unsigned plugins are accepted if allowUnsigned
+// is true or allowed by the configuration. Verification is skipped if the
+// trust store is not configured.
+func VerifyPlugin(path string, signature string, allowUnsigned bool) error {
+	keys, err := trustedKeys()
+	if err != nil || keys == nil {
+		return err
+	}
+
+	if signature == "" {
+		if allowUnsigned || allowUnsignedPlugins() {
+			return nil
+		}
+		return signatureError("plugin is not signed")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return signatureError("malformed plugin signature")
+	}
+	if fi, err := os.Stat(path); err != nil {
+		return err
+	} else if fi.IsDir() {
+		return signatureError("plugin directory cannot be signed, install the plugin tarball instead")
+	}
+
+	digest, err := pluginDigest(path)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if ed25519.Verify(key, digest, sig) {
+			return nil
+		}
+	}
+	return signatureError("plugin signature verification failed")
+}
+
+func pluginDigest(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// trustedKeys loads public keys from the trust store. Returns nil if the
+// trust store is not configured.
+func trustedKeys() ([]ed25519.PublicKey, error) {
+	path := config.Get("hub.trusted_keys")
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	keys := []ed25519.PublicKey{}
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%s:%d: invalid public key", path, lineno)
+		}
+		keys = append(keys, ed25519.PublicKey(key))
+	}
+	return keys, scanner.Err()
+}
+
+func allowUnsignedPlugins() bool {
+	b, _ := strconv.ParseBool(config.Get("hub.allow_unsigned"))
+	return b
+}
+
+type signatureError string
+
+func (e signatureError) Error() string {
+	return string(e)
+}
+
+func (e signatureError) HTTPErrorStatusCode() int {
+	return http.StatusForbidden
+}
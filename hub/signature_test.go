@@ -0,0 +1,75 @@
+package hub
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/config"
+)
+
+var _ = Describe("Plugin signature", func() {
+	var (
+		tarball, keys string
+		privateKey    string
+	)
+
+	BeforeEach(func() {
+		f, err := ioutil.TempFile("", "plugin")
+		Ω(err).ShouldNot(HaveOccurred())
+		f.WriteString("plugin content")
+		f.Close()
+		tarball = f.Name()
+
+		var publicKey string
+		privateKey, publicKey, err = GenerateSigningKey()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		f, err = ioutil.TempFile("", "keys")
+		Ω(err).ShouldNot(HaveOccurred())
+		f.WriteString("# trusted keys\n" + publicKey + "\n")
+		f.Close()
+		keys = f.Name()
+		config.Set("hub.trusted_keys", keys)
+	})
+
+	AfterEach(func() {
+		config.Set("hub.trusted_keys", "")
+		os.Remove(tarball)
+		os.Remove(keys)
+	})
+
+	It("should accept plugins signed with trusted keys", func() {
+		sig, err := SignPlugin(tarball, privateKey)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(VerifyPlugin(tarball, sig, false)).Should(Succeed())
+	})
+
+	It("should refuse plugins signed with untrusted keys", func() {
+		otherKey, _, err := GenerateSigningKey()
+		Ω(err).ShouldNot(HaveOccurred())
+		sig, err := SignPlugin(tarball, otherKey)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(VerifyPlugin(tarball, sig, false)).ShouldNot(Succeed())
+	})
+
+	It("should refuse tampered plugins", func() {
+		sig, err := SignPlugin(tarball, privateKey)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(ioutil.WriteFile(tarball, []byte("tampered content"), 0644)).Should(Succeed())
+		Ω(VerifyPlugin(tarball, sig, false)).ShouldNot(Succeed())
+		Ω(VerifyPlugin(tarball, sig, true)).ShouldNot(Succeed())
+	})
+
+	It("should refuse unsigned plugins unless explicitly allowed", func() {
+		Ω(VerifyPlugin(tarball, "", false)).ShouldNot(Succeed())
+		Ω(VerifyPlugin(tarball, "", true)).Should(Succeed())
+	})
+
+	It("should skip verification if the trust store is not configured", func() {
+		config.Set("hub.trusted_keys", "")
+		Ω(VerifyPlugin(tarball, "", false)).Should(Succeed())
+	})
+})
@@ -0,0 +1,108 @@
+package archive
+
+import (
+	"archive/tar"
+	"io"
+	"path"
+	"strings"
+)
+
+// Filter selects entries in a tar archive by name patterns. The patterns
+// use the syntax of path.Match and are matched against the entry name and
+// every trailing part of it, so that "*.conf" matches "etc/app.conf" and
+// "conf/app.conf" matches "home/etc/conf/app.conf". A directory pattern
+// matches all entries below the directory.
+type Filter struct {
+	Includes []string
+	Excludes []string
+}
+
+// IsEmpty returns true if the filter has no patterns.
+func (f *Filter) IsEmpty() bool {
+	return f == nil || (len(f.Includes) == 0 && len(f.Excludes) == 0)
+}
+
+// Match reports whether the entry with given name is selected by the
+// filter. Excludes take precedence over includes. Directories are not
+// affected by include patterns so that files below them can be selected.
+func (f *Filter) Match(name string, isDir bool) bool {
+	if f.IsEmpty() {
+		return true
+	}
+
+	name = strings.Trim(path.Clean("/"+name), "/")
+	if matchAny(f.Excludes, name) {
+		return false
+	}
+	if len(f.Includes) == 0 || isDir {
+		return true
+	}
+	return matchAny(f.Includes, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.Trim(pattern, "/")
+		for p := name; p != ""; {
+			if matchPrefix(pattern, p) {
+				return true
+			}
+			if i := strings.IndexByte(p, '/'); i >= 0 {
+				p = p[i+1:]
+			} else {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// matchPrefix reports whether the pattern matches the name or one of its
+// parent directories.
+func matchPrefix(pattern, name string) bool {
+	for {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		i := strings.LastIndexByte(name, '/')
+		if i < 0 {
+			return false
+		}
+		name = name[:i]
+	}
+}
+
+// FilterFiles copies entries selected by the filter from a tar archive to
+// a new tar archive.
+func FilterFiles(r io.Reader, filter *Filter) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(filterFiles(pw, r, filter))
+	}()
+	return pr
+}
+
+func filterFiles(w io.Writer, r io.Reader, filter *Filter) error {
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if !filter.Match(hdr.Name, hdr.Typeflag == tar.TypeDir) {
+			continue
+		}
+		if err = tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err = io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
@@ -0,0 +1,74 @@
+package archive_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	. "github.com/cloudway/platform/pkg/archive"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Filter", func() {
+	It("should match everything if empty", func() {
+		var f *Filter
+		Expect(f.Match("etc/app.conf", false)).To(BeTrue())
+		Expect((&Filter{}).Match("etc/app.conf", false)).To(BeTrue())
+	})
+
+	It("should match base name with include patterns", func() {
+		f := &Filter{Includes: []string{"*.conf"}}
+		Expect(f.Match("etc/app.conf", false)).To(BeTrue())
+		Expect(f.Match("etc/app.yml", false)).To(BeFalse())
+		Expect(f.Match("etc", true)).To(BeTrue())
+	})
+
+	It("should match relative path with include patterns", func() {
+		f := &Filter{Includes: []string{"conf/*.yml"}}
+		Expect(f.Match("home/conf/app.yml", false)).To(BeTrue())
+		Expect(f.Match("home/etc/app.yml", false)).To(BeFalse())
+	})
+
+	It("should exclude all entries below excluded directory", func() {
+		f := &Filter{Excludes: []string{"node_modules"}}
+		Expect(f.Match("repo/node_modules", true)).To(BeFalse())
+		Expect(f.Match("repo/node_modules/lib/index.js", false)).To(BeFalse())
+		Expect(f.Match("repo/index.js", false)).To(BeTrue())
+	})
+
+	It("should give precedence to exclude patterns", func() {
+		f := &Filter{Includes: []string{"*.conf"}, Excludes: []string{"secret.conf"}}
+		Expect(f.Match("etc/app.conf", false)).To(BeTrue())
+		Expect(f.Match("etc/secret.conf", false)).To(BeFalse())
+	})
+
+	It("should filter tar archive", func() {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, name := range []string{"etc/app.conf", "etc/app.yml", "etc/secret.conf"} {
+			Expect(AddFile(tw, name, 0644, []byte(name))).To(Succeed())
+		}
+		Expect(tw.Close()).To(Succeed())
+
+		f := &Filter{Includes: []string{"*.conf"}, Excludes: []string{"secret.conf"}}
+		r := FilterFiles(&buf, f)
+		defer r.Close()
+
+		var names []string
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).NotTo(HaveOccurred())
+			content, err := ioutil.ReadAll(tr)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal(hdr.Name))
+			names = append(names, hdr.Name)
+		}
+		Expect(names).To(Equal([]string{"etc/app.conf"}))
+	})
+})
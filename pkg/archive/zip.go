@@ -0,0 +1,126 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// FromZip converts a zip archive to a tar archive. Symbolic links are
+// skipped, and entries with absolute paths or parent directory references
+// are rejected.
+func FromZip(r io.ReaderAt, size int64) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(zipToTar(pw, r, size))
+	}()
+	return pr
+}
+
+func zipToTar(w io.Writer, r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	for _, f := range zr.File {
+		fi := f.FileInfo()
+		if !fi.Mode().IsRegular() && !fi.IsDir() {
+			continue // symbolic links and special files are not supported
+		}
+
+		name := path.Clean(f.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("Invalid file name in zip archive: %s", f.Name)
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+		if err = tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// ToZip converts a tar archive to a zip archive written to w. Regular files,
+// directories and symbolic links are converted, other entries are skipped.
+// Files are deflated at the compress/flate level, or stored if the level is
+// flate.NoCompression.
+func ToZip(w io.Writer, r io.Reader, level int) error {
+	tr := tar.NewReader(r)
+	zw := zip.NewWriter(w)
+	zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, level)
+	})
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		fi := hdr.FileInfo()
+		if !fi.Mode().IsRegular() && !fi.IsDir() && fi.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		name := strings.TrimPrefix(path.Clean(hdr.Name), "/")
+		if name == "." || name == "" {
+			continue
+		}
+
+		zh, err := zip.FileInfoHeader(fi)
+		if err != nil {
+			return err
+		}
+		zh.Name = name
+		if fi.IsDir() {
+			zh.Name += "/"
+		} else if level != flate.NoCompression {
+			zh.Method = zip.Deflate
+		}
+
+		fw, err := zw.CreateHeader(zh)
+		if err != nil {
+			return err
+		}
+		switch {
+		case fi.Mode()&os.ModeSymlink != 0:
+			_, err = io.WriteString(fw, hdr.Linkname)
+		case fi.Mode().IsRegular():
+			_, err = io.Copy(fw, tr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,92 @@
+package archive_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"io"
+	"io/ioutil"
+	"os"
+
+	. "github.com/cloudway/platform/pkg/archive"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Zip", func() {
+	createZip := func(names ...string) *bytes.Reader {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for _, name := range names {
+			w, err := zw.Create(name)
+			Expect(err).NotTo(HaveOccurred())
+			if name[len(name)-1] != '/' {
+				w.Write([]byte(name))
+			}
+		}
+		Expect(zw.Close()).To(Succeed())
+		return bytes.NewReader(buf.Bytes())
+	}
+
+	It("should convert zip archive to tar archive", func() {
+		zr := createZip("bin/", "bin/app", "lib/app.jar")
+		r := FromZip(zr, zr.Size())
+		defer r.Close()
+
+		var names []string
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).NotTo(HaveOccurred())
+			if hdr.Typeflag != tar.TypeDir {
+				content, err := ioutil.ReadAll(tr)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal(hdr.Name))
+			}
+			names = append(names, hdr.Name)
+		}
+		Expect(names).To(Equal([]string{"bin/", "bin/app", "lib/app.jar"}))
+	})
+
+	It("should reject entries outside of the archive", func() {
+		zr := createZip("../etc/passwd")
+		r := FromZip(zr, zr.Size())
+		defer r.Close()
+
+		_, err := ioutil.ReadAll(r)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should convert tar archive to zip archive", func() {
+		var tarBuf bytes.Buffer
+		tw := tar.NewWriter(&tarBuf)
+		Expect(tw.WriteHeader(&tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0755})).To(Succeed())
+		Expect(tw.WriteHeader(&tar.Header{Name: "bin/", Typeflag: tar.TypeDir, Mode: 0755})).To(Succeed())
+		Expect(tw.WriteHeader(&tar.Header{Name: "bin/app", Typeflag: tar.TypeReg, Mode: 0755, Size: 7})).To(Succeed())
+		tw.Write([]byte("bin/app"))
+		Expect(tw.WriteHeader(&tar.Header{Name: "app", Typeflag: tar.TypeSymlink, Linkname: "bin/app", Mode: 0777})).To(Succeed())
+		Expect(tw.Close()).To(Succeed())
+
+		var zipBuf bytes.Buffer
+		Expect(ToZip(&zipBuf, &tarBuf, flate.DefaultCompression)).To(Succeed())
+
+		zr, err := zip.NewReader(bytes.NewReader(zipBuf.Bytes()), int64(zipBuf.Len()))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(zr.File).To(HaveLen(3))
+		Expect(zr.File[0].Name).To(Equal("bin/"))
+		Expect(zr.File[1].Name).To(Equal("bin/app"))
+		Expect(zr.File[2].Name).To(Equal("app"))
+		Expect(zr.File[2].Mode() & os.ModeSymlink).NotTo(BeZero())
+
+		rc, err := zr.File[1].Open()
+		Expect(err).NotTo(HaveOccurred())
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("bin/app"))
+	})
+})
@@ -18,12 +18,19 @@ func (e Errors) Error() string {
 	return buf.String()
 }
 
-func (e Errors) Add(err error) {
+// Add adds an error to the composite error. Nil errors are ignored.
+func (e *Errors) Add(err error) {
 	if err != nil {
 		e.errors = append(e.errors, err)
 	}
 }
 
+// Errors returns all errors that have been added.
+func (e Errors) Errors() []error {
+	return e.errors
+}
+
+// Err returns the composite error, or nil if no error has been added.
 func (e Errors) Err() error {
 	if len(e.errors) != 0 {
 		return e
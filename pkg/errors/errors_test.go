@@ -0,0 +1,30 @@
+package errors_test
+
+import (
+	stderrors "errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/pkg/errors"
+)
+
+var _ = Describe("Errors", func() {
+	It("should return nil if no error added", func() {
+		var errs errors.Errors
+		errs.Add(nil)
+		Expect(errs.Err()).To(BeNil())
+	})
+
+	It("should accumulate errors", func() {
+		var errs errors.Errors
+		errs.Add(stderrors.New("first"))
+		errs.Add(nil)
+		errs.Add(stderrors.New("second"))
+
+		err := errs.Err()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("first\nsecond\n"))
+		Expect(errs.Errors()).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,103 @@
+package manifest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// AppManifestFile is the name of the application manifest file in the
+// root of application repository.
+const AppManifestFile = "cloudway.yml"
+
+// AppManifest declares the configuration of an application in the
+// application repository, such as:
+//
+//   framework: php
+//   services: [mysql, redis]
+//   scaling: 2
+//   env:
+//     APP_ENV: production
+//   hooks:
+//     post-deploy: php artisan migrate
+type AppManifest struct {
+	Framework string            `yaml:"framework,omitempty"`
+	Services  []string          `yaml:"services,omitempty"`
+	Scaling   int               `yaml:"scaling,omitempty"`
+	Env       map[string]string `yaml:"env,omitempty"`
+	Hooks     map[string]string `yaml:"hooks,omitempty"`
+}
+
+// Lifecycle hooks that can be declared in the application manifest.
+var AppHooks = []string{"pre-deploy", "post-deploy", "pre-restart"}
+
+// maxScaling is the maximum number of framework containers of an application.
+const maxScaling = 10
+
+var appEnvKeyPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z_0-9]*$`)
+
+// LoadAppManifest loads the application manifest from the given file.
+func LoadAppManifest(filename string) (*AppManifest, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadAppManifest(f)
+}
+
+// ReadAppManifest reads and validates the application manifest.
+func ReadAppManifest(r io.Reader) (*AppManifest, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &AppManifest{}
+	if err = yaml.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%s: %v", AppManifestFile, err)
+	}
+	if err = m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Validate checks the syntax of the application manifest. Plugins are not
+// checked as the manifest may be read without access to the plugin hub.
+func (m *AppManifest) Validate() error {
+	for _, tag := range m.Services {
+		if tag == "" || strings.ContainsAny(tag, " \t\r\n") {
+			return fmt.Errorf("%s: invalid service plugin: %q", AppManifestFile, tag)
+		}
+	}
+	if m.Scaling < 0 || m.Scaling > maxScaling {
+		return fmt.Errorf("%s: scaling must be between 1 and %d", AppManifestFile, maxScaling)
+	}
+	for key := range m.Env {
+		if !appEnvKeyPattern.MatchString(key) {
+			return fmt.Errorf("%s: invalid environment variable key: %q", AppManifestFile, key)
+		}
+	}
+	for name := range m.Hooks {
+		if !isAppHook(name) {
+			return fmt.Errorf("%s: unknown hook %q, must be one of %s",
+				AppManifestFile, name, strings.Join(AppHooks, ", "))
+		}
+	}
+	return nil
+}
+
+func isAppHook(name string) bool {
+	for _, h := range AppHooks {
+		if h == name {
+			return true
+		}
+	}
+	return false
+}
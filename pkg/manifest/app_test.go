@@ -0,0 +1,44 @@
+package manifest_test
+
+import (
+	"strings"
+
+	. "github.com/cloudway/platform/pkg/manifest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AppManifest", func() {
+	It("should read application manifest", func() {
+		m, err := ReadAppManifest(strings.NewReader(`
+framework: php
+services: [mysql, redis]
+scaling: 2
+env:
+  APP_ENV: production
+hooks:
+  post-deploy: php artisan migrate
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Framework).To(Equal("php"))
+		Expect(m.Services).To(Equal([]string{"mysql", "redis"}))
+		Expect(m.Scaling).To(Equal(2))
+		Expect(m.Env).To(HaveKeyWithValue("APP_ENV", "production"))
+		Expect(m.Hooks).To(HaveKeyWithValue("post-deploy", "php artisan migrate"))
+	})
+
+	It("should reject invalid scaling", func() {
+		_, err := ReadAppManifest(strings.NewReader("scaling: 11"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject invalid environment variable key", func() {
+		_, err := ReadAppManifest(strings.NewReader("env:\n  1KEY: value"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject unknown hook", func() {
+		_, err := ReadAppManifest(strings.NewReader("hooks:\n  post-build: make"))
+		Expect(err).To(HaveOccurred())
+	})
+})
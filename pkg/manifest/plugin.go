@@ -36,21 +36,43 @@ func (cat Category) IsLibrary() bool {
 }
 
 type Plugin struct {
-	Path        string      `yaml:"-" json:",omitempty"`
-	Tag         string      `yaml:"-" json:",omitempty"`
-	Name        string      `yaml:"Name"`
-	DisplayName string      `yaml:"Display-Name"`
-	Description string      `yaml:"Description,omitempty"`
-	Version     string      `yaml:"Version"`
-	Vendor      string      `yaml:"Vendor"`
-	Shared      bool        `yaml:"Shared,omitempty" json:",omitempty"`
-	Logo        string      `yaml:"Logo,omitempty" json:",omitempty"`
-	Category    Category    `yaml:"Category"`
-	BaseImage   string      `yaml:"Base-Image"`
-	BuildCache  []string    `yaml:"Build-Cache" json:",omitempty"`
-	DependsOn   []string    `yaml:"Depends-On,omitempty" json:",omitempty"`
-	User        string      `yaml:"User,omitempty" json:",omitempty"`
-	Endpoints   []*Endpoint `yaml:"Endpoints,omitempty" json:",omitempty"`
+	Path         string      `yaml:"-" json:",omitempty"`
+	Tag          string      `yaml:"-" json:",omitempty"`
+	Name         string      `yaml:"Name"`
+	DisplayName  string      `yaml:"Display-Name"`
+	Description  string      `yaml:"Description,omitempty"`
+	Version      string      `yaml:"Version"`
+	Vendor       string      `yaml:"Vendor"`
+	Shared       bool        `yaml:"Shared,omitempty" json:",omitempty"`
+	Logo         string      `yaml:"Logo,omitempty" json:",omitempty"`
+	Category     Category    `yaml:"Category"`
+	BaseImage    string      `yaml:"Base-Image"`
+	BuildCache   []string    `yaml:"Build-Cache" json:",omitempty"`
+	DependsOn    []string    `yaml:"Depends-On,omitempty" json:",omitempty"`
+	ReadyTimeout int         `yaml:"Ready-Timeout,omitempty" json:",omitempty"`
+	User         string      `yaml:"User,omitempty" json:",omitempty"`
+	Endpoints    []*Endpoint `yaml:"Endpoints,omitempty" json:",omitempty"`
+
+	// Commands to take a logical backup of the plugin data, such as
+	// pg_dump and pg_restore. The Backup command writes the backup to
+	// stdout, and the Restore command reads the backup from stdin. The
+	// commands are run by shell in the plugin directory while the plugin
+	// is running, instead of copying the data directory, for databases
+	// that can't be safely copied while running. The data directory of
+	// such plugin, named by the plugin name under $CLOUDWAY_DATA_DIR, is
+	// left out from data dumps.
+	Backup  string `yaml:"Backup,omitempty" json:",omitempty"`
+	Restore string `yaml:"Restore,omitempty" json:",omitempty"`
+
+	// Overrides of container hardening for plugins that need more, that
+	// is a writable root filesystem, additional Linux capabilities, or
+	// "unconfined" to disable the seccomp profile. HostUserns runs the
+	// container in the host user namespace if the docker daemon remaps
+	// user namespaces.
+	WritableRoot bool     `yaml:"Writable-Root,omitempty" json:",omitempty"`
+	Capabilities []string `yaml:"Capabilities,omitempty" json:",omitempty"`
+	Seccomp      string   `yaml:"Seccomp,omitempty" json:",omitempty"`
+	HostUserns   bool     `yaml:"Host-Userns,omitempty" json:",omitempty"`
 }
 
 type Endpoint struct {
@@ -68,6 +90,49 @@ type ProxyMapping struct {
 	Backend   string   `yaml:"Backend"`
 	Protocols []string `yaml:"Protocols,omitempty" json:"-"`
 	Protocol  string   `yaml:"-" json:"Protocol,omitempty"`
+
+	// The relative weight of the backend when load balanced with other
+	// backends of the same frontend. Zero means the default weight, and
+	// a negative weight drains the backend.
+	Weight int `yaml:"-" json:"Weight,omitempty"`
+
+	// The access policy of the frontend enforced by the proxy.
+	Policy *AccessPolicy `yaml:"-" json:"Policy,omitempty"`
+
+	// The HTTP settings of the frontend applied by the proxy.
+	Settings *HTTPSettings `yaml:"-" json:"Settings,omitempty"`
+}
+
+// HTTPSettings controls how the proxy serves a frontend. Plain HTTP
+// requests are redirected to HTTPS if ForceHTTPS is set, and the HSTS
+// header is added if HSTSMaxAge is not zero, for frontends with TLS
+// certificates. Error responses of the backend with the status codes in
+// ErrorPages are redirected to the custom error page URLs. WebSocket
+// enables connection upgrades, and GRPC passes requests to the backend
+// over HTTP/2. IdleTimeout is the time in seconds a proxied connection
+// may stay idle, which is the proxy default if zero.
+type HTTPSettings struct {
+	ForceHTTPS            bool              `json:",omitempty"`
+	HSTSMaxAge            int               `json:",omitempty"`
+	HSTSIncludeSubdomains bool              `json:",omitempty"`
+	ErrorPages            map[string]string `json:",omitempty"`
+	WebSocket             bool              `json:",omitempty"`
+	GRPC                  bool              `json:",omitempty"`
+	IdleTimeout           int               `json:",omitempty"`
+}
+
+// AccessPolicy restricts access to a frontend at the proxy. Clients are
+// denied if the address matches the deny list, or doesn't match the allow
+// list if not empty.
+type AccessPolicy struct {
+	Allow []string `json:",omitempty"`
+	Deny  []string `json:",omitempty"`
+
+	// Basic auth users in htpasswd format, such as "user:{SHA}hash".
+	BasicAuth []string `json:",omitempty"`
+
+	// Redirects to the URL instead of serving the maintenance page.
+	MaintenanceRedirect string `json:",omitempty"`
 }
 
 const ManifestEntry = "manifest/plugin.yml"
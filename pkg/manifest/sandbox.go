@@ -19,18 +19,20 @@ const (
 	StateBuilding
 	StateFailed
 	StateUnknown
+	StateCrashLooping
 )
 
 var stateString = [...]string{
-	StateNew:        "new",
-	StateStarting:   "starting",
-	StateRestarting: "restarting",
-	StateRunning:    "running",
-	StateStopping:   "stopping",
-	StateStopped:    "stopped",
-	StateBuilding:   "building",
-	StateFailed:     "failed",
-	StateUnknown:    "unknown",
+	StateNew:          "new",
+	StateStarting:     "starting",
+	StateRestarting:   "restarting",
+	StateRunning:      "running",
+	StateStopping:     "stopping",
+	StateStopped:      "stopped",
+	StateBuilding:     "building",
+	StateFailed:       "failed",
+	StateUnknown:      "unknown",
+	StateCrashLooping: "crashlooping",
 }
 
 func (s ActiveState) String() string {
@@ -0,0 +1,198 @@
+// Package migrate applies versioned schema migrations to a data store.
+//
+// Each migration has a version number, migrations are applied in order of
+// versions and reverted in reverse order. The store records the version of
+// the last applied migration, which is updated after each migration, so a
+// failed migration can be retried after the problem is fixed.
+package migrate
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Migration describes a change of stored data.
+type Migration struct {
+	Version     int
+	Description string
+
+	// Up applies the migration.
+	Up func() error
+
+	// Down reverts the migration, nil if the migration is irreversible.
+	Down func() error
+}
+
+// Store saves the schema version of a data store.
+type Store interface {
+	// Version returns the version of the last applied migration, or zero
+	// if no migration applied.
+	Version() (int, error)
+
+	// SetVersion saves the version of the last applied migration.
+	SetVersion(version int) error
+}
+
+// The IrreversibleError indicates that a migration cannot be reverted.
+type IrreversibleError struct {
+	Store   string
+	Version int
+}
+
+func (e IrreversibleError) Error() string {
+	return fmt.Sprintf("%s: migration %d is irreversible", e.Store, e.Version)
+}
+
+// The UnknownVersionError indicates that the store has a schema version
+// newer than known migrations, which means the store is migrated by a newer
+// version of the platform.
+type UnknownVersionError struct {
+	Store   string
+	Version int
+	Latest  int
+}
+
+func (e UnknownVersionError) Error() string {
+	return fmt.Sprintf("%s: schema version %d is newer than the latest known version %d",
+		e.Store, e.Version, e.Latest)
+}
+
+// Status describes a migration and whether it is applied to the store.
+type Status struct {
+	*Migration
+	Applied bool
+}
+
+// Migrator applies migrations to a store.
+type Migrator struct {
+	Name       string
+	store      Store
+	migrations []*Migration
+}
+
+// New creates a migrator for the named store. Migration versions must be
+// positive and unique.
+func New(name string, store Store, migrations ...*Migration) *Migrator {
+	sorted := append([]*Migration(nil), migrations...)
+	sort.Sort(byVersion(sorted))
+	for i, m := range sorted {
+		if m.Version <= 0 || (i > 0 && m.Version == sorted[i-1].Version) {
+			panic(fmt.Sprintf("migrate: invalid migration version %d of %s", m.Version, name))
+		}
+	}
+	return &Migrator{Name: name, store: store, migrations: sorted}
+}
+
+type byVersion []*Migration
+
+func (a byVersion) Len() int           { return len(a) }
+func (a byVersion) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byVersion) Less(i, j int) bool { return a[i].Version < a[j].Version }
+
+// Latest returns the version of the last known migration.
+func (m *Migrator) Latest() int {
+	if n := len(m.migrations); n != 0 {
+		return m.migrations[n-1].Version
+	}
+	return 0
+}
+
+// Version returns the current schema version of the store.
+func (m *Migrator) Version() (int, error) {
+	return m.store.Version()
+}
+
+// Status returns all known migrations and whether they are applied.
+func (m *Migrator) Status() ([]Status, error) {
+	current, err := m.store.Version()
+	if err != nil {
+		return nil, err
+	}
+	status := make([]Status, len(m.migrations))
+	for i, mig := range m.migrations {
+		status[i] = Status{mig, mig.Version <= current}
+	}
+	return status, nil
+}
+
+// Pending returns migrations not yet applied to the store.
+func (m *Migrator) Pending() ([]*Migration, error) {
+	current, err := m.store.Version()
+	if err != nil {
+		return nil, err
+	}
+	var pending []*Migration
+	for _, mig := range m.migrations {
+		if mig.Version > current {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies pending migrations up to and including the target version. A
+// negative target applies all pending migrations. Progress is written to
+// the writer.
+func (m *Migrator) Up(target int, w io.Writer) error {
+	current, err := m.store.Version()
+	if err != nil {
+		return err
+	}
+	if latest := m.Latest(); current > latest {
+		return UnknownVersionError{m.Name, current, latest}
+	}
+
+	for _, mig := range m.migrations {
+		if mig.Version <= current || (target >= 0 && mig.Version > target) {
+			continue
+		}
+		fmt.Fprintf(w, "%s: applying %d %s\n", m.Name, mig.Version, mig.Description)
+		if err = mig.Up(); err != nil {
+			return fmt.Errorf("%s: migration %d failed: %v", m.Name, mig.Version, err)
+		}
+		if err = m.store.SetVersion(mig.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Down reverts applied migrations with versions greater than the target
+// version, in reverse order. Progress is written to the writer.
+func (m *Migrator) Down(target int, w io.Writer) error {
+	current, err := m.store.Version()
+	if err != nil {
+		return err
+	}
+	if latest := m.Latest(); current > latest {
+		return UnknownVersionError{m.Name, current, latest}
+	}
+
+	// check before reverting any migration so the store is not left in
+	// the middle of the requested range
+	for _, mig := range m.migrations {
+		if mig.Version > target && mig.Version <= current && mig.Down == nil {
+			return IrreversibleError{m.Name, mig.Version}
+		}
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if mig.Version > current || mig.Version <= target {
+			continue
+		}
+		fmt.Fprintf(w, "%s: reverting %d %s\n", m.Name, mig.Version, mig.Description)
+		if err = mig.Down(); err != nil {
+			return fmt.Errorf("%s: reverting migration %d failed: %v", m.Name, mig.Version, err)
+		}
+		prev := 0
+		if i > 0 {
+			prev = m.migrations[i-1].Version
+		}
+		if err = m.store.SetVersion(prev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
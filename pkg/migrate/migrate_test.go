@@ -0,0 +1,103 @@
+package migrate
+
+import (
+	"errors"
+	"io/ioutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type memStore struct {
+	version int
+}
+
+func (s *memStore) Version() (int, error) {
+	return s.version, nil
+}
+
+func (s *memStore) SetVersion(version int) error {
+	s.version = version
+	return nil
+}
+
+var _ = Describe("Migrator", func() {
+	var (
+		store    *memStore
+		migrator *Migrator
+		applied  []int
+	)
+
+	migration := func(version int) *Migration {
+		return &Migration{
+			Version: version,
+			Up:      func() error { applied = append(applied, version); return nil },
+			Down:    func() error { applied = append(applied, -version); return nil },
+		}
+	}
+
+	BeforeEach(func() {
+		store = &memStore{}
+		applied = nil
+		migrator = New("test", store, migration(3), migration(1), migration(2))
+	})
+
+	It("should apply all pending migrations in order", func() {
+		Expect(migrator.Up(-1, ioutil.Discard)).To(Succeed())
+		Expect(applied).To(Equal([]int{1, 2, 3}))
+		Expect(store.version).To(Equal(3))
+
+		applied = nil
+		Expect(migrator.Up(-1, ioutil.Discard)).To(Succeed())
+		Expect(applied).To(BeEmpty())
+	})
+
+	It("should apply migrations up to the target version", func() {
+		Expect(migrator.Up(2, ioutil.Discard)).To(Succeed())
+		Expect(applied).To(Equal([]int{1, 2}))
+		Expect(migrator.Pending()).To(HaveLen(1))
+	})
+
+	It("should revert migrations in reverse order", func() {
+		store.version = 3
+		Expect(migrator.Down(1, ioutil.Discard)).To(Succeed())
+		Expect(applied).To(Equal([]int{-3, -2}))
+		Expect(store.version).To(Equal(1))
+	})
+
+	It("should report status of migrations", func() {
+		store.version = 2
+		status, err := migrator.Status()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status).To(HaveLen(3))
+		Expect(status[1].Version).To(Equal(2))
+		Expect(status[1].Applied).To(BeTrue())
+		Expect(status[2].Applied).To(BeFalse())
+	})
+
+	It("should record the last successful migration", func() {
+		failing := migration(2)
+		failing.Up = func() error { return errors.New("failed") }
+		migrator = New("test", store, migration(1), failing, migration(3))
+
+		Expect(migrator.Up(-1, ioutil.Discard)).NotTo(Succeed())
+		Expect(store.version).To(Equal(1))
+	})
+
+	It("should not revert irreversible migrations", func() {
+		irreversible := migration(2)
+		irreversible.Down = nil
+		migrator = New("test", store, migration(1), irreversible, migration(3))
+		store.version = 3
+
+		Expect(migrator.Down(0, ioutil.Discard)).To(Equal(IrreversibleError{"test", 2}))
+		Expect(applied).To(BeEmpty())
+		Expect(migrator.Down(2, ioutil.Discard)).To(Succeed())
+		Expect(store.version).To(Equal(2))
+	})
+
+	It("should refuse to migrate a store with unknown version", func() {
+		store.version = 4
+		Expect(migrator.Up(-1, ioutil.Discard)).To(BeAssignableToTypeOf(UnknownVersionError{}))
+	})
+})
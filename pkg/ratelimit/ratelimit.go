@@ -0,0 +1,101 @@
+// Package ratelimit limits the number of events per key in a time window,
+// such as login attempts from a client address.
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type counter struct {
+	start time.Time
+	n     int
+}
+
+// Limiter counts events in fixed time windows. Counters of past windows
+// are discarded periodically.
+type Limiter struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	counters  map[string]*counter
+	lastSweep time.Time
+}
+
+// New creates a limiter that counts events in the given time window.
+func New(window time.Duration) *Limiter {
+	return &Limiter{
+		window:   window,
+		now:      time.Now,
+		counters: make(map[string]*counter),
+	}
+}
+
+// Allow records an event for the key, and returns false if the number of
+// events in the current window exceeds the limit.
+func (l *Limiter) Allow(key string, limit int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) > l.window {
+		for k, c := range l.counters {
+			if now.Sub(c.start) > l.window {
+				delete(l.counters, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c := l.counters[key]
+	if c == nil || now.Sub(c.start) > l.window {
+		c = &counter{start: now}
+		l.counters[key] = c
+	}
+	c.n++
+	return c.n <= limit
+}
+
+// ClientIP returns the address of the client sending the request. The
+// X-Real-IP or X-Forwarded-For header is only used if the request comes
+// from one of the trusted reverse proxies, as anyone can set them.
+func ClientIP(r *http.Request, trusted []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrusted(host, trusted) {
+		return host
+	}
+
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+
+	// each proxy appends the address it received the request from, so the
+	// client is the last address not added by a trusted proxy
+	forwarded := strings.Split(strings.Join(r.Header["X-Forwarded-For"], ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		if ip := strings.TrimSpace(forwarded[i]); ip != "" && (i == 0 || !isTrusted(ip, trusted)) {
+			return ip
+		}
+	}
+	return host
+}
+
+func isTrusted(addr string, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Limiter", func() {
+	var (
+		limiter *Limiter
+		now     time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Now()
+		limiter = New(time.Minute)
+		limiter.now = func() time.Time { return now }
+	})
+
+	It("should allow events up to the limit", func() {
+		for i := 0; i < 3; i++ {
+			Expect(limiter.Allow("a", 3)).To(BeTrue())
+		}
+		Expect(limiter.Allow("a", 3)).To(BeFalse())
+	})
+
+	It("should count events by key", func() {
+		Expect(limiter.Allow("a", 1)).To(BeTrue())
+		Expect(limiter.Allow("a", 1)).To(BeFalse())
+		Expect(limiter.Allow("b", 1)).To(BeTrue())
+	})
+
+	It("should reset the count in the next window", func() {
+		Expect(limiter.Allow("a", 1)).To(BeTrue())
+		Expect(limiter.Allow("a", 1)).To(BeFalse())
+		now = now.Add(2 * time.Minute)
+		Expect(limiter.Allow("a", 1)).To(BeTrue())
+	})
+
+	It("should discard counters of past windows", func() {
+		limiter.Allow("a", 1)
+		now = now.Add(2 * time.Minute)
+		limiter.Allow("b", 1)
+		Expect(limiter.counters).To(HaveLen(1))
+		Expect(limiter.counters).To(HaveKey("b"))
+	})
+})
+
+var _ = Describe("ClientIP", func() {
+	_, proxies, _ := net.ParseCIDR("127.0.0.0/8")
+	trusted := []*net.IPNet{proxies}
+
+	newRequest := func(remote string) *http.Request {
+		r, err := http.NewRequest("POST", "/auth", nil)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		r.RemoteAddr = remote
+		return r
+	}
+
+	It("should return the remote address", func() {
+		r := newRequest("10.0.0.1:4321")
+		Expect(ClientIP(r, trusted)).To(Equal("10.0.0.1"))
+	})
+
+	It("should prefer the address from trusted reverse proxy", func() {
+		r := newRequest("127.0.0.1:4321")
+		r.Header.Set("X-Real-IP", "10.0.0.2")
+		Expect(ClientIP(r, trusted)).To(Equal("10.0.0.2"))
+	})
+
+	It("should ignore addresses from untrusted clients", func() {
+		r := newRequest("10.0.0.1:4321")
+		r.Header.Set("X-Real-IP", "10.0.0.2")
+		r.Header.Set("X-Forwarded-For", "10.0.0.3")
+		Expect(ClientIP(r, trusted)).To(Equal("10.0.0.1"))
+		Expect(ClientIP(newRequest("127.0.0.1:4321"), nil)).To(Equal("127.0.0.1"))
+	})
+
+	It("should skip trusted proxies in forwarded addresses", func() {
+		r := newRequest("127.0.0.1:4321")
+		r.Header.Add("X-Forwarded-For", "10.0.0.9, 10.0.0.2")
+		r.Header.Add("X-Forwarded-For", "127.0.0.2")
+		Expect(ClientIP(r, trusted)).To(Equal("10.0.0.2"))
+	})
+})
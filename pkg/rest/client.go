@@ -24,6 +24,10 @@ type Client struct {
 	version string
 	// custom http headers configured by users.
 	customHTTPHeaders map[string]string
+	// tracer dumps requests and responses for debugging, nil if disabled.
+	tracer *tracer
+	// headerHook is called with headers of every response, nil if not set.
+	headerHook func(http.Header)
 }
 
 // NewClient initializes a new API client for the given host and API version.
@@ -41,6 +45,10 @@ func NewClient(host string, version string, client *http.Client, httpHeaders map
 		return nil, err
 	}
 
+	if httpHeaders == nil {
+		httpHeaders = make(map[string]string)
+	}
+
 	return &Client{
 		proto:             proto,
 		addr:              addr,
@@ -62,8 +70,11 @@ func (cli *Client) getAPIPath(p string, query url.Values) string {
 		apiPath = fmt.Sprintf("%s%s", cli.basePath, p)
 	}
 
-	u := &url.URL{
-		Path: apiPath,
+	// path segments may have been escaped by the caller, keep them
+	// escaped on the wire instead of escaping them again
+	u, err := url.Parse(apiPath)
+	if err != nil {
+		u = &url.URL{Path: apiPath}
 	}
 	if len(query) > 0 {
 		u.RawQuery = query.Encode()
@@ -93,6 +104,12 @@ func (cli *Client) RemoveCustomHeader(name string) {
 	delete(cli.customHTTPHeaders, name)
 }
 
+// SetHeaderHook sets the function to be called with headers of every
+// response received from server, including error responses.
+func (cli *Client) SetHeaderHook(fn func(http.Header)) {
+	cli.headerHook = fn
+}
+
 // ParseHost verifies that the given host strings is valid.
 func ParseHost(host string) (string, string, string, error) {
 	protoAddrParts := strings.SplitN(host, "://", 2)
@@ -0,0 +1,26 @@
+package rest
+
+import (
+	"net/http"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	It("should keep escaped path segments escaped", func() {
+		cli := &Client{version: "1.2"}
+		Expect(cli.getAPIPath("/user/keys/SHA256:a%2Fb%2Bc", nil)).To(Equal("/v1.2/user/keys/SHA256:a%2Fb%2Bc"))
+
+		req, err := http.NewRequest("DELETE", cli.getAPIPath("/user/keys/a%2Fb", nil), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(req.URL.Path).To(Equal("/v1.2/user/keys/a/b"))
+		Expect(req.URL.RequestURI()).To(Equal("/v1.2/user/keys/a%2Fb"))
+	})
+
+	It("should escape unescaped paths and append the query", func() {
+		cli := &Client{}
+		Expect(cli.getAPIPath("/applications/a b", url.Values{"x": {"1"}})).To(Equal("/applications/a%20b?x=1"))
+	})
+})
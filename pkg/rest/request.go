@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/cloudway/platform/pkg/rest/transport/cancellable"
 )
@@ -104,7 +105,16 @@ func (cli *Client) sendClientRequest(ctx context.Context, method, path string, q
 	req.URL.Host = cli.addr
 	req.URL.Scheme = cli.transport.Scheme()
 
+	var start time.Time
+	if cli.tracer != nil {
+		start = time.Now()
+		cli.tracer.traceRequest(req)
+	}
+
 	resp, err := cancellable.Do(ctx, cli.transport, req)
+	if cli.tracer != nil {
+		cli.tracer.traceResponse(req, resp, err, time.Since(start))
+	}
 	if err != nil {
 		if isTimeout(err) || strings.Contains(err.Error(), "connection refused") ||
 			strings.Contains(err.Error(), "dial unix") {
@@ -126,6 +136,9 @@ func (cli *Client) sendClientRequest(ctx context.Context, method, path string, q
 
 	if resp != nil {
 		serverResp.StatusCode = resp.StatusCode
+		if cli.headerHook != nil {
+			cli.headerHook(resp.Header)
+		}
 	}
 
 	if serverResp.StatusCode < 200 || serverResp.StatusCode >= 400 {
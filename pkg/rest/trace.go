@@ -0,0 +1,155 @@
+package rest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxTraceBody is the maximum size of a request or response body that
+// will be dumped to the trace writer. Larger or streamed bodies are omitted.
+const maxTraceBody = 64 * 1024
+
+const redacted = "[REDACTED]"
+
+// sensitiveHeaders lists the headers whose values must never be written
+// to the trace log.
+var sensitiveHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"Set-Cookie":    true,
+	"X-Auth-Token":  true,
+}
+
+// sensitiveFields matches JSON string fields that may contain credentials.
+var sensitiveFields = regexp.MustCompile(`(?i)("[^"]*(?:password|passwd|secret|token|key)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// tracer writes a human readable dump of the requests and responses
+// to a writer, redacting credentials.
+type tracer struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// SetTraceWriter enables request and response tracing to the given writer.
+// Credentials in headers and JSON bodies are redacted. Pass nil to disable
+// tracing.
+func (cli *Client) SetTraceWriter(w io.Writer) {
+	if w == nil {
+		cli.tracer = nil
+	} else {
+		cli.tracer = &tracer{out: w}
+	}
+}
+
+func (t *tracer) traceRequest(req *http.Request) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--> %s %s\n", req.Method, req.URL)
+	writeHeaders(&buf, req.Header)
+
+	// the length is only known for in-memory bodies, which can be buffered
+	// and replaced without consuming a stream, streamed bodies have zero
+	// or negative length depending on the caller
+	if req.Body != nil {
+		if req.ContentLength > 0 && req.ContentLength <= maxTraceBody {
+			data, rerr := ioutil.ReadAll(req.Body)
+			req.Body.Close()
+			req.Body = ioutil.NopCloser(bytes.NewReader(data))
+			if rerr == nil {
+				writeBody(&buf, req.Header.Get("Content-Type"), data)
+			}
+		} else {
+			fmt.Fprintf(&buf, "\n[request body omitted]\n")
+		}
+	}
+
+	t.write(buf.Bytes())
+}
+
+func (t *tracer) traceResponse(req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+	var buf bytes.Buffer
+
+	if err != nil {
+		fmt.Fprintf(&buf, "<-- %s %s failed (%v): %v\n", req.Method, req.URL, elapsed, err)
+		t.write(buf.Bytes())
+		return
+	}
+
+	fmt.Fprintf(&buf, "<-- %s %s %s (%v)\n", resp.Status, req.Method, req.URL, elapsed)
+	writeHeaders(&buf, resp.Header)
+
+	ctype := resp.Header.Get("Content-Type")
+	if resp.ContentLength >= 0 && resp.ContentLength <= maxTraceBody && isTextContent(ctype) {
+		// buffer the body so it can be dumped and still read by the caller
+		data, rerr := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+		if rerr == nil {
+			writeBody(&buf, ctype, data)
+		}
+	} else if resp.ContentLength != 0 {
+		fmt.Fprintf(&buf, "\n[response body omitted]\n")
+	}
+
+	t.write(buf.Bytes())
+}
+
+func (t *tracer) write(p []byte) {
+	t.mu.Lock()
+	t.out.Write(p)
+	io.WriteString(t.out, "\n")
+	t.mu.Unlock()
+}
+
+func writeHeaders(buf *bytes.Buffer, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range header[k] {
+			switch ck := http.CanonicalHeaderKey(k); {
+			case ck == "Authorization":
+				v = redactHeader(v)
+			case sensitiveHeaders[ck]:
+				v = redacted
+			}
+			fmt.Fprintf(buf, "%s: %s\n", k, v)
+		}
+	}
+}
+
+// redactHeader hides the credential in a header value but keeps the
+// authentication scheme, e.g. "Bearer [REDACTED]".
+func redactHeader(v string) string {
+	if i := strings.IndexByte(v, ' '); i > 0 {
+		return v[:i+1] + redacted
+	}
+	return redacted
+}
+
+func writeBody(buf *bytes.Buffer, ctype string, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	if !isTextContent(ctype) {
+		fmt.Fprintf(buf, "\n[%d bytes of binary data]\n", len(data))
+		return
+	}
+	buf.WriteByte('\n')
+	buf.Write(sensitiveFields.ReplaceAll(bytes.TrimSpace(data), []byte(`$1"`+redacted+`"`)))
+	buf.WriteByte('\n')
+}
+
+func isTextContent(ctype string) bool {
+	return strings.HasPrefix(ctype, "application/json") || strings.HasPrefix(ctype, "text/")
+}
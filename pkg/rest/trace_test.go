@@ -0,0 +1,129 @@
+package rest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tracer", func() {
+	var (
+		out *bytes.Buffer
+		t   *tracer
+	)
+
+	BeforeEach(func() {
+		out = new(bytes.Buffer)
+		t = &tracer{out: out}
+	})
+
+	newResponse := func(ctype, body string) *http.Response {
+		resp := &http.Response{
+			Status:        "200 OK",
+			StatusCode:    200,
+			Header:        http.Header{},
+			Body:          ioutil.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+		}
+		if ctype != "" {
+			resp.Header.Set("Content-Type", ctype)
+		}
+		return resp
+	}
+
+	Context("Request", func() {
+		It("should redact credentials in headers", func() {
+			req, _ := http.NewRequest("GET", "http://localhost/info", nil)
+			req.Header.Set("Authorization", "Bearer secret-token")
+			req.Header.Set("Cookie", "session=abc")
+			t.traceRequest(req)
+
+			Expect(out.String()).To(ContainSubstring("--> GET http://localhost/info"))
+			Expect(out.String()).To(ContainSubstring("Authorization: Bearer [REDACTED]"))
+			Expect(out.String()).To(ContainSubstring("Cookie: [REDACTED]"))
+			Expect(out.String()).NotTo(ContainSubstring("secret-token"))
+			Expect(out.String()).NotTo(ContainSubstring("abc"))
+		})
+
+		It("should redact credentials in JSON bodies and keep the body readable", func() {
+			body := `{"Name":"admin","Password":"s3cr3t","APIToken":"xyz"}`
+			req, _ := http.NewRequest("POST", "http://localhost/auth", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			t.traceRequest(req)
+
+			Expect(out.String()).To(ContainSubstring(`"Name":"admin"`))
+			Expect(out.String()).To(ContainSubstring(`"Password":"[REDACTED]"`))
+			Expect(out.String()).To(ContainSubstring(`"APIToken":"[REDACTED]"`))
+			Expect(out.String()).NotTo(ContainSubstring("s3cr3t"))
+
+			data, err := ioutil.ReadAll(req.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal(body))
+		})
+
+		It("should omit streamed bodies without consuming them", func() {
+			stream := ioutil.NopCloser(strings.NewReader("streamed content"))
+			req, _ := http.NewRequest("PUT", "http://localhost/repo", stream)
+			t.traceRequest(req)
+
+			Expect(out.String()).To(ContainSubstring("[request body omitted]"))
+			data, _ := ioutil.ReadAll(req.Body)
+			Expect(string(data)).To(Equal("streamed content"))
+		})
+
+		It("should omit bodies larger than the limit", func() {
+			body := strings.Repeat("x", maxTraceBody+1)
+			req, _ := http.NewRequest("POST", "http://localhost/data", strings.NewReader(body))
+			req.Header.Set("Content-Type", "text/plain")
+			t.traceRequest(req)
+
+			Expect(out.String()).To(ContainSubstring("[request body omitted]"))
+			Expect(out.Len()).To(BeNumerically("<", 1024))
+		})
+	})
+
+	Context("Response", func() {
+		var req *http.Request
+
+		BeforeEach(func() {
+			req, _ = http.NewRequest("GET", "http://localhost/user", nil)
+		})
+
+		It("should dump text bodies and keep them readable", func() {
+			resp := newResponse("application/json", `{"Name":"admin","Token":"xyz"}`)
+			t.traceResponse(req, resp, nil, time.Second)
+
+			Expect(out.String()).To(ContainSubstring("<-- 200 OK GET http://localhost/user"))
+			Expect(out.String()).To(ContainSubstring(`"Token":"[REDACTED]"`))
+
+			data, _ := ioutil.ReadAll(resp.Body)
+			Expect(string(data)).To(Equal(`{"Name":"admin","Token":"xyz"}`))
+		})
+
+		It("should redact cookies set by the server", func() {
+			resp := newResponse("", "")
+			resp.Header.Set("Set-Cookie", "session=abc; HttpOnly")
+			t.traceResponse(req, resp, nil, time.Second)
+
+			Expect(out.String()).To(ContainSubstring("Set-Cookie: [REDACTED]"))
+		})
+
+		It("should omit binary bodies", func() {
+			resp := newResponse("application/octet-stream", "\x00\x01\x02")
+			t.traceResponse(req, resp, nil, time.Second)
+
+			Expect(out.String()).To(ContainSubstring("[response body omitted]"))
+		})
+
+		It("should report failed requests", func() {
+			t.traceResponse(req, nil, http.ErrHandlerTimeout, time.Second)
+
+			Expect(out.String()).To(ContainSubstring("<-- GET http://localhost/user failed"))
+		})
+	})
+})
@@ -0,0 +1,254 @@
+// Package s3 implements a minimal client of the Amazon S3 API, and other
+// storage services compatible with it, to upload and download objects.
+// Requests are signed with AWS Signature Version 4.
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRegion = "us-east-1"
+	emptyHash     = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	unsigned      = "UNSIGNED-PAYLOAD"
+	timeFormat    = "20060102T150405Z"
+)
+
+// Client accesses objects with path-style URLs, such as
+// https://s3.amazonaws.com/bucket/key.
+type Client struct {
+	// The service endpoint. Defaults to the regional endpoint of Amazon S3.
+	Endpoint string
+
+	// The region of buckets. Defaults to us-east-1.
+	Region string
+
+	AccessKey string
+	SecretKey string
+
+	// The service name in the signing scope, defaults to s3. Other AWS
+	// services share the signature with a different service name.
+	Service string
+
+	HTTPClient *http.Client
+}
+
+// Error is returned if the service responded with an error status.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("S3 error %d: %s", e.StatusCode, e.Message)
+}
+
+// ParseURL parses a URL in the form of s3://bucket/key.
+func ParseURL(rawurl string) (bucket, key string, err error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", "", err
+	}
+	key = strings.TrimPrefix(u.Path, "/")
+	if u.Scheme != "s3" || u.Host == "" || key == "" {
+		return "", "", fmt.Errorf("Invalid S3 URL: %s, must be s3://bucket/key", rawurl)
+	}
+	return u.Host, key, nil
+}
+
+// IsURL returns true if the string is a S3 URL.
+func IsURL(s string) bool {
+	return strings.HasPrefix(s, "s3://")
+}
+
+// Put uploads an object with the given size. The payload is not signed as
+// the content may be too large to be hashed in advance, so an HTTPS
+// endpoint should be used.
+func (c *Client) Put(bucket, key string, body io.Reader, size int64) error {
+	req, err := c.newRequest("PUT", bucket, key, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	c.Sign(req, unsigned, time.Now())
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get downloads an object. The caller must close the returned reader.
+func (c *Client) Get(bucket, key string) (io.ReadCloser, error) {
+	req, err := c.newRequest("GET", bucket, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.Sign(req, emptyHash, time.Now())
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (c *Client) region() string {
+	if c.Region != "" {
+		return c.Region
+	}
+	return defaultRegion
+}
+
+func (c *Client) service() string {
+	if c.Service != "" {
+		return c.Service
+	}
+	return "s3"
+}
+
+func (c *Client) endpoint() string {
+	if c.Endpoint != "" {
+		return strings.TrimSuffix(c.Endpoint, "/")
+	}
+	if c.Region == "" || c.Region == defaultRegion {
+		return "https://s3.amazonaws.com"
+	}
+	return "https://s3." + c.Region + ".amazonaws.com"
+}
+
+func (c *Client) newRequest(method, bucket, key string, body io.Reader) (*http.Request, error) {
+	u, err := url.Parse(c.endpoint())
+	if err != nil {
+		return nil, err
+	}
+	u.Path = "/" + bucket + "/" + key
+	return http.NewRequest(method, u.String(), body)
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, &Error{resp.StatusCode, strings.TrimSpace(string(msg))}
+	}
+	return resp, nil
+}
+
+// Sign adds the authorization header to the request. The host and all
+// headers set in the request are signed.
+func (c *Client) Sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzdate := now.Format(timeFormat)
+	date := amzdate[:8]
+
+	req.Header.Set("X-Amz-Date", amzdate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders string
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL),
+		canonicalQuery(req.URL),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.region() + "/" + c.service() + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzdate + "\n" + scope + "\n" + hashHex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretKey), date)
+	key = hmacSHA256(key, c.region())
+	key = hmacSHA256(key, c.service())
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKey, scope, signedHeaders, signature))
+}
+
+func canonicalPath(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, escape(k)+"="+escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escape encodes the string as required by the signature, where only
+// unreserved characters are not encoded.
+func escape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,197 @@
+// Package scaffold generates skeletons of new plugins.
+//
+// A generated plugin contains the manifest, the hook scripts run by the
+// sandbox and environment variable templates, laid out as:
+//
+//	manifest/plugin.yml   plugin manifest
+//	bin/setup             run once when the plugin is installed
+//	bin/control           start, stop and restart the plugin
+//	bin/build, bin/deploy build and deploy the application (frameworks)
+//	env/*.cwt             templates of environment variables
+//
+// Templates with the ".cwt" suffix are rendered with the environment of
+// the application when the plugin is started, so an environment template
+// named "env/CLOUDWAY_DB_URL.export.cwt" exports the CLOUDWAY_DB_URL
+// variable to applications that depend on the plugin.
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// Kind is the kind of plugin skeletons.
+type Kind string
+
+const (
+	// HTTPFramework is a framework plugin serving HTTP requests from the
+	// application repository.
+	HTTPFramework Kind = "http"
+
+	// TCPService is a service plugin listening on a TCP port.
+	TCPService Kind = "tcp"
+)
+
+// Kinds lists all kinds of plugin skeletons.
+var Kinds = []Kind{HTTPFramework, TCPService}
+
+// Options are the manifest values of a generated plugin. Empty values are
+// replaced by defaults.
+type Options struct {
+	Name        string
+	DisplayName string
+	Description string
+	Version     string
+	Vendor      string
+	BaseImage   string
+	Port        int32
+}
+
+var validName = regexp.MustCompile(`^[a-zA-Z_0-9]+$`)
+
+type file struct {
+	name string
+	mode os.FileMode
+	text string
+}
+
+// Generate writes the skeleton of a plugin into the directory, which must
+// not exist or be empty.
+func Generate(dir string, kind Kind, opts Options) error {
+	files, ok := skeletons[kind]
+	if !ok {
+		return fmt.Errorf("Unknown plugin kind: %s", kind)
+	}
+	if !validName.MatchString(opts.Name) {
+		return fmt.Errorf("Invalid plugin name: %q", opts.Name)
+	}
+	if err := checkEmpty(dir); err != nil {
+		return err
+	}
+
+	meta := makeManifest(kind, opts)
+	data, err := yaml.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err = writeFile(dir, manifest.ManifestEntry, 0644, append([]byte("---\n"), data...)); err != nil {
+		return err
+	}
+
+	vars := map[string]interface{}{
+		"Name":        meta.Name,
+		"DisplayName": meta.DisplayName,
+		"Env":         "CLOUDWAY_" + strings.ToUpper(meta.Name),
+		"Port":        meta.Endpoints[0].PrivatePort,
+	}
+	for _, f := range files {
+		// use different delimiters so that environment templates are
+		// written as is
+		name, err := render(f.name, vars)
+		if err != nil {
+			return err
+		}
+		text, err := render(f.text, vars)
+		if err != nil {
+			return err
+		}
+		if err = writeFile(dir, name, f.mode, []byte(text)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func makeManifest(kind Kind, opts Options) *manifest.Plugin {
+	meta := &manifest.Plugin{
+		Name:        opts.Name,
+		DisplayName: opts.DisplayName,
+		Description: opts.Description,
+		Version:     opts.Version,
+		Vendor:      opts.Vendor,
+		BaseImage:   opts.BaseImage,
+	}
+	if meta.Version == "" {
+		meta.Version = "1.0"
+	}
+	if meta.DisplayName == "" {
+		meta.DisplayName = opts.Name + " " + meta.Version
+	}
+	if meta.Vendor == "" {
+		meta.Vendor = "unknown"
+	}
+	if meta.BaseImage == "" {
+		meta.BaseImage = "debian:jessie"
+	}
+
+	ep := &manifest.Endpoint{
+		PrivateHostName: "HOST",
+		PrivatePortName: "PORT",
+		PrivatePort:     opts.Port,
+	}
+	switch kind {
+	case HTTPFramework:
+		meta.Category = manifest.Framework
+		if ep.PrivatePort == 0 {
+			ep.PrivatePort = 8080
+		}
+		ep.ProxyMappings = []*manifest.ProxyMapping{{Frontend: "/", Backend: "/"}}
+	case TCPService:
+		meta.Category = manifest.Service
+		if ep.PrivatePort == 0 {
+			ep.PrivatePort = 9000
+		}
+	}
+	meta.Endpoints = []*manifest.Endpoint{ep}
+	return meta
+}
+
+func render(text string, vars map[string]interface{}) (string, error) {
+	t, err := template.New("").Delims("[[", "]]").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = t.Execute(&buf, vars)
+	return buf.String(), err
+}
+
+func checkEmpty(dir string) error {
+	f, err := os.Open(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if names, _ := f.Readdirnames(1); len(names) != 0 {
+		return fmt.Errorf("Directory %s is not empty", dir)
+	}
+	return nil
+}
+
+func writeFile(dir, name string, mode os.FileMode, data []byte) error {
+	filename := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
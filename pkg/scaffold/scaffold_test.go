@@ -0,0 +1,72 @@
+package scaffold_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudway/platform/pkg/manifest"
+	. "github.com/cloudway/platform/pkg/scaffold"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Scaffold", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "scaffold")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should generate HTTP framework plugin", func() {
+		path := filepath.Join(dir, "myweb")
+		Expect(Generate(path, HTTPFramework, Options{Name: "myweb"})).To(Succeed())
+		Expect(manifest.IsPluginDir(path)).To(BeTrue())
+
+		meta, err := manifest.Load(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(meta.Name).To(Equal("myweb"))
+		Expect(meta.Category).To(Equal(manifest.Framework))
+		Expect(meta.Endpoints).To(HaveLen(1))
+		Expect(meta.Endpoints[0].PrivatePort).To(Equal(int32(8080)))
+		Expect(meta.Endpoints[0].ProxyMappings).To(HaveLen(1))
+
+		fi, err := os.Stat(filepath.Join(path, "bin", "control"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fi.Mode() & 0111).NotTo(BeZero())
+
+		env, err := ioutil.ReadFile(filepath.Join(path, "env", "CLOUDWAY_MYWEB_URL.cwt"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(env)).To(Equal("http://{{.CLOUDWAY_MYWEB_HOST}}:{{.CLOUDWAY_MYWEB_PORT}}/\n"))
+	})
+
+	It("should generate TCP service plugin", func() {
+		Expect(Generate(dir, TCPService, Options{Name: "mydb", Version: "2.1", Port: 1234})).To(Succeed())
+
+		meta, err := manifest.Load(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(meta.Category).To(Equal(manifest.Service))
+		Expect(meta.Version).To(Equal("2.1"))
+		Expect(meta.DisplayName).To(Equal("mydb 2.1"))
+		Expect(meta.Endpoints[0].PrivatePort).To(Equal(int32(1234)))
+		Expect(meta.Endpoints[0].ProxyMappings).To(BeEmpty())
+		Expect(filepath.Join(dir, "env", "CLOUDWAY_MYDB_URL.export.cwt")).To(BeAnExistingFile())
+		Expect(filepath.Join(dir, "bin", "deploy")).NotTo(BeAnExistingFile())
+	})
+
+	It("should reject invalid options", func() {
+		Expect(Generate(dir, "ftp", Options{Name: "test"})).NotTo(Succeed())
+		Expect(Generate(dir, HTTPFramework, Options{Name: "my-web"})).NotTo(Succeed())
+	})
+
+	It("should not overwrite existing files", func() {
+		Expect(ioutil.WriteFile(filepath.Join(dir, "README"), nil, 0644)).To(Succeed())
+		Expect(Generate(dir, HTTPFramework, Options{Name: "myweb"})).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,119 @@
+package scaffold
+
+// skeletons lists files of plugin skeletons by kind, other than the
+// manifest. File names and contents are templates delimited by "[[" and
+// "]]", with the plugin name, display name, environment variable prefix
+// and port as variables.
+var skeletons = map[Kind][]file{
+	HTTPFramework: {
+		{"bin/setup", 0755, setupScript},
+		{"bin/control", 0755, controlScript("\"$CLOUDWAY_REPO_DIR/start\"", httpServerComment)},
+		{"bin/build", 0755, buildScript},
+		{"bin/deploy", 0755, deployScript},
+		{"env/[[.Env]]_URL.cwt", 0644, "http://{{.[[.Env]]_HOST}}:{{.[[.Env]]_PORT}}/\n"},
+		{"template/start", 0755, startScript},
+		{"template/index.html", 0644, indexPage},
+	},
+	TCPService: {
+		{"bin/setup", 0755, setupScript},
+		{"bin/control", 0755, controlScript("/usr/local/bin/[[.Name]]", tcpServerComment)},
+		{"env/[[.Env]]_URL.export.cwt", 0644, "tcp://{{.[[.Env]]_HOST}}:{{.[[.Env]]_PORT}}\n"},
+	},
+}
+
+const setupScript = `#!/bin/bash
+# Runs once in the plugin directory when the plugin is installed into an
+# application container. Plugin environment variables can be written to
+# files in the env directory, with the ".export" suffix for variables
+# shared with other applications.
+
+set -e
+
+mkdir -p "$CLOUDWAY_DATA_DIR" "$CLOUDWAY_LOG_DIR"
+`
+
+const httpServerComment = `    # The application server must listen on $[[.Env]]_HOST and
+    # $[[.Env]]_PORT, which is [[.Port]] by default.`
+
+const tcpServerComment = `    # The service must listen on $[[.Env]]_HOST and $[[.Env]]_PORT,
+    # which is [[.Port]] by default, and keep data in $CLOUDWAY_DATA_DIR.`
+
+func controlScript(command, comment string) string {
+	return `#!/bin/bash
+# Starts, stops and restarts [[.DisplayName]]. The script runs in the plugin
+# directory with the application environment.
+
+PID_FILE="$CLOUDWAY_DATA_DIR/.[[.Name]].pid"
+LOG_FILE="$CLOUDWAY_LOG_DIR/[[.Name]].log"
+
+is_running() {
+    [ -f "$PID_FILE" ] && kill -0 "$(cat "$PID_FILE")" 2>/dev/null
+}
+
+start() {
+    if is_running; then
+        return 0
+    fi
+    echo "Starting [[.DisplayName]]"
+` + comment + `
+    nohup ` + command + ` >>"$LOG_FILE" 2>&1 &
+    echo $! > "$PID_FILE"
+}
+
+stop() {
+    if is_running; then
+        echo "Stopping [[.DisplayName]]"
+        kill "$(cat "$PID_FILE")"
+    fi
+    rm -f "$PID_FILE"
+}
+
+restart() {
+    stop
+    start
+}
+
+case "$1" in
+    start)    start ;;
+    stop)     stop ;;
+    restart)  restart;;
+    *) exit 0
+esac
+
+exit 0
+`
+}
+
+const buildScript = `#!/bin/bash
+# Runs in the application repository when the application is built, and
+# should install dependencies and compile the application.
+
+set -e
+`
+
+const deployScript = `#!/bin/bash
+# Runs in the application repository after the application is built and
+# before it is started.
+
+set -e
+`
+
+const startScript = `#!/bin/bash
+# Starts the application server in the foreground. New applications are
+# created from the template directory of the framework.
+
+echo "Application server is not configured" >&2
+exit 1
+`
+
+const indexPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>[[.DisplayName]]</title>
+</head>
+<body>
+  <h1>Welcome to [[.DisplayName]]</h1>
+</body>
+</html>
+`
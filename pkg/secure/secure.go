@@ -0,0 +1,154 @@
+// Package secure sets security related response headers, such as HSTS,
+// X-Frame-Options and Content-Security-Policy.
+package secure
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options describes security headers sent with responses. Empty values
+// omit the corresponding headers.
+type Options struct {
+	// The max-age of HSTS, the header is only sent over HTTPS.
+	HSTSMaxAge time.Duration
+
+	// The value of X-Frame-Options, such as DENY or SAMEORIGIN.
+	FrameOptions string
+
+	// The value of Content-Security-Policy.
+	ContentSecurityPolicy string
+
+	// Sends X-Content-Type-Options: nosniff.
+	NoSniff bool
+}
+
+type route struct {
+	prefix string
+	opts   Options
+}
+
+// Policy applies security headers to responses. The default options can be
+// overridden for routes with a specific path prefix.
+type Policy struct {
+	Default Options
+	routes  []route
+}
+
+// NewPolicy creates a policy with the given default options.
+func NewPolicy(opts Options) *Policy {
+	return &Policy{Default: opts}
+}
+
+// Override replaces the default options for requests whose path starts
+// with the given prefix. The longest matching prefix wins.
+func (p *Policy) Override(prefix string, opts Options) {
+	p.routes = append(p.routes, route{prefix, opts})
+}
+
+// Options returns options applied to the request path.
+func (p *Policy) Options(path string) Options {
+	opts, n := p.Default, -1
+	for _, rt := range p.routes {
+		if strings.HasPrefix(path, rt.prefix) && len(rt.prefix) > n {
+			opts, n = rt.opts, len(rt.prefix)
+		}
+	}
+	return opts
+}
+
+// Apply sets security headers to the response of the request.
+func (p *Policy) Apply(w http.ResponseWriter, r *http.Request) {
+	opts := p.Options(r.URL.Path)
+	h := w.Header()
+	if opts.HSTSMaxAge > 0 && IsTLS(r) {
+		h.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(int(opts.HSTSMaxAge.Seconds())))
+	}
+	if opts.FrameOptions != "" {
+		h.Set("X-Frame-Options", opts.FrameOptions)
+	}
+	if opts.ContentSecurityPolicy != "" {
+		h.Set("Content-Security-Policy", opts.ContentSecurityPolicy)
+	}
+	if opts.NoSniff {
+		h.Set("X-Content-Type-Options", "nosniff")
+	}
+}
+
+// Handler returns a handler that applies security headers before calling
+// the given handler.
+func (p *Policy) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.Apply(w, r)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// IsTLS returns true if the request is received over HTTPS, directly or
+// through a reverse proxy.
+func IsTLS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// SameSiteLax returns a handler that adds the "SameSite=Lax" attribute to
+// cookies set by the given handler, so the cookies are not sent with
+// cross-site form posts. Cookies that already have the attribute are kept
+// unchanged.
+func SameSiteLax(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(&sameSiteWriter{ResponseWriter: w}, r)
+	})
+}
+
+type sameSiteWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *sameSiteWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		cookies := w.Header()["Set-Cookie"]
+		for i, c := range cookies {
+			if !strings.Contains(strings.ToLower(c), "samesite=") {
+				cookies[i] = c + "; SameSite=Lax"
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *sameSiteWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *sameSiteWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.wroteHeader {
+			w.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+func (w *sameSiteWriter) CloseNotify() <-chan bool {
+	if n, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return n.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+func (w *sameSiteWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("The response writer does not support hijacking")
+}
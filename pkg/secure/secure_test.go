@@ -0,0 +1,90 @@
+package secure_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudway/platform/pkg/secure"
+)
+
+var _ = Describe("Policy", func() {
+	var policy *Policy
+
+	BeforeEach(func() {
+		policy = NewPolicy(Options{
+			HSTSMaxAge:            time.Hour,
+			FrameOptions:          "DENY",
+			ContentSecurityPolicy: "default-src 'self'",
+			NoSniff:               true,
+		})
+	})
+
+	apply := func(path string, tls bool) http.Header {
+		r, err := http.NewRequest("GET", path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		if tls {
+			r.Header.Set("X-Forwarded-Proto", "https")
+		}
+		w := httptest.NewRecorder()
+		policy.Handler(http.NotFoundHandler()).ServeHTTP(w, r)
+		return w.Header()
+	}
+
+	It("should set default headers", func() {
+		h := apply("/", false)
+		Expect(h.Get("X-Frame-Options")).To(Equal("DENY"))
+		Expect(h.Get("Content-Security-Policy")).To(Equal("default-src 'self'"))
+		Expect(h.Get("X-Content-Type-Options")).To(Equal("nosniff"))
+	})
+
+	It("should only send HSTS over HTTPS", func() {
+		Expect(apply("/", false).Get("Strict-Transport-Security")).To(BeEmpty())
+		Expect(apply("/", true).Get("Strict-Transport-Security")).To(Equal("max-age=3600"))
+	})
+
+	It("should apply options of the longest matching route", func() {
+		policy.Override("/a/", Options{FrameOptions: "SAMEORIGIN"})
+		policy.Override("/a/b/", Options{})
+
+		Expect(apply("/a/x", false).Get("X-Frame-Options")).To(Equal("SAMEORIGIN"))
+		Expect(apply("/a/x", false).Get("Content-Security-Policy")).To(BeEmpty())
+		Expect(apply("/a/b/x", false).Get("X-Frame-Options")).To(BeEmpty())
+		Expect(apply("/b", false).Get("X-Frame-Options")).To(Equal("DENY"))
+	})
+})
+
+var _ = Describe("SameSiteLax", func() {
+	serve := func(handler http.HandlerFunc) *httptest.ResponseRecorder {
+		r, err := http.NewRequest("GET", "/", nil)
+		Expect(err).NotTo(HaveOccurred())
+		w := httptest.NewRecorder()
+		SameSiteLax(handler).ServeHTTP(w, r)
+		return w
+	}
+
+	It("should add SameSite attribute to cookies", func() {
+		w := serve(func(w http.ResponseWriter, r *http.Request) {
+			http.SetCookie(w, &http.Cookie{Name: "a", Value: "1", Path: "/"})
+			w.Header().Add("Set-Cookie", "b=2; SameSite=Strict")
+			w.Write([]byte("ok"))
+		})
+		Expect(w.HeaderMap["Set-Cookie"]).To(Equal([]string{
+			"a=1; Path=/; SameSite=Lax",
+			"b=2; SameSite=Strict",
+		}))
+		Expect(w.Body.String()).To(Equal("ok"))
+	})
+
+	It("should add SameSite attribute before writing status", func() {
+		w := serve(func(w http.ResponseWriter, r *http.Request) {
+			http.SetCookie(w, &http.Cookie{Name: "a", Value: "1"})
+			w.WriteHeader(http.StatusFound)
+		})
+		Expect(w.Code).To(Equal(http.StatusFound))
+		Expect(w.HeaderMap.Get("Set-Cookie")).To(Equal("a=1; SameSite=Lax"))
+	})
+})
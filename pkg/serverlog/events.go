@@ -0,0 +1,96 @@
+package serverlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Event names used in server-sent event streams.
+const (
+	EventStdout   = "stdout"
+	EventStderr   = "stderr"
+	EventStatus   = "status"
+	EventProgress = "progress"
+	EventError    = "error"
+	EventEnd      = "end"
+)
+
+// EventStream writes server logs as server-sent events, so that browsers
+// and simple HTTP clients can follow the progress of a long running
+// operation without decoding the multiplexed stream protocol.
+type EventStream struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewEventStream creates an event stream that writes to the given writer.
+// The writer is flushed after each event if it implements http.Flusher.
+func NewEventStream(w io.Writer) *EventStream {
+	return &EventStream{w: w}
+}
+
+// Log returns a server log that sends standard output, standard error and
+// progress records as "stdout", "stderr" and "progress" events.
+func (es *EventStream) Log() *ServerLog {
+	l := Encap(&eventWriter{es, EventStdout}, &eventWriter{es, EventStderr})
+	l.progress = func(p *Progress) error {
+		return es.SendObject(EventProgress, p)
+	}
+	return l
+}
+
+// Send writes an event with the given name and data. Multi-line data is
+// split into multiple data fields as required by the SSE format.
+func (es *EventStream) Send(event string, data []byte) error {
+	var buf bytes.Buffer
+	buf.WriteString("event: ")
+	buf.WriteString(event)
+	buf.WriteByte('\n')
+
+	data = bytes.TrimSuffix(data, []byte{'\n'})
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		buf.WriteString("data: ")
+		buf.Write(bytes.TrimSuffix(line, []byte{'\r'}))
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if _, err := es.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if f, ok := es.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// SendObject writes an event with the JSON encoded object as data.
+func (es *EventStream) SendObject(event string, obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return es.Send(event, data)
+}
+
+// SendError writes an "error" event with the given error.
+func (es *EventStream) SendError(err error) error {
+	return es.SendObject(EventError, &Error{Message: err.Error()})
+}
+
+type eventWriter struct {
+	es    *EventStream
+	event string
+}
+
+func (w *eventWriter) Write(p []byte) (int, error) {
+	if err := w.es.Send(w.event, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
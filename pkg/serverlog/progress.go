@@ -0,0 +1,54 @@
+package serverlog
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/cloudway/platform/pkg/stdcopy"
+)
+
+// ProgressHeader is the request header used by clients to indicate that
+// they are able to decode progress records.
+const ProgressHeader = "X-Cloudway-Progress"
+
+// Progress describes the progress of a long running operation such as
+// application creation, deployment or restoration.
+type Progress struct {
+	// The current phase of operation, e.g. "create", "deploy", "start"
+	Phase string `json:"phase,omitempty"`
+
+	// The current step in the phase
+	Step int `json:"step,omitempty"`
+
+	// The total number of steps in the phase
+	Total int `json:"total,omitempty"`
+
+	// The completion percentage of the phase, in range [0, 100]
+	Percent int `json:"percent"`
+
+	// A short message describes the current step
+	Message string `json:"msg,omitempty"`
+}
+
+// Progress reports the progress of current operation. It does nothing if
+// the client doesn't understand progress records.
+func (l *ServerLog) Progress(phase string, step, total int, message string) {
+	if l == nil || l.progress == nil {
+		return
+	}
+
+	p := &Progress{Phase: phase, Step: step, Total: total, Message: message}
+	if total > 0 {
+		p.Percent = step * 100 / total
+	}
+	if p.Percent > 100 {
+		p.Percent = 100
+	}
+	l.progress(p)
+}
+
+// SendProgress writes a progress record to the data stream.
+func SendProgress(w io.Writer, p *Progress) error {
+	out := stdcopy.NewWriter(w, stdcopy.Data)
+	return json.NewEncoder(out).Encode(&record{Progress: p})
+}
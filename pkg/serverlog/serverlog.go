@@ -19,8 +19,9 @@ type Error struct {
 
 // record represents object generated from server
 type record struct {
-	Error  *Error      `json:"err,omitempty"`
-	Result interface{} `json:"obj,omitempty"`
+	Error    *Error      `json:"err,omitempty"`
+	Result   interface{} `json:"obj,omitempty"`
+	Progress *Progress   `json:"progress,omitempty"`
 }
 
 func (e *Error) Error() string {
@@ -29,16 +30,55 @@ func (e *Error) Error() string {
 
 // ServerLog encapsulate multiplexed standard output and standard error streams.
 type ServerLog struct {
-	stdout io.Writer
-	stderr io.Writer
+	stdout   io.Writer
+	stderr   io.Writer
+	data     io.Writer
+	progress func(*Progress) error
+}
+
+// StreamVersionHeader is the request header used by clients to announce
+// the latest stream framing protocol version they support.
+const StreamVersionHeader = "X-Cloudway-Stream-Version"
+
+// Options specifies stream features negotiated with the client.
+type Options struct {
+	// Send progress records to the data stream.
+	Progress bool
+
+	// The stream framing protocol version, see stdcopy.NewWriterVersion.
+	Version int
 }
 
 // New create a multiplexed server log.
 func New(w io.Writer) *ServerLog {
-	return &ServerLog{
-		stdout: stdcopy.NewWriter(w, stdcopy.Stdout),
-		stderr: stdcopy.NewWriter(w, stdcopy.Stderr),
+	return NewWithOptions(w, Options{})
+}
+
+// NewWithProgress create a multiplexed server log that also sends progress
+// records. Only use it when the client is known to understand progress
+// records, older clients fail to decode them.
+func NewWithProgress(w io.Writer) *ServerLog {
+	return NewWithOptions(w, Options{Progress: true})
+}
+
+// NewWithOptions create a multiplexed server log with negotiated options.
+func NewWithOptions(w io.Writer, opts Options) *ServerLog {
+	version := opts.Version
+	if version == 0 {
+		version = stdcopy.Version1
+	}
+
+	l := &ServerLog{
+		stdout: stdcopy.NewWriterVersion(w, stdcopy.Stdout, version),
+		stderr: stdcopy.NewWriterVersion(w, stdcopy.Stderr, version),
+		data:   stdcopy.NewWriterVersion(w, stdcopy.Data, version),
 	}
+	if opts.Progress {
+		l.progress = func(p *Progress) error {
+			return l.send(&record{Progress: p})
+		}
+	}
+	return l
 }
 
 // Encap encapsulate two streams.
@@ -68,6 +108,21 @@ func (l *ServerLog) Stderr() io.Writer {
 	}
 }
 
+// Tee returns a server log that also copies standard output and standard
+// error into the writer, such as a log file of the operation. Data and
+// progress records are sent to the client only.
+func (l *ServerLog) Tee(w io.Writer) *ServerLog {
+	if l == nil {
+		return Encap(w, w)
+	}
+	return &ServerLog{
+		stdout:   io.MultiWriter(l.stdout, w),
+		stderr:   io.MultiWriter(l.stderr, w),
+		data:     l.data,
+		progress: l.progress,
+	}
+}
+
 func (l *ServerLog) Write(p []byte) (n int, err error) {
 	if l == nil {
 		return len(p), nil
@@ -76,6 +131,23 @@ func (l *ServerLog) Write(p []byte) (n int, err error) {
 	}
 }
 
+// SendError sends an error to the data stream of the server log.
+func (l *ServerLog) SendError(err error) error {
+	return l.send(&record{Error: &Error{Message: err.Error()}})
+}
+
+// SendObject sends a result object to the data stream of the server log.
+func (l *ServerLog) SendObject(obj interface{}) error {
+	return l.send(&record{Result: obj})
+}
+
+func (l *ServerLog) send(rec *record) error {
+	if l == nil || l.data == nil {
+		return nil
+	}
+	return json.NewEncoder(l.data).Encode(rec)
+}
+
 func SendError(w io.Writer, err error) error {
 	rec := record{
 		Error: &Error{Message: err.Error()},
@@ -89,20 +161,69 @@ func SendObject(w io.Writer, obj interface{}) error {
 	return json.NewEncoder(out).Encode(&record{Result: obj})
 }
 
-func Drain(in io.Reader, dstout, dsterr io.Writer, result interface{}) (err error) {
-	data := bytes.NewBuffer(nil)
-	_, err = stdcopy.Copy(dstout, dsterr, data, in)
-	if err != nil {
+// Drain demultiplex the server log to the standard output and standard error
+// streams, and decode the result object from the data stream.
+func Drain(in io.Reader, dstout, dsterr io.Writer, result interface{}) error {
+	return DrainProgress(in, dstout, dsterr, nil, result)
+}
+
+// DrainProgress is similar to Drain but also calls the progress function
+// for each progress record received from server.
+func DrainProgress(in io.Reader, dstout, dsterr io.Writer, progress func(*Progress), result interface{}) error {
+	data := &recordWriter{progress: progress, result: result}
+	if _, err := stdcopy.Copy(dstout, dsterr, data, in); err != nil {
 		return err
 	}
+	return data.Close()
+}
+
+// recordWriter decodes newline delimited records from the data stream
+// as soon as they arrived.
+type recordWriter struct {
+	buf      []byte
+	progress func(*Progress)
+	result   interface{}
+	err      error
+}
 
-	if data.Len() != 0 {
-		rec := record{Result: result}
-		err = json.NewDecoder(data).Decode(&rec)
-		if err == nil && rec.Error != nil {
-			err = rec.Error
+func (w *recordWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
 		}
+		w.decode(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *recordWriter) Close() error {
+	if len(w.buf) != 0 {
+		w.decode(w.buf)
+		w.buf = nil
+	}
+	return w.err
+}
+
+func (w *recordWriter) decode(line []byte) {
+	if len(bytes.TrimSpace(line)) == 0 || w.err != nil {
+		return
+	}
+
+	rec := record{Result: w.result}
+	if err := json.Unmarshal(line, &rec); err != nil {
+		w.err = err
+		return
 	}
 
-	return
+	switch {
+	case rec.Progress != nil:
+		if w.progress != nil {
+			w.progress(rec.Progress)
+		}
+	case rec.Error != nil:
+		w.err = rec.Error
+	}
 }
@@ -0,0 +1,13 @@
+package serverlog_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestServerLog(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ServerLog Suite")
+}
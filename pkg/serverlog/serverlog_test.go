@@ -0,0 +1,114 @@
+package serverlog_test
+
+import (
+	"bytes"
+	"errors"
+
+	. "github.com/cloudway/platform/pkg/serverlog"
+	"github.com/cloudway/platform/pkg/stdcopy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type result struct {
+	Name string
+}
+
+var _ = Describe("ServerLog", func() {
+	var (
+		buf            *bytes.Buffer
+		stdout, stderr *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		buf = new(bytes.Buffer)
+		stdout = new(bytes.Buffer)
+		stderr = new(bytes.Buffer)
+	})
+
+	It("should demultiplex output streams and result", func() {
+		log := New(buf)
+		log.Stdout().Write([]byte("hello\n"))
+		log.Stderr().Write([]byte("world\n"))
+		Ω(SendObject(buf, &result{"test"})).Should(Succeed())
+
+		var res result
+		Ω(Drain(buf, stdout, stderr, &res)).Should(Succeed())
+		Ω(stdout.String()).Should(Equal("hello\n"))
+		Ω(stderr.String()).Should(Equal("world\n"))
+		Ω(res.Name).Should(Equal("test"))
+	})
+
+	It("should return error sent from server", func() {
+		Ω(SendError(buf, errors.New("failed"))).Should(Succeed())
+		err := Drain(buf, stdout, stderr, nil)
+		Ω(err).Should(HaveOccurred())
+		Ω(err.(*Error).Message).Should(Equal("failed"))
+	})
+
+	It("should send records with negotiated stream version", func() {
+		log := NewWithOptions(buf, Options{Version: stdcopy.Version2})
+		log.Stdout().Write([]byte("hello\n"))
+		Ω(log.SendObject(&result{"test"})).Should(Succeed())
+		Ω(buf.Bytes()[1]).Should(BeEquivalentTo(stdcopy.Version2))
+
+		var res result
+		Ω(Drain(buf, stdout, stderr, &res)).Should(Succeed())
+		Ω(stdout.String()).Should(Equal("hello\n"))
+		Ω(res.Name).Should(Equal("test"))
+	})
+
+	It("should copy output streams to tee writer", func() {
+		var copied bytes.Buffer
+		log := New(buf).Tee(&copied)
+		log.Stdout().Write([]byte("hello\n"))
+		log.Stderr().Write([]byte("world\n"))
+		Ω(log.SendObject(&result{"test"})).Should(Succeed())
+		Ω(copied.String()).Should(Equal("hello\nworld\n"))
+
+		var res result
+		Ω(Drain(buf, stdout, stderr, &res)).Should(Succeed())
+		Ω(stdout.String()).Should(Equal("hello\n"))
+		Ω(stderr.String()).Should(Equal("world\n"))
+		Ω(res.Name).Should(Equal("test"))
+	})
+
+	Context("with progress", func() {
+		It("should not send progress records unless enabled", func() {
+			log := New(buf)
+			log.Progress("create", 1, 2, "step 1")
+			Ω(buf.Len()).Should(BeZero())
+		})
+
+		It("should report progress records", func() {
+			log := NewWithProgress(buf)
+			log.Progress("create", 1, 4, "step 1")
+			log.Stdout().Write([]byte("hello\n"))
+			log.Progress("create", 4, 4, "step 4")
+			Ω(SendObject(buf, &result{"test"})).Should(Succeed())
+
+			var res result
+			var progress []*Progress
+			err := DrainProgress(buf, stdout, stderr, func(p *Progress) {
+				progress = append(progress, p)
+			}, &res)
+
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(stdout.String()).Should(Equal("hello\n"))
+			Ω(res.Name).Should(Equal("test"))
+			Ω(progress).Should(HaveLen(2))
+			Ω(*progress[0]).Should(Equal(Progress{Phase: "create", Step: 1, Total: 4, Percent: 25, Message: "step 1"}))
+			Ω(progress[1].Percent).Should(Equal(100))
+		})
+
+		It("should ignore progress records when draining without handler", func() {
+			log := NewWithProgress(buf)
+			log.Progress("deploy", 1, 2, "")
+			Ω(SendObject(buf, &result{"test"})).Should(Succeed())
+
+			var res result
+			Ω(Drain(buf, stdout, stderr, &res)).Should(Succeed())
+			Ω(res.Name).Should(Equal("test"))
+		})
+	})
+})
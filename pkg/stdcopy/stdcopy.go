@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 
 	"github.com/Sirupsen/logrus"
@@ -26,12 +27,44 @@ const (
 	stdWriterPrefixLen = 8
 	stdWriterFdIndex   = 0
 	stdWriterSizeIndex = 4
+
+	// The version 2 frame header has a version byte and a CRC-32 checksum
+	// of the frame payload appended to the version 1 header.
+	stdWriterV2PrefixLen   = 12
+	stdWriterVersionIndex  = 1
+	stdWriterChecksumIndex = 8
 )
 
+// Supported framing protocol versions. Version 1 is compatible with the
+// docker multiplexing protocol. Version 2 adds a version byte and a per
+// frame checksum so that corrupted streams can be detected.
+const (
+	Version1 = 1
+	Version2 = 2
+
+	// Version is the latest protocol version supported by this package.
+	Version = Version2
+)
+
+// MaxFrameSize is the maximum payload size of a version 2 frame. Version 2
+// frames are buffered to verify the checksum, so larger writes are split
+// into multiple frames, and larger frames are rejected by Copy without
+// trusting the size in a possibly corrupted header.
+const MaxFrameSize = 1 << 20
+
+// ErrFrameTooLarge is returned by Copy when the size of a version 2 frame
+// exceeds MaxFrameSize.
+var ErrFrameTooLarge = errors.New("stdcopy: frame too large")
+
+// ErrChecksum is returned by Copy when a frame payload doesn't match
+// the checksum in the frame header.
+var ErrChecksum = errors.New("stdcopy: frame checksum mismatch")
+
 // stdWriter is wrapper of io.Writer with extra customized info.
 type stdWriter struct {
 	io.Writer
-	prefix byte
+	prefix  byte
+	version byte
 }
 
 type flusher interface {
@@ -54,24 +87,44 @@ func (w *stdWriter) Write(p []byte) (n int, err error) {
 		return 0, nil
 	}
 
+	for w.version >= Version2 && len(p)-n > MaxFrameSize {
+		var nw int
+		nw, err = w.writeFrame(p[n : n+MaxFrameSize])
+		n += nw
+		if err != nil {
+			return n, err
+		}
+	}
+	nw, err := w.writeFrame(p[n:])
+	return n + nw, err
+}
+
+// writeFrame writes the buffer as a single frame.
+func (w *stdWriter) writeFrame(p []byte) (n int, err error) {
 	const chunkSize = 0x1000 // 4096
 	var (
-		buf      [chunkSize]byte
-		size     = len(p)
-		copySize int
+		buf       [chunkSize]byte
+		size      = len(p)
+		prefixLen = stdWriterPrefixLen
+		copySize  int
 	)
 
 	// fill in header
 	buf[stdWriterFdIndex] = w.prefix
 	binary.BigEndian.PutUint32(buf[stdWriterSizeIndex:], uint32(size))
+	if w.version >= Version2 {
+		prefixLen = stdWriterV2PrefixLen
+		buf[stdWriterVersionIndex] = w.version
+		binary.BigEndian.PutUint32(buf[stdWriterChecksumIndex:], crc32.ChecksumIEEE(p))
+	}
 
 	// write first chunk
-	if copySize = chunkSize - stdWriterPrefixLen; copySize > size {
+	if copySize = chunkSize - prefixLen; copySize > size {
 		copySize = size
 	}
-	copy(buf[stdWriterPrefixLen:], p[:copySize])
-	n, err = w.Writer.Write(buf[:copySize+stdWriterPrefixLen])
-	n -= stdWriterPrefixLen
+	copy(buf[prefixLen:], p[:copySize])
+	n, err = w.Writer.Write(buf[:copySize+prefixLen])
+	n -= prefixLen
 	if n < 0 {
 		n = 0
 	}
@@ -103,9 +156,20 @@ func (w *stdWriter) Write(p []byte) (n int, err error) {
 // `t` indicates the id of the stream to encapsulate.
 // It can be stdcopy.Stdin, stdcopy.Stdout, stdcopy.Stderr.
 func NewWriter(w io.Writer, t StdType) io.Writer {
+	return NewWriterVersion(w, t, Version1)
+}
+
+// NewWriterVersion is similar to NewWriter but uses the given framing
+// protocol version. Version 2 frames can only be demultiplexed by a Copy
+// that supports it, so only use it after the peer has announced support.
+func NewWriterVersion(w io.Writer, t StdType, version int) io.Writer {
+	if version < Version1 || version > Version {
+		version = Version1
+	}
 	return &stdWriter{
-		Writer: w,
-		prefix: byte(t),
+		Writer:  w,
+		prefix:  byte(t),
+		version: byte(version),
 	}
 }
 
@@ -118,18 +182,22 @@ func NewWriter(w io.Writer, t StdType) io.Writer {
 // Copy will read until it hits EOF on `src`. It will then return a nil error.
 // In other words: if `err` is non nil, it indicates a real underlying error.
 //
+// Both version 1 and version 2 frames are accepted, the version is detected
+// for each frame. ErrChecksum is returned if a version 2 frame is corrupted.
+//
 // `written` will hold the total number of bytes written to `dstout` and `dsterr`.
 func Copy(dstout, dsterr, data io.Writer, src io.Reader) (written int64, err error) {
 	var (
-		rd     = bufio.NewReader(src)
-		wr     io.Writer
-		header [stdWriterPrefixLen]byte
-		er, ew error
+		rd       = bufio.NewReader(src)
+		wr       io.Writer
+		header   [stdWriterV2PrefixLen]byte
+		checksum uint32
+		er, ew   error
 	)
 
 	for {
 		// Make sure we have at least a full header
-		_, er = io.ReadFull(rd, header[:])
+		_, er = io.ReadFull(rd, header[:stdWriterPrefixLen])
 		if er == io.EOF {
 			return written, nil
 		}
@@ -138,6 +206,22 @@ func Copy(dstout, dsterr, data io.Writer, src io.Reader) (written int64, err err
 			return written, er
 		}
 
+		// Check the version byte to know whether there is a checksum
+		version := header[stdWriterVersionIndex]
+		switch version {
+		case 0:
+			// version 1 frame, the version byte is always zero
+		case Version2:
+			_, er = io.ReadFull(rd, header[stdWriterPrefixLen:stdWriterV2PrefixLen])
+			if er != nil {
+				logrus.Debugf("Error reading header: %s", er)
+				return written, er
+			}
+			checksum = binary.BigEndian.Uint32(header[stdWriterChecksumIndex:])
+		default:
+			return written, fmt.Errorf("Unsupported stream version: %d", version)
+		}
+
 		// Check the first byte to know where to write
 		switch StdType(header[stdWriterFdIndex]) {
 		case Stdin:
@@ -158,7 +242,11 @@ func Copy(dstout, dsterr, data io.Writer, src io.Reader) (written int64, err err
 		logrus.Debugf("framesize: %d", frameSize)
 
 		// Write to output stream
-		if wr != nil {
+		if version == Version2 {
+			var nw int64
+			nw, ew = copyChecked(wr, rd, int64(frameSize), checksum)
+			written += nw
+		} else if wr != nil {
 			var nw int64
 			nw, ew = io.CopyN(wr, rd, int64(frameSize))
 			written += nw
@@ -173,3 +261,24 @@ func Copy(dstout, dsterr, data io.Writer, src io.Reader) (written int64, err err
 		}
 	}
 }
+
+// copyChecked reads a version 2 frame payload and verifies its checksum
+// before writing it to the output stream, so corrupted data never reaches
+// the output.
+func copyChecked(wr io.Writer, rd io.Reader, size int64, checksum uint32) (int64, error) {
+	if size > MaxFrameSize {
+		return 0, ErrFrameTooLarge
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(rd, buf); err != nil {
+		return 0, err
+	}
+	if crc32.ChecksumIEEE(buf) != checksum {
+		return 0, ErrChecksum
+	}
+	if wr == nil {
+		return size, nil
+	}
+	n, err := wr.Write(buf)
+	return int64(n), err
+}
@@ -0,0 +1,13 @@
+package stdcopy_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestStdcopy(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Stdcopy Suite")
+}
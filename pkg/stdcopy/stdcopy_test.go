@@ -0,0 +1,96 @@
+package stdcopy_test
+
+import (
+	"bytes"
+
+	. "github.com/cloudway/platform/pkg/stdcopy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stdcopy", func() {
+	var (
+		buf                  *bytes.Buffer
+		stdout, stderr, data *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		buf = new(bytes.Buffer)
+		stdout = new(bytes.Buffer)
+		stderr = new(bytes.Buffer)
+		data = new(bytes.Buffer)
+	})
+
+	multiplex := func(version int) {
+		NewWriterVersion(buf, Stdout, version).Write([]byte("hello"))
+		NewWriterVersion(buf, Stderr, version).Write([]byte("world"))
+		NewWriterVersion(buf, Data, version).Write([]byte("{}"))
+	}
+
+	It("should demultiplex version 1 frames", func() {
+		multiplex(Version1)
+		Ω(buf.Bytes()[1]).Should(BeZero())
+		n, err := Copy(stdout, stderr, data, buf)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(n).Should(BeEquivalentTo(12))
+		Ω(stdout.String()).Should(Equal("hello"))
+		Ω(stderr.String()).Should(Equal("world"))
+		Ω(data.String()).Should(Equal("{}"))
+	})
+
+	It("should demultiplex version 2 frames", func() {
+		multiplex(Version2)
+		Ω(buf.Bytes()[1]).Should(BeEquivalentTo(Version2))
+		n, err := Copy(stdout, stderr, data, buf)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(n).Should(BeEquivalentTo(12))
+		Ω(stdout.String()).Should(Equal("hello"))
+		Ω(stderr.String()).Should(Equal("world"))
+		Ω(data.String()).Should(Equal("{}"))
+	})
+
+	It("should demultiplex mixed version frames", func() {
+		NewWriter(buf, Stdout).Write([]byte("hello "))
+		NewWriterVersion(buf, Stdout, Version2).Write([]byte("world"))
+		_, err := Copy(stdout, stderr, data, buf)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(stdout.String()).Should(Equal("hello world"))
+	})
+
+	It("should detect corrupted version 2 frames", func() {
+		NewWriterVersion(buf, Stdout, Version2).Write([]byte("hello"))
+		corrupted := buf.Bytes()
+		corrupted[len(corrupted)-1] ^= 0xff
+		_, err := Copy(stdout, stderr, data, buf)
+		Ω(err).Should(Equal(ErrChecksum))
+		Ω(stdout.Len()).Should(BeZero())
+	})
+
+	It("should split large writes into multiple version 2 frames", func() {
+		payload := bytes.Repeat([]byte("x"), MaxFrameSize*2+10)
+		n, err := NewWriterVersion(buf, Stdout, Version2).Write(payload)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(n).Should(Equal(len(payload)))
+		Ω(buf.Len()).Should(Equal(len(payload) + 3*12))
+
+		_, err = Copy(stdout, stderr, data, buf)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(stdout.Bytes()).Should(Equal(payload))
+	})
+
+	It("should reject oversized version 2 frames", func() {
+		NewWriterVersion(buf, Stdout, Version2).Write([]byte("hello"))
+		header := buf.Bytes()
+		header[4], header[5], header[6], header[7] = 0xff, 0xff, 0xff, 0xff
+		_, err := Copy(stdout, stderr, data, buf)
+		Ω(err).Should(Equal(ErrFrameTooLarge))
+		Ω(stdout.Len()).Should(BeZero())
+	})
+
+	It("should reject unknown versions", func() {
+		NewWriter(buf, Stdout).Write([]byte("hello"))
+		buf.Bytes()[1] = 9
+		_, err := Copy(stdout, stderr, data, buf)
+		Ω(err).Should(HaveOccurred())
+	})
+})
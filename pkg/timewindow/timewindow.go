@@ -0,0 +1,188 @@
+// Package timewindow parses recurring weekly time windows, such as
+// "Mon-Fri 09:00-17:00", and checks whether a time falls in a window.
+//
+// A window consists of optional week days, a time range and an optional
+// time zone name. Week days are separated by commas or given as a range,
+// and all days are included if omitted. A time range whose end is before
+// the start crosses midnight, and belongs to the day it starts. Times are
+// in the local time zone unless a zone such as "Asia/Shanghai" is given.
+//
+//	09:00-17:00
+//	Mon-Fri 09:00-17:00
+//	Sat,Sun 22:00-06:00 UTC
+package timewindow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window is a recurring weekly time window.
+type Window struct {
+	Days       [7]bool
+	Start, End time.Duration // offsets from midnight
+	Location   *time.Location
+
+	text string
+}
+
+// Parse parses a time window.
+func Parse(s string) (*Window, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 3 {
+		return nil, fmt.Errorf("invalid time window: %q", s)
+	}
+
+	w := &Window{Location: time.Local, text: strings.Join(fields, " ")}
+
+	// the time range is the first field that contains a colon
+	i := 0
+	for i < len(fields) && !strings.Contains(fields[i], ":") {
+		i++
+	}
+	if i == len(fields) || i > 1 {
+		return nil, fmt.Errorf("invalid time window: %q", s)
+	}
+
+	if i == 1 {
+		if err := w.parseDays(fields[0]); err != nil {
+			return nil, fmt.Errorf("invalid time window: %q: %v", s, err)
+		}
+	} else {
+		for d := range w.Days {
+			w.Days[d] = true
+		}
+	}
+
+	if err := w.parseRange(fields[i]); err != nil {
+		return nil, fmt.Errorf("invalid time window: %q: %v", s, err)
+	}
+
+	if i+1 < len(fields) {
+		loc, err := time.LoadLocation(fields[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid time window: %q: %v", s, err)
+		}
+		w.Location = loc
+	}
+	return w, nil
+}
+
+// ParseList parses a list of time windows.
+func ParseList(list []string) ([]*Window, error) {
+	windows := make([]*Window, 0, len(list))
+	for _, s := range list {
+		w, err := Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+func (w *Window) parseDays(s string) error {
+	for _, part := range strings.Split(s, ",") {
+		if i := strings.Index(part, "-"); i >= 0 {
+			from, err := parseWeekday(part[:i])
+			if err != nil {
+				return err
+			}
+			to, err := parseWeekday(part[i+1:])
+			if err != nil {
+				return err
+			}
+			for d := from; ; d = (d + 1) % 7 {
+				w.Days[d] = true
+				if d == to {
+					break
+				}
+			}
+		} else {
+			d, err := parseWeekday(part)
+			if err != nil {
+				return err
+			}
+			w.Days[d] = true
+		}
+	}
+	return nil
+}
+
+// parseWeekday parses a week day name, which may be abbreviated to three
+// or more letters.
+func parseWeekday(s string) (int, error) {
+	name := strings.ToLower(s)
+	if len(name) >= 3 {
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if strings.HasPrefix(strings.ToLower(d.String()), name) {
+				return int(d), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unknown week day %q", s)
+}
+
+func (w *Window) parseRange(s string) (err error) {
+	i := strings.Index(s, "-")
+	if i < 0 {
+		return fmt.Errorf("missing end time")
+	}
+	if w.Start, err = parseClock(s[:i]); err != nil {
+		return err
+	}
+	if w.End, err = parseClock(s[i+1:]); err != nil {
+		return err
+	}
+	if w.Start == w.End {
+		return fmt.Errorf("empty time range")
+	}
+	return nil
+}
+
+// parseClock parses a clock time HH:MM, "24:00" is allowed as the end of
+// a day.
+func parseClock(s string) (time.Duration, error) {
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	h, err1 := strconv.Atoi(s[:i])
+	m, err2 := strconv.Atoi(s[i+1:])
+	if err1 != nil || err2 != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// Contains returns true if the time falls in the window.
+func (w *Window) Contains(t time.Time) bool {
+	t = t.In(w.Location)
+	day := int(t.Weekday())
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+
+	if w.Start < w.End {
+		return w.Days[day] && offset >= w.Start && offset < w.End
+	}
+
+	// the window crosses midnight
+	prev := (day + 6) % 7
+	return (w.Days[day] && offset >= w.Start) || (w.Days[prev] && offset < w.End)
+}
+
+func (w *Window) String() string {
+	return w.text
+}
+
+// Any returns true if the time falls in any of the windows.
+func Any(windows []*Window, t time.Time) bool {
+	for _, w := range windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,13 @@
+package timewindow_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestTimeWindow(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "TimeWindow Suite")
+}
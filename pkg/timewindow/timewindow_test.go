@@ -0,0 +1,84 @@
+package timewindow_test
+
+import (
+	"time"
+
+	. "github.com/cloudway/platform/pkg/timewindow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// 2017-03-06 is a Monday
+func at(day int, clock string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", "2017-03-06 "+clock, time.UTC)
+	Expect(err).NotTo(HaveOccurred())
+	return t.AddDate(0, 0, day)
+}
+
+var _ = Describe("TimeWindow", func() {
+	It("should include all days if omitted", func() {
+		w, err := Parse("09:00-17:00 UTC")
+		Expect(err).NotTo(HaveOccurred())
+		for day := 0; day < 7; day++ {
+			Expect(w.Contains(at(day, "09:00"))).To(BeTrue())
+			Expect(w.Contains(at(day, "16:59"))).To(BeTrue())
+			Expect(w.Contains(at(day, "17:00"))).To(BeFalse())
+			Expect(w.Contains(at(day, "08:59"))).To(BeFalse())
+		}
+	})
+
+	It("should parse week day ranges and lists", func() {
+		w, err := Parse("Mon-Wed,friday 09:00-17:00 UTC")
+		Expect(err).NotTo(HaveOccurred())
+		expected := []bool{true, true, true, false, true, false, false}
+		for day, ok := range expected {
+			Expect(w.Contains(at(day, "12:00"))).To(Equal(ok), "day %d", day)
+		}
+	})
+
+	It("should wrap week day ranges", func() {
+		w, err := Parse("Sat-Mon 00:00-24:00 UTC")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Contains(at(0, "23:59"))).To(BeTrue())
+		Expect(w.Contains(at(1, "12:00"))).To(BeFalse())
+		Expect(w.Contains(at(5, "00:00"))).To(BeTrue())
+		Expect(w.Contains(at(6, "12:00"))).To(BeTrue())
+	})
+
+	It("should handle windows crossing midnight", func() {
+		w, err := Parse("Fri 22:00-06:00 UTC")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Contains(at(4, "21:59"))).To(BeFalse())
+		Expect(w.Contains(at(4, "22:00"))).To(BeTrue())
+		Expect(w.Contains(at(5, "05:59"))).To(BeTrue())
+		Expect(w.Contains(at(5, "06:00"))).To(BeFalse())
+		Expect(w.Contains(at(5, "23:00"))).To(BeFalse())
+	})
+
+	It("should convert time to the window location", func() {
+		w, err := Parse("09:00-10:00 Asia/Shanghai")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Contains(at(0, "01:30"))).To(BeTrue())
+		Expect(w.Contains(at(0, "09:30"))).To(BeFalse())
+	})
+
+	It("should reject invalid windows", func() {
+		for _, s := range []string{
+			"", "Mon-Fri", "09:00", "9-17", "Mo 09:00-17:00", "Mon-Foo 09:00-17:00",
+			"09:00-09:00", "09:00-25:00", "09:60-10:00", "09:00-17:00 Nowhere/City",
+			"Mon Tue 09:00-17:00",
+		} {
+			_, err := Parse(s)
+			Expect(err).To(HaveOccurred(), s)
+		}
+	})
+
+	It("should check any of the windows", func() {
+		ws, err := ParseList([]string{"Mon 09:00-10:00 UTC", "Tue 09:00-10:00 UTC"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(Any(ws, at(0, "09:30"))).To(BeTrue())
+		Expect(Any(ws, at(1, "09:30"))).To(BeTrue())
+		Expect(Any(ws, at(2, "09:30"))).To(BeFalse())
+		Expect(Any(nil, at(2, "09:30"))).To(BeFalse())
+	})
+})
@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	dockerevents "github.com/docker/engine-api/types/events"
+
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/container/docker"
+)
+
+// Watch consumes container lifecycle events published by the broker and
+// updates the proxy routes immediately, so that new containers are
+// routable without delay. It returns when the event channel is closed.
+//
+// If a docker engine is given, the proxy is synchronized with running
+// containers first, and docker events are also consumed for containers
+// not managed by the broker, such as containers restarted by the docker
+// daemon and virtual host containers. Static mappings, redirects and
+// certificates are updated when configuration reloaded, and redirects are
+// expired periodically. So no out-of-band proxy updater is required.
+func Watch(ctx context.Context, engine container.Engine, events <-chan broker.Event, proxy Proxy) {
+	defer proxy.Close()
+
+	var dockerEvents <-chan dockerevents.Message
+	if cli, ok := engine.(docker.DockerEngine); ok {
+		if err := Sync(ctx, cli, proxy); err != nil {
+			logrus.WithError(err).Error("Failed to synchronize proxy")
+		}
+		dockerEvents = watchDocker(ctx, cli)
+	}
+
+	reload := make(chan struct{}, 1)
+	config.OnReload(func() {
+		select {
+		case reload <- struct{}{}:
+		default:
+		}
+	})
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		var event broker.Event
+		var ok bool
+		select {
+		case event, ok = <-events:
+			if !ok {
+				return
+			}
+		case e := <-dockerEvents:
+			if err := handleEvent(proxy, ctx, engine.(docker.DockerEngine), e); err != nil {
+				logrus.WithError(err).Errorf("Failed to update proxy for %s", e.Actor.ID)
+			}
+			continue
+		case <-reload:
+			if err := update(proxy); err != nil {
+				logrus.WithError(err).Error("Failed to update proxy")
+			}
+			continue
+		case <-ticker.C:
+			if err := UpdateRedirects(proxy); err != nil {
+				logrus.WithError(err).Error("Failed to update redirects")
+			}
+			continue
+		}
+
+		var err error
+		switch event.Type {
+		case broker.ContainerStarted, broker.ContainerUpdated:
+			logrus.Debugf("container started: %s", event.Container.ID())
+			err = handleStart(proxy, ctx, event.Container)
+		case broker.ContainerStopped:
+			logrus.Debugf("container stopped: %s", event.Container.ID())
+			err = handleDie(proxy, ctx, event.Container)
+		case broker.ContainerDestroyed:
+			logrus.Debugf("container destroyed: %s", event.Container.ID())
+			err = handleStop(proxy, event.Container.ID())
+		}
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to update proxy for %s", event.Container.Name())
+		}
+	}
+}
+
+// watchDocker streams docker container events, reopening the event stream
+// if interrupted. The channel is never closed.
+func watchDocker(ctx context.Context, cli docker.DockerEngine) <-chan dockerevents.Message {
+	ch := make(chan dockerevents.Message)
+	go func() {
+		for {
+			err := streamEvents(ctx, cli, ch)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				logrus.WithError(err).Warn("Docker event stream interrupted")
+			}
+			select {
+			case <-time.After(5 * time.Second):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,33 @@
+package proxy
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CheckBackend checks whether a backend is reachable by connecting to
+// the backend address within the given timeout.
+func CheckBackend(backend string, timeout time.Duration) error {
+	u, err := url.Parse(backend)
+	if err != nil {
+		return err
+	}
+
+	addr := u.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		host := strings.Trim(u.Host, "[]")
+		if u.Scheme == "https" {
+			addr = net.JoinHostPort(host, "443")
+		} else {
+			addr = net.JoinHostPort(host, "80")
+		}
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
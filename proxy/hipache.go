@@ -7,14 +7,21 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/garyburd/redigo/redis"
 
+	"github.com/cloudway/platform/broker"
 	"github.com/cloudway/platform/pkg/manifest"
 )
 
+// The hipache proxy stores routes into the redis database used by Hipache.
+// Hipache picks a random backend of the frontend, so weighted backends are
+// repeated by their weights. Access policies and HTTP settings are not
+// supported, and special backends such as the maintenance and redirect
+// backends are skipped.
 type hipacheProxy struct {
 	conn redis.Conn
 }
 
 func init() {
+	broker.RegisterProxyFeatures("hipache", broker.ProxyWeights)
 	proxyRegistry["hipache"] = func(u *url.URL) (Proxy, error) {
 		r, err := redis.Dial("tcp", u.Host)
 		if err != nil {
@@ -37,13 +44,18 @@ func (px *hipacheProxy) AddEndpoints(id string, endpoints []*manifest.Endpoint)
 	// add new endpoints
 	for _, ep := range endpoints {
 		for _, m := range ep.ProxyMappings {
-			if m.Protocol == "http" {
+			if m.Protocol == "http" && m.Weight >= 0 {
+				// drained backends are skipped
 				frontend, backend := m.Frontend, m.Backend
+				if u, err := url.Parse(backend); err != nil || u.Host == "" {
+					logrus.Debugf("skip unsupported backend %s", backend)
+					continue
+				}
 				if i := strings.IndexRune(frontend, '/'); i != -1 {
 					backend = backend + "#" + frontend[i:]
 					frontend = frontend[0:i]
 				}
-				if err := addEndpoint(px.conn, id, frontend, backend); err != nil {
+				if err := addEndpoint(px.conn, id, frontend, backend, m.Weight); err != nil {
 					return err
 				}
 			}
@@ -53,7 +65,9 @@ func (px *hipacheProxy) AddEndpoints(id string, endpoints []*manifest.Endpoint)
 	return nil
 }
 
-func addEndpoint(conn redis.Conn, id, frontend, backend string) error {
+// addEndpoint adds the backend to the frontend, which is repeated by the
+// weight if the weight is set. The container record is added once.
+func addEndpoint(conn redis.Conn, id, frontend, backend string, weight int) error {
 	key := "frontend:" + frontend
 	ckey := "container:" + id
 
@@ -70,8 +84,12 @@ func addEndpoint(conn redis.Conn, id, frontend, backend string) error {
 		logrus.Debugf("add %s", frontend)
 	}
 
-	// add endpoint record
-	_, err = conn.Do("RPUSH", key, backend)
+	// add endpoint records
+	args := []interface{}{key, backend}
+	for i := 1; i < weight; i++ {
+		args = append(args, backend)
+	}
+	_, err = conn.Do("RPUSH", args...)
 	if err != nil {
 		return err
 	} else {
@@ -132,6 +150,42 @@ func (px *hipacheProxy) RemoveEndpoints(id string) error {
 	return err
 }
 
+func (px *hipacheProxy) Endpoints(id string) ([]*manifest.ProxyMapping, error) {
+	r, err := redis.Values(px.conn.Do("LRANGE", "container:"+id, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	var vs []string
+	if err = redis.ScanSlice(r, &vs); err != nil {
+		return nil, err
+	}
+
+	var mappings []*manifest.ProxyMapping
+	for _, rec := range vs {
+		kv := strings.SplitN(rec, " ", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		frontend := strings.TrimPrefix(kv[0], "frontend:")
+		backend := kv[1]
+		if i := strings.IndexRune(backend, '#'); i != -1 {
+			frontend = frontend + backend[i+1:]
+			backend = backend[:i]
+		}
+		mappings = append(mappings, &manifest.ProxyMapping{
+			Frontend: frontend,
+			Backend:  backend,
+			Protocol: "http",
+		})
+	}
+	return mappings, nil
+}
+
+func (px *hipacheProxy) SetCert(host string, cert, key []byte) error {
+	return ErrCertNotSupported
+}
+
 func (px *hipacheProxy) Reset() error {
 	// remove all keys from redis database
 	_, err := px.conn.Do("FLUSHALL")
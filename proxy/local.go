@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// LocalProxy is an HTTP reverse proxy running in the process, which keeps
+// routes in memory. It's used by the development mode so no external proxy
+// is required. Frontends are matched by host and the longest path prefix,
+// and the path prefix is replaced by the backend path like the nginx proxy.
+// Weights, access policies and HTTP settings are not applied, and TLS is
+// not supported.
+type LocalProxy struct {
+	mu     sync.RWMutex
+	routes map[string][]*manifest.ProxyMapping
+}
+
+// NewLocalProxy creates a local proxy without routes.
+func NewLocalProxy() *LocalProxy {
+	return &LocalProxy{routes: make(map[string][]*manifest.ProxyMapping)}
+}
+
+func (px *LocalProxy) Close() error {
+	return nil
+}
+
+func (px *LocalProxy) AddEndpoints(id string, endpoints []*manifest.Endpoint) error {
+	var mappings []*manifest.ProxyMapping
+	for _, ep := range endpoints {
+		for _, m := range ep.ProxyMappings {
+			if m.Protocol == "http" && m.Weight >= 0 {
+				mappings = append(mappings, &manifest.ProxyMapping{
+					Frontend: m.Frontend,
+					Backend:  m.Backend,
+					Protocol: m.Protocol,
+				})
+			}
+		}
+	}
+
+	px.mu.Lock()
+	defer px.mu.Unlock()
+	if len(mappings) == 0 {
+		delete(px.routes, id)
+	} else {
+		px.routes[id] = mappings
+	}
+	return nil
+}
+
+func (px *LocalProxy) RemoveEndpoints(id string) error {
+	px.mu.Lock()
+	delete(px.routes, id)
+	px.mu.Unlock()
+	return nil
+}
+
+func (px *LocalProxy) Endpoints(id string) ([]*manifest.ProxyMapping, error) {
+	px.mu.RLock()
+	defer px.mu.RUnlock()
+	return append([]*manifest.ProxyMapping(nil), px.routes[id]...), nil
+}
+
+func (px *LocalProxy) SetCert(host string, cert, key []byte) error {
+	return ErrCertNotSupported
+}
+
+func (px *LocalProxy) Reset() error {
+	px.mu.Lock()
+	px.routes = make(map[string][]*manifest.ProxyMapping)
+	px.mu.Unlock()
+	return nil
+}
+
+// ServeHTTP proxies the request to one of the backends of the matching
+// frontend, chosen at random.
+func (px *LocalProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	backend, prefix := px.match(r.Host, r.URL.Path)
+	if backend == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	rp := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			path := strings.TrimPrefix(req.URL.Path, prefix)
+			req.URL.Scheme = backend.Scheme
+			req.URL.Host = backend.Host
+			req.URL.Path = strings.TrimSuffix(backend.Path, "/") + "/" + strings.TrimPrefix(path, "/")
+			req.Header.Set("X-Forwarded-Proto", "http")
+		},
+	}
+	rp.ServeHTTP(w, r)
+}
+
+func (px *LocalProxy) match(host, path string) (*url.URL, string) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	px.mu.RLock()
+	defer px.mu.RUnlock()
+
+	var prefix string
+	var backends []*url.URL
+	for _, mappings := range px.routes {
+		for _, m := range mappings {
+			fhost, fpath := m.Frontend, "/"
+			if i := strings.IndexRune(fhost, '/'); i != -1 {
+				fhost, fpath = fhost[:i], fhost[i:]
+			}
+			if fpath != "/" {
+				fpath = strings.TrimSuffix(fpath, "/")
+			}
+			if fhost != host || !hasPathPrefix(path, fpath) || len(fpath) < len(prefix) {
+				continue
+			}
+			u, err := url.Parse(m.Backend)
+			if err != nil || u.Host == "" {
+				continue
+			}
+			if len(fpath) > len(prefix) {
+				prefix, backends = fpath, nil
+			}
+			backends = append(backends, u)
+		}
+	}
+
+	if len(backends) == 0 {
+		return nil, ""
+	}
+	return backends[rand.Intn(len(backends))], prefix
+}
+
+func hasPathPrefix(path, prefix string) bool {
+	return prefix == "/" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
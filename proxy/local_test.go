@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+var _ = Describe("Local proxy", func() {
+	var (
+		backend *httptest.Server
+		px      *LocalProxy
+	)
+
+	BeforeEach(func() {
+		backend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.URL.Path))
+		}))
+		px = NewLocalProxy()
+	})
+
+	AfterEach(func() {
+		backend.Close()
+	})
+
+	var get = func(host, path string) (int, string) {
+		req := httptest.NewRequest("GET", "http://"+host+path, nil)
+		w := httptest.NewRecorder()
+		px.ServeHTTP(w, req)
+		body, _ := ioutil.ReadAll(w.Body)
+		return w.Code, string(body)
+	}
+
+	It("should route requests by host and the longest path prefix", func() {
+		Expect(px.AddEndpoints("c1", []*manifest.Endpoint{{ProxyMappings: []*manifest.ProxyMapping{
+			{Frontend: "app-test.localhost", Backend: backend.URL + "/", Protocol: "http"},
+			{Frontend: "app-test.localhost/admin", Backend: backend.URL + "/console", Protocol: "http"},
+		}}})).To(Succeed())
+
+		code, body := get("app-test.localhost:8000", "/index.html")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(Equal("/index.html"))
+
+		_, body = get("app-test.localhost", "/admin/users")
+		Expect(body).To(Equal("/console/users"))
+
+		_, body = get("app-test.localhost", "/administrator")
+		Expect(body).To(Equal("/administrator"))
+
+		code, _ = get("other-test.localhost", "/")
+		Expect(code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should not route to removed endpoints", func() {
+		Expect(px.AddEndpoints("c1", []*manifest.Endpoint{{ProxyMappings: []*manifest.ProxyMapping{
+			{Frontend: "app-test.localhost", Backend: backend.URL, Protocol: "http"},
+		}}})).To(Succeed())
+		Expect(px.RemoveEndpoints("c1")).To(Succeed())
+
+		code, _ := get("app-test.localhost", "/")
+		Expect(code).To(Equal(http.StatusNotFound))
+	})
+})
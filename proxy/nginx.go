@@ -0,0 +1,620 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// The nginx proxy writes all routes into a single configuration file that
+// should be included from the main nginx configuration, and reloads nginx
+// on each change. The proxy URL has the form "nginx:///etc/nginx/conf.d",
+// and the reload command can be configured by the "proxy.reload" key.
+// Routes are saved in a state file that is shared by all processes using
+// the same directory, and changes are serialized by a file lock.
+// Applications in maintenance mode respond with 503 and the HTML page
+// configured by the "proxy.maintenance_page" key. Access policies are
+// enforced by allow and deny directives, and basic auth users are written
+// to password files in the "auth" directory. HTTPS redirect and HSTS are
+// only applied to hosts with certificates. WebSocket connections are
+// upgraded if enabled for the application, and gRPC requests are passed
+// to backends over HTTP/2, which nginx only accepts on the TLS listener.
+type nginxProxy struct {
+	mu          sync.Mutex
+	dir         string
+	reload      []string
+	routes      map[string][]*manifest.ProxyMapping
+	maintenance string
+}
+
+const (
+	nginxConfFile  = "cloudway.conf"
+	nginxStateFile = "cloudway.json"
+	nginxLockFile  = "cloudway.lock"
+	nginxCertDir   = "certs"
+	nginxAuthDir   = "auth"
+)
+
+func init() {
+	broker.RegisterProxyFeatures("nginx", broker.ProxyMaintenance|broker.ProxyRedirect|broker.ProxyWeights)
+	proxyRegistry["nginx"] = func(u *url.URL) (Proxy, error) {
+		if u.Path == "" {
+			return nil, fmt.Errorf("Missing nginx configuration directory in proxy URL")
+		}
+		reload := strings.Fields(config.GetOrDefault("proxy.reload", "nginx -s reload"))
+		px, err := newNginxProxy(u.Path, reload)
+		if err != nil {
+			return nil, err
+		}
+		px.maintenance = config.Get("proxy.maintenance_page")
+		return px, nil
+	}
+}
+
+func newNginxProxy(dir string, reload []string) (*nginxProxy, error) {
+	if err := os.MkdirAll(filepath.Join(dir, nginxCertDir), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, nginxAuthDir), 0755); err != nil {
+		return nil, err
+	}
+
+	px := &nginxProxy{
+		dir:    dir,
+		reload: reload,
+		routes: make(map[string][]*manifest.ProxyMapping),
+	}
+	if err := px.load(); err != nil {
+		return nil, err
+	}
+	return px, nil
+}
+
+// load loads routes from the state file. The state file is shared by all
+// processes managing the same nginx configuration directory, so routes
+// must be reloaded before each change to avoid dropping routes added by
+// other processes.
+func (px *nginxProxy) load() error {
+	routes := make(map[string][]*manifest.ProxyMapping)
+	data, err := ioutil.ReadFile(filepath.Join(px.dir, nginxStateFile))
+	if err == nil {
+		err = json.Unmarshal(data, &routes)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	px.routes = routes
+	return nil
+}
+
+// modify reloads routes and applies the change under an exclusive file
+// lock, then updates nginx configuration if the routes were changed.
+func (px *nginxProxy) modify(change func(routes map[string][]*manifest.ProxyMapping) bool) error {
+	px.mu.Lock()
+	defer px.mu.Unlock()
+
+	lock, err := os.OpenFile(filepath.Join(px.dir, nginxLockFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err = syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	if err = px.load(); err != nil {
+		return err
+	}
+	if !change(px.routes) {
+		return nil
+	}
+	return px.update()
+}
+
+func (px *nginxProxy) Close() error {
+	return nil
+}
+
+func (px *nginxProxy) AddEndpoints(id string, endpoints []*manifest.Endpoint) error {
+	var mappings []*manifest.ProxyMapping
+	for _, ep := range endpoints {
+		for _, m := range ep.ProxyMappings {
+			if m.Protocol == "http" {
+				mappings = append(mappings, m)
+			}
+		}
+	}
+
+	return px.modify(func(routes map[string][]*manifest.ProxyMapping) bool {
+		if len(mappings) == 0 {
+			delete(routes, id)
+		} else {
+			routes[id] = mappings
+		}
+		return true
+	})
+}
+
+func (px *nginxProxy) RemoveEndpoints(id string) error {
+	return px.modify(func(routes map[string][]*manifest.ProxyMapping) bool {
+		if _, ok := routes[id]; !ok {
+			return false
+		}
+		delete(routes, id)
+		return true
+	})
+}
+
+func (px *nginxProxy) Endpoints(id string) ([]*manifest.ProxyMapping, error) {
+	px.mu.Lock()
+	defer px.mu.Unlock()
+	if err := px.load(); err != nil {
+		return nil, err
+	}
+	return px.routes[id], nil
+}
+
+func (px *nginxProxy) SetCert(host string, cert, key []byte) error {
+	certFile, keyFile := px.certFiles(host)
+	if err := writeFile(certFile, cert, 0644); err != nil {
+		return err
+	}
+	if err := writeFile(keyFile, key, 0600); err != nil {
+		return err
+	}
+	return px.modify(func(map[string][]*manifest.ProxyMapping) bool {
+		return true
+	})
+}
+
+func (px *nginxProxy) Reset() error {
+	return px.modify(func(routes map[string][]*manifest.ProxyMapping) bool {
+		for id := range routes {
+			delete(routes, id)
+		}
+		return true
+	})
+}
+
+func (px *nginxProxy) certFiles(host string) (certFile, keyFile string) {
+	base := filepath.Join(px.dir, nginxCertDir, filepath.Base(host))
+	return base + ".crt", base + ".key"
+}
+
+func (px *nginxProxy) hasCert(host string) bool {
+	certFile, keyFile := px.certFiles(host)
+	_, err1 := os.Stat(certFile)
+	_, err2 := os.Stat(keyFile)
+	return err1 == nil && err2 == nil
+}
+
+// update saves the routes and the generated configuration, then reloads nginx.
+func (px *nginxProxy) update() error {
+	state, err := json.Marshal(px.routes)
+	if err != nil {
+		return err
+	}
+	if err = writeFile(filepath.Join(px.dir, nginxStateFile), state, 0644); err != nil {
+		return err
+	}
+	conf, auth := px.generate()
+	if err = px.writeAuthFiles(auth); err != nil {
+		return err
+	}
+	if err = writeFile(filepath.Join(px.dir, nginxConfFile), conf, 0644); err != nil {
+		return err
+	}
+
+	if len(px.reload) == 0 {
+		return nil
+	}
+	out, err := exec.Command(px.reload[0], px.reload[1:]...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Failed to reload nginx: %v: %s", err, bytes.TrimSpace(out))
+	}
+	logrus.Debug("nginx reloaded")
+	return nil
+}
+
+// writeAuthFiles writes basic auth password files and removes files that
+// are no longer used.
+func (px *nginxProxy) writeAuthFiles(auth map[string][]byte) error {
+	dir := filepath.Join(px.dir, nginxAuthDir)
+	for name, data := range auth {
+		if err := writeFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		if _, ok := auth[fi.Name()]; !ok {
+			os.Remove(filepath.Join(dir, fi.Name()))
+		}
+	}
+	return nil
+}
+
+type nginxLocation struct {
+	path     string
+	backends []string
+	weights  []int
+	policy   *manifest.AccessPolicy
+	settings *manifest.HTTPSettings
+}
+
+// generate generates nginx configuration from routes. Routes of the same
+// frontend from different containers are load balanced by an upstream.
+// Returns the configuration and basic auth password files by file name.
+func (px *nginxProxy) generate() ([]byte, map[string][]byte) {
+	// group backends by host and path
+	hosts := make(map[string]map[string]*nginxLocation)
+	for _, mappings := range px.routes {
+		for _, m := range mappings {
+			host, path := m.Frontend, "/"
+			if i := strings.IndexRune(host, '/'); i != -1 {
+				host, path = host[:i], host[i:]
+			}
+			if !nginxSafe(host) || !nginxSafe(path) || !nginxSafe(m.Backend) {
+				logrus.WithFields(logrus.Fields{
+					"frontend": m.Frontend,
+					"backend":  m.Backend,
+				}).Warn("Ignoring proxy mapping with unsafe characters")
+				continue
+			}
+			if hosts[host] == nil {
+				hosts[host] = make(map[string]*nginxLocation)
+			}
+			loc := hosts[host][path]
+			if loc == nil {
+				loc = &nginxLocation{path: path}
+				hosts[host][path] = loc
+			}
+			loc.backends = append(loc.backends, m.Backend)
+			loc.weights = append(loc.weights, m.Weight)
+			if m.Policy != nil {
+				loc.policy = m.Policy
+			}
+			if m.Settings != nil {
+				loc.settings = m.Settings
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by cloudway, DO NOT EDIT.\n")
+	auth := make(map[string][]byte)
+
+	// close the connection to backend unless it is upgraded
+	var websocket bool
+	for _, locations := range hosts {
+		websocket = websocket || hasHTTPSettings(locations, func(s *manifest.HTTPSettings) bool { return s.WebSocket })
+	}
+	if websocket {
+		fmt.Fprintf(&buf, "\nmap $http_upgrade $cloudway_connection_upgrade {\n")
+		fmt.Fprintf(&buf, "    default upgrade;\n")
+		fmt.Fprintf(&buf, "    '' close;\n")
+		fmt.Fprintf(&buf, "}\n")
+	}
+
+	hostNames := make([]string, 0, len(hosts))
+	for host := range hosts {
+		hostNames = append(hostNames, host)
+	}
+	sort.Strings(hostNames)
+
+	upstream := 0
+	for _, host := range hostNames {
+		var servers bytes.Buffer
+		tls := px.hasCert(host)
+		fmt.Fprintf(&servers, "\nserver {\n")
+		fmt.Fprintf(&servers, "    listen 80;\n")
+		if tls {
+			certFile, keyFile := px.certFiles(host)
+			if hasHTTPSettings(hosts[host], func(s *manifest.HTTPSettings) bool { return s.GRPC }) {
+				fmt.Fprintf(&servers, "    listen 443 ssl http2;\n")
+			} else {
+				fmt.Fprintf(&servers, "    listen 443 ssl;\n")
+			}
+			fmt.Fprintf(&servers, "    ssl_certificate %s;\n", certFile)
+			fmt.Fprintf(&servers, "    ssl_certificate_key %s;\n", keyFile)
+		}
+		fmt.Fprintf(&servers, "    server_name %s;\n", host)
+
+		locations := hosts[host]
+		paths := make([]string, 0, len(locations))
+		for path := range locations {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		var maintenance bool
+		for _, path := range paths {
+			loc := locations[path]
+			upstream++
+			name := fmt.Sprintf("cloudway_%d", upstream)
+			if inMaintenance(loc.backends) {
+				px.writeMaintenanceLocation(&servers, loc)
+				maintenance = true
+			} else if writeUpstream(&buf, name, loc) {
+				var authFile string
+				if loc.policy != nil && len(loc.policy.BasicAuth) != 0 {
+					authFile = name + ".htpasswd"
+					auth[authFile] = []byte(strings.Join(loc.policy.BasicAuth, "\n") + "\n")
+					authFile = filepath.Join(px.dir, nginxAuthDir, authFile)
+				}
+				writeProxyLocation(&servers, name, loc, authFile, tls)
+			} else {
+				writeSpecialLocation(&servers, loc)
+			}
+		}
+		if maintenance && px.maintenance != "" {
+			fmt.Fprintf(&servers, "    location @cloudway_maintenance {\n")
+			fmt.Fprintf(&servers, "        root %s;\n", filepath.Dir(px.maintenance))
+			fmt.Fprintf(&servers, "        try_files /%s =503;\n", filepath.Base(px.maintenance))
+			fmt.Fprintf(&servers, "    }\n")
+		}
+
+		fmt.Fprintf(&servers, "}\n")
+		buf.Write(servers.Bytes())
+	}
+
+	return buf.Bytes(), auth
+}
+
+// nginxSafe returns true if the value can be written into nginx
+// configuration as a single token without quoting. Hosts and paths come
+// from users, so values that may inject directives are rejected.
+func nginxSafe(s string) bool {
+	return s != "" && !strings.ContainsAny(s, " \t\r\n;{}\"'\\#$")
+}
+
+// hasHTTPSettings returns true if HTTP settings of any location satisfy
+// the predicate.
+func hasHTTPSettings(locations map[string]*nginxLocation, pred func(*manifest.HTTPSettings) bool) bool {
+	for _, loc := range locations {
+		if loc.settings != nil && pred(loc.settings) {
+			return true
+		}
+	}
+	return false
+}
+
+type nginxServer struct {
+	addr   string
+	weight int
+}
+
+// writeUpstream writes an upstream block for backend URLs of a location.
+// Returns false if backends are special directives instead of URLs.
+// Drained backends are marked as down unless all backends are drained.
+func writeUpstream(buf *bytes.Buffer, name string, loc *nginxLocation) bool {
+	var servers []nginxServer
+	var active int
+	for i, backend := range loc.backends {
+		u, err := url.Parse(backend)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		servers = append(servers, nginxServer{u.Host, loc.weights[i]})
+		if loc.weights[i] >= 0 {
+			active++
+		}
+	}
+	if len(servers) == 0 {
+		return false
+	}
+
+	sort.Sort(serversByAddr(servers))
+	fmt.Fprintf(buf, "\nupstream %s {\n", name)
+	for _, s := range servers {
+		switch {
+		case s.weight < 0 && active > 0:
+			fmt.Fprintf(buf, "    server %s down;\n", s.addr)
+		case s.weight > 0:
+			fmt.Fprintf(buf, "    server %s weight=%d;\n", s.addr, s.weight)
+		default:
+			fmt.Fprintf(buf, "    server %s;\n", s.addr)
+		}
+	}
+	fmt.Fprintf(buf, "}\n")
+	return true
+}
+
+type serversByAddr []nginxServer
+
+func (a serversByAddr) Len() int           { return len(a) }
+func (a serversByAddr) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a serversByAddr) Less(i, j int) bool { return a[i].addr < a[j].addr }
+
+func writeProxyLocation(buf *bytes.Buffer, upstream string, loc *nginxLocation, authFile string, tls bool) {
+	// all backends of a location share the same scheme and path
+	var scheme, path string
+	for _, backend := range loc.backends {
+		if u, err := url.Parse(backend); err == nil && u.Host != "" {
+			scheme, path = u.Scheme, u.Path
+			break
+		}
+	}
+
+	prefix := loc.path
+	if prefix != "/" {
+		// strip the frontend path prefix when passing to backend
+		fmt.Fprintf(buf, "    location = %s {\n", prefix)
+		fmt.Fprintf(buf, "        return 301 %s/;\n", prefix)
+		fmt.Fprintf(buf, "    }\n")
+		prefix += "/"
+	}
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+
+	fmt.Fprintf(buf, "    location %s {\n", prefix)
+	writeHTTPSettings(buf, loc.settings, tls)
+	writeAccessPolicy(buf, loc.policy, authFile)
+	if s := loc.settings; s != nil && s.GRPC {
+		// gRPC methods are addressed by the request path, which is
+		// passed to backend unchanged
+		if scheme == "https" {
+			scheme = "grpcs"
+		} else {
+			scheme = "grpc"
+		}
+		fmt.Fprintf(buf, "        grpc_pass %s://%s;\n", scheme, upstream)
+		fmt.Fprintf(buf, "        grpc_set_header X-Real-IP $remote_addr;\n")
+		fmt.Fprintf(buf, "        grpc_set_header X-Forwarded-For $proxy_add_x_forwarded_for;\n")
+		fmt.Fprintf(buf, "        grpc_set_header X-Forwarded-Proto $scheme;\n")
+		writeIdleTimeout(buf, "grpc", s.IdleTimeout)
+		fmt.Fprintf(buf, "    }\n")
+		return
+	}
+	fmt.Fprintf(buf, "        proxy_pass %s://%s%s;\n", scheme, upstream, path)
+	fmt.Fprintf(buf, "        proxy_http_version 1.1;\n")
+	fmt.Fprintf(buf, "        proxy_set_header Host $host;\n")
+	fmt.Fprintf(buf, "        proxy_set_header X-Real-IP $remote_addr;\n")
+	fmt.Fprintf(buf, "        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;\n")
+	fmt.Fprintf(buf, "        proxy_set_header X-Forwarded-Proto $scheme;\n")
+	if s := loc.settings; s != nil {
+		if s.WebSocket {
+			fmt.Fprintf(buf, "        proxy_set_header Upgrade $http_upgrade;\n")
+			fmt.Fprintf(buf, "        proxy_set_header Connection $cloudway_connection_upgrade;\n")
+		}
+		writeIdleTimeout(buf, "proxy", s.IdleTimeout)
+	}
+	fmt.Fprintf(buf, "    }\n")
+}
+
+// writeIdleTimeout writes read and send timeouts of proxied connections
+// with the given directive prefix.
+func writeIdleTimeout(buf *bytes.Buffer, prefix string, timeout int) {
+	if timeout > 0 {
+		fmt.Fprintf(buf, "        %s_read_timeout %ds;\n", prefix, timeout)
+		fmt.Fprintf(buf, "        %s_send_timeout %ds;\n", prefix, timeout)
+	}
+}
+
+// writeHTTPSettings writes HTTPS redirect, HSTS header and custom error
+// pages of a location.
+func writeHTTPSettings(buf *bytes.Buffer, settings *manifest.HTTPSettings, tls bool) {
+	if settings == nil {
+		return
+	}
+	if tls && settings.ForceHTTPS {
+		fmt.Fprintf(buf, "        if ($scheme = http) {\n")
+		fmt.Fprintf(buf, "            return 301 https://$host$request_uri;\n")
+		fmt.Fprintf(buf, "        }\n")
+	}
+	if tls && settings.HSTSMaxAge > 0 {
+		hsts := fmt.Sprintf("max-age=%d", settings.HSTSMaxAge)
+		if settings.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		fmt.Fprintf(buf, "        add_header Strict-Transport-Security \"%s\" always;\n", hsts)
+	}
+	if len(settings.ErrorPages) != 0 {
+		codes := make([]string, 0, len(settings.ErrorPages))
+		for code := range settings.ErrorPages {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		if settings.GRPC {
+			fmt.Fprintf(buf, "        grpc_intercept_errors on;\n")
+		} else {
+			fmt.Fprintf(buf, "        proxy_intercept_errors on;\n")
+		}
+		for _, code := range codes {
+			fmt.Fprintf(buf, "        error_page %s %s;\n", code, settings.ErrorPages[code])
+		}
+	}
+}
+
+// writeAccessPolicy writes access directives of a location. Denied
+// addresses are checked before allowed addresses, as nginx applies the
+// first matching rule.
+func writeAccessPolicy(buf *bytes.Buffer, policy *manifest.AccessPolicy, authFile string) {
+	if policy == nil {
+		return
+	}
+	for _, addr := range policy.Deny {
+		fmt.Fprintf(buf, "        deny %s;\n", addr)
+	}
+	for _, addr := range policy.Allow {
+		fmt.Fprintf(buf, "        allow %s;\n", addr)
+	}
+	if len(policy.Allow) != 0 {
+		fmt.Fprintf(buf, "        deny all;\n")
+	}
+	if authFile != "" {
+		fmt.Fprintf(buf, "        auth_basic \"Restricted\";\n")
+		fmt.Fprintf(buf, "        auth_basic_user_file %s;\n", authFile)
+	}
+}
+
+// writeSpecialLocation writes a location for special backends such as
+// GONE, FORBIDDEN, NOTFOUND and REDIRECT:/url.
+func writeSpecialLocation(buf *bytes.Buffer, loc *nginxLocation) {
+	backend := loc.backends[0]
+	fmt.Fprintf(buf, "    location %s {\n", loc.path)
+	switch {
+	case backend == "GONE":
+		fmt.Fprintf(buf, "        return 410;\n")
+	case backend == "FORBIDDEN":
+		fmt.Fprintf(buf, "        return 403;\n")
+	case strings.HasPrefix(backend, "REDIRECT:"):
+		fmt.Fprintf(buf, "        return 302 %s;\n", backend[len("REDIRECT:"):])
+	default:
+		fmt.Fprintf(buf, "        return 404;\n")
+	}
+	fmt.Fprintf(buf, "    }\n")
+}
+
+// inMaintenance returns true if any backend of a location is in maintenance
+// mode. The location is then served by the maintenance page even if other
+// backends are not yet switched.
+func inMaintenance(backends []string) bool {
+	for _, backend := range backends {
+		if backend == MaintenanceBackend {
+			return true
+		}
+	}
+	return false
+}
+
+// writeMaintenanceLocation writes a location that responds with 503 and
+// the maintenance page, if configured.
+func (px *nginxProxy) writeMaintenanceLocation(buf *bytes.Buffer, loc *nginxLocation) {
+	fmt.Fprintf(buf, "    location %s {\n", loc.path)
+	if px.maintenance != "" {
+		fmt.Fprintf(buf, "        error_page 503 @cloudway_maintenance;\n")
+	}
+	fmt.Fprintf(buf, "        return 503;\n")
+	fmt.Fprintf(buf, "    }\n")
+}
+
+// writeFile atomically writes data to a file.
+func writeFile(filename string, data []byte, perm os.FileMode) error {
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
@@ -0,0 +1,307 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+var _ = Describe("Nginx", func() {
+	var (
+		dir string
+		px  *nginxProxy
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "nginx")
+		Expect(err).NotTo(HaveOccurred())
+		px, err = newNginxProxy(dir, nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	var endpoints = func(mappings ...*manifest.ProxyMapping) []*manifest.Endpoint {
+		return []*manifest.Endpoint{{ProxyMappings: mappings}}
+	}
+
+	var readConf = func() string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, nginxConfFile))
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	It("should load balance backends of the same frontend", func() {
+		Expect(px.AddEndpoints("c1", endpoints(&manifest.ProxyMapping{
+			Frontend: "app-test.example.com",
+			Backend:  "http://172.17.0.2:8080",
+			Protocol: "http",
+		}))).To(Succeed())
+		Expect(px.AddEndpoints("c2", endpoints(&manifest.ProxyMapping{
+			Frontend: "app-test.example.com",
+			Backend:  "http://172.17.0.3:8080",
+			Protocol: "http",
+		}))).To(Succeed())
+
+		conf := readConf()
+		Expect(conf).To(ContainSubstring("server_name app-test.example.com;"))
+		Expect(conf).To(ContainSubstring("server 172.17.0.2:8080;"))
+		Expect(conf).To(ContainSubstring("server 172.17.0.3:8080;"))
+		Expect(conf).To(ContainSubstring("proxy_pass http://cloudway_1/;"))
+	})
+
+	It("should remove endpoints of a container", func() {
+		Expect(px.AddEndpoints("c1", endpoints(&manifest.ProxyMapping{
+			Frontend: "app-test.example.com",
+			Backend:  "http://172.17.0.2:8080",
+			Protocol: "http",
+		}))).To(Succeed())
+		Expect(px.RemoveEndpoints("c1")).To(Succeed())
+
+		Expect(readConf()).NotTo(ContainSubstring("app-test.example.com"))
+		Expect(px.Endpoints("c1")).To(BeEmpty())
+	})
+
+	It("should balance traffic by backend weights", func() {
+		Expect(px.AddEndpoints("c1", endpoints(&manifest.ProxyMapping{
+			Frontend: "app-test.example.com",
+			Backend:  "http://172.17.0.2:8080",
+			Protocol: "http",
+			Weight:   9,
+		}))).To(Succeed())
+		Expect(px.AddEndpoints("c2", endpoints(&manifest.ProxyMapping{
+			Frontend: "app-test.example.com",
+			Backend:  "http://172.17.0.3:8080",
+			Protocol: "http",
+			Weight:   1,
+		}))).To(Succeed())
+		Expect(px.AddEndpoints("c3", endpoints(&manifest.ProxyMapping{
+			Frontend: "app-test.example.com",
+			Backend:  "http://172.17.0.4:8080",
+			Protocol: "http",
+			Weight:   -1,
+		}))).To(Succeed())
+
+		conf := readConf()
+		Expect(conf).To(ContainSubstring("server 172.17.0.2:8080 weight=9;"))
+		Expect(conf).To(ContainSubstring("server 172.17.0.3:8080 weight=1;"))
+		Expect(conf).To(ContainSubstring("server 172.17.0.4:8080 down;"))
+	})
+
+	It("should strip path prefix of frontend", func() {
+		Expect(px.AddEndpoints("c1", endpoints(&manifest.ProxyMapping{
+			Frontend: "app-test.example.com/admin",
+			Backend:  "http://172.17.0.2:8080/console",
+			Protocol: "http",
+		}))).To(Succeed())
+
+		conf := readConf()
+		Expect(conf).To(ContainSubstring("location /admin/ {"))
+		Expect(conf).To(ContainSubstring("proxy_pass http://cloudway_1/console/;"))
+	})
+
+	It("should generate special locations", func() {
+		Expect(px.AddEndpoints("c1", endpoints(&manifest.ProxyMapping{
+			Frontend: "app-test.example.com/old",
+			Backend:  "GONE",
+			Protocol: "http",
+		}))).To(Succeed())
+
+		Expect(readConf()).To(ContainSubstring("return 410;"))
+	})
+
+	It("should serve maintenance page for applications in maintenance", func() {
+		px.maintenance = "/var/www/maintenance.html"
+		Expect(px.AddEndpoints("c1", endpoints(&manifest.ProxyMapping{
+			Frontend: "app-test.example.com",
+			Backend:  "http://172.17.0.2:8080",
+			Protocol: "http",
+		}))).To(Succeed())
+		Expect(px.AddEndpoints("c2", maintenanceEndpoints(endpoints(&manifest.ProxyMapping{
+			Frontend: "app-test.example.com",
+			Backend:  "http://172.17.0.3:8080",
+			Protocol: "http",
+		})))).To(Succeed())
+
+		conf := readConf()
+		Expect(conf).NotTo(ContainSubstring("proxy_pass"))
+		Expect(conf).To(ContainSubstring("error_page 503 @cloudway_maintenance;"))
+		Expect(conf).To(ContainSubstring("return 503;"))
+		Expect(conf).To(ContainSubstring("root /var/www;"))
+		Expect(conf).To(ContainSubstring("try_files /maintenance.html =503;"))
+	})
+
+	It("should enforce access policy", func() {
+		Expect(px.AddEndpoints("c1", endpoints(&manifest.ProxyMapping{
+			Frontend: "app-test.example.com",
+			Backend:  "http://172.17.0.2:8080",
+			Protocol: "http",
+			Policy: &manifest.AccessPolicy{
+				Allow:     []string{"10.0.0.0/8"},
+				Deny:      []string{"10.0.0.1"},
+				BasicAuth: []string{"admin:{SHA}secret"},
+			},
+		}))).To(Succeed())
+
+		conf := readConf()
+		authFile := filepath.Join(dir, nginxAuthDir, "cloudway_1.htpasswd")
+		Expect(conf).To(ContainSubstring("deny 10.0.0.1;\n        allow 10.0.0.0/8;\n        deny all;\n"))
+		Expect(conf).To(ContainSubstring("auth_basic_user_file " + authFile + ";"))
+		Expect(ioutil.ReadFile(authFile)).To(Equal([]byte("admin:{SHA}secret\n")))
+
+		Expect(px.RemoveEndpoints("c1")).To(Succeed())
+		_, err := os.Stat(authFile)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("should redirect applications in maintenance", func() {
+		Expect(px.AddEndpoints("c1", maintenanceEndpoints(endpoints(&manifest.ProxyMapping{
+			Frontend: "app-test.example.com",
+			Backend:  "http://172.17.0.2:8080",
+			Protocol: "http",
+			Policy:   &manifest.AccessPolicy{MaintenanceRedirect: "https://status.example.com"},
+		})))).To(Succeed())
+
+		Expect(readConf()).To(ContainSubstring("return 302 https://status.example.com;"))
+	})
+
+	It("should apply HTTP settings", func() {
+		settings := &manifest.HTTPSettings{
+			ForceHTTPS:            true,
+			HSTSMaxAge:            31536000,
+			HSTSIncludeSubdomains: true,
+			ErrorPages:            map[string]string{"502": "https://status.example.com/502.html"},
+		}
+		Expect(px.AddEndpoints("c1", endpoints(&manifest.ProxyMapping{
+			Frontend: "app-test.example.com",
+			Backend:  "http://172.17.0.2:8080",
+			Protocol: "http",
+			Settings: settings,
+		}))).To(Succeed())
+
+		conf := readConf()
+		Expect(conf).NotTo(ContainSubstring("return 301 https://"))
+		Expect(conf).NotTo(ContainSubstring("Strict-Transport-Security"))
+		Expect(conf).To(ContainSubstring("proxy_intercept_errors on;"))
+		Expect(conf).To(ContainSubstring("error_page 502 https://status.example.com/502.html;"))
+
+		Expect(px.SetCert("app-test.example.com", []byte("cert"), []byte("key"))).To(Succeed())
+		conf = readConf()
+		Expect(conf).To(ContainSubstring("return 301 https://$host$request_uri;"))
+		Expect(conf).To(ContainSubstring(`add_header Strict-Transport-Security "max-age=31536000; includeSubDomains" always;`))
+	})
+
+	It("should pass WebSocket and gRPC connections", func() {
+		Expect(px.AddEndpoints("c1", endpoints(&manifest.ProxyMapping{
+			Frontend: "app-test.example.com",
+			Backend:  "http://172.17.0.2:8080",
+			Protocol: "http",
+			Settings: &manifest.HTTPSettings{WebSocket: true, IdleTimeout: 3600},
+		}))).To(Succeed())
+
+		conf := readConf()
+		Expect(conf).To(ContainSubstring("map $http_upgrade $cloudway_connection_upgrade {"))
+		Expect(conf).To(ContainSubstring("proxy_set_header Upgrade $http_upgrade;"))
+		Expect(conf).To(ContainSubstring("proxy_set_header Connection $cloudway_connection_upgrade;"))
+		Expect(conf).To(ContainSubstring("proxy_read_timeout 3600s;"))
+
+		Expect(px.AddEndpoints("c1", endpoints(&manifest.ProxyMapping{
+			Frontend: "app-test.example.com",
+			Backend:  "http://172.17.0.2:8080",
+			Protocol: "http",
+			Settings: &manifest.HTTPSettings{GRPC: true},
+		}))).To(Succeed())
+		Expect(px.SetCert("app-test.example.com", []byte("cert"), []byte("key"))).To(Succeed())
+
+		conf = readConf()
+		Expect(conf).NotTo(ContainSubstring("$cloudway_connection_upgrade"))
+		Expect(conf).To(ContainSubstring("listen 443 ssl http2;"))
+		Expect(conf).To(ContainSubstring("grpc_pass grpc://cloudway_1;"))
+		Expect(conf).NotTo(ContainSubstring("proxy_pass"))
+	})
+
+	It("should enable TLS for host with certificate", func() {
+		Expect(px.AddEndpoints("c1", endpoints(&manifest.ProxyMapping{
+			Frontend: "app-test.example.com",
+			Backend:  "http://172.17.0.2:8080",
+			Protocol: "http",
+		}))).To(Succeed())
+		Expect(px.SetCert("app-test.example.com", []byte("cert"), []byte("key"))).To(Succeed())
+
+		conf := readConf()
+		Expect(conf).To(ContainSubstring("listen 443 ssl;"))
+		Expect(conf).To(ContainSubstring(filepath.Join(dir, nginxCertDir, "app-test.example.com.crt")))
+	})
+
+	It("should persist endpoints across instances", func() {
+		m := &manifest.ProxyMapping{
+			Frontend: "app-test.example.com",
+			Backend:  "http://172.17.0.2:8080",
+			Protocol: "http",
+		}
+		Expect(px.AddEndpoints("c1", endpoints(m))).To(Succeed())
+
+		px2, err := newNginxProxy(dir, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(px2.Endpoints("c1")).To(Equal([]*manifest.ProxyMapping{m}))
+	})
+
+	It("should share endpoints with other instances", func() {
+		px2, err := newNginxProxy(dir, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		m1 := &manifest.ProxyMapping{
+			Frontend: "app1-test.example.com",
+			Backend:  "http://172.17.0.2:8080",
+			Protocol: "http",
+		}
+		m2 := &manifest.ProxyMapping{
+			Frontend: "app2-test.example.com",
+			Backend:  "http://172.17.0.3:8080",
+			Protocol: "http",
+		}
+		Expect(px.AddEndpoints("c1", endpoints(m1))).To(Succeed())
+		Expect(px2.AddEndpoints("c2", endpoints(m2))).To(Succeed())
+
+		conf := readConf()
+		Expect(conf).To(ContainSubstring("server_name app1-test.example.com;"))
+		Expect(conf).To(ContainSubstring("server_name app2-test.example.com;"))
+		Expect(px.Endpoints("c2")).To(Equal([]*manifest.ProxyMapping{m2}))
+
+		Expect(px2.RemoveEndpoints("c1")).To(Succeed())
+		Expect(px.Endpoints("c1")).To(BeEmpty())
+	})
+
+	It("should ignore mappings with unsafe characters", func() {
+		Expect(px.AddEndpoints("c1", endpoints(
+			&manifest.ProxyMapping{
+				Frontend: "evil.com; include /etc/passwd",
+				Backend:  "http://172.17.0.2:8080",
+				Protocol: "http",
+			},
+			&manifest.ProxyMapping{
+				Frontend: "app-test.example.com/old",
+				Backend:  "REDIRECT:/new; }",
+				Protocol: "http",
+			},
+			&manifest.ProxyMapping{
+				Frontend: "app-test.example.com",
+				Backend:  "http://172.17.0.2:8080",
+				Protocol: "http",
+			},
+		))).To(Succeed())
+
+		conf := readConf()
+		Expect(conf).To(ContainSubstring("server_name app-test.example.com;"))
+		Expect(conf).NotTo(ContainSubstring("evil.com"))
+		Expect(conf).NotTo(ContainSubstring("/new"))
+	})
+})
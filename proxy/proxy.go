@@ -15,6 +15,13 @@ type Proxy interface {
 	// Remove endpoints associated to a container.
 	RemoveEndpoints(id string) error
 
+	// Endpoints returns proxy mappings currently registered for a container.
+	Endpoints(id string) ([]*manifest.ProxyMapping, error)
+
+	// SetCert installs the TLS certificate and private key, in PEM format,
+	// for the given frontend host.
+	SetCert(host string, cert, key []byte) error
+
 	// Reset the proxy to an initial state.
 	Reset() error
 
@@ -22,8 +29,14 @@ type Proxy interface {
 	Close() error
 }
 
+// MaintenanceBackend is a special backend that serves the maintenance
+// page for applications in maintenance mode.
+const MaintenanceBackend = "MAINTENANCE"
+
 var ErrMisconfigured = errors.New("Proxy URL not configured")
 
+var ErrCertNotSupported = errors.New("The proxy does not support TLS certificates")
+
 type UnsupportedSchemeError string
 
 func (scheme UnsupportedSchemeError) Error() string {
@@ -0,0 +1,34 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// UpdateRedirects installs redirects configured in the "proxy-redirect"
+// section, which are added after applications renamed. Expired redirects
+// are not installed.
+func UpdateRedirects(proxy Proxy) error {
+	var mappings []*manifest.ProxyMapping
+	now := time.Now()
+	for host, value := range config.GetSection(broker.RedirectSection) {
+		target, expiry, err := broker.ParseRedirect(value)
+		if err != nil || expiry.Before(now) {
+			continue
+		}
+		mappings = append(mappings, &manifest.ProxyMapping{
+			Frontend: host,
+			Backend:  "REDIRECT:" + target,
+			Protocol: "http",
+		})
+	}
+
+	if len(mappings) == 0 {
+		return proxy.RemoveEndpoints("redirect")
+	}
+	eps := []*manifest.Endpoint{{ProxyMappings: mappings}}
+	return proxy.AddEndpoints("redirect", eps)
+}
@@ -0,0 +1,318 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// The traefik proxy stores routes into the etcd key/value store watched by
+// Traefik. The proxy URL has the form "traefik://127.0.0.1:2379/traefik",
+// where the path is the key prefix configured in Traefik. Deny lists of
+// access policies and custom error pages are not supported by Traefik and
+// ignored. HTTPS redirect requires the "https" entry point. WebSocket
+// connections are always upgraded by Traefik, gRPC requests are passed to
+// plain HTTP backends using h2c, and idle timeouts can only be configured
+// globally in Traefik.
+type traefikProxy struct {
+	client   *http.Client
+	endpoint string
+	prefix   string
+}
+
+func init() {
+	broker.RegisterProxyFeatures("traefik", broker.ProxyWeights)
+	proxyRegistry["traefik"] = func(u *url.URL) (Proxy, error) {
+		prefix := strings.TrimSuffix(u.Path, "/")
+		if prefix == "" {
+			prefix = "/traefik"
+		}
+		return &traefikProxy{
+			client:   &http.Client{Timeout: 10 * time.Second},
+			endpoint: "http://" + u.Host,
+			prefix:   prefix,
+		}, nil
+	}
+}
+
+func (px *traefikProxy) Close() error {
+	return nil
+}
+
+var reInvalidName = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// traefikName converts a frontend to a name that can be used as a
+// frontend or backend name in Traefik.
+func traefikName(frontend string) string {
+	return "cw-" + strings.Trim(reInvalidName.ReplaceAllString(frontend, "-"), "-")
+}
+
+func (px *traefikProxy) AddEndpoints(id string, endpoints []*manifest.Endpoint) error {
+	// remove previously configured endpoints
+	if err := px.RemoveEndpoints(id); err != nil {
+		return err
+	}
+
+	var mappings []*manifest.ProxyMapping
+	for _, ep := range endpoints {
+		for _, m := range ep.ProxyMappings {
+			if m.Protocol != "http" {
+				continue
+			}
+			backend, err := url.Parse(m.Backend)
+			if err != nil || backend.Host == "" {
+				logrus.Debugf("skip unsupported backend %s", m.Backend)
+				continue
+			}
+			if m.Weight >= 0 {
+				// drained backends are kept in the container record
+				// but not routed
+				if err = px.addRoute(id, len(mappings), m, backend); err != nil {
+					return err
+				}
+			}
+			mappings = append(mappings, m)
+		}
+	}
+
+	// add container record
+	data, err := json.Marshal(mappings)
+	if err != nil {
+		return err
+	}
+	return px.put(px.containerKey(id), string(data))
+}
+
+func (px *traefikProxy) addRoute(id string, n int, m *manifest.ProxyMapping, backend *url.URL) error {
+	frontend := m.Frontend
+	host, path := frontend, ""
+	if i := strings.IndexRune(host, '/'); i != -1 {
+		host, path = host[:i], host[i:]
+	}
+
+	rule := "Host:" + host
+	if path != "" {
+		rule += ";PathPrefixStrip:" + path
+	}
+	if backend.Path != "" && backend.Path != "/" {
+		rule += ";AddPrefix:" + backend.Path
+	}
+
+	name := traefikName(frontend)
+	fe := px.prefix + "/frontends/" + name
+	be := px.prefix + "/backends/" + name
+	server := fmt.Sprintf("%s/servers/%s-%d", be, id, n)
+
+	kvs := [][2]string{
+		{fe + "/backend", name},
+		{fe + "/passHostHeader", "true"},
+		{fe + "/routes/main/rule", rule},
+		{server + "/url", traefikServerURL(backend, m.Settings)},
+	}
+	if m.Weight > 0 {
+		kvs = append(kvs, [2]string{server + "/weight", strconv.Itoa(m.Weight)})
+	}
+
+	// replace access policy and settings of the frontend
+	for _, dir := range []string{"/whitelistSourceRange", "/basicAuth", "/redirect", "/headers"} {
+		if err := px.delete(fe + dir); err != nil {
+			return err
+		}
+	}
+	if p := m.Policy; p != nil {
+		for i, addr := range p.Allow {
+			kvs = append(kvs, [2]string{fmt.Sprintf("%s/whitelistSourceRange/%d", fe, i), addr})
+		}
+		for i, user := range p.BasicAuth {
+			kvs = append(kvs, [2]string{fmt.Sprintf("%s/basicAuth/%d", fe, i), user})
+		}
+		if len(p.Deny) != 0 {
+			logrus.Warnf("deny list of %s is not supported by traefik", frontend)
+		}
+	}
+	if s := m.Settings; s != nil {
+		if s.ForceHTTPS {
+			kvs = append(kvs, [2]string{fe + "/redirect/entryPoint", "https"})
+		}
+		if s.HSTSMaxAge > 0 {
+			kvs = append(kvs, [2]string{fe + "/headers/STSSeconds", strconv.Itoa(s.HSTSMaxAge)})
+			if s.HSTSIncludeSubdomains {
+				kvs = append(kvs, [2]string{fe + "/headers/STSIncludeSubdomains", "true"})
+			}
+		}
+		if len(s.ErrorPages) != 0 {
+			logrus.Warnf("custom error pages of %s are not supported by traefik", frontend)
+		}
+		if s.IdleTimeout > 0 {
+			logrus.Warnf("idle timeout of %s is not supported by traefik", frontend)
+		}
+	}
+	for _, kv := range kvs {
+		if err := px.put(kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	logrus.Debugf("add %s -> %s", frontend, backend)
+	return nil
+}
+
+// traefikServerURL returns the server URL of a backend. HTTP/2 without TLS
+// must be requested explicitly for gRPC backends.
+func traefikServerURL(backend *url.URL, settings *manifest.HTTPSettings) string {
+	scheme := backend.Scheme
+	if settings != nil && settings.GRPC && scheme == "http" {
+		scheme = "h2c"
+	}
+	return scheme + "://" + backend.Host
+}
+
+func (px *traefikProxy) RemoveEndpoints(id string) error {
+	mappings, err := px.Endpoints(id)
+	if err != nil || len(mappings) == 0 {
+		return err
+	}
+
+	for i, m := range mappings {
+		name := traefikName(m.Frontend)
+		be := px.prefix + "/backends/" + name
+		if err = px.delete(fmt.Sprintf("%s/servers/%s-%d", be, id, i)); err != nil {
+			return err
+		}
+		logrus.Debugf("remove %s -> %s", m.Frontend, m.Backend)
+
+		// remove the whole frontend if no more servers
+		servers, err := px.list(be + "/servers")
+		if err == nil && len(servers) == 0 {
+			px.delete(be)
+			px.delete(px.prefix + "/frontends/" + name)
+			logrus.Debugf("remove %s", m.Frontend)
+		}
+	}
+
+	return px.delete(px.containerKey(id))
+}
+
+func (px *traefikProxy) Endpoints(id string) ([]*manifest.ProxyMapping, error) {
+	data, err := px.get(px.containerKey(id))
+	if err != nil || data == "" {
+		return nil, err
+	}
+	var mappings []*manifest.ProxyMapping
+	err = json.Unmarshal([]byte(data), &mappings)
+	return mappings, err
+}
+
+func (px *traefikProxy) SetCert(host string, cert, key []byte) error {
+	tls := px.prefix + "/tls/" + traefikName(host)
+	if err := px.put(tls+"/entrypoints", "https"); err != nil {
+		return err
+	}
+	if err := px.put(tls+"/certificate/certfile", string(cert)); err != nil {
+		return err
+	}
+	return px.put(tls+"/certificate/keyfile", string(key))
+}
+
+func (px *traefikProxy) Reset() error {
+	for _, dir := range []string{"/frontends", "/backends", "/cloudway"} {
+		if err := px.delete(px.prefix + dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (px *traefikProxy) containerKey(id string) string {
+	return px.prefix + "/cloudway/containers/" + id
+}
+
+// etcd v2 key/value API
+
+type etcdNode struct {
+	Key   string      `json:"key"`
+	Value string      `json:"value"`
+	Dir   bool        `json:"dir"`
+	Nodes []*etcdNode `json:"nodes"`
+}
+
+type etcdResponse struct {
+	Node *etcdNode `json:"node"`
+}
+
+func (px *traefikProxy) do(method, key string, query url.Values) (*etcdNode, error) {
+	u := px.endpoint + "/v2/keys" + key
+	var body *strings.Reader
+	if method == "PUT" {
+		body = strings.NewReader(query.Encode())
+	} else {
+		if len(query) != 0 {
+			u += "?" + query.Encode()
+		}
+		body = strings.NewReader("")
+	}
+
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if method == "PUT" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := px.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("etcd: %s %s: %s", method, key, strings.TrimSpace(string(msg)))
+	}
+
+	var r etcdResponse
+	if err = json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+	return r.Node, nil
+}
+
+func (px *traefikProxy) get(key string) (string, error) {
+	node, err := px.do("GET", key, nil)
+	if err != nil || node == nil {
+		return "", err
+	}
+	return node.Value, nil
+}
+
+func (px *traefikProxy) list(dir string) ([]*etcdNode, error) {
+	node, err := px.do("GET", dir, nil)
+	if err != nil || node == nil {
+		return nil, err
+	}
+	return node.Nodes, nil
+}
+
+func (px *traefikProxy) put(key, value string) error {
+	_, err := px.do("PUT", key, url.Values{"value": {value}})
+	return err
+}
+
+func (px *traefikProxy) delete(key string) error {
+	_, err := px.do("DELETE", key, url.Values{"recursive": {"true"}})
+	return err
+}
@@ -3,7 +3,9 @@ package proxy
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/signal"
 	"strings"
@@ -44,19 +46,22 @@ func RunUpdater(engine container.Engine, proxy Proxy) error {
 		}
 	}()
 
-	if err := proxy.Reset(); err != nil {
+	if err := Sync(context.Background(), cli, proxy); err != nil {
 		return err
 	}
-	if err := update(proxy); err != nil {
-		return err
-	}
-	if err := rebuild(cli, proxy); err != nil {
+	return listen(cli, proxy)
+}
+
+// Sync resets the proxy and rebuilds routes from static mappings, redirects,
+// certificates and running containers.
+func Sync(ctx context.Context, cli docker.DockerEngine, proxy Proxy) error {
+	if err := proxy.Reset(); err != nil {
 		return err
 	}
-	if err := listen(cli, proxy); err != nil {
+	if err := update(proxy); err != nil {
 		return err
 	}
-	return nil
+	return rebuild(ctx, cli, proxy)
 }
 
 func update(proxy Proxy) error {
@@ -75,15 +80,41 @@ func update(proxy Proxy) error {
 				ProxyMappings: mappings,
 			},
 		}
-		return proxy.AddEndpoints("static", eps)
+		if err := proxy.AddEndpoints("static", eps); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	if err := UpdateRedirects(proxy); err != nil {
+		return err
+	}
+	return updateCerts(proxy)
 }
 
-func rebuild(cli docker.DockerEngine, proxy Proxy) error {
-	ctx := context.Background()
+// updateCerts installs TLS certificates configured in the "proxy-cert"
+// section, in the form of "host = certfile,keyfile".
+func updateCerts(proxy Proxy) error {
+	for host, files := range config.GetSection("proxy-cert") {
+		parts := strings.SplitN(files, ",", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("Invalid certificate configuration for %s: %s", host, files)
+		}
+		cert, err := ioutil.ReadFile(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return err
+		}
+		key, err := ioutil.ReadFile(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return err
+		}
+		if err = proxy.SetCert(host, cert, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+func rebuild(ctx context.Context, cli docker.DockerEngine, proxy Proxy) error {
 	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{})
 	if err != nil {
 		return err
@@ -112,9 +143,25 @@ func rebuild(cli docker.DockerEngine, proxy Proxy) error {
 }
 
 func listen(cli docker.DockerEngine, proxy Proxy) error {
-	var ctx = context.Background()
-	var err error
+	ctx := context.Background()
+	ch := make(chan events.Message)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- streamEvents(ctx, cli, ch)
+		close(ch)
+	}()
 
+	for event := range ch {
+		if err := handleEvent(proxy, ctx, cli, event); err != nil {
+			logrus.Error(err)
+		}
+	}
+	return <-errc
+}
+
+// streamEvents sends container events from the docker daemon to the channel
+// until the event stream is closed or the context is cancelled.
+func streamEvents(ctx context.Context, cli docker.DockerEngine, ch chan<- events.Message) error {
 	filters := filters.NewArgs()
 	filters.Add("type", "container")
 	filters.Add("event", "start")
@@ -132,40 +179,54 @@ func listen(cli docker.DockerEngine, proxy Proxy) error {
 		var event events.Message
 		err = dec.Decode(&event)
 		if err == io.EOF {
-			break
+			return nil
 		}
 		if err != nil {
 			return err
 		}
+		select {
+		case ch <- event:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
 
-		switch event.Action {
-		case "start":
-			_, ok1 := event.Actor.Attributes[docker.APP_NAME_KEY]
-			_, ok2 := event.Actor.Attributes[docker.APP_NAMESPACE_KEY]
-			if ok1 && ok2 {
-				logrus.Debugf("container started: %s", event.Actor.ID)
-				c, err := cli.Inspect(ctx, event.Actor.ID)
-				if err == nil {
-					err = handleStart(proxy, ctx, c)
-				}
-			} else {
-				info, err := cli.ContainerInspect(ctx, event.Actor.ID)
-				if err == nil {
-					err = handleVirtualHost(proxy, info)
-				}
+// handleEvent updates the proxy on a docker container event.
+func handleEvent(proxy Proxy, ctx context.Context, cli docker.DockerEngine, event events.Message) (err error) {
+	switch event.Action {
+	case "start":
+		_, ok1 := event.Actor.Attributes[docker.APP_NAME_KEY]
+		_, ok2 := event.Actor.Attributes[docker.APP_NAMESPACE_KEY]
+		if ok1 && ok2 {
+			logrus.Debugf("container started: %s", event.Actor.ID)
+			var c container.Container
+			if c, err = cli.Inspect(ctx, event.Actor.ID); err == nil {
+				err = handleStart(proxy, ctx, c)
+			}
+		} else {
+			var info types.ContainerJSON
+			if info, err = cli.ContainerInspect(ctx, event.Actor.ID); err == nil {
+				err = handleVirtualHost(proxy, info)
 			}
+		}
 
-		case "die", "destroy":
-			logrus.Debugf("container stopped: %s", event.Actor.ID)
+	case "die":
+		logrus.Debugf("container stopped: %s", event.Actor.ID)
+		if _, ok := event.Actor.Attributes[docker.APP_NAME_KEY]; ok {
+			var c container.Container
+			if c, err = cli.Inspect(ctx, event.Actor.ID); err == nil {
+				err = handleDie(proxy, ctx, c)
+			}
+		} else {
 			err = handleStop(proxy, event.Actor.ID)
 		}
 
-		if err != nil {
-			logrus.Error(err)
-		}
+	case "destroy":
+		logrus.Debugf("container destroyed: %s", event.Actor.ID)
+		err = handleStop(proxy, event.Actor.ID)
 	}
-
-	return nil
+	return err
 }
 
 func handleStart(proxy Proxy, ctx context.Context, c container.Container) error {
@@ -175,6 +236,32 @@ func handleStart(proxy Proxy, ctx context.Context, c container.Container) error
 		return err
 	}
 
+	// balance traffic between container generations during canary release
+	if weight := c.TrafficWeight(ctx); weight != 0 {
+		info.Endpoints = mapEndpoints(info.Endpoints, func(m *manifest.ProxyMapping) {
+			m.Weight = weight
+		})
+	}
+
+	// restrict access to the application
+	if policy := c.AccessPolicy(ctx); policy != nil {
+		info.Endpoints = mapEndpoints(info.Endpoints, func(m *manifest.ProxyMapping) {
+			m.Policy = policy
+		})
+	}
+
+	// HTTPS redirect, HSTS and custom error pages
+	if settings := c.HTTPSettings(ctx); settings != nil {
+		info.Endpoints = mapEndpoints(info.Endpoints, func(m *manifest.ProxyMapping) {
+			m.Settings = settings
+		})
+	}
+
+	// serve the maintenance page instead of the application
+	if c.Maintenance(ctx) != "" {
+		info.Endpoints = maintenanceEndpoints(info.Endpoints)
+	}
+
 	// add endpoints to the proxy server
 	if err = proxy.AddEndpoints(c.ID(), info.Endpoints); err != nil {
 		return err
@@ -183,6 +270,52 @@ func handleStart(proxy Proxy, ctx context.Context, c container.Container) error
 	return nil
 }
 
+// maintenanceEndpoints replaces backends of HTTP proxy mappings with the
+// special maintenance backend, or redirects if the access policy has a
+// maintenance redirect.
+func maintenanceEndpoints(endpoints []*manifest.Endpoint) []*manifest.Endpoint {
+	return mapEndpoints(endpoints, func(m *manifest.ProxyMapping) {
+		if m.Policy != nil && m.Policy.MaintenanceRedirect != "" {
+			m.Backend = "REDIRECT:" + m.Policy.MaintenanceRedirect
+		} else {
+			m.Backend = MaintenanceBackend
+		}
+		m.Weight = 0
+	})
+}
+
+// mapEndpoints returns copies of HTTP proxy mappings modified by the
+// function.
+func mapEndpoints(endpoints []*manifest.Endpoint, fn func(*manifest.ProxyMapping)) []*manifest.Endpoint {
+	var mappings []*manifest.ProxyMapping
+	for _, ep := range endpoints {
+		for _, m := range ep.ProxyMappings {
+			if m.Protocol == "http" {
+				m2 := *m
+				fn(&m2)
+				mappings = append(mappings, &m2)
+			}
+		}
+	}
+	return []*manifest.Endpoint{{ProxyMappings: mappings}}
+}
+
+// handleDie removes endpoints of a stopped container, unless the container
+// is in maintenance mode, in which case the endpoints are switched to the
+// maintenance page.
+func handleDie(proxy Proxy, ctx context.Context, c container.Container) error {
+	if c.Maintenance(ctx) == "" {
+		return handleStop(proxy, c.ID())
+	}
+
+	mappings, err := proxy.Endpoints(c.ID())
+	if err != nil || len(mappings) == 0 {
+		return err
+	}
+	eps := []*manifest.Endpoint{{ProxyMappings: mappings}}
+	return proxy.AddEndpoints(c.ID(), maintenanceEndpoints(eps))
+}
+
 func handleVirtualHost(proxy Proxy, info types.ContainerJSON) error {
 	// convert env to a map
 	env := make(map[string]string)
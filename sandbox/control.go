@@ -1,6 +1,7 @@
 package sandbox
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -20,6 +21,9 @@ func (box *Sandbox) Start() error {
 
 	box.SetActiveState(manifest.StateStarting)
 	err := box.Control("start", true, true)
+	if err == nil {
+		err = box.WaitReady()
+	}
 	if err != nil {
 		box.SetActiveState(manifest.StateFailed)
 	} else {
@@ -40,6 +44,10 @@ func (box *Sandbox) Stop() error {
 }
 
 func (box *Sandbox) Restart() (err error) {
+	if err = box.runLifecycleHook("pre-restart", MakeExecEnv(box.Environ())); err != nil {
+		return err
+	}
+
 	if box.hasDeployments() {
 		err = box.Stop()
 		if err == nil {
@@ -49,6 +57,9 @@ func (box *Sandbox) Restart() (err error) {
 		box.CreatePrivateEndpoints("")
 		box.SetActiveState(manifest.StateRestarting)
 		err = box.Control("restart", true, true)
+		if err == nil {
+			err = box.WaitReady()
+		}
 		if err != nil {
 			box.SetActiveState(manifest.StateFailed)
 		} else {
@@ -162,6 +173,56 @@ func (box *Sandbox) runActionHook(action string, env []string) error {
 	return reaper.RunCmd(cmd)
 }
 
+// RunHook runs the lifecycle hook provided by the application repository.
+func (box *Sandbox) RunHook(name string) error {
+	return box.runLifecycleHook(name, MakeExecEnv(box.Environ()))
+}
+
+// runLifecycleHook runs the lifecycle hook provided by the application
+// repository, such as "pre-deploy", "post-deploy" and "pre-restart". Unlike
+// action hooks, the operation is aborted if the lifecycle hook failed.
+func (box *Sandbox) runLifecycleHook(name string, env []string) error {
+	hook := filepath.Join(box.RepoDir(), ".cloudway", "hooks", name)
+	if _, err := os.Stat(hook); os.IsNotExist(err) {
+		return box.runManifestHook(name, env)
+	}
+
+	fmt.Fprintf(os.Stdout, "Running %s hook\n", name)
+	if err := box.runActionHook(name, env); err != nil {
+		return fmt.Errorf("%s hook failed: %v", name, err)
+	}
+	return nil
+}
+
+// runManifestHook runs the lifecycle hook command declared in the
+// application manifest. The command is run by shell in the repository
+// directory.
+func (box *Sandbox) runManifestHook(name string, env []string) error {
+	m, err := manifest.LoadAppManifest(filepath.Join(box.RepoDir(), manifest.AppManifestFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	command := m.Hooks[name]
+	if command == "" {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stdout, "Running %s hook\n", name)
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Stdin = nil
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	cmd.Dir = box.RepoDir()
+	if err = reaper.RunCmd(cmd); err != nil {
+		return fmt.Errorf("%s hook failed: %v", name, err)
+	}
+	return nil
+}
+
 func MakeExecEnv(env map[string]string) []string {
 	if env != nil {
 		eenv := make([]string, 0, len(env))
@@ -2,22 +2,53 @@ package sandbox
 
 import (
 	"archive/tar"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
 
 	"github.com/cloudway/platform/pkg/archive"
+	"github.com/cloudway/platform/pkg/manifest"
 )
 
+// Logical backups taken by the Backup commands of plugins are stored in
+// this directory of the dump archive, named by the plugin name.
+const backupDir = ".backup"
+
+// Dump writes the data directory to the sink as a tar archive. If plugins
+// in the sandbox declare the Backup command, the archive also contains
+// logical backups taken by the commands, and the data directories of these
+// plugins, named by the plugin name, are left out.
 func (box *Sandbox) Dump(sink io.Writer) (err error) {
+	plugins, err := box.Plugins()
+	if err != nil {
+		return err
+	}
+	backups := backupPlugins(plugins)
+
 	err = box.Control("pre-dump", false, false)
 	if err != nil {
 		return err
 	}
 
+	excludes := []string{backupDir}
+	for _, p := range backups {
+		excludes = append(excludes, p.Name)
+	}
+
 	tw := tar.NewWriter(sink)
-	err = archive.CopyFileTree(tw, "", box.DataDir(), nil, false)
+	err = archive.CopyFileTree(tw, "", box.DataDir(), excludes, false)
 	if err != nil {
 		return err
 	}
+	if err = box.dumpBackups(tw, backups); err != nil {
+		return err
+	}
 	if err = tw.Close(); err != nil {
 		return err
 	}
@@ -25,16 +56,217 @@ func (box *Sandbox) Dump(sink io.Writer) (err error) {
 	return box.Control("post-dump", false, false)
 }
 
+type byPluginName []*manifest.Plugin
+
+func (a byPluginName) Len() int           { return len(a) }
+func (a byPluginName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byPluginName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+// backupPlugins returns plugins declaring the Backup command, sorted by
+// name.
+func backupPlugins(plugins map[string]*manifest.Plugin) []*manifest.Plugin {
+	var backups []*manifest.Plugin
+	for _, p := range plugins {
+		if p.Backup != "" {
+			backups = append(backups, p)
+		}
+	}
+	sort.Sort(byPluginName(backups))
+	return backups
+}
+
+func (box *Sandbox) dumpBackups(tw *tar.Writer, plugins []*manifest.Plugin) error {
+	if len(plugins) == 0 {
+		return nil
+	}
+	eenv := MakeExecEnv(box.Environ())
+	for _, p := range plugins {
+		if err := box.dumpBackup(tw, p, eenv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dumpBackup writes the backup taken by the plugin to the archive. The
+// backup is spooled to a file next to the data directory first, since the
+// size must be known before the tar header is written.
+func (box *Sandbox) dumpBackup(tw *tar.Writer, p *manifest.Plugin, env []string) error {
+	f, err := ioutil.TempFile(filepath.Dir(box.DataDir()), ".backup")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	if err = runPluginCommand(p, p.Backup, env, nil, f); err != nil {
+		return fmt.Errorf("%s backup failed: %v", p.DisplayName, err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err = f.Seek(0, os.SEEK_SET); err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    path.Join(backupDir, p.Name),
+		Mode:    0600,
+		Size:    fi.Size(),
+		ModTime: time.Now(),
+	})
+	if err == nil {
+		_, err = io.Copy(tw, f)
+	}
+	return err
+}
+
+// Restore replaces the data directory with the archive. The archive is
+// extracted into a staging directory first, so a truncated or malformed
+// archive leaves the current data untouched. The staging directory is
+// swapped with the data directory between the pre-restore and post-restore
+// hooks. If the archive contains logical backups, the data directories of
+// the backed up plugins are kept, and the backups are restored by the
+// Restore commands of plugins after the swap.
 func (box *Sandbox) Restore(source io.Reader) (err error) {
+	dataDir := box.DataDir()
+	stagingDir := dataDir + ".restore"
+	oldDir := dataDir + ".old"
+	backupsDir := dataDir + ".backup"
+
+	// recover from an interrupted swap
+	fi, err := os.Stat(dataDir)
+	if os.IsNotExist(err) && os.Rename(oldDir, dataDir) == nil {
+		fi, err = os.Stat(dataDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	os.RemoveAll(stagingDir)
+	if err = os.Mkdir(stagingDir, fi.Mode().Perm()); err != nil {
+		return err
+	}
+	defer os.RemoveAll(stagingDir)
+	os.Chown(stagingDir, box.uid, box.gid)
+
+	err = archive.ExtractFiles(stagingDir, source)
+	if err != nil {
+		return err
+	}
+
+	// move logical backups out of the staging directory, and make sure
+	// all of them can be restored before the data directory is replaced
+	var backups []*manifest.Plugin
+	os.RemoveAll(backupsDir)
+	if _, err = os.Stat(filepath.Join(stagingDir, backupDir)); err == nil {
+		if err = os.Rename(filepath.Join(stagingDir, backupDir), backupsDir); err != nil {
+			return err
+		}
+		defer os.RemoveAll(backupsDir)
+		if backups, err = box.restorePlugins(backupsDir); err != nil {
+			return err
+		}
+	}
+
 	err = box.Control("pre-restore", false, false)
 	if err != nil {
 		return err
 	}
 
-	err = archive.ExtractFiles(box.DataDir(), source)
+	// data of plugins are restored by the Restore commands
+	for _, p := range backups {
+		dir := filepath.Join(dataDir, p.Name)
+		if _, err = os.Lstat(dir); err == nil {
+			os.RemoveAll(filepath.Join(stagingDir, p.Name))
+			if err = os.Rename(dir, filepath.Join(stagingDir, p.Name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = swapDir(dataDir, stagingDir, oldDir); err != nil {
+		return err
+	}
+
+	err = box.Control("post-restore", false, false)
 	if err != nil {
 		return err
 	}
 
-	return box.Control("post-restore", false, false)
+	return box.restoreBackups(backupsDir, backups)
+}
+
+// restorePlugins returns plugins to restore logical backups in the
+// directory. All backups must have a plugin with the Restore command.
+func (box *Sandbox) restorePlugins(dir string) ([]*manifest.Plugin, error) {
+	plugins, err := box.Plugins()
+	if err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []*manifest.Plugin
+	for _, fi := range files {
+		p := plugins[fi.Name()]
+		if p == nil || p.Restore == "" {
+			return nil, fmt.Errorf("Cannot restore backup of %s, the plugin doesn't provide the Restore command", fi.Name())
+		}
+		backups = append(backups, p)
+	}
+	return backups, nil
+}
+
+// restoreBackups restores logical backups in the directory by the Restore
+// commands of plugins.
+func (box *Sandbox) restoreBackups(dir string, plugins []*manifest.Plugin) error {
+	if len(plugins) == 0 {
+		return nil
+	}
+
+	eenv := MakeExecEnv(box.Environ())
+	for _, p := range plugins {
+		f, err := os.Open(filepath.Join(dir, p.Name))
+		if err != nil {
+			return err
+		}
+		err = runPluginCommand(p, p.Restore, eenv, f, os.Stdout)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s restore failed: %v", p.DisplayName, err)
+		}
+	}
+	return nil
+}
+
+// runPluginCommand runs the command declared in the plugin manifest by
+// shell in the plugin directory.
+func runPluginCommand(p *manifest.Plugin, command string, env []string, stdin io.Reader, stdout io.Writer) error {
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	cmd.Dir = p.Path
+	return reaper.RunCmd(cmd)
+}
+
+// swapDir replaces dir with newDir, the original directory is renamed to
+// oldDir and removed after the swap, or renamed back if the swap failed.
+func swapDir(dir, newDir, oldDir string) error {
+	os.RemoveAll(oldDir)
+	if err := os.Rename(dir, oldDir); err != nil {
+		return err
+	}
+	if err := os.Rename(newDir, dir); err != nil {
+		os.Rename(oldDir, dir)
+		return err
+	}
+	return os.RemoveAll(oldDir)
 }
@@ -0,0 +1,132 @@
+package sandbox_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/sandbox"
+)
+
+var _ = Describe("Data", func() {
+	var home string
+	var box *sandbox.Sandbox
+
+	var writeFile = func(name, content string) {
+		filename := filepath.Join(home, filepath.FromSlash(name))
+		ExpectWithOffset(1, os.MkdirAll(filepath.Dir(filename), 0755)).To(Succeed())
+		ExpectWithOffset(1, ioutil.WriteFile(filename, []byte(content), 0644)).To(Succeed())
+	}
+
+	var readFile = func(name string) string {
+		content, err := ioutil.ReadFile(filepath.Join(home, filepath.FromSlash(name)))
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		return string(content)
+	}
+
+	var dump = func() *bytes.Buffer {
+		var buf bytes.Buffer
+		ExpectWithOffset(1, box.Dump(&buf)).To(Succeed())
+		return &buf
+	}
+
+	var entries = func(ar *bytes.Buffer) map[string]string {
+		files := make(map[string]string)
+		tr := tar.NewReader(bytes.NewReader(ar.Bytes()))
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			ExpectWithOffset(1, err).NotTo(HaveOccurred())
+			content, err := ioutil.ReadAll(tr)
+			ExpectWithOffset(1, err).NotTo(HaveOccurred())
+			files[hdr.Name] = string(content)
+		}
+		return files
+	}
+
+	BeforeEach(func() {
+		var err error
+		home, err = ioutil.TempDir("", "sandbox")
+		Expect(err).NotTo(HaveOccurred())
+
+		os.Setenv("CLOUDWAY_APP_NAME", "test")
+		os.Setenv("CLOUDWAY_APP_NAMESPACE", "demo")
+		os.Setenv("CLOUDWAY_HOME_DIR", home)
+		os.Setenv("CLOUDWAY_DATA_DIR", filepath.Join(home, "data"))
+		box = sandbox.New()
+
+		writeFile("web/manifest/plugin.yml", "Name: web\nDisplay-Name: Web\nCategory: Framework\n")
+		writeFile("data/uploads/a.txt", "upload")
+		writeFile("data/web.conf", "config")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(home)
+	})
+
+	Context("without logical backups", func() {
+		It("should dump the data directory", func() {
+			files := entries(dump())
+			Expect(files).To(HaveKeyWithValue("uploads/a.txt", "upload"))
+			Expect(files).To(HaveKeyWithValue("web.conf", "config"))
+		})
+
+		It("should restore the data directory", func() {
+			ar := dump()
+			writeFile("data/uploads/a.txt", "changed")
+			writeFile("data/new.txt", "new")
+
+			Expect(box.Restore(ar)).To(Succeed())
+			Expect(readFile("data/uploads/a.txt")).To(Equal("upload"))
+			Expect(filepath.Join(home, "data", "new.txt")).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Context("with logical backups", func() {
+		BeforeEach(func() {
+			writeFile("db/manifest/plugin.yml", "Name: db\nDisplay-Name: DB\nCategory: Service\n"+
+				"Backup: cat \"$CLOUDWAY_DATA_DIR/db/table\"\n"+
+				"Restore: cat > \"$CLOUDWAY_DATA_DIR/db/table\"\n")
+			writeFile("data/db/table", "rows")
+		})
+
+		It("should dump data files along with logical backups", func() {
+			files := entries(dump())
+			Expect(files).To(HaveKeyWithValue("uploads/a.txt", "upload"))
+			Expect(files).To(HaveKeyWithValue("web.conf", "config"))
+			Expect(files).To(HaveKeyWithValue(".backup/db", "rows"))
+			Expect(files).NotTo(HaveKey("db/table"))
+		})
+
+		It("should restore data files and logical backups", func() {
+			ar := dump()
+			writeFile("data/uploads/a.txt", "changed")
+			writeFile("data/db/table", "changed rows")
+			writeFile("data/db/index", "index")
+
+			Expect(box.Restore(ar)).To(Succeed())
+			Expect(readFile("data/uploads/a.txt")).To(Equal("upload"))
+			Expect(readFile("data/web.conf")).To(Equal("config"))
+			Expect(readFile("data/db/table")).To(Equal("rows"))
+			Expect(readFile("data/db/index")).To(Equal("index"))
+			Expect(filepath.Join(home, "data", ".backup")).NotTo(BeAnExistingFile())
+		})
+
+		It("should refuse backups without the Restore command", func() {
+			ar := dump()
+			writeFile("db/manifest/plugin.yml", "Name: db\nDisplay-Name: DB\nCategory: Service\n")
+			writeFile("data/uploads/a.txt", "changed")
+
+			Expect(box.Restore(ar)).NotTo(Succeed())
+			Expect(readFile("data/uploads/a.txt")).To(Equal("changed"))
+		})
+	})
+})
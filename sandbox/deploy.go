@@ -48,7 +48,15 @@ func (box *Sandbox) Deploy() error {
 	if err != nil {
 		return err
 	}
-	return runPluginAction(primary.Path, box.RepoDir(), MakeExecEnv(box.Environ()), "deploy")
+
+	eenv := MakeExecEnv(box.Environ())
+	if err = box.runLifecycleHook("pre-deploy", eenv); err != nil {
+		return err
+	}
+	if err = runPluginAction(primary.Path, box.RepoDir(), eenv, "deploy"); err != nil {
+		return err
+	}
+	return box.runLifecycleHook("post-deploy", eenv)
 }
 
 func (box *Sandbox) hasDeployments() bool {
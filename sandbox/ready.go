@@ -0,0 +1,71 @@
+package sandbox
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// DefaultReadyTimeout is the time to wait for a plugin to become ready if
+// the plugin doesn't declare the Ready-Timeout in its manifest.
+const DefaultReadyTimeout = 60 * time.Second
+
+const readyCheckInterval = time.Second
+
+// WaitReady waits until service plugins in the sandbox are ready to accept
+// connections, so containers depending on the service are started after
+// the service is ready. A plugin is ready if the "ready" action provided
+// by the plugin succeeds, or all private endpoints are accepting TCP
+// connections if the plugin doesn't provide the action.
+func (box *Sandbox) WaitReady() error {
+	plugins, err := box.Plugins()
+	if err != nil {
+		return err
+	}
+	ip, err := box.LocalIP()
+	if err != nil {
+		return err
+	}
+
+	eenv := MakeExecEnv(box.Environ())
+	for _, p := range plugins {
+		if !p.IsService() {
+			continue
+		}
+
+		timeout := DefaultReadyTimeout
+		if p.ReadyTimeout > 0 {
+			timeout = time.Duration(p.ReadyTimeout) * time.Second
+		}
+
+		deadline := time.Now().Add(timeout)
+		for !box.pluginReady(p, ip, eenv) {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("%s is not ready after %v", p.DisplayName, timeout)
+			}
+			time.Sleep(readyCheckInterval)
+		}
+	}
+	return nil
+}
+
+func (box *Sandbox) pluginReady(p *manifest.Plugin, ip string, env []string) bool {
+	if _, err := os.Stat(filepath.Join(p.Path, "bin", "ready")); err == nil {
+		return runPluginAction(p.Path, p.Path, env, "ready") == nil
+	}
+
+	for _, ep := range p.GetEndpoints(box.FQDN(), box.ServiceName(), ip) {
+		addr := net.JoinHostPort(ip, strconv.Itoa(int(ep.PrivatePort)))
+		conn, err := net.DialTimeout("tcp", addr, readyCheckInterval)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+	}
+	return true
+}
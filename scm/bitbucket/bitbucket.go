@@ -2,7 +2,6 @@ package bitbucket
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,6 +10,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/container"
@@ -21,6 +21,8 @@ import (
 
 type bitbucketClient struct {
 	*rest.Client
+	long  *rest.Client // for calls that wait for long running work
+	cache *refCache
 }
 
 func init() {
@@ -40,21 +42,27 @@ func init() {
 			return nil, err
 		}
 
-		username := u.User.Username()
-		password, _ := u.User.Password()
-		auth := string(base64.StdEncoding.EncodeToString([]byte(username + ":" + password)))
-
 		headers := map[string]string{
-			"Authorization":     "Basic " + auth, // TODO
 			"X-Atlassian-Token": "no-check",
 			"Accept":            "application/json",
 		}
+		if auth := authorization(u); auth != "" {
+			headers["Authorization"] = auth
+		}
 
-		cli, err := rest.NewClient(scmurl, "", nil, headers)
+		cli, err := rest.NewClient(scmurl, "", newHTTPClient(false), headers)
+		if err != nil {
+			return nil, err
+		}
+		long, err := rest.NewClient(scmurl, "", newHTTPClient(true), headers)
 		if err != nil {
 			return nil, err
 		}
-		return &bitbucketClient{cli}, nil
+		ttl := defaultCacheTTL
+		if d, err := time.ParseDuration(config.Get("scm.cache_ttl")); err == nil {
+			ttl = d
+		}
+		return &bitbucketClient{cli, long, newRefCache(ttl)}, nil
 	}
 }
 
@@ -62,6 +70,12 @@ func (cli *bitbucketClient) Type() string {
 	return "git"
 }
 
+func (cli *bitbucketClient) Ping() error {
+	resp, err := cli.Get(context.Background(), "/rest/api/1.0/application-properties", nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
 func (cli *bitbucketClient) CreateNamespace(namespace string) error {
 	opts := CreateProjectOpts{
 		Key:  namespace,
@@ -102,6 +116,21 @@ func (cli *bitbucketClient) RemoveNamespace(namespace string) error {
 	return checkNamespaceError(namespace, resp, err)
 }
 
+func (cli *bitbucketClient) RenameNamespace(oldNamespace, newNamespace string) error {
+	opts := CreateProjectOpts{
+		Key:  newNamespace,
+		Name: newNamespace,
+	}
+
+	path := fmt.Sprintf("/rest/api/1.0/projects/%s", oldNamespace)
+	resp, err := cli.Put(context.Background(), path, nil, opts, nil)
+	resp.EnsureClosed()
+	if resp.StatusCode == http.StatusConflict {
+		return scm.NamespaceExistError(newNamespace)
+	}
+	return checkNamespaceError(oldNamespace, resp, err)
+}
+
 func (cli *bitbucketClient) getRepoPage(ctx context.Context, namespace string, start int) (page *RepoPage, err error) {
 	var (
 		path   = fmt.Sprintf("/rest/api/1.0/projects/%s/repos", namespace)
@@ -176,6 +205,8 @@ func (cli *bitbucketClient) CreateRepo(namespace, name string, purge bool) error
 }
 
 func (cli *bitbucketClient) RemoveRepo(namespace, name string) error {
+	cli.cache.invalidate(namespace, name)
+
 	path := fmt.Sprintf("/rest/api/1.0/projects/%s/repos/%s", namespace, name)
 	resp, err := cli.Delete(context.Background(), path, nil, nil)
 	resp.EnsureClosed()
@@ -188,6 +219,27 @@ func (cli *bitbucketClient) RemoveRepo(namespace, name string) error {
 	}
 }
 
+func (cli *bitbucketClient) RenameRepo(namespace, oldName, newName string) error {
+	cli.cache.invalidate(namespace, oldName)
+
+	opts := CreateRepoOpts{
+		Name: newName,
+	}
+
+	path := fmt.Sprintf("/rest/api/1.0/projects/%s/repos/%s", namespace, oldName)
+	resp, err := cli.Put(context.Background(), path, nil, opts, nil)
+	resp.EnsureClosed()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return scm.RepoNotFoundError(oldName)
+	case http.StatusConflict:
+		return scm.RepoExistError(newName)
+	default:
+		return checkServerError(resp, err)
+	}
+}
+
 func (cli *bitbucketClient) Populate(namespace, name string, payload io.Reader, size int64) error {
 	path := fmt.Sprintf("/rest/deploy/1.0/projects/%s/repos/%s/populate", namespace, name)
 
@@ -204,8 +256,9 @@ func (cli *bitbucketClient) Populate(namespace, name string, payload io.Reader,
 		"Content-Type":   {"application/tar"},
 		"Content-Length": {strconv.FormatInt(size, 10)},
 	}
-	resp, err = cli.PutRaw(context.Background(), path, nil, payload, headers)
+	resp, err = cli.long.PutRaw(context.Background(), path, nil, payload, headers)
 	resp.EnsureClosed()
+	cli.cache.invalidate(namespace, name)
 	return checkNamespaceError(namespace, resp, err)
 }
 
@@ -223,13 +276,32 @@ func isAllowedScheme(scheme string) bool {
 }
 
 func (cli *bitbucketClient) PopulateURL(namespace, name, remote string) error {
-	u, err := url.Parse(remote)
+	return cli.populateURL(namespace, name, PopulateOpts{URL: remote})
+}
+
+// PopulateURLWithCredentials populates the repository from an URL that
+// requires authentication. The credentials are sent in the request body,
+// so they are neither logged in the server access log nor saved in the
+// remote configuration of the cloned repository.
+func (cli *bitbucketClient) PopulateURLWithCredentials(namespace, name, remote string, cred *scm.Credentials) error {
+	return cli.populateURL(namespace, name, PopulateOpts{
+		URL:      remote,
+		Username: cred.Username,
+		Password: cred.Password,
+	})
+}
+
+func (cli *bitbucketClient) populateURL(namespace, name string, opts PopulateOpts) error {
+	u, err := url.Parse(opts.URL)
 	if err != nil {
 		return err
 	}
 	if u.Scheme == "" || !isAllowedScheme(u.Scheme) {
 		return fmt.Errorf("Unsupported Git clone scheme: %s", u.Scheme)
 	}
+	if opts.Username != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("Credentials are only supported for HTTP repository URL")
+	}
 
 	path := fmt.Sprintf("/rest/deploy/1.0/projects/%s/repos/%s/populate", namespace, name)
 
@@ -243,20 +315,20 @@ func (cli *bitbucketClient) PopulateURL(namespace, name, remote string) error {
 	}
 
 	// populate repository from template URL
-	query := url.Values{"url": []string{remote}}
-	resp, err = cli.Post(context.Background(), path, query, nil, nil)
+	resp, err = cli.long.Post(context.Background(), path, nil, &opts, nil)
 	resp.EnsureClosed()
+	cli.cache.invalidate(namespace, name)
 	return checkNamespaceError(namespace, resp, err)
 }
 
-func (cli *bitbucketClient) Deploy(_ container.Engine, namespace, name string, branch string, log *serverlog.ServerLog) error {
+func (cli *bitbucketClient) Deploy(ctx context.Context, _ container.Engine, namespace, name string, branch string, log *serverlog.ServerLog) error {
 	if log == nil {
 		log = serverlog.Discard
 	}
 
 	path := fmt.Sprintf("/rest/deploy/1.0/projects/%s/repos/%s/deploy", namespace, name)
 	query := url.Values{"branch": []string{branch}}
-	resp, err := cli.Post(context.Background(), path, query, nil, nil)
+	resp, err := cli.long.Post(ctx, path, query, nil, nil)
 	if err != nil {
 		return checkNamespaceError(namespace, resp, err)
 	} else {
@@ -278,52 +350,118 @@ func (cli *bitbucketClient) GetDeploymentBranch(namespace, name string) (branch
 	return
 }
 
-func (cli *bitbucketClient) GetDeploymentBranches(namespace, name string) ([]*scm.Branch, error) {
-	branches, err := cli.getRefs(namespace, name, "branches")
+func (cli *bitbucketClient) GetDeploymentBranches(namespace, name string, opts scm.BranchOptions) ([]*scm.Branch, error) {
+	refs, err := cli.getRefs(namespace, name, "branches", opts.FilterText, opts.Limit)
 	if err != nil {
 		return nil, err
 	}
 
-	tags, err := cli.getRefs(namespace, name, "tags")
-	if err != nil {
-		return nil, err
+	if opts.Limit <= 0 || len(refs) < opts.Limit {
+		limit := 0
+		if opts.Limit > 0 {
+			limit = opts.Limit - len(refs)
+		}
+		tags, err := cli.getRefs(namespace, name, "tags", opts.FilterText, limit)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, tags...)
 	}
 
-	return append(branches, tags...), nil
+	return scm.FilterBranches(refs, opts), nil
 }
 
-func (cli *bitbucketClient) getRefs(namespace, name, typ string) (refs []*scm.Branch, err error) {
+// getRefs returns branches or tags of the repository. The filter text is
+// passed to Bitbucket to search refs, and no more pages are requested once
+// the limit is reached. Unfiltered and complete result is cached for a
+// short time, and an expired result is revalidated with a conditional
+// request on the first page.
+func (cli *bitbucketClient) getRefs(namespace, name, typ, filter string, limit int) ([]*scm.Branch, error) {
 	var (
 		path  = fmt.Sprintf("/rest/api/1.0/projects/%s/repos/%s/%s", namespace, name, typ)
 		ctx   = context.Background()
+		key   = refCacheKey(namespace, name, typ)
 		start = 0
 	)
+
+	var cached *refCacheEntry
+	if filter == "" {
+		var fresh bool
+		if cached, fresh = cli.cache.get(key); fresh {
+			return limitRefs(cached.refs, limit), nil
+		}
+	}
+
+	var headers map[string][]string
+	if cached != nil && cached.etag != "" {
+		headers = map[string][]string{"If-None-Match": {cached.etag}}
+	}
+
+	var refs []*scm.Branch
+	var etag string
 	for {
-		page, er := cli.getRefPage(ctx, path, namespace, start)
-		if er != nil {
-			err = er
-			break
+		page, resp, err := cli.getRefPage(ctx, path, namespace, filter, start, limit-len(refs), headers)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusNotModified {
+			cli.cache.put(key, cached.refs, cached.etag)
+			return limitRefs(cached.refs, limit), nil
+		}
+		if start == 0 {
+			etag = resp.Header.Get("ETag")
+		}
+		for _, ref := range page.Values {
+			refs = append(refs, ref.toBranch())
 		}
-		refs = append(refs, page.Values...)
 		start = page.NextPageStart
+		headers = nil
 		if page.IsLastPage {
 			break
 		}
+		if limit > 0 && len(refs) >= limit {
+			// incomplete result is not cached
+			return limitRefs(refs, limit), nil
+		}
 	}
-	return
+
+	if filter == "" {
+		cli.cache.put(key, refs, etag)
+	}
+	return limitRefs(refs, limit), nil
 }
 
-func (cli *bitbucketClient) getRefPage(ctx context.Context, path, namespace string, start int) (page *BranchPage, err error) {
-	params := url.Values{"start": []string{strconv.Itoa(start)}}
-	resp, err := cli.Get(ctx, path, params, nil)
-	if err == nil {
-		page = new(BranchPage)
-		err = json.NewDecoder(resp.Body).Decode(page)
-		resp.Body.Close()
-	} else {
-		err = checkNamespaceError(namespace, resp, err)
+func (cli *bitbucketClient) getRefPage(ctx context.Context, path, namespace, filter string, start, limit int, headers map[string][]string) (page *BranchPage, resp *rest.ServerResponse, err error) {
+	params := url.Values{
+		"start":   []string{strconv.Itoa(start)},
+		"details": []string{"true"},
 	}
-	return
+	if filter != "" {
+		params.Set("filterText", filter)
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+
+	resp, err = cli.Get(ctx, path, params, headers)
+	if err != nil {
+		return nil, resp, checkNamespaceError(namespace, resp, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, resp, nil
+	}
+	page = new(BranchPage)
+	err = json.NewDecoder(resp.Body).Decode(page)
+	return page, resp, err
+}
+
+// limitRefs returns a copy of at most limit refs, zero means no limit.
+func limitRefs(refs []*scm.Branch, limit int) []*scm.Branch {
+	if limit > 0 && len(refs) > limit {
+		refs = refs[:limit]
+	}
+	return append([]*scm.Branch(nil), refs...)
 }
 
 func (cli *bitbucketClient) AddKey(namespace string, key string) error {
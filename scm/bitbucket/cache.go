@@ -0,0 +1,62 @@
+package bitbucket
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudway/platform/scm"
+)
+
+const defaultCacheTTL = 30 * time.Second
+
+// refCache caches branches and tags of repositories for a short time, so
+// that repeated requests don't hit Bitbucket with multiple paginated calls.
+// The ETag of an expired entry is used to make conditional request.
+type refCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*refCacheEntry
+}
+
+type refCacheEntry struct {
+	refs    []*scm.Branch
+	etag    string
+	expires time.Time
+}
+
+func newRefCache(ttl time.Duration) *refCache {
+	return &refCache{ttl: ttl, entries: make(map[string]*refCacheEntry)}
+}
+
+func refCacheKey(namespace, name, typ string) string {
+	return namespace + "/" + name + "/" + typ
+}
+
+// get returns the cached entry and whether it's still fresh.
+func (c *refCache) get(key string) (entry *refCacheEntry, fresh bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry = c.entries[key]
+	return entry, entry != nil && time.Now().Before(entry.expires)
+}
+
+func (c *refCache) put(key string, refs []*scm.Branch, etag string) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	c.entries[key] = &refCacheEntry{refs: refs, etag: etag, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+}
+
+func (c *refCache) invalidate(namespace, name string) {
+	c.mu.Lock()
+	for _, typ := range []string{"branches", "tags"} {
+		delete(c.entries, refCacheKey(namespace, name, typ))
+	}
+	c.mu.Unlock()
+}
+
+func (cli *bitbucketClient) InvalidateCache(namespace, name string) {
+	cli.cache.invalidate(namespace, name)
+}
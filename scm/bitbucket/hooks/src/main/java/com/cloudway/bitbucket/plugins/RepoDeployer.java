@@ -13,9 +13,11 @@ import java.io.InputStream;
 import java.io.OutputStream;
 import java.nio.channels.Channels;
 import java.nio.channels.Pipe;
+import java.nio.charset.StandardCharsets;
 import java.nio.file.Files;
 import java.nio.file.Path;
 import java.nio.file.attribute.PosixFilePermission;
+import java.util.Base64;
 import java.util.EnumSet;
 import java.util.Set;
 import java.util.logging.Level;
@@ -310,8 +312,18 @@ public class RepoDeployer
     }
 
     public void populate(Repository repository, String url) throws IOException {
+        populate(repository, url, null, null);
+    }
+
+    public void populate(Repository repository, String url, String username, String password)
+        throws IOException
+    {
         Path tempRepoDir = Files.createTempDirectory("repo");
-        cloneTemplateRepo(tempRepoDir, url);
+        if (username == null || username.isEmpty()) {
+            cloneTemplateRepo(tempRepoDir, url);
+        } else {
+            fetchTemplateRepo(tempRepoDir, url, username, password);
+        }
         pushTemplateToNewRepo(tempRepoDir, repository);
         FileUtils.deleteDirectory(tempRepoDir.toFile());
     }
@@ -378,6 +390,37 @@ public class RepoDeployer
             .call();
     }
 
+    // Fetch the private repository with credentials sent in the HTTP
+    // Authorization header. The header is only configured in the temporary
+    // repository, so the credentials are neither passed in the command line
+    // nor saved in the new repository.
+    private void fetchTemplateRepo(Path tempRepoDir, String url, String username, String password) {
+        gitCommandBuilderFactory.builder()
+            .workingDirectory(tempRepoDir.toString())
+            .command("init")
+            .argument("--bare")
+            .build(new LoggingHandler(System.err))
+            .call();
+
+        String auth = username + ":" + (password == null ? "" : password);
+        gitCommandBuilderFactory.builder()
+            .workingDirectory(tempRepoDir.toString())
+            .command("config")
+            .argument("http.extraHeader")
+            .argument("Authorization: Basic " + Base64.getEncoder().encodeToString(auth.getBytes(StandardCharsets.UTF_8)))
+            .build(new LoggingHandler(System.err))
+            .call();
+
+        gitCommandBuilderFactory.builder()
+            .workingDirectory(tempRepoDir.toString())
+            .command("fetch")
+            .argument(url)
+            .argument("+refs/heads/*:refs/heads/*")
+            .argument("+refs/tags/*:refs/tags/*")
+            .build(new LoggingHandler(System.err))
+            .call();
+    }
+
     private void createTemplateRepo(Path tempRepoDir) {
         // git init
         gitCommandBuilderFactory.builder()
@@ -0,0 +1,22 @@
+/**
+ * Cloudway Platform
+ * Copyright (c) 2012-2016 Cloudway Technology, Inc.
+ * All rights reserved.
+ */
+
+package com.cloudway.bitbucket.plugins.rest;
+
+import org.codehaus.jackson.annotate.JsonIgnoreProperties;
+import org.codehaus.jackson.annotate.JsonProperty;
+
+@JsonIgnoreProperties(ignoreUnknown = true)
+public class PopulateOptions {
+    @JsonProperty
+    public String url;
+
+    @JsonProperty
+    public String username;
+
+    @JsonProperty
+    public String password;
+}
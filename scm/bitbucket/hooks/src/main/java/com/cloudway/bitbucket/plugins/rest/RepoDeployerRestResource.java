@@ -134,12 +134,21 @@ public class RepoDeployerRestResource {
 
     @POST
     @Path("/populate")
-    public Response populate(@Context Repository repository, @QueryParam("url") String url) {
+    public Response populate(@Context Repository repository, @QueryParam("url") String url, PopulateOptions opts) {
         validator.validateForRepository(repository, Permission.REPO_WRITE);
 
+        // the URL in query parameter is accepted from older clients
+        if (opts == null) {
+            opts = new PopulateOptions();
+            opts.url = url;
+        }
+        if (opts.url == null || opts.url.isEmpty()) {
+            return Response.status(Response.Status.BAD_REQUEST).build();
+        }
+
         if (repoService.isEmpty(repository)) {
             try {
-                deployer.populate(repository, url);
+                deployer.populate(repository, opts.url, opts.username, opts.password);
                 return Response.noContent().build();
             } catch (Exception ex) {
                 return Response.serverError().build();
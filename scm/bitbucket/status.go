@@ -0,0 +1,66 @@
+package bitbucket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/cloudway/platform/scm"
+)
+
+// Bitbucket build states corresponding to commit status states.
+var buildStates = map[string]string{
+	scm.StatusPending: "INPROGRESS",
+	scm.StatusSuccess: "SUCCESSFUL",
+	scm.StatusFailed:  "FAILED",
+}
+
+func (cli *bitbucketClient) ResolveCommit(namespace, name, branch string) (string, error) {
+	if branch == "" {
+		current, err := cli.GetDeploymentBranch(namespace, name)
+		if err != nil {
+			return "", err
+		}
+		branch = current.Id
+	}
+
+	var (
+		path  = fmt.Sprintf("/rest/api/1.0/projects/%s/repos/%s/commits", namespace, name)
+		query = url.Values{"until": []string{branch}, "limit": []string{"1"}}
+	)
+	resp, err := cli.Get(context.Background(), path, query, nil)
+	if err != nil {
+		return "", checkNamespaceError(namespace, resp, err)
+	}
+	defer resp.Body.Close()
+
+	var page CommitPage
+	if err = json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return "", err
+	}
+	if len(page.Values) == 0 {
+		return "", nil // empty repository
+	}
+	return page.Values[0].Id, nil
+}
+
+func (cli *bitbucketClient) SetCommitStatus(namespace, name, commit string, status *scm.CommitStatus) error {
+	state, ok := buildStates[status.State]
+	if !ok {
+		return fmt.Errorf("Invalid commit status: %s", status.State)
+	}
+
+	opts := BuildStatus{
+		State:       state,
+		Key:         status.Key,
+		Name:        status.Name,
+		URL:         status.URL,
+		Description: status.Description,
+	}
+
+	path := "/rest/build-status/1.0/commits/" + commit
+	resp, err := cli.Post(context.Background(), path, nil, opts, nil)
+	resp.EnsureClosed()
+	return checkServerError(resp, err)
+}
@@ -0,0 +1,74 @@
+package bitbucket
+
+import (
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/cloudway/platform/config"
+)
+
+const (
+	defaultTimeout      = 30 * time.Second
+	defaultMaxIdleConns = 16
+)
+
+// authorization returns the value of Authorization header used by REST
+// calls. A personal access token or OAuth access token configured by the
+// "scm.token" key takes precedence over the "scm.username" and
+// "scm.password" keys. For backward compatibility the credentials can
+// also be embedded in the Bitbucket URL.
+func authorization(u *url.URL) string {
+	if token := config.Get("scm.token"); token != "" {
+		return "Bearer " + token
+	}
+
+	username, password := config.Get("scm.username"), config.Get("scm.password")
+	if username == "" && u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+	if username == "" {
+		return ""
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+// newHTTPClient creates a HTTP client that keeps connections alive and
+// shares them among all REST calls. The timeout and the maximum number of
+// idle connections can be configured by "scm.timeout" and
+// "scm.max_idle_conns" keys. The timeout bounds connecting to the server
+// and, if waitResponse is false, waiting for the response headers. Reading
+// the response body is never bounded, so streamed responses such as the
+// deployment output are not cut off. Calls that respond after long running
+// work, such as populating a repository, should wait for the response.
+func newHTTPClient(waitResponse bool) *http.Client {
+	timeout := defaultTimeout
+	if d, err := time.ParseDuration(config.Get("scm.timeout")); err == nil && d > 0 {
+		timeout = d
+	}
+
+	maxIdle := defaultMaxIdleConns
+	if n, err := strconv.Atoi(config.Get("scm.max_idle_conns")); err == nil && n > 0 {
+		maxIdle = n
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		Dial: (&net.Dialer{
+			Timeout:   timeout,
+			KeepAlive: 30 * time.Second,
+		}).Dial,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        maxIdle,
+		MaxIdleConnsPerHost: maxIdle,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	if !waitResponse {
+		transport.ResponseHeaderTimeout = timeout
+	}
+	return &http.Client{Transport: transport}
+}
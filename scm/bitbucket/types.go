@@ -3,6 +3,8 @@ package bitbucket
 import (
 	"bytes"
 	"fmt"
+	"time"
+
 	"github.com/cloudway/platform/scm"
 )
 
@@ -22,6 +24,12 @@ type CreateRepoOpts struct {
 	Name string `json:"name"`
 }
 
+type PopulateOpts struct {
+	URL      string `json:"url"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
 type Repo struct {
 	Slug string `json:"slug"`
 }
@@ -45,9 +53,53 @@ type SSHKeyPage struct {
 	Values []SSHKey `json:"values"`
 }
 
+type Ref struct {
+	Id           string `json:"id"`
+	DisplayId    string `json:"displayId"`
+	Type         string `json:"type"`
+	LatestCommit string `json:"latestCommit"`
+	Metadata     struct {
+		Commit *struct {
+			AuthorTimestamp int64 `json:"authorTimestamp"`
+		} `json:"com.atlassian.bitbucket.server.bitbucket-branch:latest-commit-metadata"`
+	} `json:"metadata"`
+}
+
+func (ref *Ref) toBranch() *scm.Branch {
+	br := &scm.Branch{
+		Id:           ref.Id,
+		DisplayId:    ref.DisplayId,
+		Type:         ref.Type,
+		LatestCommit: ref.LatestCommit,
+	}
+	if c := ref.Metadata.Commit; c != nil && c.AuthorTimestamp != 0 {
+		ms := c.AuthorTimestamp
+		br.LatestCommitTime = time.Unix(ms/1000, ms%1000*int64(time.Millisecond))
+	}
+	return br
+}
+
 type BranchPage struct {
 	Page
-	Values []*scm.Branch `json:"values"`
+	Values []*Ref `json:"values"`
+}
+
+type Commit struct {
+	Id        string `json:"id"`
+	DisplayId string `json:"displayId"`
+}
+
+type CommitPage struct {
+	Page
+	Values []Commit `json:"values"`
+}
+
+type BuildStatus struct {
+	State       string `json:"state"`
+	Key         string `json:"key"`
+	Name        string `json:"name,omitempty"`
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
 }
 
 type ServerErrors struct {
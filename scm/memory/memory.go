@@ -0,0 +1,356 @@
+// Package memory provides a source code management that keeps all
+// repositories in memory. It's intended for development and testing, and
+// all repositories are lost when the process exits.
+package memory
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/serverlog"
+	"github.com/cloudway/platform/scm"
+)
+
+// SCM is an in-memory source code management. Repositories hold the
+// archive populated from a template, which is deployed as is, repositories
+// populated from an URL are deployed empty.
+type SCM struct {
+	mu         sync.Mutex
+	namespaces map[string]*scmNamespace
+}
+
+type scmNamespace struct {
+	repos map[string]*Repo
+	keys  []scm.SSHKey
+}
+
+// Repo is a repository in the in-memory SCM.
+type Repo struct {
+	// The archive populated from a template, nil if the repository is
+	// empty.
+	Content []byte
+
+	// The URL the repository populated from.
+	URL string
+
+	// The current deployment branch.
+	Branch *scm.Branch
+
+	// The number of deployments of the repository.
+	Deployments int
+}
+
+// The SCM shared by the process. The SCM is created again when the
+// configuration reloaded, which must not lose repositories.
+var (
+	sharedOnce sync.Once
+	shared     *SCM
+)
+
+func init() {
+	old := scm.New
+	scm.New = func() (scm.SCM, error) {
+		if config.Get("scm.type") != "memory" {
+			return old()
+		}
+		sharedOnce.Do(func() { shared = New() })
+		return shared, nil
+	}
+}
+
+// New creates an empty in-memory SCM.
+func New() *SCM {
+	return &SCM{namespaces: make(map[string]*scmNamespace)}
+}
+
+func (s *SCM) Type() string {
+	return "memory"
+}
+
+// Repo returns a copy of the repository, or nil if the repository doesn't
+// exist.
+func (s *SCM) Repo(namespace, name string) *Repo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ns := s.namespaces[namespace]; ns != nil && ns.repos[name] != nil {
+		repo := *ns.repos[name]
+		return &repo
+	}
+	return nil
+}
+
+func (s *SCM) CreateNamespace(namespace string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.namespaces[namespace] != nil {
+		return scm.NamespaceExistError(namespace)
+	}
+	s.namespaces[namespace] = &scmNamespace{repos: make(map[string]*Repo)}
+	return nil
+}
+
+func (s *SCM) RemoveNamespace(namespace string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.namespaces[namespace] == nil {
+		return scm.NamespaceNotFoundError(namespace)
+	}
+	delete(s.namespaces, namespace)
+	return nil
+}
+
+func (s *SCM) RenameNamespace(oldNamespace, newNamespace string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns := s.namespaces[oldNamespace]
+	if ns == nil {
+		return scm.NamespaceNotFoundError(oldNamespace)
+	}
+	if s.namespaces[newNamespace] != nil {
+		return scm.NamespaceExistError(newNamespace)
+	}
+	delete(s.namespaces, oldNamespace)
+	s.namespaces[newNamespace] = ns
+	return nil
+}
+
+func (s *SCM) CreateRepo(namespace, name string, purge bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns := s.namespaces[namespace]
+	if ns == nil {
+		return scm.NamespaceNotFoundError(namespace)
+	}
+	if ns.repos[name] != nil && !purge {
+		return scm.RepoExistError(name)
+	}
+	ns.repos[name] = &Repo{Branch: defaultBranch()}
+	return nil
+}
+
+func (s *SCM) RemoveRepo(namespace, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns, err := s.namespace(namespace)
+	if err != nil {
+		return err
+	}
+	if ns.repos[name] == nil {
+		return scm.RepoNotFoundError(name)
+	}
+	delete(ns.repos, name)
+	return nil
+}
+
+func (s *SCM) RenameRepo(namespace, oldName, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo, err := s.repo(namespace, oldName)
+	if err != nil {
+		return err
+	}
+	ns := s.namespaces[namespace]
+	if ns.repos[newName] != nil {
+		return scm.RepoExistError(newName)
+	}
+	delete(ns.repos, oldName)
+	ns.repos[newName] = repo
+	return nil
+}
+
+func (s *SCM) Populate(namespace, name string, payload io.Reader, size int64) error {
+	content, err := ioutil.ReadAll(payload)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo, err := s.repo(namespace, name)
+	if err != nil || repo.Content != nil || repo.URL != "" {
+		return err
+	}
+	repo.Content = content
+	return nil
+}
+
+func (s *SCM) PopulateURL(namespace, name string, url string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo, err := s.repo(namespace, name)
+	if err != nil || repo.Content != nil || repo.URL != "" {
+		return err
+	}
+	repo.URL = url
+	return nil
+}
+
+// PopulateURLWithCredentials records the URL of a private repository. The
+// credentials are not kept.
+func (s *SCM) PopulateURLWithCredentials(namespace, name, url string, cred *scm.Credentials) error {
+	return s.PopulateURL(namespace, name, url)
+}
+
+func (s *SCM) Deploy(ctx context.Context, engine container.Engine, namespace, name string, branch string, log *serverlog.ServerLog) error {
+	if log == nil {
+		log = serverlog.Discard
+	}
+
+	s.mu.Lock()
+	repo, err := s.repo(namespace, name)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	if branch != "" {
+		repo.Branch = &scm.Branch{Id: branch, DisplayId: displayID(branch), Type: "BRANCH"}
+	}
+	repo.Deployments++
+	content := repo.Content
+	s.mu.Unlock()
+
+	if content == nil {
+		if content, err = emptyArchive(); err != nil {
+			return err
+		}
+	}
+	return engine.DeployRepo(ctx, name, namespace, bytes.NewReader(content), log)
+}
+
+func (s *SCM) GetDeploymentBranch(namespace, name string) (*scm.Branch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo, err := s.repo(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	branch := *repo.Branch
+	return &branch, nil
+}
+
+func (s *SCM) GetDeploymentBranches(namespace, name string, opts scm.BranchOptions) ([]*scm.Branch, error) {
+	current, err := s.GetDeploymentBranch(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	branches := []*scm.Branch{defaultBranch()}
+	if current.Id != branches[0].Id {
+		branches = append(branches, current)
+	}
+	return scm.FilterBranches(branches, opts), nil
+}
+
+func (s *SCM) AddKey(namespace string, key string) error {
+	_, label, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return scm.InvalidKeyError{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns, err := s.namespace(namespace)
+	if err != nil {
+		return err
+	}
+	key = strings.TrimSpace(key)
+	for _, k := range ns.keys {
+		if k.Text == key {
+			return fmt.Errorf("The SSH public key already exists: %s", key)
+		}
+	}
+	ns.keys = append(ns.keys, scm.SSHKey{Label: label, Text: key})
+	return nil
+}
+
+func (s *SCM) RemoveKey(namespace string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns, err := s.namespace(namespace)
+	if err != nil {
+		return err
+	}
+	key = strings.TrimSpace(key)
+	for i, k := range ns.keys {
+		if k.Text == key {
+			ns.keys = append(ns.keys[:i:i], ns.keys[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("ssh key not found")
+}
+
+func (s *SCM) ListKeys(namespace string) ([]scm.SSHKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns, err := s.namespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return append([]scm.SSHKey(nil), ns.keys...), nil
+}
+
+func (s *SCM) namespace(namespace string) (*scmNamespace, error) {
+	ns := s.namespaces[namespace]
+	if ns == nil {
+		return nil, scm.NamespaceNotFoundError(namespace)
+	}
+	return ns, nil
+}
+
+func (s *SCM) repo(namespace, name string) (*Repo, error) {
+	ns, err := s.namespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+	repo := ns.repos[name]
+	if repo == nil {
+		return nil, scm.RepoNotFoundError(name)
+	}
+	return repo, nil
+}
+
+func defaultBranch() *scm.Branch {
+	return &scm.Branch{Id: "refs/heads/master", DisplayId: "master", Type: "BRANCH"}
+}
+
+func displayID(ref string) string {
+	ref = strings.TrimPrefix(ref, "refs/heads/")
+	return strings.TrimPrefix(ref, "refs/tags/")
+}
+
+func emptyArchive() ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	err := tw.Close()
+	if err == nil {
+		err = zw.Close()
+	}
+	return buf.Bytes(), err
+}
+
+var _ scm.SCM = (*SCM)(nil)
@@ -5,14 +5,16 @@ import (
 	"bufio"
 	"compress/gzip"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 
@@ -56,6 +58,11 @@ func (mock mockSCM) Type() string {
 	return "git"
 }
 
+func (mock mockSCM) Ping() error {
+	_, err := os.Stat(mock.repositoryRoot)
+	return err
+}
+
 func (mock mockSCM) ensureNamespaceExist(namespace string) error {
 	dir := filepath.Join(mock.repositoryRoot, namespace)
 	st, err := os.Stat(dir)
@@ -137,6 +144,36 @@ func (mock mockSCM) RemoveNamespace(namespace string) error {
 	return os.RemoveAll(dir)
 }
 
+func (mock mockSCM) RenameNamespace(oldNamespace, newNamespace string) error {
+	if err := mock.ensureNamespaceExist(oldNamespace); err != nil {
+		return err
+	}
+	if err := mock.ensureNamespaceNotExist(newNamespace); err != nil {
+		return err
+	}
+
+	dir := filepath.Join(mock.repositoryRoot, newNamespace)
+	if err := os.Rename(filepath.Join(mock.repositoryRoot, oldNamespace), dir); err != nil {
+		return err
+	}
+
+	// the post-receive hooks refer to the namespace
+	repos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range repos {
+		hook := filepath.Join(dir, fi.Name(), "hooks", "post-receive")
+		if _, err := os.Stat(hook); err == nil {
+			script := fmt.Sprintf(postReceiveHook, fi.Name(), newNamespace)
+			if err = ioutil.WriteFile(hook, []byte(script), 0750); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 const postReceiveHook = `#!/bin/bash
 
 if git config cloudway.disablehook 2>/dev/null; then
@@ -188,6 +225,24 @@ func (mock mockSCM) RemoveRepo(namespace, name string) error {
 	return os.RemoveAll(repodir)
 }
 
+func (mock mockSCM) RenameRepo(namespace, oldName, newName string) error {
+	if err := mock.ensureRepositoryExist(namespace, oldName); err != nil {
+		return err
+	}
+	if err := mock.ensureRepositoryNotExist(namespace, newName); err != nil {
+		return err
+	}
+
+	repodir := filepath.Join(mock.repositoryRoot, namespace, newName)
+	if err := os.Rename(filepath.Join(mock.repositoryRoot, namespace, oldName), repodir); err != nil {
+		return err
+	}
+
+	hook := filepath.Join(repodir, "hooks", "post-receive")
+	script := fmt.Sprintf(postReceiveHook, newName, namespace)
+	return ioutil.WriteFile(hook, []byte(script), 0750)
+}
+
 func (mock mockSCM) Populate(namespace, name string, payload io.Reader, size int64) error {
 	if empty, err := mock.isEmptyRepository(namespace, name); !empty || err != nil {
 		return err
@@ -232,6 +287,25 @@ func (mock mockSCM) Populate(namespace, name string, payload io.Reader, size int
 }
 
 func (mock mockSCM) PopulateURL(namespace, name string, url string) error {
+	return mock.populateURL(namespace, name, url)
+}
+
+// PopulateURLWithCredentials clones the remote repository with credentials
+// sent in the HTTP Authorization header, so they are not saved anywhere.
+func (mock mockSCM) PopulateURLWithCredentials(namespace, name, remote string, cred *scm.Credentials) error {
+	u, err := url.Parse(remote)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("Credentials are only supported for HTTP repository URL")
+	}
+
+	auth := base64.StdEncoding.EncodeToString([]byte(cred.Username + ":" + cred.Password))
+	return mock.populateURL(namespace, name, remote, "-c", "http.extraHeader=Authorization: Basic "+auth)
+}
+
+func (mock mockSCM) populateURL(namespace, name string, url string, gitopts ...string) error {
 	if empty, err := mock.isEmptyRepository(namespace, name); !empty || err != nil {
 		return err
 	}
@@ -244,7 +318,8 @@ func (mock mockSCM) PopulateURL(namespace, name string, url string) error {
 	defer os.RemoveAll(tempdir)
 
 	repo := NewGitRepo(tempdir)
-	if err := repo.Run("clone", "--bare", "--no-hardlinks", url, "."); err != nil {
+	args := append(gitopts, "clone", "--bare", "--no-hardlinks", url, ".")
+	if err := repo.Run(args...); err != nil {
 		return err
 	}
 
@@ -257,7 +332,7 @@ func (mock mockSCM) PopulateURL(namespace, name string, url string) error {
 	return repo.Run("push", "--mirror", repodir)
 }
 
-func (mock mockSCM) Deploy(engine container.Engine, namespace, name string, branch string, log *serverlog.ServerLog) (err error) {
+func (mock mockSCM) Deploy(ctx context.Context, engine container.Engine, namespace, name string, branch string, log *serverlog.ServerLog) (err error) {
 	if log == nil {
 		log = serverlog.Discard
 	}
@@ -307,7 +382,7 @@ func (mock mockSCM) Deploy(engine container.Engine, namespace, name string, bran
 		return err
 	}
 
-	return engine.DeployRepo(context.Background(), name, namespace, repofile, log)
+	return engine.DeployRepo(ctx, name, namespace, repofile, log)
 }
 
 const _DEFAULT_BRANCH = "refs/heads/master"
@@ -327,11 +402,15 @@ func (mock mockSCM) GetDeploymentBranch(namespace, name string) (*scm.Branch, er
 	return mock.getCurrentDeployment(namespace, name, "")
 }
 
-func (mock mockSCM) GetDeploymentBranches(namespace, name string) ([]*scm.Branch, error) {
+func (mock mockSCM) GetDeploymentBranches(namespace, name string, opts scm.BranchOptions) ([]*scm.Branch, error) {
 	if empty, err := mock.isEmptyRepository(namespace, name); empty || err != nil {
 		return nil, err
 	}
-	return mock.getAllBranches(namespace, name)
+	branches, err := mock.getAllBranches(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return scm.FilterBranches(branches, opts), nil
 }
 
 func (mock mockSCM) getCurrentDeployment(namespace, name, refId string) (*scm.Branch, error) {
@@ -371,47 +450,60 @@ func (mock mockSCM) getCurrentDeployment(namespace, name, refId string) (*scm.Br
 	return current, nil
 }
 
+// The format of git for-each-ref output, annotated tags are dereferenced
+// to get the commit object.
+const refFormat = "%(refname)%09%(objectname)%09%(committerdate:raw)%09%(*objectname)%09%(*committerdate:raw)"
+
 func (mock mockSCM) getAllBranches(namespace, name string) ([]*scm.Branch, error) {
 	repodir := filepath.Join(mock.repositoryRoot, namespace, name)
 	repo := NewGitRepo(repodir)
 
-	branches, err := getGitRefs(repo.Command("branch", "--no-color"), "refs/heads/", "BRANCH")
+	out, err := repo.Output("for-each-ref", "--format="+refFormat, "refs/heads/", "refs/tags/")
 	if err != nil {
 		return nil, err
 	}
-	tags, err := getGitRefs(repo.Command("tag"), "refs/tags/", "TAG")
-	if err != nil {
-		return nil, err
-	}
-	return append(branches, tags...), nil
-}
 
-func getGitRefs(cmd *exec.Cmd, refPrefix, refType string) ([]*scm.Branch, error) {
-	cmd.Stdout = nil
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
+	var result []*scm.Branch
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			continue
+		}
 
-	refs := strings.Split(string(output), "\n")
-	result := make([]*scm.Branch, 0, len(refs))
-	for i := range refs {
-		id := refs[i]
-		if id == "" {
+		ref := &scm.Branch{Id: fields[0]}
+		switch {
+		case strings.HasPrefix(ref.Id, "refs/heads/"):
+			ref.DisplayId, ref.Type = strings.TrimPrefix(ref.Id, "refs/heads/"), "BRANCH"
+		case strings.HasPrefix(ref.Id, "refs/tags/"):
+			ref.DisplayId, ref.Type = strings.TrimPrefix(ref.Id, "refs/tags/"), "TAG"
+		default:
 			continue
 		}
-		if strings.HasPrefix(id, "  ") || strings.HasPrefix(id, "* ") {
-			id = id[2:]
+
+		commit, date := fields[1], fields[2]
+		if fields[3] != "" {
+			commit, date = fields[3], fields[4]
 		}
-		result = append(result, &scm.Branch{
-			Id:        refPrefix + id,
-			DisplayId: id,
-			Type:      refType,
-		})
+		ref.LatestCommit = commit
+		ref.LatestCommitTime = parseGitDate(date)
+		result = append(result, ref)
 	}
 	return result, nil
 }
 
+// parseGitDate parses the raw git date in the form of "<seconds> <zone>".
+func parseGitDate(raw string) time.Time {
+	fields := strings.Fields(raw)
+	if len(fields) == 0 {
+		return time.Time{}
+	}
+	sec, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
 func (mock mockSCM) AddKey(namespace string, key string) error {
 	if err := mock.ensureNamespaceExist(namespace); err != nil {
 		return err
@@ -106,6 +106,58 @@ var _ = Describe("SCM", func() {
 		})
 	})
 
+	Describe("Rename namespace", func() {
+		It("should move repositories to the new namespace", func() {
+			Expect(mock.CreateNamespace("demo")).To(Succeed())
+			Expect(mock.CreateRepo("demo", "test", false)).To(Succeed())
+			Expect(scm.RenameNamespace(mock, "demo", "demo2")).To(Succeed())
+			Expect(filepath.Join(repoRoot, "demo")).NotTo(BeADirectory())
+			Expect(filepath.Join(repoRoot, "demo2", "test")).To(BeADirectory())
+
+			hook, err := ioutil.ReadFile(filepath.Join(repoRoot, "demo2", "test", "hooks", "post-receive"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(hook)).To(ContainSubstring("cwman deploy test demo2"))
+		})
+
+		It("should fail when new namespace already exists", func() {
+			Expect(mock.CreateNamespace("demo")).To(Succeed())
+			Expect(mock.CreateNamespace("demo2")).To(Succeed())
+			Expect(scm.RenameNamespace(mock, "demo", "demo2")).NotTo(Succeed())
+		})
+
+		It("should fail when namespace does not exists", func() {
+			Expect(scm.RenameNamespace(mock, "demo", "demo2")).NotTo(Succeed())
+		})
+	})
+
+	Describe("Rename repository", func() {
+		BeforeEach(func() {
+			Expect(mock.CreateNamespace("demo")).To(Succeed())
+			Expect(mock.CreateRepo("demo", "test", false)).To(Succeed())
+		})
+
+		It("should move the repository and update the hook", func() {
+			Expect(scm.RenameRepo(mock, "demo", "test", "test2")).To(Succeed())
+			Expect(filepath.Join(repoRoot, "demo", "test")).NotTo(BeADirectory())
+			Expect(filepath.Join(repoRoot, "demo", "test2")).To(BeADirectory())
+
+			hook, err := ioutil.ReadFile(filepath.Join(repoRoot, "demo", "test2", "hooks", "post-receive"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(hook)).To(ContainSubstring("cwman deploy test2 demo"))
+		})
+
+		It("should fail when new repository already exists", func() {
+			Expect(mock.CreateRepo("demo", "test2", false)).To(Succeed())
+			err := scm.RenameRepo(mock, "demo", "test", "test2")
+			Expect(err).To(BeAssignableToTypeOf(scm.RepoExistError("")))
+		})
+
+		It("should fail when repository does not exists", func() {
+			err := scm.RenameRepo(mock, "demo", "none", "test2")
+			Expect(err).To(BeAssignableToTypeOf(scm.RepoNotFoundError("")))
+		})
+	})
+
 	Describe("Populate repository from archive", func() {
 		var message = []byte("This is a test file")
 		var payload = &bytes.Buffer{}
@@ -273,9 +325,31 @@ var _ = Describe("SCM", func() {
 					},
 				}
 
-				actual, err := mock.GetDeploymentBranches("demo", "test")
+				actual, err := mock.GetDeploymentBranches("demo", "test", scm.BranchOptions{})
+				Expect(err).NotTo(HaveOccurred())
+				for _, ref := range actual {
+					Expect(ref.LatestCommit).To(HaveLen(40))
+					Expect(ref.LatestCommitTime.IsZero()).To(BeFalse())
+				}
+				Expect(withoutCommits(actual)).To(ConsistOf(expected))
+			})
+
+			It("should filter and limit deployment branches", func() {
+				Expect(repo.Run("branch", "feature-a")).To(Succeed())
+				Expect(repo.Run("branch", "feature-b")).To(Succeed())
+				Expect(repo.Run("tag", "FEATURE-c")).To(Succeed())
+
+				Expect(mock.CreateNamespace("demo")).To(Succeed())
+				Expect(mock.CreateRepo("demo", "test", false)).To(Succeed())
+				Expect(mock.PopulateURL("demo", "test", tempdir)).To(Succeed())
+
+				actual, err := mock.GetDeploymentBranches("demo", "test", scm.BranchOptions{FilterText: "feature"})
 				Expect(err).NotTo(HaveOccurred())
-				Expect(actual).To(ConsistOf(expected))
+				Expect(actual).To(HaveLen(3))
+
+				actual, err = mock.GetDeploymentBranches("demo", "test", scm.BranchOptions{FilterText: "feature", Limit: 2})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(actual).To(HaveLen(2))
 			})
 		})
 
@@ -288,7 +362,7 @@ var _ = Describe("SCM", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(branch.Id).To(Equal("refs/heads/master"))
 
-				branches, err := mock.GetDeploymentBranches("demo", "test")
+				branches, err := mock.GetDeploymentBranches("demo", "test", scm.BranchOptions{})
 				Expect(err).NotTo(HaveOccurred())
 				Expect(branches).To(BeEmpty())
 			})
@@ -528,3 +602,12 @@ func makeSSHKeyPair() (pub, priv string, err error) {
 
 	return pub, priv, nil
 }
+
+// withoutCommits strips commit information from branches for comparison.
+func withoutCommits(refs []*scm.Branch) []*scm.Branch {
+	result := make([]*scm.Branch, len(refs))
+	for i, ref := range refs {
+		result[i] = &scm.Branch{Id: ref.Id, DisplayId: ref.DisplayId, Type: ref.Type}
+	}
+	return result
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/Sirupsen/logrus"
 	"golang.org/x/crypto/ssh"
 
+	"github.com/cloudway/platform/auth/userdb"
 	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/scm"
 	"github.com/cloudway/platform/sshd"
@@ -25,6 +26,7 @@ import (
 type SSHServer struct {
 	repoRoot string
 	mockscm  scm.SCM
+	users    *userdb.UserDatabase
 	listener net.Listener
 }
 
@@ -35,6 +37,12 @@ func NewSSHServer(repoRoot string) *SSHServer {
 	}
 }
 
+// SetUserDatabase sets the user database used to authorize public keys
+// in addition to the keys stored in repositories.
+func (s *SSHServer) SetUserDatabase(users *userdb.UserDatabase) {
+	s.users = users
+}
+
 func (s *SSHServer) Accept(listener net.Listener) {
 	s.listener = listener
 }
@@ -130,6 +138,12 @@ func (s *SSHServer) checkPublicKey(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.
 	var permitted []string
 	var keyBytes = key.Marshal()
 
+	if s.users != nil {
+		if user, err := s.users.AuthorizeSSHKey(key); err == nil && user.Namespace != "" {
+			permitted = append(permitted, user.Namespace)
+		}
+	}
+
 	for _, ns := range namespaces {
 		if ns.IsDir() {
 			keys, err := s.mockscm.ListKeys(ns.Name())
@@ -0,0 +1,74 @@
+package mock
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudway/platform/scm"
+)
+
+func (mock mockSCM) ResolveCommit(namespace, name, branch string) (string, error) {
+	if empty, err := mock.isEmptyRepository(namespace, name); empty || err != nil {
+		return "", err
+	}
+
+	current, err := mock.getCurrentDeployment(namespace, name, branch)
+	if err != nil {
+		return "", err
+	}
+
+	repo := NewGitRepo(filepath.Join(mock.repositoryRoot, namespace, name))
+	out, err := repo.Output("rev-parse", current.Id+"^{commit}")
+	return strings.TrimSpace(out), err
+}
+
+// Commit statuses are saved in the repository directory as JSON files,
+// one file per commit.
+func (mock mockSCM) statusFile(namespace, name, commit string) string {
+	return filepath.Join(mock.repositoryRoot, namespace, name, "statuses", commit+".json")
+}
+
+func (mock mockSCM) SetCommitStatus(namespace, name, commit string, status *scm.CommitStatus) error {
+	if err := mock.ensureRepositoryExist(namespace, name); err != nil {
+		return err
+	}
+
+	statuses, err := mock.getCommitStatuses(namespace, name, commit)
+	if err != nil {
+		return err
+	}
+	statuses[status.Key] = status
+
+	data, err := json.Marshal(statuses)
+	if err != nil {
+		return err
+	}
+	filename := mock.statusFile(namespace, name, commit)
+	if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+// GetCommitStatus returns the status of the commit with the given key,
+// or nil if no such status.
+func (mock mockSCM) GetCommitStatus(namespace, name, commit, key string) (*scm.CommitStatus, error) {
+	statuses, err := mock.getCommitStatuses(namespace, name, commit)
+	return statuses[key], err
+}
+
+func (mock mockSCM) getCommitStatuses(namespace, name, commit string) (map[string]*scm.CommitStatus, error) {
+	statuses := make(map[string]*scm.CommitStatus)
+	data, err := ioutil.ReadFile(mock.statusFile(namespace, name, commit))
+	if os.IsNotExist(err) {
+		return statuses, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &statuses)
+	return statuses, err
+}
@@ -1,8 +1,12 @@
 package scm
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
+	"time"
 
 	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/container"
@@ -38,13 +42,13 @@ type SCM interface {
 	PopulateURL(namespace, name string, url string) error
 
 	// Deploy application with new commit. Log build output to the give writer.
-	Deploy(engine container.Engine, namespace, name string, branch string, log *serverlog.ServerLog) error
+	Deploy(ctx context.Context, engine container.Engine, namespace, name string, branch string, log *serverlog.ServerLog) error
 
 	// Get the current deployment branch.
 	GetDeploymentBranch(namespace, name string) (*Branch, error)
 
-	// Get all deployment branches.
-	GetDeploymentBranches(namespace, name string) ([]*Branch, error)
+	// Get deployment branches that match the given options.
+	GetDeploymentBranches(namespace, name string, opts BranchOptions) ([]*Branch, error)
 
 	// Add an SSH key to the given namespace.
 	AddKey(namespace string, key string) error
@@ -66,6 +70,37 @@ type Branch struct {
 
 	// The branch type, such as "BRANCH" or "TAG"
 	Type string `json:"type,omitempty"`
+
+	// The hash of the latest commit on the branch.
+	LatestCommit string `json:"latestCommit,omitempty"`
+
+	// The time of the latest commit, zero if unknown.
+	LatestCommitTime time.Time `json:"-"`
+}
+
+// BranchOptions controls which deployment branches are returned.
+type BranchOptions struct {
+	// Only branches with display identifier contains the filter text are
+	// returned, case insensitive.
+	FilterText string
+
+	// The maximum number of branches to return, zero means no limit.
+	Limit int
+}
+
+// FilterBranches returns branches that match the given options.
+func FilterBranches(branches []*Branch, opts BranchOptions) []*Branch {
+	filter := strings.ToLower(opts.FilterText)
+	result := make([]*Branch, 0, len(branches))
+	for _, br := range branches {
+		if opts.Limit > 0 && len(result) >= opts.Limit {
+			break
+		}
+		if filter == "" || strings.Contains(strings.ToLower(br.DisplayId), filter) {
+			result = append(result, br)
+		}
+	}
+	return result
 }
 
 type SSHKey struct {
@@ -73,6 +108,130 @@ type SSHKey struct {
 	Text  string
 }
 
+// Credentials used to access a remote repository.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// AuthPopulator is implemented by SCMs that can populate repository from
+// an URL that requires authentication, without exposing the credentials
+// in the URL.
+type AuthPopulator interface {
+	PopulateURLWithCredentials(namespace, name, url string, cred *Credentials) error
+}
+
+// PopulateURL populates repository from an URL with optional credentials.
+// Credentials are never embedded in the URL, as they would be exposed in
+// server logs and the remote configuration of the cloned repository, so
+// the SCM must implement the AuthPopulator to accept credentials.
+func PopulateURL(s SCM, namespace, name, remote string, cred *Credentials) error {
+	if cred == nil || cred.Username == "" {
+		return s.PopulateURL(namespace, name, remote)
+	}
+	if p, ok := s.(AuthPopulator); ok {
+		return p.PopulateURLWithCredentials(namespace, name, remote, cred)
+	}
+	return errors.New("The SCM does not support credentials for repository URL")
+}
+
+// Commit status states.
+const (
+	StatusPending = "pending"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// CommitStatus describes the result of an operation, such as deployment,
+// performed on a commit.
+type CommitStatus struct {
+	// The status state, one of "pending", "success" or "failed".
+	State string
+
+	// The key that identifies the status, statuses with the same key
+	// replace each other.
+	Key string
+
+	// The status name displayed by SCM.
+	Name string
+
+	// The URL links to the status details.
+	URL string
+
+	// A short description of the status.
+	Description string
+}
+
+// StatusReporter is implemented by SCMs that can attach statuses to
+// commits, so developers can see deployment results next to their commits.
+type StatusReporter interface {
+	// Resolve the commit hash of the given branch. The current deployment
+	// branch is resolved if the branch is empty.
+	ResolveCommit(namespace, name, branch string) (string, error)
+
+	// Set the status of the given commit.
+	SetCommitStatus(namespace, name, commit string, status *CommitStatus) error
+}
+
+// Cache is implemented by SCMs that cache results of expensive operations
+// such as listing deployment branches.
+type Cache interface {
+	// Remove cached data of the given repository, so that the next
+	// operation gets fresh data from the SCM.
+	InvalidateCache(namespace, name string)
+}
+
+// InvalidateCache removes cached data of the given repository if the SCM
+// supports caching.
+func InvalidateCache(s SCM, namespace, name string) {
+	if c, ok := s.(Cache); ok {
+		c.InvalidateCache(namespace, name)
+	}
+}
+
+// Pinger is implemented by SCMs that can check connectivity to the SCM
+// server.
+type Pinger interface {
+	Ping() error
+}
+
+// Ping checks connectivity to the SCM server, it always succeeds if the SCM
+// doesn't support the check.
+func Ping(s SCM) error {
+	if p, ok := s.(Pinger); ok {
+		return p.Ping()
+	}
+	return nil
+}
+
+// NamespaceRenamer is implemented by SCMs that can rename a namespace with
+// all repositories in it.
+type NamespaceRenamer interface {
+	RenameNamespace(oldNamespace, newNamespace string) error
+}
+
+// RenameNamespace renames the namespace if the SCM supports renaming.
+func RenameNamespace(s SCM, oldNamespace, newNamespace string) error {
+	if r, ok := s.(NamespaceRenamer); ok {
+		return r.RenameNamespace(oldNamespace, newNamespace)
+	}
+	return fmt.Errorf("The %s SCM doesn't support renaming namespace", s.Type())
+}
+
+// RepoRenamer is implemented by SCMs that can rename a repository in
+// place, preserving its history.
+type RepoRenamer interface {
+	RenameRepo(namespace, oldName, newName string) error
+}
+
+// RenameRepo renames the repository if the SCM supports renaming.
+func RenameRepo(s SCM, namespace, oldName, newName string) error {
+	if r, ok := s.(RepoRenamer); ok {
+		return r.RenameRepo(namespace, oldName, newName)
+	}
+	return fmt.Errorf("The %s SCM doesn't support renaming repository", s.Type())
+}
+
 var New = func() (SCM, error) {
 	scmtype := config.Get("scm.type")
 	if scmtype == "" {
@@ -14,6 +14,7 @@ import (
 	"github.com/Sirupsen/logrus"
 	"golang.org/x/crypto/ssh"
 
+	"github.com/cloudway/platform/auth/userdb"
 	conf "github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/container"
 	"github.com/cloudway/platform/scm"
@@ -25,9 +26,20 @@ func Serve(engine container.Engine, addr string) error {
 		return err
 	}
 
+	users, err := userdb.Open()
+	if err != nil {
+		return err
+	}
+
+	return ServeWith(engine, scm, users, addr)
+}
+
+// ServeWith serves SSH connections authenticated against the given SCM and
+// user database, which may be shared with other services in the process.
+func ServeWith(engine container.Engine, scm scm.SCM, users *userdb.UserDatabase, addr string) error {
 	config := &ssh.ServerConfig{
 		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
-			return nil, checkPublicKey(engine, scm, c.User(), key)
+			return nil, checkPublicKey(engine, scm, users, c.User(), key)
 		},
 	}
 
@@ -102,12 +114,22 @@ func findContainer(engine container.Engine, userInfo string) (container.Containe
 	}
 }
 
-func checkPublicKey(engine container.Engine, scm scm.SCM, userInfo string, key ssh.PublicKey) error {
+func checkPublicKey(engine container.Engine, scm scm.SCM, users *userdb.UserDatabase, userInfo string, key ssh.PublicKey) error {
 	c, err := findContainer(engine, userInfo)
 	if err != nil {
 		return err
 	}
 
+	// keys stored in user database take precedence over keys in SCM
+	user, err := users.AuthorizeSSHKey(key)
+	if err == nil {
+		if user.Namespace == c.Namespace() {
+			return nil
+		}
+	} else if !userdb.IsUserNotFound(err) {
+		return err
+	}
+
 	keys, err := scm.ListKeys(c.Namespace())
 	if err != nil {
 		return err
@@ -157,6 +179,11 @@ func handleChannel(newChannel ssh.NewChannel, c container.Container) {
 
 	// session have out-of-band requests such as "shell", "pty-req" and "env"
 	go func() {
+		// the context is canceled when the channel is closed by client,
+		// which aborts the running commands
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
 		var (
 			pty    *pty_req
 			lang   string
@@ -178,11 +205,18 @@ func handleChannel(newChannel ssh.NewChannel, c container.Container) {
 				}
 				req.Reply(true, nil)
 			case "shell":
-				execId, err = execShell(channel, c, pty, lang)
+				execId, err = execShell(ctx, channel, c, pty, lang)
 				req.Reply(err == nil, nil)
 			case "exec":
 				if cmd, _, ok := decodeString(req.Payload); ok {
-					go execCmd(channel, c, string(cmd))
+					go execCmd(ctx, channel, c, string(cmd))
+				} else {
+					req.Reply(false, nil)
+				}
+			case "subsystem":
+				if name, _, ok := decodeString(req.Payload); ok && string(name) == "sftp" {
+					req.Reply(true, nil)
+					go execSftp(ctx, channel, c)
 				} else {
 					req.Reply(false, nil)
 				}
@@ -190,7 +224,7 @@ func handleChannel(newChannel ssh.NewChannel, c container.Container) {
 				if execId != "" {
 					if dims := decodeWindowChange(req.Payload); dims != nil {
 						resize := container.TtySize{Width: int(dims.Width), Height: int(dims.Height)}
-						c.ExecResize(context.Background(), execId, resize)
+						c.ExecResize(ctx, execId, resize)
 					}
 				}
 			default:
@@ -200,7 +234,7 @@ func handleChannel(newChannel ssh.NewChannel, c container.Container) {
 	}()
 }
 
-func execShell(channel ssh.Channel, c container.Container, pty *pty_req, lang string) (execId string, err error) {
+func execShell(ctx context.Context, channel ssh.Channel, c container.Container, pty *pty_req, lang string) (execId string, err error) {
 	// construct command to run in sandbox, passing TERM environment variable
 	command := []string{"/usr/bin/cwctl", "sh"}
 	if pty != nil {
@@ -230,15 +264,28 @@ func execShell(channel ssh.Channel, c container.Container, pty *pty_req, lang st
 		logrus.Debug("Session closed")
 	}
 
-	err = c.Run(context.Background(), cmd)
+	err = c.Run(ctx, cmd)
 	return cmd.ExecID, err
 }
 
-func execCmd(channel ssh.Channel, c container.Container, args string) {
+func execCmd(ctx context.Context, channel ssh.Channel, c container.Container, args string) {
+	logrus.Debugf("exec: %s", args)
+	runCommand(ctx, channel, c, "/usr/bin/cwsh", "-c", args)
+}
+
+// execSftp runs the SFTP server within the container, starting in the
+// application home directory that contains the repo and data directories.
+// The SFTP server program can be configured by the "sshd.sftp_server" key.
+func execSftp(ctx context.Context, channel ssh.Channel, c container.Container) {
+	server := conf.GetOrDefault("sshd.sftp_server", "/usr/lib/openssh/sftp-server")
+	logrus.Debugf("sftp: %s", c.Name())
+	runCommand(ctx, channel, c, server, "-d", c.Home())
+}
+
+func runCommand(ctx context.Context, channel ssh.Channel, c container.Container, cmd ...string) {
 	defer channel.Close()
 
-	logrus.Debugf("exec: %s", args)
-	err := c.Exec(context.Background(), "", channel, channel, channel.Stderr(), "/usr/bin/cwsh", "-c", args)
+	err := c.Exec(ctx, "", channel, channel, channel.Stderr(), cmd...)
 
 	var exitCode int
 	if se, ok := err.(container.StatusError); ok {